@@ -0,0 +1,40 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/gctuning"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// updateGCFromConfigMap passes the tuning of the garbage collector of the
+// activator in the deployment config map to apply. Invalid configurations
+// are ignored, keeping the current tuning.
+func updateGCFromConfigMap(logger *zap.SugaredLogger, apply func(gctuning.Config)) func(configMap *corev1.ConfigMap) {
+	return func(configMap *corev1.ConfigMap) {
+		config, err := gctuning.Parse(configMap.Data[deployment.ActivatorGOGCKey],
+			configMap.Data[deployment.ActivatorMemoryLimitKey], configMap.Data[deployment.ActivatorBallastKey])
+		if err != nil {
+			logger.Errorw("Failed to update the garbage collection tuning.", zap.Error(err))
+			return
+		}
+		apply(config)
+		logger.Infof("Updated the garbage collection tuning to %+v.", config)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/gctuning"
+	testing2 "knative.dev/pkg/logging/testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestUpdateGCFromConfigMap(t *testing.T) {
+	defer testing2.ClearAll()
+	var applied []gctuning.Config
+	update := updateGCFromConfigMap(testing2.TestLogger(t), func(c gctuning.Config) {
+		applied = append(applied, c)
+	})
+
+	update(&corev1.ConfigMap{
+		Data: map[string]string{
+			deployment.ActivatorGOGCKey:        "400",
+			deployment.ActivatorMemoryLimitKey: "2Gi",
+			deployment.ActivatorBallastKey:     "512Mi",
+			// The tuning of queue-proxy doesn't apply to the activator.
+			deployment.QueueSidecarGOGCKey: "off",
+		},
+	})
+	// Invalid tunings are ignored.
+	update(&corev1.ConfigMap{
+		Data: map[string]string{
+			deployment.ActivatorGOGCKey: "none",
+		},
+	})
+	// Removed tunings restore the defaults.
+	update(&corev1.ConfigMap{})
+
+	want := []gctuning.Config{{
+		GCPercent:   400,
+		MemoryLimit: 2 << 30,
+		Ballast:     512 << 20,
+	}, {}}
+	if len(applied) != len(want) {
+		t.Fatalf("Applied %d configs, want: %d", len(applied), len(want))
+	}
+	for i := range want {
+		if applied[i] != want[i] {
+			t.Errorf("Applied config #%d = %+v, want: %+v", i, applied[i], want[i])
+		}
+	}
+}
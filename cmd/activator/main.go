@@ -23,6 +23,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"knative.dev/pkg/configmap"
@@ -39,9 +40,12 @@ import (
 	activatorconfig "github.com/knative/serving/pkg/activator/config"
 	activatorhandler "github.com/knative/serving/pkg/activator/handler"
 	"github.com/knative/serving/pkg/apis/networking"
+	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/autoscaler"
 	clientset "github.com/knative/serving/pkg/client/clientset/versioned"
 	servinginformers "github.com/knative/serving/pkg/client/informers/externalversions"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/gctuning"
 	"github.com/knative/serving/pkg/goversion"
 	pkghttp "github.com/knative/serving/pkg/http"
 	"github.com/knative/serving/pkg/logging"
@@ -52,6 +56,7 @@ import (
 	zipkin "github.com/openzipkin/zipkin-go"
 	perrors "github.com/pkg/errors"
 	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -80,6 +85,23 @@ const (
 	// As new endpoints show up, the Breakers concurrency increases up to this value.
 	breakerMaxConcurrency = 1000
 
+	// The number of requests the activator holds across all revisions
+	// before it sheds new ones.
+	overloadMaxRequests = 30000
+
+	// The number of goroutines the activator runs before it sheds new requests.
+	overloadMaxGoroutines = 100000
+
+	// The fraction of the container's memory limit the heap may use before
+	// the activator sheds new requests.
+	overloadHeapFraction = 0.8
+
+	// The number of body bytes the request journal keeps per request.
+	requestJournalMaxBodyBytes = 64 << 10
+
+	// How often to report the pauses of the garbage collector.
+	gcReportingPeriod = 10 * time.Second
+
 	// The port on which autoscaler WebSocket server listens.
 	autoscalerPort = 8080
 
@@ -155,6 +177,10 @@ func main() {
 	if err != nil {
 		logger.Fatalw("Failed to create stats reporter", zap.Error(err))
 	}
+	gcReporter, err := gctuning.NewReporter(context.Background())
+	if err != nil {
+		logger.Fatalw("Failed to create garbage collector reporter", zap.Error(err))
+	}
 
 	// Set up signals so we handle the first shutdown signal gracefully.
 	stopCh := signals.SetupSignalHandler()
@@ -168,8 +194,17 @@ func main() {
 	servingInformerFactory := servinginformers.NewSharedInformerFactory(servingClient, defaultResyncInterval)
 	endpointInformer := kubeInformerFactory.Core().V1().Endpoints()
 	serviceInformer := kubeInformerFactory.Core().V1().Services()
+	// The ConfigMaps holding the static assets of the revisions. Only the
+	// ones labeled as such are cached, rather than all of the cluster's.
+	staticAssetsInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, defaultResyncInterval,
+		kubeinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = serving.StaticAssetsLabelKey
+		}))
+	configMapInformer := staticAssetsInformerFactory.Core().V1().ConfigMaps()
+	namespaceInformer := kubeInformerFactory.Core().V1().Namespaces()
 	revisionInformer := servingInformerFactory.Serving().V1alpha1().Revisions()
 	sksInformer := servingInformerFactory.Networking().V1alpha1().ServerlessServices()
+	paInformer := servingInformerFactory.Autoscaling().V1alpha1().PodAutoscalers()
 
 	// Run informers instead of starting them from the factory to prevent the sync hanging because of empty handler.
 	if err := controller.StartInformers(
@@ -177,12 +212,17 @@ func main() {
 		revisionInformer.Informer(),
 		endpointInformer.Informer(),
 		serviceInformer.Informer(),
-		sksInformer.Informer()); err != nil {
+		configMapInformer.Informer(),
+		namespaceInformer.Informer(),
+		sksInformer.Informer(),
+		paInformer.Informer()); err != nil {
 		logger.Fatalw("Failed to start informers", zap.Error(err))
 	}
 
 	params := queue.BreakerParams{QueueDepth: breakerQueueDepth, MaxConcurrency: breakerMaxConcurrency, InitialCapacity: 0}
 	throttler := activator.NewThrottler(params, endpointInformer, sksInformer.Lister(), revisionInformer.Lister(), logger)
+	throttler.WatchPodAutoscalers(paInformer)
+	go throttler.Run(stopCh)
 
 	activatorL3 := fmt.Sprintf("%s:%d", activator.K8sServiceName, networking.ServiceHTTPPort)
 	zipkinEndpoint, err := zipkin.NewEndpoint("activator", activatorL3)
@@ -192,6 +232,10 @@ func main() {
 	}
 	oct := tracing.NewOpenCensusTracer(
 		tracing.WithZipkinExporter(tracing.CreateZipkinReporter, zipkinEndpoint),
+		tracing.WithOTLPExporter(map[string]string{
+			"service.name":       "activator",
+			"k8s.namespace.name": system.Namespace(),
+		}),
 	)
 
 	tracerUpdater := configmap.TypeFilter(&tracingconfig.Config{})(func(name string, value interface{}) {
@@ -213,6 +257,10 @@ func main() {
 	statSink := websocket.NewDurableSendingConnection(autoscalerEndpoint, logger)
 	go statReporter(statSink, stopCh, statChan, logger)
 
+	gcTicker := time.NewTicker(gcReportingPeriod)
+	defer gcTicker.Stop()
+	go gcReporter.Run(gcTicker.C, stopCh)
+
 	podName := util.GetRequiredEnvOrFatal("POD_NAME", logger)
 
 	// Create and run our concurrency reporter
@@ -230,9 +278,16 @@ func main() {
 		revisionInformer.Lister(),
 		serviceInformer.Lister(),
 		sksInformer.Lister(),
+		requestJournal(logger),
+		activator.NewRateLimiter(throttler.ActivatorCount),
+		activator.NewNamespaceLimiter(namespaceInformer.Lister(), throttler.ActivatorCount),
+		activator.NewStaticAssets(configMapInformer.Lister(), http.DefaultTransport),
+		// Drop the keep-alive connections to the pods going away, e.g. on
+		// scale down, rather than racing with their shutdown.
+		activator.NewRevisionTransports(network.NewAutoTransport, endpointInformer, logger),
 	)
 	ah = activatorhandler.NewRequestEventHandler(reqChan, ah)
-	ah = tracing.HTTPSpanMiddleware(ah)
+	ah = tracing.HTTPSpanMiddlewareWithSampling(ah, revisionSamplingPolicy(revisionInformer.Lister()))
 	ah = configStore.HTTPMiddleware(ah)
 	reqLogHandler, err := pkghttp.NewRequestLogHandler(ah, logging.NewSyncFileWriter(os.Stdout), "",
 		requestLogTemplateInputGetter(revisionInformer.Lister()))
@@ -240,6 +295,11 @@ func main() {
 		logger.Fatalw("Unable to create request log handler", zap.Error(err))
 	}
 	ah = reqLogHandler
+	overloadTicker := time.NewTicker(time.Second)
+	defer overloadTicker.Stop()
+	oh := activatorhandler.NewOverloadHandler(overloadBudget(logger), overloadTicker.C, ah)
+	go oh.Run(stopCh)
+	ah = oh
 	ah = &activatorhandler.ProbeHandler{NextHandler: ah}
 	ah = &activatorhandler.HealthHandler{HealthCheck: statSink.Status, NextHandler: ah}
 
@@ -249,6 +309,8 @@ func main() {
 	configMapWatcher.Watch(metrics.ConfigMapName(), metrics.UpdateExporterFromConfigMap(component, logger))
 	// Watch the observability config map and dynamically update request logs.
 	configMapWatcher.Watch(metrics.ConfigMapName(), updateRequestLogFromConfigMap(logger, reqLogHandler))
+	// Watch the deployment config map and dynamically tune the garbage collector.
+	configMapWatcher.Watch(deployment.ConfigName, updateGCFromConfigMap(logger, gctuning.Apply))
 	if err = configMapWatcher.Start(stopCh); err != nil {
 		logger.Fatalw("Failed to start configuration manager", zap.Error(err))
 	}
@@ -280,6 +342,40 @@ func main() {
 	}
 }
 
+// overloadBudget returns the budget of the activator. The heap budget is
+// derived from the MEMORY_LIMIT of the container, and unbounded without it.
+func overloadBudget(logger *zap.SugaredLogger) activatorhandler.OverloadBudget {
+	budget := activatorhandler.OverloadBudget{
+		MaxRequests:   overloadMaxRequests,
+		MaxGoroutines: overloadMaxGoroutines,
+	}
+	if raw := os.Getenv("MEMORY_LIMIT"); raw != "" {
+		limit, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			logger.Fatalw("Invalid MEMORY_LIMIT "+raw, zap.Error(err))
+		}
+		budget.MaxHeapBytes = uint64(float64(limit) * overloadHeapFraction)
+	}
+	return budget
+}
+
+// requestJournal returns the journal of the requests the activator fails,
+// appended to REQUEST_JOURNAL_PATH. Journaling is disabled without it.
+func requestJournal(logger *zap.SugaredLogger) *activator.Journal {
+	path := os.Getenv("REQUEST_JOURNAL_PATH")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Fatalw("Unable to open request journal "+path, zap.Error(err))
+	}
+	return &activator.Journal{
+		Sink:         activator.NewWriterJournalSink(f),
+		MaxBodyBytes: requestJournalMaxBodyBytes,
+	}
+}
+
 func flush(logger *zap.SugaredLogger) {
 	logger.Sync()
 	os.Stdout.Sync()
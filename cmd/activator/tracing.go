@@ -0,0 +1,40 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/knative/serving/pkg/activator"
+	servinglisters "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
+	pkghttp "github.com/knative/serving/pkg/http"
+	"github.com/knative/serving/pkg/tracing"
+)
+
+// revisionSamplingPolicy returns the tracing SamplingPolicy set by the
+// annotations of the revision a request is addressed to.
+func revisionSamplingPolicy(revisionLister servinglisters.RevisionLister) tracing.SamplingPolicyFunc {
+	return func(req *http.Request) *tracing.SamplingPolicy {
+		namespace := pkghttp.LastHeaderValue(req.Header, activator.RevisionHeaderNamespace)
+		name := pkghttp.LastHeaderValue(req.Header, activator.RevisionHeaderName)
+		revision, err := revisionLister.Revisions(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		return tracing.SamplingPolicyFromAnnotations(revision.Annotations)
+	}
+}
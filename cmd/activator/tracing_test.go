@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/knative/serving/pkg/activator"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/client/clientset/versioned/fake"
+	servinginformers "github.com/knative/serving/pkg/client/informers/externalversions"
+)
+
+func TestRevisionSamplingPolicy(t *testing.T) {
+	rev := &v1alpha1.Revision{}
+	rev.Name = testRevisionName
+	rev.Namespace = testNamespaceName
+	rev.Annotations = map[string]string{
+		serving.TracingSampleOnErrorAnnotationKey: "true",
+	}
+	informer := servinginformers.NewSharedInformerFactory(fake.NewSimpleClientset(rev), 0)
+	revisions := informer.Serving().V1alpha1().Revisions()
+	revisions.Informer().GetIndexer().Add(rev)
+	policy := revisionSamplingPolicy(revisions.Lister())
+
+	tests := []struct {
+		name       string
+		revision   string
+		wantPolicy bool
+	}{{
+		name:       "annotated revision",
+		revision:   testRevisionName,
+		wantPolicy: true,
+	}, {
+		name:     "unknown revision",
+		revision: "unknown",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(activator.RevisionHeaderNamespace, testNamespaceName)
+			req.Header.Set(activator.RevisionHeaderName, test.revision)
+
+			p := policy(req)
+			if got := p != nil; got != test.wantPolicy {
+				t.Fatalf("policy() = %v, want policy: %v", p, test.wantPolicy)
+			}
+			if p != nil && !p.SampleOnError {
+				t.Error("SampleOnError = false, want: true")
+			}
+		})
+	}
+}
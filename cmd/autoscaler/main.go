@@ -32,6 +32,7 @@ import (
 	pkgmetrics "knative.dev/pkg/metrics"
 	"knative.dev/pkg/signals"
 	"knative.dev/pkg/system"
+	"github.com/knative/serving/pkg/activator"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/autoscaler"
 	"github.com/knative/serving/pkg/autoscaler/statserver"
@@ -53,6 +54,15 @@ const (
 	statsServerAddr = ":8080"
 	statsBufferLen  = 1000
 	component       = "autoscaler"
+
+	// How long the client usage reported by a queue-proxy counts towards
+	// the client quotas of its revision. The reports are sent every
+	// quarter second.
+	clientQuotaReportTTL = 2 * time.Second
+
+	// How often the wall clock is compared with the monotonic clock to
+	// detect steps of the clock.
+	clockSkewInterval = 10 * time.Second
 )
 
 var (
@@ -110,7 +120,14 @@ func main() {
 
 	endpointsInformer := endpointsinformer.Get(ctx)
 
-	collector := autoscaler.NewMetricCollector(statsScraperFactoryFunc(endpointsInformer.Lister()), logger)
+	// The queue-proxies only serve their stats to scrapers holding a token
+	// derived from this key.
+	statsKey, err := autoscaler.EnsureStatsKey(kubeclient.Get(ctx), system.Namespace())
+	if err != nil {
+		logger.Fatalw("Failed to get the stats key", zap.Error(err))
+	}
+
+	collector := autoscaler.NewMetricCollector(statsScraperFactoryFunc(endpointsInformer.Lister(), statsKey), logger)
 	customMetricsAdapter.WithCustomMetrics(autoscaler.NewMetricProvider(collector))
 
 	// Set up scalers.
@@ -124,7 +141,12 @@ func main() {
 	}
 
 	// Set up a statserver.
-	statsServer := statserver.New(statsServerAddr, statsCh, logger)
+	// Only the activators push stats, and only for themselves.
+	statsValidator := statserver.NewPodValidator(endpointsInformer.Lister(), system.Namespace(), activator.K8sServiceName)
+	statsServer := statserver.New(statsServerAddr, statsCh, statsValidator, logger)
+	// The queue-proxies only coordinate the quotas of their own revision.
+	statsServer.Handle(autoscaler.ClientQuotaPath, autoscaler.NewClientQuotaCoordinator(clientQuotaReportTTL,
+		statserver.NewRevisionPodValidator(endpointsInformer.Lister())))
 
 	// Start watching the configs.
 	if err := cmw.Start(ctx.Done()); err != nil {
@@ -137,6 +159,7 @@ func main() {
 	}
 
 	go controller.StartAll(ctx.Done(), controllers...)
+	go autoscaler.NewClockSkewMonitor(logger).Run(clockSkewInterval, ctx.Done())
 
 	go func() {
 		for sm := range statsCh {
@@ -184,10 +207,10 @@ func uniScalerFactoryFunc(endpointsInformer corev1informers.EndpointsInformer, m
 	}
 }
 
-func statsScraperFactoryFunc(endpointsLister corev1listers.EndpointsLister) func(metric *autoscaler.Metric) (autoscaler.StatsScraper, error) {
+func statsScraperFactoryFunc(endpointsLister corev1listers.EndpointsLister, statsKey []byte) func(metric *autoscaler.Metric) (autoscaler.StatsScraper, error) {
 	return func(metric *autoscaler.Metric) (autoscaler.StatsScraper, error) {
 		podCounter := resources.NewScopedEndpointsCounter(endpointsLister, metric.Namespace, metric.Spec.ScrapeTarget)
-		return autoscaler.NewServiceScraper(metric, podCounter)
+		return autoscaler.NewServiceScraper(metric, podCounter, statsKey)
 	}
 }
 
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/autoscaler"
@@ -135,3 +136,7 @@ type testMetricClient struct{}
 func (t *testMetricClient) StableAndPanicConcurrency(key string) (float64, float64, error) {
 	return 1.0, 1.0, nil
 }
+
+func (t *testMetricClient) LatestStatTime(key string) (time.Time, error) {
+	return time.Now(), nil
+}
@@ -19,7 +19,10 @@ package main
 import (
 	// The set of controllers this controller process runs.
 	"github.com/knative/serving/pkg/reconciler/configuration"
+	"github.com/knative/serving/pkg/reconciler/configvalidation"
 	"github.com/knative/serving/pkg/reconciler/labeler"
+	"github.com/knative/serving/pkg/reconciler/orphan"
+	"github.com/knative/serving/pkg/reconciler/report"
 	"github.com/knative/serving/pkg/reconciler/revision"
 	"github.com/knative/serving/pkg/reconciler/route"
 	"github.com/knative/serving/pkg/reconciler/serverlessservice"
@@ -32,7 +35,12 @@ import (
 func main() {
 	sharedmain.Main("controller",
 		configuration.NewController,
+		configvalidation.NewController,
 		labeler.NewRouteToConfigurationController,
+		orphan.NewClusterIngressController,
+		orphan.NewCertificateController,
+		orphan.NewServerlessServiceController,
+		report.NewController,
 		revision.NewController,
 		route.NewController,
 		serverlessservice.NewController,
@@ -20,10 +20,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -31,6 +33,7 @@ import (
 
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/system"
+	"github.com/knative/serving/pkg/network"
 	cicfg "github.com/knative/serving/pkg/reconciler/ingress/config"
 	routecfg "github.com/knative/serving/pkg/reconciler/route/config"
 	corev1 "k8s.io/api/core/v1"
@@ -90,23 +93,44 @@ func lookupIngressGateway(kubeClient *kubernetes.Clientset) (*corev1.Service, er
 	return kubeClient.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
 }
 
-func lookupIngressGatewayAddress(kubeClient *kubernetes.Clientset) (*corev1.LoadBalancerIngress, error) {
+// lookupPreferredIPFamily returns the IP family preferred by the network
+// config, defaulting to IPv4 if the ConfigMap doesn't exist.
+func lookupPreferredIPFamily(kubeClient *kubernetes.Clientset) (network.IPFamily, error) {
+	networkCM, err := lookupConfigMap(kubeClient, network.ConfigName)
+	if apierrs.IsNotFound(err) {
+		return network.IPv4, nil
+	} else if err != nil {
+		return "", err
+	}
+	networkConfig, err := network.NewConfigFromConfigMap(networkCM)
+	if err != nil {
+		return "", fmt.Errorf("error parsing ConfigMap: %v", err)
+	}
+	return networkConfig.PreferredIPFamily, nil
+}
+
+func lookupIngressGatewayAddress(kubeClient *kubernetes.Clientset, family network.IPFamily) (*corev1.LoadBalancerIngress, error) {
 	svc, err := lookupIngressGateway(kubeClient)
 	if err != nil {
 		return nil, fmt.Errorf("error looking up IngressGateway: %v", err)
 	}
 	// Walk the list of Ingress entries in the Service's LoadBalancer status.
-	// If one of IP address is found, return it.  Otherwise, keep one with
-	// a hostname assigned, if any.
+	// If an IP address is found, return the one of the preferred family,
+	// dual-stack load balancers report one of each.  Otherwise, keep one
+	// with a hostname assigned, if any.
+	var ips []string
 	var hostname *corev1.LoadBalancerIngress
 	for i, ing := range svc.Status.LoadBalancer.Ingress {
 		if ing.IP != "" {
-			return &svc.Status.LoadBalancer.Ingress[i], nil
+			ips = append(ips, ing.IP)
 		}
 		if ing.Hostname != "" {
 			hostname = &svc.Status.LoadBalancer.Ingress[i]
 		}
 	}
+	if ip := network.PreferredIP(ips, family); ip != "" {
+		return &corev1.LoadBalancerIngress{IP: ip}, nil
+	}
 	if hostname != nil {
 		return hostname, nil
 	}
@@ -114,10 +138,10 @@ func lookupIngressGatewayAddress(kubeClient *kubernetes.Clientset) (*corev1.Load
 		svc.Namespace, svc.Name)
 }
 
-func waitForIngressGatewayAddress(kubeclient *kubernetes.Clientset) (addr *corev1.LoadBalancerIngress, waitErr error) {
+func waitForIngressGatewayAddress(kubeclient *kubernetes.Clientset, family network.IPFamily) (addr *corev1.LoadBalancerIngress, waitErr error) {
 	logger := logging.FromContext(context.Background()).Named(appName)
 	waitErr = wait.PollImmediate(pollInterval, waitTimeout, func() (done bool, err error) {
-		addr, err = lookupIngressGatewayAddress(kubeclient)
+		addr, err = lookupIngressGatewayAddress(kubeclient, family)
 		if err == nil {
 			return true, nil
 		}
@@ -153,8 +177,13 @@ func main() {
 		return
 	}
 
+	family, err := lookupPreferredIPFamily(kubeClient)
+	if err != nil {
+		logger.Fatalw("Error getting preferred IP family", zap.Error(err))
+	}
+
 	// Look up the address for IngressGateway.
-	address, err := waitForIngressGatewayAddress(kubeClient)
+	address, err := waitForIngressGatewayAddress(kubeClient, family)
 	if err != nil {
 		logger.Fatalw("Error waiting for IngressGateway address", zap.Error(err))
 	}
@@ -163,12 +192,12 @@ func main() {
 		return
 	}
 
-	// Use the IP (assumes IPv4) to set up a magic DNS name under a top-level Magic
+	// Use the IP to set up a magic DNS name under a top-level Magic
 	// DNS service like xip.io or nip.io, where:
 	//     1.2.3.4.xip.io  ===(magically resolves to)===> 1.2.3.4
 	// Add this magic DNS name without a label selector to the ConfigMap,
 	// and send it back to the API server.
-	domain := fmt.Sprintf("%s.%s", address.IP, *magicDNS)
+	domain := magicDomain(address.IP, *magicDNS)
 	domainCM.Data[domain] = ""
 	if _, err = kubeClient.CoreV1().ConfigMaps(system.Namespace()).Update(domainCM); err != nil {
 		logger.Fatalw("Error updating ConfigMap", zap.Error(err))
@@ -176,3 +205,14 @@ func main() {
 
 	logger.Infof("Updated default domain to: %s", domain)
 }
+
+// magicDomain returns the magic DNS name resolving to the given IP. Colons
+// aren't allowed in DNS names, so IPv6 addresses are written with dashes
+// instead, the form understood by IPv6 capable services like sslip.io, e.g.
+// fd00--1.sslip.io resolves to fd00::1.
+func magicDomain(ip, magicDNS string) string {
+	if network.FamilyOf(net.ParseIP(ip)) == network.IPv6 {
+		ip = strings.Replace(ip, ":", "-", -1)
+	}
+	return fmt.Sprintf("%s.%s", ip, magicDNS)
+}
@@ -18,8 +18,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -27,27 +29,37 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/knative/serving/cmd/util"
 	"github.com/knative/serving/pkg/activator"
 	activatorutil "github.com/knative/serving/pkg/activator/util"
 	"github.com/knative/serving/pkg/apis/networking"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
 	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/gctuning"
 	pkghttp "github.com/knative/serving/pkg/http"
 	"github.com/knative/serving/pkg/logging"
 	"github.com/knative/serving/pkg/network"
+	"github.com/knative/serving/pkg/otlp"
 	"github.com/knative/serving/pkg/queue"
 	"github.com/knative/serving/pkg/queue/health"
 	queuestats "github.com/knative/serving/pkg/queue/stats"
+	"github.com/knative/serving/pkg/tracing"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 	"github.com/pkg/errors"
 
+	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
 	"go.uber.org/zap"
 
 	"knative.dev/pkg/logging/logkey"
 	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/signals"
+	"knative.dev/pkg/system"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 )
@@ -71,6 +83,34 @@ const (
 	// advantage of the full window
 	probeTimeout = 10 * time.Second
 
+	// How long each HTTP readiness probe of the user-container may take
+	// unless the probe says otherwise, which matches the kubelet's default.
+	defaultHTTPProbeTimeout = time.Second
+
+	// How often to check the pod's annotations for configuration changes.
+	// Kubelet refreshes downward API volumes on its own sync period, so
+	// there is little point in polling more often.
+	dynamicConfigPollPeriod = 5 * time.Second
+
+	// How often to report the pauses of the garbage collector.
+	gcReportingPeriod = 10 * time.Second
+
+	// How often to share the usage of the client quota with the other pods
+	// of the revision through the autoscaler, and the port it listens on.
+	clientQuotaSyncPeriod = 250 * time.Millisecond
+	autoscalerPort        = 8080
+
+	// How long fetching the JSON Web Key Set of the request authentication
+	// may take.
+	jwksFetchTimeout = 5 * time.Second
+
+	// How long a response is cached for unless the revision says otherwise.
+	defaultResponseCacheTTL = 10 * time.Second
+
+	// How long a client of the UDP port of the user container counts as a
+	// request after its last datagram.
+	udpSessionIdleTimeout = time.Minute
+
 	badProbeTemplate = "unexpected probe header value: %s"
 
 	// Metrics' names (without component prefix).
@@ -78,12 +118,18 @@ const (
 	responseTimeInMsecN    = "request_latencies"
 	appRequestCountN       = "app_request_count"
 	appResponseTimeInMsecN = "app_request_latencies"
+	requestCPUSecondsN     = "request_cpu_seconds"
+	queueWaitSecondsN      = "queue_wait_seconds"
+	requestSizeBytesN      = "request_size_bytes"
+	responseSizeBytesN     = "response_size_bytes"
+
+	// cgroupRoot is where the cgroup filesystem is mounted, to sample
+	// the CPU usage for the requests.
+	cgroupRoot = "/sys/fs/cgroup"
 
 	// requestQueueHealthPath specifies the path for health checks for
 	// queue-proxy.
 	requestQueueHealthPath = "/health"
-
-	healthURLTemplate = "http://127.0.0.1:%d" + requestQueueHealthPath
 )
 
 var (
@@ -103,12 +149,62 @@ var (
 	enableVarLogCollection bool
 	varLogVolumeName       string
 	internalVolumePath     string
+	statsSocketPath        string
+	statsToken             string
+	concurrencyStateURL    string
+	enableCheckpoint       bool
+	enableEarlyHints       bool
+	enableDynamicCC        bool
+	enableConfigReload     bool
+	releaseOnHeaders       bool
+	enableCPUAccounting    bool
+	maintenanceMode        bool
+	enableGRPCWeb          bool
+	samplingPolicy         *tracing.SamplingPolicy
+	requestWeightHeader    string
+	requestCosts           serving.RequestCosts
+	clientQuota            *serving.ClientQuota
+	requestAuthentication  *serving.RequestAuthentication
+	authProbePaths         []string
+
+	// The peers whose X-Forwarded-Client-Cert header is honored.
+	clientCertTrustedProxies []*net.IPNet
+
+	requestsPerSecondLimit int
+	requestsPerSecondBurst int
+	pathBreakers           *queue.PathBreakers
+	webSocketIdleWindow    time.Duration
+	retryBufferBytes       int
+	retryMethods           []string
+	responseCacheBytes     int
+	responseCacheTTL       = defaultResponseCacheTTL
+	maxRequestBytes        int
+	maxResponseBytes       int
+	queueDiscipline        queue.QueueDiscipline
+	overflowURL            string
+	reportQueueWait        func(time.Duration)
+	userHTTPProbe          *health.HTTPProbeConfig
+	userHTTPTimeout        time.Duration
+	maxHeaderBytes         int
+	maxConnections         int
+	readHeaderTimeout      time.Duration
+	maxRevisionTimeout     time.Duration
+	gcBallastBytes         int
+	userL4Port             int
+	userL4Protocol         string
+	tracingConfig          *tracingconfig.Config
+	metricsOTLPEndpoint    string
+	metricsOTLPProtocol    string
 	reqChan                = make(chan queue.ReqEvent, requestCountingQueueLength)
 	logger                 *zap.SugaredLogger
 	breaker                *queue.Breaker
 
 	httpProxy *httputil.ReverseProxy
 
+	// The exporters flushed on exit, when set up.
+	oct                 *tracing.OpenCensusTracer
+	otlpMetricsExporter *otlp.Exporter
+
 	healthState      = &health.State{}
 	promStatReporter *queue.PrometheusStatsReporter // Prometheus stats reporter.
 
@@ -131,6 +227,22 @@ var (
 		appResponseTimeInMsecN,
 		"The response time in millisecond",
 		stats.UnitMilliseconds)
+	requestCPUSecondsM = stats.Float64(
+		requestCPUSecondsN,
+		"The CPU time spent on the requests served one at a time, in seconds",
+		"s")
+	queueWaitSecondsM = stats.Float64(
+		queueWaitSecondsN,
+		"The time the requests waited for capacity in the queue, in seconds",
+		"s")
+	requestSizeBytesM = stats.Int64(
+		requestSizeBytesN,
+		"The size of the bodies of the requests, in bytes",
+		stats.UnitBytes)
+	responseSizeBytesM = stats.Int64(
+		responseSizeBytesN,
+		"The size of the bodies of the responses, in bytes",
+		stats.UnitBytes)
 )
 
 func initEnv() {
@@ -144,7 +256,7 @@ func initEnv() {
 	servingRevision = util.GetRequiredEnvOrFatal("SERVING_REVISION", logger)
 	servingService = os.Getenv("SERVING_SERVICE") // KService is optional
 	userTargetPort = util.MustParseIntEnvOrFatal("USER_PORT", logger)
-	userTargetAddress = fmt.Sprintf("127.0.0.1:%d", userTargetPort)
+	userTargetAddress = net.JoinHostPort(loopbackAddress(servingPodIP), strconv.Itoa(userTargetPort))
 	userContainerName = util.GetRequiredEnvOrFatal("USER_CONTAINER_NAME", logger)
 
 	enableVarLogCollection, _ = strconv.ParseBool(os.Getenv("ENABLE_VAR_LOG_COLLECTION")) // Optional, default is false
@@ -156,6 +268,154 @@ func initEnv() {
 	if internalVolumePath == "" && enableVarLogCollection {
 		logger.Fatal("INTERNAL_VOLUME_PATH must be specified when ENABLE_VAR_LOG_COLLECTION is true")
 	}
+	statsSocketPath = os.Getenv("QUEUE_STATS_SOCKET_PATH")                          // Optional, disabled by default
+	statsToken = os.Getenv("STATS_TOKEN")                                           // Optional, stats are public if unset
+	concurrencyStateURL = os.Getenv("CONCURRENCY_STATE_ENDPOINT")                   // Optional, disabled by default
+	enableCheckpoint, _ = strconv.ParseBool(os.Getenv("ENABLE_CHECKPOINT_RESTORE")) // Optional, default is false
+	if enableCheckpoint && concurrencyStateURL == "" {
+		logger.Error("Disabling checkpoint/restore, CONCURRENCY_STATE_ENDPOINT is not specified")
+		enableCheckpoint = false
+	}
+	enableEarlyHints, _ = strconv.ParseBool(os.Getenv("ENABLE_EARLY_HINTS"))                  // Optional, default is false
+	enableDynamicCC, _ = strconv.ParseBool(os.Getenv("ENABLE_DYNAMIC_CONTAINER_CONCURRENCY")) // Optional, default is false
+	enableConfigReload, _ = strconv.ParseBool(os.Getenv("ENABLE_CONFIG_RELOAD"))              // Optional, default is false
+	releaseOnHeaders, _ = strconv.ParseBool(os.Getenv("RELEASE_CONCURRENCY_ON_HEADERS"))      // Optional, default is false
+	enableCPUAccounting, _ = strconv.ParseBool(os.Getenv("ENABLE_REQUEST_CPU_ACCOUNTING"))    // Optional, default is false
+	maintenanceMode, _ = strconv.ParseBool(os.Getenv("MAINTENANCE_MODE"))                     // Optional, default is false
+	enableGRPCWeb, _ = strconv.ParseBool(os.Getenv("ENABLE_GRPC_WEB"))                        // Optional, default is false
+	requestWeightHeader = os.Getenv("REQUEST_WEIGHT_HEADER")                                  // Optional, every request weighs 1 by default
+	// Optional, traces are sampled as config-tracing says by default.
+	samplingPolicy = tracing.SamplingPolicyFromAnnotations(map[string]string{
+		serving.TracingSampleRateAnnotationKey:    os.Getenv("TRACING_SAMPLE_RATE"),
+		serving.TracingSampleOnErrorAnnotationKey: os.Getenv("TRACING_SAMPLE_ON_ERROR"),
+	})
+	// Optional, every request costs 1 by default.
+	if c, err := serving.ParseRequestCosts(os.Getenv("REQUEST_COSTS")); err != nil {
+		logger.Fatalw("Invalid REQUEST_COSTS", zap.Error(err))
+	} else {
+		requestCosts = c
+	}
+	if v := os.Getenv("PATH_CONCURRENCY"); v != "" { // Optional, paths only share the container concurrency by default
+		c, err := serving.ParsePathConcurrency(v)
+		if err != nil {
+			logger.Fatalw("Invalid PATH_CONCURRENCY", zap.Error(err))
+		}
+		pathBreakers = queue.NewPathBreakers(c)
+	}
+	if v := os.Getenv("WEBSOCKET_IDLE_WINDOW"); v != "" { // Optional, WebSocket connections count until closed by default
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logger.Fatal("WEBSOCKET_IDLE_WINDOW must be a positive duration")
+		}
+		webSocketIdleWindow = d
+	}
+	if v := os.Getenv("RETRY_BUFFER_BYTES"); v != "" { // Optional, requests are not retried by default
+		retryBufferBytes = util.MustParseIntEnvOrFatal("RETRY_BUFFER_BYTES", logger)
+	}
+	if m, err := serving.ParseRetryMethods(os.Getenv("RETRY_METHODS")); err != nil { // Optional, only GET and HEAD are retried by default
+		logger.Fatalw("Invalid RETRY_METHODS", zap.Error(err))
+	} else {
+		retryMethods = m
+	}
+	if v := os.Getenv("RESPONSE_CACHE_BYTES"); v != "" { // Optional, responses are not cached by default
+		responseCacheBytes = util.MustParseIntEnvOrFatal("RESPONSE_CACHE_BYTES", logger)
+	}
+	if v := os.Getenv("RESPONSE_CACHE_TTL"); v != "" { // Optional
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logger.Fatal("RESPONSE_CACHE_TTL must be a positive duration")
+		}
+		responseCacheTTL = d
+	}
+	if v := os.Getenv("MAX_REQUEST_BYTES"); v != "" { // Optional, request bodies are unlimited by default
+		maxRequestBytes = util.MustParseIntEnvOrFatal("MAX_REQUEST_BYTES", logger)
+	}
+	if v := os.Getenv("MAX_RESPONSE_BYTES"); v != "" { // Optional, response bodies are unlimited by default
+		maxResponseBytes = util.MustParseIntEnvOrFatal("MAX_RESPONSE_BYTES", logger)
+	}
+	if v := os.Getenv("CLIENT_QUOTA"); v != "" { // Optional, clients are unlimited by default
+		q, err := serving.ParseClientQuota(v)
+		if err != nil {
+			logger.Fatalw("Invalid CLIENT_QUOTA", zap.Error(err))
+		}
+		clientQuota = &q
+	}
+	if v := os.Getenv("REQUEST_AUTHENTICATION"); v != "" { // Optional, requests are not authenticated by default
+		a, err := serving.ParseRequestAuthentication(v)
+		if err != nil {
+			logger.Fatalw("Invalid REQUEST_AUTHENTICATION", zap.Error(err))
+		}
+		requestAuthentication = &a
+		authProbePaths = strings.Fields(os.Getenv("REQUEST_AUTHENTICATION_PROBE_PATHS"))
+	}
+	if v := os.Getenv("CLIENT_CERT_TRUSTED_PROXIES"); v != "" { // Optional, forwarded client certificates are ignored by default
+		nets, err := network.ParseCIDRs(v)
+		if err != nil {
+			logger.Fatalw("Invalid CLIENT_CERT_TRUSTED_PROXIES", zap.Error(err))
+		}
+		clientCertTrustedProxies = nets
+	}
+	if v := os.Getenv("REQUESTS_PER_SECOND_LIMIT"); v != "" { // Optional, the rate is unlimited by default
+		requestsPerSecondLimit = util.MustParseIntEnvOrFatal("REQUESTS_PER_SECOND_LIMIT", logger)
+		requestsPerSecondBurst = requestsPerSecondLimit
+		if v := os.Getenv("REQUESTS_PER_SECOND_BURST"); v != "" {
+			requestsPerSecondBurst = util.MustParseIntEnvOrFatal("REQUESTS_PER_SECOND_BURST", logger)
+		}
+	}
+	// Optional, default is FIFO.
+	if d, err := queue.ParseQueueDiscipline(os.Getenv("QUEUE_DISCIPLINE")); err != nil {
+		logger.Fatalw("Invalid QUEUE_DISCIPLINE", zap.Error(err))
+	} else {
+		queueDiscipline = d
+	}
+	overflowURL = os.Getenv("OVERFLOW_URL") // Optional, requests are failed once the queue is full by default
+	if raw := os.Getenv("USER_READINESS_HTTP_PROBE"); raw != "" {
+		userHTTPProbe = &health.HTTPProbeConfig{}
+		if err := json.Unmarshal([]byte(raw), userHTTPProbe); err != nil {
+			logger.Fatalw("USER_READINESS_HTTP_PROBE must be a JSON HTTP probe", zap.Error(err))
+		}
+		userHTTPTimeout = defaultHTTPProbeTimeout
+		if ts := os.Getenv("USER_READINESS_HTTP_TIMEOUT_SECONDS"); ts != "" {
+			userHTTPTimeout = time.Duration(util.MustParseIntEnvOrFatal("USER_READINESS_HTTP_TIMEOUT_SECONDS", logger)) * time.Second
+		}
+	}
+
+	// The limits of our server are optional, Go's defaults apply without them.
+	if v := os.Getenv("MAX_HEADER_BYTES"); v != "" {
+		maxHeaderBytes = util.MustParseIntEnvOrFatal("MAX_HEADER_BYTES", logger)
+	}
+	if v := os.Getenv("MAX_CONNECTIONS"); v != "" {
+		maxConnections = util.MustParseIntEnvOrFatal("MAX_CONNECTIONS", logger)
+	}
+	if v := os.Getenv("READ_HEADER_TIMEOUT_SECONDS"); v != "" {
+		readHeaderTimeout = time.Duration(util.MustParseIntEnvOrFatal("READ_HEADER_TIMEOUT_SECONDS", logger)) * time.Second
+	}
+	// Optional, bounds the request timeout the pod annotations may set.
+	if v := os.Getenv("MAX_REVISION_TIMEOUT_SECONDS"); v != "" {
+		maxRevisionTimeout = time.Duration(util.MustParseIntEnvOrFatal("MAX_REVISION_TIMEOUT_SECONDS", logger)) * time.Second
+	}
+
+	// Optional, only revisions serving a protocol other than HTTP have one.
+	if v := os.Getenv("USER_L4_PORT"); v != "" {
+		userL4Port = util.MustParseIntEnvOrFatal("USER_L4_PORT", logger)
+		userL4Protocol = util.GetRequiredEnvOrFatal("USER_L4_PROTOCOL", logger)
+	}
+
+	// Optional, there is no ballast by default. The Go runtime reads GOGC
+	// and GOMEMLIMIT on its own.
+	if v := os.Getenv("GC_BALLAST_BYTES"); v != "" {
+		gcBallastBytes = util.MustParseIntEnvOrFatal("GC_BALLAST_BYTES", logger)
+	}
+
+	// Optional, requests are only traced when tracing is enabled.
+	if cfg, err := tracingConfigFromEnv(os.Getenv); err != nil {
+		logger.Fatalw("Invalid TRACING_CONFIG", zap.Error(err))
+	} else {
+		tracingConfig = cfg
+	}
+	// Only set when the request metrics are exported with OTLP.
+	metricsOTLPEndpoint = os.Getenv("SERVING_REQUEST_METRICS_OTLP_ENDPOINT")
+	metricsOTLPProtocol = os.Getenv("SERVING_REQUEST_METRICS_OTLP_PROTOCOL")
 
 	// TODO(mattmoor): Move this key to be in terms of the KPA.
 	servingRevisionKey = autoscaler.NewMetricKey(servingNamespace, servingRevision)
@@ -182,13 +442,49 @@ func knativeProxyHeader(r *http.Request) string {
 	return r.Header.Get(network.ProxyHeaderName)
 }
 
+// requestWeight returns the number of concurrency slots the request takes.
+// It is the cost of the request by the requestCosts, unless the client asks
+// for more in the requestWeightHeader. Clients can't weigh their requests
+// below their cost, nor above the capacity, if there is one, so they can't
+// slip past the concurrency limit nor hold up the requests queued behind.
+func requestWeight(r *http.Request, capacity int) int {
+	weight := requestCosts.Cost(r.Method, r.URL.Path, r.ContentLength)
+	if requestWeightHeader != "" {
+		if w, err := strconv.Atoi(r.Header.Get(requestWeightHeader)); err == nil && w > weight {
+			weight = w
+		}
+	}
+	if capacity > 0 && weight > capacity {
+		weight = capacity
+	}
+	return weight
+}
+
+// waitForUserContainer waits for the user-container to accept connections
+// again, for at most the probe timeout.
+func waitForUserContainer(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	return wait.PollImmediateUntil(50*time.Millisecond, func() (bool, error) {
+		return health.TCPProbe(userTargetAddress, 100*time.Millisecond) == nil, nil
+	}, ctx.Done())
+}
+
 func probeUserContainer() bool {
 	var err error
-	wait.PollImmediate(50*time.Millisecond, probeTimeout, func() (bool, error) {
-		logger.Debug("TCP probing the user-container.")
-		err = health.TCPProbe(userTargetAddress, 100*time.Millisecond)
-		return err == nil, nil
-	})
+	if userHTTPProbe != nil {
+		wait.PollImmediate(50*time.Millisecond, probeTimeout, func() (bool, error) {
+			logger.Debug("HTTP probing the user-container.")
+			err = health.HTTPProbe(userTargetAddress, *userHTTPProbe, userHTTPTimeout)
+			return err == nil, nil
+		})
+	} else {
+		wait.PollImmediate(50*time.Millisecond, probeTimeout, func() (bool, error) {
+			logger.Debug("TCP probing the user-container.")
+			err = health.TCPProbe(userTargetAddress, 100*time.Millisecond)
+			return err == nil, nil
+		})
+	}
 
 	if err == nil {
 		logger.Info("User-container successfully probed.")
@@ -199,6 +495,18 @@ func probeUserContainer() bool {
 	return err == nil
 }
 
+// serveKnativeProbe answers a probe of the queue-proxy itself, without
+// forwarding it to the user container.
+func serveKnativeProbe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(network.ProtocolVersionHeaderName, network.NegotiateProtocolVersion(r.Header).String())
+	if probeUserContainer() {
+		// Respond with the name of the component handling the request.
+		w.Write([]byte(queue.Name))
+	} else {
+		http.Error(w, "container not ready", http.StatusServiceUnavailable)
+	}
+}
+
 // Make handler a closure for testing.
 func handler(reqChan chan queue.ReqEvent, breaker *queue.Breaker, handler http.Handler) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -209,12 +517,7 @@ func handler(reqChan chan queue.ReqEvent, breaker *queue.Breaker, handler http.H
 				http.Error(w, fmt.Sprintf(badProbeTemplate, ph), http.StatusBadRequest)
 				return
 			}
-			if probeUserContainer() {
-				// Respond with the name of the component handling the request.
-				w.Write([]byte(queue.Name))
-			} else {
-				http.Error(w, "container not ready", http.StatusServiceUnavailable)
-			}
+			serveKnativeProbe(w, r)
 			return
 		case network.IsKubeletProbe(r):
 			// Do not count health checks for concurrency metrics
@@ -224,39 +527,183 @@ func handler(reqChan chan queue.ReqEvent, breaker *queue.Breaker, handler http.H
 
 		// Metrics for autoscaling.
 		in, out := queue.ReqIn, queue.ReqOut
+		idle, active := queue.ReqIdle, queue.ReqActive
 		if activator.Name == knativeProxyHeader(r) {
 			in, out = queue.ProxiedIn, queue.ProxiedOut
+			idle, active = queue.ProxiedIdle, queue.ProxiedActive
 		}
-		reqChan <- queue.ReqEvent{Time: time.Now(), EventType: in}
+		var bytes int64
+		if r.ContentLength > 0 {
+			bytes = r.ContentLength
+		}
+		start := time.Now()
+		reqChan <- queue.ReqEvent{Time: start, EventType: in, Bytes: bytes}
 		defer func() {
-			reqChan <- queue.ReqEvent{Time: time.Now(), EventType: out}
+			now := time.Now()
+			reqChan <- queue.ReqEvent{Time: now, EventType: out, Bytes: bytes, Duration: now.Sub(start)}
 		}()
+		if webSocketIdleWindow > 0 && pkghttp.UpgradeProtocol(r) == "websocket" {
+			// Idle WebSocket connections don't count towards the concurrency.
+			tracker := queue.NewWebSocketIdleTracker(webSocketIdleWindow,
+				func() { reqChan <- queue.ReqEvent{Time: time.Now(), EventType: idle} },
+				func() { reqChan <- queue.ReqEvent{Time: time.Now(), EventType: active} })
+			defer tracker.Stop()
+			w = tracker.Writer(w)
+		}
 		network.RewriteHostOut(r)
 
+		if healthState.IsShuttingDown() {
+			// Tell clients to go elsewhere for their next request rather
+			// than reusing a connection to a draining pod.
+			w.Header().Set("Connection", "close")
+		}
+
 		// Enforce queuing and concurrency limits.
-		if breaker != nil {
-			if !breaker.Maybe(0 /* Infinite timeout */, func() {
+		serve := func(w http.ResponseWriter, r *http.Request) {
+			if breaker != nil {
+				// Requests timing out while still queued were never seen by
+				// the user container.
+				queue.SetTimeoutStatus(r.Context(), http.StatusGatewayTimeout)
+				ctx, wait := queue.WithQueueWait(r.Context())
+				ctx = queue.WithOverflowRequest(ctx, w, r)
+				// Requests whose client went away are dropped from the queue.
+				if err := breaker.MaybeWithWeight(ctx, requestWeight(r, breaker.Capacity()), func(release func()) {
+					queue.SetTimeoutStatus(r.Context(), http.StatusServiceUnavailable)
+					w.Header().Set(network.QueueWaitTimeHeaderName, wait.String())
+					if reportQueueWait != nil {
+						reportQueueWait(*wait)
+					}
+					rw := w
+					if releaseOnHeaders {
+						// Don't hold the concurrency slot while the body streams.
+						rw = queue.ReleaseOnHeaders(w, release)
+					}
+					handler.ServeHTTP(rw, r)
+				}); err == queue.ErrQueueFull {
+					w.Header().Set(network.OverloadedByHeaderName, queue.Name)
+					setRetryAfter(w, breaker.RetryAfter())
+					http.Error(w, "overload", queue.ErrorStatusCode(err))
+				} else if err != nil {
+					http.Error(w, err.Error(), queue.ErrorStatusCode(err))
+				}
+			} else {
 				handler.ServeHTTP(w, r)
-			}) {
-				http.Error(w, "overload", http.StatusServiceUnavailable)
 			}
+		}
+		if pathBreakers != nil {
+			// Requests wait for a slot of their path before taking one of
+			// the container concurrency, while counting as queued.
+			queue.PathConcurrencyHandler(http.HandlerFunc(serve), pathBreakers).ServeHTTP(w, r)
 		} else {
-			handler.ServeHTTP(w, r)
+			serve(w, r)
 		}
 	}
 }
 
-// Sets up /health and /wait-for-drain endpoints.
-func createAdminHandlers() *http.ServeMux {
+// setRetryAfter tells the client to try again after d, in whole seconds,
+// unless there is no estimate.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	secs := int64((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
+// overflowProxy returns a proxy of the requests the breaker can't queue
+// to target. The requests are addressed to the host of target, for it to
+// be routed to, e.g., a fallback revision.
+func overflowProxy(target *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = network.AutoTransport
+	proxy.FlushInterval = -1
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+	}
+	activatorutil.SetupHeaderPruning(proxy)
+	pkghttp.SetupRequestTimeoutForwarding(proxy)
+	return proxy
+}
+
+// Sets up /health and /wait-for-drain endpoints, and /debug/breaker if
+// there is a breaker.
+func createAdminHandlers(breaker *queue.Breaker) http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc(requestQueueHealthPath, healthState.HealthHandler(probeUserContainer))
+	mux.HandleFunc(requestQueueHealthPath, healthState.HealthHandler(checkpointOnReady(probeUserContainer, checkpointer())))
 	mux.HandleFunc(queue.RequestQueueDrainPath, healthState.DrainHandler())
+	if breaker != nil {
+		mux.HandleFunc(queue.RequestQueueBreakerPath, queue.BreakerDebugHandler(breaker))
+	}
 
-	return mux
+	return network.ProtocolVersionHandler(mux)
+}
+
+// checkpointOnReady asks checkpointer to restore the pod from the snapshot
+// of the revision, and wraps the prober to have the pod checkpointed the
+// first time the user container is found ready, unless it was restored.
+// The pod is not ready until the restore is done, since the user container
+// is replaced by its snapshot in the meantime.
+func checkpointOnReady(prober func() bool, checkpointer queue.PodCheckpointer) func() bool {
+	if checkpointer == nil {
+		return prober
+	}
+	var restored bool
+	restoreDone := make(chan struct{})
+	go func() {
+		defer close(restoreDone)
+		ok, err := checkpointer.Restore(context.Background())
+		if err != nil {
+			logger.Errorw("Failed to restore the pod", zap.Error(err))
+		} else if ok {
+			logger.Info("Restored the pod from the snapshot of the revision")
+		}
+		restored = ok
+	}()
+
+	var once sync.Once
+	return func() bool {
+		select {
+		case <-restoreDone:
+		default:
+			return false
+		}
+		ready := prober()
+		if ready && !restored {
+			once.Do(func() {
+				// Don't hold up the readiness probe on the snapshot.
+				go func() {
+					if err := checkpointer.Checkpoint(context.Background()); err != nil {
+						logger.Errorw("Failed to checkpoint the pod", zap.Error(err))
+					}
+				}()
+			})
+		}
+		return ready
+	}
+}
+
+// checkpointer returns the checkpointer of the pod, nil unless
+// checkpoint/restore is enabled.
+func checkpointer() queue.PodCheckpointer {
+	if !enableCheckpoint {
+		return nil
+	}
+	return &queue.HTTPPauser{Endpoint: concurrencyStateURL, PodName: servingPodName}
+}
+
+// loopbackAddress returns the loopback address of the pod's IP family, so
+// the containers of the pod are also reachable in single-stack IPv6 clusters.
+func loopbackAddress(podIP string) string {
+	return network.Loopback(network.FamilyOf(net.ParseIP(podIP)))
 }
 
 func probeQueueHealthPath(port int, timeout time.Duration) error {
-	url := fmt.Sprintf(healthURLTemplate, port)
+	// The probe runs as a separate process before initEnv, so read the
+	// pod IP straight from the environment.
+	host := net.JoinHostPort(loopbackAddress(os.Getenv("SERVING_POD_IP")), strconv.Itoa(port))
+	url := "http://" + host + requestQueueHealthPath
 
 	httpClient := &http.Client{
 		Transport: &http.Transport{
@@ -305,7 +752,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger, _ = logging.NewLogger(os.Getenv("SERVING_LOGGING_CONFIG"), os.Getenv("SERVING_LOGGING_LEVEL"))
+	var atomicLevel zap.AtomicLevel
+	logger, atomicLevel = logging.NewLogger(os.Getenv("SERVING_LOGGING_CONFIG"), os.Getenv("SERVING_LOGGING_LEVEL"))
 	logger = logger.Named("queueproxy")
 	defer flush(logger)
 
@@ -313,6 +761,7 @@ func main() {
 	logger = logger.With(
 		zap.String(logkey.Key, servingRevisionKey),
 		zap.String(logkey.Pod, servingPodName))
+	gctuning.SetBallast(int64(gcBallastBytes))
 
 	target, err := url.Parse("http://" + userTargetAddress)
 	if err != nil {
@@ -321,25 +770,118 @@ func main() {
 
 	httpProxy = httputil.NewSingleHostReverseProxy(target)
 	httpProxy.Transport = network.AutoTransport
+	if retryBufferBytes > 0 {
+		// Replay the idempotent requests the user container didn't answer,
+		// e.g. because it crashed, once it is back.
+		httpProxy.Transport = queue.NewReplayTransport(network.AutoTransport, int64(retryBufferBytes), retryMethods, waitForUserContainer)
+	}
+	if tracingConfig != nil {
+		if o, err := setupTracing(tracingConfig); err != nil {
+			logger.Errorw("Error setting up tracing. Requests will not be traced.", zap.Error(err))
+		} else {
+			oct = o
+			// Trace the requests to the user container, propagating the
+			// trace context to it.
+			httpProxy.Transport = &ochttp.Transport{Base: httpProxy.Transport}
+		}
+	}
 	httpProxy.FlushInterval = -1
 
 	activatorutil.SetupHeaderPruning(httpProxy)
+	pkghttp.SetupRequestTimeoutForwarding(httpProxy)
+
+	// The request timeout may be changed at runtime when config reloading is enabled.
+	defaultTimeout := time.Duration(revisionTimeoutSeconds) * time.Second
+	revisionTimeout := int64(defaultTimeout)
 
 	// If containerConcurrency == 0 then concurrency is unlimited.
 	if containerConcurrency > 0 {
 		// We set the queue depth to be equal to the container concurrency * 10 to
 		// allow the autoscaler to get a strong enough signal.
-		queueDepth := containerConcurrency * 10
-		params := queue.BreakerParams{QueueDepth: queueDepth, MaxConcurrency: containerConcurrency, InitialCapacity: containerConcurrency}
+		queueDepth := containerConcurrency * queue.QueueDepthPerConcurrency
+		params := queue.BreakerParams{QueueDepth: queueDepth, MaxConcurrency: containerConcurrency, InitialCapacity: containerConcurrency, Discipline: queueDiscipline}
+		if enableDynamicCC || enableConfigReload {
+			// Leave room for the concurrency to be raised up to the maximum
+			// a revision may be configured with. The queue depth follows
+			// the concurrency as it changes.
+			params.MaxConcurrency = int(v1beta1.RevisionContainerConcurrencyMax)
+		}
+		if overflowURL != "" {
+			overflowTarget, err := url.Parse(overflowURL)
+			if err != nil {
+				logger.Fatalw("Failed to parse OVERFLOW_URL", zap.Error(err))
+			}
+			params.Overflow = queue.OverflowHandler(overflowProxy(overflowTarget))
+		}
+		// Requests still queued by the request timeout fail anyway, so they
+		// are preempted once the concurrency drops too low to serve them.
+		params.Timeout = func() time.Duration {
+			return time.Duration(atomic.LoadInt64(&revisionTimeout))
+		}
+		if concurrencyStateURL != "" {
+			// Pause the user container while the breaker lets nothing through.
+			params.Hooks.OnStateChange = queue.ConcurrencyStateHook(logger,
+				&queue.HTTPPauser{Endpoint: concurrencyStateURL, PodName: servingPodName})
+		}
 		breaker = queue.NewBreaker(params)
 		logger.Infof("Queue container is starting with %#v", params)
 	}
 
-	go func() {
-		mux := http.NewServeMux()
-		mux.Handle("/metrics", promStatReporter.Handler())
-		http.ListenAndServe(fmt.Sprintf(":%d", networking.AutoscalingQueueMetricsPort), mux)
-	}()
+	if enableDynamicCC || enableConfigReload {
+		var handlers []func(*queue.DynamicConfig)
+		if breaker != nil {
+			handlers = append(handlers, queue.UpdateBreakerConcurrency(logger, breaker, containerConcurrency))
+		}
+		if enableConfigReload {
+			defaultLevel := atomicLevel.Level()
+			handlers = append(handlers, func(dc *queue.DynamicConfig) {
+				level := defaultLevel
+				if dc.LoggingLevel != nil {
+					level = *dc.LoggingLevel
+				}
+				if level != atomicLevel.Level() {
+					logger.Infof("Updating logging level from %v to %v", atomicLevel.Level(), level)
+					atomicLevel.SetLevel(level)
+				}
+
+				timeout := defaultTimeout
+				if dc.RevisionTimeout > 0 {
+					timeout = dc.RevisionTimeout
+				}
+				if maxRevisionTimeout > 0 && timeout > maxRevisionTimeout {
+					logger.Warnf("Request timeout %v exceeds the maximum of %v", timeout, maxRevisionTimeout)
+					timeout = maxRevisionTimeout
+				}
+				if old := time.Duration(atomic.SwapInt64(&revisionTimeout, int64(timeout))); old != timeout {
+					logger.Infof("Updating request timeout from %v to %v", old, timeout)
+				}
+			})
+		}
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go queue.WatchDynamicConfig(logger, path.Join(queue.PodInfoVolumePath, queue.PodInfoAnnotationsFile),
+			dynamicConfigPollPeriod, func(dc *queue.DynamicConfig) {
+				for _, h := range handlers {
+					h(dc)
+				}
+			}, stopCh)
+	}
+
+	statsMux := http.NewServeMux()
+	statsMux.Handle("/metrics", network.ProtocolVersionHandler(promStatReporter.Handler()))
+	// Only the autoscaler may read the stats over the network. The socket
+	// is only reachable from within the pod.
+	go http.ListenAndServe(fmt.Sprintf(":%d", networking.AutoscalingQueueMetricsPort), queue.RequireBearerToken(statsToken, statsMux))
+	if statsSocketPath != "" {
+		// Expose the same stats on a unix socket, so scrapers within the
+		// pod can avoid the per-scrape overhead of the pod network.
+		go func() {
+			if err := queue.ServeStatsSocket(statsSocketPath, statsMux); err != nil {
+				logger.Errorw("Failed to serve stats on socket "+statsSocketPath, zap.Error(err))
+			}
+		}()
+	}
 
 	statChan := make(chan *autoscaler.Stat, statReportingQueueLength)
 	defer close(statChan)
@@ -355,7 +897,7 @@ func main() {
 
 	adminServer := &http.Server{
 		Addr:    fmt.Sprintf(":%d", networking.QueueAdminPort),
-		Handler: createAdminHandlers(),
+		Handler: createAdminHandlers(breaker),
 	}
 
 	metricsSupported := false
@@ -373,19 +915,109 @@ func main() {
 	// Create queue handler chain
 	// Note: innermost handlers are specified first, ie. the last handler in the chain will be executed first
 	var composedHandler http.Handler = httpProxy
+	if enableGRPCWeb {
+		// Translate right before the proxy, which sees the gRPC trailers.
+		composedHandler = queue.GRPCWebHandler(composedHandler)
+	}
 	if metricsSupported {
-		composedHandler = pushRequestMetricHandler(httpProxy, appRequestCountM, appResponseTimeInMsecM)
+		if breaker != nil {
+			reportQueueWait = queueWaitReporter()
+		}
+		composedHandler = pushRequestMetricHandler(composedHandler, appRequestCountM, appResponseTimeInMsecM)
+		if enableCPUAccounting {
+			composedHandler = pushCPUAccountingHandler(composedHandler)
+		}
+	}
+	if !enableEarlyHints {
+		composedHandler = pkghttp.NewEarlyHintsFilter(composedHandler)
+	}
+	if concurrencyStateURL != "" && breaker == nil {
+		// Pause the user container while it has nothing to do. This must be
+		// inside of the breaker, to only count requests actually forwarded.
+		// With a breaker, its OnStateChange hook does so instead.
+		composedHandler = queue.ConcurrencyStateHandler(logger, composedHandler,
+			&queue.HTTPPauser{Endpoint: concurrencyStateURL, PodName: servingPodName})
 	}
 	composedHandler = http.HandlerFunc(handler(reqChan, breaker, composedHandler))
+	if responseCacheBytes > 0 {
+		// Answer the repeated requests before they take a slot in the
+		// breaker, or the tokens of the rate limit.
+		composedHandler = queue.ResponseCacheHandler(composedHandler,
+			queue.NewResponseCache(int64(responseCacheBytes), responseCacheTTL))
+	}
+	if maxRequestBytes > 0 || maxResponseBytes > 0 || metricsSupported {
+		// Fail the requests announcing too large a body before they take
+		// a slot in the breaker.
+		composedHandler = pushBodySizeHandler(composedHandler, metricsSupported)
+	}
+	if requestsPerSecondLimit > 0 {
+		// Shed the requests over the rate before they take a slot in the
+		// breaker, but after the client quotas, for the clients over theirs
+		// not to take the tokens of the others.
+		composedHandler = queue.RateLimitHandler(composedHandler,
+			queue.NewRateLimiter(requestsPerSecondLimit, requestsPerSecondBurst))
+	}
+	if clientQuota != nil {
+		// Shed the requests of the clients over quota before they take a
+		// slot in the breaker.
+		limiter := queue.NewClientQuotaLimiter(*clientQuota)
+		go syncClientQuota(limiter)
+		composedHandler = queue.ClientQuotaHandler(composedHandler, limiter)
+	}
+	if requestAuthentication != nil {
+		// Reject the requests without valid credentials before they count
+		// against any quota or limit.
+		composedHandler = queue.AuthenticationHandler(composedHandler,
+			makeAuthenticationPolicy(*requestAuthentication, authProbePaths))
+	}
+	if upgradePolicy, err := pkghttp.ParseUpgradePolicy(os.Getenv("ALLOWED_UPGRADE_PROTOCOLS"), os.Getenv("MAX_UPGRADED_CONNECTIONS")); err != nil {
+		logger.Errorw("Invalid upgrade policy, protocol upgrades will not be restricted", zap.Error(err))
+	} else if upgradePolicy.AllowedProtocols != nil || upgradePolicy.MaxConnections > 0 {
+		composedHandler = pkghttp.NewUpgradeHandler(composedHandler, upgradePolicy)
+	}
 	composedHandler = queue.ForwardedShimHandler(composedHandler)
-	composedHandler = queue.TimeToFirstByteTimeoutHandler(composedHandler,
-		time.Duration(revisionTimeoutSeconds)*time.Second, "request timeout")
+	composedHandler = queue.ClientCertHandler(composedHandler, clientCertTrustedProxies)
+	composedHandler = queue.CacheDefaultsHandler(composedHandler)
+	if headerPolicy, err := pkghttp.ParseHeaderPolicy(os.Getenv("REQUEST_HEADER_POLICY")); err != nil {
+		logger.Errorw("Invalid request header policy, headers will not be sanitized", zap.Error(err))
+	} else if !headerPolicy.IsZero() {
+		composedHandler = pkghttp.NewHeaderPolicyHandler(composedHandler, headerPolicy)
+	}
+	if maintenanceMode {
+		// Answer for the user container while it is under maintenance.
+		logger.Info("Queue-proxy is serving the maintenance page")
+		composedHandler = queue.MaintenanceHandler(composedHandler, os.Getenv("MAINTENANCE_PAGE"), os.Getenv("MAINTENANCE_PAGE_CONTENT_TYPE"))
+	}
+	composedHandler = queue.DynamicTimeToFirstByteTimeoutHandler(composedHandler, func() time.Duration {
+		return time.Duration(atomic.LoadInt64(&revisionTimeout))
+	}, "request timeout")
+	// Don't queue the requests for longer than their clients wait, which
+	// is at most as long as the revision lets them take.
+	composedHandler = pkghttp.NewRequestTimeoutHandler(composedHandler, func() time.Duration {
+		return time.Duration(atomic.LoadInt64(&revisionTimeout))
+	})
 	composedHandler = pushRequestLogHandler(composedHandler)
 	if metricsSupported {
 		composedHandler = pushRequestMetricHandler(composedHandler, requestCountM, responseTimeInMsecM)
+		if r, err := queuestats.NewGCReporter(servingNamespace, servingService, servingConfig, servingRevision); err != nil {
+			logger.Errorw("Error setting up garbage collector reporter. Garbage collector metrics will be unavailable.", zap.Error(err))
+		} else {
+			gcTicker := time.NewTicker(gcReportingPeriod)
+			defer gcTicker.Stop()
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go r.Run(gcTicker.C, stopCh)
+		}
+	}
+	if oct != nil {
+		composedHandler = tracing.HTTPSpanMiddlewareWithSampling(composedHandler, func(*http.Request) *tracing.SamplingPolicy {
+			return samplingPolicy
+		})
 	}
 	logger.Infof("Queue-proxy will listen on port %d", queueServingPort)
 	server := network.NewServer(fmt.Sprintf(":%d", queueServingPort), composedHandler)
+	server.MaxHeaderBytes = maxHeaderBytes
+	server.ReadHeaderTimeout = readHeaderTimeout
 
 	errChan := make(chan error, 2)
 	defer close(errChan)
@@ -398,8 +1030,36 @@ func main() {
 		}
 	}
 
-	go catchServerError(server.ListenAndServe)
+	go catchServerError(func() error {
+		l, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			return err
+		}
+		if maxConnections > 0 {
+			l = network.LimitListener(l, maxConnections)
+		}
+		return server.Serve(l)
+	})
 	go catchServerError(adminServer.ListenAndServe)
+	if userL4Port > 0 {
+		l4Addr := fmt.Sprintf(":%d", networking.BackendL4Port)
+		l4Target := net.JoinHostPort(loopbackAddress(servingPodIP), strconv.Itoa(userL4Port))
+		logger.Infof("Queue-proxy will proxy %s port %d on port %d", userL4Protocol, userL4Port, networking.BackendL4Port)
+		go catchServerError(func() error {
+			if userL4Protocol == "UDP" {
+				conn, err := net.ListenPacket("udp", l4Addr)
+				if err != nil {
+					return err
+				}
+				return queue.ProxyUDP(conn, l4Target, udpSessionIdleTimeout, reqChan)
+			}
+			l, err := net.Listen("tcp", l4Addr)
+			if err != nil {
+				return err
+			}
+			return queue.ProxyTCP(l, l4Target, reqChan)
+		})
+	}
 
 	// Logic that isn't required to be executed before the critical path
 	// and should be started last to not impact start up latency
@@ -423,6 +1083,18 @@ func main() {
 			// Give Istio time to sync our "not ready" state.
 			time.Sleep(quitSleepDuration)
 
+			// Shed the queued requests and let the in-flight ones finish
+			// before the proxy server goes away.
+			if breaker != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(atomic.LoadInt64(&revisionTimeout)))
+				shed, err := breaker.Drain(ctx)
+				cancel()
+				if err != nil {
+					logger.Errorw("Failed to drain the in-flight requests", zap.Error(err))
+				}
+				logger.Infof("Drained the breaker, shedding %d queued requests", shed)
+			}
+
 			// Calling server.Shutdown() allows pending requests to
 			// complete, while no new work is accepted.
 			if err := server.Shutdown(context.Background()); err != nil {
@@ -437,6 +1109,54 @@ func main() {
 	}
 }
 
+// syncClientQuota shares the usage of the client quota with the other pods
+// of the revision through the autoscaler, for as long as the process runs.
+// The quota is enforced per pod while the autoscaler can't be reached.
+func syncClientQuota(limiter *queue.ClientQuotaLimiter) {
+	url := fmt.Sprintf("http://autoscaler.%s.svc.%s:%d%s", system.Namespace(), network.GetClusterDomainName(),
+		autoscalerPort, autoscaler.ClientQuotaPath)
+	key := servingNamespace + "/" + servingRevision
+	client := &http.Client{Timeout: clientQuotaSyncPeriod}
+	ticker := time.NewTicker(clientQuotaSyncPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := queue.SyncClientQuota(context.Background(), client, url, key, servingPodName, limiter); err != nil {
+			logger.Debugw("Failed to sync the client quota", zap.Error(err))
+		}
+	}
+}
+
+// makeAuthenticationPolicy makes the policy authenticating the requests to
+// the revision, verifying bearer tokens with the JWKS fetched from its URL or
+// mounted from its secret, and client certificates by their SANs.
+func makeAuthenticationPolicy(a serving.RequestAuthentication, probePaths []string) queue.AuthenticationPolicy {
+	var authenticators []queue.Authenticator
+	switch {
+	case a.JWKSURL != nil:
+		client := &http.Client{Timeout: jwksFetchTimeout}
+		authenticators = append(authenticators, queue.NewJWTAuthenticator(
+			queue.JWKSFromURL(client, a.JWKSURL.String()), a.Issuer, a.Audiences))
+	case a.JWKSSecret != "":
+		authenticators = append(authenticators, queue.NewJWTAuthenticator(
+			queue.JWKSFromFile(path.Join(queue.RequestAuthenticationVolumePath, serving.JWKSSecretKey)),
+			a.Issuer, a.Audiences))
+	}
+	if len(a.ClientSANs) > 0 {
+		authenticators = append(authenticators, &queue.ClientCertAuthenticator{
+			SANs:           a.ClientSANs,
+			TrustedProxies: clientCertTrustedProxies,
+		})
+	}
+	return queue.AuthenticationPolicy{
+		Authenticators: authenticators,
+		Exempt: func(r *http.Request) bool {
+			return a.Exempts(r.URL.Path)
+		},
+		ProbePaths: probePaths,
+		Probe:      http.HandlerFunc(serveKnativeProbe),
+	}
+}
+
 // createVarLogLink creates a symlink allowing the fluentd daemon set to capture the
 // logs from the user container /var/log. See fluentd config for more details.
 func createVarLogLink(servingNamespace, servingPodName, userContainerName, varLogVolumeName, internalVolumePath string) {
@@ -487,7 +1207,55 @@ func pushRequestMetricHandler(currentHandler http.Handler, countMetric *stats.In
 	return handler
 }
 
+func queueWaitReporter() func(time.Duration) {
+	r, err := queuestats.NewQueueWaitReporter(servingNamespace, servingService, servingConfig, servingRevision, queueWaitSecondsM)
+	if err != nil {
+		logger.Errorw("Error setting up queue wait reporter. Queue wait times will be unavailable.", zap.Error(err))
+		return nil
+	}
+	return r.ReportQueueWait
+}
+
+func pushBodySizeHandler(currentHandler http.Handler, reportSizes bool) http.Handler {
+	var report func(request, response int64)
+	if reportSizes {
+		if r, err := queuestats.NewSizeReporter(servingNamespace, servingService, servingConfig, servingRevision,
+			requestSizeBytesM, responseSizeBytesM); err != nil {
+			logger.Errorw("Error setting up body size reporter. Body sizes will be unavailable.", zap.Error(err))
+		} else {
+			report = r.ReportSizes
+		}
+	}
+	return queue.BodySizeHandler(currentHandler, int64(maxRequestBytes), int64(maxResponseBytes), report)
+}
+
+func pushCPUAccountingHandler(currentHandler http.Handler) http.Handler {
+	path, err := queue.CgroupCPUUsagePath(cgroupRoot)
+	if err != nil {
+		logger.Errorw("Error finding the CPU usage. Request CPU time will be unavailable.", zap.Error(err))
+		return currentHandler
+	}
+
+	r, err := queuestats.NewCPUReporter(servingNamespace, servingService, servingConfig, servingRevision, requestCPUSecondsM)
+	if err != nil {
+		logger.Errorw("Error setting up request CPU time reporter. Request CPU time will be unavailable.", zap.Error(err))
+		return currentHandler
+	}
+	return queue.CPUAccountingHandler(currentHandler, queue.CgroupCPUUsage(path), r.ReportCPUTime)
+}
+
 func setupMetricsExporter(backend string) error {
+	// OTLP isn't supported by knative.dev/pkg/metrics, its exporter is
+	// registered on its own.
+	if backend == "otlp" {
+		e, err := setupOTLPMetricsExporter(metricsOTLPEndpoint, metricsOTLPProtocol)
+		if err != nil {
+			return err
+		}
+		otlpMetricsExporter = e
+		return nil
+	}
+
 	// Set up OpenCensus exporter.
 	// NOTE: We use revision as the component instead of queue because queue is
 	// implementation specific. The current metrics are request relative. Using
@@ -510,4 +1278,13 @@ func flush(logger *zap.SugaredLogger) {
 	os.Stdout.Sync()
 	os.Stderr.Sync()
 	metrics.FlushExporter()
+	if otlpMetricsExporter != nil {
+		if err := otlpMetricsExporter.Stop(); err != nil {
+			logger.Errorw("Failed to flush the OTLP metrics exporter", zap.Error(err))
+		}
+	}
+	if oct != nil {
+		// Finishing the tracer flushes the spans still buffered.
+		oct.Finish()
+	}
 }
@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -32,8 +33,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/knative/serving/pkg/activator"
+	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/queue"
+	"github.com/knative/serving/pkg/queue/health"
+	"k8s.io/apimachinery/pkg/util/wait"
 	logtesting "knative.dev/pkg/logging/testing"
 )
 
@@ -91,6 +95,293 @@ func TestHandlerReqEvent(t *testing.T) {
 	}
 }
 
+func TestHandlerQueueFull(t *testing.T) {
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request was passed to the user container")
+	})
+
+	// No capacity and room for a single queued request.
+	breaker := queue.NewBreaker(queue.BreakerParams{QueueDepth: 1, MaxConcurrency: 0, InitialCapacity: 0})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		breaker.MaybeContext(ctx, func() {})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Stats().Queued == 1, nil
+	}); err != nil {
+		t.Fatal("Timed out waiting for the queue to fill")
+	}
+
+	reqChan := make(chan queue.ReqEvent, 10)
+	h := handler(reqChan, breaker, proxy)
+
+	writer := httptest.NewRecorder()
+	h(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if got, want := writer.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	if got, want := writer.Header().Get(network.OverloadedByHeaderName), queue.Name; got != want {
+		t.Errorf("%s = %q, want: %q", network.OverloadedByHeaderName, got, want)
+	}
+	// No call was served, so there is nothing to estimate the wait from.
+	if got := writer.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After = %q, want none", got)
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{0, ""},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		setRetryAfter(w, test.wait)
+		if got := w.Header().Get("Retry-After"); got != test.want {
+			t.Errorf("Retry-After for %v = %q, want: %q", test.wait, got, test.want)
+		}
+	}
+}
+
+func TestHandlerQueueFullOverflow(t *testing.T) {
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request was passed to the user container")
+	})
+	var gotHost string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		w.Write([]byte("fallback"))
+	}))
+	defer fallback.Close()
+	fallbackURL, _ := url.Parse(fallback.URL)
+
+	// No capacity and room for a single queued request.
+	breaker := queue.NewBreaker(queue.BreakerParams{QueueDepth: 1, MaxConcurrency: 0, InitialCapacity: 0,
+		Overflow: queue.OverflowHandler(overflowProxy(fallbackURL))})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		breaker.MaybeContext(ctx, func() {})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Stats().Queued == 1, nil
+	}); err != nil {
+		t.Fatal("Timed out waiting for the queue to fill")
+	}
+
+	reqChan := make(chan queue.ReqEvent, 10)
+	h := handler(reqChan, breaker, proxy)
+
+	writer := httptest.NewRecorder()
+	h(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if got, want := writer.Code, http.StatusOK; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	if got, want := writer.Body.String(), "fallback"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	if got, want := gotHost, fallbackURL.Host; got != want {
+		t.Errorf("Host = %q, want: %q", got, want)
+	}
+}
+
+func TestHandlerGRPC(t *testing.T) {
+	// The user container echoes the requests, with a gRPC status in the
+	// trailers of gRPC ones.
+	type seen struct {
+		contentType string
+		protoMajor  int
+	}
+	seenCh := make(chan seen, 1)
+	user := httptest.NewServer(network.NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCh <- seen{contentType: r.Header.Get("Content-Type"), protoMajor: r.ProtoMajor}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			w.Header().Set("Trailer", "Grpc-Status")
+			defer w.Header().Set("Grpc-Status", "0")
+		}
+		w.Write(body)
+	})).Handler)
+	defer user.Close()
+	userURL, _ := url.Parse(user.URL)
+	proxy := httputil.NewSingleHostReverseProxy(userURL)
+	proxy.Transport = network.AutoTransport
+
+	reqChan := make(chan queue.ReqEvent, 10)
+	go func() {
+		for range reqChan {
+		}
+	}()
+	defer close(reqChan)
+	server := httptest.NewServer(network.NewServer("", http.HandlerFunc(handler(reqChan, nil, queue.GRPCWebHandler(proxy)))).Handler)
+	defer server.Close()
+
+	msg := "\x00\x00\x00\x00\x04ping"
+	tests := []struct {
+		name          string
+		path          string
+		contentType   string
+		body          string
+		transport     http.RoundTripper
+		wantSeen      seen
+		wantType      string
+		wantBody      string
+		wantGRPCTrail string
+	}{{
+		name:          "server reflection",
+		path:          "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+		contentType:   "application/grpc",
+		body:          msg,
+		transport:     network.NewH2CTransport(),
+		wantSeen:      seen{contentType: "application/grpc", protoMajor: 2},
+		wantType:      "application/grpc",
+		wantBody:      msg,
+		wantGRPCTrail: "0",
+	}, {
+		name:        "json transcoding",
+		path:        "/v1/ping",
+		contentType: "application/json",
+		body:        `{"msg":"ping"}`,
+		transport:   http.DefaultTransport,
+		wantSeen:    seen{contentType: "application/json", protoMajor: 1},
+		wantType:    "application/json",
+		wantBody:    `{"msg":"ping"}`,
+	}, {
+		name:        "grpc-web",
+		path:        "/ping.PingService/Ping",
+		contentType: "application/grpc-web+proto",
+		body:        msg,
+		transport:   http.DefaultTransport,
+		wantSeen:    seen{contentType: "application/grpc+proto", protoMajor: 2},
+		wantType:    "application/grpc-web+proto",
+		wantBody:    msg + "\x80\x00\x00\x00\x10grpc-status: 0\r\n",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL+test.path, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			resp, err := test.transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() = %v", err)
+			}
+			defer resp.Body.Close()
+			got, _ := ioutil.ReadAll(resp.Body)
+
+			if s := <-seenCh; s != test.wantSeen {
+				t.Errorf("User container saw %+v, want: %+v", s, test.wantSeen)
+			}
+			if got, want := resp.Header.Get("Content-Type"), test.wantType; got != want {
+				t.Errorf("Content-Type = %q, want: %q", got, want)
+			}
+			if string(got) != test.wantBody {
+				t.Errorf("Body = %q, want: %q", got, test.wantBody)
+			}
+			if got, want := resp.Trailer.Get("Grpc-Status"), test.wantGRPCTrail; got != want {
+				t.Errorf("Grpc-Status trailer = %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerQueueWait(t *testing.T) {
+	defer func(f func(time.Duration)) {
+		reportQueueWait = f
+	}(reportQueueWait)
+	var reported []time.Duration
+	reportQueueWait = func(d time.Duration) {
+		reported = append(reported, d)
+	}
+
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	breaker := queue.NewBreaker(queue.BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	reqChan := make(chan queue.ReqEvent, 10)
+	h := handler(reqChan, breaker, proxy)
+
+	writer := httptest.NewRecorder()
+	h(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if got, want := writer.Code, http.StatusOK; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	wait, err := time.ParseDuration(writer.Header().Get(network.QueueWaitTimeHeaderName))
+	if err != nil {
+		t.Errorf("%s = %q, want a duration", network.QueueWaitTimeHeaderName, writer.Header().Get(network.QueueWaitTimeHeaderName))
+	}
+	if got, want := reported, []time.Duration{wait}; !cmp.Equal(got, want) {
+		t.Errorf("Reported queue waits = %v, want: %v", got, want)
+	}
+}
+
+func TestHandlerQueueTimeout(t *testing.T) {
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request was passed to the user container")
+	})
+
+	// Without capacity the request stays queued until it times out.
+	breaker := queue.NewBreaker(queue.BreakerParams{QueueDepth: 1, MaxConcurrency: 0, InitialCapacity: 0})
+	reqChan := make(chan queue.ReqEvent, 10)
+	h := queue.TimeToFirstByteTimeoutHandler(http.HandlerFunc(handler(reqChan, breaker, proxy)), 10*time.Millisecond, "request timeout")
+
+	writer := httptest.NewRecorder()
+	h.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if got, want := writer.Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+}
+
+func TestHandlerDraining(t *testing.T) {
+	defer func(hs *health.State) {
+		healthState = hs
+	}(healthState)
+	healthState = &health.State{}
+
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	reqChan := make(chan queue.ReqEvent, 10)
+	h := handler(reqChan, nil, proxy)
+
+	writer := httptest.NewRecorder()
+	h(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if got := writer.Header().Get("Connection"); got != "" {
+		t.Errorf("Connection = %q before shutdown, want none", got)
+	}
+
+	healthState.Shutdown(func() {
+		writer := httptest.NewRecorder()
+		h(writer, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+		if got, want := writer.Code, http.StatusOK; got != want {
+			t.Errorf("Status = %d, want: %d", got, want)
+		}
+		if got, want := writer.Header().Get("Connection"), "close"; got != want {
+			t.Errorf("Connection = %q while draining, want: %q", got, want)
+		}
+	})
+}
+
 func TestProberHandler(t *testing.T) {
 	defer logtesting.ClearAll()
 	logger = logtesting.TestLogger(t)
@@ -134,6 +425,11 @@ func TestProberHandler(t *testing.T) {
 		// \r\n might be inserted, etc.
 		t.Errorf("Good probe body = %q, want: %q, diff: %s", got, want, cmp.Diff(got, want))
 	}
+
+	// The prober didn't announce a protocol version.
+	if got, want := writer.Header().Get(network.ProtocolVersionHeaderName), network.ProtocolV1.String(); got != want {
+		t.Errorf("Good probe %s = %q, want: %q", network.ProtocolVersionHeaderName, got, want)
+	}
 }
 
 func TestCreateVarLogLink(t *testing.T) {
@@ -248,3 +544,224 @@ func TestProbeQueueDelayedReady(t *testing.T) {
 		t.Errorf("probeQueueHealthPath(%d) = %s", port, err)
 	}
 }
+
+func TestLoopbackAddress(t *testing.T) {
+	for podIP, want := range map[string]string{
+		"10.4.0.12":      "127.0.0.1",
+		"fd00:10:4::c":   "::1",
+		"":               "127.0.0.1",
+		"not-an-address": "127.0.0.1",
+	} {
+		if got := loopbackAddress(podIP); got != want {
+			t.Errorf("loopbackAddress(%q) = %q, want %q", podIP, got, want)
+		}
+	}
+}
+
+type fakeCheckpointer struct {
+	restore     chan bool
+	checkpoints chan struct{}
+}
+
+func (c *fakeCheckpointer) Restore(context.Context) (bool, error) {
+	return <-c.restore, nil
+}
+
+func (c *fakeCheckpointer) Checkpoint(context.Context) error {
+	c.checkpoints <- struct{}{}
+	return nil
+}
+
+func TestCheckpointOnReady(t *testing.T) {
+	defer logtesting.ClearAll()
+	logger = logtesting.TestLogger(t)
+	ready := func() bool { return true }
+
+	if got := checkpointOnReady(ready, nil)(); !got {
+		t.Error("Probe without a checkpointer = false, want: true")
+	}
+
+	for _, restored := range []bool{true, false} {
+		t.Run(fmt.Sprint("restored=", restored), func(t *testing.T) {
+			c := &fakeCheckpointer{restore: make(chan bool), checkpoints: make(chan struct{}, 2)}
+			prober := checkpointOnReady(ready, c)
+			if prober() {
+				t.Error("Probe while restoring = true, want: false")
+			}
+
+			c.restore <- restored
+			if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+				return prober(), nil
+			}); err != nil {
+				t.Fatal("Probe never succeeded after the restore")
+			}
+			prober()
+
+			select {
+			case <-c.checkpoints:
+				if restored {
+					t.Error("Restored pod was checkpointed")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if !restored {
+					t.Error("Pod that was not restored was not checkpointed")
+				}
+			}
+			select {
+			case <-c.checkpoints:
+				t.Error("Pod was checkpointed twice")
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestRequestWeight(t *testing.T) {
+	defer func(h string, c serving.RequestCosts) {
+		requestWeightHeader, requestCosts = h, c
+	}(requestWeightHeader, requestCosts)
+
+	costs, err := serving.ParseRequestCosts("POST /v1/predict=4, /static/*=2, bytes=1Ki")
+	if err != nil {
+		t.Fatalf("ParseRequestCosts() = %v", err)
+	}
+	for _, test := range []struct {
+		name     string
+		header   string
+		value    string
+		costs    serving.RequestCosts
+		method   string
+		path     string
+		body     int
+		capacity int
+		want     int
+	}{{
+		name:  "no weight header",
+		value: "5",
+		want:  1,
+	}, {
+		name:   "weight",
+		header: "X-Batch-Size",
+		value:  "5",
+		want:   5,
+	}, {
+		name:   "missing weight",
+		header: "X-Batch-Size",
+		want:   1,
+	}, {
+		name:   "invalid weight",
+		header: "X-Batch-Size",
+		value:  "-5",
+		want:   1,
+	}, {
+		name:  "cost by method and path",
+		costs: costs,
+		path:  "/v1/predict",
+		want:  4,
+	}, {
+		name:   "cost by path only for the method",
+		costs:  costs,
+		method: http.MethodGet,
+		path:   "/v1/predict",
+		want:   1,
+	}, {
+		name:   "cost by path prefix",
+		costs:  costs,
+		method: http.MethodGet,
+		path:   "/static/app.js",
+		want:   2,
+	}, {
+		name:  "cost by body size",
+		costs: costs,
+		path:  "/v1/upload",
+		body:  5000,
+		want:  5,
+	}, {
+		name:  "larger cost of path and body size",
+		costs: costs,
+		path:  "/v1/predict",
+		body:  2000,
+		want:  4,
+	}, {
+		name:   "weight under cost",
+		header: "X-Batch-Size",
+		value:  "1",
+		costs:  costs,
+		path:   "/v1/predict",
+		want:   4,
+	}, {
+		name:   "weight over cost",
+		header: "X-Batch-Size",
+		value:  "6",
+		costs:  costs,
+		path:   "/v1/predict",
+		want:   6,
+	}, {
+		name:     "weight over capacity",
+		header:   "X-Batch-Size",
+		value:    "100",
+		capacity: 10,
+		want:     10,
+	}, {
+		name:     "cost over capacity",
+		costs:    costs,
+		path:     "/v1/predict",
+		capacity: 3,
+		want:     3,
+	}, {
+		name:   "invalid weight falls back to cost",
+		header: "X-Batch-Size",
+		value:  "many",
+		costs:  costs,
+		path:   "/v1/predict",
+		want:   4,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			requestWeightHeader, requestCosts = test.header, test.costs
+			method, path := test.method, test.path
+			if method == "" {
+				method = http.MethodPost
+			}
+			if path == "" {
+				path = "/"
+			}
+			req := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(strings.Repeat("x", test.body)))
+			if test.value != "" {
+				req.Header.Set("X-Batch-Size", test.value)
+			}
+			if got := requestWeight(req, test.capacity); got != test.want {
+				t.Errorf("requestWeight() = %d, want: %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMakeAuthenticationPolicy(t *testing.T) {
+	a, err := serving.ParseRequestAuthentication("jwksSecret=jwks, clientSANs=spiffe://client, exemptPaths=/public/*")
+	if err != nil {
+		t.Fatalf("ParseRequestAuthentication() = %v", err)
+	}
+	p := makeAuthenticationPolicy(a, []string{"/healthz"})
+
+	if got, want := len(p.Authenticators), 2; got != want {
+		t.Fatalf("len(Authenticators) = %d, want %d", got, want)
+	}
+	if _, ok := p.Authenticators[0].(*queue.JWTAuthenticator); !ok {
+		t.Errorf("Authenticators[0] = %T, want *queue.JWTAuthenticator", p.Authenticators[0])
+	}
+	if _, ok := p.Authenticators[1].(*queue.ClientCertAuthenticator); !ok {
+		t.Errorf("Authenticators[1] = %T, want *queue.ClientCertAuthenticator", p.Authenticators[1])
+	}
+	if !p.Exempt(httptest.NewRequest(http.MethodGet, "/public/index.html", nil)) {
+		t.Error("Exempt(/public/index.html) = false, want true")
+	}
+	if p.Exempt(httptest.NewRequest(http.MethodGet, "/admin", nil)) {
+		t.Error("Exempt(/admin) = true, want false")
+	}
+	if !cmp.Equal(p.ProbePaths, []string{"/healthz"}) {
+		t.Errorf("ProbePaths = %v, want [/healthz]", p.ProbePaths)
+	}
+	if p.Probe == nil {
+		t.Error("Probe = nil, want the queue-proxy's probe handler")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/knative/serving/pkg/otlp"
+	"github.com/knative/serving/pkg/tracing"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
+	zipkin "github.com/openzipkin/zipkin-go"
+	"go.opencensus.io/stats/view"
+)
+
+// tracingConfigFromEnv returns the config-tracing passed down by the
+// revision reconciler, or nil if tracing is disabled.
+func tracingConfigFromEnv(getenv func(string) string) (*tracingconfig.Config, error) {
+	if getenv("TRACING_CONFIG_BACKEND") == "" {
+		return nil, nil
+	}
+	cfg := map[string]string{"enable": "true"}
+	for key, env := range map[string]string{
+		"backend":         "TRACING_CONFIG_BACKEND",
+		"zipkin-endpoint": "TRACING_CONFIG_ZIPKIN_ENDPOINT",
+		"otlp-endpoint":   "TRACING_CONFIG_OTLP_ENDPOINT",
+		"otlp-protocol":   "TRACING_CONFIG_OTLP_PROTOCOL",
+		"debug":           "TRACING_CONFIG_DEBUG",
+		"sample-rate":     "TRACING_CONFIG_SAMPLE_RATE",
+	} {
+		// The unset values keep their defaults.
+		if v := getenv(env); v != "" {
+			cfg[key] = v
+		}
+	}
+	return tracingconfig.NewTracingConfigFromMap(cfg)
+}
+
+// otlpResource returns the attributes of the resource the queue-proxy
+// reports its spans and metrics for.
+func otlpResource() map[string]string {
+	resource := map[string]string{
+		"service.name":                  "queue-proxy",
+		"k8s.namespace.name":            servingNamespace,
+		"k8s.pod.name":                  servingPodName,
+		"knative.serving.configuration": servingConfig,
+		"knative.serving.revision":      servingRevision,
+	}
+	if servingService != "" {
+		resource["knative.serving.service"] = servingService
+	}
+	return resource
+}
+
+// setupTracing starts exporting the spans of the requests to the backend
+// of the config.
+func setupTracing(cfg *tracingconfig.Config) (*tracing.OpenCensusTracer, error) {
+	endpoint, err := zipkin.NewEndpoint("queue-proxy", net.JoinHostPort(servingPodIP, strconv.Itoa(queueServingPort)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the zipkin endpoint: %v", err)
+	}
+	oct := tracing.NewOpenCensusTracer(
+		tracing.WithZipkinExporter(tracing.CreateZipkinReporter, endpoint),
+		tracing.WithOTLPExporter(otlpResource()),
+	)
+	if err := oct.ApplyConfig(cfg); err != nil {
+		return nil, err
+	}
+	return oct, nil
+}
+
+// setupOTLPMetricsExporter exports the request metrics to the OTLP
+// collector passed down by the revision reconciler.
+func setupOTLPMetricsExporter(endpoint, protocol string) (*otlp.Exporter, error) {
+	e, err := otlp.NewExporter(otlp.Options{
+		Endpoint: endpoint,
+		Protocol: protocol,
+		Resource: otlpResource(),
+		Logger:   logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	view.RegisterExporter(e)
+	return e, nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
+)
+
+func TestTracingConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    *tracingconfig.Config
+		wantErr bool
+	}{{
+		name: "disabled",
+		env:  map[string]string{},
+	}, {
+		name: "zipkin",
+		env: map[string]string{
+			"TRACING_CONFIG_BACKEND":         "zipkin",
+			"TRACING_CONFIG_ZIPKIN_ENDPOINT": "http://zipkin:9411/api/v2/spans",
+			"TRACING_CONFIG_OTLP_ENDPOINT":   "",
+			"TRACING_CONFIG_OTLP_PROTOCOL":   "",
+			"TRACING_CONFIG_DEBUG":           "false",
+			"TRACING_CONFIG_SAMPLE_RATE":     "0.5",
+		},
+		want: &tracingconfig.Config{
+			Enable:         true,
+			Backend:        tracingconfig.Zipkin,
+			ZipkinEndpoint: "http://zipkin:9411/api/v2/spans",
+			OTLPProtocol:   tracingconfig.OTLPProtocolGRPC,
+			SampleRate:     0.5,
+		},
+	}, {
+		name: "otlp",
+		env: map[string]string{
+			"TRACING_CONFIG_BACKEND":       "otlp",
+			"TRACING_CONFIG_OTLP_ENDPOINT": "otel-collector:4317",
+			"TRACING_CONFIG_DEBUG":         "true",
+		},
+		want: &tracingconfig.Config{
+			Enable:       true,
+			Backend:      tracingconfig.OTLP,
+			OTLPEndpoint: "otel-collector:4317",
+			OTLPProtocol: tracingconfig.OTLPProtocolGRPC,
+			Debug:        true,
+			SampleRate:   0.1,
+		},
+	}, {
+		name: "otlp without endpoint",
+		env: map[string]string{
+			"TRACING_CONFIG_BACKEND": "otlp",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tracingConfigFromEnv(func(key string) string { return test.env[key] })
+			if (err != nil) != test.wantErr {
+				t.Fatalf("tracingConfigFromEnv() = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("tracingConfigFromEnv() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestOTLPResource(t *testing.T) {
+	defer func(ns, pod, svc, cfg, rev string) {
+		servingNamespace, servingPodName = ns, pod
+		servingService, servingConfig, servingRevision = svc, cfg, rev
+	}(servingNamespace, servingPodName, servingService, servingConfig, servingRevision)
+
+	servingNamespace, servingPodName = "default", "hello-00001-deployment-abcde"
+	servingService, servingConfig, servingRevision = "", "hello", "hello-00001"
+	want := map[string]string{
+		"service.name":                  "queue-proxy",
+		"k8s.namespace.name":            "default",
+		"k8s.pod.name":                  "hello-00001-deployment-abcde",
+		"knative.serving.configuration": "hello",
+		"knative.serving.revision":      "hello-00001",
+	}
+	if diff := cmp.Diff(want, otlpResource()); diff != "" {
+		t.Errorf("otlpResource() (-want, +got) = %v", diff)
+	}
+
+	servingService = "hello"
+	want["knative.serving.service"] = "hello"
+	if diff := cmp.Diff(want, otlpResource()); diff != "" {
+		t.Errorf("otlpResource() (-want, +got) = %v", diff)
+	}
+}
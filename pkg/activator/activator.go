@@ -29,8 +29,17 @@ const (
 	RevisionHeaderName = "Knative-Serving-Revision"
 	// RevisionHeaderNamespace is the header key for revision's namespace.
 	RevisionHeaderNamespace = "Knative-Serving-Namespace"
+	// OverloadReasonHeader is the header key for the reason the activator
+	// rejected a request it was too loaded to buffer.
+	OverloadReasonHeader = "Knative-Activator-Overload-Reason"
 )
 
+// PoolServiceName returns the name of the Kubernetes service of the
+// activators dedicated to the given pool.
+func PoolServiceName(pool string) string {
+	return fmt.Sprintf("%s-%s", K8sServiceName, pool)
+}
+
 // RevisionID is the combination of namespace and revision name
 type RevisionID struct {
 	Namespace string
@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+var (
+	breakerCountM = stats.Int64(
+		"breakers",
+		"The number of revisions the Activator keeps a breaker for",
+		stats.UnitDimensionless)
+	breakerEventCountM = stats.Int64(
+		"breaker_event_count",
+		"The number of breakers created, removed and evicted for being idle",
+		stats.UnitDimensionless)
+)
+
+// Values of the event tag of breakerEventCountM.
+const (
+	breakerCreated = "created"
+	breakerRemoved = "removed"
+	breakerEvicted = "evicted"
+)
+
+// breakerPoolReporter reports the lifecycle of the per revision breakers
+// of the Throttler.
+type breakerPoolReporter struct {
+	logger          *zap.SugaredLogger
+	namespaceTagKey tag.Key
+	revisionTagKey  tag.Key
+	eventTagKey     tag.Key
+}
+
+func newBreakerPoolReporter(logger *zap.SugaredLogger) (*breakerPoolReporter, error) {
+	r := &breakerPoolReporter{logger: logger}
+
+	var err error
+	if r.namespaceTagKey, err = tag.NewKey(metricskey.LabelNamespaceName); err != nil {
+		return nil, err
+	}
+	if r.revisionTagKey, err = tag.NewKey(metricskey.LabelRevisionName); err != nil {
+		return nil, err
+	}
+	if r.eventTagKey, err = tag.NewKey("event"); err != nil {
+		return nil, err
+	}
+
+	err = view.Register(
+		&view.View{
+			Description: "The number of revisions the Activator keeps a breaker for",
+			Measure:     breakerCountM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: "The number of breakers created, removed and evicted for being idle",
+			Measure:     breakerEventCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{r.namespaceTagKey, r.revisionTagKey, r.eventTagKey},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BreakerCreated implements queue.BreakerPoolReporter.
+func (r *breakerPoolReporter) BreakerCreated(key interface{}, size int) {
+	r.report(key.(RevisionID), size, breakerCreated)
+}
+
+// BreakerRemoved implements queue.BreakerPoolReporter.
+func (r *breakerPoolReporter) BreakerRemoved(key interface{}, size int, idle bool) {
+	event := breakerRemoved
+	if idle {
+		event = breakerEvicted
+	}
+	r.report(key.(RevisionID), size, event)
+}
+
+func (r *breakerPoolReporter) report(rev RevisionID, size int, event string) {
+	metrics.Record(context.Background(), breakerCountM.M(int64(size)))
+
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(r.namespaceTagKey, rev.Namespace),
+		tag.Insert(r.revisionTagKey, rev.Name),
+		tag.Insert(r.eventTagKey, event))
+	if err != nil {
+		r.logger.Errorw("Failed to create the tags of the breaker metrics", zap.Error(err))
+		return
+	}
+	metrics.Record(ctx, breakerEventCountM.M(1))
+}
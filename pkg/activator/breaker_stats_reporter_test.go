@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+// unregisterBreakerPoolViews unregisters the views registered by the
+// throttlers created by the other tests, or by a previous run of the test
+// with -count > 1.
+func unregisterBreakerPoolViews() {
+	for _, s := range []string{"breakers", "breaker_event_count"} {
+		if v := view.Find(s); v != nil {
+			view.Unregister(v)
+		}
+	}
+}
+
+func TestBreakerPoolReporter(t *testing.T) {
+	unregisterBreakerPoolViews()
+	r, err := newBreakerPoolReporter(TestLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create a new reporter: %v", err)
+	}
+	defer unregisterBreakerPoolViews()
+
+	rev := RevisionID{Namespace: "testns", Name: "testrev"}
+	r.BreakerCreated(rev, 1)
+	r.BreakerCreated(RevisionID{Namespace: "testns", Name: "otherrev"}, 2)
+	r.BreakerRemoved(rev, 1, true /*idle*/)
+
+	rows, err := view.RetrieveData("breakers")
+	if err != nil {
+		t.Fatalf("Failed to retrieve the breakers metric: %v", err)
+	}
+	if got, want := rows[0].Data.(*view.LastValueData).Value, 1.0; got != want {
+		t.Errorf("breakers = %v, want: %v", got, want)
+	}
+
+	rows, err = view.RetrieveData("breaker_event_count")
+	if err != nil {
+		t.Fatalf("Failed to retrieve the breaker_event_count metric: %v", err)
+	}
+	got := map[string]int64{}
+	for _, row := range rows {
+		// The tags are sorted by key: event, namespace, revision.
+		key := ""
+		for _, tag := range row.Tags {
+			key += tag.Value + "/"
+		}
+		got[key] = row.Data.(*view.CountData).Value
+	}
+	want := map[string]int64{
+		"created/testns/testrev/":  1,
+		"created/testns/otherrev/": 1,
+		"evicted/testns/testrev/":  1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("breaker_event_count = %v, want: %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("breaker_event_count[%s] = %d, want: %d", k, got[k], v)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 
 	"knative.dev/pkg/configmap"
+	"github.com/knative/serving/pkg/network"
 	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 )
 
@@ -29,11 +30,14 @@ type cfgKey struct{}
 // Config is a configuration for the activator
 type Config struct {
 	Tracing *tracingconfig.Config
+	Network *network.Config
 }
 
-// FromContext obtains a Config injected into the passed context
+// FromContext obtains a Config injected into the passed context, or nil
+// if there is none.
 func FromContext(ctx context.Context) *Config {
-	return ctx.Value(cfgKey{}).(*Config)
+	cfg, _ := ctx.Value(cfgKey{}).(*Config)
+	return cfg
 }
 
 func toContext(ctx context.Context, c *Config) context.Context {
@@ -54,6 +58,7 @@ func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value i
 			logger,
 			configmap.Constructors{
 				tracingconfig.ConfigName: tracingconfig.NewTracingConfigFromConfigMap,
+				network.ConfigName:       network.NewConfigFromConfigMap,
 			},
 			onAfterStore...,
 		),
@@ -69,6 +74,7 @@ func (s *Store) ToContext(ctx context.Context) context.Context {
 func (s *Store) Load() *Config {
 	return &Config{
 		Tracing: s.UntypedLoad(tracingconfig.ConfigName).(*tracingconfig.Config).DeepCopy(),
+		Network: s.UntypedLoad(network.ConfigName).(*network.Config).DeepCopy(),
 	}
 }
 
@@ -21,6 +21,7 @@ limitations under the License.
 package config
 
 import (
+	network "github.com/knative/serving/pkg/network"
 	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 )
 
@@ -32,6 +33,11 @@ func (in *Config) DeepCopyInto(out *Config) {
 		*out = new(tracingconfig.Config)
 		**out = **in
 	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(network.Config)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
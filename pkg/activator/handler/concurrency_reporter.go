@@ -33,6 +33,10 @@ type ConcurrencyReporter struct {
 	reportChan <-chan time.Time
 	// Stat reporting channel
 	statChan chan *autoscaler.StatMessage
+	// epoch identifies this process in the stat messages, and nonce is
+	// incremented for every stat message sent.
+	epoch int64
+	nonce uint64
 
 	clock system.Clock
 }
@@ -51,6 +55,7 @@ func NewConcurrencyReporterWithClock(podName string, reqChan chan ReqEvent, repo
 		reqChan:    reqChan,
 		reportChan: reportChan,
 		statChan:   statChan,
+		epoch:      clock.Now().UnixNano(),
 		clock:      clock,
 	}
 }
@@ -64,9 +69,12 @@ func (cr *ConcurrencyReporter) report(key string, concurrency, requestCount int3
 
 	// Send the stat to another goroutine to transmit
 	// so we can continue bucketing stats.
+	cr.nonce++
 	cr.statChan <- &autoscaler.StatMessage{
-		Key:  key,
-		Stat: stat,
+		Key:   key,
+		Stat:  stat,
+		Epoch: cr.epoch,
+		Nonce: cr.nonce,
 	}
 }
 
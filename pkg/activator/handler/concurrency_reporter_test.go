@@ -217,11 +217,23 @@ func TestStats(t *testing.T) {
 				stats = append(stats, sm)
 			}
 
+			// Every message carries the epoch of the reporter and the
+			// next nonce.
+			for i, sm := range stats {
+				if got, want := sm.Nonce, uint64(i+1); got != want {
+					t.Errorf("stats[%d].Nonce = %d, want %d", i, got, want)
+				}
+				if got, want := sm.Epoch, cr.epoch; got != want || got == 0 {
+					t.Errorf("stats[%d].Epoch = %d, want %d", i, got, want)
+				}
+			}
+
 			// Check the stats we got match what we wanted
 			sorter := cmpopts.SortSlices(func(a, b *autoscaler.StatMessage) bool {
 				return a.Key < b.Key
 			})
-			if diff := cmp.Diff(tc.expectedStats, stats, sorter); diff != "" {
+			ignoreNonce := cmpopts.IgnoreFields(autoscaler.StatMessage{}, "Epoch", "Nonce")
+			if diff := cmp.Diff(tc.expectedStats, stats, sorter, ignoreNonce); diff != "" {
 				t.Errorf("Unexpected stats (-want +got): %v", diff)
 			}
 		})
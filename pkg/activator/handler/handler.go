@@ -18,9 +18,12 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
@@ -29,7 +32,9 @@ import (
 
 	"knative.dev/pkg/logging/logkey"
 	"github.com/knative/serving/pkg/activator"
+	activatorconfig "github.com/knative/serving/pkg/activator/config"
 	"github.com/knative/serving/pkg/activator/util"
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
@@ -52,6 +57,15 @@ type activationHandler struct {
 	transport http.RoundTripper
 	reporter  activator.StatsReporter
 	throttler *activator.Throttler
+	upgrades  *pkghttp.UpgradeTracker
+	journal   *activator.Journal
+	limiter   *activator.RateLimiter
+	nsLimiter *activator.NamespaceLimiter
+	assets    *activator.StaticAssets
+
+	// transports are the transports of the requests to each revision,
+	// transport is used when it's nil.
+	transports *activator.RevisionTransports
 
 	probeTimeout          time.Duration
 	probeTransportFactory prober.TransportFactory
@@ -60,21 +74,47 @@ type activationHandler struct {
 	revisionLister servinglisters.RevisionLister
 	serviceLister  corev1listers.ServiceLister
 	sksLister      netlisters.ServerlessServiceLister
+
+	// trustedProxies holds the last parsed trustedProxyNets.
+	trustedProxies atomic.Value
+}
+
+// trustedProxyNets are the parsed CIDRs of the peers trusted to forward
+// the client certificate, as configured by raw.
+type trustedProxyNets struct {
+	raw  string
+	nets []*net.IPNet
 }
 
 // The default time we'll try to probe the revision for activation.
 const defaulTimeout = 2 * time.Minute
 
+// errProbeFailed is the error journaled for the requests whose revision
+// could not be probed in time.
+var errProbeFailed = errors.New("revision probe failed")
+
 // New constructs a new http.Handler that deals with revision activation.
+// The requests failed while their revision scales from zero are recorded
+// in j, the rate limits of the revisions are enforced by lim, the
+// concurrency limits of their namespaces by nsLim and their static assets
+// are served by sa and the requests are proxied with the transports of their
+// revision in ts. Any of them may be nil.
 func New(l *zap.SugaredLogger, r activator.StatsReporter, t *activator.Throttler,
 	rl servinglisters.RevisionLister, sl corev1listers.ServiceLister,
-	sksL netlisters.ServerlessServiceLister) http.Handler {
+	sksL netlisters.ServerlessServiceLister, j *activator.Journal, lim *activator.RateLimiter,
+	nsLim *activator.NamespaceLimiter, sa *activator.StaticAssets, ts *activator.RevisionTransports) http.Handler {
 
 	return &activationHandler{
 		logger:         l,
 		transport:      network.AutoTransport,
+		transports:     ts,
 		reporter:       r,
 		throttler:      t,
+		upgrades:       pkghttp.NewUpgradeTracker(),
+		journal:        j,
+		limiter:        lim,
+		nsLimiter:      nsLim,
+		assets:         sa,
 		revisionLister: rl,
 		sksLister:      sksL,
 		serviceLister:  sl,
@@ -99,7 +139,67 @@ func withOrigProto(or *http.Request) prober.Preparer {
 	}
 }
 
-func (a *activationHandler) probeEndpoint(logger *zap.SugaredLogger, r *http.Request, target *url.URL) (bool, int) {
+// timeouts returns how long the request may wait for capacity in the
+// throttler and for the revision to become reachable. They depend on the
+// request's method and whether its revision is scaling from zero, unless
+// there is no network configuration, and are bounded by the request's
+// deadline.
+func (a *activationHandler) timeouts(r *http.Request, revID activator.RevisionID) (endpointTimeout, probeTimeout time.Duration) {
+	endpointTimeout, probeTimeout = a.endpointTimeout, a.probeTimeout
+	if cfg := activatorconfig.FromContext(r.Context()); cfg != nil && cfg.Network != nil {
+		timeout := cfg.Network.ActivatorTimeouts.For(r.Method, !a.throttler.HasCapacity(revID))
+		endpointTimeout, probeTimeout = timeout, timeout
+	}
+	if deadline, ok := r.Context().Deadline(); ok {
+		left := time.Until(deadline)
+		endpointTimeout, probeTimeout = minDuration(endpointTimeout, left), minDuration(probeTimeout, left)
+	}
+	return endpointTimeout, probeTimeout
+}
+
+// revisionTimeout returns the longest a request to the revision may take, or
+// 0 if it doesn't say.
+func revisionTimeout(rev *v1alpha1.Revision) time.Duration {
+	if rev.Spec.TimeoutSeconds == nil {
+		return 0
+	}
+	return time.Duration(*rev.Spec.TimeoutSeconds) * time.Second
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// shedsCapacityPending returns whether the requests that would wait for a
+// revision whose pods can't be scheduled are failed right away.
+func shedsCapacityPending(r *http.Request) bool {
+	cfg := activatorconfig.FromContext(r.Context())
+	return cfg != nil && cfg.Network != nil && cfg.Network.ActivatorShedCapacityPending
+}
+
+// trustsForwardedClientCert returns whether the peer of the request is
+// trusted to forward the client certificate it verified. The CIDRs are
+// only parsed again when the config changes.
+func (a *activationHandler) trustsForwardedClientCert(r *http.Request) bool {
+	cfg := activatorconfig.FromContext(r.Context())
+	if cfg == nil || cfg.Network == nil {
+		return false
+	}
+	raw := cfg.Network.ClientCertTrustedProxies
+	trusted, ok := a.trustedProxies.Load().(trustedProxyNets)
+	if !ok || trusted.raw != raw {
+		// The CIDRs were validated when the config was loaded.
+		nets, _ := network.ParseCIDRs(raw)
+		trusted = trustedProxyNets{raw: raw, nets: nets}
+		a.trustedProxies.Store(trusted)
+	}
+	return network.IsPeerTrusted(r, trusted.nets)
+}
+
+func (a *activationHandler) probeEndpoint(logger *zap.SugaredLogger, r *http.Request, target *url.URL, timeout time.Duration) (bool, int) {
 	var (
 		attempts int
 		st       = time.Now()
@@ -111,7 +211,7 @@ func (a *activationHandler) probeEndpoint(logger *zap.SugaredLogger, r *http.Req
 		a.logger.Debugf("Probing %s took %d attempts and %v time", target.String(), attempts, time.Since(st))
 	}()
 
-	err := wait.PollImmediate(100*time.Millisecond, a.probeTimeout, func() (bool, error) {
+	err := wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
 		attempts++
 		ret, err := prober.Do(
 			reqCtx,
@@ -141,6 +241,12 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	logger := a.logger.With(zap.String(logkey.Key, revID.String()))
 
+	// The queue-proxy may trust the activator to forward the client
+	// certificate, so don't pass along the one of an untrusted peer.
+	if !a.trustsForwardedClientCert(r) {
+		r.Header.Del(network.ForwardedClientCertHeaderName)
+	}
+
 	revision, err := a.revisionLister.Revisions(namespace).Get(name)
 	if err != nil {
 		logger.Errorw("Error while getting revision", zap.Error(err))
@@ -148,6 +254,70 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var configurationName string
+	var serviceName string
+	if revision.Labels != nil {
+		configurationName = revision.Labels[serving.ConfigurationLabelKey]
+		serviceName = revision.Labels[serving.ServiceLabelKey]
+	}
+
+	// Don't wait for longer than the client, nor than the revision would.
+	r, cancel, err := pkghttp.WithRequestTimeout(r, revisionTimeout(revision))
+	defer cancel()
+	if err == pkghttp.ErrRequestTimeoutExpired {
+		logger.Debug("Rejecting request whose timeout expired")
+		pkghttp.WriteDeadlineExceeded(w, r)
+		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusGatewayTimeout, 0, 1.0)
+		return
+	}
+
+	if autoscaling.IsHibernated(revision.Annotations) {
+		// A hibernated revision is never scaled up, so waiting for it
+		// would only time out.
+		logger.Debug("Rejecting request to hibernated revision")
+		sendHibernationPage(w, revision.Annotations[autoscaling.HibernationPageAnnotationKey])
+		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusServiceUnavailable, 0, 1.0)
+		return
+	}
+
+	if a.assets != nil {
+		// Static assets are served without waking the revision.
+		if status := a.assets.Serve(w, r, revision); status != 0 {
+			a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, status, 0, 1.0)
+			a.reporter.ReportResponseTime(namespace, serviceName, configurationName, name, status, time.Since(start))
+			return
+		}
+	}
+
+	if !a.allowRate(revID, revision.Annotations) {
+		logger.Debug("Rejecting request over the rate limit")
+		// The rate is limited per second.
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusTooManyRequests, 0, 1.0)
+		return
+	}
+
+	release, ok := a.acquireNamespace(namespace)
+	if !ok {
+		logger.Debug("Rejecting request over the concurrency limit of the namespace")
+		sendOverloaded(w, OverloadReasonNamespaceLimit)
+		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusServiceUnavailable, 0, 1.0)
+		return
+	}
+	defer release()
+
+	if proto := pkghttp.UpgradeProtocol(r); proto != "" {
+		release, err := a.upgrades.Admit(revID.String(), pkghttp.UpgradePolicyFromAnnotations(revision.Annotations), proto)
+		if err != nil {
+			logger.Infow("Rejecting upgrade to "+proto, zap.Error(err))
+			http.Error(w, err.Error(), pkghttp.UpgradeErrorStatus(err))
+			return
+		}
+		defer release()
+		a.reporter.ReportUpgradedConnectionCount(namespace, serviceName, configurationName, name, proto, 1)
+	}
+
 	// SKS name matches that of revision.
 	sks, err := a.sksLister.ServerlessServices(namespace).Get(name)
 	if err != nil {
@@ -155,6 +325,14 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		sendError(err, w)
 		return
 	}
+	if sks.Spec.CapacityPending && shedsCapacityPending(r) && !a.throttler.HasCapacity(revID) {
+		// The pods of the revision can't be scheduled, so it won't get
+		// the capacity for the request before it times out.
+		logger.Debug("Rejecting request to revision waiting for cluster capacity")
+		sendOverloaded(w, OverloadReasonCapacityPending)
+		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusServiceUnavailable, 0, 1.0)
+		return
+	}
 	host, err := a.serviceHostName(revision, sks.Status.PrivateServiceName)
 	if err != nil {
 		logger.Errorw("Error while getting hostname", zap.Error(err))
@@ -167,9 +345,19 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Host:   host,
 	}
 
+	endpointTimeout, probeTimeout := a.timeouts(r, revID)
+	coldStart := !a.throttler.HasCapacity(revID)
+	journal := func(status int, err error) {
+		if !coldStart {
+			return
+		}
+		if err := a.journal.Record(revID, revision.Annotations, r, status, err); err != nil {
+			logger.Errorw("Failed to journal request", zap.Error(err))
+		}
+	}
 	_, ttSpan := trace.StartSpan(r.Context(), "throttler_try")
 	ttStart := time.Now()
-	err = a.throttler.Try(a.endpointTimeout, revID, func() {
+	err = a.throttler.Try(endpointTimeout, revID, func() {
 		var (
 			httpStatus int
 		)
@@ -177,28 +365,22 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ttSpan.End()
 		a.logger.Debugf("Waiting for throttler took %v time", time.Since(ttStart))
 
-		success, attempts := a.probeEndpoint(logger, r, target)
+		success, attempts := a.probeEndpoint(logger, r, target, probeTimeout)
 		if success {
 			// Once we see a successful probe, send traffic.
 			attempts++
 			reqCtx, proxySpan := trace.StartSpan(r.Context(), "proxy")
-			httpStatus = a.proxyRequest(w, r.WithContext(reqCtx), target)
+			httpStatus = a.proxyRequest(w, r.WithContext(reqCtx), target, a.transportFor(revID))
 			proxySpan.End()
 		} else {
 			httpStatus = http.StatusInternalServerError
 			w.WriteHeader(httpStatus)
+			journal(httpStatus, errProbeFailed)
 		}
 
 		// Report the metrics
 		duration := time.Since(start)
 
-		var configurationName string
-		var serviceName string
-		if revision.Labels != nil {
-			configurationName = revision.Labels[serving.ConfigurationLabelKey]
-			serviceName = revision.Labels[serving.ServiceLabelKey]
-		}
-
 		a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, httpStatus, attempts, 1.0)
 		a.reporter.ReportResponseTime(namespace, serviceName, configurationName, name, httpStatus, duration)
 	})
@@ -209,27 +391,72 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}, "ThrottlerTry")
 		ttSpan.End()
 
-		if err == activator.ErrActivatorOverload {
-			http.Error(w, activator.ErrActivatorOverload.Error(), http.StatusServiceUnavailable)
-		} else {
+		switch err {
+		case activator.ErrActivatorOverload:
+			sendOverloaded(w, OverloadReasonRevisionBacklog)
+			a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusServiceUnavailable, 0, 1.0)
+			journal(http.StatusServiceUnavailable, err)
+		case activator.ErrActivatorTimeout:
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+			a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusGatewayTimeout, 0, 1.0)
+			journal(http.StatusGatewayTimeout, err)
+		case queue.ErrDraining, queue.ErrPreempted:
+			// The revision went away, or lost the capacity to serve the
+			// request in time, while the request waited for it.
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			a.reporter.ReportRequestCount(namespace, serviceName, configurationName, name, http.StatusServiceUnavailable, 0, 1.0)
+			journal(http.StatusServiceUnavailable, err)
+		default:
 			w.WriteHeader(http.StatusInternalServerError)
 			logger.Errorw("Error processing request in the activator", zap.Error(err))
+			journal(http.StatusInternalServerError, err)
 		}
 	}
 }
 
-func (a *activationHandler) proxyRequest(w http.ResponseWriter, r *http.Request, target *url.URL) int {
+// allowRate returns whether the request to the revision is within its rate
+// limit, if it has one.
+func (a *activationHandler) allowRate(revID activator.RevisionID, annotations map[string]string) bool {
+	if a.limiter == nil {
+		return true
+	}
+	limit, err := strconv.Atoi(annotations[serving.RateLimitAnnotationKey])
+	if err != nil || limit < 1 {
+		return true
+	}
+	return a.limiter.Allow(revID, limit)
+}
+
+// acquireNamespace admits a request to a revision of the namespace within
+// the concurrency limit of the namespace, if it has one.
+func (a *activationHandler) acquireNamespace(namespace string) (func(), bool) {
+	if a.nsLimiter == nil {
+		return func() {}, true
+	}
+	return a.nsLimiter.Acquire(namespace)
+}
+
+// transportFor returns the transport of the requests to the revision.
+func (a *activationHandler) transportFor(revID activator.RevisionID) http.RoundTripper {
+	if a.transports == nil {
+		return a.transport
+	}
+	return a.transports.Get(revID)
+}
+
+func (a *activationHandler) proxyRequest(w http.ResponseWriter, r *http.Request, target *url.URL, transport http.RoundTripper) int {
 	network.RewriteHostIn(r)
 	recorder := pkghttp.NewResponseRecorder(w, http.StatusOK)
 	proxy := httputil.NewSingleHostReverseProxy(target)
 	proxy.Transport = &ochttp.Transport{
-		Base: a.transport,
+		Base: transport,
 	}
 	proxy.FlushInterval = -1
 
 	r.Header.Set(network.ProxyHeaderName, activator.Name)
 
 	util.SetupHeaderPruning(proxy)
+	pkghttp.SetupRequestTimeoutForwarding(proxy)
 
 	proxy.ServeHTTP(recorder, r)
 	return recorder.ResponseCode
@@ -257,7 +484,19 @@ func (a *activationHandler) serviceHostName(rev *v1alpha1.Revision, serviceName
 
 	serviceFQDN := network.GetServiceHostname(serviceName, rev.Namespace)
 
-	return fmt.Sprintf("%s:%d", serviceFQDN, port), nil
+	return net.JoinHostPort(serviceFQDN, strconv.Itoa(port)), nil
+}
+
+// sendHibernationPage responds with the given page, or a default message
+// when none is configured, and a 503 status.
+func sendHibernationPage(w http.ResponseWriter, page string) {
+	if page == "" {
+		http.Error(w, "Revision is hibernated", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType([]byte(page)))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(page))
 }
 
 func sendError(err error, w http.ResponseWriter) {
@@ -15,6 +15,7 @@ limitations under the License.
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -25,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/test/helpers"
 
 	"github.com/google/go-cmp/cmp"
@@ -36,7 +38,9 @@ import (
 	. "knative.dev/pkg/logging/testing"
 	_ "knative.dev/pkg/system/testing"
 	"github.com/knative/serving/pkg/activator"
+	activatorconfig "github.com/knative/serving/pkg/activator/config"
 	activatortest "github.com/knative/serving/pkg/activator/testing"
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	nv1a1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
@@ -45,6 +49,7 @@ import (
 	servinginformers "github.com/knative/serving/pkg/client/informers/externalversions"
 	netlisters "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
 	servinglisters "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
+	pkghttp "github.com/knative/serving/pkg/http"
 	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/queue"
 	"github.com/knative/serving/pkg/tracing"
@@ -317,6 +322,7 @@ func TestActivationHandler(t *testing.T) {
 				revisionLister(revision(testNamespace, testRevName)),
 				serviceLister(service(testNamespace, testRevName, "http")),
 				sksLister(sks(testNamespace, testRevName)),
+				nil, nil, nil, nil, nil,
 			)).(*activationHandler)
 			handler.probeTimeout = test.probeTimeout
 
@@ -392,6 +398,7 @@ func TestActivationHandlerOverflow(t *testing.T) {
 		revisionLister(revision(namespace, revName)),
 		serviceLister(service(namespace, revName, "http")),
 		sksLister(sks(namespace, revName)),
+		nil, nil, nil, nil, nil,
 	)).(*activationHandler)
 
 	// Setup transports.
@@ -402,6 +409,279 @@ func TestActivationHandlerOverflow(t *testing.T) {
 	assertResponses(wantedSuccess, wantedFailure, requests, lockerCh, respCh, t)
 }
 
+func TestActivationHandlerThrottlerTimeout(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0}
+	reporter := &fakeReporter{}
+
+	// Without endpoints, the revision never gets capacity.
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(revision(testNamespace, testRevName)),
+		TestLogger(t))
+
+	fakeRT := &activatortest.FakeRoundTripper{}
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              reporter,
+		throttler:             throttler,
+		upgrades:              pkghttp.NewUpgradeTracker(),
+		revisionLister:        revisionLister(revision(testNamespace, testRevName)),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+		endpointTimeout:       10 * time.Millisecond,
+	}
+
+	writer := sendRequest(testNamespace, testRevName, handler)
+	if got, want := writer.Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := strings.TrimSpace(writer.Body.String()), activator.ErrActivatorTimeout.Error(); got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	if len(reporter.calls) != 1 || reporter.calls[0].StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Reporter calls = %v, want a single 504 request count", reporter.calls)
+	}
+}
+
+type fakeJournalSink struct {
+	entries []activator.JournalEntry
+}
+
+func (s *fakeJournalSink) Record(entry activator.JournalEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestActivationHandlerJournal(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0}
+	rev := revision(testNamespace, testRevName)
+	rev.Annotations = map[string]string{serving.RequestJournalAnnotationKey: serving.RequestJournalBody}
+
+	// Without endpoints, the revision never gets capacity.
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(rev),
+		TestLogger(t))
+
+	sink := &fakeJournalSink{}
+	fakeRT := &activatortest.FakeRoundTripper{}
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              &fakeReporter{},
+		throttler:             throttler,
+		upgrades:              pkghttp.NewUpgradeTracker(),
+		journal:               &activator.Journal{Sink: sink, MaxBodyBytes: 4},
+		revisionLister:        revisionLister(rev),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+		endpointTimeout:       10 * time.Millisecond,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/orders", strings.NewReader("order-42"))
+	req.Header.Set(activator.RevisionHeaderNamespace, testNamespace)
+	req.Header.Set(activator.RevisionHeaderName, testRevName)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got, want := len(sink.entries), 1; got != want {
+		t.Fatalf("Journaled %d requests, want: %d", got, want)
+	}
+	entry := sink.entries[0]
+	if entry.Namespace != testNamespace || entry.Revision != testRevName {
+		t.Errorf("Journaled revision %s/%s, want: %s/%s", entry.Namespace, entry.Revision, testNamespace, testRevName)
+	}
+	if got, want := entry.URL, "http://example.com/orders"; got != want {
+		t.Errorf("URL = %q, want: %q", got, want)
+	}
+	if got, want := entry.Status, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	if got, want := entry.Error, activator.ErrActivatorTimeout.Error(); got != want {
+		t.Errorf("Error = %q, want: %q", got, want)
+	}
+	if got, want := string(entry.Body), "orde"; got != want || !entry.BodyTruncated {
+		t.Errorf("Body = %q, truncated %v, want: %q, true", got, entry.BodyTruncated, want)
+	}
+}
+
+func TestActivationHandlerRateLimit(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0}
+	rev := revision(testNamespace, testRevName)
+	rev.Annotations = map[string]string{serving.RateLimitAnnotationKey: "1"}
+
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(rev),
+		TestLogger(t))
+
+	fakeRT := &activatortest.FakeRoundTripper{}
+	reporter := &fakeReporter{}
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              reporter,
+		throttler:             throttler,
+		upgrades:              pkghttp.NewUpgradeTracker(),
+		limiter:               activator.NewRateLimiter(func() int { return 1 }),
+		revisionLister:        revisionLister(rev),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+		endpointTimeout:       10 * time.Millisecond,
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.Header.Set(activator.RevisionHeaderNamespace, testNamespace)
+		req.Header.Set(activator.RevisionHeaderName, testRevName)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The first request of the second is let through to wait for capacity.
+	if got, want := serve().Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	rec := serve()
+	if got, want := rec.Code, http.StatusTooManyRequests; got != want {
+		t.Errorf("Status over the rate limit = %d, want: %d", got, want)
+	}
+	if got, want := rec.Header().Get("Retry-After"), "1"; got != want {
+		t.Errorf("Retry-After = %q, want: %q", got, want)
+	}
+}
+
+func TestActivationHandlerNamespaceLimit(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0}
+	rev := revision(testNamespace, testRevName)
+
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(rev),
+		TestLogger(t))
+
+	namespaces := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0).Core().V1().Namespaces()
+	namespaces.Informer().GetIndexer().Add(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: testNamespace,
+			Annotations: map[string]string{
+				autoscaling.NamespaceMaxConcurrencyAnnotationKey: "1",
+			},
+		},
+	})
+	nsLimiter := activator.NewNamespaceLimiter(namespaces.Lister(), func() int { return 1 })
+
+	fakeRT := &activatortest.FakeRoundTripper{}
+	reporter := &fakeReporter{}
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              reporter,
+		throttler:             throttler,
+		upgrades:              pkghttp.NewUpgradeTracker(),
+		nsLimiter:             nsLimiter,
+		revisionLister:        revisionLister(rev),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+		endpointTimeout:       10 * time.Millisecond,
+	}
+
+	// Another request to the namespace is in flight.
+	release, ok := nsLimiter.Acquire(testNamespace)
+	if !ok {
+		t.Fatal("Acquire() = false, want: true")
+	}
+	rec := sendRequest(testNamespace, testRevName, handler)
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Status over the namespace limit = %d, want: %d", got, want)
+	}
+	if got, want := rec.Header().Get(activator.OverloadReasonHeader), OverloadReasonNamespaceLimit; got != want {
+		t.Errorf("%s = %q, want: %q", activator.OverloadReasonHeader, got, want)
+	}
+
+	// Once it's done, the request is let through to wait for capacity.
+	release()
+	if got, want := sendRequest(testNamespace, testRevName, handler).Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	// And it gave its slot back.
+	release, ok = nsLimiter.Acquire(testNamespace)
+	if !ok {
+		t.Error("Acquire() after the request = false, want: true")
+	} else {
+		release()
+	}
+}
+
+func TestActivationHandlerStaticAssets(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0}
+	rev := revision(testNamespace, testRevName)
+	rev.Annotations = map[string]string{serving.StaticAssetsAnnotationKey: "paths=/favicon.ico, url=https://assets.example.com"}
+
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(rev),
+		TestLogger(t))
+
+	fakeRT := &activatortest.FakeRoundTripper{}
+	assetsRT := network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("icon")),
+		}, nil
+	})
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              &fakeReporter{},
+		throttler:             throttler,
+		upgrades:              pkghttp.NewUpgradeTracker(),
+		assets:                activator.NewStaticAssets(nil, assetsRT),
+		revisionLister:        revisionLister(rev),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+		endpointTimeout:       10 * time.Millisecond,
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set(activator.RevisionHeaderNamespace, testNamespace)
+		req.Header.Set(activator.RevisionHeaderName, testRevName)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The asset is served while the revision has no capacity.
+	rec := serve("/favicon.ico")
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+	if got, want := rec.Body.String(), "icon"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	// Other paths wait for the revision.
+	if got, want := serve("/").Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("Status = %d, want: %d", got, want)
+	}
+}
+
 // Make sure if one breaker is overflowed, the requests to other revisions are still served
 func TestActivationHandlerOverflowSeveralRevisions(t *testing.T) {
 	const (
@@ -436,7 +716,7 @@ func TestActivationHandlerOverflowSeveralRevisions(t *testing.T) {
 	}
 	rt := network.RoundTripperFunc(fakeRT.RT)
 	handler := (New(TestLogger(t), reporter, throttler,
-		revClient, svcClient, sksClient)).(*activationHandler)
+		revClient, svcClient, sksClient, nil, nil, nil, nil, nil)).(*activationHandler)
 
 	// Setup transports.
 	handler.transport = rt
@@ -502,6 +782,249 @@ func TestActivationHandlerProxyHeader(t *testing.T) {
 	}
 }
 
+func TestActivationHandlerForwardedClientCert(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10}
+	namespace, revName := testNamespace, testRevName
+	const xfcc = `Subject="CN=client";URI=spiffe://client`
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{{
+		name:       "trusted peer",
+		remoteAddr: "10.4.0.2:43210",
+		want:       xfcc,
+	}, {
+		name:       "untrusted peer",
+		remoteAddr: "10.8.0.2:43210",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interceptCh := make(chan *http.Request, 1)
+			rt := network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				interceptCh <- r
+				fake := httptest.NewRecorder()
+				return fake.Result(), nil
+			})
+			throttler := activator.NewThrottler(
+				breakerParams,
+				endpointsInformer(endpoints(namespace, revName, breakerParams.InitialCapacity)),
+				sksLister(sks(namespace, revName)),
+				revisionLister(revision(namespace, revName)),
+				TestLogger(t))
+
+			fakeRT := activatortest.FakeRoundTripper{
+				RequestResponse: &activatortest.FakeResponse{
+					Code: http.StatusOK,
+					Body: wantBody,
+				},
+			}
+
+			handler := activationHandler{
+				transport:             rt,
+				probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+				logger:                TestLogger(t),
+				reporter:              &fakeReporter{},
+				throttler:             throttler,
+				upgrades:              pkghttp.NewUpgradeTracker(),
+				revisionLister:        revisionLister(revision(testNamespace, testRevName)),
+				serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+				sksLister:             sksLister(sks(testNamespace, testRevName)),
+			}
+
+			store := activatorconfig.NewStore(TestLogger(t))
+			store.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: tracingconfig.ConfigName},
+			})
+			store.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: network.ConfigName},
+				Data: map[string]string{
+					network.ClientCertTrustedProxiesKey: "10.4.0.0/16",
+				},
+			})
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set(activator.RevisionHeaderNamespace, namespace)
+			req.Header.Set(activator.RevisionHeaderName, revName)
+			req.Header.Set(network.ForwardedClientCertHeaderName, xfcc)
+			handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(store.ToContext(req.Context())))
+
+			select {
+			case httpReq := <-interceptCh:
+				if got := httpReq.Header.Get(network.ForwardedClientCertHeaderName); got != test.want {
+					t.Errorf("%s = %q, want: %q", network.ForwardedClientCertHeaderName, got, test.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for a request to be intercepted")
+			}
+		})
+	}
+}
+
+func TestTrustsForwardedClientCert(t *testing.T) {
+	defer ClearAll()
+	handler := &activationHandler{}
+	store := activatorconfig.NewStore(TestLogger(t))
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: tracingconfig.ConfigName},
+	})
+	trusts := func(proxies string) bool {
+		store.OnConfigChanged(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: network.ConfigName},
+			Data: map[string]string{
+				network.ClientCertTrustedProxiesKey: proxies,
+			},
+		})
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.RemoteAddr = "10.4.0.1:43210"
+		return handler.trustsForwardedClientCert(req.WithContext(store.ToContext(req.Context())))
+	}
+
+	if !trusts("10.4.0.0/16") {
+		t.Error("Peer in the trusted CIDRs isn't trusted")
+	}
+	if !trusts("10.4.0.0/16") {
+		t.Error("Peer in the cached trusted CIDRs isn't trusted")
+	}
+	// The cached CIDRs are replaced once the config changes.
+	if trusts("10.8.0.0/16") {
+		t.Error("Peer is still trusted after the trusted CIDRs changed")
+	}
+}
+
+func TestActivationHandlerUpgradePolicy(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10}
+	namespace, revName := testNamespace, testRevName
+	rev := revision(namespace, revName)
+	rev.Annotations = map[string]string{
+		serving.AllowedUpgradeProtocolsAnnotationKey: "websocket",
+		serving.MaxUpgradedConnectionsAnnotationKey:  "1",
+	}
+
+	throttler := activator.NewThrottler(
+		breakerParams,
+		endpointsInformer(endpoints(namespace, revName, breakerParams.InitialCapacity)),
+		sksLister(sks(namespace, revName)),
+		revisionLister(rev),
+		TestLogger(t))
+
+	fakeRT := &activatortest.FakeRoundTripper{}
+	upgrades := pkghttp.NewUpgradeTracker()
+	reporter := &fakeReporter{}
+	handler := activationHandler{
+		transport:             network.RoundTripperFunc(fakeRT.RT),
+		probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+		logger:                TestLogger(t),
+		reporter:              reporter,
+		throttler:             throttler,
+		upgrades:              upgrades,
+		revisionLister:        revisionLister(rev),
+		serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+		sksLister:             sksLister(sks(testNamespace, testRevName)),
+	}
+
+	upgradeRequest := func(proto string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.Header.Set(activator.RevisionHeaderNamespace, namespace)
+		req.Header.Set(activator.RevisionHeaderName, revName)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", proto)
+		return req
+	}
+
+	writer := httptest.NewRecorder()
+	handler.ServeHTTP(writer, upgradeRequest("h2c"))
+	if got, want := writer.Code, http.StatusForbidden; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+
+	// Occupy the only upgraded connection slot of the revision.
+	revID := activator.RevisionID{Namespace: namespace, Name: revName}
+	release, err := upgrades.Admit(revID.String(), pkghttp.UpgradePolicyFromAnnotations(rev.Annotations), "websocket")
+	if err != nil {
+		t.Fatalf("Admit() = %v", err)
+	}
+	defer release()
+
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, upgradeRequest("websocket"))
+	if got, want := writer.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+
+	if len(reporter.calls) != 0 {
+		t.Errorf("Rejected upgrades were reported: %v", reporter.calls)
+	}
+}
+
+func TestActivationHandlerHibernated(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		wantBody string
+		wantType string
+	}{{
+		name:     "default page",
+		wantBody: "Revision is hibernated\n",
+		wantType: "text/plain; charset=utf-8",
+	}, {
+		name:     "custom page",
+		page:     "<html><body>Back soon</body></html>",
+		wantBody: "<html><body>Back soon</body></html>",
+		wantType: "text/html; charset=utf-8",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10}
+			rev := revision(testNamespace, testRevName)
+			rev.Annotations = map[string]string{
+				autoscaling.HibernatedAnnotationKey: "true",
+			}
+			if test.page != "" {
+				rev.Annotations[autoscaling.HibernationPageAnnotationKey] = test.page
+			}
+
+			throttler := activator.NewThrottler(
+				breakerParams,
+				endpointsInformer(endpoints(testNamespace, testRevName, breakerParams.InitialCapacity)),
+				sksLister(sks(testNamespace, testRevName)),
+				revisionLister(rev),
+				TestLogger(t))
+
+			fakeRT := &activatortest.FakeRoundTripper{}
+			reporter := &fakeReporter{}
+			handler := activationHandler{
+				transport:             network.RoundTripperFunc(fakeRT.RT),
+				probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+				logger:                TestLogger(t),
+				reporter:              reporter,
+				throttler:             throttler,
+				upgrades:              pkghttp.NewUpgradeTracker(),
+				revisionLister:        revisionLister(rev),
+				serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+				sksLister:             sksLister(sks(testNamespace, testRevName)),
+			}
+
+			writer := sendRequest(testNamespace, testRevName, handler)
+			if got, want := writer.Code, http.StatusServiceUnavailable; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got := writer.Body.String(); got != test.wantBody {
+				t.Errorf("Body = %q, want: %q", got, test.wantBody)
+			}
+			if got := writer.Header().Get("Content-Type"); got != test.wantType {
+				t.Errorf("Content-Type = %q, want: %q", got, test.wantType)
+			}
+			if len(reporter.calls) != 1 || reporter.calls[0].StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("Reporter calls = %v, want a single 503 request count", reporter.calls)
+			}
+		})
+	}
+}
+
 func TestActivationHandlerTraceSpans(t *testing.T) {
 	// Setup transport
 	fakeRt := activatortest.FakeRoundTripper{
@@ -568,6 +1091,263 @@ func TestActivationHandlerTraceSpans(t *testing.T) {
 	}
 }
 
+func TestActivationHandlerTimeouts(t *testing.T) {
+	revID := activator.RevisionID{Namespace: testNamespace, Name: testRevName}
+	throttler := activator.NewThrottler(
+		queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0},
+		endpointsInformer(endpoints(testNamespace, testRevName, 0)),
+		sksLister(sks(testNamespace, testRevName)),
+		revisionLister(revision(testNamespace, testRevName)),
+		TestLogger(t))
+	handler := activationHandler{
+		throttler:       throttler,
+		endpointTimeout: time.Second,
+		probeTimeout:    2 * time.Second,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if endpoint, probe := handler.timeouts(req, revID); endpoint != time.Second || probe != 2*time.Second {
+		t.Errorf("timeouts without config = (%v, %v), want: (1s, 2s)", endpoint, probe)
+	}
+
+	store := activatorconfig.NewStore(TestLogger(t))
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: tracingconfig.ConfigName},
+	})
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: network.ConfigName},
+		Data: map[string]string{
+			network.ActivatorColdStartTimeoutKey: "5m",
+			network.ActivatorTimeoutKey:          "10s",
+			network.ActivatorMethodTimeoutsKey:   "GET=3s",
+		},
+	})
+	ctx := store.ToContext(req.Context())
+	post := httptest.NewRequest(http.MethodPost, "http://example.com", nil).WithContext(ctx)
+	get := req.WithContext(ctx)
+
+	// No capacity, the revision is scaling from zero.
+	for _, r := range []*http.Request{get, post} {
+		if endpoint, _ := handler.timeouts(r, revID); endpoint != 5*time.Minute {
+			t.Errorf("%s cold start timeout = %v, want: 5m", r.Method, endpoint)
+		}
+	}
+
+	if err := throttler.UpdateCapacity(revID, 1); err != nil {
+		t.Fatalf("UpdateCapacity() = %v", err)
+	}
+	if endpoint, probe := handler.timeouts(get, revID); endpoint != 3*time.Second || probe != 3*time.Second {
+		t.Errorf("GET timeouts = (%v, %v), want: (3s, 3s)", endpoint, probe)
+	}
+	if endpoint, _ := handler.timeouts(post, revID); endpoint != 10*time.Second {
+		t.Errorf("POST timeout = %v, want: 10s", endpoint)
+	}
+
+	// The requests don't wait for longer than their deadline.
+	dlCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if endpoint, probe := handler.timeouts(post.WithContext(dlCtx), revID); endpoint > time.Second || probe > time.Second {
+		t.Errorf("POST timeouts with a deadline = (%v, %v), want: at most 1s", endpoint, probe)
+	}
+}
+
+func TestActivationHandlerRequestTimeout(t *testing.T) {
+	breakerParams := queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10}
+	namespace, revName := testNamespace, testRevName
+	rev := revision(namespace, revName)
+	rev.Spec.TimeoutSeconds = ptr.Int64(10)
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		wantStatus  int
+		wantForward bool
+		wantTimeout time.Duration
+	}{{
+		name:        "no hint",
+		wantStatus:  http.StatusOK,
+		wantForward: true,
+	}, {
+		name:        "clamped to the revision timeout",
+		header:      http.Header{network.GRPCTimeoutHeaderName: {"1H"}},
+		wantStatus:  http.StatusOK,
+		wantForward: true,
+		wantTimeout: 10 * time.Second,
+	}, {
+		name:        "decremented",
+		header:      http.Header{network.RequestTimeoutHeaderName: {"5s"}},
+		wantStatus:  http.StatusOK,
+		wantForward: true,
+		wantTimeout: 5 * time.Second,
+	}, {
+		name:       "expired",
+		header:     http.Header{network.RequestTimeoutHeaderName: {"0s"}},
+		wantStatus: http.StatusGatewayTimeout,
+	}, {
+		name:        "malformed",
+		header:      http.Header{network.GRPCTimeoutHeaderName: {"5s"}},
+		wantStatus:  http.StatusOK,
+		wantForward: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interceptCh := make(chan *http.Request, 1)
+			rt := network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				interceptCh <- r
+				fake := httptest.NewRecorder()
+				return fake.Result(), nil
+			})
+			throttler := activator.NewThrottler(
+				breakerParams,
+				endpointsInformer(endpoints(namespace, revName, breakerParams.InitialCapacity)),
+				sksLister(sks(namespace, revName)),
+				revisionLister(rev),
+				TestLogger(t))
+
+			fakeRT := activatortest.FakeRoundTripper{
+				RequestResponse: &activatortest.FakeResponse{
+					Err:  nil,
+					Code: http.StatusOK,
+					Body: wantBody,
+				},
+			}
+			handler := activationHandler{
+				transport:             rt,
+				probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+				logger:                TestLogger(t),
+				reporter:              &fakeReporter{},
+				throttler:             throttler,
+				revisionLister:        revisionLister(rev),
+				serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+				sksLister:             sksLister(sks(testNamespace, testRevName)),
+				endpointTimeout:       defaulTimeout,
+				probeTimeout:          defaulTimeout,
+			}
+
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+			req.Header.Set(activator.RevisionHeaderNamespace, namespace)
+			req.Header.Set(activator.RevisionHeaderName, revName)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			handler.ServeHTTP(writer, req)
+
+			if got, want := writer.Code, test.wantStatus; got != want {
+				t.Errorf("Status = %d, want %d", got, want)
+			}
+			select {
+			case httpReq := <-interceptCh:
+				if !test.wantForward {
+					t.Fatal("The request was forwarded")
+				}
+				got, ok, err := pkghttp.RequestTimeout(httpReq.Header)
+				if err != nil {
+					t.Fatalf("RequestTimeout() = %v", err)
+				}
+				if ok != (test.wantTimeout > 0) || got > test.wantTimeout || got < test.wantTimeout-time.Second {
+					t.Errorf("Forwarded timeout = %v, want a bit less than %v", got, test.wantTimeout)
+				}
+			default:
+				if test.wantForward {
+					t.Fatal("The request wasn't forwarded")
+				}
+			}
+		})
+	}
+}
+
+func TestActivationHandlerCapacityPending(t *testing.T) {
+	tests := []struct {
+		name       string
+		shed       string
+		pending    bool
+		capacity   int
+		wantCode   int
+		wantReason string
+	}{{
+		name:       "pending without capacity",
+		shed:       "Enabled",
+		pending:    true,
+		wantCode:   http.StatusServiceUnavailable,
+		wantReason: OverloadReasonCapacityPending,
+	}, {
+		name:     "pending with capacity",
+		shed:     "Enabled",
+		pending:  true,
+		capacity: 1,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "shedding disabled",
+		shed:     "Disabled",
+		pending:  true,
+		wantCode: http.StatusGatewayTimeout,
+	}, {
+		name:     "not pending",
+		shed:     "Enabled",
+		wantCode: http.StatusGatewayTimeout,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revID := activator.RevisionID{Namespace: testNamespace, Name: testRevName}
+			pendingSKS := sks(testNamespace, testRevName)
+			pendingSKS.Spec.CapacityPending = test.pending
+			throttler := activator.NewThrottler(
+				queue.BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 0},
+				endpointsInformer(endpoints(testNamespace, testRevName, test.capacity)),
+				sksLister(pendingSKS),
+				revisionLister(revision(testNamespace, testRevName)),
+				TestLogger(t))
+			if test.capacity > 0 {
+				if err := throttler.UpdateCapacity(revID, test.capacity); err != nil {
+					t.Fatalf("UpdateCapacity() = %v", err)
+				}
+			}
+
+			fakeRT := &activatortest.FakeRoundTripper{}
+			reporter := &fakeReporter{}
+			handler := activationHandler{
+				transport:             network.RoundTripperFunc(fakeRT.RT),
+				probeTransportFactory: rtFact(network.RoundTripperFunc(fakeRT.RT)),
+				logger:                TestLogger(t),
+				reporter:              reporter,
+				throttler:             throttler,
+				upgrades:              pkghttp.NewUpgradeTracker(),
+				revisionLister:        revisionLister(revision(testNamespace, testRevName)),
+				serviceLister:         serviceLister(service(testNamespace, testRevName, "http")),
+				sksLister:             sksLister(pendingSKS),
+			}
+
+			store := activatorconfig.NewStore(TestLogger(t))
+			store.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: tracingconfig.ConfigName},
+			})
+			store.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: network.ConfigName},
+				Data: map[string]string{
+					network.ActivatorShedCapacityPendingKey: test.shed,
+					// Don't wait long for the capacity that never comes.
+					network.ActivatorColdStartTimeoutKey: "50ms",
+				},
+			})
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+			req.Header.Set(activator.RevisionHeaderNamespace, testNamespace)
+			req.Header.Set(activator.RevisionHeaderName, testRevName)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req.WithContext(store.ToContext(req.Context())))
+
+			if got, want := resp.Code, test.wantCode; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got, want := resp.Header().Get(activator.OverloadReasonHeader), test.wantReason; got != want {
+				t.Errorf("%s = %q, want: %q", activator.OverloadReasonHeader, got, want)
+			}
+		})
+	}
+}
+
 func sendRequest(namespace, revName string, handler activationHandler) *httptest.ResponseRecorder {
 	resp := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
@@ -619,6 +1399,12 @@ func assertResponses(wantedSuccess, wantedFailure, overallRequests int, lockerCh
 				if gotBody != activator.ErrActivatorOverload.Error() {
 					t.Errorf("error message = %q, want: %q", gotBody, activator.ErrActivatorOverload.Error())
 				}
+				if got, want := resp.Header().Get(activator.OverloadReasonHeader), OverloadReasonRevisionBacklog; got != want {
+					t.Errorf("%s = %q, want: %q", activator.OverloadReasonHeader, got, want)
+				}
+				if got, want := resp.Header().Get(network.OverloadedByHeaderName), activator.Name; got != want {
+					t.Errorf("%s = %q, want: %q", network.OverloadedByHeaderName, got, want)
+				}
 			default:
 				t.Errorf("http response code = %d, want: %d or %d", resp.Code, successCode, failureCode)
 			}
@@ -703,6 +1489,21 @@ func (f *fakeReporter) ReportResponseTime(ns, service, config, rev string, respo
 	return nil
 }
 
+func (f *fakeReporter) ReportUpgradedConnectionCount(ns, service, config, rev, protocol string, v int64) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.calls = append(f.calls, reporterCall{
+		Op:        "ReportUpgradedConnectionCount",
+		Namespace: ns,
+		Service:   service,
+		Config:    config,
+		Revision:  rev,
+		Value:     v,
+	})
+
+	return nil
+}
+
 func revision(namespace, name string) *v1alpha1.Revision {
 	return &v1alpha1.Revision{
 		ObjectMeta: metav1.ObjectMeta{
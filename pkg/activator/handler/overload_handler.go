@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/knative/serving/pkg/activator"
+	"github.com/knative/serving/pkg/gctuning"
+	"github.com/knative/serving/pkg/network"
+)
+
+// The reasons the activator sheds requests, sent back in the
+// activator.OverloadReasonHeader of the 503 response.
+const (
+	// OverloadReasonRequests means the activator holds as many requests as
+	// its budget allows.
+	OverloadReasonRequests = "requests"
+	// OverloadReasonMemory means the heap of the activator grew past its budget.
+	OverloadReasonMemory = "memory"
+	// OverloadReasonGoroutines means the activator runs more goroutines than
+	// its budget allows.
+	OverloadReasonGoroutines = "goroutines"
+	// OverloadReasonRevisionBacklog means the revision has more requests
+	// waiting for capacity than its breaker can queue.
+	OverloadReasonRevisionBacklog = "revision-backlog"
+	// OverloadReasonCapacityPending means the revision is scaling from zero
+	// but its pods can't be scheduled, so the request would only time out.
+	OverloadReasonCapacityPending = "capacity-pending"
+	// OverloadReasonNamespaceLimit means the namespace of the revision has
+	// as many requests in flight as its namespaceMaxConcurrency allows.
+	OverloadReasonNamespaceLimit = "namespace-limit"
+)
+
+// OverloadBudget bounds the resources the activator spends on the requests
+// it buffers. Zero values are unbounded.
+type OverloadBudget struct {
+	// MaxRequests is the number of requests the activator holds at once,
+	// across all revisions.
+	MaxRequests int64
+	// MaxHeapBytes is the size the heap of the activator may grow to.
+	MaxHeapBytes uint64
+	// MaxGoroutines is the number of goroutines the activator may run.
+	MaxGoroutines int64
+}
+
+// usage is a sample of the resources used by the activator.
+type usage struct {
+	heapBytes  uint64
+	goroutines int64
+}
+
+func readUsage() usage {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	// The ballast is never touched, so it doesn't use up the memory.
+	heapBytes := ms.HeapAlloc
+	if ballast := gctuning.BallastBytes(); heapBytes > ballast {
+		heapBytes -= ballast
+	}
+	return usage{
+		heapBytes:  heapBytes,
+		goroutines: int64(runtime.NumGoroutine()),
+	}
+}
+
+// OverloadHandler protects the activator from running out of memory when
+// requests pile up for revisions that can't scale, by failing fast with a
+// 503 before its budget is exhausted.
+type OverloadHandler struct {
+	budget OverloadBudget
+	// Ticks with every sample of the resources in use
+	sampleChan  <-chan time.Time
+	nextHandler http.Handler
+
+	// Reading the heap stops the world, so the usage is sampled on ticks
+	// rather than for every request.
+	readUsage  func() usage
+	heapBytes  uint64
+	goroutines int64
+	inFlight   int64
+}
+
+// NewOverloadHandler creates an OverloadHandler which samples the usage of
+// the activator against budget on ticks of sampleChan.
+func NewOverloadHandler(budget OverloadBudget, sampleChan <-chan time.Time, next http.Handler) *OverloadHandler {
+	h := &OverloadHandler{
+		budget:      budget,
+		sampleChan:  sampleChan,
+		nextHandler: next,
+		readUsage:   readUsage,
+	}
+	h.sample()
+	return h
+}
+
+// Run samples the usage of the activator until stopCh is closed.
+func (h *OverloadHandler) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-h.sampleChan:
+			h.sample()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (h *OverloadHandler) sample() {
+	u := h.readUsage()
+	atomic.StoreUint64(&h.heapBytes, u.heapBytes)
+	atomic.StoreInt64(&h.goroutines, u.goroutines)
+}
+
+// overloaded returns the reason the activator can't take another request,
+// or an empty string if it can.
+func (h *OverloadHandler) overloaded() string {
+	switch {
+	case h.budget.MaxRequests > 0 && atomic.LoadInt64(&h.inFlight) >= h.budget.MaxRequests:
+		return OverloadReasonRequests
+	case h.budget.MaxHeapBytes > 0 && atomic.LoadUint64(&h.heapBytes) >= h.budget.MaxHeapBytes:
+		return OverloadReasonMemory
+	case h.budget.MaxGoroutines > 0 && atomic.LoadInt64(&h.goroutines) >= h.budget.MaxGoroutines:
+		return OverloadReasonGoroutines
+	}
+	return ""
+}
+
+func (h *OverloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if reason := h.overloaded(); reason != "" {
+		sendOverloaded(w, reason)
+		return
+	}
+
+	atomic.AddInt64(&h.inFlight, 1)
+	defer atomic.AddInt64(&h.inFlight, -1)
+	h.nextHandler.ServeHTTP(w, r)
+}
+
+// sendOverloaded fails the request with a 503, telling the client why the
+// activator rejected it.
+func sendOverloaded(w http.ResponseWriter, reason string) {
+	w.Header().Set(activator.OverloadReasonHeader, reason)
+	w.Header().Set(network.OverloadedByHeaderName, activator.Name)
+	http.Error(w, activator.ErrActivatorOverload.Error(), http.StatusServiceUnavailable)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/activator"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestOverloadHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		budget     OverloadBudget
+		usage      usage
+		inFlight   int64
+		wantReason string
+	}{{
+		name:  "unbounded",
+		usage: usage{heapBytes: 1 << 30, goroutines: 1 << 20},
+	}, {
+		name:     "within budget",
+		budget:   OverloadBudget{MaxRequests: 10, MaxHeapBytes: 1 << 20, MaxGoroutines: 100},
+		usage:    usage{heapBytes: 1 << 19, goroutines: 50},
+		inFlight: 9,
+	}, {
+		name:       "too many requests",
+		budget:     OverloadBudget{MaxRequests: 10, MaxHeapBytes: 1 << 20, MaxGoroutines: 100},
+		usage:      usage{heapBytes: 1 << 19, goroutines: 50},
+		inFlight:   10,
+		wantReason: OverloadReasonRequests,
+	}, {
+		name:       "heap exhausted",
+		budget:     OverloadBudget{MaxRequests: 10, MaxHeapBytes: 1 << 20, MaxGoroutines: 100},
+		usage:      usage{heapBytes: 1 << 20, goroutines: 50},
+		wantReason: OverloadReasonMemory,
+	}, {
+		name:       "too many goroutines",
+		budget:     OverloadBudget{MaxRequests: 10, MaxHeapBytes: 1 << 20, MaxGoroutines: 100},
+		usage:      usage{heapBytes: 1 << 19, goroutines: 100},
+		wantReason: OverloadReasonGoroutines,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var called bool
+			h := NewOverloadHandler(test.budget, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			h.readUsage = func() usage { return test.usage }
+			h.sample()
+			h.inFlight = test.inFlight
+
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+			if got := resp.Header().Get(activator.OverloadReasonHeader); got != test.wantReason {
+				t.Errorf("%s = %q, want: %q", activator.OverloadReasonHeader, got, test.wantReason)
+			}
+			if test.wantReason == "" {
+				if !called {
+					t.Error("Request was not passed to the next handler")
+				}
+				return
+			}
+			if called {
+				t.Error("Request was passed to the next handler")
+			}
+			if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got, want := resp.Header().Get(network.OverloadedByHeaderName), activator.Name; got != want {
+				t.Errorf("%s = %q, want: %q", network.OverloadedByHeaderName, got, want)
+			}
+		})
+	}
+}
+
+func TestOverloadHandlerTracksInFlight(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	h := NewOverloadHandler(OverloadBudget{MaxRequests: 1}, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+		return resp
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve()
+	}()
+	<-entered
+
+	if got, want := serve().Header().Get(activator.OverloadReasonHeader), OverloadReasonRequests; got != want {
+		t.Errorf("%s = %q, want: %q", activator.OverloadReasonHeader, got, want)
+	}
+
+	// Once the first request finished, requests are admitted again.
+	close(release)
+	<-done
+	if got := serve().Header().Get(activator.OverloadReasonHeader); got != "" {
+		t.Errorf("%s = %q, want none", activator.OverloadReasonHeader, got)
+	}
+}
+
+func TestOverloadHandlerSamples(t *testing.T) {
+	sampleCh := make(chan time.Time)
+	stopCh := make(chan struct{})
+	h := NewOverloadHandler(OverloadBudget{MaxGoroutines: 100}, sampleCh, http.NotFoundHandler())
+	samples := make(chan usage)
+	h.readUsage = func() usage { return <-samples }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(stopCh)
+	}()
+
+	// Run only receives the next tick once it stored the previous sample.
+	sampleCh <- time.Now()
+	samples <- usage{goroutines: 100}
+	sampleCh <- time.Now()
+	if got, want := h.overloaded(), OverloadReasonGoroutines; got != want {
+		t.Errorf("overloaded() = %q, want: %q", got, want)
+	}
+
+	samples <- usage{goroutines: 10}
+	sampleCh <- time.Now()
+	if got, want := h.overloaded(), ""; got != want {
+		t.Errorf("overloaded() = %q, want: %q", got, want)
+	}
+
+	samples <- usage{goroutines: 10}
+	close(stopCh)
+	<-done
+}
@@ -34,6 +34,7 @@ func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("unexpected probe header value: %q", val), http.StatusBadRequest)
 			return
 		}
+		w.Header().Set(network.ProtocolVersionHeaderName, network.NegotiateProtocolVersion(r.Header).String())
 		w.Write([]byte(activator.Name))
 		return
 	}
@@ -103,6 +103,22 @@ func TestProbeHandler(t *testing.T) {
 	}
 }
 
+func TestProbeHandlerProtocolVersion(t *testing.T) {
+	handler := ProbeHandler{NextHandler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("Probe got passed to the next handler")
+	})}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set(network.ProbeHeaderName, activator.Name)
+	req.Header.Set(network.ProtocolVersionHeaderName, network.CurrentProtocolVersion.String())
+	handler.ServeHTTP(resp, req)
+
+	if got, want := resp.Header().Get(network.ProtocolVersionHeaderName), network.CurrentProtocolVersion.String(); got != want {
+		t.Errorf("%s = %q, want: %q", network.ProtocolVersionHeaderName, got, want)
+	}
+}
+
 func mapToHeader(m map[string]string) http.Header {
 	h := http.Header{}
 	for k, v := range m {
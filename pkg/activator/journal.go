@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+)
+
+// redactedHeaders are left out of the journal, not to persist credentials.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// JournalEntry is a request the activator failed to deliver to its revision
+// while it was scaling from zero.
+type JournalEntry struct {
+	Time      time.Time   `json:"time"`
+	Namespace string      `json:"namespace"`
+	Revision  string      `json:"revision"`
+	Method    string      `json:"method"`
+	Host      string      `json:"host"`
+	URL       string      `json:"url"`
+	Header    http.Header `json:"header"`
+	// Body is only set if the revision journals bodies, and at most
+	// Journal.MaxBodyBytes long. BodyTruncated tells whether it was cut.
+	Body          []byte `json:"body,omitempty"`
+	BodyTruncated bool   `json:"bodyTruncated,omitempty"`
+	// Status is the status code of the response of the activator, and
+	// Error why it failed the request.
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// JournalSink stores the entries of a Journal, e.g. to replay them later.
+type JournalSink interface {
+	Record(JournalEntry) error
+}
+
+// Journal records the requests the activator failed for platform reasons,
+// e.g. timing out waiting for capacity, while their revision was scaling
+// from zero, for the revisions opting in with the
+// serving.RequestJournalAnnotationKey annotation.
+type Journal struct {
+	Sink JournalSink
+	// MaxBodyBytes caps the bodies of the entries.
+	MaxBodyBytes int64
+}
+
+// Record journals r, failed with status for err, if the annotations of
+// its revision ask for it. It reads the body of r if they ask for it too,
+// so r must not have been proxied. A nil Journal records nothing.
+func (j *Journal) Record(rev RevisionID, annotations map[string]string, r *http.Request, status int, err error) error {
+	mode := annotations[serving.RequestJournalAnnotationKey]
+	if j == nil || (mode != serving.RequestJournalMetadata && mode != serving.RequestJournalBody) {
+		return nil
+	}
+	entry := JournalEntry{
+		Time:      time.Now(),
+		Namespace: rev.Namespace,
+		Revision:  rev.Name,
+		Method:    r.Method,
+		Host:      r.Host,
+		URL:       r.URL.String(),
+		Header:    make(http.Header, len(r.Header)),
+		Status:    status,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	for k, v := range r.Header {
+		entry.Header[k] = v
+	}
+	for _, h := range redactedHeaders {
+		delete(entry.Header, h)
+	}
+	if mode == serving.RequestJournalBody && r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, j.MaxBodyBytes+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > j.MaxBodyBytes {
+			body, entry.BodyTruncated = body[:j.MaxBodyBytes], true
+		}
+		entry.Body = body
+	}
+	return j.Sink.Record(entry)
+}
+
+// NewWriterJournalSink returns a JournalSink writing the entries to w as
+// JSON, one per line.
+func NewWriterJournalSink(w io.Writer) JournalSink {
+	return &writerJournalSink{encoder: json.NewEncoder(w)}
+}
+
+type writerJournalSink struct {
+	mux     sync.Mutex
+	encoder *json.Encoder
+}
+
+func (s *writerJournalSink) Record(entry JournalEntry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.encoder.Encode(entry)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/knative/serving/pkg/apis/serving"
+)
+
+type fakeJournalSink struct {
+	entries []JournalEntry
+}
+
+func (s *fakeJournalSink) Record(entry JournalEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestJournalRecord(t *testing.T) {
+	rev := RevisionID{Namespace: "ns", Name: "rev"}
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name        string
+		mode        string
+		body        string
+		wantEntries int
+		wantBody    string
+		truncated   bool
+	}{{
+		name: "not opted in",
+		body: "hello",
+	}, {
+		name:        "metadata",
+		mode:        serving.RequestJournalMetadata,
+		body:        "hello",
+		wantEntries: 1,
+	}, {
+		name:        "body",
+		mode:        serving.RequestJournalBody,
+		body:        "hello",
+		wantEntries: 1,
+		wantBody:    "hello",
+	}, {
+		name:        "body over the cap",
+		mode:        serving.RequestJournalBody,
+		body:        "hello, world",
+		wantEntries: 1,
+		wantBody:    "hello, w",
+		truncated:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &fakeJournalSink{}
+			j := &Journal{Sink: sink, MaxBodyBytes: 8}
+			req := httptest.NewRequest(http.MethodPut, "http://example.com/items/1?v=2", strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("X-Request-Id", "42")
+			var annotations map[string]string
+			if test.mode != "" {
+				annotations = map[string]string{serving.RequestJournalAnnotationKey: test.mode}
+			}
+			if err := j.Record(rev, annotations, req, http.StatusServiceUnavailable, errBoom); err != nil {
+				t.Fatalf("Record() = %v", err)
+			}
+
+			if got := len(sink.entries); got != test.wantEntries {
+				t.Fatalf("Journaled %d entries, want: %d", got, test.wantEntries)
+			}
+			if test.wantEntries == 0 {
+				return
+			}
+			entry := sink.entries[0]
+			if entry.Namespace != "ns" || entry.Revision != "rev" || entry.Method != http.MethodPut ||
+				entry.URL != "http://example.com/items/1?v=2" || entry.Status != http.StatusServiceUnavailable || entry.Error != "boom" {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+			if got := entry.Header.Get("Authorization"); got != "" {
+				t.Errorf("Authorization = %q, want: redacted", got)
+			}
+			if got, want := entry.Header.Get("X-Request-Id"), "42"; got != want {
+				t.Errorf("X-Request-Id = %q, want: %q", got, want)
+			}
+			if string(entry.Body) != test.wantBody || entry.BodyTruncated != test.truncated {
+				t.Errorf("Body = %q, truncated %v, want: %q, %v", entry.Body, entry.BodyTruncated, test.wantBody, test.truncated)
+			}
+		})
+	}
+}
+
+func TestJournalRecordNil(t *testing.T) {
+	var j *Journal
+	annotations := map[string]string{serving.RequestJournalAnnotationKey: serving.RequestJournalBody}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err := j.Record(RevisionID{}, annotations, req, http.StatusGatewayTimeout, nil); err != nil {
+		t.Errorf("Record() = %v, want: nil", err)
+	}
+}
+
+func TestWriterJournalSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterJournalSink(&buf)
+	for _, name := range []string{"a", "b"} {
+		if err := sink.Record(JournalEntry{Revision: name, Body: []byte("{}")}); err != nil {
+			t.Fatalf("Record() = %v", err)
+		}
+	}
+
+	// One entry per line, with the body intact.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("Wrote %d lines, want: %d", got, want)
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if entry.Revision != "b" || string(entry.Body) != "{}" {
+		t.Errorf("Entry = %+v, want revision b with body {}", entry)
+	}
+}
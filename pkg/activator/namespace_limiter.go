@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"sync"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// NamespaceLimiter enforces the namespaceMaxConcurrency of the Namespaces
+// across all of the activators. Like the RateLimiter, each activator admits
+// its share of the requests in flight to the revisions of a Namespace, the
+// limit divided by the number of activators, rounded up.
+type NamespaceLimiter struct {
+	namespaceLister corev1listers.NamespaceLister
+	activators      func() int
+
+	mux      sync.Mutex
+	inFlight map[string]int
+}
+
+// NewNamespaceLimiter creates a NamespaceLimiter reading the limits of the
+// Namespaces from namespaceLister and sharing them among the number of
+// activators returned by activators, e.g. Throttler.ActivatorCount.
+func NewNamespaceLimiter(namespaceLister corev1listers.NamespaceLister, activators func() int) *NamespaceLimiter {
+	return &NamespaceLimiter{
+		namespaceLister: namespaceLister,
+		activators:      activators,
+		inFlight:        make(map[string]int),
+	}
+}
+
+// Acquire admits a request to a revision of the namespace, unless the
+// namespace has as many requests in flight through this activator as its
+// share of the limit allows. The returned func must be called once the
+// admitted request is done.
+func (l *NamespaceLimiter) Acquire(namespace string) (func(), bool) {
+	limit := l.limit(namespace)
+	if limit == 0 {
+		return func() {}, true
+	}
+	activators := minOneOrValue(l.activators())
+	share := (limit + activators - 1) / activators
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.inFlight[namespace] >= share {
+		return nil, false
+	}
+	l.inFlight[namespace]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(namespace)
+		})
+	}, true
+}
+
+func (l *NamespaceLimiter) release(namespace string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.inFlight[namespace]--; l.inFlight[namespace] <= 0 {
+		delete(l.inFlight, namespace)
+	}
+}
+
+// limit returns the namespaceMaxConcurrency of the namespace, or 0 if it
+// has none.
+func (l *NamespaceLimiter) limit(namespace string) int {
+	ns, err := l.namespaceLister.Get(namespace)
+	if err != nil {
+		return 0
+	}
+	return autoscaling.NamespaceLimit(ns.Annotations, autoscaling.NamespaceMaxConcurrencyAnnotationKey)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"testing"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceLimiter(t *testing.T) {
+	informer := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+	namespaces := informer.Core().V1().Namespaces()
+	namespaces.Informer().GetIndexer().Add(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "limited",
+			Annotations: map[string]string{
+				autoscaling.NamespaceMaxConcurrencyAnnotationKey: "3",
+			},
+		},
+	})
+	namespaces.Informer().GetIndexer().Add(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlimited"},
+	})
+
+	activators := 1
+	l := NewNamespaceLimiter(namespaces.Lister(), func() int { return activators })
+
+	acquire := func(namespace string, n int) ([]func(), int) {
+		var releases []func()
+		for i := 0; i < n; i++ {
+			if release, ok := l.Acquire(namespace); ok {
+				releases = append(releases, release)
+			}
+		}
+		return releases, len(releases)
+	}
+	releaseAll := func(releases []func()) {
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	for _, namespace := range []string{"unlimited", "missing"} {
+		releases, got := acquire(namespace, 10)
+		if got != 10 {
+			t.Errorf("Acquired %d requests in %s, want: 10", got, namespace)
+		}
+		releaseAll(releases)
+	}
+
+	releases, got := acquire("limited", 10)
+	if got != 3 {
+		t.Errorf("Acquired %d requests, want: 3", got)
+	}
+	// Releasing twice doesn't free up more room.
+	releases[0]()
+	releases[0]()
+	more, got := acquire("limited", 10)
+	if got != 1 {
+		t.Errorf("Acquired %d requests after a release, want: 1", got)
+	}
+	releaseAll(releases)
+	releaseAll(more)
+
+	// Each of 2 activators admits half of the limit, rounded up.
+	activators = 2
+	releases, got = acquire("limited", 10)
+	if got != 2 {
+		t.Errorf("Acquired %d requests as one of 2 activators, want: 2", got)
+	}
+	releaseAll(releases)
+	activators = 8
+	releases, got = acquire("limited", 10)
+	if got != 1 {
+		t.Errorf("Acquired %d requests as one of 8 activators, want: 1", got)
+	}
+	releaseAll(releases)
+
+	if len(l.inFlight) != 0 {
+		t.Errorf("inFlight = %v, want empty", l.inFlight)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdleTimeout is how long the limiter of a revision that
+// receives no requests is kept.
+const rateLimiterIdleTimeout = 10 * time.Minute
+
+// RateLimiter enforces the rate limits of the revisions across all of the
+// activators. Each activator lets through its share of the requests per
+// second of a revision, the limit divided by the number of activators, as
+// the requests are spread evenly over them. The shares are recomputed as
+// activators come and go, so that the limit holds for the revision
+// whatever the scale of either.
+type RateLimiter struct {
+	activators func() int
+	now        func() time.Time
+
+	mux       sync.Mutex
+	limiters  map[RevisionID]*revisionLimiter
+	lastSweep time.Time
+}
+
+// revisionLimiter is the token bucket of the share of a revision.
+type revisionLimiter struct {
+	limiter    *rate.Limiter
+	limit      int
+	activators int
+	lastUsed   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter sharing the limits among the number
+// of activators returned by activators, e.g. Throttler.ActivatorCount.
+func NewRateLimiter(activators func() int) *RateLimiter {
+	return &RateLimiter{
+		activators: activators,
+		now:        time.Now,
+		limiters:   make(map[RevisionID]*revisionLimiter),
+	}
+}
+
+// Allow returns whether a request to the revision limited to limit requests
+// per second may be let through by this activator.
+func (r *RateLimiter) Allow(rev RevisionID, limit int) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	now := r.now()
+	if now.Sub(r.lastSweep) > rateLimiterIdleTimeout {
+		r.sweep(now)
+	}
+
+	activators := minOneOrValue(r.activators())
+	l, ok := r.limiters[rev]
+	if !ok || l.limit != limit || l.activators != activators {
+		// A share of less than a request per second still lets single
+		// requests through, at its rate.
+		share := float64(limit) / float64(activators)
+		l = &revisionLimiter{
+			limiter:    rate.NewLimiter(rate.Limit(share), int(math.Ceil(share))),
+			limit:      limit,
+			activators: activators,
+		}
+		r.limiters[rev] = l
+	}
+	l.lastUsed = now
+	return l.limiter.AllowN(now, 1)
+}
+
+// sweep removes the limiters of the revisions that received no requests
+// for a while. `mux` must be held to call it.
+func (r *RateLimiter) sweep(now time.Time) {
+	r.lastSweep = now
+	for rev, l := range r.limiters {
+		if now.Sub(l.lastUsed) > rateLimiterIdleTimeout {
+			delete(r.limiters, rev)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterShare(t *testing.T) {
+	now := time.Unix(1000, 0)
+	activators := 2
+	r := NewRateLimiter(func() int { return activators })
+	r.now = func() time.Time { return now }
+	rev := RevisionID{Namespace: "ns", Name: "rev"}
+
+	allowed := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if r.Allow(rev, 4) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Each of 2 activators lets through half of the limit.
+	if got, want := allowed(), 2; got != want {
+		t.Errorf("Allowed %d requests, want: %d", got, want)
+	}
+	now = now.Add(time.Second)
+	if got, want := allowed(), 2; got != want {
+		t.Errorf("Allowed %d requests a second later, want: %d", got, want)
+	}
+
+	// The share follows the number of activators.
+	activators = 1
+	if got, want := allowed(), 4; got != want {
+		t.Errorf("Allowed %d requests as the only activator, want: %d", got, want)
+	}
+	activators = 8
+	now = now.Add(time.Second)
+	if got, want := allowed(), 1; got != want {
+		t.Errorf("Allowed %d requests as one of 8 activators, want: %d", got, want)
+	}
+	// A share below one request per second lets one through every so often.
+	now = now.Add(time.Second)
+	if got, want := allowed(), 0; got != want {
+		t.Errorf("Allowed %d requests within 2s, want: %d", got, want)
+	}
+	now = now.Add(time.Second)
+	if got, want := allowed(), 1; got != want {
+		t.Errorf("Allowed %d requests after 2s, want: %d", got, want)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRateLimiter(func() int { return 0 })
+	r.now = func() time.Time { return now }
+
+	// No activators counted yet is like being the only one.
+	old := RevisionID{Namespace: "ns", Name: "old"}
+	if !r.Allow(old, 1) {
+		t.Error("Allow() = false, want: true")
+	}
+	now = now.Add(2 * rateLimiterIdleTimeout)
+	r.Allow(RevisionID{Namespace: "ns", Name: "new"}, 1)
+	if _, ok := r.limiters[old]; ok {
+		t.Error("The limiter of the idle revision wasn't removed")
+	}
+}
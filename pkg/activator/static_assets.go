@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	pkghttp "github.com/knative/serving/pkg/http"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// staticAssetRequestHeaders are the headers of the requests for static
+// assets passed on to the object stores. The others, like cookies, are
+// meant for the revision.
+var staticAssetRequestHeaders = []string{
+	"Accept-Encoding",
+	"If-Modified-Since",
+	"If-None-Match",
+	"Range",
+}
+
+// StaticAssets serves the static assets of the revisions, as declared by
+// their serving.StaticAssetsAnnotationKey annotation, from ConfigMaps of
+// their namespace or from object stores, without waking them.
+type StaticAssets struct {
+	configMaps corev1listers.ConfigMapLister
+	transport  http.RoundTripper
+}
+
+// NewStaticAssets creates a StaticAssets reading the ConfigMaps from cml and
+// fetching from the object stores through transport.
+func NewStaticAssets(cml corev1listers.ConfigMapLister, transport http.RoundTripper) *StaticAssets {
+	return &StaticAssets{
+		configMaps: cml,
+		transport:  transport,
+	}
+}
+
+// Serve answers r if it is a GET or HEAD request for a static asset of rev,
+// and returns the status of the response. It returns 0 without writing to w
+// otherwise.
+func (s *StaticAssets) Serve(w http.ResponseWriter, r *http.Request, rev *v1alpha1.Revision) int {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return 0
+	}
+	v, ok := rev.Annotations[serving.StaticAssetsAnnotationKey]
+	if !ok {
+		return 0
+	}
+	// The annotation was validated with the revision.
+	assets, err := serving.ParseStaticAssets(v)
+	if err != nil || !assets.Matches(r.URL.Path) {
+		return 0
+	}
+
+	recorder := pkghttp.NewResponseRecorder(w, http.StatusOK)
+	if assets.ConfigMap != "" {
+		s.serveConfigMap(recorder, r, rev.Namespace, assets.ConfigMap)
+	} else {
+		s.serveURL(recorder, r, assets)
+	}
+	return recorder.ResponseCode
+}
+
+// serveConfigMap serves the key of the ConfigMap named by the last element
+// of the request path.
+func (s *StaticAssets) serveConfigMap(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	cm, err := s.configMaps.ConfigMaps(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	key := path.Base(r.URL.Path)
+	data, ok := cm.BinaryData[key]
+	if !ok {
+		str, ok := cm.Data[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data = []byte(str)
+	}
+	// ServeContent sets the content type from the extension of the key,
+	// and handles conditional and range requests.
+	http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+}
+
+// serveURL fetches the asset from the object store under the request path.
+func (s *StaticAssets) serveURL(w http.ResponseWriter, r *http.Request, assets serving.StaticAssets) {
+	u := *assets.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	req, err := http.NewRequest(r.Method, u.String(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req = req.WithContext(r.Context())
+	for _, name := range staticAssetRequestHeaders {
+		if v, ok := r.Header[name]; ok {
+			req.Header[name] = v
+		}
+	}
+
+	resp, err := s.transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		// The object store's cookies aren't the revision's.
+		if name != "Set-Cookie" {
+			w.Header()[name] = values
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+type staticAssetsRoundTripper struct {
+	req *http.Request
+}
+
+func (rt *staticAssetsRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.req = r
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": {"text/css"},
+			"Set-Cookie":   {"store=1"},
+		},
+		Body: ioutil.NopCloser(strings.NewReader("body {}")),
+	}, nil
+}
+
+func TestStaticAssetsServe(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "assets"},
+		Data:       map[string]string{"robots.txt": "User-agent: *"},
+		BinaryData: map[string][]byte{"favicon.ico": {0, 0, 1, 0}},
+	}
+	informer := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+	configMaps := informer.Core().V1().ConfigMaps()
+	configMaps.Informer().GetIndexer().Add(cm)
+
+	tests := []struct {
+		name       string
+		annotation string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantType   string
+	}{{
+		name:   "no static assets",
+		method: http.MethodGet,
+		path:   "/favicon.ico",
+	}, {
+		name:       "not a static asset",
+		annotation: "paths=/favicon.ico, configMap=assets",
+		method:     http.MethodGet,
+		path:       "/index.html",
+	}, {
+		name:       "not a GET",
+		annotation: "paths=/favicon.ico, configMap=assets",
+		method:     http.MethodPost,
+		path:       "/favicon.ico",
+	}, {
+		name:       "binary data",
+		annotation: "paths=/favicon.ico /robots.txt, configMap=assets",
+		method:     http.MethodGet,
+		path:       "/favicon.ico",
+		wantStatus: http.StatusOK,
+		wantBody:   "\x00\x00\x01\x00",
+		wantType:   "image/vnd.microsoft.icon",
+	}, {
+		name:       "data",
+		annotation: "paths=/favicon.ico /robots.txt, configMap=assets",
+		method:     http.MethodGet,
+		path:       "/robots.txt",
+		wantStatus: http.StatusOK,
+		wantBody:   "User-agent: *",
+		wantType:   "text/plain; charset=utf-8",
+	}, {
+		name:       "missing key",
+		annotation: "paths=/static/*, configMap=assets",
+		method:     http.MethodGet,
+		path:       "/static/app.js",
+		wantStatus: http.StatusNotFound,
+		wantBody:   "404 page not found\n",
+		wantType:   "text/plain; charset=utf-8",
+	}, {
+		name:       "missing config map",
+		annotation: "paths=/favicon.ico, configMap=other",
+		method:     http.MethodGet,
+		path:       "/favicon.ico",
+		wantStatus: http.StatusNotFound,
+		wantBody:   "404 page not found\n",
+		wantType:   "text/plain; charset=utf-8",
+	}, {
+		name:       "object store",
+		annotation: "paths=/static/*, url=https://assets.example.com/site/",
+		method:     http.MethodGet,
+		path:       "/static/app.css",
+		wantStatus: http.StatusOK,
+		wantBody:   "body {}",
+		wantType:   "text/css",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := &staticAssetsRoundTripper{}
+			s := NewStaticAssets(configMaps.Lister(), rt)
+			rev := &v1alpha1.Revision{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "rev"},
+			}
+			if test.annotation != "" {
+				rev.Annotations = map[string]string{serving.StaticAssetsAnnotationKey: test.annotation}
+			}
+			req := httptest.NewRequest(test.method, "http://example.com"+test.path, nil)
+			req.Header.Set("Cookie", "session=secret")
+			rec := httptest.NewRecorder()
+
+			if got, want := s.Serve(rec, req, rev), test.wantStatus; got != want {
+				t.Errorf("Serve() = %d, want: %d", got, want)
+			}
+			if test.wantStatus == 0 {
+				return
+			}
+			if got, want := rec.Code, test.wantStatus; got != want {
+				t.Errorf("Status = %d, want: %d", got, want)
+			}
+			if got, want := rec.Body.String(), test.wantBody; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+			if got, want := rec.Header().Get("Content-Type"), test.wantType; got != want {
+				t.Errorf("Content-Type = %q, want: %q", got, want)
+			}
+			if got := rec.Header().Get("Set-Cookie"); got != "" {
+				t.Errorf("Set-Cookie = %q, want none", got)
+			}
+			if rt.req != nil {
+				if got, want := rt.req.URL.String(), "https://assets.example.com/site/static/app.css"; got != want {
+					t.Errorf("Fetched %q, want: %q", got, want)
+				}
+				if got := rt.req.Header.Get("Cookie"); got != "" {
+					t.Errorf("Cookie = %q, want none", got)
+				}
+			}
+		})
+	}
+}
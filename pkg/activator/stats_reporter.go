@@ -38,6 +38,10 @@ var (
 		"request_latencies",
 		"The response time in millisecond",
 		stats.UnitMilliseconds)
+	upgradedConnectionCountM = stats.Int64(
+		"upgraded_connection_count",
+		"The number of upgraded connections (e.g. WebSockets) that are routed to Activator",
+		stats.UnitDimensionless)
 
 	defaultLatencyDistribution = view.Distribution(0, 5, 10, 20, 40, 60, 80, 100, 150, 200, 250, 300, 350, 400, 450, 500, 600, 700, 800, 900, 1000, 2000, 5000, 10000, 20000, 50000, 100000)
 )
@@ -46,6 +50,7 @@ var (
 type StatsReporter interface {
 	ReportRequestCount(ns, service, config, rev string, responseCode, numTries int, v int64) error
 	ReportResponseTime(ns, service, config, rev string, responseCode int, d time.Duration) error
+	ReportUpgradedConnectionCount(ns, service, config, rev, protocol string, v int64) error
 }
 
 // Reporter holds cached metric objects to report autoscaler metrics
@@ -58,6 +63,7 @@ type Reporter struct {
 	responseCodeKey      tag.Key
 	responseCodeClassKey tag.Key
 	numTriesKey          tag.Key
+	protocolKey          tag.Key
 }
 
 // NewStatsReporter creates a reporter that collects and reports activator metrics
@@ -100,6 +106,11 @@ func NewStatsReporter() (*Reporter, error) {
 		return nil, err
 	}
 	r.numTriesKey = numTriesTag
+	protocolTag, err := tag.NewKey("upgrade_protocol")
+	if err != nil {
+		return nil, err
+	}
+	r.protocolKey = protocolTag
 	// Create view to see our measurements.
 	err = view.Register(
 		&view.View{
@@ -114,6 +125,12 @@ func NewStatsReporter() (*Reporter, error) {
 			Aggregation: defaultLatencyDistribution,
 			TagKeys:     []tag.Key{r.namespaceTagKey, r.serviceTagKey, r.configTagKey, r.revisionTagKey, r.responseCodeClassKey, r.responseCodeKey},
 		},
+		&view.View{
+			Description: "The number of upgraded connections that are routed to Activator",
+			Measure:     upgradedConnectionCountM,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{r.namespaceTagKey, r.serviceTagKey, r.configTagKey, r.revisionTagKey, r.protocolKey},
+		},
 	)
 	if err != nil {
 		return nil, err
@@ -178,6 +195,29 @@ func (r *Reporter) ReportResponseTime(ns, service, config, rev string, responseC
 	return nil
 }
 
+// ReportUpgradedConnectionCount captures the upgraded connection count
+// metric with value v.
+func (r *Reporter) ReportUpgradedConnectionCount(ns, service, config, rev, protocol string, v int64) error {
+	if !r.initialized {
+		return errors.New("StatsReporter is not initialized yet")
+	}
+
+	// Note that service names can be an empty string, so it needs a special treatment.
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(r.namespaceTagKey, ns),
+		tag.Insert(r.serviceTagKey, valueOrUnknown(service)),
+		tag.Insert(r.configTagKey, config),
+		tag.Insert(r.revisionTagKey, rev),
+		tag.Insert(r.protocolKey, protocol))
+	if err != nil {
+		return err
+	}
+
+	metrics.Record(ctx, upgradedConnectionCountM.M(v))
+	return nil
+}
+
 // responseCodeClass converts response code to a string of response code class.
 // e.g. The response code class is "5xx" for response code 503.
 func responseCodeClass(responseCode int) string {
@@ -33,6 +33,7 @@ func unregister() {
 	for _, s := range []string{
 		"request_count",
 		"request_latencies",
+		"upgraded_connection_count",
 	} {
 		if v := view.Find(s); v != nil {
 			view.Unregister(v)
@@ -87,6 +88,31 @@ func TestActivatorReporter(t *testing.T) {
 	checkDistributionData(t, "request_latencies", wantTags3, 2, 1100.0, 9100.0)
 }
 
+func TestReportUpgradedConnectionCount(t *testing.T) {
+	r := &Reporter{}
+	if err := r.ReportUpgradedConnectionCount("testns", "testsvc", "testconfig", "testrev", "websocket", 1); err == nil {
+		t.Error("Reporter expected an error for Report call before init. Got success.")
+	}
+
+	r, _ = NewStatsReporter()
+	defer unregister()
+
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     "testns",
+		metricskey.LabelServiceName:       "testsvc",
+		metricskey.LabelConfigurationName: "testconfig",
+		metricskey.LabelRevisionName:      "testrev",
+		"upgrade_protocol":                "websocket",
+	}
+	expectSuccess(t, func() error {
+		return r.ReportUpgradedConnectionCount("testns", "testsvc", "testconfig", "testrev", "websocket", 1)
+	})
+	expectSuccess(t, func() error {
+		return r.ReportUpgradedConnectionCount("testns", "testsvc", "testconfig", "testrev", "websocket", 1)
+	})
+	checkSumData(t, "upgraded_connection_count", wantTags, 2)
+}
+
 func TestReportRequestCount_EmptyServiceName(t *testing.T) {
 	r, _ := NewStatsReporter()
 	defer unregister()
@@ -26,8 +26,12 @@ import (
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging/logkey"
 	"knative.dev/pkg/system"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	painformers "github.com/knative/serving/pkg/client/informers/externalversions/autoscaling/v1alpha1"
+	palisters "github.com/knative/serving/pkg/client/listers/autoscaling/v1alpha1"
 	netlisters "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
 	servinglisters "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
 	"github.com/knative/serving/pkg/queue"
@@ -40,8 +44,17 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-// ErrActivatorOverload indicates that throttler has no free slots to buffer the request.
-var ErrActivatorOverload = errors.New("activator overload")
+var (
+	// ErrActivatorOverload indicates that throttler has no free slots to buffer the request.
+	ErrActivatorOverload = errors.New("activator overload")
+	// ErrActivatorTimeout indicates that the request timed out waiting for
+	// capacity in the throttler.
+	ErrActivatorTimeout = errors.New("activator timeout")
+)
+
+// breakerIdleTimeout is how long the breaker of a revision that receives no
+// requests is kept. It's recreated with up to date capacity on demand.
+const breakerIdleTimeout = 10 * time.Minute
 
 // Throttler keeps the mapping of Revisions to Breakers
 // and allows updating max concurrency dynamically of respective Breakers.
@@ -50,14 +63,16 @@ var ErrActivatorOverload = errors.New("activator overload")
 // It enables the use case to start with max concurrency set to 0 (no requests are sent because no endpoints are available)
 // and gradually increase its value depending on the external condition (e.g. new endpoints become available)
 type Throttler struct {
-	breakersMux sync.Mutex
-	breakers    map[RevisionID]*queue.Breaker
+	breakers *queue.BreakerPool
 
 	breakerParams   queue.BreakerParams
 	logger          *zap.SugaredLogger
 	endpointsLister corev1listers.EndpointsLister
 	revisionLister  servinglisters.RevisionLister
 	sksLister       netlisters.ServerlessServiceLister
+	// paLister reads the containerConcurrency of the PodAutoscalers, the
+	// one of the revisions is used when it's nil.
+	paLister palisters.PodAutoscalerLister
 
 	numActivatorsMux sync.RWMutex
 	numActivators    int
@@ -71,8 +86,14 @@ func NewThrottler(
 	revisionLister servinglisters.RevisionLister,
 	logger *zap.SugaredLogger) *Throttler {
 
+	var reporter queue.BreakerPoolReporter
+	if r, err := newBreakerPoolReporter(logger); err != nil {
+		logger.Errorw("Failed to create the breaker pool reporter", zap.Error(err))
+	} else {
+		reporter = r
+	}
 	throttler := &Throttler{
-		breakers:        make(map[RevisionID]*queue.Breaker),
+		breakers:        queue.NewBreakerPool(params, breakerIdleTimeout, reporter),
 		breakerParams:   params,
 		logger:          logger,
 		endpointsLister: endpointsInformer.Lister(),
@@ -113,11 +134,25 @@ func NewThrottler(
 	return throttler
 }
 
+// WatchPodAutoscalers makes the throttler follow the containerConcurrency
+// of the PodAutoscalers, which the autoscaler may change in place, instead
+// of the one the revisions were created with.
+func (t *Throttler) WatchPodAutoscalers(paInformer painformers.PodAutoscalerInformer) {
+	t.paLister = paInformer.Lister()
+	paInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: t.podAutoscalerUpdated,
+	})
+}
+
+// Run evicts the breakers of the revisions that stopped receiving requests
+// until stopCh is closed.
+func (t *Throttler) Run(stopCh <-chan struct{}) {
+	t.breakers.Run(stopCh)
+}
+
 // Remove deletes the breaker from the bookkeeping.
 func (t *Throttler) Remove(rev RevisionID) {
-	t.breakersMux.Lock()
-	defer t.breakersMux.Unlock()
-	delete(t.breakers, rev)
+	t.breakers.Remove(rev)
 }
 
 // UpdateCapacity updates the max concurrency of the Breaker corresponding to a revision.
@@ -126,31 +161,63 @@ func (t *Throttler) UpdateCapacity(rev RevisionID, size int) error {
 	if err != nil {
 		return err
 	}
-	breaker, _ := t.getOrCreateBreaker(rev)
-	return t.updateCapacity(breaker, int(revision.Spec.ContainerConcurrency), size, t.activatorCount())
+	breaker, _ := t.breakers.GetOrCreate(rev)
+	return breaker.UpdateConcurrency(t.targetCapacity(t.containerConcurrency(revision), size, t.ActivatorCount()))
+}
+
+// containerConcurrency returns the containerConcurrency the pods of the
+// revision currently apply, which is the one of its PodAutoscaler.
+func (t *Throttler) containerConcurrency(revision *v1alpha1.Revision) int {
+	if t.paLister != nil {
+		// PA name matches revision name.
+		if pa, err := t.paLister.PodAutoscalers(revision.Namespace).Get(revision.Name); err == nil {
+			return int(pa.Spec.ContainerConcurrency)
+		}
+	}
+	return int(revision.Spec.ContainerConcurrency)
+}
+
+// HasCapacity returns true if the breaker of the revision lets requests
+// through, i.e. the revision is not scaling from zero.
+func (t *Throttler) HasCapacity(rev RevisionID) bool {
+	breaker, ok := t.breakers.Get(rev)
+	return ok && breaker.Capacity() > 0
 }
 
 // Try potentially registers a new breaker in our bookkeeping
 // and executes the `function` on the Breaker.
 // It returns an error if either breaker doesn't have enough capacity,
 // or breaker's registration didn't succeed, e.g. getting endpoints or update capacity failed.
-// timeout is the time before this function returns ErrActivatorOverload. A 0 value for
+// It returns queue.ErrDraining if the revision was removed while the request waited.
+// timeout is the time before this function returns ErrActivatorTimeout. A 0 value for
 // timeout is infinite.
 func (t *Throttler) Try(timeout time.Duration, rev RevisionID, function func()) error {
-	breaker, existed := t.getOrCreateBreaker(rev)
+	breaker, existed := t.breakers.GetOrCreate(rev)
 	if !existed {
 		// Need to fetch the latest endpoints state, in case we missed the update.
-		if err := t.forceUpdateCapacity(rev, breaker, t.activatorCount()); err != nil {
+		// This also avoids a potential deadlock after a restart of the Activator
+		// or when a new one is added as part of scale out.
+		capacity, err := t.revisionCapacity(rev, t.ActivatorCount())
+		if err == nil {
+			err = breaker.UpdateConcurrency(capacity)
+		}
+		if err != nil {
 			return err
 		}
 	}
-	if !breaker.Maybe(timeout, function) {
+	switch err := breaker.Maybe(timeout, function); err {
+	case queue.ErrQueueFull:
 		return ErrActivatorOverload
+	case queue.ErrAcquireTimeout:
+		return ErrActivatorTimeout
+	default:
+		return err
 	}
-	return nil
 }
 
-func (t *Throttler) activatorCount() int {
+// ActivatorCount returns the number of ready activators the capacity of the
+// revisions is shared by.
+func (t *Throttler) ActivatorCount() int {
 	t.numActivatorsMux.RLock()
 	defer t.numActivatorsMux.RUnlock()
 	return t.numActivators
@@ -174,8 +241,9 @@ func minOneOrValue(num int) int {
 	return 1
 }
 
-// This method updates Breaker's concurrency.
-func (t *Throttler) updateCapacity(breaker *queue.Breaker, cc, size, activatorCount int) (err error) {
+// targetCapacity computes the Breaker's concurrency for a revision with the
+// given container concurrency and number of ready pods.
+func (t *Throttler) targetCapacity(cc, size, activatorCount int) int {
 	targetCapacity := cc * size
 
 	if size > 0 && (cc == 0 || targetCapacity > t.breakerParams.MaxConcurrency) {
@@ -184,36 +252,21 @@ func (t *Throttler) updateCapacity(breaker *queue.Breaker, cc, size, activatorCo
 	} else if targetCapacity > 0 {
 		targetCapacity = minOneOrValue(targetCapacity / minOneOrValue(activatorCount))
 	}
-	return breaker.UpdateConcurrency(targetCapacity)
-}
-
-// getOrCreateBreaker retrieves existing breaker or creates a new one.
-// This is important for not loosing the update signals
-// that came before the requests reached the Activator's Handler.
-func (t *Throttler) getOrCreateBreaker(rev RevisionID) (*queue.Breaker, bool) {
-	t.breakersMux.Lock()
-	defer t.breakersMux.Unlock()
-	breaker, ok := t.breakers[rev]
-	if !ok {
-		breaker = queue.NewBreaker(t.breakerParams)
-		t.breakers[rev] = breaker
-	}
-	return breaker, ok
+	return targetCapacity
 }
 
-// forceUpdateCapacity fetches the endpoints and updates the capacity of the newly created breaker.
-// This avoids a potential deadlock in case if we missed the updates from the Endpoints informer.
-// This could happen because of a restart of the Activator or when a new one is added as part of scale out.
-func (t *Throttler) forceUpdateCapacity(rev RevisionID, breaker *queue.Breaker, activatorCount int) (err error) {
+// revisionCapacity fetches the endpoints of the revision and computes the
+// capacity of its breaker from them.
+func (t *Throttler) revisionCapacity(rev RevisionID, activatorCount int) (int, error) {
 	revision, err := t.revisionLister.Revisions(rev.Namespace).Get(rev.Name)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// SKS name matches revision name.
 	sks, err := t.sksLister.ServerlessServices(rev.Namespace).Get(rev.Name)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// We have to read the private service endpoints in activator
@@ -222,20 +275,19 @@ func (t *Throttler) forceUpdateCapacity(rev RevisionID, breaker *queue.Breaker,
 	podCounter := resources.NewScopedEndpointsCounter(t.endpointsLister, sks.Namespace, sks.Status.PrivateServiceName)
 	size, err := podCounter.ReadyCount()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return t.updateCapacity(breaker, int(revision.Spec.ContainerConcurrency), size, activatorCount)
+	return t.targetCapacity(t.containerConcurrency(revision), size, activatorCount), nil
 }
 
 // updateAllBreakerCapacity updates the capacity of all breakers.
 func (t *Throttler) updateAllBreakerCapacity(activatorCount int) {
-	t.breakersMux.Lock()
-	defer t.breakersMux.Unlock()
-	for revID, breaker := range t.breakers {
-		if err := t.forceUpdateCapacity(revID, breaker, activatorCount); err != nil {
-			t.logger.With(zap.String(logkey.Key, revID.String())).Errorw("updating capacity failed", zap.Error(err))
-		}
+	errs := t.breakers.UpdateAll(func(key interface{}) (int, error) {
+		return t.revisionCapacity(key.(RevisionID), activatorCount)
+	})
+	for key, err := range errs {
+		t.logger.With(zap.String(logkey.Key, key.(RevisionID).String())).Errorw("updating capacity failed", zap.Error(err))
 	}
 }
 
@@ -254,6 +306,28 @@ func (t *Throttler) endpointsUpdated(newObj interface{}) {
 	}
 }
 
+// podAutoscalerUpdated is a handler function to be used by the PodAutoscaler
+// informer. It updates the capacity of the revision's breaker, if it has one,
+// when the containerConcurrency changed.
+func (t *Throttler) podAutoscalerUpdated(oldObj, newObj interface{}) {
+	old, pa := oldObj.(*pav1alpha1.PodAutoscaler), newObj.(*pav1alpha1.PodAutoscaler)
+	if old.Spec.ContainerConcurrency == pa.Spec.ContainerConcurrency {
+		return
+	}
+	revID := RevisionID{pa.Namespace, pa.Name}
+	breaker, ok := t.breakers.Get(revID)
+	if !ok {
+		return
+	}
+	capacity, err := t.revisionCapacity(revID, t.ActivatorCount())
+	if err == nil {
+		err = breaker.UpdateConcurrency(capacity)
+	}
+	if err != nil {
+		t.logger.With(zap.String(logkey.Key, revID.String())).Errorw("updating capacity failed", zap.Error(err))
+	}
+}
+
 // endpointsDeleted is a handler function to be used by the Endpoints informer.
 // It removes the Breaker from the Throttler bookkeeping.
 func (t *Throttler) endpointsDeleted(obj interface{}) {
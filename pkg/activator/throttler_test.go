@@ -28,6 +28,7 @@ import (
 	. "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/system"
 	"knative.dev/pkg/test/helpers"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	"github.com/knative/serving/pkg/apis/networking"
 	nv1a1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving"
@@ -122,8 +123,8 @@ func TestThrottlerUpdateCapacity(t *testing.T) {
 				t.Errorf("UpdateCapacity() = %v, wanted no error", err)
 			}
 			if s.want > 0 {
-				if got := throttler.breakers[revID].Capacity(); got != s.want {
-					t.Errorf("breakers[revID].Capacity() = %d, want %d", got, s.want)
+				if got := getBreaker(t, throttler, revID).Capacity(); got != s.want {
+					t.Errorf("Capacity() = %d, want %d", got, s.want)
 				}
 			}
 		})
@@ -192,7 +193,7 @@ func TestThrottlerActivatorEndpoints(t *testing.T) {
 			fake.CoreV1().Endpoints(activatorEp.Namespace).Create(activatorEp)
 			endpoints.Informer().GetIndexer().Add(activatorEp)
 
-			breaker := throttler.breakers[RevisionID{Name: testRevision, Namespace: testNamespace}]
+			breaker := getBreaker(t, throttler, RevisionID{Name: testRevision, Namespace: testNamespace})
 
 			if err := wait.PollImmediate(updatePollInterval, updatePollTimeout, func() (bool, error) {
 				return breaker.Capacity() == s.wantCapacity, nil
@@ -319,6 +320,66 @@ func TestThrottlerTryOverload(t *testing.T) {
 	}
 }
 
+func TestThrottlerTryTimeout(t *testing.T) {
+	defer ClearAll()
+	// Without endpoints, the revision never gets capacity.
+	th := getThrottler(
+		defaultMaxConcurrency,
+		revisionLister(testNamespace, testRevision, 1),
+		endpointsInformer(testNamespace, testRevision, 0),
+		sksLister(testNamespace, testRevision),
+		TestLogger(t),
+		0)
+
+	err := th.Try(10*time.Millisecond, revID, func() {
+		t.Error("function was run without capacity")
+	})
+	if err != ErrActivatorTimeout {
+		t.Errorf("error = %v, want: %v", err, ErrActivatorTimeout)
+	}
+}
+
+func TestThrottlerPodAutoscalerConcurrency(t *testing.T) {
+	throttler := getThrottler(
+		defaultMaxConcurrency,
+		revisionLister(testNamespace, testRevision, 10),
+		endpointsInformer(testNamespace, testRevision, 2),
+		sksLister(testNamespace, testRevision),
+		TestLogger(t),
+		initCapacity)
+
+	pa := &pav1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      testRevision,
+		},
+		Spec: pav1alpha1.PodAutoscalerSpec{
+			ContainerConcurrency: 4,
+		},
+	}
+	informer := servinginformers.NewSharedInformerFactory(servingfake.NewSimpleClientset(pa), 0)
+	pas := informer.Autoscaling().V1alpha1().PodAutoscalers()
+	pas.Informer().GetIndexer().Add(pa)
+	throttler.WatchPodAutoscalers(pas)
+
+	// The containerConcurrency of the PA supersedes the one of the revision.
+	if err := throttler.UpdateCapacity(revID, 2); err != nil {
+		t.Fatalf("UpdateCapacity() = %v", err)
+	}
+	if got, want := getBreaker(t, throttler, revID).Capacity(), 8; got != want {
+		t.Errorf("Capacity() = %d, want %d", got, want)
+	}
+
+	// Changing it in place updates the breaker.
+	updated := pa.DeepCopy()
+	updated.Spec.ContainerConcurrency = 1
+	pas.Informer().GetIndexer().Update(updated)
+	throttler.podAutoscalerUpdated(pa, updated)
+	if got, want := getBreaker(t, throttler, revID).Capacity(), 2; got != want {
+		t.Errorf("Capacity() = %d, want %d", got, want)
+	}
+}
+
 func TestThrottlerRemove(t *testing.T) {
 	throttler := getThrottler(
 		defaultMaxConcurrency,
@@ -328,13 +389,13 @@ func TestThrottlerRemove(t *testing.T) {
 		TestLogger(t),
 		initCapacity)
 
-	throttler.breakers[revID] = queue.NewBreaker(throttler.breakerParams)
-	if got := len(throttler.breakers); got != 1 {
+	throttler.breakers.GetOrCreate(revID)
+	if got := breakerCount(throttler); got != 1 {
 		t.Errorf("Number of Breakers created = %d, want: 1", got)
 	}
 
 	throttler.Remove(revID)
-	if got := len(throttler.breakers); got != 0 {
+	if got := breakerCount(throttler); got != 0 {
 		t.Errorf("Number of Breakers created = %d, want: %d", got, 0)
 	}
 }
@@ -379,7 +440,7 @@ func TestHelper_ReactToEndpoints(t *testing.T) {
 	if got := breakerCount(throttler); got != 1 {
 		t.Errorf("breakerCount() = %d, want 1", got)
 	}
-	breaker := throttler.breakers[RevisionID{Name: testRevision, Namespace: testNamespace}]
+	breaker := getBreaker(t, throttler, RevisionID{Name: testRevision, Namespace: testNamespace})
 	if got := breaker.Capacity(); got != 0 {
 		t.Errorf("Capacity() = %d, want 0", got)
 	}
@@ -479,9 +540,16 @@ func getThrottler(
 }
 
 func breakerCount(t *Throttler) int {
-	t.breakersMux.Lock()
-	defer t.breakersMux.Unlock()
-	return len(t.breakers)
+	return t.breakers.Len()
+}
+
+func getBreaker(t *testing.T, throttler *Throttler, rev RevisionID) *queue.Breaker {
+	t.Helper()
+	breaker, ok := throttler.breakers.Get(rev)
+	if !ok {
+		t.Fatalf("No breaker for %v", rev)
+	}
+	return breaker
 }
 
 func endpointsSubset(hostsPerSubset, subsets int) []v1.EndpointSubset {
@@ -496,3 +564,27 @@ func endpointsSubset(hostsPerSubset, subsets int) []v1.EndpointSubset {
 	}
 	return resp
 }
+
+// BenchmarkThrottlerTry measures the admission path of the activator for a
+// revision with capacity to spare. Its result is checked against
+// test/benchmarks.txt by test/benchmarks.sh.
+func BenchmarkThrottlerTry(b *testing.B) {
+	params := queue.BreakerParams{QueueDepth: 10000, MaxConcurrency: 1000, InitialCapacity: 0}
+	throttler := NewThrottler(params,
+		endpointsInformer(testNamespace, testRevision, 1),
+		sksLister(testNamespace, testRevision),
+		revisionLister(testNamespace, testRevision, 0),
+		zap.NewNop().Sugar())
+	if err := throttler.UpdateCapacity(revID, 1); err != nil {
+		b.Fatalf("UpdateCapacity() = %v", err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := throttler.Try(0, revID, func() {}); err != nil {
+				b.Errorf("Try() = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging/logkey"
+	"github.com/knative/serving/pkg/apis/networking"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/network"
+	"github.com/knative/serving/pkg/reconciler"
+	"github.com/knative/serving/pkg/resources"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RevisionTransports hands out a transport per revision, which sends the
+// requests to the ready pods of the revision with a transport per pod
+// address, so that the keep-alive connections to a pod can be dropped when
+// it goes away. Pods are removed from the endpoints of their revision as
+// soon as they are terminating, e.g. because the autoscaler scaled the
+// revision down, while the queue-proxy keeps serving for a while. Reusing a
+// connection to such a pod races with the queue-proxy closing it when it
+// finally shuts down, which fails the request with a 502.
+type RevisionTransports struct {
+	newTransport func() http.RoundTripper
+	logger       *zap.SugaredLogger
+	// fallback sends the requests to the revisions whose ready pods are not
+	// known yet through their private service.
+	fallback http.RoundTripper
+
+	mux  sync.Mutex
+	pods map[RevisionID]*revisionPods
+}
+
+// revisionPods are the ready pods of a revision and the transports of the
+// requests to them.
+type revisionPods struct {
+	// addresses are the sorted host:port of the ready pods.
+	addresses []string
+	// next is the index of the address to send the next request to.
+	next       int
+	transports map[string]http.RoundTripper
+}
+
+// revisionTransport sends the requests to a revision.
+type revisionTransport struct {
+	rt  *RevisionTransports
+	rev RevisionID
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t revisionTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	address, transport := t.rt.pick(t.rev)
+	if transport == nil {
+		return t.rt.fallback.RoundTrip(r)
+	}
+	// Keep the Host of the request to the private service, but dial the pod.
+	r2 := r.WithContext(r.Context())
+	u := *r.URL
+	u.Host = address
+	r2.URL = &u
+	if r2.Host == "" {
+		r2.Host = r.URL.Host
+	}
+	return transport.RoundTrip(r2)
+}
+
+// NewRevisionTransports creates the transports of the pods of the revisions
+// with newTransport, and drains them as the pods leave the endpoints of
+// their revisions in endpointsInformer.
+func NewRevisionTransports(newTransport func() http.RoundTripper,
+	endpointsInformer corev1informers.EndpointsInformer, logger *zap.SugaredLogger) *RevisionTransports {
+	rt := &RevisionTransports{
+		newTransport: newTransport,
+		logger:       logger,
+		fallback:     newTransport(),
+		pods:         make(map[RevisionID]*revisionPods),
+	}
+
+	// Like the throttler, watch the private services, which are populated
+	// by the actual revision backends.
+	endpointsInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: reconciler.ChainFilterFuncs(
+			reconciler.LabelExistsFilterFunc(serving.RevisionUID),
+			reconciler.LabelFilterFunc(networking.ServiceTypeKey, string(networking.ServiceTypePrivate), true),
+		),
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    rt.endpointsUpdated,
+			UpdateFunc: controller.PassNew(rt.endpointsUpdated),
+			DeleteFunc: rt.endpointsDeleted,
+		},
+	})
+
+	return rt
+}
+
+// Get returns the transport of the requests to the revision.
+func (rt *RevisionTransports) Get(rev RevisionID) http.RoundTripper {
+	return revisionTransport{rt: rt, rev: rev}
+}
+
+// pick returns the address of the next ready pod of the revision, round
+// robin, and its transport. The transport is nil when no pod is known.
+func (rt *RevisionTransports) pick(rev RevisionID) (string, http.RoundTripper) {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+	p, ok := rt.pods[rev]
+	if !ok || len(p.addresses) == 0 {
+		return "", nil
+	}
+	address := p.addresses[p.next%len(p.addresses)]
+	p.next++
+	t, ok := p.transports[address]
+	if !ok {
+		t = rt.newTransport()
+		p.transports[address] = t
+	}
+	return address, t
+}
+
+// updateReady records the ready addresses of the revision, and returns the
+// transports of the previous ones that are gone.
+func (rt *RevisionTransports) updateReady(rev RevisionID, ready sets.String) []http.RoundTripper {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+	p, ok := rt.pods[rev]
+	if !ok {
+		p = &revisionPods{transports: make(map[string]http.RoundTripper)}
+		rt.pods[rev] = p
+	}
+	p.addresses = ready.List()
+	var removed []http.RoundTripper
+	for address, t := range p.transports {
+		if !ready.Has(address) {
+			removed = append(removed, t)
+			delete(p.transports, address)
+		}
+	}
+	return removed
+}
+
+// endpointsUpdated drains the transports of the pods of the revision that
+// are not ready anymore.
+func (rt *RevisionTransports) endpointsUpdated(newObj interface{}) {
+	endpoints := newObj.(*corev1.Endpoints)
+	rev := RevisionID{endpoints.Namespace, resources.ParentResourceFromService(endpoints.Name)}
+	if removed := rt.updateReady(rev, readyAddresses(endpoints)); len(removed) > 0 {
+		rt.logger.With(zap.String(logkey.Key, rev.String())).Debugf(
+			"Closing the idle connections to %d pod(s) of the revision that went away", len(removed))
+		drain(removed)
+	}
+}
+
+// endpointsDeleted drains the transports of all the pods of the revision,
+// once it is gone.
+func (rt *RevisionTransports) endpointsDeleted(obj interface{}) {
+	endpoints := obj.(*corev1.Endpoints)
+	rev := RevisionID{endpoints.Namespace, resources.ParentResourceFromService(endpoints.Name)}
+	rt.mux.Lock()
+	p, ok := rt.pods[rev]
+	delete(rt.pods, rev)
+	rt.mux.Unlock()
+	if !ok {
+		return
+	}
+	removed := make([]http.RoundTripper, 0, len(p.transports))
+	for _, t := range p.transports {
+		removed = append(removed, t)
+	}
+	drain(removed)
+}
+
+// drain closes the idle connections of the transports. The connections
+// still in use are never reused once their requests are done, and are
+// closed by the idle timeout of the transports.
+func drain(transports []http.RoundTripper) {
+	for _, t := range transports {
+		if c, ok := t.(network.IdleConnectionsCloser); ok {
+			c.CloseIdleConnections()
+		}
+	}
+}
+
+// readyAddresses returns the host:port of the ready addresses of the
+// endpoints, on the serving port of the private service.
+func readyAddresses(endpoints *corev1.Endpoints) sets.String {
+	ready := sets.NewString()
+	for _, subset := range endpoints.Subsets {
+		port, ok := servingPort(subset)
+		if !ok {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			ready.Insert(net.JoinHostPort(address.IP, strconv.Itoa(int(port))))
+		}
+	}
+	return ready
+}
+
+// servingPort returns the port of the subset the requests are served on.
+func servingPort(subset corev1.EndpointSubset) (int32, bool) {
+	for _, p := range subset.Ports {
+		if p.Name == networking.ServicePortNameHTTP1 || p.Name == networking.ServicePortNameH2C {
+			return p.Port, true
+		}
+	}
+	return 0, false
+}
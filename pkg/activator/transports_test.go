@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "knative.dev/pkg/logging/testing"
+	"github.com/knative/serving/pkg/apis/networking"
+	"github.com/knative/serving/pkg/apis/serving"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeTransport struct {
+	hosts  []string
+	closed int
+}
+
+func (t *fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, r.URL.Host+" "+r.Host)
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func (t *fakeTransport) CloseIdleConnections() {
+	t.closed++
+}
+
+func privateEndpoints(ips ...string) *corev1.Endpoints {
+	addresses := make([]corev1.EndpointAddress, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+	}
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testRevision + "-private",
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				serving.RevisionUID:       "test",
+				networking.ServiceTypeKey: string(networking.ServiceTypePrivate),
+			},
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: addresses,
+			Ports: []corev1.EndpointPort{{
+				Name: networking.ServicePortNameHTTP1,
+				Port: 8012,
+			}},
+		}},
+	}
+}
+
+func TestRevisionTransports(t *testing.T) {
+	const service = testRevision + "-private." + testNamespace + ".svc.cluster.local:80"
+	var created []*fakeTransport
+	informer := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+	rt := NewRevisionTransports(func() http.RoundTripper {
+		t := &fakeTransport{}
+		created = append(created, t)
+		return t
+	}, informer.Core().V1().Endpoints(), TestLogger(t))
+	fallback := created[0]
+	rev := RevisionID{Namespace: testNamespace, Name: testRevision}
+
+	// send sends a request to the revision, and returns the transport of the
+	// pod it was sent to.
+	send := func() *fakeTransport {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://"+service, nil)
+		req.Host = ""
+		sent := make(map[*fakeTransport]int, len(created))
+		for _, p := range created {
+			sent[p] = len(p.hosts)
+		}
+		if _, err := rt.Get(rev).RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip() = %v", err)
+		}
+		for _, p := range created {
+			if len(p.hosts) > sent[p] {
+				return p
+			}
+		}
+		t.Fatal("The request was sent by no transport")
+		return nil
+	}
+
+	// The requests go through the private service until the pods are known.
+	if got := send(); got != fallback {
+		t.Error("Request sent to a pod of a revision without known pods")
+	}
+	if got, want := fallback.hosts[0], service+" "; got != want {
+		t.Errorf("Request sent to %q, want: %q", got, want)
+	}
+
+	// The requests are spread over the pods, keeping the Host of the service.
+	rt.endpointsUpdated(privateEndpoints("10.0.0.1", "10.0.0.2"))
+	first, second := send(), send()
+	if first == second || first == fallback || second == fallback {
+		t.Fatal("Requests not spread over the pods with a transport each")
+	}
+	for _, p := range []*fakeTransport{first, second} {
+		if host := p.hosts[0]; !strings.HasPrefix(host, "10.0.0.") || !strings.HasSuffix(host, ":8012 "+service) {
+			t.Errorf("Request sent to %q, want a pod with the Host %q", host, service)
+		}
+	}
+	if got := send(); got != first {
+		t.Error("Requests not sent round robin")
+	}
+
+	// Pods coming up don't affect the connections to the others.
+	rt.endpointsUpdated(privateEndpoints("10.0.0.1", "10.0.0.2", "10.0.0.3"))
+	if first.closed != 0 || second.closed != 0 {
+		t.Errorf("Idle connections closed %d and %d times after a scale up, want 0", first.closed, second.closed)
+	}
+
+	// A pod going away drains its transport only.
+	rt.endpointsUpdated(privateEndpoints("10.0.0.2", "10.0.0.3"))
+	gone, kept := first, second
+	if strings.HasPrefix(second.hosts[0], "10.0.0.1:") {
+		gone, kept = second, first
+	}
+	if gone.closed != 1 {
+		t.Errorf("Idle connections to the removed pod closed %d times, want 1", gone.closed)
+	}
+	if kept.closed != 0 {
+		t.Errorf("Idle connections to the remaining pod closed %d times, want 0", kept.closed)
+	}
+	for i := 0; i < 4; i++ {
+		if got := send(); got == gone {
+			t.Error("Request sent to the removed pod")
+		}
+	}
+
+	// The revision going away drains the transports of all its pods.
+	rt.endpointsDeleted(privateEndpoints())
+	if kept.closed != 1 {
+		t.Errorf("Idle connections closed %d times after the revision went away, want 1", kept.closed)
+	}
+	if got := send(); got != fallback {
+		t.Error("Request sent to a pod of a revision that went away")
+	}
+	if fallback.closed != 0 {
+		t.Errorf("Idle connections through the private services closed %d times, want 0", fallback.closed)
+	}
+}
@@ -19,7 +19,9 @@ package autoscaling
 import (
 	"fmt"
 	"strconv"
+	"time"
 
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 )
 
@@ -59,5 +61,100 @@ func ValidateAnnotations(annotations map[string]string) *apis.FieldError {
 		}
 	}
 
+	initial, err := getIntGE0(annotations, InitialScaleAnnotationKey)
+	if err != nil {
+		return err
+	}
+	if max != 0 && max < initial {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, max, InitialScaleAnnotationKey, initial),
+			Paths:   []string{MaxScaleAnnotationKey, InitialScaleAnnotationKey},
+		}
+	}
+
+	learned, err := getIntGE0(annotations, LearnedMinScaleMaxAnnotationKey)
+	if err != nil {
+		return err
+	}
+	if max != 0 && max < learned {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, max, LearnedMinScaleMaxAnnotationKey, learned),
+			Paths:   []string{MaxScaleAnnotationKey, LearnedMinScaleMaxAnnotationKey},
+		}
+	}
+
+	zones, err := getIntGE0(annotations, ZoneSpreadAnnotationKey)
+	if err != nil {
+		return err
+	}
+	if max != 0 && max < zones {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, max, ZoneSpreadAnnotationKey, zones),
+			Paths:   []string{MaxScaleAnnotationKey, ZoneSpreadAnnotationKey},
+		}
+	}
+	if _, err := getIntGE0(annotations, ZoneSpreadMinScaleAnnotationKey); err != nil {
+		return err
+	}
+
+	if v, ok := annotations[ScalingStrategyAnnotationKey]; ok {
+		// The strategies are registered with the autoscaler, which may be
+		// extended downstream, so only their names are checked here.
+		if len(validation.IsDNS1123Label(v)) != 0 {
+			return apis.ErrInvalidValue(v, ScalingStrategyAnnotationKey)
+		}
+	}
+
+	if v, ok := annotations[ScaleSmoothingAnnotationKey]; ok && !IsScaleSmoothing(v) {
+		return apis.ErrInvalidValue(v, ScaleSmoothingAnnotationKey)
+	}
+
+	if v, ok := annotations[HibernatedAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return apis.ErrInvalidValue(v, HibernatedAnnotationKey)
+		}
+	}
+
+	return validateActivation(annotations)
+}
+
+// IsScaleSmoothing returns true if v is the name of a scale smoothing filter.
+func IsScaleSmoothing(v string) bool {
+	return v == ScaleSmoothingNone || v == ScaleSmoothingEWMA || v == ScaleSmoothingPID
+}
+
+// IsHibernated returns true if the annotations hibernate their Revision.
+func IsHibernated(annotations map[string]string) bool {
+	b, _ := strconv.ParseBool(annotations[HibernatedAnnotationKey])
+	return b
+}
+
+// NamespaceLimit returns the limit set by the annotation key of a
+// Namespace, or 0 if it sets none. Namespaces aren't validated, so an
+// invalid limit is ignored.
+func NamespaceLimit(annotations map[string]string, key string) int {
+	i, err := strconv.ParseInt(annotations[key], 10, 32)
+	if err != nil || i < 0 {
+		return 0
+	}
+	return int(i)
+}
+
+func validateActivation(annotations map[string]string) *apis.FieldError {
+	if _, err := getIntGE0(annotations, ActivationScaleAnnotationKey); err != nil {
+		return err
+	}
+	_, hasScale := annotations[ActivationScaleAnnotationKey]
+	expiry, hasExpiry := annotations[ActivationExpiryAnnotationKey]
+	switch {
+	case hasScale && !hasExpiry:
+		return apis.ErrMissingField(ActivationExpiryAnnotationKey)
+	case hasExpiry && !hasScale:
+		return apis.ErrMissingField(ActivationScaleAnnotationKey)
+	case hasExpiry:
+		if _, err := time.Parse(time.RFC3339, expiry); err != nil {
+			return apis.ErrInvalidValue(expiry, ActivationExpiryAnnotationKey)
+		}
+	}
 	return nil
 }
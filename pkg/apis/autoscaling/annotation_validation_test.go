@@ -99,6 +99,129 @@ func TestValidateScaleBoundAnnotations(t *testing.T) {
 			MaxScaleAnnotationKey: "0",
 		},
 		expectErr: nil,
+	}, {
+		name:        "initialScale is 0",
+		annotations: map[string]string{InitialScaleAnnotationKey: "0"},
+		expectErr:   nil,
+	}, {
+		name:        "initialScale is -1",
+		annotations: map[string]string{InitialScaleAnnotationKey: "-1"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", InitialScaleAnnotationKey),
+			Paths:   []string{InitialScaleAnnotationKey},
+		},
+	}, {
+		name:        "initialScale is 3, maxScale is 5",
+		annotations: map[string]string{InitialScaleAnnotationKey: "3", MaxScaleAnnotationKey: "5"},
+		expectErr:   nil,
+	}, {
+		name:        "initialScale is 5, maxScale is 2",
+		annotations: map[string]string{InitialScaleAnnotationKey: "5", MaxScaleAnnotationKey: "2"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, 2, InitialScaleAnnotationKey, 5),
+			Paths:   []string{MaxScaleAnnotationKey, InitialScaleAnnotationKey},
+		},
+	}, {
+		name:        "learnedMinScaleMax is 2, maxScale is 5",
+		annotations: map[string]string{LearnedMinScaleMaxAnnotationKey: "2", MaxScaleAnnotationKey: "5"},
+		expectErr:   nil,
+	}, {
+		name:        "learnedMinScaleMax is -1",
+		annotations: map[string]string{LearnedMinScaleMaxAnnotationKey: "-1"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", LearnedMinScaleMaxAnnotationKey),
+			Paths:   []string{LearnedMinScaleMaxAnnotationKey},
+		},
+	}, {
+		name:        "learnedMinScaleMax is 5, maxScale is 2",
+		annotations: map[string]string{LearnedMinScaleMaxAnnotationKey: "5", MaxScaleAnnotationKey: "2"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, 2, LearnedMinScaleMaxAnnotationKey, 5),
+			Paths:   []string{MaxScaleAnnotationKey, LearnedMinScaleMaxAnnotationKey},
+		},
+	}, {
+		name: "zoneSpread is 3, zoneSpreadMinScale is 2",
+		annotations: map[string]string{
+			ZoneSpreadAnnotationKey:         "3",
+			ZoneSpreadMinScaleAnnotationKey: "2",
+		},
+	}, {
+		name:        "zoneSpread is -1",
+		annotations: map[string]string{ZoneSpreadAnnotationKey: "-1"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", ZoneSpreadAnnotationKey),
+			Paths:   []string{ZoneSpreadAnnotationKey},
+		},
+	}, {
+		name:        "zoneSpreadMinScale is not an integer",
+		annotations: map[string]string{ZoneSpreadMinScaleAnnotationKey: "two"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", ZoneSpreadMinScaleAnnotationKey),
+			Paths:   []string{ZoneSpreadMinScaleAnnotationKey},
+		},
+	}, {
+		name:        "zoneSpread is 3, maxScale is 2",
+		annotations: map[string]string{ZoneSpreadAnnotationKey: "3", MaxScaleAnnotationKey: "2"},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("%s=%v is less than %s=%v", MaxScaleAnnotationKey, 2, ZoneSpreadAnnotationKey, 3),
+			Paths:   []string{MaxScaleAnnotationKey, ZoneSpreadAnnotationKey},
+		},
+	}, {
+		name: "activation",
+		annotations: map[string]string{
+			ActivationScaleAnnotationKey:  "5",
+			ActivationExpiryAnnotationKey: "2019-06-01T12:00:00Z",
+		},
+		expectErr: nil,
+	}, {
+		name: "activationScale is -1",
+		annotations: map[string]string{
+			ActivationScaleAnnotationKey:  "-1",
+			ActivationExpiryAnnotationKey: "2019-06-01T12:00:00Z",
+		},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", ActivationScaleAnnotationKey),
+			Paths:   []string{ActivationScaleAnnotationKey},
+		},
+	}, {
+		name:        "activation without expiry",
+		annotations: map[string]string{ActivationScaleAnnotationKey: "5"},
+		expectErr:   apis.ErrMissingField(ActivationExpiryAnnotationKey),
+	}, {
+		name:        "activation without scale",
+		annotations: map[string]string{ActivationExpiryAnnotationKey: "2019-06-01T12:00:00Z"},
+		expectErr:   apis.ErrMissingField(ActivationScaleAnnotationKey),
+	}, {
+		name: "activation with invalid expiry",
+		annotations: map[string]string{
+			ActivationScaleAnnotationKey:  "5",
+			ActivationExpiryAnnotationKey: "in 5 minutes",
+		},
+		expectErr: apis.ErrInvalidValue("in 5 minutes", ActivationExpiryAnnotationKey),
+	}, {
+		name:        "hibernated",
+		annotations: map[string]string{HibernatedAnnotationKey: "true"},
+		expectErr:   nil,
+	}, {
+		name:        "hibernated is not a boolean",
+		annotations: map[string]string{HibernatedAnnotationKey: "yes please"},
+		expectErr:   apis.ErrInvalidValue("yes please", HibernatedAnnotationKey),
+	}, {
+		name:        "scaling strategy",
+		annotations: map[string]string{ScalingStrategyAnnotationKey: "pid"},
+		expectErr:   nil,
+	}, {
+		name:        "scaling strategy is not a name",
+		annotations: map[string]string{ScalingStrategyAnnotationKey: "PID controller"},
+		expectErr:   apis.ErrInvalidValue("PID controller", ScalingStrategyAnnotationKey),
+	}, {
+		name:        "scale smoothing",
+		annotations: map[string]string{ScaleSmoothingAnnotationKey: ScaleSmoothingEWMA},
+		expectErr:   nil,
+	}, {
+		name:        "unknown scale smoothing",
+		annotations: map[string]string{ScaleSmoothingAnnotationKey: "kalman"},
+		expectErr:   apis.ErrInvalidValue("kalman", ScaleSmoothingAnnotationKey),
 	}}
 
 	for _, c := range cases {
@@ -110,3 +233,34 @@ func TestValidateScaleBoundAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestNamespaceLimit(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        int
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "limit",
+		annotations: map[string]string{NamespaceMaxScaleAnnotationKey: "50"},
+		want:        50,
+	}, {
+		name:        "other limit",
+		annotations: map[string]string{NamespaceMaxConcurrencyAnnotationKey: "50"},
+	}, {
+		name:        "negative",
+		annotations: map[string]string{NamespaceMaxScaleAnnotationKey: "-1"},
+	}, {
+		name:        "not a number",
+		annotations: map[string]string{NamespaceMaxScaleAnnotationKey: "many"},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := NamespaceLimit(c.annotations, NamespaceMaxScaleAnnotationKey); got != c.want {
+				t.Errorf("NamespaceLimit() = %d, want: %d", got, c.want)
+			}
+		})
+	}
+}
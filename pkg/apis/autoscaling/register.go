@@ -41,6 +41,124 @@ const (
 	// the PodAutoscaler should provision. For example,
 	//   autoscaling.knative.dev/maxScale: "10"
 	MaxScaleAnnotationKey = GroupName + "/maxScale"
+	// InitialScaleAnnotationKey is the annotation to specify the number of Pods
+	// a new Revision should be created with. When set on a Namespace it acts as
+	// the default for all Revisions in that Namespace. For example,
+	//   autoscaling.knative.dev/initialScale: "3"
+	InitialScaleAnnotationKey = GroupName + "/initialScale"
+	// AllowZeroInitialScaleAnnotationKey is the Namespace annotation that permits
+	// Revisions in the Namespace to be created with an initial scale of zero.
+	// For example,
+	//   autoscaling.knative.dev/allowZeroInitialScale: "true"
+	AllowZeroInitialScaleAnnotationKey = GroupName + "/allowZeroInitialScale"
+	// NamespaceMaxScaleAnnotationKey is the Namespace annotation that caps
+	// the total number of Pods of all the Revisions in the Namespace. The
+	// autoscaler doesn't scale a Revision up past it. For example,
+	//   autoscaling.knative.dev/namespaceMaxScale: "50"
+	NamespaceMaxScaleAnnotationKey = GroupName + "/namespaceMaxScale"
+	// NamespaceMaxConcurrencyAnnotationKey is the Namespace annotation that
+	// caps the total number of requests in flight through the activator to
+	// all the Revisions in the Namespace. The requests past it are rejected
+	// with a 503. For example,
+	//   autoscaling.knative.dev/namespaceMaxConcurrency: "1000"
+	NamespaceMaxConcurrencyAnnotationKey = GroupName + "/namespaceMaxConcurrency"
+
+	// LearnedMinScaleMaxAnnotationKey is the annotation to opt a Revision
+	// into having the autoscaler learn its baseline load and keep enough
+	// Pods for it as a floating minimum scale, so that traffic which is
+	// regular but spaced out doesn't repeatedly cause cold starts. The
+	// value is the largest minimum scale that may be learned; the
+	// MinScaleAnnotationKey still applies. For example,
+	//   autoscaling.knative.dev/learnedMinScaleMax: "3"
+	LearnedMinScaleMaxAnnotationKey = GroupName + "/learnedMinScaleMax"
+
+	// ScalingStrategyAnnotationKey is the annotation to choose the algorithm
+	// the KPA computes the desired scale of a Revision with, among those
+	// registered with the autoscaler: "default", sizing the Revision for the
+	// target concurrency per Pod right away, or "pid", tracking the target
+	// with a PID controller that smooths the reaction to changes of the
+	// load. Unknown strategies fall back to the default one. For example,
+	//   autoscaling.knative.dev/scalingStrategy: "pid"
+	ScalingStrategyAnnotationKey = GroupName + "/scalingStrategy"
+
+	// ScaleSmoothingAnnotationKey is the annotation to choose the filter
+	// smoothing the desired scale of a Revision over the scaling decisions,
+	// overriding the scale-smoothing of config-autoscaler, to damp the
+	// oscillation of Revisions whose request latency varies widely. It is
+	// ScaleSmoothingNone, ScaleSmoothingEWMA or ScaleSmoothingPID, whose
+	// gains are set in config-autoscaler. For example,
+	//   autoscaling.knative.dev/scaleSmoothing: "ewma"
+	ScaleSmoothingAnnotationKey = GroupName + "/scaleSmoothing"
+	// ScaleSmoothingNone leaves the desired scale as computed.
+	ScaleSmoothingNone = "none"
+	// ScaleSmoothingEWMA smooths the desired scale with an exponentially
+	// weighted moving average.
+	ScaleSmoothingEWMA = "ewma"
+	// ScaleSmoothingPID has the scale track the desired one with a PID
+	// controller.
+	ScaleSmoothingPID = "pid"
+
+	// ActivationScaleAnnotationKey is the annotation external systems, e.g.
+	// an event source that knows a burst is coming, set on a Revision to
+	// activate it to at least this many Pods ahead of the traffic, without
+	// sending any. It's honored until ActivationExpiryAnnotationKey.
+	// For example,
+	//   autoscaling.knative.dev/activationScale: "5"
+	ActivationScaleAnnotationKey = GroupName + "/activationScale"
+	// ActivationExpiryAnnotationKey is when the activation requested by
+	// ActivationScaleAnnotationKey ends, in RFC3339 format. For example,
+	//   autoscaling.knative.dev/activationExpiry: "2019-06-01T12:00:00Z"
+	ActivationExpiryAnnotationKey = GroupName + "/activationExpiry"
+
+	// ZoneSpreadAnnotationKey is the annotation to spread the Pods of a
+	// Revision across this many topology zones, so that a single zone
+	// outage doesn't take all of them down. Once the Revision is scaled to
+	// ZoneSpreadMinScaleAnnotationKey Pods, the autoscaler keeps at least
+	// one Pod per zone and the Pods prefer not to share a zone.
+	// For example,
+	//   autoscaling.knative.dev/zoneSpread: "3"
+	ZoneSpreadAnnotationKey = GroupName + "/zoneSpread"
+	// ZoneSpreadMinScaleAnnotationKey is the scale from which the
+	// ZoneSpreadAnnotationKey applies. It defaults to 1, i.e. whenever the
+	// Revision isn't scaled to zero. For example,
+	//   autoscaling.knative.dev/zoneSpreadMinScale: "2"
+	ZoneSpreadMinScaleAnnotationKey = GroupName + "/zoneSpreadMinScale"
+
+	// HibernatedAnnotationKey is the annotation to hibernate a Revision,
+	// e.g. the preview of a closed pull request. A hibernated Revision is
+	// scaled to zero right away, whatever its minScale, and isn't scaled
+	// up by requests. Its routes stay programmed and are answered by the
+	// activator with a 503. Removing the annotation, or setting it to
+	// "false", restores the Revision. For example,
+	//   autoscaling.knative.dev/hibernated: "true"
+	HibernatedAnnotationKey = GroupName + "/hibernated"
+	// HibernationPageAnnotationKey is the annotation to customize the body
+	// of the responses to requests for a hibernated Revision. For example,
+	//   autoscaling.knative.dev/hibernationPage: "<h1>This preview is asleep</h1>"
+	HibernationPageAnnotationKey = GroupName + "/hibernationPage"
+
+	// PreScaleAnnotationKey is the annotation to opt a Route in to having
+	// the revisions whose share of its traffic grows scaled up before the
+	// traffic shifts to them. It only applies to KPA-class revisions. For
+	// example,
+	//   autoscaling.knative.dev/preScale: "true"
+	PreScaleAnnotationKey = GroupName + "/preScale"
+	// PreScalePercentAnnotationKey is set on a PodAutoscaler by the Route
+	// reconciler when the share of traffic its revision receives grows. The
+	// value is the new traffic percentage.
+	PreScalePercentAnnotationKey = InternalGroupName + "/preScalePercent"
+	// PreScaleFromAnnotationKey lists the comma separated names of the
+	// PodAutoscalers that served traffic before the shift.
+	PreScaleFromAnnotationKey = InternalGroupName + "/preScaleFrom"
+	// PreScaleTimeAnnotationKey records when the traffic shift was
+	// requested, in RFC3339 format.
+	PreScaleTimeAnnotationKey = InternalGroupName + "/preScaleTime"
+
+	// ContainerConcurrencyAnnotationKey is set on the pods of a revision by
+	// the autoscaler when the containerConcurrency of its PodAutoscaler is
+	// changed in place, so that the queue-proxy can pick it up without a
+	// new revision being rolled out.
+	ContainerConcurrencyAnnotationKey = InternalGroupName + "/containerConcurrency"
 
 	// MetricAnnotationKey is the annotation to specify what metric the PodAutoscaler
 	// should be scaled on. For example,
@@ -84,6 +84,43 @@ func (pa *PodAutoscaler) ScaleBounds() (min, max int32) {
 	return
 }
 
+// LearnedMinScaleMax returns the upper bound of the minimum scale the
+// autoscaler may learn for the PA. The value of 0 means learning is disabled.
+func (pa *PodAutoscaler) LearnedMinScaleMax() int32 {
+	return pa.annotationInt32(autoscaling.LearnedMinScaleMaxAnnotationKey)
+}
+
+// ScalingStrategy returns the name of the strategy the autoscaler computes
+// the desired scale of the PA with. The empty string means the default one.
+func (pa *PodAutoscaler) ScalingStrategy() string {
+	return pa.Annotations[autoscaling.ScalingStrategyAnnotationKey]
+}
+
+// ScaleSmoothing returns the scale smoothing annotation value or false if not
+// present.
+func (pa *PodAutoscaler) ScaleSmoothing() (string, bool) {
+	v, ok := pa.Annotations[autoscaling.ScaleSmoothingAnnotationKey]
+	return v, ok
+}
+
+// ZoneSpread returns the number of zones the PA's target is spread across
+// and the scale from which it keeps at least one Pod in each of them.
+// The value of 0 for zones means the target isn't spread.
+func (pa *PodAutoscaler) ZoneSpread() (zones, minScale int32) {
+	zones = pa.annotationInt32(autoscaling.ZoneSpreadAnnotationKey)
+	minScale = pa.annotationInt32(autoscaling.ZoneSpreadMinScaleAnnotationKey)
+	if minScale == 0 {
+		minScale = 1
+	}
+	return
+}
+
+// IsHibernated returns true if the PA's target is hibernated and must be
+// kept at zero scale.
+func (pa *PodAutoscaler) IsHibernated() bool {
+	return autoscaling.IsHibernated(pa.Annotations)
+}
+
 // Target returns the target annotation value or false if not present, or invalid.
 func (pa *PodAutoscaler) Target() (float64, bool) {
 	if s, ok := pa.Annotations[autoscaling.TargetAnnotationKey]; ok {
@@ -176,6 +213,39 @@ func (pas *PodAutoscalerStatus) MarkInactive(reason, message string) {
 	podCondSet.Manage(pas.duck()).MarkFalse(PodAutoscalerConditionActive, reason, message)
 }
 
+// MarkCapacityPending adds an Info-severity condition noting that pods of
+// the target can't be scheduled, with the reason given by the scheduler.
+func (pas *PodAutoscalerStatus) MarkCapacityPending(message string) {
+	podCondSet.Manage(pas.duck()).SetCondition(apis.Condition{
+		Type:     PodAutoscalerConditionCapacityPending,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   "Unschedulable",
+		Message:  message,
+	})
+}
+
+// ClearCapacityPending removes the CapacityPending condition once the pods
+// of the target are scheduled.
+func (pas *PodAutoscalerStatus) ClearCapacityPending() {
+	conds := pas.Conditions[:0]
+	for _, c := range pas.Conditions {
+		if c.Type != PodAutoscalerConditionCapacityPending {
+			conds = append(conds, c)
+		}
+	}
+	if len(conds) == 0 {
+		conds = nil
+	}
+	pas.Conditions = conds
+}
+
+// IsCapacityPending returns true if pods of the target can't be scheduled.
+func (pas *PodAutoscalerStatus) IsCapacityPending() bool {
+	cond := pas.GetCondition(PodAutoscalerConditionCapacityPending)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
 // MarkResourceNotOwned changes the "Active" condition to false to reflect that the
 // resource of the given kind and name has already been created, and we do not own it.
 func (pas *PodAutoscalerStatus) MarkResourceNotOwned(kind, name string) {
@@ -492,6 +492,136 @@ func TestScaleBounds(t *testing.T) {
 	}
 }
 
+func TestLearnedMinScaleMax(t *testing.T) {
+	cases := []struct {
+		name string
+		pa   *PodAutoscaler
+		want int32
+	}{{
+		name: "present",
+		pa: pa(map[string]string{
+			autoscaling.LearnedMinScaleMaxAnnotationKey: "3",
+		}),
+		want: 3,
+	}, {
+		name: "absent",
+		pa:   pa(map[string]string{}),
+		want: 0,
+	}, {
+		name: "malformed",
+		pa: pa(map[string]string{
+			autoscaling.LearnedMinScaleMaxAnnotationKey: "ham",
+		}),
+		want: 0,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.pa.LearnedMinScaleMax(); got != tc.want {
+				t.Errorf("LearnedMinScaleMax = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScalingStrategy(t *testing.T) {
+	if got, want := pa(map[string]string{}).ScalingStrategy(), ""; got != want {
+		t.Errorf("ScalingStrategy = %q, want: %q", got, want)
+	}
+	if got, want := pa(map[string]string{autoscaling.ScalingStrategyAnnotationKey: "pid"}).ScalingStrategy(), "pid"; got != want {
+		t.Errorf("ScalingStrategy = %q, want: %q", got, want)
+	}
+}
+
+func TestScaleSmoothing(t *testing.T) {
+	if got, ok := pa(map[string]string{}).ScaleSmoothing(); ok {
+		t.Errorf("ScaleSmoothing = %q, want none", got)
+	}
+	got, ok := pa(map[string]string{autoscaling.ScaleSmoothingAnnotationKey: autoscaling.ScaleSmoothingPID}).ScaleSmoothing()
+	if !ok || got != autoscaling.ScaleSmoothingPID {
+		t.Errorf("ScaleSmoothing = %q, %v, want: %q, true", got, ok, autoscaling.ScaleSmoothingPID)
+	}
+}
+
+func TestZoneSpread(t *testing.T) {
+	cases := []struct {
+		name      string
+		pa        *PodAutoscaler
+		wantZones int32
+		wantMin   int32
+	}{{
+		name: "present",
+		pa: pa(map[string]string{
+			autoscaling.ZoneSpreadAnnotationKey:         "3",
+			autoscaling.ZoneSpreadMinScaleAnnotationKey: "2",
+		}),
+		wantZones: 3,
+		wantMin:   2,
+	}, {
+		name: "default min scale",
+		pa: pa(map[string]string{
+			autoscaling.ZoneSpreadAnnotationKey: "3",
+		}),
+		wantZones: 3,
+		wantMin:   1,
+	}, {
+		name:      "absent",
+		pa:        pa(map[string]string{}),
+		wantZones: 0,
+		wantMin:   1,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			zones, min := tc.pa.ZoneSpread()
+			if zones != tc.wantZones {
+				t.Errorf("zones = %v, want: %v", zones, tc.wantZones)
+			}
+			if min != tc.wantMin {
+				t.Errorf("minScale = %v, want: %v", min, tc.wantMin)
+			}
+		})
+	}
+}
+
+func TestIsHibernated(t *testing.T) {
+	cases := []struct {
+		name string
+		pa   *PodAutoscaler
+		want bool
+	}{{
+		name: "hibernated",
+		pa: pa(map[string]string{
+			autoscaling.HibernatedAnnotationKey: "true",
+		}),
+		want: true,
+	}, {
+		name: "restored",
+		pa: pa(map[string]string{
+			autoscaling.HibernatedAnnotationKey: "false",
+		}),
+		want: false,
+	}, {
+		name: "absent",
+		pa:   pa(map[string]string{}),
+		want: false,
+	}, {
+		name: "malformed",
+		pa: pa(map[string]string{
+			autoscaling.HibernatedAnnotationKey: "zzz",
+		}),
+		want: false,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.pa.IsHibernated(); got != tc.want {
+				t.Errorf("IsHibernated = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestMarkResourceNotOwned(t *testing.T) {
 	pa := pa(map[string]string{})
 	pa.Status.MarkResourceNotOwned("doesn't", "matter")
@@ -518,6 +648,35 @@ func TestMarkResourceFailedCreation(t *testing.T) {
 	}
 }
 
+func TestCapacityPending(t *testing.T) {
+	pa := &PodAutoscalerStatus{}
+	pa.InitializeConditions()
+	pa.MarkActive()
+	if pa.IsCapacityPending() {
+		t.Error("IsCapacityPending() = true before any pod was unschedulable")
+	}
+
+	pa.MarkCapacityPending("0/3 nodes are available: 3 Insufficient cpu.")
+	if !pa.IsCapacityPending() {
+		t.Error("IsCapacityPending() = false, want true")
+	}
+	cond := pa.GetCondition(PodAutoscalerConditionCapacityPending)
+	if cond.Reason != "Unschedulable" || cond.Severity != apis.ConditionSeverityInfo {
+		t.Errorf("CapacityPending condition = %#v, want an Info-severity Unschedulable condition", cond)
+	}
+	// Pending capacity holds back scale ups, but doesn't make the PA unready.
+	apitest.CheckConditionSucceeded(pa.duck(), PodAutoscalerConditionReady, t)
+
+	pa.ClearCapacityPending()
+	if pa.IsCapacityPending() {
+		t.Error("IsCapacityPending() = true after ClearCapacityPending")
+	}
+	if got, want := len(pa.Conditions), 2; got != want {
+		t.Errorf("Got %d conditions after ClearCapacityPending, want: %d", got, want)
+	}
+	apitest.CheckConditionSucceeded(pa.duck(), PodAutoscalerConditionActive, t)
+}
+
 func TestClass(t *testing.T) {
 	cases := []struct {
 		name string
@@ -101,6 +101,11 @@ const (
 	PodAutoscalerConditionReady = apis.ConditionReady
 	// PodAutoscalerConditionActive is set when the PodAutoscaler's ScaleTargetRef is receiving traffic.
 	PodAutoscalerConditionActive apis.ConditionType = "Active"
+	// PodAutoscalerConditionCapacityPending is set while pods of the
+	// PodAutoscaler's ScaleTargetRef can't be scheduled, e.g. because the
+	// cluster is out of capacity, which holds back further scale ups. It
+	// doesn't affect the readiness of the PodAutoscaler.
+	PodAutoscalerConditionCapacityPending apis.ConditionType = "CapacityPending"
 )
 
 // PodAutoscalerStatus communicates the observed state of the PodAutoscaler (from the controller).
@@ -114,6 +119,23 @@ type PodAutoscalerStatus struct {
 	// MetricsServiceName is the K8s Service name that provides revision metrics.
 	// The service is managed by the PA object.
 	MetricsServiceName string `json:"metricsServiceName"`
+
+	// ExcessBurstCapacity is the capacity the revision has left to absorb
+	// bursts of requests, as of the last scaling decision that changed its
+	// sign. A negative value means bursts should be buffered by the
+	// activator. It is only set when the revision maintains a target burst
+	// capacity.
+	// +optional
+	ExcessBurstCapacity *int32 `json:"excessBurstCapacity,omitempty"`
+
+	// PreScaleTime echoes the preScaleTime annotation the PA was last
+	// reconciled with, and PreScaleReady whether the revision then had at
+	// least the ready pods it was pre-scaled to. The Route holds the traffic
+	// shift it announced until then.
+	// +optional
+	PreScaleTime string `json:"preScaleTime,omitempty"`
+	// +optional
+	PreScaleReady bool `json:"preScaleReady,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -107,6 +107,11 @@ func (in *PodAutoscalerSpec) DeepCopy() *PodAutoscalerSpec {
 func (in *PodAutoscalerStatus) DeepCopyInto(out *PodAutoscalerStatus) {
 	*out = *in
 	in.Status.DeepCopyInto(&out.Status)
+	if in.ExcessBurstCapacity != nil {
+		in, out := &in.ExcessBurstCapacity, &out.ExcessBurstCapacity
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
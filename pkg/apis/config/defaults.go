@@ -64,11 +64,22 @@ func NewDefaultsConfigFromMap(data map[string]string) (*Defaults, error) {
 		key:          "max-revision-timeout-seconds",
 		field:        &nc.MaxRevisionTimeoutSeconds,
 		defaultValue: DefaultMaxRevisionTimeoutSeconds,
+	}, {
+		key:   "queue-max-header-bytes",
+		field: &nc.QueueMaxHeaderBytes,
+	}, {
+		key:   "queue-max-connections",
+		field: &nc.QueueMaxConnections,
+	}, {
+		key:   "queue-read-header-timeout-seconds",
+		field: &nc.QueueReadHeaderTimeoutSeconds,
 	}} {
 		if raw, ok := data[i64.key]; !ok {
 			*i64.field = i64.defaultValue
 		} else if val, err := strconv.ParseInt(raw, 10, 64); err != nil {
 			return nil, err
+		} else if val < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", i64.key, val)
 		} else {
 			*i64.field = val
 		}
@@ -141,6 +152,12 @@ type Defaults struct {
 	RevisionCPULimit      *resource.Quantity
 	RevisionMemoryRequest *resource.Quantity
 	RevisionMemoryLimit   *resource.Quantity
+
+	// The limits of the queue-proxy's server, which the annotations of a
+	// revision may override. Zero keeps the defaults of Go's http.Server.
+	QueueMaxHeaderBytes           int64
+	QueueMaxConnections           int64
+	QueueReadHeaderTimeoutSeconds int64
 }
 
 // UserContainerName returns the name of the user container based on the context.
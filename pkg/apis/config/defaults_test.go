@@ -74,17 +74,37 @@ func TestDefaultsConfiguration(t *testing.T) {
 			MaxRevisionTimeoutSeconds: 456,
 			RevisionCPURequest:        &oneTwoThree,
 			UserContainerNameTemplate: "{{.Name}}",
+
+			QueueMaxHeaderBytes:           65536,
+			QueueMaxConnections:           1000,
+			QueueReadHeaderTimeoutSeconds: 10,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      DefaultsConfigName,
+			},
+			Data: map[string]string{
+				"revision-timeout-seconds":          "123",
+				"max-revision-timeout-seconds":      "456",
+				"revision-cpu-request":              "123m",
+				"container-name-template":           "{{.Name}}",
+				"queue-max-header-bytes":            "65536",
+				"queue-max-connections":             "1000",
+				"queue-read-header-timeout-seconds": "10",
+			},
 		},
+	}, {
+		name:         "negative queue max connections",
+		wantErr:      true,
+		wantDefaults: (*Defaults)(nil),
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: system.Namespace(),
 				Name:      DefaultsConfigName,
 			},
 			Data: map[string]string{
-				"revision-timeout-seconds":     "123",
-				"max-revision-timeout-seconds": "456",
-				"revision-cpu-request":         "123m",
-				"container-name-template":      "{{.Name}}",
+				"queue-max-connections": "-1",
 			},
 		},
 	}, {
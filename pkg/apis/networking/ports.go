@@ -32,6 +32,10 @@ const (
 	// BackendHTTP2Port is the backend, i.e. `targetPort` that we setup for HTTP services.
 	BackendHTTP2Port = 8013
 
+	// BackendL4Port is the port on which queue-proxy proxies the TCP or UDP
+	// port of revisions serving protocols other than HTTP.
+	BackendL4Port = 8014
+
 	// QueueAdminPort specifies the port number for
 	// health check and lifecycle hooks for queue-proxy.
 	QueueAdminPort = 8022
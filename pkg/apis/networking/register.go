@@ -35,6 +35,29 @@ const (
 	// Istio-based ClusterIngress will reconcile into a VirtualService).
 	IngressClassAnnotationKey = "networking.knative.dev/ingress.class"
 
+	// ActivatorPoolLabelKey is the label on a namespace that selects a
+	// dedicated pool of activators for the revisions in that namespace.
+	// For example,
+	//
+	//    networking.knative.dev/activator-pool: tenant-a
+	//
+	// routes traffic that needs buffering to the endpoints of the
+	// activator-service-tenant-a Service in the system namespace. If the
+	// pool does not exist or has no ready endpoints, the shared activator
+	// pool is used.
+	ActivatorPoolLabelKey = "networking.knative.dev/activator-pool"
+
+	// TLSPolicyAnnotationKeyPrefix prefixes the annotations on a namespace
+	// that override the cluster-wide TLS policy of the routes in that
+	// namespace. For example,
+	//
+	//    networking.knative.dev/tls.minProtocolVersion: TLSV1_2
+	//    networking.knative.dev/tls.cipherSuites: ECDHE-RSA-AES128-GCM-SHA256
+	//    networking.knative.dev/tls.alpnProtocols: h2,http/1.1
+	//
+	// The overrides apply to both external and cluster-local endpoints.
+	TLSPolicyAnnotationKeyPrefix = "networking.knative.dev/tls."
+
 	// ClusterIngressLabelKey is the label key attached to underlying network programming
 	// resources to indicate which ClusterIngress triggered their creation.
 	ClusterIngressLabelKey = GroupName + "/clusteringress"
@@ -109,6 +109,12 @@ type IngressSpec struct {
 
 	// Visibility setting.
 	Visibility IngressVisibility `json:"visibility,omitempty"`
+
+	// TLSPolicy restricts the TLS handshakes accepted on the TLS ports of
+	// the Ingress. Implementations that cannot enforce a field of the
+	// policy should ignore it.
+	// +optional
+	TLSPolicy *IngressTLSPolicy `json:"tlsPolicy,omitempty"`
 }
 
 // IngressVisibility describes whether the Ingress should be exposed to
@@ -151,6 +157,39 @@ type IngressTLS struct {
 	PrivateKey string `json:"privateKey,omitempty"`
 }
 
+// IngressTLSPolicy describes the TLS handshake policy of an Ingress.
+type IngressTLSPolicy struct {
+	// MinProtocolVersion is the lowest TLS protocol version accepted.
+	// Defaults to the implementation's minimum, if left unspecified.
+	// +optional
+	MinProtocolVersion TLSProtocolVersion `json:"minProtocolVersion,omitempty"`
+
+	// CipherSuites is the list of cipher suites accepted for TLS versions
+	// prior to 1.3, by their OpenSSL names, e.g. ECDHE-RSA-AES128-GCM-SHA256.
+	// Defaults to the implementation's cipher suites, if left unspecified.
+	// +optional
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+
+	// ALPNProtocols is the list of protocols offered through the ALPN TLS
+	// extension, in order of preference, e.g. h2 and http/1.1.
+	// +optional
+	ALPNProtocols []string `json:"alpnProtocols,omitempty"`
+}
+
+// TLSProtocolVersion is a version of the TLS protocol.
+type TLSProtocolVersion string
+
+const (
+	// TLSProtocolVersion10 is TLS 1.0.
+	TLSProtocolVersion10 TLSProtocolVersion = "TLSV1_0"
+	// TLSProtocolVersion11 is TLS 1.1.
+	TLSProtocolVersion11 TLSProtocolVersion = "TLSV1_1"
+	// TLSProtocolVersion12 is TLS 1.2.
+	TLSProtocolVersion12 TLSProtocolVersion = "TLSV1_2"
+	// TLSProtocolVersion13 is TLS 1.3.
+	TLSProtocolVersion13 TLSProtocolVersion = "TLSV1_3"
+)
+
 // IngressRule represents the rules mapping the paths under a specified host to
 // the related backend services. Incoming requests are first evaluated for a host
 // match, then routed to the backend associated with the matching IngressRuleValue.
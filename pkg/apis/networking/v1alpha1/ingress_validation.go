@@ -49,6 +49,9 @@ func (spec *IngressSpec) Validate(ctx context.Context) *apis.FieldError {
 	for idx, tls := range spec.TLS {
 		all = all.Also(tls.Validate(ctx).ViaFieldIndex("tls", idx))
 	}
+	if spec.TLSPolicy != nil {
+		all = all.Also(spec.TLSPolicy.Validate(ctx).ViaField("tlsPolicy"))
+	}
 	return all
 }
 
@@ -172,3 +175,24 @@ func (t *IngressTLS) Validate(ctx context.Context) *apis.FieldError {
 	}
 	return all
 }
+
+// Validate inspects and validates IngressTLSPolicy object.
+func (p *IngressTLSPolicy) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	switch p.MinProtocolVersion {
+	case "", TLSProtocolVersion10, TLSProtocolVersion11, TLSProtocolVersion12, TLSProtocolVersion13:
+	default:
+		all = all.Also(apis.ErrInvalidValue(p.MinProtocolVersion, "minProtocolVersion"))
+	}
+	for idx, suite := range p.CipherSuites {
+		if suite == "" {
+			all = all.Also(apis.ErrMissingField(apis.CurrentField).ViaFieldIndex("cipherSuites", idx))
+		}
+	}
+	for idx, proto := range p.ALPNProtocols {
+		if proto == "" {
+			all = all.Also(apis.ErrMissingField(apis.CurrentField).ViaFieldIndex("alpnProtocols", idx))
+		}
+	}
+	return all
+}
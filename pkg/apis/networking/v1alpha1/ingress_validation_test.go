@@ -334,6 +334,54 @@ func TestIngressSpecValidation(t *testing.T) {
 			}},
 		},
 		want: apis.ErrMissingField("tls[0].secretName"),
+	}, {
+		name: "valid-tls-policy",
+		is: &IngressSpec{
+			TLSPolicy: &IngressTLSPolicy{
+				MinProtocolVersion: TLSProtocolVersion12,
+				CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+				ALPNProtocols:      []string{"h2", "http/1.1"},
+			},
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		},
+		want: nil,
+	}, {
+		name: "invalid-tls-policy",
+		is: &IngressSpec{
+			TLSPolicy: &IngressTLSPolicy{
+				MinProtocolVersion: "SSLV3",
+				ALPNProtocols:      []string{"h2", ""},
+			},
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrInvalidValue("SSLV3", "tlsPolicy.minProtocolVersion").Also(
+			apis.ErrMissingField("tlsPolicy.alpnProtocols[1]")),
 	}}
 
 	for _, test := range tests {
@@ -100,6 +100,22 @@ type ServerlessServiceSpec struct {
 	// The application-layer protocol. Matches `RevisionProtocolType` set on the owning pa/revision.
 	// serving imports networking, so just use string.
 	ProtocolType networking.ProtocolType
+
+	// ExcessBurstCapacity is the capacity the pods have left to absorb
+	// bursts of requests, as computed by the autoscaler. Ingress
+	// implementations may use it to decide which requests to send through
+	// the activator while in Serve mode, e.g. to buffer requests that
+	// aren't safe to retry when it is negative. It is only updated when its
+	// sign changes. Unset if the revision doesn't maintain a target burst
+	// capacity.
+	// +optional
+	ExcessBurstCapacity *int32 `json:"excessBurstCapacity,omitempty"`
+
+	// CapacityPending is set while pods of the revision can't be scheduled,
+	// so that it won't scale up soon. The activator may use it to shed the
+	// requests that would wait for capacity rather than buffer them.
+	// +optional
+	CapacityPending bool `json:"capacityPending,omitempty"`
 }
 
 // ServerlessServiceStatus describes the current state of the ServerlessService.
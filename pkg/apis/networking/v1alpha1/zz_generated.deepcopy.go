@@ -448,6 +448,11 @@ func (in *IngressSpec) DeepCopyInto(out *IngressSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TLSPolicy != nil {
+		in, out := &in.TLSPolicy, &out.TLSPolicy
+		*out = new(IngressTLSPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -504,6 +509,32 @@ func (in *IngressTLS) DeepCopy() *IngressTLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressTLSPolicy) DeepCopyInto(out *IngressTLSPolicy) {
+	*out = *in
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ALPNProtocols != nil {
+		in, out := &in.ALPNProtocols, &out.ALPNProtocols
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressTLSPolicy.
+func (in *IngressTLSPolicy) DeepCopy() *IngressTLSPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressTLSPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadBalancerIngressStatus) DeepCopyInto(out *LoadBalancerIngressStatus) {
 	*out = *in
@@ -546,7 +577,7 @@ func (in *ServerlessService) DeepCopyInto(out *ServerlessService) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -606,6 +637,11 @@ func (in *ServerlessServiceList) DeepCopyObject() runtime.Object {
 func (in *ServerlessServiceSpec) DeepCopyInto(out *ServerlessServiceSpec) {
 	*out = *in
 	out.ObjectRef = in.ObjectRef
+	if in.ExcessBurstCapacity != nil {
+		in, out := &in.ExcessBurstCapacity, &out.ExcessBurstCapacity
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ClientQuota are the quotas of each client of a Revision, across all of
+// its queue-proxies, as declared by the ClientQuotaAnnotationKey
+// annotation.
+type ClientQuota struct {
+	// Header is the request header identifying the client, e.g. an API
+	// key. Clients are identified by their IP without it, or if a request
+	// lacks it.
+	Header string
+	// Concurrency is the number of requests a client may have in flight,
+	// unlimited if 0.
+	Concurrency int
+	// RPS is the number of requests a client may make per second,
+	// unlimited if 0.
+	RPS int
+}
+
+// ParseClientQuota parses the value of the client quota annotation, a
+// comma separated list of "header=NAME", "concurrency=N" and "rps=N"
+// entries, e.g. "header=X-Api-Key, concurrency=10, rps=100". At least one
+// of concurrency and rps must be given.
+func ParseClientQuota(v string) (ClientQuota, error) {
+	var q ClientQuota
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return ClientQuota{}, fmt.Errorf("entry %q must be of the form KEY=VALUE", entry)
+		}
+		key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		switch key {
+		case "header":
+			if value == "" || strings.ContainsAny(value, " \t:") {
+				return ClientQuota{}, fmt.Errorf("header must be a header name, was %q", value)
+			}
+			q.Header = http.CanonicalHeaderKey(value)
+		case "concurrency", "rps":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return ClientQuota{}, fmt.Errorf("%s must be an integer greater than 0, was %q", key, value)
+			}
+			if key == "concurrency" {
+				q.Concurrency = n
+			} else {
+				q.RPS = n
+			}
+		default:
+			return ClientQuota{}, fmt.Errorf("unknown entry %q", entry)
+		}
+	}
+	if q.Concurrency == 0 && q.RPS == 0 {
+		return ClientQuota{}, fmt.Errorf("at least one of concurrency and rps must be set")
+	}
+	return q, nil
+}
@@ -394,6 +394,7 @@ func validateContainerPorts(ports []corev1.ContainerPort) *apis.FieldError {
 	// Don't allow userPort to conflict with QueueProxy sidecar
 	if userPort.ContainerPort == networking.BackendHTTPPort ||
 		userPort.ContainerPort == networking.BackendHTTP2Port ||
+		userPort.ContainerPort == networking.BackendL4Port ||
 		userPort.ContainerPort == networking.QueueAdminPort ||
 		userPort.ContainerPort == networking.AutoscalingQueueMetricsPort ||
 		userPort.ContainerPort == networking.UserQueueMetricsPort {
@@ -430,6 +431,16 @@ func validateProbe(p *corev1.Probe) *apis.FieldError {
 		errs = errs.Also(apis.CheckDisallowedFields(*h.HTTPGet, *HTTPGetActionMask(h.HTTPGet))).ViaField("httpGet")
 	case h.TCPSocket != nil:
 		errs = errs.Also(apis.CheckDisallowedFields(*h.TCPSocket, *TCPSocketActionMask(h.TCPSocket))).ViaField("tcpSocket")
+	case h.Exec != nil:
+		errs = errs.Also(validateExecAction(h.Exec).ViaField("exec"))
+	}
+	return errs
+}
+
+func validateExecAction(e *corev1.ExecAction) *apis.FieldError {
+	errs := apis.CheckDisallowedFields(*e, *ExecActionMask(e))
+	if len(e.Command) == 0 || e.Command[0] == "" {
+		errs = errs.Also(apis.ErrMissingField("command"))
 	}
 	return errs
 }
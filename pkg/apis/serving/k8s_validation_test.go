@@ -376,6 +376,15 @@ func TestContainerValidation(t *testing.T) {
 			}},
 		},
 		want: apis.ErrInvalidValue(8012, "ports.containerPort"),
+	}, {
+		name: "port conflicts with queue proxy l4",
+		c: corev1.Container{
+			Image: "foo",
+			Ports: []corev1.ContainerPort{{
+				ContainerPort: 8014,
+			}},
+		},
+		want: apis.ErrInvalidValue(8014, "ports.containerPort"),
 	}, {
 		name: "port conflicts with queue proxy metrics",
 		c: corev1.Container{
@@ -517,6 +526,30 @@ func TestContainerValidation(t *testing.T) {
 			},
 		},
 		want: apis.ErrDisallowedFields("readinessProbe.httpGet.port"),
+	}, {
+		name: "valid readiness exec probe",
+		c: corev1.Container{
+			Image: "foo",
+			ReadinessProbe: &corev1.Probe{
+				Handler: corev1.Handler{
+					Exec: &corev1.ExecAction{
+						Command: []string{"/bin/healthcheck", "--ready"},
+					},
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid readiness exec probe (no command)",
+		c: corev1.Container{
+			Image: "foo",
+			ReadinessProbe: &corev1.Probe{
+				Handler: corev1.Handler{
+					Exec: &corev1.ExecAction{},
+				},
+			},
+		},
+		want: apis.ErrMissingField("readinessProbe.exec.command"),
 	}, {
 		name: "disallowed security context field",
 		c: corev1.Container{
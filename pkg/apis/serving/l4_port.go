@@ -0,0 +1,54 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knative/serving/pkg/apis/networking"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// L4Port is the non-HTTP port of a Revision, as declared by the
+// L4PortAnnotationKey annotation.
+type L4Port struct {
+	Port     int32
+	Protocol corev1.Protocol
+}
+
+// ParseL4Port parses the value of the L4 port annotation, e.g. "6379/TCP".
+func ParseL4Port(v string) (L4Port, error) {
+	port, protocol := v, string(corev1.ProtocolTCP)
+	if i := strings.Index(v, "/"); i >= 0 {
+		port, protocol = v[:i], strings.ToUpper(v[i+1:])
+	}
+	if protocol != string(corev1.ProtocolTCP) && protocol != string(corev1.ProtocolUDP) {
+		return L4Port{}, fmt.Errorf("protocol must be TCP or UDP, was %q", protocol)
+	}
+	p, err := strconv.ParseInt(port, 10, 32)
+	if err != nil || p <= 0 || p > 65535 {
+		return L4Port{}, fmt.Errorf("port must be between 1 and 65535, was %q", port)
+	}
+	switch p {
+	case networking.BackendHTTPPort, networking.BackendHTTP2Port, networking.BackendL4Port,
+		networking.QueueAdminPort, networking.AutoscalingQueueMetricsPort, networking.UserQueueMetricsPort:
+		return L4Port{}, fmt.Errorf("port %d is reserved for the queue-proxy", p)
+	}
+	return L4Port{Port: int32(p), Protocol: corev1.Protocol(protocol)}, nil
+}
@@ -17,8 +17,16 @@ limitations under the License.
 package serving
 
 import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"strconv"
+	"time"
+
 	"knative.dev/pkg/apis"
 	"github.com/knative/serving/pkg/apis/autoscaling"
+	"golang.org/x/net/http/httpguts"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,5 +34,306 @@ import (
 // resources is correct.
 func ValidateObjectMetadata(meta metav1.Object) *apis.FieldError {
 	return apis.ValidateObjectMetadata(meta).Also(
-		autoscaling.ValidateAnnotations(meta.GetAnnotations()).ViaField("annotations"))
+		autoscaling.ValidateAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateUpgradeAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateQueueServerAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateTracingAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateRolloutAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateReadinessGateAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateRequestAuthenticationAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateTTLAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateMaintenanceAnnotations(meta.GetAnnotations()).ViaField("annotations")).Also(
+		validateRevisionNameTemplate(meta.GetAnnotations()).ViaField("annotations"))
+}
+
+func validateUpgradeAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[MaxUpgradedConnectionsAnnotationKey]; ok {
+		if i, err := strconv.Atoi(v); err != nil || i < 0 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", MaxUpgradedConnectionsAnnotationKey),
+				Paths:   []string{MaxUpgradedConnectionsAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[WebSocketIdleWindowAnnotationKey]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a positive duration", WebSocketIdleWindowAnnotationKey),
+				Paths:   []string{WebSocketIdleWindowAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateQueueServerAnnotations(annotations map[string]string) *apis.FieldError {
+	for _, key := range []string{
+		QueueSideCarMaxHeaderBytesAnnotation,
+		QueueSideCarMaxConnectionsAnnotation,
+		QueueSideCarReadHeaderTimeoutSecondsAnnotation,
+		QueueSideCarRetryBufferBytesAnnotation,
+		QueueSideCarResponseCacheBytesAnnotation,
+		QueueSideCarMaxRequestBytesAnnotation,
+		QueueSideCarMaxResponseBytesAnnotation,
+	} {
+		if v, ok := annotations[key]; ok {
+			if i, err := strconv.ParseInt(v, 10, 64); err != nil || i < 0 {
+				return &apis.FieldError{
+					Message: fmt.Sprintf("Invalid %s annotation value: must be an integer equal or greater than 0", key),
+					Paths:   []string{key},
+				}
+			}
+		}
+	}
+	if v, ok := annotations[RequestWeightHeaderAnnotationKey]; ok && !httpguts.ValidHeaderFieldName(v) {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be a header name", RequestWeightHeaderAnnotationKey),
+			Paths:   []string{RequestWeightHeaderAnnotationKey},
+		}
+	}
+	if v, ok := annotations[RequestCostsAnnotationKey]; ok {
+		if _, err := ParseRequestCosts(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", RequestCostsAnnotationKey, err),
+				Paths:   []string{RequestCostsAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[QueueSideCarPathConcurrencyAnnotation]; ok {
+		if _, err := ParsePathConcurrency(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", QueueSideCarPathConcurrencyAnnotation, err),
+				Paths:   []string{QueueSideCarPathConcurrencyAnnotation},
+			}
+		}
+	}
+	if v, ok := annotations[QueueSideCarRetryMethodsAnnotation]; ok {
+		if _, err := ParseRetryMethods(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", QueueSideCarRetryMethodsAnnotation, err),
+				Paths:   []string{QueueSideCarRetryMethodsAnnotation},
+			}
+		}
+	}
+	if v, ok := annotations[QueueSideCarResponseCacheTTLAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a positive duration", QueueSideCarResponseCacheTTLAnnotation),
+				Paths:   []string{QueueSideCarResponseCacheTTLAnnotation},
+			}
+		}
+	}
+	if v, ok := annotations[QueueDisciplineAnnotationKey]; ok && v != QueueDisciplineFIFO && v != QueueDisciplineLIFO && v != QueueDisciplineAdaptive {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be %s, %s or %s", QueueDisciplineAnnotationKey,
+				QueueDisciplineFIFO, QueueDisciplineLIFO, QueueDisciplineAdaptive),
+			Paths: []string{QueueDisciplineAnnotationKey},
+		}
+	}
+	if v, ok := annotations[L4PortAnnotationKey]; ok {
+		if _, err := ParseL4Port(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", L4PortAnnotationKey, err),
+				Paths:   []string{L4PortAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[OverflowURLAnnotationKey]; ok {
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be an absolute http or https URL", OverflowURLAnnotationKey),
+				Paths:   []string{OverflowURLAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[ClientQuotaAnnotationKey]; ok {
+		if _, err := ParseClientQuota(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", ClientQuotaAnnotationKey, err),
+				Paths:   []string{ClientQuotaAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[StaticAssetsAnnotationKey]; ok {
+		if _, err := ParseStaticAssets(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", StaticAssetsAnnotationKey, err),
+				Paths:   []string{StaticAssetsAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[RateLimitAnnotationKey]; ok {
+		if limit, err := strconv.Atoi(v); err != nil || limit < 1 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be an integer greater than 0", RateLimitAnnotationKey),
+				Paths:   []string{RateLimitAnnotationKey},
+			}
+		}
+	}
+	for _, key := range []string{RequestsPerSecondLimitAnnotationKey, RequestsPerSecondBurstAnnotationKey} {
+		if v, ok := annotations[key]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n < 1 {
+				return &apis.FieldError{
+					Message: fmt.Sprintf("Invalid %s annotation value: must be an integer greater than 0", key),
+					Paths:   []string{key},
+				}
+			}
+		}
+	}
+	if v, ok := annotations[RequestJournalAnnotationKey]; ok && v != RequestJournalMetadata && v != RequestJournalBody {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be %s or %s", RequestJournalAnnotationKey,
+				RequestJournalMetadata, RequestJournalBody),
+			Paths: []string{RequestJournalAnnotationKey},
+		}
+	}
+	if v, ok := annotations[GRPCWebAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a boolean", GRPCWebAnnotationKey),
+				Paths:   []string{GRPCWebAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateTracingAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[TracingSampleRateAnnotationKey]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil || f < 0 || f > 1 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a number in [0, 1]", TracingSampleRateAnnotationKey),
+				Paths:   []string{TracingSampleRateAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[TracingSampleOnErrorAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a boolean", TracingSampleOnErrorAnnotationKey),
+				Paths:   []string{TracingSampleOnErrorAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateRolloutAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[RolloutGateURLAnnotationKey]; ok {
+		if u, err := url.Parse(v); err != nil || !u.IsAbs() {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be an absolute URL", RolloutGateURLAnnotationKey),
+				Paths:   []string{RolloutGateURLAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[RolloutMaxErrorRateAnnotationKey]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil || f < 0 || f > 1 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a number in [0, 1]", RolloutMaxErrorRateAnnotationKey),
+				Paths:   []string{RolloutMaxErrorRateAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[RollbackOnFailureAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a boolean", RollbackOnFailureAnnotationKey),
+				Paths:   []string{RollbackOnFailureAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateReadinessGateAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[ReadinessGatesAnnotationKey]; ok {
+		if _, err := ParseReadinessGates(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", ReadinessGatesAnnotationKey, err),
+				Paths:   []string{ReadinessGatesAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateRequestAuthenticationAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[RequestAuthenticationAnnotationKey]; ok {
+		if _, err := ParseRequestAuthentication(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: %v", RequestAuthenticationAnnotationKey, err),
+				Paths:   []string{RequestAuthenticationAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateTTLAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[TTLAnnotationKey]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a positive duration", TTLAnnotationKey),
+				Paths:   []string{TTLAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[TTLActionAnnotationKey]; ok && v != TTLActionDelete && v != TTLActionHibernate {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must be %s or %s", TTLActionAnnotationKey, TTLActionDelete, TTLActionHibernate),
+			Paths:   []string{TTLActionAnnotationKey},
+		}
+	}
+	return nil
+}
+
+func validateMaintenanceAnnotations(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[MaintenanceModeAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a boolean", MaintenanceModeAnnotationKey),
+				Paths:   []string{MaintenanceModeAnnotationKey},
+			}
+		}
+	}
+	if v, ok := annotations[MaintenancePageContentTypeAnnotationKey]; ok {
+		if _, _, err := mime.ParseMediaType(v); err != nil {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("Invalid %s annotation value: must be a media type", MaintenancePageContentTypeAnnotationKey),
+				Paths:   []string{MaintenancePageContentTypeAnnotationKey},
+			}
+		}
+	}
+	return nil
+}
+
+func validateRevisionNameTemplate(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[RevisionNameTemplateAnnotationKey]
+	if !ok {
+		return nil
+	}
+	tmpl, err := ParseRevisionNameTemplate(v)
+	if err != nil {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: %v", RevisionNameTemplateAnnotationKey, err),
+			Paths:   []string{RevisionNameTemplateAnnotationKey},
+		}
+	}
+	// Every generation has to get a name of its own, which we check by
+	// rendering two of them.
+	var first, second bytes.Buffer
+	if err := tmpl.Execute(&first, RevisionNameData{Generation: 1}); err != nil {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: %v", RevisionNameTemplateAnnotationKey, err),
+			Paths:   []string{RevisionNameTemplateAnnotationKey},
+		}
+	}
+	if err := tmpl.Execute(&second, RevisionNameData{Generation: 2}); err != nil || first.String() == second.String() {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Invalid %s annotation value: must render a different name for every generation", RevisionNameTemplateAnnotationKey),
+			Paths:   []string{RevisionNameTemplateAnnotationKey},
+		}
+	}
+	return nil
 }
@@ -87,6 +87,786 @@ func TestValidateObjectMetadata(t *testing.T) {
 			Message: "not a DNS 1035 label prefix: [must be no more than 63 characters]",
 			Paths:   []string{"generateName"},
 		},
+	}, {
+		name: "valid upgrade annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				AllowedUpgradeProtocolsAnnotationKey: "websocket",
+				MaxUpgradedConnectionsAnnotationKey:  "10",
+				WebSocketIdleWindowAnnotationKey:     "5m",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid max upgraded connections",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				MaxUpgradedConnectionsAnnotationKey: "-1",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/maxUpgradedConnections annotation value: must be an integer equal or greater than 0",
+			Paths:   []string{"annotations.serving.knative.dev/maxUpgradedConnections"},
+		}),
+	}, {
+		name: "invalid websocket idle window",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				WebSocketIdleWindowAnnotationKey: "0s",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/webSocketIdleWindow annotation value: must be a positive duration",
+			Paths:   []string{"annotations.serving.knative.dev/webSocketIdleWindow"},
+		}),
+	}, {
+		name: "valid queue server annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarMaxHeaderBytesAnnotation:           "65536",
+				QueueSideCarMaxConnectionsAnnotation:           "0",
+				QueueSideCarReadHeaderTimeoutSecondsAnnotation: "10",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid queue read header timeout",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarReadHeaderTimeoutSecondsAnnotation: "10s",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid queue.sidecar.serving.knative.dev/readHeaderTimeoutSeconds annotation value: must be an integer equal or greater than 0",
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/readHeaderTimeoutSeconds"},
+		}),
+	}, {
+		name: "valid tracing annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TracingSampleRateAnnotationKey:    "1",
+				TracingSampleOnErrorAnnotationKey: "true",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid tracing sample rate",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TracingSampleRateAnnotationKey: "1.5",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/tracingSampleRate annotation value: must be a number in [0, 1]",
+			Paths:   []string{"annotations.serving.knative.dev/tracingSampleRate"},
+		}),
+	}, {
+		name: "invalid tracing sample on error",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TracingSampleOnErrorAnnotationKey: "sometimes",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/tracingSampleOnError annotation value: must be a boolean",
+			Paths:   []string{"annotations.serving.knative.dev/tracingSampleOnError"},
+		}),
+	}, {
+		name: "valid rollout annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RolloutGateURLAnnotationKey:      "http://analysis.default.svc/check",
+				RolloutMaxErrorRateAnnotationKey: "0.05",
+				RollbackOnFailureAnnotationKey:   "true",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "relative rollout gate URL",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RolloutGateURLAnnotationKey: "/check",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/rolloutGateURL annotation value: must be an absolute URL",
+			Paths:   []string{"annotations.serving.knative.dev/rolloutGateURL"},
+		}),
+	}, {
+		name: "invalid rollout max error rate",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RolloutMaxErrorRateAnnotationKey: "5%",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/rolloutMaxErrorRate annotation value: must be a number in [0, 1]",
+			Paths:   []string{"annotations.serving.knative.dev/rolloutMaxErrorRate"},
+		}),
+	}, {
+		name: "invalid rollback on failure",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RollbackOnFailureAnnotationKey: "yes",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/rollbackOnFailure annotation value: must be a boolean",
+			Paths:   []string{"annotations.serving.knative.dev/rollbackOnFailure"},
+		}),
+	}, {
+		name: "valid ttl annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TTLAnnotationKey:       "72h",
+				TTLActionAnnotationKey: TTLActionHibernate,
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "negative ttl",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TTLAnnotationKey: "-1h",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/ttl annotation value: must be a positive duration",
+			Paths:   []string{"annotations.serving.knative.dev/ttl"},
+		}),
+	}, {
+		name: "invalid ttl action",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				TTLActionAnnotationKey: "Scale",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/ttlAction annotation value: must be Delete or Hibernate",
+			Paths:   []string{"annotations.serving.knative.dev/ttlAction"},
+		}),
+	}, {
+		name: "valid maintenance annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				MaintenanceModeAnnotationKey:            "true",
+				MaintenancePageAnnotationKey:            `{"error": "maintenance"}`,
+				MaintenancePageContentTypeAnnotationKey: "application/json",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid maintenance mode",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				MaintenanceModeAnnotationKey: "soon",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/maintenanceMode annotation value: must be a boolean",
+			Paths:   []string{"annotations.serving.knative.dev/maintenanceMode"},
+		}),
+	}, {
+		name: "invalid maintenance page content type",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				MaintenancePageContentTypeAnnotationKey: "text/",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/maintenancePageContentType annotation value: must be a media type",
+			Paths:   []string{"annotations.serving.knative.dev/maintenancePageContentType"},
+		}),
+	}, {
+		name: "valid request weight header",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestWeightHeaderAnnotationKey: "X-Batch-Size",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid request weight header",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestWeightHeaderAnnotationKey: "batch size",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestWeightHeader annotation value: must be a header name",
+			Paths:   []string{"annotations.serving.knative.dev/requestWeightHeader"},
+		}),
+	}, {
+		name: "valid request costs",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestCostsAnnotationKey: "POST /v1/predict=4, /static/*=1, bytes=1Mi",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid request cost",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestCostsAnnotationKey: "/healthz=0",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/requestCosts annotation value: cost of entry "/healthz=0" must be an integer greater than 0`,
+			Paths:   []string{"annotations.serving.knative.dev/requestCosts"},
+		}),
+	}, {
+		name: "valid client quota",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ClientQuotaAnnotationKey: "header=X-Api-Key, concurrency=10, rps=100",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "client quota without limits",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ClientQuotaAnnotationKey: "header=X-Api-Key",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/clientQuota annotation value: at least one of concurrency and rps must be set",
+			Paths:   []string{"annotations.serving.knative.dev/clientQuota"},
+		}),
+	}, {
+		name: "invalid client quota rps",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ClientQuotaAnnotationKey: "rps=fast",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/clientQuota annotation value: rps must be an integer greater than 0, was "fast"`,
+			Paths:   []string{"annotations.serving.knative.dev/clientQuota"},
+		}),
+	}, {
+		name: "valid rate limit",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RateLimitAnnotationKey: "100",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid rate limit",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RateLimitAnnotationKey: "0",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/rateLimit annotation value: must be an integer greater than 0",
+			Paths:   []string{"annotations.serving.knative.dev/rateLimit"},
+		}),
+	}, {
+		name: "valid requests per second limit",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestsPerSecondLimitAnnotationKey: "50",
+				RequestsPerSecondBurstAnnotationKey: "100",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid requests per second burst",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestsPerSecondLimitAnnotationKey: "50",
+				RequestsPerSecondBurstAnnotationKey: "lots",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestsPerSecondBurst annotation value: must be an integer greater than 0",
+			Paths:   []string{"annotations.serving.knative.dev/requestsPerSecondBurst"},
+		}),
+	}, {
+		name: "valid static assets",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				StaticAssetsAnnotationKey: "paths=/static/* /favicon.ico, configMap=site-assets",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid static assets path",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				StaticAssetsAnnotationKey: "paths=/static/*.css, url=https://assets.example.com",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/staticAssets annotation value: path "/static/*.css" must start with / and may only end with /*`,
+			Paths:   []string{"annotations.serving.knative.dev/staticAssets"},
+		}),
+	}, {
+		name: "static assets with two sources",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				StaticAssetsAnnotationKey: "paths=/favicon.ico, configMap=site-assets, url=https://assets.example.com",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/staticAssets annotation value: exactly one of configMap and url must be set",
+			Paths:   []string{"annotations.serving.knative.dev/staticAssets"},
+		}),
+	}, {
+		name: "valid readiness gates",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ReadinessGatesAnnotationKey: "secret=db-credentials, url=http://flags.default.svc/ready, condition=SchemaMigrated",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "readiness gate with a relative url",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ReadinessGatesAnnotationKey: "url=/ready",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/readinessGates annotation value: url must be an absolute http or https URL, was "/ready"`,
+			Paths:   []string{"annotations.serving.knative.dev/readinessGates"},
+		}),
+	}, {
+		name: "readiness gate on the ready condition",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ReadinessGatesAnnotationKey: "condition=Ready",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/readinessGates annotation value: condition "Ready" can't gate the Revision`,
+			Paths:   []string{"annotations.serving.knative.dev/readinessGates"},
+		}),
+	}, {
+		name: "empty readiness gates",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				ReadinessGatesAnnotationKey: " , ",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/readinessGates annotation value: at least one gate must be set",
+			Paths:   []string{"annotations.serving.knative.dev/readinessGates"},
+		}),
+	}, {
+		name: "valid request authentication",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestAuthenticationAnnotationKey: "jwksURL=https://auth.example.com/.well-known/jwks.json, issuer=https://auth.example.com, audiences=hello world, clientSANs=spiffe://cluster.local/ns/default/sa/frontend, exemptPaths=/healthz /public/*",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "request authentication with both key sets",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestAuthenticationAnnotationKey: "jwksURL=https://auth.example.com/jwks.json, jwksSecret=hello-jwks",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestAuthentication annotation value: at most one of jwksURL and jwksSecret may be set",
+			Paths:   []string{"annotations.serving.knative.dev/requestAuthentication"},
+		}),
+	}, {
+		name: "request authentication of audiences without key set",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestAuthenticationAnnotationKey: "audiences=hello, clientSANs=*",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestAuthentication annotation value: issuer and audiences need jwksURL or jwksSecret to be set",
+			Paths:   []string{"annotations.serving.knative.dev/requestAuthentication"},
+		}),
+	}, {
+		name: "request authentication without checks",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestAuthenticationAnnotationKey: "exemptPaths=/healthz",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestAuthentication annotation value: at least one of jwksURL, jwksSecret and clientSANs must be set",
+			Paths:   []string{"annotations.serving.knative.dev/requestAuthentication"},
+		}),
+	}, {
+		name: "valid path concurrency",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarPathConcurrencyAnnotation: "/report=2, /export/=1",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid path concurrency prefix",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarPathConcurrencyAnnotation: "report=2",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid queue.sidecar.serving.knative.dev/pathConcurrency annotation value: prefix of entry "report=2" must be a path starting with /`,
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/pathConcurrency"},
+		}),
+	}, {
+		name: "invalid path concurrency value",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarPathConcurrencyAnnotation: "/report=0",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid queue.sidecar.serving.knative.dev/pathConcurrency annotation value: concurrency of entry "/report=0" must be an integer greater than 0`,
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/pathConcurrency"},
+		}),
+	}, {
+		name: "duplicate path concurrency prefix",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarPathConcurrencyAnnotation: "/report=1,/report=2",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid queue.sidecar.serving.knative.dev/pathConcurrency annotation value: prefix "/report" is listed more than once`,
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/pathConcurrency"},
+		}),
+	}, {
+		name: "valid retry annotations",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarRetryBufferBytesAnnotation: "65536",
+				QueueSideCarRetryMethodsAnnotation:     "PUT, DELETE",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid retry buffer bytes",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarRetryBufferBytesAnnotation: "64Ki",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid queue.sidecar.serving.knative.dev/retryBufferBytes annotation value: must be an integer equal or greater than 0",
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/retryBufferBytes"},
+		}),
+	}, {
+		name: "invalid retry method",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarRetryMethodsAnnotation: "PUT, POST /",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid queue.sidecar.serving.knative.dev/retryMethods annotation value: "POST /" is not an HTTP method`,
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/retryMethods"},
+		}),
+	}, {
+		name: "valid response cache",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarResponseCacheBytesAnnotation: "10485760",
+				QueueSideCarResponseCacheTTLAnnotation:   "30s",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid response cache bytes",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarResponseCacheBytesAnnotation: "10Mi",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid queue.sidecar.serving.knative.dev/responseCacheBytes annotation value: must be an integer equal or greater than 0",
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/responseCacheBytes"},
+		}),
+	}, {
+		name: "invalid response cache ttl",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarResponseCacheTTLAnnotation: "0s",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid queue.sidecar.serving.knative.dev/responseCacheTTL annotation value: must be a positive duration",
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/responseCacheTTL"},
+		}),
+	}, {
+		name: "valid body size limits",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarMaxRequestBytesAnnotation:  "1048576",
+				QueueSideCarMaxResponseBytesAnnotation: "0",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid max response bytes",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueSideCarMaxResponseBytesAnnotation: "-1",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid queue.sidecar.serving.knative.dev/maxResponseBytes annotation value: must be an integer equal or greater than 0",
+			Paths:   []string{"annotations.queue.sidecar.serving.knative.dev/maxResponseBytes"},
+		}),
+	}, {
+		name: "invalid request cost path",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestCostsAnnotationKey: "GET predict=2",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/requestCosts annotation value: path of entry "GET predict=2" must start with /`,
+			Paths:   []string{"annotations.serving.knative.dev/requestCosts"},
+		}),
+	}, {
+		name: "invalid request cost bytes",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestCostsAnnotationKey: "bytes=lots",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/requestCosts annotation value: bytes must be a positive quantity, was "lots"`,
+			Paths:   []string{"annotations.serving.knative.dev/requestCosts"},
+		}),
+	}, {
+		name: "valid queue discipline",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueDisciplineAnnotationKey: QueueDisciplineAdaptive,
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid queue discipline",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				QueueDisciplineAnnotationKey: "lifo",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/queueDiscipline annotation value: must be FIFO, LIFO or Adaptive",
+			Paths:   []string{"annotations.serving.knative.dev/queueDiscipline"},
+		}),
+	}, {
+		name: "valid tcp l4 port",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				L4PortAnnotationKey: "6379",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "valid udp l4 port",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				L4PortAnnotationKey: "5683/udp",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid l4 protocol",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				L4PortAnnotationKey: "6379/SCTP",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/l4Port annotation value: protocol must be TCP or UDP, was "SCTP"`,
+			Paths:   []string{"annotations.serving.knative.dev/l4Port"},
+		}),
+	}, {
+		name: "invalid l4 port",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				L4PortAnnotationKey: "70000/TCP",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/l4Port annotation value: port must be between 1 and 65535, was "70000"`,
+			Paths:   []string{"annotations.serving.knative.dev/l4Port"},
+		}),
+	}, {
+		name: "reserved l4 port",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				L4PortAnnotationKey: "8012",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/l4Port annotation value: port 8012 is reserved for the queue-proxy",
+			Paths:   []string{"annotations.serving.knative.dev/l4Port"},
+		}),
+	}, {
+		name: "valid overflow url",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				OverflowURLAnnotationKey: "http://fallback.default.svc.cluster.local",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid overflow url",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				OverflowURLAnnotationKey: "fallback.default.svc.cluster.local",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/overflowURL annotation value: must be an absolute http or https URL",
+			Paths:   []string{"annotations.serving.knative.dev/overflowURL"},
+		}),
+	}, {
+		name: "valid request journal",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestJournalAnnotationKey: RequestJournalBody,
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid request journal",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RequestJournalAnnotationKey: "all",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/requestJournal annotation value: must be metadata or body",
+			Paths:   []string{"annotations.serving.knative.dev/requestJournal"},
+		}),
+	}, {
+		name: "valid grpc-web",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				GRPCWebAnnotationKey: "true",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "invalid grpc-web",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				GRPCWebAnnotationKey: "browsers",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/grpcWeb annotation value: must be a boolean",
+			Paths:   []string{"annotations.serving.knative.dev/grpcWeb"},
+		}),
+	}, {
+		name: "valid revision name template",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RevisionNameTemplateAnnotationKey: `{{.Config}}-{{.Generation}}-{{index .Annotations "example.com/git-sha"}}`,
+			},
+		},
+		expectErr: (*apis.FieldError)(nil),
+	}, {
+		name: "unparsable revision name template",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RevisionNameTemplateAnnotationKey: "{{.Config}}-{{.Generation}}{{end}}",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: `Invalid serving.knative.dev/revisionNameTemplate annotation value: template: serving.knative.dev/revisionNameTemplate:1: unexpected {{end}}`,
+			Paths:   []string{"annotations.serving.knative.dev/revisionNameTemplate"},
+		}),
+	}, {
+		name: "revision name template without generation",
+		objectMeta: &metav1.ObjectMeta{
+			Name: "some-name",
+			Annotations: map[string]string{
+				RevisionNameTemplateAnnotationKey: "{{.Config}}-stable",
+			},
+		},
+		expectErr: (*apis.FieldError)(nil).Also(&apis.FieldError{
+			Message: "Invalid serving.knative.dev/revisionNameTemplate annotation value: must render a different name for every generation",
+			Paths:   []string{"annotations.serving.knative.dev/revisionNameTemplate"},
+		}),
 	}, {
 		name:       "missing name and generateName",
 		objectMeta: &metav1.ObjectMeta{},
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathConcurrency are the concurrency limits of the requests to a Revision
+// by the prefix of their path, as declared by the
+// QueueSideCarPathConcurrencyAnnotation annotation.
+type PathConcurrency map[string]int
+
+// ParsePathConcurrency parses the value of the path concurrency annotation,
+// a comma separated list of "PREFIX=CONCURRENCY" entries, e.g.
+// "/report=2, /export/=1".
+func ParsePathConcurrency(v string) (PathConcurrency, error) {
+	c := PathConcurrency{}
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("entry %q must be of the form PREFIX=CONCURRENCY", entry)
+		}
+		prefix, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " \t") {
+			return nil, fmt.Errorf("prefix of entry %q must be a path starting with /", entry)
+		}
+		if _, ok := c[prefix]; ok {
+			return nil, fmt.Errorf("prefix %q is listed more than once", prefix)
+		}
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency < 1 {
+			return nil, fmt.Errorf("concurrency of entry %q must be an integer greater than 0", entry)
+		}
+		c[prefix] = concurrency
+	}
+	return c, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ReadinessGate is an external dependency of a Revision, as declared by the
+// ReadinessGatesAnnotationKey annotation, that has to be ready before the
+// Revision is. Exactly one of its fields is set.
+type ReadinessGate struct {
+	// URL passes once it answers a GET with a 2xx status.
+	URL *url.URL
+	// Secret passes once the Secret of that name exists in the Revision's
+	// namespace.
+	Secret string
+	// Condition passes once the Revision has a True condition of that
+	// type, which another controller sets.
+	Condition string
+}
+
+// ParseReadinessGates parses the value of the readiness gates annotation, a
+// comma separated list of "url=URL", "secret=NAME" and "condition=TYPE"
+// entries, e.g. "secret=db-credentials, url=http://flags.default.svc/ready".
+func ParseReadinessGates(v string) ([]ReadinessGate, error) {
+	var gates []ReadinessGate
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("entry %q must be of the form KEY=VALUE", entry)
+		}
+		key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		switch key {
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("url must be an absolute http or https URL, was %q", value)
+			}
+			gates = append(gates, ReadinessGate{URL: u})
+		case "secret":
+			if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+				return nil, fmt.Errorf("secret must be a Secret name, was %q: %s", value, strings.Join(errs, ", "))
+			}
+			gates = append(gates, ReadinessGate{Secret: value})
+		case "condition":
+			if value == "" || strings.ContainsAny(value, " \t") {
+				return nil, fmt.Errorf("condition must be a condition type, was %q", value)
+			}
+			// These depend on the gates themselves, so they'd never pass.
+			if value == "Ready" || value == "DependenciesReady" {
+				return nil, fmt.Errorf("condition %q can't gate the Revision", value)
+			}
+			gates = append(gates, ReadinessGate{Condition: value})
+		default:
+			return nil, fmt.Errorf("unknown entry %q", entry)
+		}
+	}
+	if len(gates) == 0 {
+		return nil, fmt.Errorf("at least one gate must be set")
+	}
+	return gates, nil
+}
@@ -53,6 +53,14 @@ const (
 	// metadata generation of the Configuration that created this revision
 	ConfigurationGenerationLabelKey = GroupName + "/configurationGeneration"
 
+	// ReportLabelKey is the label key attached to the ConfigMap summarizing
+	// the Services of a namespace, to tell it apart from the user's own.
+	ReportLabelKey = GroupName + "/report"
+
+	// StaticAssetsLabelKey is the label key the ConfigMaps holding the
+	// static assets of Revisions must carry for the activator to see them.
+	StaticAssetsLabelKey = GroupName + "/staticAssets"
+
 	// CreatorAnnotation is the annotation key to describe the user that
 	// created the resource.
 	CreatorAnnotation = GroupName + "/creator"
@@ -63,4 +71,336 @@ const (
 	// QueueSideCarResourcePercentageAnnotation is the percentage of user container resources to be used for queue-proxy
 	// It has to be in [0.1,100]
 	QueueSideCarResourcePercentageAnnotation = "queue.sidecar." + GroupName + "/resourcePercentage"
+
+	// QueueSideCarLoggingLevelAnnotation is the pod annotation to change the
+	// logging level of a running queue-proxy, when config reloading is enabled.
+	// For example,
+	//   queue.sidecar.serving.knative.dev/loggingLevel: "debug"
+	QueueSideCarLoggingLevelAnnotation = "queue.sidecar." + GroupName + "/loggingLevel"
+
+	// QueueSideCarTimeoutSecondsAnnotation is the pod annotation to change the
+	// request timeout of a running queue-proxy, when config reloading is enabled.
+	// For example,
+	//   queue.sidecar.serving.knative.dev/timeoutSeconds: "120"
+	QueueSideCarTimeoutSecondsAnnotation = "queue.sidecar." + GroupName + "/timeoutSeconds"
+
+	// QueueSideCarMaxHeaderBytesAnnotation is the annotation to limit the
+	// size of the request headers the queue-proxy reads, overriding the
+	// queue-max-header-bytes of config-defaults. For example,
+	//   queue.sidecar.serving.knative.dev/maxHeaderBytes: "65536"
+	QueueSideCarMaxHeaderBytesAnnotation = "queue.sidecar." + GroupName + "/maxHeaderBytes"
+
+	// QueueSideCarMaxConnectionsAnnotation is the annotation to limit the
+	// number of connections the queue-proxy of each pod accepts at the same
+	// time, overriding the queue-max-connections of config-defaults.
+	// For example,
+	//   queue.sidecar.serving.knative.dev/maxConnections: "1000"
+	QueueSideCarMaxConnectionsAnnotation = "queue.sidecar." + GroupName + "/maxConnections"
+
+	// QueueSideCarReadHeaderTimeoutSecondsAnnotation is the annotation to
+	// limit the time the queue-proxy waits for the headers of a request,
+	// overriding the queue-read-header-timeout-seconds of config-defaults.
+	// For example,
+	//   queue.sidecar.serving.knative.dev/readHeaderTimeoutSeconds: "10"
+	QueueSideCarReadHeaderTimeoutSecondsAnnotation = "queue.sidecar." + GroupName + "/readHeaderTimeoutSeconds"
+
+	// QueueSideCarHeaderPolicyAnnotation is the annotation on a Namespace
+	// that replaces the queueSidecarHeaderPolicy of config-deployment for
+	// the revisions in that Namespace. For example,
+	//   queue.sidecar.serving.knative.dev/headerPolicy: "Knative-*,X-Forwarded-Proto=https"
+	QueueSideCarHeaderPolicyAnnotation = "queue.sidecar." + GroupName + "/headerPolicy"
+
+	// QueueSideCarPathConcurrencyAnnotation is the annotation of a Revision
+	// to limit the concurrency of the requests by the prefix of their path,
+	// as parsed by ParsePathConcurrency, on top of the containerConcurrency
+	// they share. The requests of the longest matching prefix wait for their
+	// own slots before taking one of the containerConcurrency, so that a
+	// slow endpoint can't starve the others. For example,
+	//   queue.sidecar.serving.knative.dev/pathConcurrency: "/report=2, /export/=1"
+	QueueSideCarPathConcurrencyAnnotation = "queue.sidecar." + GroupName + "/pathConcurrency"
+
+	// QueueSideCarRetryBufferBytesAnnotation is the annotation to let the
+	// queue-proxy retry a request once, when the user container went away
+	// before answering it, e.g. because it crashed and is being restarted.
+	// The body of the request is buffered up to the given number of bytes
+	// to be sent again; requests with larger bodies aren't retried. Only
+	// GET and HEAD requests, and those with a method of the
+	// QueueSideCarRetryMethodsAnnotation, are retried. For example,
+	//   queue.sidecar.serving.knative.dev/retryBufferBytes: "65536"
+	// The value 0 or the absence of the annotation disables the retries.
+	QueueSideCarRetryBufferBytesAnnotation = "queue.sidecar." + GroupName + "/retryBufferBytes"
+
+	// QueueSideCarRetryMethodsAnnotation is the annotation to list the
+	// methods of the requests the queue-proxy may retry on top of GET and
+	// HEAD, as parsed by ParseRetryMethods, e.g. because the application
+	// makes them idempotent. For example,
+	//   queue.sidecar.serving.knative.dev/retryMethods: "PUT, DELETE"
+	QueueSideCarRetryMethodsAnnotation = "queue.sidecar." + GroupName + "/retryMethods"
+
+	// QueueSideCarResponseCacheBytesAnnotation is the annotation to let the
+	// queue-proxy answer repeated GET and HEAD requests from an in-memory
+	// LRU cache of the responses of the user container, of at most the
+	// given number of bytes. Only the responses with a Cache-Control of
+	// public, max-age or s-maxage are cached, apart for each value of
+	// their Vary headers. Those with cookies or a Cache-Control of
+	// no-store, no-cache or private, and the responses to requests with
+	// credentials, cookies or an authenticated identity, aren't. For example,
+	//   queue.sidecar.serving.knative.dev/responseCacheBytes: "10485760"
+	// The value 0 or the absence of the annotation disables the cache.
+	QueueSideCarResponseCacheBytesAnnotation = "queue.sidecar." + GroupName + "/responseCacheBytes"
+
+	// QueueSideCarResponseCacheTTLAnnotation is the annotation to set for
+	// how long the queue-proxy answers from a cached response, unless its
+	// max-age is shorter. For example,
+	//   queue.sidecar.serving.knative.dev/responseCacheTTL: "30s"
+	// It defaults to 10 seconds.
+	QueueSideCarResponseCacheTTLAnnotation = "queue.sidecar." + GroupName + "/responseCacheTTL"
+
+	// QueueSideCarMaxRequestBytesAnnotation is the annotation to limit the
+	// size of the request bodies the queue-proxy forwards to the user
+	// container. The larger requests are failed with a 413. For example,
+	//   queue.sidecar.serving.knative.dev/maxRequestBytes: "1048576"
+	// The value 0 or the absence of the annotation means unlimited.
+	QueueSideCarMaxRequestBytesAnnotation = "queue.sidecar." + GroupName + "/maxRequestBytes"
+
+	// QueueSideCarMaxResponseBytesAnnotation is the annotation to limit the
+	// size of the response bodies the queue-proxy forwards from the user
+	// container. The larger responses are failed with a 502, or cut short
+	// if their size isn't known before they are sent. For example,
+	//   queue.sidecar.serving.knative.dev/maxResponseBytes: "10485760"
+	// The value 0 or the absence of the annotation means unlimited.
+	QueueSideCarMaxResponseBytesAnnotation = "queue.sidecar." + GroupName + "/maxResponseBytes"
+
+	// AllowedUpgradeProtocolsAnnotationKey is the annotation to restrict the
+	// protocols a request may be upgraded to (e.g. via WebSocket handshakes)
+	// when passing through the activator and queue-proxy. For example,
+	//   serving.knative.dev/allowedUpgradeProtocols: "websocket,h2c"
+	// All protocols are allowed if the annotation is absent.
+	AllowedUpgradeProtocolsAnnotationKey = GroupName + "/allowedUpgradeProtocols"
+
+	// MaxUpgradedConnectionsAnnotationKey is the annotation to limit the
+	// number of upgraded connections a component in the data path keeps
+	// open for a revision at the same time. For example,
+	//   serving.knative.dev/maxUpgradedConnections: "100"
+	// The value 0 or the absence of the annotation means unlimited.
+	MaxUpgradedConnectionsAnnotationKey = GroupName + "/maxUpgradedConnections"
+
+	// WebSocketIdleWindowAnnotationKey is the annotation to stop counting a
+	// WebSocket connection towards the concurrency of a revision once no
+	// data frames went through it for the given duration, so that idle
+	// connections don't keep the revision from scaling to zero. For example,
+	//   serving.knative.dev/webSocketIdleWindow: "5m"
+	// WebSocket connections count for as long as they are open by default.
+	WebSocketIdleWindowAnnotationKey = GroupName + "/webSocketIdleWindow"
+
+	// TracingSampleRateAnnotationKey is the annotation to override the
+	// sample-rate of config-tracing for the requests to a revision. It has
+	// to be in [0, 1]. For example,
+	//   serving.knative.dev/tracingSampleRate: "1.0"
+	TracingSampleRateAnnotationKey = GroupName + "/tracingSampleRate"
+
+	// TracingSampleOnErrorAnnotationKey is the annotation to report the
+	// requests to a revision that fail with a 5xx status, even when they
+	// were not sampled. For example,
+	//   serving.knative.dev/tracingSampleOnError: "true"
+	TracingSampleOnErrorAnnotationKey = GroupName + "/tracingSampleOnError"
+
+	// RolloutGateURLAnnotationKey is the annotation of a Route (or Service)
+	// to have an external analysis service approve every shift of its
+	// traffic towards a revision. For example,
+	//   serving.knative.dev/rolloutGateURL: "http://analysis.default.svc/check"
+	RolloutGateURLAnnotationKey = GroupName + "/rolloutGateURL"
+
+	// RolloutMaxErrorRateAnnotationKey is the annotation of a Route (or
+	// Service) to deny the shifts of its traffic towards a revision whose
+	// error rate is above the given fraction. For example,
+	//   serving.knative.dev/rolloutMaxErrorRate: "0.05"
+	RolloutMaxErrorRateAnnotationKey = GroupName + "/rolloutMaxErrorRate"
+
+	// RollbackOnFailureAnnotationKey is the annotation of a Route (or
+	// Service) to shift the traffic of its Configurations back to their
+	// previous ready revision, when their latest ready revision fails.
+	// For example,
+	//   serving.knative.dev/rollbackOnFailure: "true"
+	RollbackOnFailureAnnotationKey = GroupName + "/rollbackOnFailure"
+
+	// TTLAnnotationKey is the annotation of a Service to act on it once the
+	// given duration has passed since the annotation was first seen, e.g.
+	// to clean up preview deployments. The Service is always warned about
+	// it for a while before. For example,
+	//   serving.knative.dev/ttl: "72h"
+	TTLAnnotationKey = GroupName + "/ttl"
+
+	// TTLActionAnnotationKey is the annotation of a Service to choose what
+	// happens once its ttl expires. It is one of TTLActionDelete (the
+	// default) and TTLActionHibernate.
+	TTLActionAnnotationKey = GroupName + "/ttlAction"
+
+	// MaintenanceModeAnnotationKey is the annotation of a Revision to have
+	// its queue-proxies answer all requests with a static page, without
+	// forwarding them to the user container. For example,
+	//   serving.knative.dev/maintenanceMode: "true"
+	MaintenanceModeAnnotationKey = GroupName + "/maintenanceMode"
+
+	// MaintenancePageAnnotationKey is the annotation to customize the body
+	// of the responses of a Revision in maintenance mode. For example,
+	//   serving.knative.dev/maintenancePage: "<h1>Back soon</h1>"
+	MaintenancePageAnnotationKey = GroupName + "/maintenancePage"
+
+	// MaintenancePageContentTypeAnnotationKey is the annotation to set the
+	// Content-Type of the maintenance page. It is detected from the page
+	// when absent. For example,
+	//   serving.knative.dev/maintenancePageContentType: "application/json"
+	MaintenancePageContentTypeAnnotationKey = GroupName + "/maintenancePageContentType"
+
+	// RequestWeightHeaderAnnotationKey is the annotation of a Revision to
+	// name the request header holding the number of concurrency slots a
+	// request takes, e.g. for expensive streams or batches. Requests without
+	// a valid weight take one slot. The weight is raised to the cost of the
+	// request and capped at the containerConcurrency. For example,
+	//   serving.knative.dev/requestWeightHeader: "X-Batch-Size"
+	RequestWeightHeaderAnnotationKey = GroupName + "/requestWeightHeader"
+
+	// RequestCostsAnnotationKey is the annotation of a Revision to have
+	// requests take a number of concurrency slots by their method, path and
+	// body size, as parsed by ParseRequestCosts, e.g. for expensive endpoints
+	// to take more of the containerConcurrency than trivial ones. A larger
+	// weight in the RequestWeightHeaderAnnotationKey header takes precedence
+	// over the rules. For example,
+	//   serving.knative.dev/requestCosts: "POST /v1/predict=4, /static/*=1, bytes=1Mi"
+	RequestCostsAnnotationKey = GroupName + "/requestCosts"
+
+	// QueueDisciplineAnnotationKey is the annotation of a Revision to
+	// choose the order in which its queue-proxies let queued requests
+	// through: QueueDisciplineFIFO (the default), QueueDisciplineLIFO or
+	// QueueDisciplineAdaptive. For example,
+	//   serving.knative.dev/queueDiscipline: "LIFO"
+	QueueDisciplineAnnotationKey = GroupName + "/queueDiscipline"
+
+	// RevisionNameTemplateAnnotationKey is the annotation of a Configuration
+	// (or Service) to name the Revisions it stamps out after a Go template
+	// of RevisionNameData, rather than with a generated suffix. It has to
+	// refer to the generation, for the names to be unique. For example,
+	//   serving.knative.dev/revisionNameTemplate: '{{.Config}}-{{.Generation}}-{{index .Annotations "example.com/git-sha"}}'
+	// A Revision named in the template itself takes precedence.
+	RevisionNameTemplateAnnotationKey = GroupName + "/revisionNameTemplate"
+
+	// L4PortAnnotationKey is the experimental annotation of a Revision to
+	// declare a TCP or UDP port of its container serving a protocol other
+	// than HTTP. Its queue-proxies proxy the port on networking.BackendL4Port
+	// and autoscale the Revision by the number of connections, or of UDP
+	// clients. The protocol is TCP when omitted. For example,
+	//   serving.knative.dev/l4Port: "6379/TCP"
+	L4PortAnnotationKey = GroupName + "/l4Port"
+
+	// OverflowURLAnnotationKey is the annotation of a Revision to have its
+	// queue-proxies proxy the requests they can't queue anymore to another
+	// target, e.g. a fallback Revision, rather than fail them with a 503.
+	// The target must not overflow back to the Revision. For example,
+	//   serving.knative.dev/overflowURL: "http://fallback.default.svc.cluster.local"
+	OverflowURLAnnotationKey = GroupName + "/overflowURL"
+
+	// GRPCWebAnnotationKey is the annotation of a Revision to have its
+	// queue-proxies translate the grpc-web requests of browsers to gRPC for
+	// the user container, and the responses back. For example,
+	//   serving.knative.dev/grpcWeb: "true"
+	GRPCWebAnnotationKey = GroupName + "/grpcWeb"
+
+	// RequestJournalAnnotationKey is the annotation of a Revision to have
+	// the activator journal the requests it fails for platform reasons
+	// while the Revision scales from zero, for operators to replay them:
+	// RequestJournalMetadata or RequestJournalBody. The activator must be
+	// configured with a journal. For example,
+	//   serving.knative.dev/requestJournal: "body"
+	RequestJournalAnnotationKey = GroupName + "/requestJournal"
+
+	// ClientQuotaAnnotationKey is the annotation of a Revision to limit the
+	// concurrency and rate of the requests of each of its clients, as
+	// parsed by ParseClientQuota. The queue-proxies share the usage of the
+	// clients through the autoscaler, so that the quotas hold for the
+	// Revision rather than for each pod. For example,
+	//   serving.knative.dev/clientQuota: "header=X-Api-Key, concurrency=10, rps=100"
+	ClientQuotaAnnotationKey = GroupName + "/clientQuota"
+
+	// RateLimitAnnotationKey is the annotation of a Revision to limit the
+	// number of requests per second the activators let through to it, as
+	// a whole rather than per pod or activator. Requests over the limit are
+	// answered with 429 Too Many Requests. It only applies to the requests
+	// the activators are in the path of. For example,
+	//   serving.knative.dev/rateLimit: "100"
+	RateLimitAnnotationKey = GroupName + "/rateLimit"
+
+	// RequestsPerSecondLimitAnnotationKey is the annotation of a Revision to
+	// limit the number of requests per second each of its queue-proxies lets
+	// through to the user container, as a token bucket, for handlers fast
+	// enough for the container concurrency not to bound their rate. Requests
+	// over the limit are answered with 429 Too Many Requests. For example,
+	//   serving.knative.dev/requestsPerSecondLimit: "50"
+	RequestsPerSecondLimitAnnotationKey = GroupName + "/requestsPerSecondLimit"
+
+	// RequestsPerSecondBurstAnnotationKey is the annotation of a Revision to
+	// set the burst of requests its queue-proxies let through above the
+	// RequestsPerSecondLimitAnnotationKey rate, which it defaults to.
+	// For example,
+	//   serving.knative.dev/requestsPerSecondBurst: "100"
+	RequestsPerSecondBurstAnnotationKey = GroupName + "/requestsPerSecondBurst"
+
+	// StaticAssetsAnnotationKey is the annotation of a Revision to have the
+	// activator serve some of its paths itself, from a ConfigMap or an
+	// object store, as parsed by ParseStaticAssets, so that requests for
+	// e.g. a favicon don't wake the Revision from zero. It only applies to
+	// the requests the activator is in the path of. For example,
+	//   serving.knative.dev/staticAssets: "paths=/static/* /favicon.ico, url=https://assets.example.com/site"
+	StaticAssetsAnnotationKey = GroupName + "/staticAssets"
+
+	// ReadinessGatesAnnotationKey is the annotation of a Revision to hold
+	// back its readiness until its external dependencies are ready, as
+	// parsed by ParseReadinessGates, so that it doesn't take traffic before
+	// e.g. its database is migrated. Revisions that were already ready
+	// aren't gated. For example,
+	//   serving.knative.dev/readinessGates: "secret=db-credentials, condition=SchemaMigrated"
+	ReadinessGatesAnnotationKey = GroupName + "/readinessGates"
+
+	// RequestAuthenticationAnnotationKey is the annotation of a Revision to
+	// have its queue-proxies authenticate the requests before forwarding
+	// them to the user container, by their bearer token or the client
+	// certificate verified by the ingress, as parsed by
+	// ParseRequestAuthentication. Other requests are answered with 401
+	// Unauthorized. For example,
+	//   serving.knative.dev/requestAuthentication: "jwksURL=https://auth.example.com/.well-known/jwks.json, audiences=hello"
+	RequestAuthenticationAnnotationKey = GroupName + "/requestAuthentication"
+)
+
+const (
+	// TTLActionDelete deletes a Service once its ttl expires.
+	TTLActionDelete = "Delete"
+
+	// TTLActionHibernate hibernates the Revisions of a Service once its
+	// ttl expires, which keeps them around to be restored later.
+	TTLActionHibernate = "Hibernate"
+)
+
+const (
+	// RequestJournalMetadata journals the method, URL and headers of the
+	// requests, without the credentials.
+	RequestJournalMetadata = "metadata"
+
+	// RequestJournalBody journals the bodies of the requests as well, up
+	// to a size.
+	RequestJournalBody = "body"
+)
+
+const (
+	// QueueDisciplineFIFO lets queued requests through in the order they
+	// arrived.
+	QueueDisciplineFIFO = "FIFO"
+
+	// QueueDisciplineLIFO lets the newest queued requests through first,
+	// so that under overload the oldest ones time out instead of every
+	// request waiting for long.
+	QueueDisciplineLIFO = "LIFO"
+
+	// QueueDisciplineAdaptive is FIFO until the queue stops draining, and
+	// then LIFO, shedding the requests that can't get through quickly.
+	QueueDisciplineAdaptive = "Adaptive"
 )
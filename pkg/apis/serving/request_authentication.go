@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RequestAuthentication is how the queue-proxy authenticates the requests
+// to a Revision, as declared by the RequestAuthenticationAnnotationKey
+// annotation. A request is let through when it passes any of the checks.
+type RequestAuthentication struct {
+	// JWKSURL is where the JSON Web Key Set verifying the bearer tokens is
+	// fetched from.
+	JWKSURL *url.URL
+	// JWKSSecret is the name of the Secret of the Revision's namespace
+	// holding the JSON Web Key Set verifying the bearer tokens, under the
+	// JWKSSecretKey key.
+	JWKSSecret string
+	// Issuer is the issuer the bearer tokens must have, if set.
+	Issuer string
+	// Audiences are the audiences the bearer tokens must have one of, if
+	// set.
+	Audiences []string
+	// ClientSANs are the subject alternative names of the client
+	// certificates verified by the ingress that are let through, * for
+	// any of them.
+	ClientSANs []string
+	// ExemptPaths are the request paths served without authentication. A
+	// path ending with /* matches all of the paths under it, others only
+	// match themselves.
+	ExemptPaths []string
+}
+
+// JWKSSecretKey is the key of the JSON Web Key Set in the Secret named by
+// RequestAuthentication.JWKSSecret.
+const JWKSSecretKey = "jwks.json"
+
+// ParseRequestAuthentication parses the value of the request authentication
+// annotation, a comma separated list of entries: a "jwksURL=URL" or a
+// "jwksSecret=NAME" entry to verify bearer tokens, with optional
+// "issuer=ISSUER" and "audiences=AUDIENCE..." entries, a "clientSANs=SAN..."
+// entry to let verified client certificates through and an
+// "exemptPaths=PATH..." entry, with the lists separated by spaces, e.g.
+// "jwksURL=https://auth.example.com/.well-known/jwks.json, audiences=hello".
+func ParseRequestAuthentication(v string) (RequestAuthentication, error) {
+	var a RequestAuthentication
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return RequestAuthentication{}, fmt.Errorf("entry %q must be of the form KEY=VALUE", entry)
+		}
+		key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		switch key {
+		case "jwksURL":
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return RequestAuthentication{}, fmt.Errorf("jwksURL must be an absolute http or https URL, was %q", value)
+			}
+			a.JWKSURL = u
+		case "jwksSecret":
+			if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+				return RequestAuthentication{}, fmt.Errorf("jwksSecret must be a Secret name, was %q: %s", value, strings.Join(errs, ", "))
+			}
+			a.JWKSSecret = value
+		case "issuer":
+			if value == "" {
+				return RequestAuthentication{}, fmt.Errorf("issuer must not be empty")
+			}
+			a.Issuer = value
+		case "audiences":
+			a.Audiences = strings.Fields(value)
+		case "clientSANs":
+			a.ClientSANs = strings.Fields(value)
+		case "exemptPaths":
+			for _, p := range strings.Fields(value) {
+				if !strings.HasPrefix(p, "/") || strings.Contains(strings.TrimSuffix(p, "/*"), "*") {
+					return RequestAuthentication{}, fmt.Errorf("path %q must start with / and may only end with /*", p)
+				}
+				a.ExemptPaths = append(a.ExemptPaths, p)
+			}
+		default:
+			return RequestAuthentication{}, fmt.Errorf("unknown entry %q", entry)
+		}
+	}
+	if a.JWKSURL != nil && a.JWKSSecret != "" {
+		return RequestAuthentication{}, fmt.Errorf("at most one of jwksURL and jwksSecret may be set")
+	}
+	if !a.VerifiesTokens() && (a.Issuer != "" || len(a.Audiences) > 0) {
+		return RequestAuthentication{}, fmt.Errorf("issuer and audiences need jwksURL or jwksSecret to be set")
+	}
+	if !a.VerifiesTokens() && len(a.ClientSANs) == 0 {
+		return RequestAuthentication{}, fmt.Errorf("at least one of jwksURL, jwksSecret and clientSANs must be set")
+	}
+	return a, nil
+}
+
+// VerifiesTokens returns whether the bearer tokens of the requests are
+// verified.
+func (a RequestAuthentication) VerifiesTokens() bool {
+	return a.JWKSURL != nil || a.JWKSSecret != ""
+}
+
+// Exempts returns whether the request path p is served without
+// authentication.
+func (a RequestAuthentication) Exempts(p string) bool {
+	for _, path := range a.ExemptPaths {
+		if prefix := strings.TrimSuffix(path, "*"); prefix != path {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// RequestCosts are the concurrency costs of the requests to a Revision,
+// i.e. how many of the containerConcurrency slots of its queue-proxies
+// they take, as declared by the RequestCostsAnnotationKey annotation.
+type RequestCosts struct {
+	// Rules are matched in order against the method and path of requests.
+	Rules []RequestCostRule
+	// BytesPerSlot, if positive, has requests take a slot per so many
+	// bytes of their body.
+	BytesPerSlot int64
+}
+
+// RequestCostRule is the cost of the requests with a method and path.
+type RequestCostRule struct {
+	// Method is the method of the requests, any if empty.
+	Method string
+	// Path is the path of the requests, or their prefix if it ends in "*".
+	Path string
+	Cost int
+}
+
+// ParseRequestCosts parses the value of the request costs annotation, a
+// comma separated list of "[METHOD ]PATH=COST" rules and an optional
+// "bytes=SIZE" entry, e.g. "POST /v1/predict=4, /static/*=1, bytes=1Mi".
+func ParseRequestCosts(v string) (RequestCosts, error) {
+	var c RequestCosts
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return RequestCosts{}, fmt.Errorf("entry %q must be of the form [METHOD ]PATH=COST or bytes=SIZE", entry)
+		}
+		key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if key == "bytes" {
+			q, err := resource.ParseQuantity(value)
+			if err != nil || q.Value() <= 0 {
+				return RequestCosts{}, fmt.Errorf("bytes must be a positive quantity, was %q", value)
+			}
+			c.BytesPerSlot = q.Value()
+			continue
+		}
+
+		rule := RequestCostRule{Path: key}
+		if fields := strings.Fields(key); len(fields) == 2 {
+			rule.Method, rule.Path = strings.ToUpper(fields[0]), fields[1]
+		}
+		if !strings.HasPrefix(rule.Path, "/") || strings.ContainsAny(rule.Path, " \t") {
+			return RequestCosts{}, fmt.Errorf("path of entry %q must start with /", entry)
+		}
+		cost, err := strconv.Atoi(value)
+		if err != nil || cost < 1 {
+			return RequestCosts{}, fmt.Errorf("cost of entry %q must be an integer greater than 0", entry)
+		}
+		rule.Cost = cost
+		c.Rules = append(c.Rules, rule)
+	}
+	return c, nil
+}
+
+// Cost returns the cost of a request with the method, path and body length,
+// the larger of the cost of the first matching rule and the slots taken by
+// its body. Requests cost at least 1.
+func (c RequestCosts) Cost(method, path string, contentLength int64) int {
+	cost := 1
+	for _, r := range c.Rules {
+		if r.Method != "" && r.Method != method {
+			continue
+		}
+		if r.Path == path || (strings.HasSuffix(r.Path, "*") && strings.HasPrefix(path, strings.TrimSuffix(r.Path, "*"))) {
+			cost = r.Cost
+			break
+		}
+	}
+	if c.BytesPerSlot > 0 && contentLength > 0 {
+		if slots := (contentLength + c.BytesPerSlot - 1) / c.BytesPerSlot; slots > int64(cost) {
+			cost = int(slots)
+		}
+	}
+	return cost
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ParseRetryMethods parses the value of the retry methods annotation, a
+// comma separated list of the HTTP methods of the requests that are safe
+// to send twice, on top of GET and HEAD, e.g. "PUT, DELETE".
+func ParseRetryMethods(v string) ([]string, error) {
+	var methods []string
+	for _, m := range strings.Split(v, ",") {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+		// Methods are tokens, like header names.
+		if !httpguts.ValidHeaderFieldName(m) {
+			return nil, fmt.Errorf("%q is not an HTTP method", m)
+		}
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
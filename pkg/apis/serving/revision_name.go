@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RevisionNameData holds what a revision name template may refer to.
+type RevisionNameData struct {
+	// Config is the name of the Configuration stamping out the Revision.
+	Config string
+	// Generation is the generation of the Configuration.
+	Generation int64
+	// Annotations are the annotations of the Revision template, e.g. to
+	// put the git commit a Revision is built from in its name.
+	Annotations map[string]string
+}
+
+// ParseRevisionNameTemplate parses the value of the revision name template
+// annotation.
+func ParseRevisionNameTemplate(text string) (*template.Template, error) {
+	// Missing annotations render as empty strings rather than "<no value>".
+	return template.New(RevisionNameTemplateAnnotationKey).Option("missingkey=zero").Parse(text)
+}
+
+// ExecuteRevisionNameTemplate renders the Revision name from the revision
+// name template text, and checks that it is a valid name.
+func ExecuteRevisionNameTemplate(text string, data RevisionNameData) (string, error) {
+	tmpl, err := ParseRevisionNameTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	name := buf.String()
+	if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid revision name %q from %s: %v", name, RevisionNameTemplateAnnotationKey, errs)
+	}
+	return name, nil
+}
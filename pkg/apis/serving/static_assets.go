@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serving
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StaticAssets are the paths of a Revision the activator serves itself from
+// a ConfigMap or an object store, as declared by the
+// StaticAssetsAnnotationKey annotation, rather than waking the Revision.
+type StaticAssets struct {
+	// Paths are the request paths served. A path ending with /* matches
+	// all of the paths under it, others only match themselves.
+	Paths []string
+	// ConfigMap is the name of the ConfigMap of the Revision's namespace
+	// holding the assets, by the last element of their path. It must carry
+	// the StaticAssetsLabelKey label.
+	ConfigMap string
+	// URL is the base URL of the object store holding the assets, which
+	// the request path is appended to.
+	URL *url.URL
+}
+
+// ParseStaticAssets parses the value of the static assets annotation, a
+// comma separated list of a "paths=PATH..." entry, with the paths
+// separated by spaces, and either a "configMap=NAME" or a "url=URL" entry,
+// e.g. "paths=/static/* /favicon.ico, configMap=site-assets".
+func ParseStaticAssets(v string) (StaticAssets, error) {
+	var a StaticAssets
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return StaticAssets{}, fmt.Errorf("entry %q must be of the form KEY=VALUE", entry)
+		}
+		key, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		switch key {
+		case "paths":
+			for _, p := range strings.Fields(value) {
+				if !strings.HasPrefix(p, "/") || strings.Contains(strings.TrimSuffix(p, "/*"), "*") {
+					return StaticAssets{}, fmt.Errorf("path %q must start with / and may only end with /*", p)
+				}
+				a.Paths = append(a.Paths, p)
+			}
+		case "configMap":
+			if value == "" {
+				return StaticAssets{}, fmt.Errorf("configMap must be a ConfigMap name")
+			}
+			a.ConfigMap = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return StaticAssets{}, fmt.Errorf("url must be an absolute http or https URL, was %q", value)
+			}
+			a.URL = u
+		default:
+			return StaticAssets{}, fmt.Errorf("unknown entry %q", entry)
+		}
+	}
+	if len(a.Paths) == 0 {
+		return StaticAssets{}, fmt.Errorf("paths must be set")
+	}
+	if (a.ConfigMap == "") == (a.URL == nil) {
+		return StaticAssets{}, fmt.Errorf("exactly one of configMap and url must be set")
+	}
+	return a, nil
+}
+
+// Matches returns whether the request path p is one of the static assets.
+func (a StaticAssets) Matches(p string) bool {
+	for _, path := range a.Paths {
+		if prefix := strings.TrimSuffix(path, "*"); prefix != path {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -80,6 +80,7 @@ func (source *RevisionStatus) ConvertUp(ctx context.Context, sink *v1beta1.Revis
 
 	sink.ServiceName = source.ServiceName
 	sink.LogURL = source.LogURL
+	sink.EffectiveConfig = source.EffectiveConfig
 	// TODO(mattmoor): ImageDigest?
 }
 
@@ -113,5 +114,6 @@ func (sink *RevisionStatus) ConvertDown(ctx context.Context, source v1beta1.Revi
 
 	sink.ServiceName = source.ServiceName
 	sink.LogURL = source.LogURL
+	sink.EffectiveConfig = source.EffectiveConfig
 	// TODO(mattmoor): ImageDigest?
 }
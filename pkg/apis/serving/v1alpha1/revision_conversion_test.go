@@ -90,6 +90,9 @@ func TestRevisionConversion(t *testing.T) {
 				},
 				ServiceName: "foo-bar",
 				LogURL:      "http://logger.io",
+				EffectiveConfig: map[string]string{
+					"config-deployment/queueSidecarImage": "queue:latest",
+				},
 			},
 		},
 	}, {
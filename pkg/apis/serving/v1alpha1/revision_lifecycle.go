@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"knative.dev/pkg/apis"
@@ -61,6 +62,14 @@ var revCondSet = apis.NewLivingConditionSet(
 	RevisionConditionContainerHealthy,
 )
 
+// revGatedCondSet is the condition set of the Revisions with readiness
+// gates, which aren't ready until their external dependencies are.
+var revGatedCondSet = apis.NewLivingConditionSet(
+	RevisionConditionResourcesAvailable,
+	RevisionConditionContainerHealthy,
+	RevisionConditionDependenciesReady,
+)
+
 func (r *Revision) GetGroupVersionKind() schema.GroupVersionKind {
 	return SchemeGroupVersion.WithKind("Revision")
 }
@@ -113,29 +122,41 @@ func (r *Revision) GetProtocol() net.ProtocolType {
 // IsReady looks at the conditions and if the Status has a condition
 // RevisionConditionReady returns true if ConditionStatus is True
 func (rs *RevisionStatus) IsReady() bool {
-	return revCondSet.Manage(rs).IsHappy()
+	return rs.condSet().Manage(rs).IsHappy()
 }
 
 // IsActivationRequired returns true if activation is required.
 func (rs *RevisionStatus) IsActivationRequired() bool {
-	if c := revCondSet.Manage(rs).GetCondition(RevisionConditionActive); c != nil {
+	if c := rs.condSet().Manage(rs).GetCondition(RevisionConditionActive); c != nil {
 		return c.Status != corev1.ConditionTrue
 	}
 	return false
 }
 
 func (rs *RevisionStatus) GetCondition(t apis.ConditionType) *apis.Condition {
-	return revCondSet.Manage(rs).GetCondition(t)
+	return rs.condSet().Manage(rs).GetCondition(t)
+}
+
+// condSet returns the condition set the readiness of the Revision is
+// computed from, which accounts for its readiness gates once they were
+// checked.
+func (rs *RevisionStatus) condSet() apis.ConditionSet {
+	for _, c := range rs.Conditions {
+		if c.Type == RevisionConditionDependenciesReady {
+			return revGatedCondSet
+		}
+	}
+	return revCondSet
 }
 
 func (rs *RevisionStatus) InitializeConditions() {
-	revCondSet.Manage(rs).InitializeConditions()
+	rs.condSet().Manage(rs).InitializeConditions()
 }
 
 // MarkResourceNotConvertible adds a Warning-severity condition to the resource noting that
 // it cannot be converted to a higher version.
 func (rs *RevisionStatus) MarkResourceNotConvertible(err *CannotConvertError) {
-	revCondSet.Manage(rs).SetCondition(apis.Condition{
+	rs.condSet().Manage(rs).SetCondition(apis.Condition{
 		Type:     ConditionTypeConvertible,
 		Status:   corev1.ConditionFalse,
 		Severity: apis.ConditionSeverityWarning,
@@ -147,52 +168,86 @@ func (rs *RevisionStatus) MarkResourceNotConvertible(err *CannotConvertError) {
 // MarkResourceNotOwned changes the "ResourcesAvailable" condition to false to reflect that the
 // resource of the given kind and name has already been created, and we do not own it.
 func (rs *RevisionStatus) MarkResourceNotOwned(kind, name string) {
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "NotOwned",
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "NotOwned",
 		fmt.Sprintf("There is an existing %s %q that we do not own.", kind, name))
 }
 
 func (rs *RevisionStatus) MarkDeploying(reason string) {
-	revCondSet.Manage(rs).MarkUnknown(RevisionConditionResourcesAvailable, reason, "")
-	revCondSet.Manage(rs).MarkUnknown(RevisionConditionContainerHealthy, reason, "")
+	rs.condSet().Manage(rs).MarkUnknown(RevisionConditionResourcesAvailable, reason, "")
+	rs.condSet().Manage(rs).MarkUnknown(RevisionConditionContainerHealthy, reason, "")
 }
 
 func (rs *RevisionStatus) MarkProgressDeadlineExceeded(message string) {
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "ProgressDeadlineExceeded", message)
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "ProgressDeadlineExceeded", message)
+}
+
+// MarkQuotaExceeded changes "ResourcesAvailable" condition to false to reflect that
+// the Revision's pods can't be created because the namespace's quota is exhausted.
+func (rs *RevisionStatus) MarkQuotaExceeded(message string) {
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "QuotaExceeded",
+		"%s", withRemediation(message, "Raise the namespace's ResourceQuota or lower the Revision's resource requests."))
+}
+
+// MarkNodeAffinityUnsatisfiable changes "ResourcesAvailable" condition to false to
+// reflect that no node matches the node selector or affinity of the Revision's pods.
+func (rs *RevisionStatus) MarkNodeAffinityUnsatisfiable(message string) {
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "NodeAffinityUnsatisfiable",
+		"%s", withRemediation(message, "Check the node selector and affinity of the Revision's pods against the labels of the cluster's nodes."))
+}
+
+// MarkInsufficientResources changes "ResourcesAvailable" condition to false to reflect
+// that no node has enough free resources to schedule the Revision's pods.
+func (rs *RevisionStatus) MarkInsufficientResources(message string) {
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, "InsufficientResources",
+		"%s", withRemediation(message, "Lower the Revision's resource requests or add capacity to the cluster."))
 }
 
 func (rs *RevisionStatus) MarkContainerHealthy() {
-	revCondSet.Manage(rs).MarkTrue(RevisionConditionContainerHealthy)
+	rs.condSet().Manage(rs).MarkTrue(RevisionConditionContainerHealthy)
 }
 
 func (rs *RevisionStatus) MarkContainerExiting(exitCode int32, message string) {
 	exitCodeString := fmt.Sprintf("ExitCode%d", exitCode)
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionContainerHealthy, exitCodeString, RevisionContainerExitingMessage(message))
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionContainerHealthy, exitCodeString, RevisionContainerExitingMessage(message))
 }
 
 func (rs *RevisionStatus) MarkResourcesAvailable() {
-	revCondSet.Manage(rs).MarkTrue(RevisionConditionResourcesAvailable)
+	rs.condSet().Manage(rs).MarkTrue(RevisionConditionResourcesAvailable)
 }
 
 // MarkResourcesUnavailable changes "ResourcesAvailable" condition to false to reflect that the
 // resources of the given kind and name cannot be created.
 func (rs *RevisionStatus) MarkResourcesUnavailable(reason, message string) {
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, reason, message)
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionResourcesAvailable, reason, message)
 }
 
 func (rs *RevisionStatus) MarkActive() {
-	revCondSet.Manage(rs).MarkTrue(RevisionConditionActive)
+	rs.condSet().Manage(rs).MarkTrue(RevisionConditionActive)
 }
 
 func (rs *RevisionStatus) MarkActivating(reason, message string) {
-	revCondSet.Manage(rs).MarkUnknown(RevisionConditionActive, reason, message)
+	rs.condSet().Manage(rs).MarkUnknown(RevisionConditionActive, reason, message)
 }
 
 func (rs *RevisionStatus) MarkInactive(reason, message string) {
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionActive, reason, message)
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionActive, reason, message)
 }
 
 func (rs *RevisionStatus) MarkContainerMissing(message string) {
-	revCondSet.Manage(rs).MarkFalse(RevisionConditionContainerHealthy, "ContainerMissing", message)
+	rs.condSet().Manage(rs).MarkFalse(RevisionConditionContainerHealthy, "ContainerMissing", message)
+}
+
+// MarkDependenciesPending changes "DependenciesReady" condition to unknown to
+// reflect that some readiness gates of the Revision haven't passed yet, which
+// keeps it from becoming ready.
+func (rs *RevisionStatus) MarkDependenciesPending(reason, message string) {
+	revGatedCondSet.Manage(rs).MarkUnknown(RevisionConditionDependenciesReady, reason, "%s", message)
+}
+
+// MarkDependenciesReady changes "DependenciesReady" condition to true to
+// reflect that all the readiness gates of the Revision passed.
+func (rs *RevisionStatus) MarkDependenciesReady() {
+	revGatedCondSet.Manage(rs).MarkTrue(RevisionConditionDependenciesReady)
 }
 
 // RevisionContainerMissingMessage constructs the status message if a given image
@@ -201,6 +256,14 @@ func RevisionContainerMissingMessage(image string, message string) string {
 	return fmt.Sprintf("Unable to fetch image %q: %s", image, message)
 }
 
+// withRemediation appends a hint on how to resolve the failure to its message.
+func withRemediation(message, hint string) string {
+	if message == "" {
+		return hint
+	}
+	return strings.TrimSuffix(message, ".") + ". " + hint
+}
+
 // RevisionContainerExitingMessage constructs the status message if a container
 // fails to come up.
 func RevisionContainerExitingMessage(message string) string {
@@ -298,6 +298,29 @@ func TestTypicalFlowWithContainerMissing(t *testing.T) {
 	}
 }
 
+func TestTypicalFlowWithReadinessGates(t *testing.T) {
+	r := &RevisionStatus{}
+	r.InitializeConditions()
+	r.MarkDependenciesPending("DependenciesPending", "Secret \"db\" doesn't exist")
+	apitest.CheckConditionOngoing(r.duck(), RevisionConditionDependenciesReady, t)
+	apitest.CheckConditionOngoing(r.duck(), RevisionConditionReady, t)
+
+	// The Revision's resources becoming ready doesn't make it ready while
+	// its dependencies are pending.
+	r.MarkResourcesAvailable()
+	r.MarkContainerHealthy()
+	apitest.CheckConditionSucceeded(r.duck(), RevisionConditionResourcesAvailable, t)
+	apitest.CheckConditionSucceeded(r.duck(), RevisionConditionContainerHealthy, t)
+	apitest.CheckConditionOngoing(r.duck(), RevisionConditionReady, t)
+	if r.IsReady() {
+		t.Error("IsReady() = true with pending dependencies")
+	}
+
+	r.MarkDependenciesReady()
+	apitest.CheckConditionSucceeded(r.duck(), RevisionConditionDependenciesReady, t)
+	apitest.CheckConditionSucceeded(r.duck(), RevisionConditionReady, t)
+}
+
 func TestTypicalFlowWithSuspendResume(t *testing.T) {
 	r := &RevisionStatus{}
 	r.InitializeConditions()
@@ -380,6 +403,56 @@ func TestRevisionResourcesUnavailable(t *testing.T) {
 	}
 }
 
+func TestRevisionDeploymentFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		mark        func(*RevisionStatus, string)
+		message     string
+		wantReason  string
+		wantMessage string
+	}{{
+		name:        "quota exceeded",
+		mark:        (*RevisionStatus).MarkQuotaExceeded,
+		message:     "exceeded quota: compute",
+		wantReason:  "QuotaExceeded",
+		wantMessage: "exceeded quota: compute. Raise the namespace's ResourceQuota or lower the Revision's resource requests.",
+	}, {
+		name:        "node affinity unsatisfiable",
+		mark:        (*RevisionStatus).MarkNodeAffinityUnsatisfiable,
+		message:     "0/3 nodes are available: 3 node(s) didn't match node selector.",
+		wantReason:  "NodeAffinityUnsatisfiable",
+		wantMessage: "0/3 nodes are available: 3 node(s) didn't match node selector. Check the node selector and affinity of the Revision's pods against the labels of the cluster's nodes.",
+	}, {
+		name:        "insufficient resources",
+		mark:        (*RevisionStatus).MarkInsufficientResources,
+		message:     "0/3 nodes are available: 3 Insufficient cpu.",
+		wantReason:  "InsufficientResources",
+		wantMessage: "0/3 nodes are available: 3 Insufficient cpu. Lower the Revision's resource requests or add capacity to the cluster.",
+	}, {
+		name:        "no message",
+		mark:        (*RevisionStatus).MarkInsufficientResources,
+		wantReason:  "InsufficientResources",
+		wantMessage: "Lower the Revision's resource requests or add capacity to the cluster.",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &RevisionStatus{}
+			r.InitializeConditions()
+			test.mark(r, test.message)
+			apitest.CheckConditionFailed(r.duck(), RevisionConditionResourcesAvailable, t)
+			apitest.CheckConditionFailed(r.duck(), RevisionConditionReady, t)
+			got := r.GetCondition(RevisionConditionResourcesAvailable)
+			if got == nil || got.Reason != test.wantReason {
+				t.Errorf("RevisionConditionResourcesAvailable = %v, want reason %v", got, test.wantReason)
+			}
+			if got == nil || got.Message != test.wantMessage {
+				t.Errorf("RevisionConditionResourcesAvailable = %v, want message %v", got, test.wantMessage)
+			}
+		})
+	}
+}
+
 func TestRevisionGetGroupVersionKind(t *testing.T) {
 	r := &Revision{}
 	want := schema.GroupVersionKind{
@@ -168,6 +168,9 @@ const (
 	RevisionConditionContainerHealthy apis.ConditionType = "ContainerHealthy"
 	// RevisionConditionActive is set when the revision is receiving traffic.
 	RevisionConditionActive apis.ConditionType = "Active"
+	// RevisionConditionDependenciesReady is set on the Revisions with readiness
+	// gates, and becomes true once all of them passed.
+	RevisionConditionDependenciesReady apis.ConditionType = "DependenciesReady"
 )
 
 // RevisionStatus communicates the observed state of the Revision (from the controller).
@@ -191,6 +194,13 @@ type RevisionStatus struct {
 	// may be empty if the image comes from a registry listed to skip resolution.
 	// +optional
 	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// EffectiveConfig records the values of the serving configuration that
+	// applied to this Revision when it was created, keyed by
+	// <config map>/<key>, along with the values the controller resolved for
+	// the Revision itself, keyed by revision/<name>.
+	// +optional
+	EffectiveConfig map[string]string `json:"effectiveConfig,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
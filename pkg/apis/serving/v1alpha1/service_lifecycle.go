@@ -18,8 +18,10 @@ package v1alpha1
 
 import (
 	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"knative.dev/pkg/apis"
@@ -63,6 +65,68 @@ func (ss *ServiceStatus) MarkResourceNotConvertible(err *CannotConvertError) {
 	})
 }
 
+// MarkReconcileBackoff adds an Info-severity condition noting that the
+// service failed to reconcile and is going to be retried, so that a
+// transient failure can be told apart from one needing intervention. The
+// message only changes with the error, so that retrying doesn't update the
+// status, which would in turn trigger another reconcile right away.
+func (ss *ServiceStatus) MarkReconcileBackoff(err error) {
+	serviceCondSet.Manage(ss).SetCondition(apis.Condition{
+		Type:     ServiceConditionReconcileBackoff,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   "ReconcileFailed",
+		Message:  fmt.Sprintf("Reconciliation failed, retrying with backoff: %v", err),
+	})
+}
+
+// ClearReconcileBackoff removes the ReconcileBackoff condition once the
+// service reconciled successfully.
+func (ss *ServiceStatus) ClearReconcileBackoff() {
+	ss.removeCondition(ServiceConditionReconcileBackoff)
+}
+
+// MarkTTLStarted records when the ttl of the service started, unless it
+// already did.
+func (ss *ServiceStatus) MarkTTLStarted(t time.Time) {
+	if ss.TTLStartTime == nil {
+		start := metav1.NewTime(t)
+		ss.TTLStartTime = &start
+	}
+}
+
+// MarkTTLExpiring adds an Info-severity condition noting that the ttl of
+// the service is about to expire, with what happens then.
+func (ss *ServiceStatus) MarkTTLExpiring(messageFormat string, messageA ...interface{}) {
+	serviceCondSet.Manage(ss).SetCondition(apis.Condition{
+		Type:     ServiceConditionTTLExpiring,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   "TTLExpiring",
+		Message:  fmt.Sprintf(messageFormat, messageA...),
+	})
+}
+
+// ClearTTL forgets the ttl of the service, once its ttl annotation is
+// removed.
+func (ss *ServiceStatus) ClearTTL() {
+	ss.TTLStartTime = nil
+	ss.removeCondition(ServiceConditionTTLExpiring)
+}
+
+func (ss *ServiceStatus) removeCondition(t apis.ConditionType) {
+	conds := ss.Conditions[:0]
+	for _, c := range ss.Conditions {
+		if c.Type != t {
+			conds = append(conds, c)
+		}
+	}
+	if len(conds) == 0 {
+		conds = nil
+	}
+	ss.Conditions = conds
+}
+
 // MarkConfigurationNotOwned surfaces a failure via the ConfigurationsReady
 // status noting that the Configuration with the name we want has already
 // been created and we do not own it.
@@ -17,6 +17,7 @@ package v1alpha1
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -573,6 +574,38 @@ func TestServiceNotOwnedStuff(t *testing.T) {
 	apitesting.CheckConditionFailed(svc.duck(), ServiceConditionReady, t)
 }
 
+func TestServiceReconcileBackoff(t *testing.T) {
+	svc := &ServiceStatus{}
+	svc.InitializeConditions()
+
+	svc.MarkReconcileBackoff(errors.New("boom"))
+	apitesting.CheckConditionSucceeded(svc.duck(), ServiceConditionReconcileBackoff, t)
+	// Backing off doesn't affect readiness.
+	apitesting.CheckConditionOngoing(svc.duck(), ServiceConditionReady, t)
+
+	c := svc.GetCondition(ServiceConditionReconcileBackoff)
+	if got, want := c.Severity, apis.ConditionSeverityInfo; got != want {
+		t.Errorf("Severity = %q, want %q", got, want)
+	}
+	if got, want := c.Message, "Reconciliation failed, retrying with backoff: boom"; got != want {
+		t.Errorf("Message = %q, want %q", got, want)
+	}
+
+	// Failing again with the same error doesn't change the status.
+	before := svc.DeepCopy()
+	svc.MarkReconcileBackoff(errors.New("boom"))
+	if !cmp.Equal(before, svc) {
+		t.Errorf("Status changed on retry (-want, +got): %s", cmp.Diff(before, svc))
+	}
+
+	svc.ClearReconcileBackoff()
+	if c := svc.GetCondition(ServiceConditionReconcileBackoff); c != nil {
+		t.Errorf("GetCondition() = %v, want nil", c)
+	}
+	apitesting.CheckConditionOngoing(svc.duck(), ServiceConditionReady, t)
+	apitesting.CheckConditionOngoing(svc.duck(), ServiceConditionRoutesReady, t)
+}
+
 func TestRouteStatusPropagation(t *testing.T) {
 	svc := &Service{}
 
@@ -172,6 +172,12 @@ const (
 	// ServiceConditionConfigurationsReady is set when the service's underlying
 	// configurations have reported readiness.
 	ServiceConditionConfigurationsReady apis.ConditionType = "ConfigurationsReady"
+	// ServiceConditionReconcileBackoff is an informational condition that
+	// is set while the service is retried after failing to reconcile.
+	ServiceConditionReconcileBackoff apis.ConditionType = "ReconcileBackoff"
+	// ServiceConditionTTLExpiring is an informational condition that is
+	// set once the ttl of the service is about to expire.
+	ServiceConditionTTLExpiring apis.ConditionType = "TTLExpiring"
 )
 
 // ServiceStatus represents the Status stanza of the Service resource.
@@ -181,6 +187,11 @@ type ServiceStatus struct {
 	RouteStatusFields `json:",inline"`
 
 	ConfigurationStatusFields `json:",inline"`
+
+	// TTLStartTime is when the ttl annotation of the service was first
+	// seen, which its ttl is measured from.
+	// +optional
+	TTLStartTime *metav1.Time `json:"ttlStartTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
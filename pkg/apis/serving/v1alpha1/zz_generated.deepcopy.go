@@ -316,6 +316,13 @@ func (in *RevisionSpec) DeepCopy() *RevisionSpec {
 func (in *RevisionStatus) DeepCopyInto(out *RevisionStatus) {
 	*out = *in
 	in.Status.DeepCopyInto(&out.Status)
+	if in.EffectiveConfig != nil {
+		in, out := &in.EffectiveConfig, &out.EffectiveConfig
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -604,6 +611,10 @@ func (in *ServiceStatus) DeepCopyInto(out *ServiceStatus) {
 	in.Status.DeepCopyInto(&out.Status)
 	in.RouteStatusFields.DeepCopyInto(&out.RouteStatusFields)
 	out.ConfigurationStatusFields = in.ConfigurationStatusFields
+	if in.TTLStartTime != nil {
+		in, out := &in.TTLStartTime, &out.TTLStartTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
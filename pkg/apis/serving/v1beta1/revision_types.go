@@ -121,6 +121,13 @@ type RevisionStatus struct {
 	// may be empty if the image comes from a registry listed to skip resolution.
 	// +optional
 	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// EffectiveConfig records the values of the serving configuration that
+	// applied to this Revision when it was created, keyed by
+	// <config map>/<key>, along with the values the controller resolved for
+	// the Revision itself, keyed by revision/<name>.
+	// +optional
+	EffectiveConfig map[string]string `json:"effectiveConfig,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
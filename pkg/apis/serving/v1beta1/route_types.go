@@ -99,6 +99,19 @@ type TrafficTarget struct {
 	// a hostname, but may not contain anything else (e.g. basic auth, url path, etc.)
 	// +optional
 	URL *apis.URL `json:"url,omitempty"`
+
+	// CacheControl is the Cache-Control header of the responses to the
+	// requests sent to this target's URL, or to the Route's for the targets
+	// without a tag, when the application doesn't set one, e.g. for a CDN
+	// in front of the Route to cache them. The targets sharing a URL take
+	// the value of the first of them that sets it.
+	// +optional
+	CacheControl string `json:"cacheControl,omitempty"`
+
+	// SurrogateControl is like CacheControl, for the Surrogate-Control
+	// header addressed to CDNs only.
+	// +optional
+	SurrogateControl string `json:"surrogateControl,omitempty"`
 }
 
 // RouteSpec holds the desired state of the Route (from the client).
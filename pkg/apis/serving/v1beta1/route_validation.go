@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/knative/serving/pkg/apis/serving"
+	"golang.org/x/net/http/httpguts"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 )
@@ -78,9 +79,20 @@ func (tt *TrafficTarget) Validate(ctx context.Context) *apis.FieldError {
 	errs := tt.validateLatestRevision(ctx)
 	errs = tt.validateRevisionAndConfiguration(ctx, errs)
 	errs = tt.validateTrafficPercentage(errs)
+	errs = tt.validateCacheHeaders(errs)
 	return tt.validateUrl(ctx, errs)
 }
 
+func (tt *TrafficTarget) validateCacheHeaders(errs *apis.FieldError) *apis.FieldError {
+	if tt.CacheControl != "" && !httpguts.ValidHeaderFieldValue(tt.CacheControl) {
+		errs = errs.Also(apis.ErrInvalidValue(tt.CacheControl, "cacheControl"))
+	}
+	if tt.SurrogateControl != "" && !httpguts.ValidHeaderFieldValue(tt.SurrogateControl) {
+		errs = errs.Also(apis.ErrInvalidValue(tt.SurrogateControl, "surrogateControl"))
+	}
+	return errs
+}
+
 func (tt *TrafficTarget) validateRevisionAndConfiguration(ctx context.Context, errs *apis.FieldError) *apis.FieldError {
 	// We only validate the sense of latestRevision in the context of a Spec,
 	// and only when it is specified.
@@ -251,6 +251,25 @@ func TestTrafficTargetValidation(t *testing.T) {
 			Percent:      101,
 		},
 		want: apis.ErrOutOfBoundsValue("101", "0", "100", "percent"),
+	}, {
+		name: "valid cache headers",
+		tt: &TrafficTarget{
+			RevisionName:     "foo",
+			Percent:          100,
+			CacheControl:     "public, max-age=60",
+			SurrogateControl: "max-age=3600",
+		},
+		want: nil,
+	}, {
+		name: "invalid cache headers",
+		tt: &TrafficTarget{
+			RevisionName:     "foo",
+			Percent:          100,
+			CacheControl:     "max-age=60\r\nSet-Cookie: a=b",
+			SurrogateControl: "max-age=3600\n",
+		},
+		want: apis.ErrInvalidValue("max-age=60\r\nSet-Cookie: a=b", "cacheControl").Also(
+			apis.ErrInvalidValue("max-age=3600\n", "surrogateControl")),
 	}, {
 		name: "disallowed url set",
 		tt: &TrafficTarget{
@@ -22,28 +22,65 @@ import (
 )
 
 // TimedFloat64Buckets keeps buckets that have been collected at a certain time.
+//
+// Buckets are indexed by their offset from the creation of the buckets rather
+// than by their wall clock time. Times carrying a monotonic clock reading (as
+// returned by time.Now) are thus bucketed by the monotonic clock and a step of
+// the wall clock, e.g. by NTP, doesn't move them out of their windows.
 type TimedFloat64Buckets struct {
 	bucketsMutex sync.RWMutex
-	buckets      map[time.Time]float64Bucket
+	buckets      map[int64]float64Bucket
 
+	// start is the time the bucket indexes are relative to. Its wall clock
+	// is aligned on the granularity, which keeps the bucket times the same
+	// as truncating the recorded times as long as the clock doesn't step.
+	start       time.Time
 	granularity time.Duration
 }
 
 // NewTimedFloat64Buckets generates a new TimedFloat64Buckets with the given
 // granularity.
 func NewTimedFloat64Buckets(granularity time.Duration) *TimedFloat64Buckets {
+	now := time.Now()
+	start := now
+	if granularity > 0 {
+		// Add keeps the monotonic clock reading, which Truncate strips.
+		start = now.Add(-now.Round(0).Sub(now.Truncate(granularity)))
+	}
 	return &TimedFloat64Buckets{
-		buckets:     make(map[time.Time]float64Bucket),
+		buckets:     make(map[int64]float64Bucket),
+		start:       start,
 		granularity: granularity,
 	}
 }
 
+// bucketIndex returns the index of the bucket the given time falls into.
+func (t *TimedFloat64Buckets) bucketIndex(time time.Time) int64 {
+	offset := time.Sub(t.start)
+	if t.granularity <= 0 {
+		return int64(offset)
+	}
+	index := int64(offset / t.granularity)
+	if offset%t.granularity < 0 {
+		index--
+	}
+	return index
+}
+
+// bucketTime returns the time at which the bucket with the given index starts.
+func (t *TimedFloat64Buckets) bucketTime(index int64) time.Time {
+	if t.granularity <= 0 {
+		return t.start.Add(time.Duration(index))
+	}
+	return t.start.Add(time.Duration(index) * t.granularity)
+}
+
 // Record adds a value with an associated time to the correct bucket.
 func (t *TimedFloat64Buckets) Record(time time.Time, name string, value float64) {
 	t.bucketsMutex.Lock()
 	defer t.bucketsMutex.Unlock()
 
-	bucketKey := time.Truncate(t.granularity)
+	bucketKey := t.bucketIndex(time)
 	bucket, ok := t.buckets[bucketKey]
 	if !ok {
 		bucket = float64Bucket{}
@@ -65,7 +102,8 @@ func (t *TimedFloat64Buckets) ForEachBucket(accs ...Accumulator) {
 	t.bucketsMutex.RLock()
 	defer t.bucketsMutex.RUnlock()
 
-	for bucketTime, bucket := range t.buckets {
+	for index, bucket := range t.buckets {
+		bucketTime := t.bucketTime(index)
 		for _, acc := range accs {
 			acc(bucketTime, bucket)
 		}
@@ -77,9 +115,9 @@ func (t *TimedFloat64Buckets) RemoveOlderThan(time time.Time) {
 	t.bucketsMutex.Lock()
 	defer t.bucketsMutex.Unlock()
 
-	for bucketTime := range t.buckets {
-		if bucketTime.Before(time) {
-			delete(t.buckets, bucketTime)
+	for index := range t.buckets {
+		if t.bucketTime(index).Before(time) {
+			delete(t.buckets, index)
 		}
 	}
 }
@@ -78,9 +78,9 @@ func TestTimedFloat64Buckets(t *testing.T) {
 			}
 
 			got := make(map[time.Time]float64)
-			for time, bucket := range buckets.buckets {
-				got[time] = bucket.Sum()
-			}
+			buckets.ForEachBucket(func(time time.Time, bucket float64Bucket) {
+				got[time.Round(0)] = bucket.Sum()
+			})
 
 			if !cmp.Equal(tt.want, got) {
 				t.Errorf("Unexpected values (-want +got): %v", cmp.Diff(tt.want, got))
@@ -194,9 +194,9 @@ func TestTimedFloat64Buckets_RemoveOlderThan(t *testing.T) {
 			}
 
 			got := make(map[time.Time]bool)
-			for time := range buckets.buckets {
-				got[time] = true
-			}
+			buckets.ForEachBucket(func(time time.Time, _ float64Bucket) {
+				got[time.Round(0)] = true
+			})
 			for _, want := range tt.want {
 				if !got[want] {
 					t.Errorf("Expected buckets to contain %v, buckets: %v", want, got)
@@ -206,6 +206,48 @@ func TestTimedFloat64Buckets_RemoveOlderThan(t *testing.T) {
 	}
 }
 
+func TestTimedFloat64Buckets_MonotonicClock(t *testing.T) {
+	pod := "pod"
+	granularity := 1 * time.Second
+	buckets := NewTimedFloat64Buckets(granularity)
+
+	// Times returned by time.Now carry a monotonic clock reading, the
+	// buckets must keep it so that comparisons ignore wall clock steps.
+	now := time.Now()
+	buckets.Record(now, pod, 1.0)
+	buckets.Record(now.Add(-granularity), pod, 1.0)
+	buckets.Record(now.Add(granularity), pod, 1.0)
+
+	var times []time.Time
+	buckets.ForEachBucket(func(time time.Time, _ float64Bucket) {
+		times = append(times, time)
+	})
+	if got, want := len(times), 3; got != want {
+		t.Fatalf("len(buckets) = %d, want %d", got, want)
+	}
+	oldest := times[0]
+	for _, bucketTime := range times {
+		if bucketTime.Before(oldest) {
+			oldest = bucketTime
+		}
+		if bucketTime == bucketTime.Round(0) {
+			t.Errorf("Bucket time %v has no monotonic clock reading", bucketTime)
+		}
+		if got, want := bucketTime.Round(0), bucketTime.Round(0).Truncate(granularity); !got.Equal(want) {
+			t.Errorf("Bucket time = %v, want it aligned on %v", got, want)
+		}
+	}
+
+	buckets.RemoveOlderThan(oldest.Add(granularity))
+	var remaining int
+	buckets.ForEachBucket(func(time.Time, float64Bucket) {
+		remaining++
+	})
+	if got, want := remaining, 2; got != want {
+		t.Errorf("len(buckets) after RemoveOlderThan = %d, want %d", got, want)
+	}
+}
+
 func TestFloat64Bucket(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -32,6 +32,17 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// baselineWeight is the weight of each observation in the exponentially
+// weighted moving average of the stable concurrency that defines the
+// baseline load of a revision. Small values make the baseline follow
+// the sustained load rather than its spikes.
+const baselineWeight = 0.05
+
+// scaleSmoothingTolerance is how far above a whole number of pods the
+// smoothed scale may be and still round down to it, for the scale to settle
+// although the filters only converge asymptotically.
+const scaleSmoothingTolerance = 0.01
+
 // Autoscaler stores current state of an instance of an autoscaler.
 type Autoscaler struct {
 	namespace    string
@@ -46,6 +57,20 @@ type Autoscaler struct {
 	panicTime    *time.Time
 	maxPanicPods int32
 
+	// State of the minimum scale learning. Guarded by the stateMux.
+	baselineConcurrency float64
+	lastTrafficTime     time.Time
+
+	// The strategy computing the desired scale and the name it was created
+	// by. Guarded by the stateMux.
+	strategy     ScalingStrategy
+	strategyName string
+
+	// The filter smoothing the desired scale and the smoothing it was
+	// created by. Guarded by the stateMux.
+	filter    scaleFilter
+	smoothing ScaleSmoothing
+
 	// specMux guards the current DeciderSpec.
 	specMux     sync.RWMutex
 	deciderSpec DeciderSpec
@@ -115,10 +140,6 @@ func (a *Autoscaler) Scale(ctx context.Context, now time.Time) (desiredPodCount
 		return 0, 0, false
 	}
 
-	maxScaleUp := spec.MaxScaleUpRate * readyPodsCount
-	desiredStablePodCount := int32(math.Min(math.Ceil(observedStableConcurrency/spec.TargetConcurrency), maxScaleUp))
-	desiredPanicPodCount := int32(math.Min(math.Ceil(observedPanicConcurrency/spec.TargetConcurrency), maxScaleUp))
-
 	a.reporter.ReportStableRequestConcurrency(observedStableConcurrency)
 	a.reporter.ReportPanicRequestConcurrency(observedPanicConcurrency)
 	a.reporter.ReportTargetRequestConcurrency(spec.TargetConcurrency)
@@ -134,6 +155,17 @@ func (a *Autoscaler) Scale(ctx context.Context, now time.Time) (desiredPodCount
 
 	a.stateMux.Lock()
 	defer a.stateMux.Unlock()
+
+	stablePods, panicPods := a.scalingStrategy(logger, spec).DesiredPodCounts(spec, ScalingInput{
+		Now:               now,
+		ReadyPods:         readyPodsCount,
+		StableConcurrency: observedStableConcurrency,
+		PanicConcurrency:  observedPanicConcurrency,
+	})
+	maxScaleUp := spec.MaxScaleUpRate * readyPodsCount
+	desiredStablePodCount := int32(math.Min(math.Ceil(stablePods), maxScaleUp))
+	desiredPanicPodCount := int32(math.Min(math.Ceil(panicPods), maxScaleUp))
+
 	if a.panicTime == nil && isOverPanicThreshold {
 		// Begin panicking when we cross the concurrency threshold in the panic window.
 		logger.Info("PANICKING")
@@ -156,9 +188,30 @@ func (a *Autoscaler) Scale(ctx context.Context, now time.Time) (desiredPodCount
 			a.maxPanicPods = desiredPanicPodCount
 		}
 		desiredPodCount = a.maxPanicPods
+		if filter := a.scaleFilter(spec); filter != nil {
+			// Smooth from where panic mode leaves the scale.
+			filter.reset(float64(desiredPodCount), now)
+		}
 	} else {
 		logger.Debug("Operating in stable mode.")
 		desiredPodCount = desiredStablePodCount
+		if filter := a.scaleFilter(spec); filter != nil {
+			if desiredPodCount == 0 {
+				// Scaling to zero is left to its grace period.
+				filter.reset(0, now)
+			} else {
+				smoothed := filter.filter(math.Min(stablePods, maxScaleUp), now)
+				desiredPodCount = int32(math.Max(1, math.Ceil(smoothed-scaleSmoothingTolerance)))
+				logger.Debugf("Smoothed the desired scale from %d to %d.", desiredStablePodCount, desiredPodCount)
+			}
+		}
+	}
+
+	if spec.LearnedMinScaleMax > 0 {
+		if floor := a.learnMinScale(spec, observedStableConcurrency, now); desiredPodCount < floor {
+			logger.Debugf("Keeping learned minimum scale %d over %d.", floor, desiredPodCount)
+			desiredPodCount = floor
+		}
 	}
 
 	// Compute the excess burst capacity based on stable concurrency for now, since we don't want to
@@ -170,9 +223,56 @@ func (a *Autoscaler) Scale(ctx context.Context, now time.Time) (desiredPodCount
 	logger.Debug("Excess burst capacity = ", excessBC)
 
 	a.reporter.ReportDesiredPodCount(int64(desiredPodCount))
+	if observed, err := a.metricClient.LatestStatTime(metricKey); err == nil {
+		a.reporter.ReportDecisionLatency(now.Sub(observed))
+	}
 	return desiredPodCount, excessBC, true
 }
 
+// scalingStrategy returns the ScalingStrategy selected by the spec, which is
+// created anew when the spec selects another one. Unknown strategies fall
+// back to the default one. Must be called with the stateMux held.
+func (a *Autoscaler) scalingStrategy(logger *zap.SugaredLogger, spec DeciderSpec) ScalingStrategy {
+	if a.strategy == nil || a.strategyName != spec.ScalingStrategy {
+		strategy, err := newScalingStrategy(spec.ScalingStrategy)
+		if err != nil {
+			logger.Errorw("Falling back to the default scaling strategy", zap.Error(err))
+			strategy = defaultScalingStrategy{}
+		}
+		a.strategy, a.strategyName = strategy, spec.ScalingStrategy
+	}
+	return a.strategy
+}
+
+// scaleFilter returns the filter smoothing the desired scale selected by the
+// spec, which is created anew when the spec selects another smoothing, or
+// nil if the desired scale isn't smoothed. Must be called with the stateMux
+// held.
+func (a *Autoscaler) scaleFilter(spec DeciderSpec) scaleFilter {
+	if a.smoothing != spec.ScaleSmoothing {
+		a.filter, a.smoothing = newScaleFilter(spec.ScaleSmoothing), spec.ScaleSmoothing
+	}
+	return a.filter
+}
+
+// learnMinScale tracks the baseline load of the revision while it receives
+// traffic and returns the number of pods that load needs, bounded by the
+// spec. That number is kept as the revision's minimum scale until it sees no
+// traffic for the retention period. Must be called with the stateMux held.
+func (a *Autoscaler) learnMinScale(spec DeciderSpec, observedStableConcurrency float64, now time.Time) int32 {
+	if observedStableConcurrency > 0 {
+		if a.baselineConcurrency == 0 {
+			a.baselineConcurrency = observedStableConcurrency
+		} else {
+			a.baselineConcurrency += baselineWeight * (observedStableConcurrency - a.baselineConcurrency)
+		}
+		a.lastTrafficTime = now
+	} else if now.Sub(a.lastTrafficTime) > spec.LearnedMinScaleRetention {
+		a.baselineConcurrency = 0
+	}
+	return int32(math.Min(math.Ceil(a.baselineConcurrency/spec.TargetConcurrency), float64(spec.LearnedMinScaleMax)))
+}
+
 func (a *Autoscaler) currentSpec() DeciderSpec {
 	a.specMux.RLock()
 	defer a.specMux.RUnlock()
@@ -19,9 +19,11 @@ package autoscaler
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	"github.com/knative/serving/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -90,6 +92,18 @@ func TestAutoscalerStableModeNoChange(t *testing.T) {
 	a.expectScale(t, time.Now(), 5, expectedEBC(10, 100, 50, 1), true)
 }
 
+func TestAutoscalerReportsDecisionLatency(t *testing.T) {
+	now := time.Now()
+	metrics := &testMetricClient{stableConcurrency: 50.0, statTime: now.Add(-3 * time.Second)}
+	a := newTestAutoscaler(10, 100, metrics)
+	a.expectScale(t, now, 5, expectedEBC(10, 100, 50, 1), true)
+
+	reporter := a.reporter.(*mockReporter)
+	if got, want := reporter.decisionLatencies, []time.Duration{3 * time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Decision latencies = %v, want: %v", got, want)
+	}
+}
+
 func TestAutoscalerStableModeNoChangeAlreadyScaled(t *testing.T) {
 	metrics := &testMetricClient{stableConcurrency: 50.0}
 	a := newTestAutoscaler(10, 100, metrics)
@@ -222,7 +236,108 @@ func TestAutoscalerUpdateTarget(t *testing.T) {
 	a.expectScale(t, time.Now(), 100, expectedEBC(1, 71, 100, 10), true)
 }
 
-type mockReporter struct{}
+func TestAutoscalerLearnedMinScale(t *testing.T) {
+	metrics := &testMetricClient{stableConcurrency: 30}
+	a := newTestAutoscaler(10, 75, metrics)
+	a.Update(DeciderSpec{
+		TargetConcurrency:        10,
+		TotalConcurrency:         10 / targetUtilization,
+		TargetBurstCapacity:      75,
+		PanicThreshold:           20,
+		MaxScaleUpRate:           10,
+		StableWindow:             stableWindow,
+		ServiceName:              testService,
+		LearnedMinScaleMax:       2,
+		LearnedMinScaleRetention: time.Hour,
+	})
+	now := time.Now()
+	endpoints(3)
+	a.expectScale(t, now, 3, expectedEBC(10, 75, 30, 3), true)
+
+	// The traffic stops, but the learned baseline, bounded by the max, is kept.
+	metrics.stableConcurrency = 0
+	now = now.Add(30 * time.Minute)
+	a.expectScale(t, now, 2, expectedEBC(10, 75, 0, 3), true)
+
+	// The traffic comes back, at a lower rate.
+	metrics.stableConcurrency = 5
+	now = now.Add(time.Minute)
+	a.expectScale(t, now, 2, expectedEBC(10, 75, 5, 3), true)
+
+	// Until the retention elapses without traffic.
+	metrics.stableConcurrency = 0
+	now = now.Add(time.Hour - time.Second)
+	a.expectScale(t, now, 2, expectedEBC(10, 75, 0, 3), true)
+	now = now.Add(2 * time.Second)
+	a.expectScale(t, now, 0, expectedEBC(10, 75, 0, 3), true)
+}
+
+func TestAutoscalerLearnedMinScaleDisabled(t *testing.T) {
+	metrics := &testMetricClient{stableConcurrency: 30}
+	a := newTestAutoscaler(10, 75, metrics)
+	endpoints(3)
+	a.expectScale(t, time.Now(), 3, expectedEBC(10, 75, 30, 3), true)
+
+	metrics.stableConcurrency = 0
+	a.expectScale(t, time.Now(), 0, expectedEBC(10, 75, 0, 3), true)
+}
+
+func TestAutoscalerScaleSmoothing(t *testing.T) {
+	metrics := &testMetricClient{stableConcurrency: 100}
+	a := newTestAutoscaler(10, 75, metrics)
+	spec := a.currentSpec()
+	spec.ScaleSmoothing = ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA, Weight: 0.5}
+	a.Update(spec)
+	now := time.Now()
+	endpoints(10)
+	a.expectScale(t, now, 10, expectedEBC(10, 75, 100, 10), true)
+
+	// The desired scale doubles, the smoothed one moves half way.
+	metrics.stableConcurrency = 200
+	now = now.Add(2 * time.Second)
+	a.expectScale(t, now, 15, expectedEBC(10, 75, 200, 10), true)
+	now = now.Add(2 * time.Second)
+	a.expectScale(t, now, 18, expectedEBC(10, 75, 200, 10), true)
+
+	// Scaling to zero isn't smoothed.
+	metrics.stableConcurrency = 0
+	now = now.Add(2 * time.Second)
+	a.expectScale(t, now, 0, expectedEBC(10, 75, 0, 10), true)
+}
+
+type fixedScalingStrategy struct {
+	stable, panicPods float64
+}
+
+func (s fixedScalingStrategy) DesiredPodCounts(DeciderSpec, ScalingInput) (float64, float64) {
+	return s.stable, s.panicPods
+}
+
+func TestAutoscalerScalingStrategy(t *testing.T) {
+	RegisterScalingStrategy("fixed", func() ScalingStrategy {
+		return fixedScalingStrategy{stable: 4.5, panicPods: 7}
+	})
+	defer delete(scalingStrategies, "fixed")
+
+	metrics := &testMetricClient{stableConcurrency: 100}
+	a := newTestAutoscaler(10, 75, metrics)
+	spec := a.currentSpec()
+	a.expectScale(t, time.Now(), 10, expectedEBC(10, 75, 100, 1), true)
+
+	// The strategy sizes the revision, rounded up.
+	spec.ScalingStrategy = "fixed"
+	a.Update(spec)
+	a.expectScale(t, time.Now(), 5, expectedEBC(10, 75, 100, 1), true)
+
+	// Unknown strategies fall back to the default one.
+	spec.ScalingStrategy = "unknown"
+	a.Update(spec)
+	a.expectScale(t, time.Now(), 10, expectedEBC(10, 75, 100, 1), true)
+}
+
+type mockReporter struct {
+	decisionLatencies []time.Duration
+}
 
 // ReportDesiredPodCount of a mockReporter does nothing and return nil for error.
 func (r *mockReporter) ReportDesiredPodCount(v int64) error {
@@ -259,6 +374,22 @@ func (r *mockReporter) ReportPanic(v int64) error {
 	return nil
 }
 
+// ReportDecisionLatency of a mockReporter records the latency and return nil for error.
+func (r *mockReporter) ReportDecisionLatency(d time.Duration) error {
+	r.decisionLatencies = append(r.decisionLatencies, d)
+	return nil
+}
+
+// ReportActuationLatency of a mockReporter does nothing and return nil for error.
+func (r *mockReporter) ReportActuationLatency(d time.Duration) error {
+	return nil
+}
+
+// ReportReadyLatency of a mockReporter does nothing and return nil for error.
+func (r *mockReporter) ReportReadyLatency(d time.Duration) error {
+	return nil
+}
+
 func newTestAutoscaler(targetConcurrency, targetBurstCapacity float64, metrics MetricClient) *Autoscaler {
 	deciderSpec := DeciderSpec{
 		TargetConcurrency:   targetConcurrency,
@@ -293,6 +424,7 @@ func (a *Autoscaler) expectScale(t *testing.T, now time.Time, expectScale, expec
 type testMetricClient struct {
 	stableConcurrency float64
 	panicConcurrency  float64
+	statTime          time.Time
 	err               error
 }
 
@@ -300,6 +432,13 @@ func (t *testMetricClient) StableAndPanicConcurrency(key string) (float64, float
 	return t.stableConcurrency, t.panicConcurrency, t.err
 }
 
+func (t *testMetricClient) LatestStatTime(key string) (time.Time, error) {
+	if t.statTime.IsZero() {
+		return time.Time{}, ErrNoData
+	}
+	return t.statTime, t.err
+}
+
 func endpoints(count int) {
 	epAddresses := make([]corev1.EndpointAddress, count)
 	for i := 0; i < count; i++ {
@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ClientQuotaPath is the path of the ClientQuotaCoordinator on the
+	// autoscaler.
+	ClientQuotaPath = "/clientquota"
+
+	// maxClientQuotaReportBytes bounds the size of the reports decoded by
+	// the ClientQuotaCoordinator.
+	maxClientQuotaReportBytes = 1 << 20
+
+	// maxClientQuotaPods and maxClientQuotaClients bound the number of pods
+	// whose reports the ClientQuotaCoordinator keeps for a Revision, and
+	// the number of clients in a report.
+	maxClientQuotaPods    = 1000
+	maxClientQuotaClients = 1000
+)
+
+// ClientQuotaValidator checks the ClientQuotaReports before they are
+// recorded, so that a pod can't report for the clients of other Revisions.
+type ClientQuotaValidator interface {
+	// ValidateClientQuota returns an error if the report received on the
+	// given request must be rejected.
+	ValidateClientQuota(r *http.Request, in *ClientQuotaReport) error
+}
+
+// ClientUsage is the usage of a client of a Revision.
+type ClientUsage struct {
+	// InFlight is the number of requests of the client in flight.
+	InFlight int `json:"inFlight,omitempty"`
+	// Requests is the number of requests the client made in the slice of
+	// the report.
+	Requests int `json:"requests,omitempty"`
+}
+
+// ClientQuotaReport is the usage of the clients of a Revision that a
+// queue-proxy reports to the ClientQuotaCoordinator, and the usage on the
+// other pods of the Revision it gets back.
+type ClientQuotaReport struct {
+	// Key is the namespace/name of the Revision.
+	Key string `json:"key"`
+	Pod string `json:"pod"`
+	// Slice is the second, in Unix time, the requests were counted in.
+	Slice int64                  `json:"slice"`
+	Usage map[string]ClientUsage `json:"usage,omitempty"`
+}
+
+// ClientQuotaCoordinator sums up the usage the pods of each Revision report
+// for its clients, so that their queue-proxies can enforce the client
+// quotas of the Revision as a whole. The reports of a pod expire after a
+// TTL, e.g. once it went away.
+type ClientQuotaCoordinator struct {
+	ttl       time.Duration
+	validator ClientQuotaValidator
+	now       func() time.Time
+
+	mux sync.Mutex
+	// reports are the last reports of the pods of each Revision, and
+	// lastSweep when the expired ones were last removed.
+	reports   map[string]map[string]clientQuotaEntry
+	lastSweep time.Time
+}
+
+type clientQuotaEntry struct {
+	report   ClientQuotaReport
+	received time.Time
+}
+
+// NewClientQuotaCoordinator creates a ClientQuotaCoordinator whose reports
+// expire after ttl, accepting the reports validated by validator.
+func NewClientQuotaCoordinator(ttl time.Duration, validator ClientQuotaValidator) *ClientQuotaCoordinator {
+	return &ClientQuotaCoordinator{
+		ttl:       ttl,
+		validator: validator,
+		now:       time.Now,
+		reports:   make(map[string]map[string]clientQuotaEntry),
+	}
+}
+
+// ServeHTTP decodes the ClientQuotaReport POSTed by a queue-proxy, and
+// responds with the one returned by Report if the validator accepts it.
+func (c *ClientQuotaCoordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var in ClientQuotaReport
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxClientQuotaReportBytes)).Decode(&in); err != nil {
+		http.Error(w, "malformed report: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ns, name, err := cache.SplitMetaNamespaceKey(in.Key); err != nil || ns == "" || name == "" || in.Pod == "" {
+		http.Error(w, "report must have a namespace/name key and a pod", http.StatusBadRequest)
+		return
+	}
+	if len(in.Usage) > maxClientQuotaClients {
+		http.Error(w, "report has too many clients", http.StatusBadRequest)
+		return
+	}
+	if err := c.validator.ValidateClientQuota(r, &in); err != nil {
+		http.Error(w, "report rejected: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Report(in))
+}
+
+// Report records the report of a pod, and returns the usage of the clients
+// on the other pods of its Revision: the requests in flight, and the ones
+// made in the slice of the report. The reports of the pods beyond the
+// first maxClientQuotaPods of a Revision aren't recorded.
+func (c *ClientQuotaCoordinator) Report(in ClientQuotaReport) ClientQuotaReport {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	now := c.now()
+	if now.Sub(c.lastSweep) > c.ttl {
+		c.sweep(now)
+	}
+
+	pods, ok := c.reports[in.Key]
+	if !ok {
+		pods = make(map[string]clientQuotaEntry)
+		c.reports[in.Key] = pods
+	}
+	if _, ok := pods[in.Pod]; ok || len(pods) < maxClientQuotaPods {
+		pods[in.Pod] = clientQuotaEntry{report: in, received: now}
+	}
+
+	out := ClientQuotaReport{Key: in.Key, Pod: in.Pod, Slice: in.Slice, Usage: make(map[string]ClientUsage)}
+	for pod, e := range pods {
+		if pod == in.Pod || now.Sub(e.received) > c.ttl {
+			continue
+		}
+		for client, u := range e.report.Usage {
+			sum := out.Usage[client]
+			sum.InFlight += u.InFlight
+			if e.report.Slice == in.Slice {
+				sum.Requests += u.Requests
+			}
+			out.Usage[client] = sum
+		}
+	}
+	return out
+}
+
+// sweep removes the expired reports. `mux` must be held to call it.
+func (c *ClientQuotaCoordinator) sweep(now time.Time) {
+	c.lastSweep = now
+	for key, pods := range c.reports {
+		for pod, e := range pods {
+			if now.Sub(e.received) > c.ttl {
+				delete(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			delete(c.reports, key)
+		}
+	}
+}
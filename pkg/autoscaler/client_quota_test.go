@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// podValidator accepts the reports of pod only.
+type podValidator string
+
+func (v podValidator) ValidateClientQuota(r *http.Request, in *ClientQuotaReport) error {
+	if in.Pod != string(v) {
+		return errors.New("wrong pod")
+	}
+	return nil
+}
+
+func TestClientQuotaCoordinatorReport(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewClientQuotaCoordinator(2*time.Second, podValidator(""))
+	c.now = func() time.Time { return now }
+
+	c.Report(ClientQuotaReport{Key: "ns/rev", Pod: "a", Slice: 1000, Usage: map[string]ClientUsage{
+		"x": {InFlight: 1, Requests: 2},
+		"y": {Requests: 1},
+	}})
+	c.Report(ClientQuotaReport{Key: "ns/rev", Pod: "b", Slice: 999, Usage: map[string]ClientUsage{
+		"x": {InFlight: 2, Requests: 5},
+	}})
+	// Other revisions don't count.
+	c.Report(ClientQuotaReport{Key: "ns/other", Pod: "d", Slice: 1000, Usage: map[string]ClientUsage{
+		"x": {InFlight: 10, Requests: 10},
+	}})
+
+	// The requests of the reports of another slice don't count.
+	got := c.Report(ClientQuotaReport{Key: "ns/rev", Pod: "c", Slice: 1000})
+	want := ClientQuotaReport{Key: "ns/rev", Pod: "c", Slice: 1000, Usage: map[string]ClientUsage{
+		"x": {InFlight: 3, Requests: 2},
+		"y": {Requests: 1},
+	}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Report() (-want,+got): %s", cmp.Diff(want, got))
+	}
+
+	// Expired reports are dropped.
+	now = now.Add(3 * time.Second)
+	got = c.Report(ClientQuotaReport{Key: "ns/rev", Pod: "c", Slice: 1003})
+	if len(got.Usage) != 0 {
+		t.Errorf("Report() = %v, want no usage", got.Usage)
+	}
+	if _, ok := c.reports["ns/other"]; ok {
+		t.Error("Reports of the expired revision weren't removed")
+	}
+
+	// The reports of too many pods aren't recorded.
+	for i := 0; i < maxClientQuotaPods+10; i++ {
+		c.Report(ClientQuotaReport{Key: "ns/many", Pod: fmt.Sprint("pod-", i), Slice: 1003})
+	}
+	if got, want := len(c.reports["ns/many"]), maxClientQuotaPods; got != want {
+		t.Errorf("Recorded the reports of %d pods, want: %d", got, want)
+	}
+}
+
+func TestClientQuotaCoordinatorServeHTTP(t *testing.T) {
+	c := NewClientQuotaCoordinator(time.Minute, podValidator("a"))
+	tooMany := make([]string, 0, maxClientQuotaClients+1)
+	for i := 0; i <= maxClientQuotaClients; i++ {
+		tooMany = append(tooMany, fmt.Sprintf(`"client-%d":{"inFlight":1}`, i))
+	}
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{{
+		name:   "valid",
+		method: http.MethodPost,
+		body:   `{"key":"ns/rev","pod":"a","slice":1,"usage":{"x":{"inFlight":1}}}`,
+		want:   http.StatusOK,
+	}, {
+		name:   "wrong method",
+		method: http.MethodGet,
+		want:   http.StatusMethodNotAllowed,
+	}, {
+		name:   "malformed",
+		method: http.MethodPost,
+		body:   `{"key":`,
+		want:   http.StatusBadRequest,
+	}, {
+		name:   "missing pod",
+		method: http.MethodPost,
+		body:   `{"key":"ns/rev"}`,
+		want:   http.StatusBadRequest,
+	}, {
+		name:   "rejected",
+		method: http.MethodPost,
+		body:   `{"key":"ns/rev","pod":"b","slice":1,"usage":{"x":{"inFlight":1}}}`,
+		want:   http.StatusForbidden,
+	}, {
+		name:   "too many clients",
+		method: http.MethodPost,
+		body:   `{"key":"ns/rev","pod":"a","slice":1,"usage":{` + strings.Join(tooMany, ",") + `}}`,
+		want:   http.StatusBadRequest,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest(test.method, ClientQuotaPath, strings.NewReader(test.body)))
+			if rec.Code != test.want {
+				t.Errorf("Status = %d, want: %d", rec.Code, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// clockStepThreshold is the change of the clock skew from which we consider
+// the wall clock to have been stepped rather than slewed.
+const clockStepThreshold = time.Second
+
+// ClockSkewMonitor tracks how far the wall clock moved away from the monotonic
+// clock, e.g. as NTP steps the clock of the VM. The metric windows follow the
+// monotonic clock, so such steps don't affect scaling, but they are reported
+// and logged to explain the gaps in timestamps elsewhere.
+type ClockSkewMonitor struct {
+	logger *zap.SugaredLogger
+	start  time.Time
+	skew   time.Duration
+}
+
+// NewClockSkewMonitor creates a ClockSkewMonitor measuring the skew from now on.
+func NewClockSkewMonitor(logger *zap.SugaredLogger) *ClockSkewMonitor {
+	return &ClockSkewMonitor{
+		logger: logger,
+		start:  time.Now(),
+	}
+}
+
+// Run observes the clocks every interval until stopCh is closed.
+func (m *ClockSkewMonitor) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.Observe(time.Now())
+		}
+	}
+}
+
+// Observe reports the skew of the clocks at the given time, which must carry
+// a monotonic clock reading as returned by time.Now.
+func (m *ClockSkewMonitor) Observe(now time.Time) {
+	m.observe(now.Round(0), now.Sub(m.start))
+}
+
+// observe reports the skew of the given wall clock time against the time
+// elapsed on the monotonic clock and returns whether the wall clock stepped.
+func (m *ClockSkewMonitor) observe(wall time.Time, elapsed time.Duration) bool {
+	skew := wall.Sub(m.start.Round(0)) - elapsed
+	step := skew - m.skew
+	m.skew = skew
+
+	ReportClockSkew(skew)
+	if step < clockStepThreshold && step > -clockStepThreshold {
+		return false
+	}
+	m.logger.Warnf("The wall clock stepped by %v, now %v away from the monotonic clock", step, skew)
+	return true
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func TestClockSkewMonitor(t *testing.T) {
+	m := NewClockSkewMonitor(TestLogger(t))
+	wallStart := m.start.Round(0)
+
+	tests := []struct {
+		name     string
+		wall     time.Time
+		elapsed  time.Duration
+		wantSkew time.Duration
+		wantStep bool
+	}{{
+		name:    "in sync",
+		wall:    wallStart.Add(10 * time.Second),
+		elapsed: 10 * time.Second,
+	}, {
+		name:     "slewed",
+		wall:     wallStart.Add(20*time.Second + 100*time.Millisecond),
+		elapsed:  20 * time.Second,
+		wantSkew: 100 * time.Millisecond,
+	}, {
+		name:     "stepped back",
+		wall:     wallStart.Add(-time.Hour),
+		elapsed:  30 * time.Second,
+		wantSkew: -time.Hour - 30*time.Second,
+		wantStep: true,
+	}, {
+		name:     "stays stepped",
+		wall:     wallStart.Add(-time.Hour + 10*time.Second),
+		elapsed:  40 * time.Second,
+		wantSkew: -time.Hour - 30*time.Second,
+	}, {
+		name:     "stepped forward",
+		wall:     wallStart.Add(52 * time.Second),
+		elapsed:  50 * time.Second,
+		wantSkew: 2 * time.Second,
+		wantStep: true,
+	}}
+
+	for _, test := range tests {
+		if got, want := m.observe(test.wall, test.elapsed), test.wantStep; got != want {
+			t.Errorf("%s: stepped = %v, want %v", test.name, got, want)
+		}
+		if got, want := m.skew, test.wantSkew; got != want {
+			t.Errorf("%s: skew = %v, want %v", test.name, got, want)
+		}
+	}
+	assertData(t, "clock_skew", nil, 2000)
+}
+
+func TestClockSkewMonitorObserve(t *testing.T) {
+	m := NewClockSkewMonitor(TestLogger(t))
+	m.Observe(time.Now())
+	if m.skew >= clockStepThreshold || m.skew <= -clockStepThreshold {
+		t.Errorf("skew = %v, want about 0", m.skew)
+	}
+}
@@ -88,6 +88,12 @@ type Stat struct {
 
 	// Part of RequestCount, for requests going through a proxy.
 	ProxiedRequestCount float64
+
+	// Average number of request body bytes currently being handled by this pod.
+	AverageBytesInFlight float64
+
+	// Average duration in seconds of the requests completed since last Stat.
+	AverageRequestDuration float64
 }
 
 // StatMessage wraps a Stat with identifying information so it can be routed
@@ -95,12 +101,21 @@ type Stat struct {
 type StatMessage struct {
 	Key  string
 	Stat Stat
+	// Epoch identifies the process of the pod sending the message, e.g.
+	// by its start time, and increases when the process restarts.
+	Epoch int64
+	// Nonce increases with every message sent by a process, starting at
+	// 1, so that messages can't be replayed.
+	Nonce uint64
 }
 
 // MetricClient surfaces the metrics that can be obtained via the collector.
 type MetricClient interface {
 	// StableAndPanicConcurrency returns both the stable and the panic concurrency.
 	StableAndPanicConcurrency(key string) (float64, float64, error)
+
+	// LatestStatTime returns when the newest stat was observed.
+	LatestStatTime(key string) (time.Time, error)
 }
 
 // MetricCollector manages collection of metrics for many entities.
@@ -217,6 +232,19 @@ func (c *MetricCollector) StableAndPanicConcurrency(key string) (float64, float6
 	return collection.stableAndPanicConcurrency(time.Now())
 }
 
+// LatestStatTime returns when the newest stat was observed.
+func (c *MetricCollector) LatestStatTime(key string) (time.Time, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	collection, exists := c.collections[key]
+	if !exists {
+		return time.Time{}, k8serrors.NewNotFound(kpa.Resource("Metrics"), key)
+	}
+
+	return collection.latestStatTime()
+}
+
 // collection represents the collection of metrics for one specific entity.
 type collection struct {
 	metricMutex sync.RWMutex
@@ -226,6 +254,10 @@ type collection struct {
 	scraper      StatsScraper
 	buckets      *aggregation.TimedFloat64Buckets
 
+	// statTimeMutex guards the time of the newest recorded stat.
+	statTimeMutex sync.RWMutex
+	statTime      time.Time
+
 	grp    sync.WaitGroup
 	stopCh chan struct{}
 }
@@ -298,6 +330,23 @@ func (c *collection) record(stat Stat) {
 	// Proxied requests have been counted at the activator. Subtract
 	// AverageProxiedConcurrentRequests to avoid double counting.
 	c.buckets.Record(*stat.Time, stat.PodName, stat.AverageConcurrentRequests-stat.AverageProxiedConcurrentRequests)
+
+	c.statTimeMutex.Lock()
+	defer c.statTimeMutex.Unlock()
+	if stat.Time.After(c.statTime) {
+		c.statTime = *stat.Time
+	}
+}
+
+// latestStatTime returns when the newest stat was observed.
+func (c *collection) latestStatTime() (time.Time, error) {
+	c.statTimeMutex.RLock()
+	defer c.statTimeMutex.RUnlock()
+
+	if c.statTime.IsZero() {
+		return time.Time{}, ErrNoData
+	}
+	return c.statTime, nil
 }
 
 // stableAndPanicConcurrency calculates both stable and panic concurrency based on the
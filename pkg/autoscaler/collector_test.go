@@ -208,6 +208,39 @@ func TestMetricCollectorRecord(t *testing.T) {
 	}
 }
 
+func TestMetricCollectorLatestStatTime(t *testing.T) {
+	defer ClearAll()
+
+	logger := TestLogger(t)
+	ctx := context.Background()
+
+	metricKey := NewMetricKey(defaultNamespace, defaultName)
+	scraper := &testScraper{
+		s: func() (*StatMessage, error) {
+			return nil, nil
+		},
+	}
+	coll := NewMetricCollector(scraperFactory(scraper, nil), logger)
+
+	if _, err := coll.LatestStatTime(metricKey); err == nil {
+		t.Error("LatestStatTime() = nil, wanted an error for a missing collection")
+	}
+
+	coll.Create(ctx, defaultMetric)
+	if _, err := coll.LatestStatTime(metricKey); err != ErrNoData {
+		t.Errorf("LatestStatTime() = %v, want %v", err, ErrNoData)
+	}
+
+	// The newest stat wins, regardless of the order the stats arrive in.
+	now := time.Now()
+	older := now.Add(-time.Second)
+	coll.Record(metricKey, Stat{Time: &now, PodName: "pod-1"})
+	coll.Record(metricKey, Stat{Time: &older, PodName: "pod-2"})
+	if got, err := coll.LatestStatTime(metricKey); err != nil || !got.Equal(now) {
+		t.Errorf("LatestStatTime() = %v, %v; want %v, nil", got, err, now)
+	}
+}
+
 func scraperFactory(scraper StatsScraper, err error) StatsScraperFactory {
 	return func(*Metric) (StatsScraper, error) {
 		return scraper, err
@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -35,6 +36,20 @@ const (
 type Config struct {
 	// Feature flags.
 	EnableScaleToZero bool
+	// EnableCheckpointRestore is experimental. When set, queue-proxy asks the
+	// runtime integration to checkpoint the first warmed-up pod of a revision,
+	// so that pods created during scale-up can be restored from it. It needs
+	// the concurrency state endpoint of the deployment config to be set.
+	EnableCheckpointRestore bool
+	// EnablePodConsolidation makes the autoscaler prefer removing pods on
+	// nodes that host few pods of the revision when scaling down, so that
+	// those nodes can be reclaimed by the cluster autoscaler.
+	EnablePodConsolidation bool
+	// EnableDynamicContainerConcurrency allows the containerConcurrency of a
+	// revision's PodAutoscaler to be changed in place. The autoscaler then
+	// propagates the new value to the revision's pods, whose queue-proxies
+	// pick it up without a new revision being rolled out.
+	EnableDynamicContainerConcurrency bool
 
 	// Target concurrency knobs for different container concurrency configurations.
 	ContainerConcurrencyTargetFraction float64
@@ -52,6 +67,13 @@ type Config struct {
 	TickInterval time.Duration
 
 	ScaleToZeroGracePeriod time.Duration
+	// LearnedMinScaleRetention is how long a revision that opted into
+	// learning its minimum scale keeps it after its traffic stops.
+	LearnedMinScaleRetention time.Duration
+
+	// ScaleSmoothing smooths the desired scale of the revisions, unless
+	// they choose their own filter.
+	ScaleSmoothing ScaleSmoothing
 }
 
 // NewConfigFromMap creates a Config from the supplied map
@@ -67,6 +89,18 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 		key:          "enable-scale-to-zero",
 		field:        &lc.EnableScaleToZero,
 		defaultValue: true,
+	}, {
+		key:          "enable-checkpoint-restore",
+		field:        &lc.EnableCheckpointRestore,
+		defaultValue: false,
+	}, {
+		key:          "enable-pod-consolidation",
+		field:        &lc.EnablePodConsolidation,
+		defaultValue: false,
+	}, {
+		key:          "enable-dynamic-container-concurrency",
+		field:        &lc.EnableDynamicContainerConcurrency,
+		defaultValue: false,
 	}} {
 		if raw, ok := data[b.key]; !ok {
 			*b.field = b.defaultValue
@@ -106,6 +140,22 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 		key:          "panic-threshold-percentage",
 		field:        &lc.PanicThresholdPercentage,
 		defaultValue: 200.0,
+	}, {
+		key:          "scale-smoothing-ewma-weight",
+		field:        &lc.ScaleSmoothing.Weight,
+		defaultValue: 0.3,
+	}, {
+		key:          "scale-smoothing-pid-proportional-gain",
+		field:        &lc.ScaleSmoothing.ProportionalGain,
+		defaultValue: 0.5,
+	}, {
+		key:          "scale-smoothing-pid-integral-gain",
+		field:        &lc.ScaleSmoothing.IntegralGain,
+		defaultValue: 0.02,
+	}, {
+		key:          "scale-smoothing-pid-derivative-gain",
+		field:        &lc.ScaleSmoothing.DerivativeGain,
+		defaultValue: 0,
 	}} {
 		if raw, ok := data[f64.key]; !ok {
 			*f64.field = f64.defaultValue
@@ -116,6 +166,11 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 		}
 	}
 
+	lc.ScaleSmoothing.Filter = autoscaling.ScaleSmoothingNone
+	if raw, ok := data["scale-smoothing"]; ok {
+		lc.ScaleSmoothing.Filter = raw
+	}
+
 	// Adjust % ⇒ fractions: for legacy reasons we allow values in the
 	// (0, 1] interval, so minimal percentage must be greater than 1.0.
 	// Internally we want to have fractions, since otherwise we'll have
@@ -141,6 +196,10 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 		key:          "scale-to-zero-grace-period",
 		field:        &lc.ScaleToZeroGracePeriod,
 		defaultValue: 30 * time.Second,
+	}, {
+		key:          "learned-min-scale-retention",
+		field:        &lc.LearnedMinScaleRetention,
+		defaultValue: time.Hour,
 	}, {
 		key:          "tick-interval",
 		field:        &lc.TickInterval,
@@ -162,6 +221,23 @@ func validate(lc *Config) (*Config, error) {
 	if lc.ScaleToZeroGracePeriod < 30*time.Second {
 		return nil, fmt.Errorf("scale-to-zero-grace-period must be at least 30s, got %v", lc.ScaleToZeroGracePeriod)
 	}
+	if lc.LearnedMinScaleRetention < 0 {
+		return nil, fmt.Errorf("learned-min-scale-retention must be non-negative, got %v", lc.LearnedMinScaleRetention)
+	}
+	if !autoscaling.IsScaleSmoothing(lc.ScaleSmoothing.Filter) {
+		return nil, fmt.Errorf("scale-smoothing must be one of %s, %s or %s, got %q", autoscaling.ScaleSmoothingNone,
+			autoscaling.ScaleSmoothingEWMA, autoscaling.ScaleSmoothingPID, lc.ScaleSmoothing.Filter)
+	}
+	if lc.ScaleSmoothing.Weight <= 0 || lc.ScaleSmoothing.Weight > 1 {
+		return nil, fmt.Errorf("scale-smoothing-ewma-weight = %f is outside of valid range of (0, 1]", lc.ScaleSmoothing.Weight)
+	}
+	if lc.ScaleSmoothing.ProportionalGain <= 0 {
+		return nil, fmt.Errorf("scale-smoothing-pid-proportional-gain must be positive, got %f", lc.ScaleSmoothing.ProportionalGain)
+	}
+	if lc.ScaleSmoothing.IntegralGain < 0 || lc.ScaleSmoothing.DerivativeGain < 0 {
+		return nil, fmt.Errorf("scale-smoothing-pid-integral-gain and scale-smoothing-pid-derivative-gain must be non-negative, got %f and %f",
+			lc.ScaleSmoothing.IntegralGain, lc.ScaleSmoothing.DerivativeGain)
+	}
 	if lc.TargetBurstCapacity < 0 {
 		return nil, fmt.Errorf("target-burst-capacity must be non-negative, got %f", lc.TargetBurstCapacity)
 	}
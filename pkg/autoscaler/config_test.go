@@ -21,11 +21,19 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	corev1 "k8s.io/api/core/v1"
 
 	. "knative.dev/pkg/configmap/testing"
 )
 
+var defaultScaleSmoothing = ScaleSmoothing{
+	Filter:           autoscaling.ScaleSmoothingNone,
+	Weight:           0.3,
+	ProportionalGain: 0.5,
+	IntegralGain:     0.02,
+}
+
 func TestNewConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -54,6 +62,8 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
@@ -81,6 +91,8 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
@@ -108,6 +120,98 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
+			TickInterval:                       2 * time.Second,
+			PanicWindowPercentage:              10.0,
+			PanicThresholdPercentage:           200.0,
+		},
+	}, {
+		name: "with checkpoint restore",
+		input: map[string]string{
+			"enable-checkpoint-restore":               "true",
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"target-burst-capacity":                   "0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+		},
+		want: &Config{
+			EnableScaleToZero:                  true,
+			EnableCheckpointRestore:            true,
+			ContainerConcurrencyTargetFraction: 0.5,
+			ContainerConcurrencyTargetDefault:  10.0,
+			TargetBurstCapacity:                0,
+			MaxScaleUpRate:                     1.0,
+			StableWindow:                       5 * time.Minute,
+			PanicWindow:                        10 * time.Second,
+			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
+			TickInterval:                       2 * time.Second,
+			PanicWindowPercentage:              10.0,
+			PanicThresholdPercentage:           200.0,
+		},
+	}, {
+		name: "with pod consolidation",
+		input: map[string]string{
+			"enable-pod-consolidation":                "true",
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"target-burst-capacity":                   "0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+		},
+		want: &Config{
+			EnableScaleToZero:                  true,
+			EnablePodConsolidation:             true,
+			ContainerConcurrencyTargetFraction: 0.5,
+			ContainerConcurrencyTargetDefault:  10.0,
+			TargetBurstCapacity:                0,
+			MaxScaleUpRate:                     1.0,
+			StableWindow:                       5 * time.Minute,
+			PanicWindow:                        10 * time.Second,
+			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
+			TickInterval:                       2 * time.Second,
+			PanicWindowPercentage:              10.0,
+			PanicThresholdPercentage:           200.0,
+		},
+	}, {
+		name: "with dynamic container concurrency",
+		input: map[string]string{
+			"enable-dynamic-container-concurrency":    "true",
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"target-burst-capacity":                   "0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+		},
+		want: &Config{
+			EnableScaleToZero:                  true,
+			EnableDynamicContainerConcurrency:  true,
+			ContainerConcurrencyTargetFraction: 0.5,
+			ContainerConcurrencyTargetDefault:  10.0,
+			TargetBurstCapacity:                0,
+			MaxScaleUpRate:                     1.0,
+			StableWindow:                       5 * time.Minute,
+			PanicWindow:                        10 * time.Second,
+			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
@@ -135,6 +239,8 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
@@ -159,6 +265,8 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
@@ -184,10 +292,104 @@ func TestNewConfig(t *testing.T) {
 			StableWindow:                       5 * time.Minute,
 			PanicWindow:                        10 * time.Second,
 			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing:                     defaultScaleSmoothing,
 			TickInterval:                       2 * time.Second,
 			PanicWindowPercentage:              10.0,
 			PanicThresholdPercentage:           200.0,
 		},
+	}, {
+		name: "with scale smoothing",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+			"scale-smoothing":                         "pid",
+			"scale-smoothing-ewma-weight":             "1",
+			"scale-smoothing-pid-proportional-gain":   "0.6",
+			"scale-smoothing-pid-integral-gain":       "0",
+			"scale-smoothing-pid-derivative-gain":     "0.25",
+		},
+		want: &Config{
+			EnableScaleToZero:                  true,
+			ContainerConcurrencyTargetFraction: 0.5,
+			ContainerConcurrencyTargetDefault:  10.0,
+			MaxScaleUpRate:                     1.0,
+			StableWindow:                       5 * time.Minute,
+			PanicWindow:                        10 * time.Second,
+			ScaleToZeroGracePeriod:             30 * time.Second,
+			LearnedMinScaleRetention:           time.Hour,
+			ScaleSmoothing: ScaleSmoothing{
+				Filter:           autoscaling.ScaleSmoothingPID,
+				Weight:           1,
+				ProportionalGain: 0.6,
+				DerivativeGain:   0.25,
+			},
+			TickInterval:             2 * time.Second,
+			PanicWindowPercentage:    10.0,
+			PanicThresholdPercentage: 200.0,
+		},
+	}, {
+		name: "unknown scale smoothing",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+			"scale-smoothing":                         "kalman",
+		},
+		wantErr: true,
+	}, {
+		name: "scale smoothing weight out of range",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+			"scale-smoothing-ewma-weight":             "1.5",
+		},
+		wantErr: true,
+	}, {
+		name: "zero scale smoothing proportional gain",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+			"scale-smoothing-pid-proportional-gain":   "0",
+		},
+		wantErr: true,
+	}, {
+		name: "negative scale smoothing integral gain",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"panic-window-percentage":                 "10",
+			"panic-threshold-percentage":              "200",
+			"scale-smoothing-pid-integral-gain":       "-0.1",
+		},
+		wantErr: true,
 	}, {
 		name: "malformed float",
 		input: map[string]string{
@@ -228,6 +430,18 @@ func TestNewConfig(t *testing.T) {
 			"panic-threshold-percentage":              "200",
 		},
 		wantErr: true,
+	}, {
+		name: "negative learned min scale retention",
+		input: map[string]string{
+			"max-scale-up-rate":                       "1.0",
+			"container-concurrency-target-percentage": "0.5",
+			"container-concurrency-target-default":    "10.0",
+			"stable-window":                           "5m",
+			"panic-window":                            "10s",
+			"tick-interval":                           "2s",
+			"learned-min-scale-retention":             "-1m",
+		},
+		wantErr: true,
 	}, {
 		name: "invalid target %, too small",
 		input: map[string]string{
@@ -21,6 +21,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/knative/serving/pkg/network"
 	"github.com/pkg/errors"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
@@ -28,6 +29,8 @@ import (
 
 type httpScrapeClient struct {
 	httpClient *http.Client
+	// token is sent as bearer token to the queue-proxies, if set.
+	token string
 }
 
 func newHTTPScrapeClient(httpClient *http.Client) (*httpScrapeClient, error) {
@@ -45,6 +48,10 @@ func (c *httpScrapeClient) Scrape(url string) (*Stat, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set(network.ProtocolVersionHeaderName, network.CurrentProtocolVersion.String())
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -54,10 +61,12 @@ func (c *httpScrapeClient) Scrape(url string) (*Stat, error) {
 		return nil, fmt.Errorf("GET request for URL %q returned HTTP status %v", url, resp.StatusCode)
 	}
 
-	return extractData(resp.Body)
+	return extractData(resp.Body, network.ParseProtocolVersion(resp.Header))
 }
 
-func extractData(body io.Reader) (*Stat, error) {
+// extractData parses the stat reported by a queue-proxy speaking the given
+// version of the data plane protocol.
+func extractData(body io.Reader, version network.ProtocolVersion) (*Stat, error) {
 	var parser expfmt.TextParser
 	metricFamilies, err := parser.TextToMetricFamilies(body)
 	if err != nil {
@@ -84,6 +93,22 @@ func extractData(body io.Reader) (*Stat, error) {
 			}
 		}
 	}
+
+	// These metrics are not reported by all the queue-proxies speaking
+	// ProtocolV1, so they are optional for them.
+	for m, pv := range map[string]*float64{
+		"queue_average_bytes_in_flight":          &stat.AverageBytesInFlight,
+		"queue_average_request_duration_seconds": &stat.AverageRequestDuration,
+	} {
+		pm := prometheusMetric(metricFamilies, m)
+		if pm == nil {
+			if version >= network.ProtocolV2 {
+				return nil, fmt.Errorf("could not find value for %s in response of protocol version %v", m, version)
+			}
+			continue
+		}
+		*pv = *pm.Gauge.Value
+	}
 	return &stat, nil
 }
 
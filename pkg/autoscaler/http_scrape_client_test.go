@@ -23,6 +23,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+
+	"github.com/knative/serving/pkg/network"
 )
 
 const (
@@ -44,6 +46,14 @@ queue_average_proxied_concurrent_requests{destination_namespace="test-namespace"
 	testProxiedQPSContext = `# HELP queue_proxied_operations_per_second Number of proxied requests received since last Stat
 # TYPE queue_proxied_operations_per_second gauge
 queue_proxied_operations_per_second{destination_namespace="test-namespace",destination_revision="test-revision",destination_pod="test-revision-1234"} 4
+`
+	testBytesInFlightContext = `# HELP queue_average_bytes_in_flight Number of request body bytes currently being handled by this pod
+# TYPE queue_average_bytes_in_flight gauge
+queue_average_bytes_in_flight{destination_namespace="test-namespace",destination_revision="test-revision",destination_pod="test-revision-1234"} 2048
+`
+	testRequestDurationContext = `# HELP queue_average_request_duration_seconds Average duration of the requests completed by this pod
+# TYPE queue_average_request_duration_seconds gauge
+queue_average_request_duration_seconds{destination_namespace="test-namespace",destination_revision="test-revision",destination_pod="test-revision-1234"} 0.25
 `
 	testFullContext = testAverageConcurrencyContext + testQPSContext + testAverageProxiedConcurrenyContext + testProxiedQPSContext
 )
@@ -99,6 +109,123 @@ func TestHTTPScrapeClient_Scrape_HappyCase(t *testing.T) {
 	if stat.PodName != "test-revision-1234" {
 		t.Errorf("stat.PodName = %s, want test-revision-1234", stat.PodName)
 	}
+	if stat.AverageBytesInFlight != 0 {
+		t.Errorf("stat.AverageBytesInFlight = %v, want 0", stat.AverageBytesInFlight)
+	}
+}
+
+func TestHTTPScrapeClient_Scrape_BytesAndDuration(t *testing.T) {
+	hClient := newTestHTTPClient(getHTTPResponse(http.StatusOK, testFullContext+testBytesInFlightContext+testRequestDurationContext), nil)
+	sClient, err := newHTTPScrapeClient(hClient)
+	if err != nil {
+		t.Fatalf("newHTTPScrapeClient = %v, want no error", err)
+	}
+
+	stat, err := sClient.Scrape(testURL)
+	if err != nil {
+		t.Fatalf("scrapeViaURL = %v, want no error", err)
+	}
+	if stat.AverageBytesInFlight != 2048 {
+		t.Errorf("stat.AverageBytesInFlight = %v, want 2048", stat.AverageBytesInFlight)
+	}
+	if stat.AverageRequestDuration != 0.25 {
+		t.Errorf("stat.AverageRequestDuration = %v, want 0.25", stat.AverageRequestDuration)
+	}
+}
+
+func TestHTTPScrapeClient_Scrape_ProtocolVersion(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version network.ProtocolVersion
+		context string
+		wantErr string
+	}{{
+		name:    "v1 without bytes and duration",
+		version: network.ProtocolV1,
+		context: testFullContext,
+	}, {
+		name:    "v2 with bytes and duration",
+		version: network.ProtocolV2,
+		context: testFullContext + testBytesInFlightContext + testRequestDurationContext,
+	}, {
+		name:    "v2 without duration",
+		version: network.ProtocolV2,
+		context: testFullContext + testBytesInFlightContext,
+		wantErr: "could not find value for queue_average_request_duration_seconds in response of protocol version 2",
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			var sent string
+			hClient := &http.Client{
+				Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					sent = r.Header.Get(network.ProtocolVersionHeaderName)
+					resp := getHTTPResponse(http.StatusOK, test.context)
+					resp.Header = http.Header{network.ProtocolVersionHeaderName: []string{test.version.String()}}
+					return resp, nil
+				}),
+			}
+			sClient, err := newHTTPScrapeClient(hClient)
+			if err != nil {
+				t.Fatalf("newHTTPScrapeClient = %v, want no error", err)
+			}
+
+			_, err = sClient.Scrape(testURL)
+			if got := errString(err); got != test.wantErr {
+				t.Errorf("Scrape = %q, want: %q", got, test.wantErr)
+			}
+			if got, want := sent, network.CurrentProtocolVersion.String(); got != want {
+				t.Errorf("Sent %s = %q, want: %q", network.ProtocolVersionHeaderName, got, want)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestHTTPScrapeClient_Scrape_Token(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		token string
+		want  string
+	}{{
+		name: "no token",
+	}, {
+		name:  "token",
+		token: "abc",
+		want:  "Bearer abc",
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			hClient := &http.Client{
+				Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					got = r.Header.Get("Authorization")
+					return getHTTPResponse(http.StatusOK, testFullContext), nil
+				}),
+			}
+			sClient, err := newHTTPScrapeClient(hClient)
+			if err != nil {
+				t.Fatalf("newHTTPScrapeClient = %v, want no error", err)
+			}
+			sClient.token = test.token
+
+			if _, err := sClient.Scrape(testURL); err != nil {
+				t.Fatalf("Scrape = %v, want no error", err)
+			}
+			if got != test.want {
+				t.Errorf("Authorization = %q, want %q", got, test.want)
+			}
+		})
+	}
 }
 
 func TestHTTPScrapeClient_Scrape_ErrorCases(t *testing.T) {
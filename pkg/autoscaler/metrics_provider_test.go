@@ -20,6 +20,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"knative.dev/pkg/kmp"
 	"github.com/knative/serving/pkg/apis/autoscaling"
@@ -117,3 +118,7 @@ func (s staticConcurrency) StableAndPanicConcurrency(key string) (float64, float
 	}
 	return 0.0, 0.0, errors.New("doesn't exist")
 }
+
+func (s staticConcurrency) LatestStatTime(key string) (time.Time, error) {
+	return time.Time{}, ErrNoData
+}
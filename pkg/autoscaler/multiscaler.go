@@ -56,6 +56,17 @@ type DeciderSpec struct {
 	StableWindow time.Duration
 	// The name of the k8s service for pod information.
 	ServiceName string
+	// LearnedMinScaleMax bounds the minimum scale learned from the
+	// baseline load of the revision. 0 disables learning.
+	LearnedMinScaleMax int32
+	// LearnedMinScaleRetention is how long the learned minimum scale is
+	// kept after the revision stops receiving traffic.
+	LearnedMinScaleRetention time.Duration
+	// ScalingStrategy is the name of the ScalingStrategy computing the
+	// desired scale, the DefaultScalingStrategy if empty.
+	ScalingStrategy string
+	// ScaleSmoothing smooths the desired scale in stable mode.
+	ScaleSmoothing ScaleSmoothing
 }
 
 // DeciderStatus is the current scale recommendation.
@@ -67,6 +78,8 @@ type DeciderStatus struct {
 	// If this number is negative: Activator will be threaded in
 	// by the PodAutoscaler controller.
 	ExcessBurstCapacity int32
+	// DecisionTime is when DesiredScale was last changed.
+	DecisionTime metav1.Time
 }
 
 // UniScaler records statistics for a particular Decider and proposes the scale for the Decider's target based on those statistics.
@@ -104,12 +117,13 @@ func sameSign(a, b int32) bool {
 	return (a&math.MinInt32)^(b&math.MinInt32) == 0
 }
 
-func (sr *scalerRunner) updateLatestScale(proposed, ebc int32) bool {
+func (sr *scalerRunner) updateLatestScale(proposed, ebc int32, now time.Time) bool {
 	ret := false
 	sr.mux.Lock()
 	defer sr.mux.Unlock()
 	if sr.decider.Status.DesiredScale != proposed {
 		sr.decider.Status.DesiredScale = proposed
+		sr.decider.Status.DecisionTime = metav1.NewTime(now)
 		ret = true
 	}
 
@@ -289,7 +303,8 @@ func (m *MultiScaler) createScaler(ctx context.Context, decider *Decider) (*scal
 
 func (m *MultiScaler) tickScaler(ctx context.Context, scaler UniScaler, runner *scalerRunner, metricKey string) {
 	logger := logging.FromContext(ctx)
-	desiredScale, excessBC, scaled := scaler.Scale(ctx, time.Now())
+	now := time.Now()
+	desiredScale, excessBC, scaled := scaler.Scale(ctx, now)
 
 	if !scaled {
 		return
@@ -301,7 +316,7 @@ func (m *MultiScaler) tickScaler(ctx context.Context, scaler UniScaler, runner *
 		return
 	}
 
-	if runner.updateLatestScale(desiredScale, excessBC) {
+	if runner.updateLatestScale(desiredScale, excessBC, now) {
 		m.Inform(metricKey)
 	}
 }
@@ -269,7 +269,7 @@ func TestMultiScalerScaleFromZero(t *testing.T) {
 	metricKey := NewMetricKey(decider.Namespace, decider.Name)
 	if scaler, exists := ms.scalers[metricKey]; !exists {
 		t.Errorf("Failed to get scaler for metric %s", metricKey)
-	} else if !scaler.updateLatestScale(0, 10) {
+	} else if !scaler.updateLatestScale(0, 10, time.Now()) {
 		t.Error("Failed to set scale for metric to 0")
 	}
 
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"math"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+)
+
+// ScaleSmoothing is the filter smoothing the desired scale of a revision
+// over the scaling decisions, with its parameters.
+type ScaleSmoothing struct {
+	// Filter is autoscaling.ScaleSmoothingNone, ScaleSmoothingEWMA or
+	// ScaleSmoothingPID. Empty means none.
+	Filter string
+	// Weight is the weight of each new desired scale in the EWMA, in (0, 1].
+	Weight float64
+	// The gains of the PID controller, applied to the difference between
+	// the desired scale and the smoothed one, the integral gain per second
+	// and the derivative gain in seconds.
+	ProportionalGain float64
+	IntegralGain     float64
+	DerivativeGain   float64
+}
+
+// scaleFilter smooths the desired scale of a revision over the scaling
+// decisions.
+type scaleFilter interface {
+	// filter returns the smoothed scale for the desired one at now.
+	filter(desired float64, now time.Time) float64
+	// reset restarts the filter from scale, e.g. once panic mode, which
+	// isn't smoothed, picked the scale instead of the filter.
+	reset(scale float64, now time.Time)
+}
+
+// newScaleFilter returns the filter of s, or nil if it doesn't smooth.
+func newScaleFilter(s ScaleSmoothing) scaleFilter {
+	switch s.Filter {
+	case autoscaling.ScaleSmoothingEWMA:
+		return &ewmaScaleFilter{weight: s.Weight}
+	case autoscaling.ScaleSmoothingPID:
+		return &pidScaleFilter{
+			proportionalGain: s.ProportionalGain,
+			integralGain:     s.IntegralGain,
+			derivativeGain:   s.DerivativeGain,
+		}
+	}
+	return nil
+}
+
+// ewmaScaleFilter smooths the desired scale with an exponentially weighted
+// moving average.
+type ewmaScaleFilter struct {
+	weight float64
+
+	started bool
+	scale   float64
+}
+
+func (f *ewmaScaleFilter) filter(desired float64, _ time.Time) float64 {
+	if !f.started {
+		f.started, f.scale = true, desired
+	} else {
+		f.scale += f.weight * (desired - f.scale)
+	}
+	return f.scale
+}
+
+func (f *ewmaScaleFilter) reset(scale float64, _ time.Time) {
+	f.started, f.scale = true, scale
+}
+
+// pidScaleFilter has the smoothed scale track the desired one with a PID
+// controller. The integral is reset whenever the difference changes sign,
+// for it not to wind up while the scale catches up.
+type pidScaleFilter struct {
+	proportionalGain float64
+	integralGain     float64
+	derivativeGain   float64
+
+	started   bool
+	scale     float64
+	lastTime  time.Time
+	lastError float64
+	integral  float64
+}
+
+func (f *pidScaleFilter) filter(desired float64, now time.Time) float64 {
+	if !f.started {
+		f.reset(desired, now)
+		return f.scale
+	}
+
+	e := desired - f.scale
+	if (e > 0) != (f.lastError > 0) {
+		f.integral = 0
+	}
+	var derivative float64
+	if dt := now.Sub(f.lastTime).Seconds(); dt > 0 {
+		f.integral += e * dt
+		derivative = (e - f.lastError) / dt
+	}
+	f.lastTime, f.lastError = now, e
+
+	f.scale = math.Max(0, f.scale+f.proportionalGain*e+f.integralGain*f.integral+f.derivativeGain*derivative)
+	return f.scale
+}
+
+func (f *pidScaleFilter) reset(scale float64, now time.Time) {
+	f.started, f.scale = true, scale
+	f.lastTime, f.lastError, f.integral = now, 0, 0
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+)
+
+func TestNewScaleFilter(t *testing.T) {
+	for _, filter := range []string{"", autoscaling.ScaleSmoothingNone} {
+		if f := newScaleFilter(ScaleSmoothing{Filter: filter}); f != nil {
+			t.Errorf("newScaleFilter(%q) = %T, want: nil", filter, f)
+		}
+	}
+	if f, ok := newScaleFilter(ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA}).(*ewmaScaleFilter); !ok {
+		t.Errorf("newScaleFilter(ewma) = %T, want: *ewmaScaleFilter", f)
+	}
+	if f, ok := newScaleFilter(ScaleSmoothing{Filter: autoscaling.ScaleSmoothingPID}).(*pidScaleFilter); !ok {
+		t.Errorf("newScaleFilter(pid) = %T, want: *pidScaleFilter", f)
+	}
+}
+
+func TestEWMAScaleFilter(t *testing.T) {
+	f := newScaleFilter(ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA, Weight: 0.5})
+	now := time.Now()
+
+	for _, step := range []struct {
+		desired, want float64
+	}{
+		{10, 10}, // Starts from the first desired scale.
+		{20, 15},
+		{20, 17.5},
+		{10, 13.75},
+	} {
+		if got := f.filter(step.desired, now); got != step.want {
+			t.Errorf("filter(%v) = %v, want: %v", step.desired, got, step.want)
+		}
+	}
+
+	f.reset(30, now)
+	if got, want := f.filter(20, now), 25.0; got != want {
+		t.Errorf("filter(20) after reset(30) = %v, want: %v", got, want)
+	}
+}
+
+func TestPIDScaleFilter(t *testing.T) {
+	f := newScaleFilter(ScaleSmoothing{
+		Filter:           autoscaling.ScaleSmoothingPID,
+		ProportionalGain: 0.5,
+		IntegralGain:     0.05,
+		DerivativeGain:   0.1,
+	})
+	now := time.Now()
+
+	if got, want := f.filter(10, now), 10.0; got != want {
+		t.Errorf("First filter(10) = %v, want: %v", got, want)
+	}
+
+	// The scale moves towards a new desired scale without jumping to it,
+	// and settles on it.
+	now = now.Add(2 * time.Second)
+	if got := f.filter(20, now); got <= 10 || got >= 20 {
+		t.Errorf("filter(20) = %v, want in (10, 20)", got)
+	}
+	var got float64
+	for i := 0; i < 20; i++ {
+		now = now.Add(2 * time.Second)
+		got = f.filter(20, now)
+	}
+	if math.Abs(got-20) > scaleSmoothingTolerance {
+		t.Errorf("Settled on %v, want: 20", got)
+	}
+
+	// An oscillating desired scale is damped.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := 0; i < 20; i++ {
+		now = now.Add(2 * time.Second)
+		desired := 10.0
+		if i%2 == 0 {
+			desired = 30
+		}
+		got := f.filter(desired, now)
+		if i >= 10 {
+			lo, hi = math.Min(lo, got), math.Max(hi, got)
+		}
+	}
+	if hi-lo >= 20 {
+		t.Errorf("Oscillated between %v and %v, want less than the desired scale", lo, hi)
+	}
+}
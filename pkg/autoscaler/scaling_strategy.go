@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// The names of the built-in ScalingStrategies, which revisions select with
+// the scalingStrategy annotation.
+const (
+	// DefaultScalingStrategy sizes a revision for its observed concurrency
+	// to average the target concurrency per pod right away.
+	DefaultScalingStrategy = "default"
+	// PIDScalingStrategy tracks the target concurrency per pod with a PID
+	// controller, which smooths the reaction to changes of the load.
+	PIDScalingStrategy = "pid"
+)
+
+// The gains of the PIDScalingStrategy, applied to the relative deviation of
+// the stable concurrency per pod from the target.
+const (
+	pidProportionalGain = 0.7
+	// pidIntegralGain is per second of deviation.
+	pidIntegralGain = 0.02
+	// pidDerivativeGain is in seconds.
+	pidDerivativeGain = 0.5
+	// pidIntegralLimit bounds the integral, in seconds of deviation, for it
+	// not to wind up while the new pods start.
+	pidIntegralLimit = 10.0
+)
+
+// ScalingInput is what a ScalingStrategy sizes a revision from.
+type ScalingInput struct {
+	// Now is the time of the scaling decision.
+	Now time.Time
+	// ReadyPods is the number of ready pods of the revision, at least 1.
+	ReadyPods float64
+	// StableConcurrency and PanicConcurrency are the total concurrency
+	// observed over the stable and panic windows.
+	StableConcurrency float64
+	PanicConcurrency  float64
+}
+
+// ScalingStrategy is the algorithm computing the number of pods a revision
+// needs, which is the extension point to experiment with other algorithms
+// than the default one. The Autoscaler bounds the counts by the
+// MaxScaleUpRate, rounds them up and picks one of them by its panic mode, so
+// strategies only size the revision. A ScalingStrategy serves one revision
+// and may keep state between its calls, which are never concurrent.
+type ScalingStrategy interface {
+	// DesiredPodCounts returns the number of pods the revision needs in
+	// stable mode and in panic mode.
+	DesiredPodCounts(spec DeciderSpec, in ScalingInput) (stablePods, panicPods float64)
+}
+
+// ScalingStrategyFactory creates the ScalingStrategy of a revision.
+type ScalingStrategyFactory func() ScalingStrategy
+
+var scalingStrategies = map[string]ScalingStrategyFactory{
+	DefaultScalingStrategy: func() ScalingStrategy { return defaultScalingStrategy{} },
+	PIDScalingStrategy:     func() ScalingStrategy { return &pidScalingStrategy{} },
+}
+
+// RegisterScalingStrategy lets revisions select the strategies created by f
+// by name, replacing any strategy registered with the same name. It must be
+// called before the autoscaler starts, e.g. from the init function of a
+// package linked into a downstream build of the autoscaler.
+func RegisterScalingStrategy(name string, f ScalingStrategyFactory) {
+	scalingStrategies[name] = f
+}
+
+// newScalingStrategy creates the ScalingStrategy registered with name, or
+// the DefaultScalingStrategy if name is empty.
+func newScalingStrategy(name string) (ScalingStrategy, error) {
+	if name == "" {
+		name = DefaultScalingStrategy
+	}
+	f, ok := scalingStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scaling strategy %q", name)
+	}
+	return f(), nil
+}
+
+// defaultScalingStrategy needs as many pods as it takes for the observed
+// concurrency to average the target concurrency per pod.
+type defaultScalingStrategy struct{}
+
+func (defaultScalingStrategy) DesiredPodCounts(spec DeciderSpec, in ScalingInput) (float64, float64) {
+	return in.StableConcurrency / spec.TargetConcurrency, in.PanicConcurrency / spec.TargetConcurrency
+}
+
+// pidScalingStrategy scales the ready pods by the output of a PID controller
+// on the relative deviation of the stable concurrency per pod from the
+// target, so that the gains don't depend on the size of the revision. With
+// only a proportional gain of 1 it would be the default strategy. Panic mode
+// is left to the default strategy, to react to bursts right away.
+type pidScalingStrategy struct {
+	lastTime  time.Time
+	lastError float64
+	integral  float64
+}
+
+func (s *pidScalingStrategy) DesiredPodCounts(spec DeciderSpec, in ScalingInput) (float64, float64) {
+	panicPods := in.PanicConcurrency / spec.TargetConcurrency
+	if in.StableConcurrency == 0 {
+		// No traffic needs no pods, whatever the history.
+		*s = pidScalingStrategy{}
+		return 0, panicPods
+	}
+
+	e := in.StableConcurrency/(in.ReadyPods*spec.TargetConcurrency) - 1
+	var derivative float64
+	if !s.lastTime.IsZero() {
+		if dt := in.Now.Sub(s.lastTime).Seconds(); dt > 0 {
+			s.integral = math.Max(-pidIntegralLimit, math.Min(pidIntegralLimit, s.integral+e*dt))
+			derivative = (e - s.lastError) / dt
+		}
+	}
+	s.lastTime, s.lastError = in.Now, e
+
+	stablePods := in.ReadyPods * (1 + pidProportionalGain*e + pidIntegralGain*s.integral + pidDerivativeGain*derivative)
+	return math.Max(0, stablePods), panicPods
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewScalingStrategy(t *testing.T) {
+	for _, name := range []string{"", DefaultScalingStrategy} {
+		if s, err := newScalingStrategy(name); err != nil {
+			t.Errorf("newScalingStrategy(%q) = %v", name, err)
+		} else if _, ok := s.(defaultScalingStrategy); !ok {
+			t.Errorf("newScalingStrategy(%q) = %T, want: defaultScalingStrategy", name, s)
+		}
+	}
+	if s, err := newScalingStrategy(PIDScalingStrategy); err != nil {
+		t.Errorf("newScalingStrategy(%q) = %v", PIDScalingStrategy, err)
+	} else if _, ok := s.(*pidScalingStrategy); !ok {
+		t.Errorf("newScalingStrategy(%q) = %T, want: *pidScalingStrategy", PIDScalingStrategy, s)
+	}
+	if _, err := newScalingStrategy("unknown"); err == nil {
+		t.Error("newScalingStrategy(unknown) = nil, want an error")
+	}
+}
+
+func TestDefaultScalingStrategy(t *testing.T) {
+	stable, panicPods := defaultScalingStrategy{}.DesiredPodCounts(DeciderSpec{TargetConcurrency: 10}, ScalingInput{
+		ReadyPods:         3,
+		StableConcurrency: 25,
+		PanicConcurrency:  80,
+	})
+	if stable != 2.5 || panicPods != 8 {
+		t.Errorf("DesiredPodCounts() = %v, %v, want: 2.5, 8", stable, panicPods)
+	}
+}
+
+func TestPIDScalingStrategy(t *testing.T) {
+	spec := DeciderSpec{TargetConcurrency: 1}
+	s := &pidScalingStrategy{}
+	now := time.Now()
+
+	// On target, the revision keeps its size.
+	if stable, _ := s.DesiredPodCounts(spec, ScalingInput{Now: now, ReadyPods: 10, StableConcurrency: 10}); stable != 10 {
+		t.Errorf("DesiredPodCounts() on target = %v, want: 10", stable)
+	}
+
+	// The load doubles: the revision moves towards twice its size, but
+	// only by the proportional gain, while panic mode sizes it right away.
+	now = now.Add(2 * time.Second)
+	stable, panicPods := s.DesiredPodCounts(spec, ScalingInput{Now: now, ReadyPods: 10, StableConcurrency: 20, PanicConcurrency: 20})
+	if stable <= 10 || stable >= 20 {
+		t.Errorf("DesiredPodCounts() after the load doubled = %v, want in (10, 20)", stable)
+	}
+	if panicPods != 20 {
+		t.Errorf("DesiredPodCounts() panic = %v, want: 20", panicPods)
+	}
+
+	// It settles on the size the default strategy would have picked.
+	ready := math.Ceil(stable)
+	for i := 0; i < 30; i++ {
+		now = now.Add(2 * time.Second)
+		stable, _ = s.DesiredPodCounts(spec, ScalingInput{Now: now, ReadyPods: ready, StableConcurrency: 20})
+		ready = math.Max(1, math.Ceil(stable))
+	}
+	if ready != 20 {
+		t.Errorf("Settled on %v pods, want: 20", ready)
+	}
+
+	// Without traffic, no pods are needed.
+	now = now.Add(2 * time.Second)
+	if stable, _ := s.DesiredPodCounts(spec, ScalingInput{Now: now, ReadyPods: ready}); stable != 0 {
+		t.Errorf("DesiredPodCounts() without traffic = %v, want: 0", stable)
+	}
+}
@@ -19,6 +19,7 @@ package autoscaler
 import (
 	"context"
 	"errors"
+	"time"
 
 	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/metrics/metricskey"
@@ -57,6 +58,27 @@ var (
 		"panic_mode",
 		"1 if autoscaler is in panic mode, 0 otherwise",
 		stats.UnitDimensionless)
+	decisionLatencyM = stats.Float64(
+		"scaling_decision_latencies",
+		"The time from the newest observed metric to the desired scale being computed",
+		stats.UnitMilliseconds)
+	actuationLatencyM = stats.Float64(
+		"scaling_actuation_latencies",
+		"The time from the desired scale being computed to the scale target being patched",
+		stats.UnitMilliseconds)
+	readyLatencyM = stats.Float64(
+		"scaling_ready_latencies",
+		"The time from the scale target being patched to the desired number of pods being ready",
+		stats.UnitMilliseconds)
+	clockSkewM = stats.Float64(
+		"clock_skew",
+		"The time the wall clock moved away from the monotonic clock since the autoscaler started",
+		stats.UnitMilliseconds)
+
+	// Scaling spans from milliseconds for a decision to minutes for pods
+	// that need a new node to be ready.
+	scalingLatencyDistribution = view.Distribution(0, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000, 120000, 300000, 600000)
+
 	namespaceTagKey tag.Key
 	configTagKey    tag.Key
 	revisionTagKey  tag.Key
@@ -133,6 +155,29 @@ func init() {
 			Aggregation: view.LastValue(),
 			TagKeys:     []tag.Key{namespaceTagKey, serviceTagKey, configTagKey, revisionTagKey},
 		},
+		&view.View{
+			Description: "The time from the newest observed metric to the desired scale being computed",
+			Measure:     decisionLatencyM,
+			Aggregation: scalingLatencyDistribution,
+			TagKeys:     []tag.Key{namespaceTagKey, serviceTagKey, configTagKey, revisionTagKey},
+		},
+		&view.View{
+			Description: "The time from the desired scale being computed to the scale target being patched",
+			Measure:     actuationLatencyM,
+			Aggregation: scalingLatencyDistribution,
+			TagKeys:     []tag.Key{namespaceTagKey, serviceTagKey, configTagKey, revisionTagKey},
+		},
+		&view.View{
+			Description: "The time from the scale target being patched to the desired number of pods being ready",
+			Measure:     readyLatencyM,
+			Aggregation: scalingLatencyDistribution,
+			TagKeys:     []tag.Key{namespaceTagKey, serviceTagKey, configTagKey, revisionTagKey},
+		},
+		&view.View{
+			Description: "The time the wall clock moved away from the monotonic clock since the autoscaler started",
+			Measure:     clockSkewM,
+			Aggregation: view.LastValue(),
+		},
 	)
 	if err != nil {
 		panic(err)
@@ -149,6 +194,9 @@ type StatsReporter interface {
 	ReportPanicRequestConcurrency(v float64) error
 	ReportTargetRequestConcurrency(v float64) error
 	ReportPanic(v int64) error
+	ReportDecisionLatency(d time.Duration) error
+	ReportActuationLatency(d time.Duration) error
+	ReportReadyLatency(d time.Duration) error
 }
 
 // Reporter holds cached metric objects to report autoscaler metrics
@@ -222,6 +270,31 @@ func (r *Reporter) ReportPanic(v int64) error {
 	return r.report(panicM.M(v))
 }
 
+// ReportDecisionLatency captures duration d for the scaling decision latency measure.
+func (r *Reporter) ReportDecisionLatency(d time.Duration) error {
+	return r.report(decisionLatencyM.M(durationMillis(d)))
+}
+
+// ReportActuationLatency captures duration d for the scaling actuation latency measure.
+func (r *Reporter) ReportActuationLatency(d time.Duration) error {
+	return r.report(actuationLatencyM.M(durationMillis(d)))
+}
+
+// ReportReadyLatency captures duration d for the scaling ready latency measure.
+func (r *Reporter) ReportReadyLatency(d time.Duration) error {
+	return r.report(readyLatencyM.M(durationMillis(d)))
+}
+
+// ReportClockSkew captures duration d for the clock skew measure. The clock
+// is shared by all the revisions, so the measure isn't tagged.
+func ReportClockSkew(d time.Duration) {
+	metrics.Record(context.Background(), clockSkewM.M(durationMillis(d)))
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d / time.Millisecond)
+}
+
 func (r *Reporter) report(m stats.Measurement) error {
 	if !r.initialized {
 		return errors.New("StatsReporter is not initialized yet")
@@ -113,6 +113,45 @@ func TestReporter_EmptyServiceName(t *testing.T) {
 	assertData(t, "desired_pods", wantTags, 10)
 }
 
+func TestReporter_ScalingLatencies(t *testing.T) {
+	// The distributions accumulate, so drop what a previous run of the
+	// test with -count > 1 recorded.
+	resetViews(t, "scaling_decision_latencies", "scaling_actuation_latencies", "scaling_ready_latencies")
+
+	r, _ := NewStatsReporter("testns", "testsvc", "testconfig", "latencyrev")
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     "testns",
+		metricskey.LabelServiceName:       "testsvc",
+		metricskey.LabelConfigurationName: "testconfig",
+		metricskey.LabelRevisionName:      "latencyrev",
+	}
+
+	expectSuccess(t, "ReportDecisionLatency", func() error { return r.ReportDecisionLatency(1500 * time.Millisecond) })
+	expectSuccess(t, "ReportActuationLatency", func() error { return r.ReportActuationLatency(20 * time.Millisecond) })
+	expectSuccess(t, "ReportActuationLatency", func() error { return r.ReportActuationLatency(40 * time.Millisecond) })
+	expectSuccess(t, "ReportReadyLatency", func() error { return r.ReportReadyLatency(time.Minute) })
+	assertDistribution(t, "scaling_decision_latencies", wantTags, 1, 1500, 1500)
+	assertDistribution(t, "scaling_actuation_latencies", wantTags, 2, 20, 40)
+	assertDistribution(t, "scaling_ready_latencies", wantTags, 1, 60000, 60000)
+}
+
+func TestReportClockSkew(t *testing.T) {
+	ReportClockSkew(-1500 * time.Millisecond)
+	assertData(t, "clock_skew", nil, -1500)
+}
+
+// resetViews drops the data recorded by the named views by registering them
+// anew.
+func resetViews(t *testing.T, names ...string) {
+	for _, name := range names {
+		v := view.Find(name)
+		view.Unregister(v)
+		if err := view.Register(v); err != nil {
+			t.Fatalf("Failed to register the %s view: %v", name, err)
+		}
+	}
+}
+
 func expectSuccess(t *testing.T, funcName string, f func() error) {
 	if err := f(); err != nil {
 		t.Errorf("Reporter.%v() expected success but got error %v", funcName, err)
@@ -166,3 +205,46 @@ func checkData(name string, wantTags map[string]string, wantValue float64) error
 
 	return nil
 }
+
+func assertDistribution(t *testing.T, name string, wantTags map[string]string, wantCount int64, wantMin, wantMax float64) {
+	var err error
+	wait.PollImmediate(1*time.Millisecond, 2*time.Second, func() (bool, error) {
+		if err = checkDistribution(name, wantTags, wantCount, wantMin, wantMax); err != nil {
+			return false, nil
+		}
+		return true, nil
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func checkDistribution(name string, wantTags map[string]string, wantCount int64, wantMin, wantMax float64) error {
+	d, err := view.RetrieveData(name)
+	if err != nil {
+		return err
+	}
+
+rows:
+	for _, row := range d {
+		for _, got := range row.Tags {
+			if wantTags[got.Key.Name()] != got.Value {
+				continue rows
+			}
+		}
+
+		value, ok := row.Data.(*view.DistributionData)
+		if !ok {
+			return fmt.Errorf("row.Data.(Type) = %T, want: %T", row.Data, value)
+		}
+		if value.Count != wantCount {
+			return fmt.Errorf("Count = %v, want: %v", value.Count, wantCount)
+		}
+		if value.Min != wantMin || value.Max != wantMax {
+			return fmt.Errorf("[Min, Max] = [%v, %v], want: [%v, %v]", value.Min, value.Max, wantMin, wantMax)
+		}
+		return nil
+	}
+	return fmt.Errorf("no row of %s with tags %v", name, wantTags)
+}
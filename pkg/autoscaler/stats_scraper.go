@@ -18,7 +18,9 @@ package autoscaler
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -85,12 +87,16 @@ type ServiceScraper struct {
 }
 
 // NewServiceScraper creates a new StatsScraper for the Revision which
-// the given Metric is responsible for.
-func NewServiceScraper(metric *Metric, counter resources.ReadyPodCounter) (*ServiceScraper, error) {
+// the given Metric is responsible for. The scraper authenticates with a
+// token derived from statsKey, unless it is empty.
+func NewServiceScraper(metric *Metric, counter resources.ReadyPodCounter, statsKey []byte) (*ServiceScraper, error) {
 	sClient, err := newHTTPScrapeClient(cacheDisabledClient)
 	if err != nil {
 		return nil, err
 	}
+	if metric != nil {
+		sClient.token = StatsToken(statsKey, metric.Namespace, metric.Labels[serving.RevisionLabelKey])
+	}
 	return newServiceScraperWithClient(metric, counter, sClient)
 }
 
@@ -122,9 +128,8 @@ func newServiceScraperWithClient(
 }
 
 func urlFromTarget(t, ns string) string {
-	return fmt.Sprintf(
-		"http://%s.%s:%d/metrics",
-		t, ns, networking.AutoscalingQueueMetricsPort)
+	host := net.JoinHostPort(t+"."+ns, strconv.Itoa(networking.AutoscalingQueueMetricsPort))
+	return "http://" + host + "/metrics"
 }
 
 // Scrape calls the destination service then sends it
@@ -172,6 +177,8 @@ func (s *ServiceScraper) Scrape() (*StatMessage, error) {
 		avgProxiedConcurrency float64
 		reqCount              float64
 		proxiedReqCount       float64
+		bytesInFlight         float64
+		reqDuration           float64
 		successCount          float64
 	)
 
@@ -181,6 +188,8 @@ func (s *ServiceScraper) Scrape() (*StatMessage, error) {
 		avgProxiedConcurrency += stat.AverageProxiedConcurrentRequests
 		reqCount += stat.RequestCount
 		proxiedReqCount += stat.ProxiedRequestCount
+		bytesInFlight += stat.AverageBytesInFlight
+		reqDuration += stat.AverageRequestDuration
 	}
 
 	frpc := float64(readyPodsCount)
@@ -188,6 +197,8 @@ func (s *ServiceScraper) Scrape() (*StatMessage, error) {
 	avgProxiedConcurrency = avgProxiedConcurrency / successCount
 	reqCount = reqCount / successCount
 	proxiedReqCount = proxiedReqCount / successCount
+	bytesInFlight = bytesInFlight / successCount
+	reqDuration = reqDuration / successCount
 	now := time.Now()
 
 	// Assumption: A particular pod can stand for other pods, i.e. other pods
@@ -204,6 +215,9 @@ func (s *ServiceScraper) Scrape() (*StatMessage, error) {
 		AverageProxiedConcurrentRequests: avgProxiedConcurrency * frpc,
 		RequestCount:                     reqCount * frpc,
 		ProxiedRequestCount:              proxiedReqCount * frpc,
+		AverageBytesInFlight:             bytesInFlight * frpc,
+		// The duration does not depend on the number of pods.
+		AverageRequestDuration: reqDuration,
 	}
 
 	return &StatMessage{
@@ -43,18 +43,24 @@ var (
 			AverageProxiedConcurrentRequests: 2.0,
 			RequestCount:                     5,
 			ProxiedRequestCount:              4,
+			AverageBytesInFlight:             1000,
+			AverageRequestDuration:           0.5,
 		}, {
 			PodName:                          "pod-2",
 			AverageConcurrentRequests:        5.0,
 			AverageProxiedConcurrentRequests: 4.0,
 			RequestCount:                     7,
 			ProxiedRequestCount:              6,
+			AverageBytesInFlight:             2000,
+			AverageRequestDuration:           1.0,
 		}, {
 			PodName:                          "pod-3",
 			AverageConcurrentRequests:        3.0,
 			AverageProxiedConcurrentRequests: 2.0,
 			RequestCount:                     5,
 			ProxiedRequestCount:              4,
+			AverageBytesInFlight:             3000,
+			AverageRequestDuration:           1.5,
 		},
 	}
 )
@@ -170,6 +176,14 @@ func TestScrapeReportStatWhenAllCallsSucceed(t *testing.T) {
 	if got.Stat.ProxiedRequestCount != 14 {
 		t.Errorf("StatMessage.Stat.ProxiedCount=%v, want %v", got.Stat.ProxiedRequestCount, 12)
 	}
+	// ((1000 + 2000 + 3000) / 3.0) * 3 = 6000
+	if got.Stat.AverageBytesInFlight != 6000 {
+		t.Errorf("StatMessage.Stat.AverageBytesInFlight=%v, want %v", got.Stat.AverageBytesInFlight, 6000)
+	}
+	// (0.5 + 1.0 + 1.5) / 3.0 = 1.0
+	if got.Stat.AverageRequestDuration != 1.0 {
+		t.Errorf("StatMessage.Stat.AverageRequestDuration=%v, want %v", got.Stat.AverageRequestDuration, 1.0)
+	}
 }
 
 func TestScrapeReportErrorCannotFindEnoughPods(t *testing.T) {
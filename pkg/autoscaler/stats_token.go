@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// StatsKeySecretName is the name of the secret holding the key that
+	// stats tokens are derived from.
+	StatsKeySecretName = "autoscaler-stats-key"
+	// StatsKeySecretKey is the key of the secret's data holding the key.
+	StatsKeySecretKey = "key"
+
+	statsKeyLength = 32
+)
+
+// StatsToken returns the bearer token that the queue-proxies of the given
+// revision expect from scrapers. Tokens are derived from a key only known to
+// the control plane, so a token doesn't allow reading or spoofing the stats of
+// other revisions. Returns an empty string, i.e. no authentication, if there
+// is no key.
+func StatsToken(key []byte, namespace, revision string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(namespace + "/" + revision))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EnsureStatsKey returns the key stats tokens are derived from, generating
+// and storing it in the given namespace if it doesn't exist yet.
+func EnsureStatsKey(client kubernetes.Interface, namespace string) ([]byte, error) {
+	secrets := client.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(StatsKeySecretName, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		key := make([]byte, statsKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, "failed to generate stats key")
+		}
+		secret, err = secrets.Create(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      StatsKeySecretName,
+				Namespace: namespace,
+			},
+			Data: map[string][]byte{
+				StatsKeySecretKey: key,
+			},
+		})
+		// Another component may have created it concurrently.
+		if apierrs.IsAlreadyExists(err) {
+			secret, err = secrets.Get(StatsKeySecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get stats key")
+	}
+	key := secret.Data[StatsKeySecretKey]
+	if len(key) == 0 {
+		return nil, errors.Errorf("secret %s/%s has no %q", namespace, StatsKeySecretName, StatsKeySecretKey)
+	}
+	return key, nil
+}
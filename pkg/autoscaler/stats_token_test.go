@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakek8s "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStatsToken(t *testing.T) {
+	key := []byte("very secret")
+
+	if got := StatsToken(nil, "ns", "rev"); got != "" {
+		t.Errorf("StatsToken() without a key = %q, want empty", got)
+	}
+
+	token := StatsToken(key, "ns", "rev")
+	if token == "" {
+		t.Fatal("StatsToken() = empty, want a token")
+	}
+	if got := StatsToken(key, "ns", "rev"); got != token {
+		t.Errorf("StatsToken() = %q, want it to be stable (%q)", got, token)
+	}
+	for _, other := range []string{
+		StatsToken(key, "ns", "other"),
+		StatsToken(key, "other", "rev"),
+		StatsToken(key, "ns/r", "ev"),
+		StatsToken([]byte("other key"), "ns", "rev"),
+	} {
+		if other == token {
+			t.Errorf("StatsToken() = %q for a different revision or key", other)
+		}
+	}
+}
+
+func TestEnsureStatsKey(t *testing.T) {
+	const ns = "knative-serving"
+
+	t.Run("generates a key", func(t *testing.T) {
+		client := fakek8s.NewSimpleClientset()
+		key, err := EnsureStatsKey(client, ns)
+		if err != nil {
+			t.Fatalf("EnsureStatsKey() = %v", err)
+		}
+		if len(key) != statsKeyLength {
+			t.Errorf("len(key) = %d, want %d", len(key), statsKeyLength)
+		}
+
+		// The key is stored and reused.
+		again, err := EnsureStatsKey(client, ns)
+		if err != nil {
+			t.Fatalf("EnsureStatsKey() = %v", err)
+		}
+		if string(again) != string(key) {
+			t.Errorf("EnsureStatsKey() = %x, want the stored key %x", again, key)
+		}
+	})
+
+	t.Run("existing key", func(t *testing.T) {
+		client := fakek8s.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      StatsKeySecretName,
+				Namespace: ns,
+			},
+			Data: map[string][]byte{
+				StatsKeySecretKey: []byte("existing"),
+			},
+		})
+		key, err := EnsureStatsKey(client, ns)
+		if err != nil {
+			t.Fatalf("EnsureStatsKey() = %v", err)
+		}
+		if got, want := string(key), "existing"; got != want {
+			t.Errorf("EnsureStatsKey() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("empty secret", func(t *testing.T) {
+		client := fakek8s.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      StatsKeySecretName,
+				Namespace: ns,
+			},
+		})
+		if _, err := EnsureStatsKey(client, ns); err == nil {
+			t.Error("EnsureStatsKey() = nil, want an error for a secret without key")
+		}
+	})
+}
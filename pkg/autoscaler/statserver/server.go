@@ -37,29 +37,33 @@ const closeCodeServiceRestart = 1012 // See https://www.iana.org/assignments/web
 type Server struct {
 	addr        string
 	wsSrv       http.Server
+	mux         *http.ServeMux
 	servingCh   chan struct{}
 	stopCh      chan struct{}
 	statsCh     chan<- *autoscaler.StatMessage
+	validator   Validator
 	openClients sync.WaitGroup
 	logger      *zap.SugaredLogger
 }
 
 // New creates a Server which will receive autoscaler statistics and forward them to statsCh until Shutdown is called.
-func New(statsServerAddr string, statsCh chan<- *autoscaler.StatMessage, logger *zap.SugaredLogger) *Server {
+// Messages rejected by the validator are dropped. A nil validator accepts all messages.
+func New(statsServerAddr string, statsCh chan<- *autoscaler.StatMessage, validator Validator, logger *zap.SugaredLogger) *Server {
 	svr := Server{
 		addr:        statsServerAddr,
 		servingCh:   make(chan struct{}),
 		stopCh:      make(chan struct{}),
 		statsCh:     statsCh,
+		validator:   validator,
 		openClients: sync.WaitGroup{},
 		logger:      logger.Named("stats-websocket-server").With("address", statsServerAddr),
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", svr.Handler)
+	svr.mux = http.NewServeMux()
+	svr.mux.HandleFunc("/", svr.Handler)
 	svr.wsSrv = http.Server{
 		Addr:      statsServerAddr,
-		Handler:   mux,
+		Handler:   svr.mux,
 		ConnState: svr.onConnStateChange,
 	}
 	return &svr
@@ -73,6 +77,13 @@ func (s *Server) onConnStateChange(conn net.Conn, state http.ConnState) {
 	}
 }
 
+// Handle serves the handler for the pattern besides the stats, e.g. for
+// other messages of the queue-proxies. It must be called before
+// ListenAndServe.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
 // ListenAndServe listens on the address s.addr and handles incoming connections.
 // It blocks until the server fails or Shutdown is called.
 // It returns an error or, if Shutdown was called, nil.
@@ -165,6 +176,12 @@ func (s *Server) Handler(w http.ResponseWriter, r *http.Request) {
 			s.logger.Error(err)
 			continue
 		}
+		if s.validator != nil {
+			if err := s.validator.Validate(r, &sm); err != nil {
+				s.logger.Warnw("Dropping stat message", zap.String("key", sm.Key), zap.Error(err))
+				continue
+			}
+		}
 		now := time.Now()
 		sm.Stat.Time = &now
 
@@ -19,6 +19,7 @@ package statserver_test
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -88,6 +89,31 @@ func TestStatsReceived(t *testing.T) {
 	closeSink(statSink, t)
 }
 
+type podNameValidator string
+
+func (v podNameValidator) Validate(_ *http.Request, sm *autoscaler.StatMessage) error {
+	if sm.Stat.PodName == string(v) {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func TestStatsDropped(t *testing.T) {
+	statsCh := make(chan *autoscaler.StatMessage)
+	server := stats.NewTestServerWithValidator(statsCh, podNameValidator("spoofer"))
+
+	defer server.Shutdown(0)
+	go server.ListenAndServe()
+
+	statSink := dialOk(server.ListenAddr(), t)
+
+	// The rejected message is dropped, so the next one received is the valid one.
+	send(statSink, newStatMessage("test-namespace/test-revision", "spoofer", 100, 1000), t)
+	assertReceivedOk(newStatMessage("test-namespace/test-revision", "activator1", 2.1, 51), statSink, statsCh, t)
+
+	closeSink(statSink, t)
+}
+
 func TestServerShutdown(t *testing.T) {
 	statsCh := make(chan *autoscaler.StatMessage)
 	server := stats.NewTestServer(statsCh)
@@ -31,8 +31,12 @@ type TestServer struct {
 }
 
 func NewTestServer(statsCh chan<- *autoscaler.StatMessage) *TestServer {
+	return NewTestServerWithValidator(statsCh, nil)
+}
+
+func NewTestServerWithValidator(statsCh chan<- *autoscaler.StatMessage, validator Validator) *TestServer {
 	return &TestServer{
-		Server:     New(testAddress, statsCh, zap.NewNop().Sugar()),
+		Server:     New(testAddress, statsCh, validator, zap.NewNop().Sugar()),
 		listenAddr: make(chan string, 1),
 	}
 }
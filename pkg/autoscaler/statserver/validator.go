@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statserver
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Validator checks stat messages before they are passed on to the
+// autoscaler, so that a pod can't drive the scale of other revisions.
+type Validator interface {
+	// Validate returns an error if the stat message received on the
+	// given request must be dropped.
+	Validate(r *http.Request, sm *autoscaler.StatMessage) error
+}
+
+// nonceTTL is how long the last nonce of a pod is remembered after its
+// last message.
+const nonceTTL = 10 * time.Minute
+
+// PodValidator accepts stat messages from the pods backing a set of
+// Endpoints only. Messages must carry the name of the pod sending them and
+// plausible values. Their nonces must increase for every message sent by
+// the process of the pod, identified by the epoch of the messages, so that
+// messages can't be replayed. Messages of an older epoch than the last one
+// of the pod are rejected too.
+type PodValidator struct {
+	endpointsLister corev1listers.EndpointsLister
+	namespace       string
+	namePrefix      string
+	now             func() time.Time
+
+	mu sync.Mutex
+	// nonces holds the last nonce received from each pod, and lastSweep
+	// when the ones of the pods gone quiet were last removed.
+	nonces    map[string]podNonce
+	lastSweep time.Time
+}
+
+type podNonce struct {
+	epoch    int64
+	nonce    uint64
+	received time.Time
+}
+
+// NewPodValidator creates a PodValidator accepting stat messages from the
+// pods backing the Endpoints in the given namespace whose name starts with
+// namePrefix.
+func NewPodValidator(endpointsLister corev1listers.EndpointsLister, namespace, namePrefix string) *PodValidator {
+	return &PodValidator{
+		endpointsLister: endpointsLister,
+		namespace:       namespace,
+		namePrefix:      namePrefix,
+		now:             time.Now,
+		nonces:          make(map[string]podNonce),
+	}
+}
+
+// Validate implements Validator.
+func (v *PodValidator) Validate(r *http.Request, sm *autoscaler.StatMessage) error {
+	if err := validateStat(sm); err != nil {
+		return err
+	}
+	ip := peerIP(r)
+	if ip == "" {
+		return errors.New("unable to determine the address of the sender")
+	}
+	if err := v.validatePod(ip, sm.Stat.PodName); err != nil {
+		return err
+	}
+	return v.validateNonce(sm.Stat.PodName, sm.Epoch, sm.Nonce)
+}
+
+func (v *PodValidator) validatePod(ip, podName string) error {
+	eps, err := v.endpointsLister.Endpoints(v.namespace).List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "failed to list endpoints")
+	}
+	// IPv6 addresses have several textual forms, so compare them parsed.
+	peer := net.ParseIP(ip)
+	for _, ep := range eps {
+		if !strings.HasPrefix(ep.Name, v.namePrefix) {
+			continue
+		}
+		// Only ready addresses are considered, pods that aren't ready
+		// don't serve requests, so they have nothing to report.
+		for _, subset := range ep.Subsets {
+			for _, addr := range subset.Addresses {
+				if !peer.Equal(net.ParseIP(addr.IP)) {
+					continue
+				}
+				if addr.TargetRef != nil && addr.TargetRef.Name != podName {
+					return fmt.Errorf("pod %s at %s reported stats as pod %q", addr.TargetRef.Name, ip, podName)
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not allowed to report stats", ip)
+}
+
+func (v *PodValidator) validateNonce(podName string, epoch int64, nonce uint64) error {
+	if epoch == 0 || nonce == 0 {
+		return fmt.Errorf("pod %q sent no epoch or nonce", podName)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	if now.Sub(v.lastSweep) > nonceTTL {
+		v.sweep(now)
+	}
+	last, ok := v.nonces[podName]
+	switch {
+	case ok && epoch < last.epoch:
+		return fmt.Errorf("pod %q sent epoch %d after %d", podName, epoch, last.epoch)
+	case ok && epoch == last.epoch && nonce <= last.nonce:
+		return fmt.Errorf("pod %q sent nonce %d after %d", podName, nonce, last.nonce)
+	}
+	// A new epoch, e.g. after a restart, starts over from any nonce.
+	v.nonces[podName] = podNonce{epoch: epoch, nonce: nonce, received: now}
+	return nil
+}
+
+// sweep forgets the nonces of the pods that didn't send any message for
+// nonceTTL. `mu` must be held to call it.
+func (v *PodValidator) sweep(now time.Time) {
+	v.lastSweep = now
+	for pod, n := range v.nonces {
+		if now.Sub(n.received) > nonceTTL {
+			delete(v.nonces, pod)
+		}
+	}
+}
+
+// RevisionPodValidator accepts the ClientQuotaReports sent by the pods of
+// the Revision they report for only, as listed in the Endpoints of the
+// Revision. Like PodValidator, it identifies the pods by the peer address
+// of the reports, see peerIP.
+type RevisionPodValidator struct {
+	endpointsLister corev1listers.EndpointsLister
+}
+
+var _ autoscaler.ClientQuotaValidator = (*RevisionPodValidator)(nil)
+
+// NewRevisionPodValidator creates a RevisionPodValidator.
+func NewRevisionPodValidator(endpointsLister corev1listers.EndpointsLister) *RevisionPodValidator {
+	return &RevisionPodValidator{endpointsLister: endpointsLister}
+}
+
+// ValidateClientQuota implements autoscaler.ClientQuotaValidator.
+func (v *RevisionPodValidator) ValidateClientQuota(r *http.Request, in *autoscaler.ClientQuotaReport) error {
+	ip := peerIP(r)
+	if ip == "" {
+		return errors.New("unable to determine the address of the sender")
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(in.Key)
+	if err != nil {
+		return err
+	}
+	eps, err := v.endpointsLister.Endpoints(namespace).List(labels.SelectorFromSet(labels.Set{
+		serving.RevisionLabelKey: name,
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed to list endpoints")
+	}
+	peer := net.ParseIP(ip)
+	for _, ep := range eps {
+		// Pods report their clients as soon as they get requests, so the
+		// ones that aren't ready yet are considered too.
+		for _, subset := range ep.Subsets {
+			for _, addr := range append(subset.Addresses, subset.NotReadyAddresses...) {
+				if !peer.Equal(net.ParseIP(addr.IP)) {
+					continue
+				}
+				if addr.TargetRef == nil || addr.TargetRef.Name != in.Pod {
+					return fmt.Errorf("%s reported clients as pod %q", ip, in.Pod)
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not a pod of revision %s", ip, in.Key)
+}
+
+// validateStat returns an error if the stat message is malformed or its
+// values are impossible.
+func validateStat(sm *autoscaler.StatMessage) error {
+	if ns, name, err := cache.SplitMetaNamespaceKey(sm.Key); err != nil || ns == "" || name == "" {
+		return fmt.Errorf("invalid key %q", sm.Key)
+	}
+	s := sm.Stat
+	if s.PodName == "" {
+		return errors.New("missing pod name")
+	}
+	for name, value := range map[string]float64{
+		"AverageConcurrentRequests":        s.AverageConcurrentRequests,
+		"AverageProxiedConcurrentRequests": s.AverageProxiedConcurrentRequests,
+		"RequestCount":                     s.RequestCount,
+		"ProxiedRequestCount":              s.ProxiedRequestCount,
+		"AverageBytesInFlight":             s.AverageBytesInFlight,
+		"AverageRequestDuration":           s.AverageRequestDuration,
+	} {
+		if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+			return fmt.Errorf("invalid %s %v", name, value)
+		}
+	}
+	if s.AverageProxiedConcurrentRequests > s.AverageConcurrentRequests {
+		return fmt.Errorf("AverageProxiedConcurrentRequests %v exceeds AverageConcurrentRequests %v",
+			s.AverageProxiedConcurrentRequests, s.AverageConcurrentRequests)
+	}
+	if s.ProxiedRequestCount > s.RequestCount {
+		return fmt.Errorf("ProxiedRequestCount %v exceeds RequestCount %v", s.ProxiedRequestCount, s.RequestCount)
+	}
+	return nil
+}
+
+// peerIP returns the IP of the peer of the request. X-Forwarded-For isn't
+// considered, as anyone can set it, so the stat server port must not be
+// captured by a sidecar, as the requests it proxies have a loopback address
+// that matches no pod. Returns an empty string if the address can't be
+// determined.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
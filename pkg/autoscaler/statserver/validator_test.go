@@ -0,0 +1,346 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statserver
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/autoscaler"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "knative-serving"
+	testKey       = "ns/rev"
+)
+
+func activatorEndpoints(name string, addrs ...corev1.EndpointAddress) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      name,
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: addrs,
+		}},
+	}
+}
+
+func podAddress(ip, name string) corev1.EndpointAddress {
+	return corev1.EndpointAddress{
+		IP: ip,
+		TargetRef: &corev1.ObjectReference{
+			Kind: "Pod",
+			Name: name,
+		},
+	}
+}
+
+func newTestValidator(t *testing.T, eps ...*corev1.Endpoints) *PodValidator {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, ep := range eps {
+		if err := indexer.Add(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewPodValidator(corev1listers.NewEndpointsLister(indexer), testNamespace, "activator-service")
+}
+
+func request(remoteAddr string, xff ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://autoscaler:8080/", nil)
+	r.RemoteAddr = remoteAddr
+	for _, h := range xff {
+		r.Header.Add("X-Forwarded-For", h)
+	}
+	return r
+}
+
+func statMessage(pod string, epoch int64, nonce uint64) *autoscaler.StatMessage {
+	return &autoscaler.StatMessage{
+		Key: testKey,
+		Stat: autoscaler.Stat{
+			PodName:                          pod,
+			AverageConcurrentRequests:        2,
+			AverageProxiedConcurrentRequests: 1,
+			RequestCount:                     10,
+			ProxiedRequestCount:              5,
+		},
+		Epoch: epoch,
+		Nonce: nonce,
+	}
+}
+
+func TestPodValidatorIdentity(t *testing.T) {
+	v := newTestValidator(t,
+		activatorEndpoints("activator-service", podAddress("10.0.0.1", "activator-1")),
+		activatorEndpoints("activator-service-tenant", podAddress("10.0.0.2", "activator-2")),
+		activatorEndpoints("other-service", podAddress("10.0.0.3", "other")),
+		activatorEndpoints("activator-service-v6", podAddress("FD00:0:0:0::5", "activator-5")),
+	)
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		pod     string
+		wantErr bool
+	}{{
+		name: "activator",
+		r:    request("10.0.0.1:4242"),
+		pod:  "activator-1",
+	}, {
+		name: "pool activator",
+		r:    request("10.0.0.2:4242"),
+		pod:  "activator-2",
+	}, {
+		name:    "activator claiming to be another activator",
+		r:       request("10.0.0.1:4242"),
+		pod:     "activator-2",
+		wantErr: true,
+	}, {
+		name:    "pod of another service",
+		r:       request("10.0.0.3:4242"),
+		pod:     "other",
+		wantErr: true,
+	}, {
+		name:    "unknown pod",
+		r:       request("10.0.0.4:4242"),
+		pod:     "activator-1",
+		wantErr: true,
+	}, {
+		// X-Forwarded-For can be set by anyone.
+		name:    "forwarded by a sidecar",
+		r:       request("127.0.0.1:4242", "10.0.0.4", "1.2.3.4, 10.0.0.1"),
+		pod:     "activator-1",
+		wantErr: true,
+	}, {
+		name:    "forwarded address of an activator",
+		r:       request("10.0.0.4:4242", "10.0.0.1"),
+		pod:     "activator-1",
+		wantErr: true,
+	}, {
+		name: "ipv6 activator",
+		r:    request("[fd00::5]:4242"),
+		pod:  "activator-5",
+	}, {
+		name:    "ipv6 activator forwarded by a sidecar",
+		r:       request("[::1]:4242", "fd00::5"),
+		pod:     "activator-5",
+		wantErr: true,
+	}, {
+		name:    "unknown ipv6 pod",
+		r:       request("[fd00::6]:4242"),
+		pod:     "activator-5",
+		wantErr: true,
+	}, {
+		name:    "unknown peer address",
+		r:       request("@"),
+		pod:     "activator-1",
+		wantErr: true,
+	}}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := v.Validate(test.r, statMessage(test.pod, 1, uint64(i+1)))
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevisionPodValidator(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, ep := range []*corev1.Endpoints{{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "rev-private",
+			Labels:    map[string]string{serving.RevisionLabelKey: "rev"},
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{podAddress("10.1.0.1", "rev-pod-1")},
+			NotReadyAddresses: []corev1.EndpointAddress{podAddress("10.1.0.2", "rev-pod-2")},
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "other-private",
+			Labels:    map[string]string{serving.RevisionLabelKey: "other"},
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{podAddress("10.1.0.3", "other-pod")},
+		}},
+	}} {
+		if err := indexer.Add(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v := NewRevisionPodValidator(corev1listers.NewEndpointsLister(indexer))
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		key     string
+		pod     string
+		wantErr bool
+	}{{
+		name: "pod of the revision",
+		r:    request("10.1.0.1:4242"),
+		key:  "ns/rev",
+		pod:  "rev-pod-1",
+	}, {
+		name: "pod of the revision that isn't ready",
+		r:    request("10.1.0.2:4242"),
+		key:  "ns/rev",
+		pod:  "rev-pod-2",
+	}, {
+		name:    "pod claiming to be another pod",
+		r:       request("10.1.0.1:4242"),
+		key:     "ns/rev",
+		pod:     "rev-pod-2",
+		wantErr: true,
+	}, {
+		name:    "pod of another revision",
+		r:       request("10.1.0.3:4242"),
+		key:     "ns/rev",
+		pod:     "other-pod",
+		wantErr: true,
+	}, {
+		name:    "unknown pod",
+		r:       request("10.1.0.4:4242"),
+		key:     "ns/rev",
+		pod:     "rev-pod-1",
+		wantErr: true,
+	}, {
+		name:    "unknown address",
+		r:       request("not-an-address"),
+		key:     "ns/rev",
+		pod:     "rev-pod-1",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := v.ValidateClientQuota(test.r, &autoscaler.ClientQuotaReport{Key: test.key, Pod: test.pod})
+			if (err != nil) != test.wantErr {
+				t.Errorf("ValidateClientQuota() = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPodValidatorValues(t *testing.T) {
+	v := newTestValidator(t, activatorEndpoints("activator-service", podAddress("10.0.0.1", "activator-1")))
+
+	tests := []struct {
+		name    string
+		mutate  func(*autoscaler.StatMessage)
+		wantErr bool
+	}{{
+		name:   "valid",
+		mutate: func(*autoscaler.StatMessage) {},
+	}, {
+		name:    "invalid key",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Key = "rev" },
+		wantErr: true,
+	}, {
+		name:    "missing pod name",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.PodName = "" },
+		wantErr: true,
+	}, {
+		name:    "negative concurrency",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.AverageConcurrentRequests = -1 },
+		wantErr: true,
+	}, {
+		name:    "NaN request count",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.RequestCount = math.NaN() },
+		wantErr: true,
+	}, {
+		name:    "infinite duration",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.AverageRequestDuration = math.Inf(1) },
+		wantErr: true,
+	}, {
+		name:    "more proxied than total concurrency",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.AverageProxiedConcurrentRequests = 3 },
+		wantErr: true,
+	}, {
+		name:    "more proxied than total requests",
+		mutate:  func(sm *autoscaler.StatMessage) { sm.Stat.ProxiedRequestCount = 11 },
+		wantErr: true,
+	}}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sm := statMessage("activator-1", 1, uint64(i+1))
+			test.mutate(sm)
+			err := v.Validate(request("10.0.0.1:4242"), sm)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPodValidatorNonce(t *testing.T) {
+	v := newTestValidator(t, activatorEndpoints("activator-service",
+		podAddress("10.0.0.1", "activator-1"),
+		podAddress("10.0.0.2", "activator-2")))
+	now := time.Unix(1000, 0)
+	v.now = func() time.Time { return now }
+	r1, r2 := request("10.0.0.1:4242"), request("10.0.0.2:4242")
+
+	for _, step := range []struct {
+		r       *http.Request
+		pod     string
+		epoch   int64
+		nonce   uint64
+		after   time.Duration
+		wantErr bool
+	}{
+		// Messages without an epoch or nonce are rejected.
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 0, wantErr: true},
+		{r: r1, pod: "activator-1", epoch: 0, nonce: 1, wantErr: true},
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 1},
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 3},
+		// Replayed.
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 3, wantErr: true},
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 2, wantErr: true},
+		// Nonces are tracked per pod.
+		{r: r2, pod: "activator-2", epoch: 1, nonce: 1},
+		// The process restarted.
+		{r: r1, pod: "activator-1", epoch: 2, nonce: 1},
+		{r: r1, pod: "activator-1", epoch: 2, nonce: 2},
+		// Replayed from the previous process.
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 4, wantErr: true},
+		// The nonces of the pods gone quiet are forgotten.
+		{r: r2, pod: "activator-2", epoch: 1, nonce: 2, after: nonceTTL + time.Second},
+		{r: r1, pod: "activator-1", epoch: 1, nonce: 1},
+	} {
+		now = now.Add(step.after)
+		err := v.Validate(step.r, statMessage(step.pod, step.epoch, step.nonce))
+		if (err != nil) != step.wantErr {
+			t.Errorf("Validate(%s, epoch %d, nonce %d) = %v, wantErr %v", step.pod, step.epoch, step.nonce, err, step.wantErr)
+		}
+	}
+}
@@ -23,6 +23,7 @@ package autoscaler
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
+	out.ScaleSmoothing = in.ScaleSmoothing
 	return
 }
 
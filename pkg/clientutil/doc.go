@@ -0,0 +1,22 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clientutil contains high-level operations on the Services of
+// Knative Serving, built on top of the generated clients, for tools that
+// drive them imperatively, like CI jobs and the e2e tests. The operations
+// retry on conflicts and wait on the Services' conditions, so that their
+// callers don't have to.
+package clientutil
@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	servingv1alpha1 "github.com/knative/serving/pkg/client/clientset/versioned/typed/serving/v1alpha1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// pollInterval is how often the status of a Service is checked while
+// waiting on it.
+const pollInterval = time.Second
+
+// WaitForServiceReady waits for the Service called name to be ready at its
+// latest generation, and returns it. It fails as soon as the Service fails
+// to become ready, or once timeout passes. Transient errors of the API
+// server are retried until then.
+func WaitForServiceReady(services servingv1alpha1.ServiceInterface, name string, timeout time.Duration) (*v1alpha1.Service, error) {
+	var (
+		svc     *v1alpha1.Service
+		lastErr error
+	)
+	err := wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+		s, err := services.Get(name, metav1.GetOptions{})
+		if err != nil {
+			if isTransient(err) {
+				lastErr = err
+				return false, nil
+			}
+			return false, err
+		}
+		svc, lastErr = s, nil
+		if svc.Generation != svc.Status.ObservedGeneration {
+			return false, nil
+		}
+		if c := svc.Status.GetCondition(v1alpha1.ServiceConditionReady); c.IsFalse() {
+			return false, fmt.Errorf("service %q failed to become ready: %s: %s", name, c.Reason, c.Message)
+		}
+		return svc.Status.IsReady(), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		msg := fmt.Sprintf("service %q isn't ready after %v", name, timeout)
+		if lastErr != nil {
+			return nil, fmt.Errorf("%s: %v", msg, lastErr)
+		}
+		if svc != nil {
+			if c := svc.Status.GetCondition(v1alpha1.ServiceConditionReady); c != nil && c.Reason != "" {
+				return nil, fmt.Errorf("%s: %s: %s", msg, c.Reason, c.Message)
+			}
+		}
+		return nil, errors.New(msg)
+	} else if err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// SplitTraffic routes the traffic of the Service called name to its
+// revisions, by the percents keyed by their names, which have to add up
+// to 100. The traffic stops following the latest revision.
+func SplitTraffic(services servingv1alpha1.ServiceInterface, name string, percents map[string]int) (*v1alpha1.Service, error) {
+	total := 0
+	revisions := make([]string, 0, len(percents))
+	for rev, p := range percents {
+		if p < 0 {
+			return nil, fmt.Errorf("the percent of revision %q must not be negative, was %d", rev, p)
+		}
+		total += p
+		revisions = append(revisions, rev)
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("the percents must add up to 100, was %d", total)
+	}
+	sort.Strings(revisions)
+
+	return updateService(services, name, func(svc *v1alpha1.Service) error {
+		if mode := deprecatedMode(&svc.Spec); mode != "" {
+			return fmt.Errorf("service %q uses the deprecated %s mode, which doesn't take a traffic split", name, mode)
+		}
+		latest := false
+		traffic := make([]v1alpha1.TrafficTarget, 0, len(revisions))
+		for _, rev := range revisions {
+			traffic = append(traffic, v1alpha1.TrafficTarget{
+				TrafficTarget: v1beta1.TrafficTarget{
+					RevisionName:   rev,
+					LatestRevision: &latest,
+					Percent:        percents[rev],
+				},
+			})
+		}
+		svc.Spec.Traffic = traffic
+		return nil
+	})
+}
+
+// PinRevision routes all of the traffic of the Service called name to one
+// of its revisions, until the traffic is split again.
+func PinRevision(services servingv1alpha1.ServiceInterface, name, revision string) (*v1alpha1.Service, error) {
+	return SplitTraffic(services, name, map[string]int{revision: 100})
+}
+
+// ScaleBounds sets the minimum and maximum number of pods of the revisions
+// of the Service called name, where 0 lifts the bound. Since the bounds are
+// part of the Service's template, this stamps out a new revision.
+func ScaleBounds(services servingv1alpha1.ServiceInterface, name string, min, max int) (*v1alpha1.Service, error) {
+	if min < 0 || max < 0 {
+		return nil, fmt.Errorf("the scale bounds must not be negative, were %d and %d", min, max)
+	}
+	if max > 0 && min > max {
+		return nil, fmt.Errorf("the minimum scale %d must not be above the maximum scale %d", min, max)
+	}
+
+	return updateService(services, name, func(svc *v1alpha1.Service) error {
+		template := serviceTemplate(&svc.Spec)
+		if template == nil {
+			return fmt.Errorf("service %q has no template to set the scale bounds on", name)
+		}
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string, 2)
+		}
+		setScaleBound(template.Annotations, autoscaling.MinScaleAnnotationKey, min)
+		setScaleBound(template.Annotations, autoscaling.MaxScaleAnnotationKey, max)
+		return nil
+	})
+}
+
+func setScaleBound(annotations map[string]string, key string, bound int) {
+	if bound == 0 {
+		delete(annotations, key)
+	} else {
+		annotations[key] = strconv.Itoa(bound)
+	}
+}
+
+// updateService applies mutate to the latest version of the Service called
+// name and updates it, starting over when the update conflicts with
+// another one.
+func updateService(services servingv1alpha1.ServiceInterface, name string, mutate func(*v1alpha1.Service) error) (*v1alpha1.Service, error) {
+	var updated *v1alpha1.Service
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		svc, err := services.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := mutate(svc); err != nil {
+			return err
+		}
+		updated, err = services.Update(svc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// deprecatedMode returns the name of the deprecated mode the Service is
+// specified with, if any.
+func deprecatedMode(spec *v1alpha1.ServiceSpec) string {
+	switch {
+	case spec.DeprecatedRunLatest != nil:
+		return "runLatest"
+	case spec.DeprecatedRelease != nil:
+		return "release"
+	case spec.DeprecatedPinned != nil:
+		return "pinned"
+	case spec.DeprecatedManual != nil:
+		return "manual"
+	default:
+		return ""
+	}
+}
+
+// serviceTemplate returns the template the Service stamps out its
+// revisions from, whatever mode it's specified with, or nil if it has
+// none.
+func serviceTemplate(spec *v1alpha1.ServiceSpec) *v1alpha1.RevisionTemplateSpec {
+	cs := &spec.ConfigurationSpec
+	switch {
+	case spec.DeprecatedRunLatest != nil:
+		cs = &spec.DeprecatedRunLatest.Configuration
+	case spec.DeprecatedRelease != nil:
+		cs = &spec.DeprecatedRelease.Configuration
+	case spec.DeprecatedPinned != nil:
+		cs = &spec.DeprecatedPinned.Configuration
+	case spec.DeprecatedManual != nil:
+		return nil
+	}
+	if cs.DeprecatedRevisionTemplate != nil {
+		return cs.DeprecatedRevisionTemplate
+	}
+	return cs.Template
+}
+
+// isTransient returns whether the error of a request to the API server is
+// likely to go away when the request is retried.
+func isTransient(err error) bool {
+	return apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) || apierrs.IsTooManyRequests(err) ||
+		apierrs.IsInternalError(err) || apierrs.IsServiceUnavailable(err) || apierrs.IsUnexpectedServerError(err)
+}
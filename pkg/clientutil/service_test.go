@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	fakeservingclient "github.com/knative/serving/pkg/client/clientset/versioned/fake"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgotesting "k8s.io/client-go/testing"
+
+	. "github.com/knative/serving/pkg/testing/v1alpha1"
+)
+
+const (
+	testNamespace = "default"
+	testService   = "svc"
+)
+
+var servicesResource = schema.GroupResource{Group: "serving.knative.dev", Resource: "services"}
+
+func TestWaitForServiceReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     *v1alpha1.Service
+		wantErr string
+	}{{
+		name: "ready",
+		svc: Service(testService, testNamespace, WithInlineRollout, WithInitSvcConditions,
+			WithReadyRoute, WithReadyConfig("svc-00001")),
+	}, {
+		name: "failed",
+		svc: Service(testService, testNamespace, WithInlineRollout, WithInitSvcConditions,
+			WithReadyRoute, WithFailedConfig("svc-00001", "ContainerMissing", "no image")),
+		wantErr: `service "svc" failed to become ready: ContainerMissing: Revision "svc-00001" failed with message: no image.`,
+	}, {
+		name:    "not ready",
+		svc:     Service(testService, testNamespace, WithInlineRollout, WithInitSvcConditions),
+		wantErr: `service "svc" isn't ready after 10ms`,
+	}, {
+		name: "not reconciled",
+		svc: Service(testService, testNamespace, WithInlineRollout, WithInitSvcConditions,
+			WithReadyRoute, WithReadyConfig("svc-00001"), func(s *v1alpha1.Service) {
+				s.Generation = 2
+				s.Status.ObservedGeneration = 1
+			}),
+		wantErr: `service "svc" isn't ready after 10ms`,
+	}, {
+		name:    "missing",
+		wantErr: `services.serving.knative.dev "svc" not found`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if test.svc != nil {
+				objs = append(objs, test.svc)
+			}
+			services := fakeservingclient.NewSimpleClientset(objs...).ServingV1alpha1().Services(testNamespace)
+
+			svc, err := WaitForServiceReady(services, testService, 10*time.Millisecond)
+			if test.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+					t.Errorf("WaitForServiceReady() = %v, want error %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WaitForServiceReady() = %v", err)
+			}
+			if !svc.Status.IsReady() {
+				t.Errorf("WaitForServiceReady() = %v, want a ready Service", svc)
+			}
+		})
+	}
+}
+
+func TestWaitForServiceReadyRetriesTransientErrors(t *testing.T) {
+	client := fakeservingclient.NewSimpleClientset(Service(testService, testNamespace, WithInlineRollout,
+		WithInitSvcConditions, WithReadyRoute, WithReadyConfig("svc-00001")))
+	failures := 1
+	client.PrependReactor("get", "services", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		if failures == 0 {
+			return false, nil, nil
+		}
+		failures--
+		return true, nil, apierrs.NewServiceUnavailable("try again")
+	})
+
+	if _, err := WaitForServiceReady(client.ServingV1alpha1().Services(testNamespace), testService, 5*time.Second); err != nil {
+		t.Errorf("WaitForServiceReady() = %v", err)
+	}
+}
+
+func TestSplitTraffic(t *testing.T) {
+	client := fakeservingclient.NewSimpleClientset(Service(testService, testNamespace, WithInlineRollout))
+	services := client.ServingV1alpha1().Services(testNamespace)
+
+	svc, err := SplitTraffic(services, testService, map[string]int{"svc-00002": 10, "svc-00001": 90})
+	if err != nil {
+		t.Fatalf("SplitTraffic() = %v", err)
+	}
+	latest := false
+	want := []v1alpha1.TrafficTarget{{
+		TrafficTarget: v1beta1.TrafficTarget{
+			RevisionName:   "svc-00001",
+			LatestRevision: &latest,
+			Percent:        90,
+		},
+	}, {
+		TrafficTarget: v1beta1.TrafficTarget{
+			RevisionName:   "svc-00002",
+			LatestRevision: &latest,
+			Percent:        10,
+		},
+	}}
+	if diff := cmp.Diff(want, svc.Spec.Traffic); diff != "" {
+		t.Errorf("Traffic (-want, +got): %s", diff)
+	}
+
+	svc, err = PinRevision(services, testService, "svc-00002")
+	if err != nil {
+		t.Fatalf("PinRevision() = %v", err)
+	}
+	want = []v1alpha1.TrafficTarget{{
+		TrafficTarget: v1beta1.TrafficTarget{
+			RevisionName:   "svc-00002",
+			LatestRevision: &latest,
+			Percent:        100,
+		},
+	}}
+	if diff := cmp.Diff(want, svc.Spec.Traffic); diff != "" {
+		t.Errorf("Traffic (-want, +got): %s", diff)
+	}
+}
+
+func TestSplitTrafficErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		svc      *v1alpha1.Service
+		percents map[string]int
+		wantErr  string
+	}{{
+		name:     "not 100",
+		svc:      Service(testService, testNamespace, WithInlineRollout),
+		percents: map[string]int{"svc-00001": 50, "svc-00002": 40},
+		wantErr:  "the percents must add up to 100, was 90",
+	}, {
+		name:     "negative",
+		svc:      Service(testService, testNamespace, WithInlineRollout),
+		percents: map[string]int{"svc-00001": 110, "svc-00002": -10},
+		wantErr:  `the percent of revision "svc-00002" must not be negative, was -10`,
+	}, {
+		name:     "deprecated mode",
+		svc:      Service(testService, testNamespace, WithRunLatestRollout),
+		percents: map[string]int{"svc-00001": 100},
+		wantErr:  `service "svc" uses the deprecated runLatest mode, which doesn't take a traffic split`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := fakeservingclient.NewSimpleClientset(test.svc).ServingV1alpha1().Services(testNamespace)
+			if _, err := SplitTraffic(services, testService, test.percents); err == nil || err.Error() != test.wantErr {
+				t.Errorf("SplitTraffic() = %v, want error %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestScaleBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		svc      *v1alpha1.Service
+		min, max int
+		want     map[string]string
+	}{{
+		name: "both bounds",
+		svc:  Service(testService, testNamespace, WithInlineRollout),
+		min:  1,
+		max:  5,
+		want: map[string]string{
+			autoscaling.MinScaleAnnotationKey: "1",
+			autoscaling.MaxScaleAnnotationKey: "5",
+		},
+	}, {
+		name: "lift the maximum",
+		svc: Service(testService, testNamespace, WithInlineRollout, WithConfigAnnotations(map[string]string{
+			autoscaling.MinScaleAnnotationKey: "1",
+			autoscaling.MaxScaleAnnotationKey: "5",
+			"foo":                             "bar",
+		})),
+		min: 2,
+		want: map[string]string{
+			autoscaling.MinScaleAnnotationKey: "2",
+			"foo":                             "bar",
+		},
+	}, {
+		name: "deprecated mode",
+		svc:  Service(testService, testNamespace, WithRunLatestRollout),
+		max:  3,
+		want: map[string]string{
+			autoscaling.MaxScaleAnnotationKey: "3",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := fakeservingclient.NewSimpleClientset(test.svc).ServingV1alpha1().Services(testNamespace)
+			svc, err := ScaleBounds(services, testService, test.min, test.max)
+			if err != nil {
+				t.Fatalf("ScaleBounds() = %v", err)
+			}
+			if diff := cmp.Diff(test.want, serviceTemplate(&svc.Spec).Annotations); diff != "" {
+				t.Errorf("Template annotations (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestScaleBoundsErrors(t *testing.T) {
+	services := fakeservingclient.NewSimpleClientset(Service(testService, testNamespace, WithInlineRollout)).
+		ServingV1alpha1().Services(testNamespace)
+
+	if _, err := ScaleBounds(services, testService, -1, 0); err == nil {
+		t.Error("ScaleBounds() = nil, want an error for a negative bound")
+	}
+	if _, err := ScaleBounds(services, testService, 3, 2); err == nil {
+		t.Error("ScaleBounds() = nil, want an error for a minimum above the maximum")
+	}
+}
+
+func TestUpdateServiceRetriesConflicts(t *testing.T) {
+	client := fakeservingclient.NewSimpleClientset(Service(testService, testNamespace, WithInlineRollout))
+	conflicts := 2
+	client.PrependReactor("update", "services", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, apierrs.NewConflict(servicesResource, testService, nil)
+	})
+	services := client.ServingV1alpha1().Services(testNamespace)
+
+	if _, err := PinRevision(services, testService, "svc-00001"); err != nil {
+		t.Fatalf("PinRevision() = %v", err)
+	}
+	svc, err := services.Get(testService, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if got := svc.Spec.Traffic; len(got) != 1 || got[0].RevisionName != "svc-00001" {
+		t.Errorf("Traffic = %v, want all of it to svc-00001", got)
+	}
+}
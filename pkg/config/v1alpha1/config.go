@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/network"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Version is the version of this API.
+const Version = "v1alpha1"
+
+const (
+	// AutoscalerConfigName is the name of the ConfigMap holding the
+	// Autoscaler configuration.
+	AutoscalerConfigName = autoscaler.ConfigName
+	// DeploymentConfigName is the name of the ConfigMap holding the
+	// Deployment configuration.
+	DeploymentConfigName = deployment.ConfigName
+	// NetworkConfigName is the name of the ConfigMap holding the Network
+	// configuration.
+	NetworkConfigName = network.ConfigName
+)
+
+// Autoscaler is the configuration of the autoscaler.
+type Autoscaler = autoscaler.Config
+
+// Deployment is the configuration of the Deployments of revisions.
+type Deployment = deployment.Config
+
+// Network is the configuration of the networking of revisions and routes.
+type Network = network.Config
+
+// Config holds the configuration loaded from a set of ConfigMaps. The
+// fields of the ConfigMaps that weren't loaded are nil.
+type Config struct {
+	Autoscaler *Autoscaler
+	Deployment *Deployment
+	Network    *Network
+}
+
+// LoadAutoscaler parses the Autoscaler configuration from the given
+// ConfigMap.
+func LoadAutoscaler(cm *corev1.ConfigMap) (*Autoscaler, error) {
+	return autoscaler.NewConfigFromConfigMap(cm)
+}
+
+// LoadDeployment parses the Deployment configuration from the given
+// ConfigMap.
+func LoadDeployment(cm *corev1.ConfigMap) (*Deployment, error) {
+	return deployment.NewConfigFromConfigMap(cm)
+}
+
+// LoadNetwork parses the Network configuration from the given ConfigMap.
+func LoadNetwork(cm *corev1.ConfigMap) (*Network, error) {
+	return network.NewConfigFromConfigMap(cm)
+}
+
+// Load parses the configuration from the given ConfigMaps, which are told
+// apart by their name. It fails on the first ConfigMap that is invalid or
+// isn't part of this API.
+func Load(cms ...*corev1.ConfigMap) (*Config, error) {
+	c := &Config{}
+	for _, cm := range cms {
+		var err error
+		switch cm.Name {
+		case AutoscalerConfigName:
+			c.Autoscaler, err = LoadAutoscaler(cm)
+		case DeploymentConfigName:
+			c.Deployment, err = LoadDeployment(cm)
+		case NetworkConfigName:
+			c.Network, err = LoadNetwork(cm)
+		default:
+			return nil, fmt.Errorf("ConfigMap %q is not part of the %s config API", cm.Name, Version)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ConfigMap %q: %v", cm.Name, err)
+		}
+	}
+	return c, nil
+}
+
+// Validate returns an error when the given ConfigMap is invalid or isn't
+// part of this API.
+func Validate(cm *corev1.ConfigMap) error {
+	_, err := Load(cm)
+	return err
+}
+
+// ReadFile reads a ConfigMap from the YAML or JSON manifest at path, for
+// it to be passed to Load or Validate.
+func ReadFile(path string) (*corev1.ConfigMap, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cm := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(b, cm); err != nil {
+		return nil, fmt.Errorf("failed to parse ConfigMap from %s: %v", path, err)
+	}
+	return cm, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestLoadExamples(t *testing.T) {
+	autoscalerCM, autoscalerExample := ConfigMapsFromTestFile(t, AutoscalerConfigName)
+	deploymentCM, deploymentExample := ConfigMapsFromTestFile(t, DeploymentConfigName, "queueSidecarImage")
+	networkCM, networkExample := ConfigMapsFromTestFile(t, NetworkConfigName)
+
+	for _, cms := range [][]*corev1.ConfigMap{
+		{autoscalerCM, deploymentCM, networkCM},
+		{autoscalerExample, deploymentExample, networkExample},
+	} {
+		c, err := Load(cms...)
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+		if c.Autoscaler == nil || c.Deployment == nil || c.Network == nil {
+			t.Errorf("Load() = %#v, wanted every configuration loaded", c)
+		}
+	}
+}
+
+func TestLoadSubset(t *testing.T) {
+	c, err := Load(ConfigMapFromTestFile(t, NetworkConfigName))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if c.Network == nil {
+		t.Error("Network = nil, wanted it loaded")
+	}
+	if c.Autoscaler != nil || c.Deployment != nil {
+		t.Errorf("Load() = %#v, wanted only Network loaded", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cm      *corev1.ConfigMap
+		wantErr string
+	}{{
+		name: "valid",
+		cm:   configMap(AutoscalerConfigName, map[string]string{"stable-window": "60s"}),
+	}, {
+		name:    "invalid",
+		cm:      configMap(DeploymentConfigName, map[string]string{}),
+		wantErr: `invalid ConfigMap "config-deployment": queue sidecar image is missing`,
+	}, {
+		name:    "not part of the API",
+		cm:      configMap("config-unknown", map[string]string{}),
+		wantErr: `ConfigMap "config-unknown" is not part of the v1alpha1 config API`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.cm)
+			if got := errString(err); got != test.wantErr {
+				t.Errorf("Validate() = %q, wanted %q", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	cm, err := ReadFile("testdata/config-network.yaml")
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	if cm.Name != NetworkConfigName {
+		t.Errorf("Name = %q, wanted %q", cm.Name, NetworkConfigName)
+	}
+	if err := Validate(cm); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	if _, err := ReadFile("testdata/missing.yaml"); err == nil {
+		t.Error("ReadFile() = nil, wanted an error for a missing file")
+	}
+}
+
+func configMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Data: data,
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,24 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 is the v1alpha1 version of the Go API of the ConfigMaps
+// configuring Knative Serving. It parses ConfigMaps with the same logic as
+// our controllers, so that external tools and tests can load and validate
+// configuration exactly the way Knative Serving will.
+//
+// The types and functions of this package only change in backwards
+// compatible ways. Incompatible changes go to a new version of the API.
+package v1alpha1
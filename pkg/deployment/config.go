@@ -18,8 +18,12 @@ package deployment
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 
+	"github.com/knative/serving/pkg/gctuning"
+	pkghttp "github.com/knative/serving/pkg/http"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -31,6 +35,45 @@ const (
 	// QueueSidecarImageKey is the config map key for queue sidecar image
 	QueueSidecarImageKey           = "queueSidecarImage"
 	registriesSkippingTagResolving = "registriesSkippingTagResolving"
+
+	// EnableEarlyHintsKey is the config map key for forwarding 103 Early
+	// Hints responses from the user container to the client.
+	EnableEarlyHintsKey = "enableEarlyHints"
+
+	// EnableQueueConfigReloadKey is the config map key for letting the
+	// queue-proxy pick up configuration changes from its pod's annotations.
+	EnableQueueConfigReloadKey = "enableQueueConfigReload"
+
+	// EnableQueueStatsSocketKey is the config map key for letting
+	// queue-proxy serve its stats on a unix socket in a volume shared with
+	// the other containers of its pod.
+	EnableQueueStatsSocketKey = "enableQueueStatsSocket"
+
+	// ReleaseConcurrencyOnHeadersKey is the config map key for letting
+	// queue-proxy count a request against the container concurrency only
+	// until its response headers are written.
+	ReleaseConcurrencyOnHeadersKey = "releaseConcurrencyOnHeaders"
+
+	// QueueSidecarHeaderPolicyKey is the config map key for the request
+	// headers queue-proxy strips or overrides.
+	QueueSidecarHeaderPolicyKey = "queueSidecarHeaderPolicy"
+
+	// ConcurrencyStateEndpointKey is the config map key for the endpoint
+	// queue-proxy notifies whenever its pod becomes idle or busy.
+	ConcurrencyStateEndpointKey = "concurrencyStateEndpoint"
+
+	// QueueSidecarGOGCKey, QueueSidecarMemoryLimitKey and
+	// QueueSidecarBallastKey are the config map keys for tuning the garbage
+	// collector of queue-proxy.
+	QueueSidecarGOGCKey        = "queueSidecarGOGC"
+	QueueSidecarMemoryLimitKey = "queueSidecarMemoryLimit"
+	QueueSidecarBallastKey     = "queueSidecarBallast"
+
+	// ActivatorGOGCKey, ActivatorMemoryLimitKey and ActivatorBallastKey are
+	// the config map keys for tuning the garbage collector of the activator.
+	ActivatorGOGCKey        = "activatorGOGC"
+	ActivatorMemoryLimitKey = "activatorMemoryLimit"
+	ActivatorBallastKey     = "activatorBallast"
 )
 
 // NewConfigFromMap creates a DeploymentConfig from the supplied Map
@@ -48,6 +91,37 @@ func NewConfigFromMap(configMap map[string]string) (*Config, error) {
 	} else {
 		nc.RegistriesSkippingTagResolving = sets.NewString(strings.Split(registries, ",")...)
 	}
+
+	nc.EnableEarlyHints = strings.ToLower(configMap[EnableEarlyHintsKey]) == "true"
+	nc.EnableQueueConfigReload = strings.ToLower(configMap[EnableQueueConfigReloadKey]) == "true"
+	nc.EnableQueueStatsSocket = strings.ToLower(configMap[EnableQueueStatsSocketKey]) == "true"
+	nc.ReleaseConcurrencyOnHeaders = strings.ToLower(configMap[ReleaseConcurrencyOnHeadersKey]) == "true"
+
+	if _, err := pkghttp.ParseHeaderPolicy(configMap[QueueSidecarHeaderPolicyKey]); err != nil {
+		return nil, err
+	}
+	nc.QueueSidecarHeaderPolicy = configMap[QueueSidecarHeaderPolicyKey]
+
+	if v := strings.TrimSpace(configMap[ConcurrencyStateEndpointKey]); v != "" {
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", ConcurrencyStateEndpointKey, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q: must be an absolute http(s) URL", ConcurrencyStateEndpointKey, v)
+		}
+		nc.ConcurrencyStateEndpoint = v
+	}
+
+	var err error
+	if nc.QueueSidecarGC, err = gctuning.Parse(configMap[QueueSidecarGOGCKey],
+		configMap[QueueSidecarMemoryLimitKey], configMap[QueueSidecarBallastKey]); err != nil {
+		return nil, fmt.Errorf("invalid garbage collection of queue sidecar: %v", err)
+	}
+	if nc.ActivatorGC, err = gctuning.Parse(configMap[ActivatorGOGCKey],
+		configMap[ActivatorMemoryLimitKey], configMap[ActivatorBallastKey]); err != nil {
+		return nil, fmt.Errorf("invalid garbage collection of activator: %v", err)
+	}
 	return nc, nil
 }
 
@@ -64,4 +138,41 @@ type Config struct {
 
 	// Repositories for which tag to digest resolving should be skipped
 	RegistriesSkippingTagResolving sets.String
+
+	// EnableEarlyHints specifies whether queue-proxy forwards 103 Early Hints
+	// responses. They are dropped by default.
+	EnableEarlyHints bool
+
+	// EnableQueueConfigReload specifies whether queue-proxy reloads its
+	// logging level, request timeout and container concurrency from the
+	// annotations of its pod, without being restarted.
+	EnableQueueConfigReload bool
+
+	// EnableQueueStatsSocket specifies whether queue-proxy also serves its
+	// stats on a unix socket, in an emptyDir volume that sidecars of the
+	// pod can mount to scrape them without going through the pod network.
+	EnableQueueStatsSocket bool
+
+	// ReleaseConcurrencyOnHeaders specifies whether queue-proxy frees the
+	// concurrency slot of a request once its response headers are written,
+	// rather than when the whole response was sent. This keeps slow clients
+	// of streamed responses from using up the containerConcurrency.
+	ReleaseConcurrencyOnHeaders bool
+
+	// QueueSidecarHeaderPolicy lists the request headers queue-proxy strips
+	// or overrides before passing requests to the user container, in the
+	// format of pkghttp.ParseHeaderPolicy.
+	QueueSidecarHeaderPolicy string
+
+	// ConcurrencyStateEndpoint is the URL queue-proxy POSTs to whenever its
+	// pod becomes idle or busy, so that a runtime integration can pause and
+	// resume the user container. Nothing is posted if it is empty.
+	ConcurrencyStateEndpoint string
+
+	// QueueSidecarGC tunes the garbage collector of queue-proxy.
+	QueueSidecarGC gctuning.Config
+
+	// ActivatorGC tunes the garbage collector of the activator. It is
+	// applied without restarting the activator.
+	ActivatorGC gctuning.Config
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/gctuning"
 	"knative.dev/pkg/system"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,6 +83,186 @@ func TestControllerConfiguration(t *testing.T) {
 				registriesSkippingTagResolving: "ko.local,ko.dev",
 			},
 		},
+	}, {
+		name:    "controller configuration with early hints",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			EnableEarlyHints:               true,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey: noSidecarImage,
+				EnableEarlyHintsKey:  "true",
+			},
+		},
+	}, {
+		name:    "controller configuration with release on headers",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			ReleaseConcurrencyOnHeaders:    true,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:           noSidecarImage,
+				ReleaseConcurrencyOnHeadersKey: "True",
+			},
+		},
+	}, {
+		name:    "controller configuration with queue config reload",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			EnableQueueConfigReload:        true,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:       noSidecarImage,
+				EnableQueueConfigReloadKey: "true",
+			},
+		},
+	}, {
+		name:    "controller configuration with queue stats socket",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			EnableQueueStatsSocket:         true,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:      noSidecarImage,
+				EnableQueueStatsSocketKey: "true",
+			},
+		},
+	}, {
+		name:    "controller configuration with header policy",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			QueueSidecarHeaderPolicy:       "Knative-*,X-Forwarded-Proto=https",
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:        noSidecarImage,
+				QueueSidecarHeaderPolicyKey: "Knative-*,X-Forwarded-Proto=https",
+			},
+		},
+	}, {
+		name:    "controller configuration with concurrency state endpoint",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			ConcurrencyStateEndpoint:       "http://state-daemon.knative-serving:9696",
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:        noSidecarImage,
+				ConcurrencyStateEndpointKey: "http://state-daemon.knative-serving:9696",
+			},
+		},
+	}, {
+		name:           "controller configuration with invalid concurrency state endpoint",
+		wantErr:        true,
+		wantController: (*Config)(nil),
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:        noSidecarImage,
+				ConcurrencyStateEndpointKey: "state-daemon:9696",
+			},
+		},
+	}, {
+		name:           "controller configuration with invalid header policy",
+		wantErr:        true,
+		wantController: (*Config)(nil),
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:        noSidecarImage,
+				QueueSidecarHeaderPolicyKey: "X-Forwarded-*=https",
+			},
+		},
+	}, {
+		name:    "controller configuration with garbage collection",
+		wantErr: false,
+		wantController: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("ko.local", "dev.local"),
+			QueueSidecarImage:              noSidecarImage,
+			QueueSidecarGC: gctuning.Config{
+				GCPercent:   200,
+				MemoryLimit: 64 << 20,
+			},
+			ActivatorGC: gctuning.Config{
+				GCPercent:   gctuning.Off,
+				MemoryLimit: 1 << 30,
+				Ballast:     256 << 20,
+			},
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey:       noSidecarImage,
+				QueueSidecarGOGCKey:        "200",
+				QueueSidecarMemoryLimitKey: "64Mi",
+				ActivatorGOGCKey:           "off",
+				ActivatorMemoryLimitKey:    "1Gi",
+				ActivatorBallastKey:        "256Mi",
+			},
+		},
+	}, {
+		name:           "controller configuration with invalid garbage collection",
+		wantErr:        true,
+		wantController: (*Config)(nil),
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				QueueSidecarImageKey: noSidecarImage,
+				ActivatorBallastKey:  "lots",
+			},
+		},
 	}, {
 		name:           "controller with no side car image",
 		wantErr:        true,
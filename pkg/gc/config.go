@@ -18,6 +18,7 @@ package gc
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -29,6 +30,17 @@ const (
 	ConfigName = "config-gc"
 )
 
+// OrphanSweepMode controls what is done about networking resources
+// whose owner no longer exists.
+type OrphanSweepMode string
+
+const (
+	// OrphanSweepReport only reports orphaned resources.
+	OrphanSweepReport OrphanSweepMode = "report"
+	// OrphanSweepDelete deletes orphaned resources.
+	OrphanSweepDelete OrphanSweepMode = "delete"
+)
+
 type Config struct {
 	// Delay duration after a revision create before considering it for GC
 	StaleRevisionCreateDelay time.Duration
@@ -39,6 +51,8 @@ type Config struct {
 	StaleRevisionMinimumGenerations int64
 	// Minimum staleness duration before updating lastPinned
 	StaleRevisionLastpinnedDebounce time.Duration
+	// What to do about networking resources whose owner is gone
+	OrphanSweepMode OrphanSweepMode
 }
 
 func NewConfigFromConfigMapFunc(logger configmap.Logger, minRevisionTimeout time.Duration) func(configMap *corev1.ConfigMap) (*Config, error) {
@@ -81,6 +95,16 @@ func NewConfigFromConfigMapFunc(logger configmap.Logger, minRevisionTimeout time
 			c.StaleRevisionMinimumGenerations = val
 		}
 
+		switch mode := OrphanSweepMode(configMap.Data["orphan-sweep-mode"]); mode {
+		case "":
+			c.OrphanSweepMode = OrphanSweepReport
+		case OrphanSweepReport, OrphanSweepDelete:
+			c.OrphanSweepMode = mode
+		default:
+			return nil, fmt.Errorf("orphan-sweep-mode must be %q or %q, was %q",
+				OrphanSweepReport, OrphanSweepDelete, mode)
+		}
+
 		if c.StaleRevisionTimeout-c.StaleRevisionLastpinnedDebounce < minRevisionTimeout {
 			logger.Errorf("Got revision timeout of %v, minimum supported value is %v", c.StaleRevisionTimeout, minRevisionTimeout+c.StaleRevisionLastpinnedDebounce)
 			c.StaleRevisionTimeout = minRevisionTimeout + c.StaleRevisionLastpinnedDebounce
@@ -44,6 +44,7 @@ func TestOurConfig(t *testing.T) {
 			StaleRevisionTimeout:            15 * time.Hour,
 			StaleRevisionMinimumGenerations: 1,
 			StaleRevisionLastpinnedDebounce: 5 * time.Hour,
+			OrphanSweepMode:                 OrphanSweepReport,
 		},
 		data: actual,
 	}, {
@@ -54,6 +55,7 @@ func TestOurConfig(t *testing.T) {
 			StaleRevisionTimeout:            15 * time.Hour,
 			StaleRevisionMinimumGenerations: 1,
 			StaleRevisionLastpinnedDebounce: 5 * time.Hour,
+			OrphanSweepMode:                 OrphanSweepReport,
 		},
 		data: example,
 	}, {
@@ -63,6 +65,7 @@ func TestOurConfig(t *testing.T) {
 			StaleRevisionTimeout:            15 * time.Hour,
 			StaleRevisionMinimumGenerations: 10,
 			StaleRevisionLastpinnedDebounce: 5 * time.Hour,
+			OrphanSweepMode:                 OrphanSweepReport,
 		},
 		data: &corev1.ConfigMap{
 			Data: map[string]string{
@@ -70,6 +73,29 @@ func TestOurConfig(t *testing.T) {
 				"stale-revision-minimum-generations": "10",
 			},
 		},
+	}, {
+		name: "Delete orphans",
+		want: &Config{
+			StaleRevisionCreateDelay:        24 * time.Hour,
+			StaleRevisionTimeout:            15 * time.Hour,
+			StaleRevisionMinimumGenerations: 1,
+			StaleRevisionLastpinnedDebounce: 5 * time.Hour,
+			OrphanSweepMode:                 OrphanSweepDelete,
+		},
+		data: &corev1.ConfigMap{
+			Data: map[string]string{
+				"orphan-sweep-mode": "delete",
+			},
+		},
+	}, {
+		name: "Invalid orphan sweep mode",
+		fail: true,
+		want: nil,
+		data: &corev1.ConfigMap{
+			Data: map[string]string{
+				"orphan-sweep-mode": "sweep",
+			},
+		},
 	}, {
 		name: "Invalid duration",
 		fail: true,
@@ -105,6 +131,7 @@ func TestOurConfig(t *testing.T) {
 			StaleRevisionTimeout:            15 * time.Hour,
 			StaleRevisionMinimumGenerations: 10,
 			StaleRevisionLastpinnedDebounce: 5 * time.Hour,
+			OrphanSweepMode:                 OrphanSweepReport,
 		},
 		data: &corev1.ConfigMap{
 			Data: map[string]string{
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gctuning tunes the garbage collector of the data plane
+// components, whose default behavior lets the heap of a busy process grow
+// in bursts and pause requests while it is collected.
+package gctuning
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Off is the GCPercent turning the garbage collector off, like GOGC=off.
+const Off = -1
+
+// Config is the tuning of the garbage collector of a process. Its zero
+// value keeps the defaults of the Go runtime.
+type Config struct {
+	// GCPercent is the GOGC of the process, or Off. The runtime's default
+	// applies when it is 0.
+	GCPercent int
+
+	// MemoryLimit is the soft memory limit of the process in bytes, as set
+	// by GOMEMLIMIT. The process has no limit when it is 0.
+	MemoryLimit int64
+
+	// Ballast is the size in bytes of a heap ballast, which is allocated
+	// but never touched. It makes the collector wait for the heap to grow
+	// past it before collecting, without using up the memory.
+	Ballast int64
+}
+
+// Parse creates a Config from the values of GOGC, GOMEMLIMIT and of the
+// ballast. The sizes are quantities, e.g. "512Mi". Empty values keep the
+// default.
+func Parse(gcPercent, memoryLimit, ballast string) (Config, error) {
+	var c Config
+	var err error
+	if c.GCPercent, err = parseGCPercent(gcPercent); err != nil {
+		return Config{}, err
+	}
+	if c.MemoryLimit, err = parseBytes(memoryLimit); err != nil {
+		return Config{}, fmt.Errorf("invalid memory limit: %v", err)
+	}
+	if c.Ballast, err = parseBytes(ballast); err != nil {
+		return Config{}, fmt.Errorf("invalid ballast: %v", err)
+	}
+	return c, nil
+}
+
+func parseGCPercent(raw string) (int, error) {
+	switch {
+	case raw == "":
+		return 0, nil
+	case strings.ToLower(raw) == "off":
+		return Off, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("GOGC must be a positive percentage or off, was %q", raw)
+	}
+	return v, nil
+}
+
+func parseBytes(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(raw)
+	if err != nil {
+		return 0, err
+	}
+	if q.Sign() < 0 {
+		return 0, fmt.Errorf("%q must not be negative", raw)
+	}
+	return q.Value(), nil
+}
+
+// GOGC returns the value of the GOGC environment variable applying the
+// GCPercent, and false for the runtime's default.
+func (c Config) GOGC() (string, bool) {
+	switch {
+	case c.GCPercent == Off:
+		return "off", true
+	case c.GCPercent > 0:
+		return strconv.Itoa(c.GCPercent), true
+	}
+	return "", false
+}
+
+var (
+	// The values the runtime started with, restored by the zero Config.
+	defaultGCPercent   int
+	defaultMemoryLimit int64
+
+	mu      sync.Mutex
+	ballast []byte
+)
+
+func init() {
+	defaultGCPercent = debug.SetGCPercent(100)
+	debug.SetGCPercent(defaultGCPercent)
+	// A negative limit reads the limit without changing it.
+	defaultMemoryLimit = debug.SetMemoryLimit(-1)
+}
+
+// Apply tunes the garbage collector of the running process to c.
+func Apply(c Config) {
+	gcPercent := c.GCPercent
+	if gcPercent == 0 {
+		gcPercent = defaultGCPercent
+	}
+	debug.SetGCPercent(gcPercent)
+
+	limit := c.MemoryLimit
+	if limit == 0 {
+		limit = defaultMemoryLimit
+	}
+	debug.SetMemoryLimit(limit)
+
+	SetBallast(c.Ballast)
+}
+
+// BallastBytes returns the size of the heap ballast of the process. The
+// ballast counts towards the allocated heap, but not the memory in use.
+func BallastBytes() uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return uint64(len(ballast))
+}
+
+// SetBallast replaces the heap ballast of the process with one of size
+// bytes, or drops it if size is 0.
+func SetBallast(size int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	if int64(len(ballast)) == size {
+		return
+	}
+	ballast = nil
+	if size > 0 {
+		ballast = make([]byte, size)
+	}
+}
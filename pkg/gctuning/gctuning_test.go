@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gctuning
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name                      string
+		gcPercent, limit, ballast string
+		want                      Config
+		wantErr                   bool
+	}{{
+		name: "defaults",
+	}, {
+		name:      "all set",
+		gcPercent: "200",
+		limit:     "1Gi",
+		ballast:   "256Mi",
+		want: Config{
+			GCPercent:   200,
+			MemoryLimit: 1 << 30,
+			Ballast:     256 << 20,
+		},
+	}, {
+		name:      "gc off",
+		gcPercent: "Off",
+		limit:     "500M",
+		want: Config{
+			GCPercent:   Off,
+			MemoryLimit: 500000000,
+		},
+	}, {
+		name:      "zero gc percent",
+		gcPercent: "0",
+		wantErr:   true,
+	}, {
+		name:      "negative gc percent",
+		gcPercent: "-1",
+		wantErr:   true,
+	}, {
+		name:      "bad gc percent",
+		gcPercent: "lots",
+		wantErr:   true,
+	}, {
+		name:    "bad limit",
+		limit:   "1Gb",
+		wantErr: true,
+	}, {
+		name:    "negative ballast",
+		ballast: "-1Mi",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.gcPercent, test.limit, test.ballast)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Parse() = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Parse() = %+v, want: %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGOGC(t *testing.T) {
+	tests := []struct {
+		gcPercent int
+		want      string
+		wantOK    bool
+	}{
+		{0, "", false},
+		{Off, "off", true},
+		{50, "50", true},
+	}
+	for _, test := range tests {
+		got, ok := Config{GCPercent: test.gcPercent}.GOGC()
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("GOGC() of %d = (%q, %v), want: (%q, %v)", test.gcPercent, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	defer Apply(Config{})
+
+	Apply(Config{GCPercent: 300, MemoryLimit: 1 << 30, Ballast: 1 << 20})
+	if got := debug.SetGCPercent(300); got != 300 {
+		t.Errorf("GCPercent = %d, want: 300", got)
+	}
+	if got := debug.SetMemoryLimit(-1); got != 1<<30 {
+		t.Errorf("MemoryLimit = %d, want: %d", got, 1<<30)
+	}
+	if got := BallastBytes(); got != 1<<20 {
+		t.Errorf("BallastBytes() = %d, want: %d", got, 1<<20)
+	}
+
+	Apply(Config{})
+	if got := debug.SetGCPercent(defaultGCPercent); got != defaultGCPercent {
+		t.Errorf("GCPercent = %d, want: %d", got, defaultGCPercent)
+	}
+	if got := debug.SetMemoryLimit(-1); got != defaultMemoryLimit {
+		t.Errorf("MemoryLimit = %d, want: %d", got, defaultMemoryLimit)
+	}
+	if got := BallastBytes(); got != 0 {
+		t.Errorf("BallastBytes() = %d, want the ballast to be dropped", got)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gctuning
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	gcPauseSecondsM = stats.Float64(
+		"gc_pause_seconds",
+		"The duration of the stop-the-world pauses of the garbage collector",
+		"s")
+	gcCPUFractionM = stats.Float64(
+		"gc_cpu_fraction",
+		"The fraction of the CPU time of the process used by the garbage collector",
+		stats.UnitDimensionless)
+	heapBytesM = stats.Int64(
+		"heap_bytes",
+		"The bytes of allocated heap objects, including the ballast",
+		stats.UnitBytes)
+
+	// Pauses range from tens of microseconds to a whole request timeout
+	// when the collector thrashes.
+	gcPauseDistribution = view.Distribution(0, .00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1)
+)
+
+// Reporter reports the pauses of the garbage collector, to tell how much
+// the latency of requests suffers from it.
+type Reporter struct {
+	ctx context.Context
+	// numGC is the number of collections reported so far.
+	numGC uint32
+}
+
+// NewReporter creates a reporter recording the metrics of the garbage
+// collector with the tags of ctx, whose keys are given.
+func NewReporter(ctx context.Context, keys ...tag.Key) (*Reporter, error) {
+	err := view.Register(
+		&view.View{
+			Description: gcPauseSecondsM.Description(),
+			Measure:     gcPauseSecondsM,
+			Aggregation: gcPauseDistribution,
+			TagKeys:     keys,
+		},
+		&view.View{
+			Description: gcCPUFractionM.Description(),
+			Measure:     gcCPUFractionM,
+			Aggregation: view.LastValue(),
+			TagKeys:     keys,
+		},
+		&view.View{
+			Description: heapBytesM.Description(),
+			Measure:     heapBytesM,
+			Aggregation: view.LastValue(),
+			TagKeys:     keys,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{ctx: ctx}, nil
+}
+
+// Run reports the collections that happened since the previous tick on
+// every tick, until stopCh is closed.
+func (r *Reporter) Run(ticks <-chan time.Time, stopCh <-chan struct{}) {
+	var ms runtime.MemStats
+	for {
+		select {
+		case <-ticks:
+			runtime.ReadMemStats(&ms)
+			r.report(&ms)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (r *Reporter) report(ms *runtime.MemStats) {
+	// The runtime only keeps the last pauses, older ones are lost when more
+	// collections happened between two ticks.
+	from := r.numGC
+	if kept := uint32(len(ms.PauseNs)); ms.NumGC-from > kept {
+		from = ms.NumGC - kept
+	}
+	for n := from + 1; n <= ms.NumGC; n++ {
+		// The pause of the n-th collection, as documented by MemStats.
+		pause := time.Duration(ms.PauseNs[(n+255)%256])
+		metrics.Record(r.ctx, gcPauseSecondsM.M(pause.Seconds()))
+	}
+	r.numGC = ms.NumGC
+
+	metrics.Record(r.ctx, gcCPUFractionM.M(ms.GCCPUFraction))
+	metrics.Record(r.ctx, heapBytesM.M(int64(ms.HeapAlloc)))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gctuning
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestReporter(t *testing.T) {
+	r, err := NewReporter(context.Background())
+	if err != nil {
+		t.Fatalf("NewReporter() = %v", err)
+	}
+	defer view.Unregister(
+		view.Find(gcPauseSecondsM.Name()),
+		view.Find(gcCPUFractionM.Name()),
+		view.Find(heapBytesM.Name()))
+
+	ms := &runtime.MemStats{NumGC: 2, GCCPUFraction: 0.25, HeapAlloc: 4096}
+	ms.PauseNs[0] = uint64(time.Millisecond)
+	ms.PauseNs[1] = uint64(3 * time.Millisecond)
+	r.report(ms)
+
+	// Only the collections since the last report are reported again, and
+	// the pauses the runtime dropped are skipped.
+	ms.NumGC = 300
+	r.report(ms)
+	if r.numGC != 300 {
+		t.Errorf("numGC = %d, want: 300", r.numGC)
+	}
+
+	d, err := view.RetrieveData(gcPauseSecondsM.Name())
+	if err != nil {
+		t.Fatalf("RetrieveData() = %v", err)
+	}
+	if len(d) != 1 {
+		t.Fatalf("len(RetrieveData()) = %d, want: 1", len(d))
+	}
+	dist := d[0].Data.(*view.DistributionData)
+	if got, want := dist.Count, int64(2+256); got != want {
+		t.Errorf("Count = %d, want: %d", got, want)
+	}
+	if got, want := dist.Max, (3 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("Max = %v, want: %v", got, want)
+	}
+
+	d, err = view.RetrieveData(gcCPUFractionM.Name())
+	if err != nil {
+		t.Fatalf("RetrieveData() = %v", err)
+	}
+	if got := d[0].Data.(*view.LastValueData).Value; got != 0.25 {
+		t.Errorf("gc_cpu_fraction = %v, want: 0.25", got)
+	}
+}
+
+func TestReporterRun(t *testing.T) {
+	r, err := NewReporter(context.Background())
+	if err != nil {
+		t.Fatalf("NewReporter() = %v", err)
+	}
+	defer view.Unregister(
+		view.Find(gcPauseSecondsM.Name()),
+		view.Find(gcCPUFractionM.Name()),
+		view.Find(heapBytesM.Name()))
+
+	ticks := make(chan time.Time)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Run(ticks, stopCh)
+		close(done)
+	}()
+
+	runtime.GC()
+	ticks <- time.Now()
+	close(stopCh)
+	<-done
+
+	if r.numGC == 0 {
+		t.Error("numGC = 0, want the collection to be reported")
+	}
+}
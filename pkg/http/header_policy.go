@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+// protectedHeaders are used by Knative itself between its components and
+// are never touched by a HeaderPolicy.
+var protectedHeaders = map[string]bool{
+	network.ProbeHeaderName:           true,
+	network.ProxyHeaderName:           true,
+	network.KubeletProbeHeaderName:    true,
+	network.OriginalHostHeader:        true,
+	network.ProtocolVersionHeaderName: true,
+}
+
+// HeaderPolicy describes the request headers that are removed or replaced
+// before a request reaches the user container, so that clients cannot
+// spoof headers that the application or the mesh trust.
+type HeaderPolicy struct {
+	// Strip lists the canonical names of the headers to remove. Names
+	// ending in "*" remove all headers starting with the rest of the name.
+	Strip []string
+	// Override maps the canonical names of headers to the value they are
+	// set to, whatever the client sent.
+	Override map[string]string
+}
+
+// ParseHeaderPolicy creates a HeaderPolicy from a comma separated list of
+// entries. An entry "Name" strips the header, "Prefix-*" strips all the
+// headers with the prefix and "Name=value" overrides the header. An empty
+// string imposes no policy.
+func ParseHeaderPolicy(s string) (HeaderPolicy, error) {
+	p := HeaderPolicy{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, value, override := entry, "", false
+		if i := strings.Index(entry, "="); i >= 0 {
+			name, value, override = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:]), true
+		}
+		prefix := strings.HasSuffix(name, "*")
+		if strings.TrimSuffix(name, "*") == "" || strings.ContainsAny(strings.TrimSuffix(name, "*"), "* \t") {
+			return HeaderPolicy{}, fmt.Errorf("invalid header policy entry %q", entry)
+		}
+		if prefix && override {
+			return HeaderPolicy{}, fmt.Errorf("invalid header policy entry %q: prefixes can only be stripped", entry)
+		}
+		if prefix {
+			// Canonicalizing "X-Forwarded-" gives "X-Forwarded-", which is a
+			// prefix of the canonical form of every matching header.
+			p.Strip = append(p.Strip, http.CanonicalHeaderKey(strings.TrimSuffix(name, "*"))+"*")
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		if protectedHeaders[name] {
+			return HeaderPolicy{}, fmt.Errorf("invalid header policy entry %q: %s is used by Knative", entry, name)
+		}
+		if override {
+			if p.Override == nil {
+				p.Override = make(map[string]string)
+			}
+			p.Override[name] = value
+		} else {
+			p.Strip = append(p.Strip, name)
+		}
+	}
+	return p, nil
+}
+
+// IsZero returns true if the policy doesn't change any header.
+func (p HeaderPolicy) IsZero() bool {
+	return len(p.Strip) == 0 && len(p.Override) == 0
+}
+
+// Apply removes and overrides the headers of h according to the policy.
+func (p HeaderPolicy) Apply(h http.Header) {
+	for _, strip := range p.Strip {
+		if !strings.HasSuffix(strip, "*") {
+			h.Del(strip)
+			continue
+		}
+		prefix := strings.TrimSuffix(strip, "*")
+		for name := range h {
+			if strings.HasPrefix(name, prefix) && !protectedHeaders[name] {
+				delete(h, name)
+			}
+		}
+	}
+	for name, value := range p.Override {
+		h.Set(name, value)
+	}
+}
+
+// NewHeaderPolicyHandler returns a handler that applies the policy to the
+// headers of every request before passing it on to next.
+func NewHeaderPolicyHandler(next http.Handler, p HeaderPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.Apply(r.Header)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestParseHeaderPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    HeaderPolicy
+		wantErr bool
+	}{{
+		name: "empty",
+		want: HeaderPolicy{},
+	}, {
+		name:   "strip and override",
+		policy: "knative-*, x-forwarded-host,X-Forwarded-Proto = https",
+		want: HeaderPolicy{
+			Strip:    []string{"Knative-*", "X-Forwarded-Host"},
+			Override: map[string]string{"X-Forwarded-Proto": "https"},
+		},
+	}, {
+		name:    "override of a prefix",
+		policy:  "X-Forwarded-*=foo",
+		wantErr: true,
+	}, {
+		name:    "wildcard only",
+		policy:  "*",
+		wantErr: true,
+	}, {
+		name:    "wildcard in the middle",
+		policy:  "X-*-Host",
+		wantErr: true,
+	}, {
+		name:    "protected header",
+		policy:  network.ProxyHeaderName,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseHeaderPolicy(test.policy)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseHeaderPolicy() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParseHeaderPolicy (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestHeaderPolicyHandler(t *testing.T) {
+	policy, err := ParseHeaderPolicy("K-*,Knative-*,X-Forwarded-Host,X-Forwarded-Proto=https")
+	if err != nil {
+		t.Fatalf("ParseHeaderPolicy() = %v", err)
+	}
+
+	var got http.Header
+	h := NewHeaderPolicyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}), policy)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("Knative-Serving-Tag", "canary")
+	req.Header.Set("Knative-Priority", "high")
+	req.Header.Set("K-Custom", "spoofed")
+	req.Header.Set(network.ProxyHeaderName, "activator")
+	req.Header.Set("X-Forwarded-Host", "evil.com")
+	req.Header.Set("X-Forwarded-Proto", "http")
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := http.Header{
+		network.ProxyHeaderName: {"activator"},
+		"X-Forwarded-Proto":     {"https"},
+		"X-Forwarded-For":       {"1.2.3.4"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Headers (-want, +got) = %s", diff)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"knative.dev/pkg/websocket"
+)
+
+// IsInformational returns true if the given status code is a 1xx
+// informational response that is followed by a final response, e.g.
+// 100 Continue or 103 Early Hints. 101 Switching Protocols is excluded,
+// since it terminates the HTTP exchange.
+func IsInformational(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
+
+// NewEarlyHintsFilter returns a handler that drops 103 Early Hints
+// responses written by `h`, so that they are not forwarded to the client.
+// All other responses are passed through unmodified.
+func NewEarlyHintsFilter(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&earlyHintsFilter{writer: w}, r)
+	})
+}
+
+type earlyHintsFilter struct {
+	writer http.ResponseWriter
+}
+
+var (
+	_ http.Flusher        = (*earlyHintsFilter)(nil)
+	_ http.ResponseWriter = (*earlyHintsFilter)(nil)
+)
+
+func (f *earlyHintsFilter) Header() http.Header { return f.writer.Header() }
+
+func (f *earlyHintsFilter) Write(p []byte) (int, error) { return f.writer.Write(p) }
+
+func (f *earlyHintsFilter) WriteHeader(code int) {
+	if code == http.StatusEarlyHints {
+		return
+	}
+	f.writer.WriteHeader(code)
+}
+
+func (f *earlyHintsFilter) Flush() {
+	f.writer.(http.Flusher).Flush()
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (f *earlyHintsFilter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return websocket.HijackIfPossible(f.writer)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsInformational(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusContinue, true},
+		{http.StatusSwitchingProtocols, false},
+		{http.StatusEarlyHints, true},
+		{http.StatusOK, false},
+		{http.StatusBadGateway, false},
+	}
+
+	for _, test := range tests {
+		if got := IsInformational(test.code); got != test.want {
+			t.Errorf("IsInformational(%d) = %v, want %v", test.code, got, test.want)
+		}
+	}
+}
+
+// proxyChain mimics the handler chain of activator and queue-proxy: a
+// reverse proxy behind a ResponseRecorder.
+func proxyChain(t *testing.T, target string, filterEarlyHints bool) (http.Handler, *int) {
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) = %v", target, err)
+	}
+	var h http.Handler = httputil.NewSingleHostReverseProxy(u)
+	if filterEarlyHints {
+		h = NewEarlyHintsFilter(h)
+	}
+	code := new(int)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rr := NewResponseRecorder(w, http.StatusOK)
+		h.ServeHTTP(rr, r)
+		*code = rr.ResponseCode
+	}), code
+}
+
+func TestProxyEarlyHints(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name      string
+		filter    bool
+		wantHints []string
+	}{{
+		name:      "forwarded",
+		wantHints: []string{"</style.css>; rel=preload; as=style"},
+	}, {
+		name:   "filtered",
+		filter: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, code := proxyChain(t, upstream.URL, test.filter)
+			proxy := httptest.NewServer(h)
+			defer proxy.Close()
+
+			var (
+				mu    sync.Mutex
+				hints []string
+			)
+			trace := &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					mu.Lock()
+					defer mu.Unlock()
+					if code == http.StatusEarlyHints {
+						hints = append(hints, header.Get("Link"))
+					}
+					return nil
+				},
+			}
+			req, _ := http.NewRequest(http.MethodGet, proxy.URL, nil)
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if got, want := resp.StatusCode, http.StatusCreated; got != want {
+				t.Errorf("StatusCode = %d, want %d", got, want)
+			}
+			if got, want := *code, http.StatusCreated; got != want {
+				t.Errorf("Recorded code = %d, want %d", got, want)
+			}
+			if got, want := string(body), "done"; got != want {
+				t.Errorf("Body = %q, want %q", got, want)
+			}
+			if got := resp.Header.Get("Link"); got != "" {
+				t.Errorf("Final response leaked Link header %q", got)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !cmp.Equal(hints, test.wantHints) {
+				t.Errorf("Early hints = %v, want %v", hints, test.wantHints)
+			}
+		})
+	}
+}
+
+func TestProxyContinue(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	h, code := proxyChain(t, upstream.URL, true)
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	var (
+		mu        sync.Mutex
+		continued bool
+	)
+	trace := &httptrace.ClientTrace{
+		Got100Continue: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			continued = true
+		},
+	}
+	req, _ := http.NewRequest(http.MethodPost, proxy.URL, strings.NewReader("payload"))
+	req.Header.Set("Expect", "100-continue")
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	client := &http.Client{
+		Transport: &http.Transport{ExpectContinueTimeout: 10 * time.Second},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+	if got, want := *code, http.StatusOK; got != want {
+		t.Errorf("Recorded code = %d, want %d", got, want)
+	}
+	if got, want := string(body), "payload"; got != want {
+		t.Errorf("Body = %q, want %q", got, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !continued {
+		t.Error("Expected a 100 Continue response")
+	}
+}
+
+func TestProxyTrailers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("body"))
+		w.(http.Flusher).Flush()
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+	}))
+	defer upstream.Close()
+
+	h, _ := proxyChain(t, upstream.URL, true)
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if got, want := string(body), "body"; got != want {
+		t.Errorf("Body = %q, want %q", got, want)
+	}
+	want := http.Header{
+		"Grpc-Status":  []string{"0"},
+		"Grpc-Message": []string{"ok"},
+	}
+	if diff := cmp.Diff(want, resp.Trailer); diff != "" {
+		t.Errorf("Trailer (-want, +got) = %v", diff)
+	}
+}
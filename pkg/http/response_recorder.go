@@ -82,10 +82,16 @@ func (rr *ResponseRecorder) Write(p []byte) (int, error) {
 }
 
 // WriteHeader sends an HTTP response header with the provided status code.
+// Informational (1xx) responses are passed through without being recorded,
+// since they are followed by the final response.
 func (rr *ResponseRecorder) WriteHeader(code int) {
 	if rr.wroteHeader || atomic.LoadInt32(&rr.hijacked) == 1 {
 		return
 	}
+	if IsInformational(code) {
+		rr.writer.WriteHeader(code)
+		return
+	}
 
 	rr.writer.WriteHeader(code)
 	rr.wroteHeader = true
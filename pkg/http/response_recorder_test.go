@@ -36,6 +36,7 @@ func TestResponseRecorder(t *testing.T) {
 	tests := []struct {
 		name          string
 		initialStatus int
+		informational int
 		finalStatus   int
 		hijack        bool
 		writeSize     int
@@ -57,6 +58,14 @@ func TestResponseRecorder(t *testing.T) {
 		writeSize:     12,
 		wantStatus:    http.StatusAccepted,
 		wantSize:      12,
+	}, {
+		name:          "informational",
+		initialStatus: http.StatusOK,
+		informational: http.StatusEarlyHints,
+		finalStatus:   http.StatusBadGateway,
+		writeSize:     12,
+		wantStatus:    http.StatusBadGateway,
+		wantSize:      12,
 	}}
 
 	for _, test := range tests {
@@ -65,6 +74,9 @@ func TestResponseRecorder(t *testing.T) {
 			if test.hijack {
 				rr.Hijack()
 			}
+			if test.informational != 0 {
+				rr.WriteHeader(test.informational)
+			}
 
 			b := make([]byte, test.writeSize)
 			rr.Write(b)
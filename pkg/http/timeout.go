@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+// ErrRequestTimeoutExpired indicates that the timeout the client hinted for
+// the request expired before it was forwarded.
+var ErrRequestTimeoutExpired = errors.New("request timeout expired")
+
+// grpcTimeoutUnits are the units of the grpc-timeout header, from the
+// finest to the coarsest.
+var grpcTimeoutUnits = []struct {
+	unit byte
+	d    time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// grpcTimeoutMaxDigits is how many digits the value of the grpc-timeout
+// header may have.
+const grpcTimeoutMaxDigits = 8
+
+// ParseGRPCTimeout parses the value of a grpc-timeout header, a positive
+// integer of at most 8 digits followed by one of the units H, M, S, m, u
+// and n, e.g. "100m" for 100 milliseconds.
+func ParseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > grpcTimeoutMaxDigits+1 {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", v)
+	}
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", v)
+	}
+	for _, u := range grpcTimeoutUnits {
+		if u.unit == v[len(v)-1] {
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown unit of grpc-timeout %q", v)
+}
+
+// FormatGRPCTimeout formats d as the value of a grpc-timeout header, in the
+// finest unit it fits 8 digits of, rounding up not to shorten it.
+func FormatGRPCTimeout(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	const max = 1e8 - 1
+	for _, u := range grpcTimeoutUnits {
+		if n := (d + u.d - 1) / u.d; n <= max {
+			return strconv.FormatInt(int64(n), 10) + string(u.unit)
+		}
+	}
+	// Durations don't go beyond 2562047 hours.
+	return strconv.FormatInt(int64(max), 10) + "H"
+}
+
+// RequestTimeout returns the shortest of the timeouts the client hinted
+// with the X-Request-Timeout and grpc-timeout headers, and whether it
+// hinted any.
+func RequestTimeout(h http.Header) (time.Duration, bool, error) {
+	var (
+		timeout time.Duration
+		ok      bool
+	)
+	if v := h.Get(network.RequestTimeoutHeaderName); v != "" {
+		d, err := parseRequestTimeout(v)
+		if err != nil {
+			return 0, false, err
+		}
+		timeout, ok = d, true
+	}
+	if v := h.Get(network.GRPCTimeoutHeaderName); v != "" {
+		d, err := ParseGRPCTimeout(v)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok || d < timeout {
+			timeout, ok = d, true
+		}
+	}
+	return timeout, ok, nil
+}
+
+// parseRequestTimeout parses the value of an X-Request-Timeout header, a
+// non-negative duration in the format of time.ParseDuration, e.g. "1.5s".
+func parseRequestTimeout(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("malformed %s %q", network.RequestTimeoutHeaderName, v)
+	}
+	return d, nil
+}
+
+// stripMalformedRequestTimeout removes the timeout hints of h that can't be
+// parsed, so that they are neither enforced nor forwarded.
+func stripMalformedRequestTimeout(h http.Header) {
+	if v := h.Get(network.RequestTimeoutHeaderName); v != "" {
+		if _, err := parseRequestTimeout(v); err != nil {
+			h.Del(network.RequestTimeoutHeaderName)
+		}
+	}
+	if v := h.Get(network.GRPCTimeoutHeaderName); v != "" {
+		if _, err := ParseGRPCTimeout(v); err != nil {
+			h.Del(network.GRPCTimeoutHeaderName)
+		}
+	}
+}
+
+// SetRequestTimeout rewrites the timeout hints the client sent to d. The
+// headers the client didn't send are left out.
+func SetRequestTimeout(h http.Header, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if h.Get(network.RequestTimeoutHeaderName) != "" {
+		h.Set(network.RequestTimeoutHeaderName, d.String())
+	}
+	if h.Get(network.GRPCTimeoutHeaderName) != "" {
+		h.Set(network.GRPCTimeoutHeaderName, FormatGRPCTimeout(d))
+	}
+}
+
+// WithRequestTimeout returns r with the deadline of the timeout the client
+// hinted, clamped to max unless max is 0, and the function releasing the
+// resources of the deadline. It fails with ErrRequestTimeoutExpired if the
+// timeout already expired, and returns r as is if there is no hint.
+// Malformed hints are ignored and removed from r, since they are only hints
+// and clients may send them in formats of other systems, e.g. "30".
+func WithRequestTimeout(r *http.Request, max time.Duration) (*http.Request, context.CancelFunc, error) {
+	stripMalformedRequestTimeout(r.Header)
+	// The hints left are well-formed.
+	d, ok, _ := RequestTimeout(r.Header)
+	if !ok {
+		return r, func() {}, nil
+	}
+	if d <= 0 {
+		return r, func() {}, ErrRequestTimeoutExpired
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	return r.WithContext(ctx), cancel, nil
+}
+
+// SetupRequestTimeoutForwarding makes the proxy rewrite the timeout hints
+// of the requests to the time left until their deadline, so that the next
+// hop doesn't wait for longer than the client, and answer the requests
+// whose deadline passed while they were proxied as such.
+func SetupRequestTimeoutForwarding(p *httputil.ReverseProxy) {
+	orig := p.Director
+	p.Director = func(r *http.Request) {
+		orig(r)
+
+		if deadline, ok := r.Context().Deadline(); ok {
+			SetRequestTimeout(r.Header, time.Until(deadline))
+		}
+	}
+	origErr := p.ErrorHandler
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		switch {
+		case r.Context().Err() == context.DeadlineExceeded:
+			WriteDeadlineExceeded(w, r)
+		case origErr != nil:
+			origErr(w, r, err)
+		default:
+			// Like the default error handler of the proxy.
+			if p.ErrorLog != nil {
+				p.ErrorLog.Printf("http: proxy error: %v", err)
+			} else {
+				log.Printf("http: proxy error: %v", err)
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}
+}
+
+// NewRequestTimeoutHandler returns a handler enforcing the timeouts hinted
+// by the clients, clamped to the one returned by max. The requests with an
+// expired hint are rejected right away, and malformed hints are ignored.
+func NewRequestTimeoutHandler(h http.Handler, max func() time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, cancel, err := WithRequestTimeout(r, max())
+		defer cancel()
+		if err == ErrRequestTimeoutExpired {
+			WriteDeadlineExceeded(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// WriteDeadlineExceeded responds that the request's timeout expired, with a
+// DEADLINE_EXCEEDED status for gRPC calls and a 504 otherwise.
+func WriteDeadlineExceeded(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); strings.HasPrefix(ct, "application/grpc") {
+		// A trailers-only response, as gRPC clients map HTTP statuses
+		// other than 200 to UNAVAILABLE or UNKNOWN.
+		w.Header().Set("Content-Type", ct)
+		w.Header().Set("Grpc-Status", "4")
+		w.Header().Set("Grpc-Message", ErrRequestTimeoutExpired.Error())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Error(w, ErrRequestTimeoutExpired.Error(), http.StatusGatewayTimeout)
+}
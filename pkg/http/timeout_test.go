@@ -0,0 +1,301 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "1H", want: time.Hour},
+		{in: "2M", want: 2 * time.Minute},
+		{in: "30S", want: 30 * time.Second},
+		{in: "100m", want: 100 * time.Millisecond},
+		{in: "5u", want: 5 * time.Microsecond},
+		{in: "99999999n", want: 99999999 * time.Nanosecond},
+		{in: "0m", want: 0},
+		{in: "", wantErr: true},
+		{in: "m", wantErr: true},
+		{in: "100", wantErr: true},
+		{in: "100x", wantErr: true},
+		{in: "-1S", wantErr: true},
+		{in: "100000000S", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParseGRPCTimeout(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseGRPCTimeout() = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseGRPCTimeout() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: -time.Second, want: "0n"},
+		{in: 0, want: "0n"},
+		{in: 99 * time.Millisecond, want: "99000000n"},
+		{in: 1500 * time.Millisecond, want: "1500000u"},
+		// Rounded up not to shorten the timeout.
+		{in: 100*time.Second + time.Nanosecond, want: "100001m"},
+		{in: 30 * time.Hour, want: "108000S"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in.String(), func(t *testing.T) {
+			if got := FormatGRPCTimeout(test.in); got != test.want {
+				t.Errorf("FormatGRPCTimeout() = %s, want %s", got, test.want)
+			}
+			if d, err := ParseGRPCTimeout(FormatGRPCTimeout(test.in)); err != nil || (test.in > 0 && d < test.in) {
+				t.Errorf("ParseGRPCTimeout(FormatGRPCTimeout()) = %v, %v, want at least %v", d, err, test.in)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		want    time.Duration
+		wantOK  bool
+		wantErr bool
+	}{{
+		name: "no hint",
+	}, {
+		name:   "request timeout",
+		header: http.Header{network.RequestTimeoutHeaderName: {"1.5s"}},
+		want:   1500 * time.Millisecond,
+		wantOK: true,
+	}, {
+		name:   "grpc timeout",
+		header: http.Header{network.GRPCTimeoutHeaderName: {"200m"}},
+		want:   200 * time.Millisecond,
+		wantOK: true,
+	}, {
+		name: "the shortest of both",
+		header: http.Header{
+			network.RequestTimeoutHeaderName: {"1s"},
+			network.GRPCTimeoutHeaderName:    {"2S"},
+		},
+		want:   time.Second,
+		wantOK: true,
+	}, {
+		name:    "malformed request timeout",
+		header:  http.Header{network.RequestTimeoutHeaderName: {"soon"}},
+		wantErr: true,
+	}, {
+		name:    "negative request timeout",
+		header:  http.Header{network.RequestTimeoutHeaderName: {"-1s"}},
+		wantErr: true,
+	}, {
+		name:    "malformed grpc timeout",
+		header:  http.Header{network.GRPCTimeoutHeaderName: {"1s"}},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok, err := RequestTimeout(test.header)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RequestTimeout() = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("RequestTimeout() = %v, %v, want %v, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestSetRequestTimeout(t *testing.T) {
+	h := http.Header{network.GRPCTimeoutHeaderName: {"1S"}}
+	SetRequestTimeout(h, 250*time.Millisecond)
+	if got, want := h.Get(network.GRPCTimeoutHeaderName), "250000u"; got != want {
+		t.Errorf("grpc-timeout = %s, want %s", got, want)
+	}
+	if _, ok := h[network.RequestTimeoutHeaderName]; ok {
+		t.Errorf("X-Request-Timeout = %s, want none as the client didn't send it", h.Get(network.RequestTimeoutHeaderName))
+	}
+
+	h = http.Header{network.RequestTimeoutHeaderName: {"1s"}}
+	SetRequestTimeout(h, -time.Second)
+	if got, want := h.Get(network.RequestTimeoutHeaderName), "0s"; got != want {
+		t.Errorf("X-Request-Timeout = %s, want %s", got, want)
+	}
+}
+
+func TestRequestTimeoutHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       http.Header
+		max          time.Duration
+		wantStatus   int
+		wantDeadline time.Duration
+		wantGRPC     string
+	}{{
+		name:       "no hint",
+		max:        time.Minute,
+		wantStatus: http.StatusOK,
+	}, {
+		name:         "hint",
+		header:       http.Header{network.RequestTimeoutHeaderName: {"10s"}},
+		max:          time.Minute,
+		wantStatus:   http.StatusOK,
+		wantDeadline: 10 * time.Second,
+	}, {
+		name:         "clamped to the max",
+		header:       http.Header{network.GRPCTimeoutHeaderName: {"1H"}},
+		max:          time.Minute,
+		wantStatus:   http.StatusOK,
+		wantDeadline: time.Minute,
+	}, {
+		name:         "no max",
+		header:       http.Header{network.GRPCTimeoutHeaderName: {"1H"}},
+		wantStatus:   http.StatusOK,
+		wantDeadline: time.Hour,
+	}, {
+		name:       "expired",
+		header:     http.Header{network.RequestTimeoutHeaderName: {"0s"}},
+		max:        time.Minute,
+		wantStatus: http.StatusGatewayTimeout,
+	}, {
+		name: "expired gRPC call",
+		header: http.Header{
+			network.GRPCTimeoutHeaderName: {"0n"},
+			"Content-Type":                {"application/grpc+proto"},
+		},
+		max:        time.Minute,
+		wantStatus: http.StatusOK,
+		wantGRPC:   "4",
+	}, {
+		name:       "malformed",
+		header:     http.Header{network.RequestTimeoutHeaderName: {"30"}},
+		max:        time.Minute,
+		wantStatus: http.StatusOK,
+	}, {
+		name: "malformed and well-formed",
+		header: http.Header{
+			network.RequestTimeoutHeaderName: {"30"},
+			network.GRPCTimeoutHeaderName:    {"10S"},
+		},
+		max:          time.Minute,
+		wantStatus:   http.StatusOK,
+		wantDeadline: 10 * time.Second,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				called   bool
+				deadline time.Time
+				hasDl    bool
+				hint     string
+			)
+			h := NewRequestTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				deadline, hasDl = r.Context().Deadline()
+				hint = r.Header.Get(network.RequestTimeoutHeaderName)
+			}), func() time.Duration { return test.max })
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			for k, v := range test.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			start := time.Now()
+			h.ServeHTTP(w, r)
+
+			if got, want := w.Code, test.wantStatus; got != want {
+				t.Errorf("Status = %d, want %d", got, want)
+			}
+			if got, want := w.Header().Get("Grpc-Status"), test.wantGRPC; got != want {
+				t.Errorf("Grpc-Status = %q, want %q", got, want)
+			}
+			if wantCalled := test.wantStatus == http.StatusOK && test.wantGRPC == ""; called != wantCalled {
+				t.Fatalf("Called = %v, want %v", called, wantCalled)
+			}
+			if hasDl != (test.wantDeadline > 0) {
+				t.Fatalf("Has deadline = %v, want %v", hasDl, test.wantDeadline > 0)
+			}
+			if hasDl {
+				if got := deadline.Sub(start); got > test.wantDeadline+time.Second || got < test.wantDeadline {
+					t.Errorf("Deadline in %v, want %v", got, test.wantDeadline)
+				}
+			}
+			// Malformed hints are stripped.
+			if _, err := parseRequestTimeout(hint); hint != "" && err != nil {
+				t.Errorf("Passed on the malformed %s %q", network.RequestTimeoutHeaderName, hint)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutForwarding(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	SetupRequestTimeoutForwarding(proxy)
+
+	// The time spent in this hop is taken off the hints of the next.
+	h := NewRequestTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		proxy.ServeHTTP(w, r)
+	}), func() time.Duration { return 2 * time.Second })
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(network.RequestTimeoutHeaderName, "1m")
+	r.Header.Set(network.GRPCTimeoutHeaderName, "1M")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+	for _, name := range []string{network.RequestTimeoutHeaderName, network.GRPCTimeoutHeaderName} {
+		d, ok, err := RequestTimeout(http.Header{name: got[name]})
+		if err != nil || !ok {
+			t.Fatalf("RequestTimeout(%s) = %v, %v", name, ok, err)
+		}
+		if d > 1900*time.Millisecond || d < time.Second {
+			t.Errorf("%s = %s, want the 2s clamp less the time spent in the hop", name, got.Get(name))
+		}
+	}
+}
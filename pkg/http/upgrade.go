@@ -0,0 +1,174 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	// ErrUpgradeNotAllowed indicates that the requested protocol upgrade
+	// is not in the allow-list of the revision.
+	ErrUpgradeNotAllowed = errors.New("protocol upgrade not allowed")
+	// ErrTooManyUpgrades indicates that the revision already has the
+	// maximum number of upgraded connections open.
+	ErrTooManyUpgrades = errors.New("too many upgraded connections")
+)
+
+// UpgradePolicy describes which protocol upgrades are permitted and how
+// many upgraded connections may be open at the same time.
+type UpgradePolicy struct {
+	// AllowedProtocols lists the permitted (lowercased) values of the
+	// Upgrade header. A nil set permits every protocol.
+	AllowedProtocols sets.String
+	// MaxConnections caps the number of concurrently open upgraded
+	// connections. 0 means unlimited.
+	MaxConnections int
+}
+
+// ParseUpgradePolicy creates an UpgradePolicy from a comma separated
+// list of protocols and a connection limit. Empty values impose no
+// restrictions.
+func ParseUpgradePolicy(protocols, maxConnections string) (UpgradePolicy, error) {
+	p := UpgradePolicy{}
+	if protocols = strings.TrimSpace(protocols); protocols != "" {
+		p.AllowedProtocols = sets.NewString()
+		for _, proto := range strings.Split(protocols, ",") {
+			if proto = strings.TrimSpace(proto); proto != "" {
+				p.AllowedProtocols.Insert(strings.ToLower(proto))
+			}
+		}
+	}
+	if maxConnections != "" {
+		max, err := strconv.Atoi(maxConnections)
+		if err != nil || max < 0 {
+			return UpgradePolicy{}, fmt.Errorf("invalid upgraded connection limit %q: must be an integer equal or greater than 0", maxConnections)
+		}
+		p.MaxConnections = max
+	}
+	return p, nil
+}
+
+// UpgradePolicyFromAnnotations creates the UpgradePolicy of a revision
+// from its annotations. Invalid values are rejected by the webhook, so
+// they are ignored here.
+func UpgradePolicyFromAnnotations(annotations map[string]string) UpgradePolicy {
+	p, err := ParseUpgradePolicy(annotations[serving.AllowedUpgradeProtocolsAnnotationKey],
+		annotations[serving.MaxUpgradedConnectionsAnnotationKey])
+	if err != nil {
+		p, _ = ParseUpgradePolicy(annotations[serving.AllowedUpgradeProtocolsAnnotationKey], "")
+	}
+	return p
+}
+
+// UpgradeProtocol returns the lowercased protocol the request asks to be
+// upgraded to, or the empty string if it is not an upgrade request.
+func UpgradeProtocol(r *http.Request) string {
+	upgrade := false
+	for _, v := range r.Header["Connection"] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				upgrade = true
+			}
+		}
+	}
+	if !upgrade {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(r.Header.Get("Upgrade")))
+}
+
+// UpgradeTracker keeps track of the upgraded connections that are
+// currently open, keyed by an arbitrary string (e.g. the revision).
+type UpgradeTracker struct {
+	mux   sync.Mutex
+	conns map[string]int
+}
+
+// NewUpgradeTracker creates a new UpgradeTracker.
+func NewUpgradeTracker() *UpgradeTracker {
+	return &UpgradeTracker{
+		conns: make(map[string]int),
+	}
+}
+
+// Admit checks the upgrade to protocol against the policy and registers
+// the connection for key. The returned function must be called once the
+// connection is closed.
+func (t *UpgradeTracker) Admit(key string, policy UpgradePolicy, protocol string) (func(), error) {
+	if policy.AllowedProtocols != nil && !policy.AllowedProtocols.Has(protocol) {
+		return nil, ErrUpgradeNotAllowed
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if policy.MaxConnections > 0 && t.conns[key] >= policy.MaxConnections {
+		return nil, ErrTooManyUpgrades
+	}
+	t.conns[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mux.Lock()
+			defer t.mux.Unlock()
+			if t.conns[key]--; t.conns[key] <= 0 {
+				delete(t.conns, key)
+			}
+		})
+	}, nil
+}
+
+// Count returns the number of upgraded connections open for key.
+func (t *UpgradeTracker) Count(key string) int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.conns[key]
+}
+
+// UpgradeErrorStatus maps the errors returned by Admit to a HTTP status.
+func UpgradeErrorStatus(err error) int {
+	if err == ErrTooManyUpgrades {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusForbidden
+}
+
+// NewUpgradeHandler enforces a fixed UpgradePolicy on all upgrade
+// requests passing through it.
+func NewUpgradeHandler(h http.Handler, policy UpgradePolicy) http.Handler {
+	tracker := NewUpgradeTracker()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proto := UpgradeProtocol(r); proto != "" {
+			release, err := tracker.Admit("", policy, proto)
+			if err != nil {
+				http.Error(w, err.Error(), UpgradeErrorStatus(err))
+				return
+			}
+			defer release()
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/apis/serving"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestParseUpgradePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols string
+		max       string
+		want      UpgradePolicy
+		wantErr   bool
+	}{{
+		name: "empty",
+		want: UpgradePolicy{},
+	}, {
+		name:      "protocols and limit",
+		protocols: "WebSocket, h2c,",
+		max:       "5",
+		want: UpgradePolicy{
+			AllowedProtocols: sets.NewString("websocket", "h2c"),
+			MaxConnections:   5,
+		},
+	}, {
+		name:    "negative limit",
+		max:     "-1",
+		wantErr: true,
+	}, {
+		name:    "garbage limit",
+		max:     "many",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseUpgradePolicy(test.protocols, test.max)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseUpgradePolicy() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParseUpgradePolicy() (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestUpgradePolicyFromAnnotations(t *testing.T) {
+	got := UpgradePolicyFromAnnotations(map[string]string{
+		serving.AllowedUpgradeProtocolsAnnotationKey: "websocket",
+		serving.MaxUpgradedConnectionsAnnotationKey:  "nope",
+	})
+	want := UpgradePolicy{AllowedProtocols: sets.NewString("websocket")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UpgradePolicyFromAnnotations() (-want +got): %s", diff)
+	}
+}
+
+func TestUpgradeProtocol(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{{
+		name: "no upgrade",
+		header: http.Header{
+			"Upgrade": []string{"websocket"},
+		},
+	}, {
+		name: "websocket",
+		header: http.Header{
+			"Connection": []string{"keep-alive, Upgrade"},
+			"Upgrade":    []string{"WebSocket"},
+		},
+		want: "websocket",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header = test.header
+			if got := UpgradeProtocol(r); got != test.want {
+				t.Errorf("UpgradeProtocol() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeTracker(t *testing.T) {
+	tracker := NewUpgradeTracker()
+	policy := UpgradePolicy{
+		AllowedProtocols: sets.NewString("websocket"),
+		MaxConnections:   1,
+	}
+
+	if _, err := tracker.Admit("rev", policy, "h2c"); err != ErrUpgradeNotAllowed {
+		t.Errorf("Admit() = %v, want: %v", err, ErrUpgradeNotAllowed)
+	}
+
+	release, err := tracker.Admit("rev", policy, "websocket")
+	if err != nil {
+		t.Fatalf("Admit() = %v", err)
+	}
+	if _, err := tracker.Admit("rev", policy, "websocket"); err != ErrTooManyUpgrades {
+		t.Errorf("Admit() = %v, want: %v", err, ErrTooManyUpgrades)
+	}
+	// Other keys are not affected.
+	if _, err := tracker.Admit("other", policy, "websocket"); err != nil {
+		t.Errorf("Admit() = %v", err)
+	}
+
+	release()
+	release() // Releasing twice is a no-op.
+	if got, want := tracker.Count("rev"), 0; got != want {
+		t.Errorf("Count() = %d, want: %d", got, want)
+	}
+	if _, err := tracker.Admit("rev", policy, "websocket"); err != nil {
+		t.Errorf("Admit() = %v", err)
+	}
+}
+
+func TestUpgradeHandler(t *testing.T) {
+	h := NewUpgradeHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		UpgradePolicy{AllowedProtocols: sets.NewString("websocket")})
+
+	tests := []struct {
+		name    string
+		upgrade string
+		want    int
+	}{{
+		name: "plain request",
+		want: http.StatusOK,
+	}, {
+		name:    "allowed",
+		upgrade: "websocket",
+		want:    http.StatusOK,
+	}, {
+		name:    "not allowed",
+		upgrade: "h2c",
+		want:    http.StatusForbidden,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.upgrade != "" {
+				r.Header.Set("Connection", "Upgrade")
+				r.Header.Set("Upgrade", test.upgrade)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != test.want {
+				t.Errorf("Code = %d, want: %d", rec.Code, test.want)
+			}
+		})
+	}
+}
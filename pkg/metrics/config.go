@@ -17,6 +17,7 @@ limitations under the License.
 package metrics
 
 import (
+	"fmt"
 	"strings"
 	"text/template"
 
@@ -59,6 +60,19 @@ type ObservabilityConfig struct {
 	// RequestMetricsBackend specifies the request metrics destination, e.g. Prometheus,
 	// Stackdriver.
 	RequestMetricsBackend string
+
+	// RequestMetricsOTLPEndpoint is the OpenTelemetry collector the request
+	// metrics are exported to when RequestMetricsBackend is otlp.
+	RequestMetricsOTLPEndpoint string
+
+	// RequestMetricsOTLPProtocol is the protocol the request metrics are
+	// exported with when RequestMetricsBackend is otlp, grpc or http.
+	RequestMetricsOTLPProtocol string
+
+	// EnableRequestCPUAccounting specifies whether the queue proxy attributes
+	// the CPU time of the user container to the requests it serves, when it
+	// serves them one at a time.
+	EnableRequestCPUAccounting bool
 }
 
 // NewObservabilityConfigFromConfigMap creates a ObservabilityConfig from the supplied ConfigMap
@@ -86,5 +100,24 @@ func NewObservabilityConfigFromConfigMap(configMap *corev1.ConfigMap) (*Observab
 		oc.RequestMetricsBackend = mb
 	}
 
+	if oe, ok := configMap.Data["metrics.otlp-endpoint"]; ok {
+		oc.RequestMetricsOTLPEndpoint = oe
+	}
+
+	if op, ok := configMap.Data["metrics.otlp-protocol"]; ok {
+		if op != "grpc" && op != "http" {
+			return nil, fmt.Errorf("unsupported metrics.otlp-protocol %q, must be grpc or http", op)
+		}
+		oc.RequestMetricsOTLPProtocol = op
+	}
+
+	if oc.RequestMetricsBackend == "otlp" && oc.RequestMetricsOTLPEndpoint == "" {
+		return nil, fmt.Errorf("request metrics backend is otlp but no metrics.otlp-endpoint specified")
+	}
+
+	if rca, ok := configMap.Data["metrics.request-cpu-accounting"]; ok {
+		oc.EnableRequestCPUAccounting = strings.ToLower(rca) == "true"
+	}
+
 	return oc, nil
 }
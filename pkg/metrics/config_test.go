@@ -51,10 +51,11 @@ func TestObservabilityConfiguration(t *testing.T) {
 		name:    "observability configuration with all inputs",
 		wantErr: false,
 		wantController: &ObservabilityConfig{
-			LoggingURLTemplate:     "https://logging.io",
-			EnableVarLogCollection: true,
-			RequestLogTemplate:     `{"requestMethod": "{{.Request.Method}}"}`,
-			RequestMetricsBackend:  "stackdriver",
+			LoggingURLTemplate:         "https://logging.io",
+			EnableVarLogCollection:     true,
+			RequestLogTemplate:         `{"requestMethod": "{{.Request.Method}}"}`,
+			RequestMetricsBackend:      "stackdriver",
+			EnableRequestCPUAccounting: true,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -67,6 +68,7 @@ func TestObservabilityConfiguration(t *testing.T) {
 				"logging.write-request-logs":                  "true",
 				"logging.request-log-template":                `{"requestMethod": "{{.Request.Method}}"}`,
 				"metrics.request-metrics-backend-destination": "stackdriver",
+				"metrics.request-cpu-accounting":              "true",
 			},
 		},
 	}, {
@@ -84,6 +86,52 @@ func TestObservabilityConfiguration(t *testing.T) {
 				Name:      metrics.ConfigMapName(),
 			},
 		},
+	}, {
+		name:    "observability config with otlp request metrics",
+		wantErr: false,
+		wantController: &ObservabilityConfig{
+			LoggingURLTemplate:         defaultLogURLTemplate,
+			RequestMetricsBackend:      "otlp",
+			RequestMetricsOTLPEndpoint: "otel-collector.observability:4317",
+			RequestMetricsOTLPProtocol: "grpc",
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      metrics.ConfigMapName(),
+			},
+			Data: map[string]string{
+				"metrics.request-metrics-backend-destination": "otlp",
+				"metrics.otlp-endpoint":                       "otel-collector.observability:4317",
+				"metrics.otlp-protocol":                       "grpc",
+			},
+		},
+	}, {
+		name:           "otlp request metrics without endpoint",
+		wantErr:        true,
+		wantController: (*ObservabilityConfig)(nil),
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      metrics.ConfigMapName(),
+			},
+			Data: map[string]string{
+				"metrics.request-metrics-backend-destination": "otlp",
+			},
+		},
+	}, {
+		name:           "invalid otlp protocol",
+		wantErr:        true,
+		wantController: (*ObservabilityConfig)(nil),
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      metrics.ConfigMapName(),
+			},
+			Data: map[string]string{
+				"metrics.otlp-protocol": "thrift",
+			},
+		},
 	}, {
 		name:           "invalid request log template",
 		wantErr:        true,
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net"
+)
+
+// IPFamily is the family of an IP address.
+type IPFamily string
+
+const (
+	// IPv4 is the family of IPv4 addresses.
+	IPv4 IPFamily = "IPv4"
+
+	// IPv6 is the family of IPv6 addresses.
+	IPv6 IPFamily = "IPv6"
+)
+
+// FamilyOf returns the family of the given IP. Addresses that can't be
+// determined, e.g. a nil IP, are assumed to be IPv4.
+func FamilyOf(ip net.IP) IPFamily {
+	if ip != nil && ip.To4() == nil {
+		return IPv6
+	}
+	return IPv4
+}
+
+// Loopback returns the loopback address of the given IP family.
+func Loopback(family IPFamily) string {
+	if family == IPv6 {
+		return net.IPv6loopback.String()
+	}
+	return "127.0.0.1"
+}
+
+// PreferredIP returns the first of the given IPs that belongs to the
+// preferred family. If there is none, the first valid IP of any family is
+// returned, so single-stack clusters keep working regardless of the
+// preference. Returns an empty string if none of the IPs are valid.
+func PreferredIP(ips []string, family IPFamily) string {
+	fallback := ""
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		if FamilyOf(ip) == family {
+			return s
+		}
+		if fallback == "" {
+			fallback = s
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFamilyOf(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want IPFamily
+	}{{
+		ip:   "10.0.0.1",
+		want: IPv4,
+	}, {
+		ip:   "::ffff:10.0.0.1",
+		want: IPv4,
+	}, {
+		ip:   "fd00::1",
+		want: IPv6,
+	}, {
+		ip:   "not an ip",
+		want: IPv4,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if got := FamilyOf(net.ParseIP(test.ip)); got != test.want {
+				t.Errorf("FamilyOf(%q) = %v, want %v", test.ip, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	if got, want := Loopback(IPv4), "127.0.0.1"; got != want {
+		t.Errorf("Loopback(IPv4) = %q, want %q", got, want)
+	}
+	if got, want := Loopback(IPv6), "::1"; got != want {
+		t.Errorf("Loopback(IPv6) = %q, want %q", got, want)
+	}
+}
+
+func TestPreferredIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		ips    []string
+		family IPFamily
+		want   string
+	}{{
+		name:   "no ips",
+		family: IPv4,
+	}, {
+		name:   "preferred family first",
+		ips:    []string{"10.0.0.1", "fd00::1"},
+		family: IPv4,
+		want:   "10.0.0.1",
+	}, {
+		name:   "preferred family last",
+		ips:    []string{"10.0.0.1", "fd00::1"},
+		family: IPv6,
+		want:   "fd00::1",
+	}, {
+		name:   "single stack fallback",
+		ips:    []string{"fd00::1", "fd00::2"},
+		family: IPv4,
+		want:   "fd00::1",
+	}, {
+		name:   "invalid ips are skipped",
+		ips:    []string{"", "bogus", "10.0.0.1"},
+		family: IPv6,
+		want:   "10.0.0.1",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PreferredIP(test.ips, test.family); got != test.want {
+				t.Errorf("PreferredIP(%v, %v) = %q, want %q", test.ips, test.family, got, test.want)
+			}
+		})
+	}
+}
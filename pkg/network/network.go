@@ -42,12 +42,69 @@ const (
 	// uses to mark requests going through it.
 	ProxyHeaderName = "K-Proxy-Request"
 
+	// DefaultCacheControlHeaderName and DefaultSurrogateControlHeaderName
+	// are the names of the internal headers the ingress adds to requests
+	// for the queue-proxy to set the Cache-Control and Surrogate-Control
+	// headers of the responses the application didn't set them on.
+	DefaultCacheControlHeaderName     = "K-Default-Cache-Control"
+	DefaultSurrogateControlHeaderName = "K-Default-Surrogate-Control"
+
+	// ForwardedClientCertHeaderName is the header in which the ingress
+	// forwards the details of the client certificate of an mTLS connection
+	// it terminated.
+	ForwardedClientCertHeaderName = "X-Forwarded-Client-Cert"
+
+	// ClientCertSubjectHeaderName is the header that carries the subject
+	// of the verified client certificate to the user container.
+	ClientCertSubjectHeaderName = "K-Client-Cert-Subject"
+
+	// ClientCertSANHeaderName is the header that carries the comma
+	// separated URI and DNS subject alternative names of the verified
+	// client certificate to the user container.
+	ClientCertSANHeaderName = "K-Client-Cert-San"
+
+	// ClientCertVerifiedHeaderName is the header that carries the result
+	// of the verification of the client certificate to the user container.
+	// It is ClientCertVerifiedSuccess when the client presented a valid
+	// certificate and absent otherwise.
+	ClientCertVerifiedHeaderName = "K-Client-Cert-Verified"
+
+	// ClientCertVerifiedSuccess is the value of ClientCertVerifiedHeaderName
+	// for verified client certificates.
+	ClientCertVerifiedSuccess = "SUCCESS"
+
+	// AuthenticatedIdentityHeaderName is the header that carries the
+	// identity of the client the queue-proxy authenticated to the user
+	// container, the subject of its bearer token or the SAN of its client
+	// certificate.
+	AuthenticatedIdentityHeaderName = "K-Authenticated-Identity"
+
 	// OriginalHostHeader is used to avoid Istio host based routing rules
 	// in Activator.
 	// The header contains the original Host value that can be rewritten
 	// at the Queue proxy level back to be a host header.
 	OriginalHostHeader = "K-Original-Host"
 
+	// OverloadedByHeaderName is the header set on the 503 responses of
+	// requests shed because of overload. Its value is the name of the
+	// data plane component that rejected the request.
+	OverloadedByHeaderName = "K-Overloaded-By"
+
+	// QueueWaitTimeHeaderName is the response header in which the
+	// queue-proxy tells how long the request waited for capacity, as a Go
+	// duration, e.g. "1.5s", to help debug cold starts and saturation.
+	QueueWaitTimeHeaderName = "X-Queue-Wait-Time"
+
+	// RequestTimeoutHeaderName is the header with which a client hints
+	// how long it waits for the response, as a Go duration, e.g. "1.5s".
+	// Each hop rewrites it to the time left when forwarding the request.
+	RequestTimeoutHeaderName = "X-Request-Timeout"
+
+	// GRPCTimeoutHeaderName is the header carrying the deadline of a gRPC
+	// call, e.g. "100m" for 100 milliseconds. It is rewritten by each hop
+	// like RequestTimeoutHeaderName.
+	GRPCTimeoutHeaderName = "Grpc-Timeout"
+
 	// ConfigName is the name of the configmap containing all
 	// customizations for networking features.
 	ConfigName = "config-network"
@@ -112,6 +169,62 @@ var (
 	// HTTPProtocolKey is the name of the configuration entry that
 	// specifies the HTTP endpoint behavior of Knative ingress.
 	HTTPProtocolKey = "httpProtocol"
+
+	// PreferredIPFamilyKey is the name of the configuration entry that
+	// specifies which IP family to use when addresses of both families
+	// are available, e.g. on dual-stack clusters.
+	PreferredIPFamilyKey = "preferredIPFamily"
+
+	// ActivatorColdStartTimeoutKey is the name of the configuration entry
+	// that specifies how long the activator buffers a request for a
+	// revision that is scaling from zero.
+	ActivatorColdStartTimeoutKey = "activatorColdStartTimeout"
+
+	// ActivatorTimeoutKey is the name of the configuration entry that
+	// specifies how long the activator buffers a request for a revision
+	// that already has ready pods.
+	ActivatorTimeoutKey = "activatorTimeout"
+
+	// ActivatorMethodTimeoutsKey is the name of the configuration entry
+	// that overrides ActivatorTimeoutKey for specific HTTP methods, as a
+	// comma separated list of method=duration pairs, e.g. "GET=10s".
+	ActivatorMethodTimeoutsKey = "activatorMethodTimeouts"
+
+	// ActivatorShedCapacityPendingKey is the name of the configuration entry
+	// that specifies whether the activator fails the requests to a revision
+	// scaling from zero right away while its pods can't be scheduled,
+	// instead of buffering them until they time out.
+	ActivatorShedCapacityPendingKey = "activatorShedCapacityPending"
+
+	// ClientCertTrustedProxiesKey is the config for the comma separated
+	// list of CIDRs of the peers whose X-Forwarded-Client-Cert header is
+	// honored by the activator and the queue-proxy.
+	ClientCertTrustedProxiesKey = "clientCertTrustedProxies"
+
+	// DefaultActivatorTimeout is the default for both the cold start and
+	// the steady state timeout of the activator.
+	DefaultActivatorTimeout = 2 * time.Minute
+
+	// ExternalTLSKeyPrefix prefixes the configuration entries of the TLS
+	// policy of external endpoints, e.g. "externalTLS.minProtocolVersion".
+	ExternalTLSKeyPrefix = "externalTLS."
+
+	// ClusterLocalTLSKeyPrefix prefixes the configuration entries of the
+	// TLS policy of cluster-local endpoints.
+	ClusterLocalTLSKeyPrefix = "clusterLocalTLS."
+
+	// TLSMinProtocolVersionKey is the suffix of the entry that specifies
+	// the lowest accepted TLS version, one of TLSV1_0, TLSV1_1, TLSV1_2
+	// and TLSV1_3.
+	TLSMinProtocolVersionKey = "minProtocolVersion"
+
+	// TLSCipherSuitesKey is the suffix of the entry that specifies the
+	// comma separated list of accepted cipher suites.
+	TLSCipherSuitesKey = "cipherSuites"
+
+	// TLSALPNProtocolsKey is the suffix of the entry that specifies the
+	// comma separated list of protocols offered through ALPN.
+	TLSALPNProtocolsKey = "alpnProtocols"
 )
 
 // DomainTemplateValues are the available properties people can choose from
@@ -156,6 +269,150 @@ type Config struct {
 	// HTTPProtocol specifics the behavior of HTTP endpoint of Knative
 	// ingress.
 	HTTPProtocol HTTPProtocol
+
+	// PreferredIPFamily specifies which IP family to use when addresses
+	// of both families are available.
+	PreferredIPFamily IPFamily
+
+	// ActivatorTimeouts specifies how long the activator buffers requests
+	// before failing them.
+	ActivatorTimeouts ActivatorTimeouts
+
+	// ActivatorShedCapacityPending specifies whether the activator sheds
+	// the requests that would wait for a revision whose pods can't be
+	// scheduled.
+	ActivatorShedCapacityPending bool
+
+	// ClientCertTrustedProxies is the comma separated list of CIDRs of
+	// the peers trusted to forward the client certificate. The header is
+	// dropped when sent by any other peer.
+	ClientCertTrustedProxies string
+
+	// ExternalTLSPolicy is the TLS policy of external endpoints.
+	ExternalTLSPolicy TLSPolicy
+
+	// ClusterLocalTLSPolicy is the TLS policy of cluster-local endpoints.
+	ClusterLocalTLSPolicy TLSPolicy
+}
+
+// TLSPolicy restricts the TLS handshakes accepted by Knative ingress.
+// Empty fields leave the choice to the ingress implementation.
+type TLSPolicy struct {
+	MinProtocolVersion string
+	CipherSuites       []string
+	ALPNProtocols      []string
+}
+
+// IsZero returns true if the policy doesn't restrict anything.
+func (p TLSPolicy) IsZero() bool {
+	return p.MinProtocolVersion == "" && len(p.CipherSuites) == 0 && len(p.ALPNProtocols) == 0
+}
+
+// Override returns p with the fields that are set in o replaced.
+func (p TLSPolicy) Override(o TLSPolicy) TLSPolicy {
+	if o.MinProtocolVersion != "" {
+		p.MinProtocolVersion = o.MinProtocolVersion
+	}
+	if len(o.CipherSuites) > 0 {
+		p.CipherSuites = o.CipherSuites
+	}
+	if len(o.ALPNProtocols) > 0 {
+		p.ALPNProtocols = o.ALPNProtocols
+	}
+	return p
+}
+
+var tlsProtocolVersions = map[string]bool{
+	"TLSV1_0": true,
+	"TLSV1_1": true,
+	"TLSV1_2": true,
+	"TLSV1_3": true,
+}
+
+// ParseTLSPolicy reads a TLSPolicy from the entries of data whose keys
+// are prefix followed by TLSMinProtocolVersionKey, TLSCipherSuitesKey
+// and TLSALPNProtocolsKey.
+func ParseTLSPolicy(data map[string]string, prefix string) (TLSPolicy, error) {
+	p := TLSPolicy{
+		MinProtocolVersion: strings.ToUpper(strings.TrimSpace(data[prefix+TLSMinProtocolVersionKey])),
+		CipherSuites:       splitList(data[prefix+TLSCipherSuitesKey]),
+		ALPNProtocols:      splitList(data[prefix+TLSALPNProtocolsKey]),
+	}
+	if p.MinProtocolVersion != "" && !tlsProtocolVersions[p.MinProtocolVersion] {
+		return TLSPolicy{}, fmt.Errorf("%s%s %s is not a supported TLS version",
+			prefix, TLSMinProtocolVersionKey, data[prefix+TLSMinProtocolVersionKey])
+	}
+	return p, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// ActivatorTimeouts are how long the activator waits for capacity, and for
+// the revision to become reachable, before failing a request. Waiting for a
+// revision to scale from zero usually takes much longer than waiting for a
+// slot on ready pods, so the two are configured separately.
+type ActivatorTimeouts struct {
+	// ColdStart applies to requests for revisions that are scaling from zero.
+	ColdStart time.Duration
+	// SteadyState applies to requests for revisions that have ready pods.
+	SteadyState time.Duration
+	// Methods overrides SteadyState for the HTTP methods it contains.
+	Methods map[string]time.Duration
+}
+
+// For returns the timeout of a request with the given HTTP method.
+// coldStart tells whether its revision is scaling from zero.
+func (t ActivatorTimeouts) For(method string, coldStart bool) time.Duration {
+	if coldStart {
+		return t.ColdStart
+	}
+	if d, ok := t.Methods[method]; ok {
+		return d
+	}
+	return t.SteadyState
+}
+
+func parseMethodTimeouts(s string) (map[string]time.Duration, error) {
+	var timeouts map[string]time.Duration
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s entry %q must be of the form method=duration", ActivatorMethodTimeoutsKey, pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s entry %q must have a positive duration", ActivatorMethodTimeoutsKey, pair)
+		}
+		if timeouts == nil {
+			timeouts = make(map[string]time.Duration)
+		}
+		timeouts[strings.ToUpper(strings.TrimSpace(parts[0]))] = d
+	}
+	return timeouts, nil
+}
+
+func parsePositiveDuration(data map[string]string, key string) (time.Duration, error) {
+	raw, ok := data[key]
+	if !ok {
+		return DefaultActivatorTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s %s in config-network ConfigMap must be a positive duration", key, raw)
+	}
+	return d, nil
 }
 
 // HTTPProtocol indicates a type of HTTP endpoint behavior
@@ -173,6 +430,42 @@ const (
 	HTTPRedirected HTTPProtocol = "redirected"
 )
 
+// ParseCIDRs parses a comma separated list of CIDRs.
+func ParseCIDRs(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// IsPeerTrusted returns true if the remote address of the request, i.e. the
+// immediate peer rather than any forwarded address, is in one of nets.
+func IsPeerTrusted(r *http.Request, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func validateAndNormalizeOutboundIPRanges(s string) (string, error) {
 	s = strings.TrimSpace(s)
 
@@ -247,6 +540,7 @@ func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
 	}
 
 	nc.AutoTLS = strings.ToLower(configMap.Data[AutoTLSKey]) == "enabled"
+	nc.ActivatorShedCapacityPending = strings.ToLower(configMap.Data[ActivatorShedCapacityPendingKey]) == "enabled"
 
 	switch strings.ToLower(configMap.Data[HTTPProtocolKey]) {
 	case string(HTTPEnabled):
@@ -262,6 +556,36 @@ func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
 	default:
 		return nil, fmt.Errorf("httpProtocol %s in config-network ConfigMap is not supported", configMap.Data[HTTPProtocolKey])
 	}
+
+	switch strings.ToLower(configMap.Data[PreferredIPFamilyKey]) {
+	case "", strings.ToLower(string(IPv4)):
+		nc.PreferredIPFamily = IPv4
+	case strings.ToLower(string(IPv6)):
+		nc.PreferredIPFamily = IPv6
+	default:
+		return nil, fmt.Errorf("preferredIPFamily %s in config-network ConfigMap is not supported", configMap.Data[PreferredIPFamilyKey])
+	}
+
+	var err error
+	if nc.ActivatorTimeouts.ColdStart, err = parsePositiveDuration(configMap.Data, ActivatorColdStartTimeoutKey); err != nil {
+		return nil, err
+	}
+	if nc.ActivatorTimeouts.SteadyState, err = parsePositiveDuration(configMap.Data, ActivatorTimeoutKey); err != nil {
+		return nil, err
+	}
+	if nc.ActivatorTimeouts.Methods, err = parseMethodTimeouts(configMap.Data[ActivatorMethodTimeoutsKey]); err != nil {
+		return nil, err
+	}
+	if _, err := ParseCIDRs(configMap.Data[ClientCertTrustedProxiesKey]); err != nil {
+		return nil, fmt.Errorf("%s in config-network ConfigMap is invalid: %v", ClientCertTrustedProxiesKey, err)
+	}
+	nc.ClientCertTrustedProxies = strings.TrimSpace(configMap.Data[ClientCertTrustedProxiesKey])
+	if nc.ExternalTLSPolicy, err = ParseTLSPolicy(configMap.Data, ExternalTLSKeyPrefix); err != nil {
+		return nil, err
+	}
+	if nc.ClusterLocalTLSPolicy, err = ParseTLSPolicy(configMap.Data, ClusterLocalTLSKeyPrefix); err != nil {
+		return nil, err
+	}
 	return nc, nil
 }
 
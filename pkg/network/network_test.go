@@ -22,6 +22,7 @@ import (
 	"net/http/httptest"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -46,6 +47,10 @@ func TestOurConfig(t *testing.T) {
 
 func TestConfiguration(t *testing.T) {
 	nonDefaultDomainTemplate := "{{.Namespace}}.{{.Name}}.{{.Domain}}"
+	defaultActivatorTimeouts := ActivatorTimeouts{
+		ColdStart:   DefaultActivatorTimeout,
+		SteadyState: DefaultActivatorTimeout,
+	}
 
 	networkConfigTests := []struct {
 		name       string
@@ -61,6 +66,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -88,6 +95,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -166,6 +175,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -184,6 +195,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -202,6 +215,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -221,6 +236,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -240,6 +257,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -259,6 +278,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -278,6 +299,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             DefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -298,6 +321,8 @@ func TestConfiguration(t *testing.T) {
 			DomainTemplate:             nonDefaultDomainTemplate,
 			TagTemplate:                DefaultTagTemplate,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -394,6 +419,8 @@ func TestConfiguration(t *testing.T) {
 			TagTemplate:                DefaultTagTemplate,
 			AutoTLS:                    true,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -415,6 +442,8 @@ func TestConfiguration(t *testing.T) {
 			TagTemplate:                DefaultTagTemplate,
 			AutoTLS:                    false,
 			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -436,6 +465,8 @@ func TestConfiguration(t *testing.T) {
 			TagTemplate:                DefaultTagTemplate,
 			AutoTLS:                    true,
 			HTTPProtocol:               HTTPDisabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -448,6 +479,163 @@ func TestConfiguration(t *testing.T) {
 				HTTPProtocolKey:          "Disabled",
 			},
 		},
+	}, {
+		name:    "network configuration with IPv6 preferred",
+		wantErr: false,
+		wantConfig: &Config{
+			IstioOutboundIPRanges:      "*",
+			DefaultClusterIngressClass: "istio.ingress.networking.knative.dev",
+			DomainTemplate:             DefaultDomainTemplate,
+			TagTemplate:                DefaultTagTemplate,
+			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv6,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				PreferredIPFamilyKey: "ipv6",
+			},
+		},
+	}, {
+		name:    "network configuration with unsupported IP family",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				PreferredIPFamilyKey: "IPv5",
+			},
+		},
+	}, {
+		name:    "network configuration with activator timeouts",
+		wantErr: false,
+		wantConfig: &Config{
+			IstioOutboundIPRanges:      "*",
+			DefaultClusterIngressClass: "istio.ingress.networking.knative.dev",
+			DomainTemplate:             DefaultDomainTemplate,
+			TagTemplate:                DefaultTagTemplate,
+			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts: ActivatorTimeouts{
+				ColdStart:   5 * time.Minute,
+				SteadyState: 10 * time.Second,
+				Methods: map[string]time.Duration{
+					http.MethodGet:  5 * time.Second,
+					http.MethodPost: time.Minute,
+				},
+			},
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ActivatorColdStartTimeoutKey: "5m",
+				ActivatorTimeoutKey:          "10s",
+				ActivatorMethodTimeoutsKey:   "get=5s, POST=1m,",
+			},
+		},
+	}, {
+		name:    "network configuration with activator shedding while capacity is pending",
+		wantErr: false,
+		wantConfig: &Config{
+			IstioOutboundIPRanges:        "*",
+			DefaultClusterIngressClass:   "istio.ingress.networking.knative.dev",
+			DomainTemplate:               DefaultDomainTemplate,
+			TagTemplate:                  DefaultTagTemplate,
+			HTTPProtocol:                 HTTPEnabled,
+			PreferredIPFamily:            IPv4,
+			ActivatorTimeouts:            defaultActivatorTimeouts,
+			ActivatorShedCapacityPending: true,
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				IstioOutboundIPRangesKey:        "*",
+				ActivatorShedCapacityPendingKey: "Enabled",
+			},
+		},
+	}, {
+		name:    "network configuration with client cert trusted proxies",
+		wantErr: false,
+		wantConfig: &Config{
+			IstioOutboundIPRanges:      "*",
+			DefaultClusterIngressClass: "istio.ingress.networking.knative.dev",
+			DomainTemplate:             DefaultDomainTemplate,
+			TagTemplate:                DefaultTagTemplate,
+			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
+			ClientCertTrustedProxies:   "10.4.0.0/16, 127.0.0.1/32",
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				IstioOutboundIPRangesKey:    "*",
+				ClientCertTrustedProxiesKey: " 10.4.0.0/16, 127.0.0.1/32",
+			},
+		},
+	}, {
+		name:    "network configuration with invalid client cert trusted proxies",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ClientCertTrustedProxiesKey: "10.4.0.1",
+			},
+		},
+	}, {
+		name:    "network configuration with invalid activator cold start timeout",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ActivatorColdStartTimeoutKey: "0s",
+			},
+		},
+	}, {
+		name:    "network configuration with invalid activator timeout",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ActivatorTimeoutKey: "forever",
+			},
+		},
+	}, {
+		name:    "network configuration with malformed activator method timeouts",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ActivatorMethodTimeoutsKey: "GET:5s",
+			},
+		},
 	}, {
 		name:    "network configuration with HTTPProtocol redirected",
 		wantErr: false,
@@ -458,6 +646,8 @@ func TestConfiguration(t *testing.T) {
 			TagTemplate:                DefaultTagTemplate,
 			AutoTLS:                    true,
 			HTTPProtocol:               HTTPRedirected,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
 		},
 		config: &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -470,6 +660,51 @@ func TestConfiguration(t *testing.T) {
 				HTTPProtocolKey:          "Redirected",
 			},
 		},
+	}, {
+		name:    "network configuration with TLS policies",
+		wantErr: false,
+		wantConfig: &Config{
+			IstioOutboundIPRanges:      "*",
+			DefaultClusterIngressClass: "istio.ingress.networking.knative.dev",
+			DomainTemplate:             DefaultDomainTemplate,
+			TagTemplate:                DefaultTagTemplate,
+			HTTPProtocol:               HTTPEnabled,
+			PreferredIPFamily:          IPv4,
+			ActivatorTimeouts:          defaultActivatorTimeouts,
+			ExternalTLSPolicy: TLSPolicy{
+				MinProtocolVersion: "TLSV1_2",
+				CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256", "ECDHE-RSA-AES256-GCM-SHA384"},
+				ALPNProtocols:      []string{"h2", "http/1.1"},
+			},
+			ClusterLocalTLSPolicy: TLSPolicy{
+				MinProtocolVersion: "TLSV1_3",
+			},
+		},
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				IstioOutboundIPRangesKey:                            "*",
+				ExternalTLSKeyPrefix + TLSMinProtocolVersionKey:     "tlsv1_2",
+				ExternalTLSKeyPrefix + TLSCipherSuitesKey:           "ECDHE-RSA-AES128-GCM-SHA256, ECDHE-RSA-AES256-GCM-SHA384",
+				ExternalTLSKeyPrefix + TLSALPNProtocolsKey:          "h2,http/1.1",
+				ClusterLocalTLSKeyPrefix + TLSMinProtocolVersionKey: "TLSV1_3",
+			},
+		},
+	}, {
+		name:    "network configuration with unsupported TLS version",
+		wantErr: true,
+		config: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      ConfigName,
+			},
+			Data: map[string]string{
+				ExternalTLSKeyPrefix + TLSMinProtocolVersionKey: "SSLV3",
+			},
+		},
 	}}
 
 	for _, tt := range networkConfigTests {
@@ -603,6 +838,37 @@ func TestIsKubeletProbe(t *testing.T) {
 	}
 }
 
+func TestIsPeerTrusted(t *testing.T) {
+	nets, err := ParseCIDRs("10.4.0.0/16, 127.0.0.1/32,")
+	if err != nil {
+		t.Fatalf("ParseCIDRs() = %v", err)
+	}
+	tests := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{"10.4.1.2:43210", true},
+		{"127.0.0.1:8080", true},
+		{"10.5.1.2:43210", false},
+		{"127.0.0.2:8080", false},
+		{"10.4.1.2", true},
+		{"", false},
+		{"garbage", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if got := IsPeerTrusted(r, nets); got != test.want {
+			t.Errorf("IsPeerTrusted(%q) = %v, want %v", test.remoteAddr, got, test.want)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.4.1.2:43210"
+	if IsPeerTrusted(r, nil) {
+		t.Error("IsPeerTrusted() = true without trusted CIDRs")
+	}
+}
+
 func TestRewriteHost(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "http://love.is/not-hate", nil)
 	r.Header.Set("Host", "love.is")
@@ -650,3 +916,51 @@ func TestRewriteHost(t *testing.T) {
 		t.Errorf("r.Header[%s] = %q	, want: %q", OriginalHostHeader, got, want)
 	}
 }
+
+func TestActivatorTimeoutsFor(t *testing.T) {
+	timeouts := ActivatorTimeouts{
+		ColdStart:   time.Minute,
+		SteadyState: 10 * time.Second,
+		Methods: map[string]time.Duration{
+			http.MethodGet: time.Second,
+		},
+	}
+
+	tests := []struct {
+		method    string
+		coldStart bool
+		want      time.Duration
+	}{
+		{http.MethodGet, true, time.Minute},
+		{http.MethodPost, true, time.Minute},
+		{http.MethodGet, false, time.Second},
+		{http.MethodPost, false, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := timeouts.For(test.method, test.coldStart); got != test.want {
+			t.Errorf("For(%s, %v) = %v, want: %v", test.method, test.coldStart, got, test.want)
+		}
+	}
+}
+
+func TestTLSPolicyOverride(t *testing.T) {
+	base := TLSPolicy{
+		MinProtocolVersion: "TLSV1_2",
+		CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+	}
+	got := base.Override(TLSPolicy{
+		MinProtocolVersion: "TLSV1_3",
+		ALPNProtocols:      []string{"h2"},
+	})
+	want := TLSPolicy{
+		MinProtocolVersion: "TLSV1_3",
+		CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+		ALPNProtocols:      []string{"h2"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Override() = %v, want: %v", got, want)
+	}
+	if !(TLSPolicy{}).IsZero() || got.IsZero() {
+		t.Error("IsZero() only wants to be true for the empty policy")
+	}
+}
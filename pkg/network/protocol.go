@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	// ProtocolVersionHeaderName is the header in which the control plane
+	// and the queue-proxy announce the version of the data plane protocol
+	// they speak: requests carry the version of the sender, responses
+	// the version both sides agreed on.
+	ProtocolVersionHeaderName = "K-Protocol-Version"
+)
+
+// ProtocolVersion is a version of the data plane protocol between the
+// control plane and the queue-proxy, i.e. the stats the queue-proxy
+// reports, the probe headers and the drain signal. While a cluster is
+// upgraded, components of different versions talk to each other, and use
+// the lower of their versions.
+type ProtocolVersion int
+
+const (
+	// ProtocolV1 is the protocol of the components that don't announce a
+	// version.
+	ProtocolV1 ProtocolVersion = 1
+	// ProtocolV2 requires the queue-proxy to report the average bytes in
+	// flight and request duration, and to announce the version on probe,
+	// stats and drain responses.
+	ProtocolV2 ProtocolVersion = 2
+
+	// CurrentProtocolVersion is the version spoken by this build.
+	CurrentProtocolVersion = ProtocolV2
+)
+
+// String returns the value of the version in ProtocolVersionHeaderName.
+func (v ProtocolVersion) String() string {
+	return strconv.Itoa(int(v))
+}
+
+// ParseProtocolVersion returns the version announced in the header, or
+// ProtocolV1 if there is none or it's invalid.
+func ParseProtocolVersion(h http.Header) ProtocolVersion {
+	v, err := strconv.Atoi(h.Get(ProtocolVersionHeaderName))
+	if err != nil || v < int(ProtocolV1) {
+		return ProtocolV1
+	}
+	return ProtocolVersion(v)
+}
+
+// NegotiateProtocolVersion returns the version to speak to the peer that
+// sent the header, i.e. the lower of its and the current version.
+func NegotiateProtocolVersion(h http.Header) ProtocolVersion {
+	if v := ParseProtocolVersion(h); v < CurrentProtocolVersion {
+		return v
+	}
+	return CurrentProtocolVersion
+}
+
+// ProtocolVersionHandler announces the version negotiated with the
+// sender of each request in the response, before passing it to h.
+func ProtocolVersionHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ProtocolVersionHeaderName, NegotiateProtocolVersion(r.Header).String())
+		h.ServeHTTP(w, r)
+	})
+}
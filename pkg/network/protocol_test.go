@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantPeer  ProtocolVersion
+		wantAgree ProtocolVersion
+	}{{
+		name:      "no version",
+		wantPeer:  ProtocolV1,
+		wantAgree: ProtocolV1,
+	}, {
+		name:      "invalid version",
+		header:    "two",
+		wantPeer:  ProtocolV1,
+		wantAgree: ProtocolV1,
+	}, {
+		name:      "zero",
+		header:    "0",
+		wantPeer:  ProtocolV1,
+		wantAgree: ProtocolV1,
+	}, {
+		name:      "v1",
+		header:    "1",
+		wantPeer:  ProtocolV1,
+		wantAgree: ProtocolV1,
+	}, {
+		name:      "current",
+		header:    CurrentProtocolVersion.String(),
+		wantPeer:  CurrentProtocolVersion,
+		wantAgree: CurrentProtocolVersion,
+	}, {
+		name:      "newer",
+		header:    "42",
+		wantPeer:  42,
+		wantAgree: CurrentProtocolVersion,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := http.Header{}
+			if test.header != "" {
+				h.Set(ProtocolVersionHeaderName, test.header)
+			}
+			if got := ParseProtocolVersion(h); got != test.wantPeer {
+				t.Errorf("ParseProtocolVersion() = %v, want: %v", got, test.wantPeer)
+			}
+			if got := NegotiateProtocolVersion(h); got != test.wantAgree {
+				t.Errorf("NegotiateProtocolVersion() = %v, want: %v", got, test.wantAgree)
+			}
+		})
+	}
+}
+
+func TestProtocolVersionHandler(t *testing.T) {
+	called := false
+	h := ProtocolVersionHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set(ProtocolVersionHeaderName, "1")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if !called {
+		t.Error("The handler wasn't called")
+	}
+	if got, want := resp.Header().Get(ProtocolVersionHeaderName), "1"; got != want {
+		t.Errorf("%s = %q, want: %q", ProtocolVersionHeaderName, got, want)
+	}
+}
@@ -33,14 +33,39 @@ func (rt RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 	return rt(r)
 }
 
+// IdleConnectionsCloser is implemented by the transports that can close
+// the keep-alive connections they don't use, like http.Transport.
+type IdleConnectionsCloser interface {
+	CloseIdleConnections()
+}
+
+// autoTransport uses v2 for HTTP2 requests and v1 for all others.
+type autoTransport struct {
+	v1, v2 http.RoundTripper
+}
+
+var _ IdleConnectionsCloser = (*autoTransport)(nil)
+
 func newAutoTransport(v1 http.RoundTripper, v2 http.RoundTripper) http.RoundTripper {
-	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
-		t := v1
-		if r.ProtoMajor == 2 {
-			t = v2
+	return &autoTransport{v1: v1, v2: v2}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *autoTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt := t.v1
+	if r.ProtoMajor == 2 {
+		rt = t.v2
+	}
+	return rt.RoundTrip(r)
+}
+
+// CloseIdleConnections closes the idle connections of both transports.
+func (t *autoTransport) CloseIdleConnections() {
+	for _, rt := range []http.RoundTripper{t.v1, t.v2} {
+		if c, ok := rt.(IdleConnectionsCloser); ok {
+			c.CloseIdleConnections()
 		}
-		return t.RoundTrip(r)
-	})
+	}
 }
 
 const (
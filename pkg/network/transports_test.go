@@ -71,6 +71,35 @@ func TestHTTPRoundTripper(t *testing.T) {
 	}
 }
 
+type fakeIdleCloser struct {
+	RoundTripperFunc
+	closed bool
+}
+
+func (c *fakeIdleCloser) CloseIdleConnections() {
+	c.closed = true
+}
+
+func TestAutoTransportCloseIdleConnections(t *testing.T) {
+	v1 := &fakeIdleCloser{}
+	rt := newAutoTransport(v1, RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, nil
+	}))
+
+	c, ok := rt.(IdleConnectionsCloser)
+	if !ok {
+		t.Fatalf("%T doesn't close idle connections", rt)
+	}
+	// The transports that can't close idle connections are skipped.
+	c.CloseIdleConnections()
+	if !v1.closed {
+		t.Error("The idle connections of the HTTP1 transport weren't closed")
+	}
+	if _, ok := NewAutoTransport().(IdleConnectionsCloser); !ok {
+		t.Error("NewAutoTransport() doesn't close idle connections")
+	}
+}
+
 func TestDialWithBackoff(t *testing.T) {
 	// Nobody's listening on a random port. Usually.
 	c, err := dialWithBackOff(context.Background(), "tcp4", "127.0.0.1:41482")
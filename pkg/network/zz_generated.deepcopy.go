@@ -20,9 +20,39 @@ limitations under the License.
 
 package network
 
+import (
+	time "time"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActivatorTimeouts) DeepCopyInto(out *ActivatorTimeouts) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make(map[string]time.Duration, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActivatorTimeouts.
+func (in *ActivatorTimeouts) DeepCopy() *ActivatorTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(ActivatorTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
+	in.ActivatorTimeouts.DeepCopyInto(&out.ActivatorTimeouts)
+	in.ExternalTLSPolicy.DeepCopyInto(&out.ExternalTLSPolicy)
+	in.ClusterLocalTLSPolicy.DeepCopyInto(&out.ClusterLocalTLSPolicy)
 	return
 }
 
@@ -59,6 +89,32 @@ func (in *DomainTemplateValues) DeepCopy() *DomainTemplateValues {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSPolicy) DeepCopyInto(out *TLSPolicy) {
+	*out = *in
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ALPNProtocols != nil {
+		in, out := &in.ALPNProtocols, &out.ALPNProtocols
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSPolicy.
+func (in *TLSPolicy) DeepCopy() *TLSPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TagTemplateValues) DeepCopyInto(out *TagTemplateValues) {
 	*out = *in
@@ -0,0 +1,289 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"sort"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+)
+
+// scopeName is the instrumentation scope the spans and metrics are
+// reported under.
+const scopeName = "knative.dev/serving"
+
+// The values of the OTLP enums the exporter sets.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	statusCodeOK    = 1
+	statusCodeError = 2
+
+	temporalityCumulative = 2
+)
+
+// encodeTraces encodes an ExportTraceServiceRequest of the spans, all
+// reported for the given resource.
+func encodeTraces(resource map[string]string, spans []*trace.SpanData) []byte {
+	var e encoder
+	// ExportTraceServiceRequest.resource_spans
+	e.message(1, func(rs *encoder) {
+		rs.message(1, func(r *encoder) { encodeResource(r, resource) })
+		// ResourceSpans.scope_spans
+		rs.message(2, func(ss *encoder) {
+			ss.message(1, encodeScope)
+			for _, s := range spans {
+				s := s
+				ss.message(2, func(e *encoder) { encodeSpan(e, s) })
+			}
+		})
+	})
+	return e.buf
+}
+
+// encodeMetrics encodes an ExportMetricsServiceRequest of the views, all
+// reported for the given resource.
+func encodeMetrics(resource map[string]string, views []*view.Data) []byte {
+	var e encoder
+	// ExportMetricsServiceRequest.resource_metrics
+	e.message(1, func(rm *encoder) {
+		rm.message(1, func(r *encoder) { encodeResource(r, resource) })
+		// ResourceMetrics.scope_metrics
+		rm.message(2, func(sm *encoder) {
+			sm.message(1, encodeScope)
+			for _, vd := range views {
+				vd := vd
+				sm.message(2, func(e *encoder) { encodeMetric(e, vd) })
+			}
+		})
+	})
+	return e.buf
+}
+
+func encodeResource(e *encoder, resource map[string]string) {
+	keys := make([]string, 0, len(resource))
+	for k := range resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		encodeAttribute(e, 1, k, resource[k])
+	}
+}
+
+func encodeScope(e *encoder) {
+	e.stringField(1, scopeName)
+}
+
+func encodeSpan(e *encoder, s *trace.SpanData) {
+	e.bytesField(1, s.TraceID[:])
+	e.bytesField(2, s.SpanID[:])
+	if s.ParentSpanID != (trace.SpanID{}) {
+		e.bytesField(4, s.ParentSpanID[:])
+	}
+	e.stringField(5, s.Name)
+	e.uint64Field(6, spanKind(s.SpanKind))
+	e.fixed64Field(7, unixNano(s.StartTime))
+	e.fixed64Field(8, unixNano(s.EndTime))
+	encodeAttributes(e, 9, s.Attributes)
+	for _, a := range s.Annotations {
+		a := a
+		// Span.events
+		e.message(11, func(ev *encoder) {
+			ev.fixed64Field(1, unixNano(a.Time))
+			ev.stringField(2, a.Message)
+			encodeAttributes(ev, 3, a.Attributes)
+		})
+	}
+	for _, l := range s.Links {
+		l := l
+		// Span.links
+		e.message(13, func(le *encoder) {
+			le.bytesField(1, l.TraceID[:])
+			le.bytesField(2, l.SpanID[:])
+			encodeAttributes(le, 4, l.Attributes)
+		})
+	}
+	// Span.status
+	e.message(15, func(st *encoder) {
+		st.stringField(2, s.Status.Message)
+		if s.Status.Code == trace.StatusCodeOK {
+			st.uint64Field(3, statusCodeOK)
+		} else {
+			st.uint64Field(3, statusCodeError)
+		}
+	})
+}
+
+func spanKind(kind int) uint64 {
+	switch kind {
+	case trace.SpanKindServer:
+		return spanKindServer
+	case trace.SpanKindClient:
+		return spanKindClient
+	default:
+		return spanKindInternal
+	}
+}
+
+func encodeMetric(e *encoder, vd *view.Data) {
+	v := vd.View
+	e.stringField(1, v.Name)
+	e.stringField(2, v.Description)
+	if v.Measure != nil {
+		e.stringField(3, v.Measure.Unit())
+	}
+
+	start, end := unixNano(vd.Start), unixNano(vd.End)
+	point := func(e *encoder, attrField int, r *view.Row) {
+		e.fixed64Field(2, start)
+		e.fixed64Field(3, end)
+		for _, t := range r.Tags {
+			encodeAttribute(e, attrField, t.Key.Name(), t.Value)
+		}
+	}
+
+	// The data of all the rows of a view has the same type, so the metric
+	// type is picked from the first one.
+	if len(vd.Rows) == 0 {
+		return
+	}
+	switch vd.Rows[0].Data.(type) {
+	case *view.CountData:
+		// Metric.sum
+		e.message(7, func(sum *encoder) {
+			for _, r := range vd.Rows {
+				r := r
+				d, ok := r.Data.(*view.CountData)
+				if !ok {
+					continue
+				}
+				sum.message(1, func(p *encoder) {
+					point(p, 7, r)
+					p.fixed64Always(6, uint64(d.Value))
+				})
+			}
+			sum.uint64Field(2, temporalityCumulative)
+			sum.boolAlways(3, true)
+		})
+	case *view.SumData:
+		// Metric.sum, not monotonic as measurements may be negative.
+		e.message(7, func(sum *encoder) {
+			for _, r := range vd.Rows {
+				r := r
+				d, ok := r.Data.(*view.SumData)
+				if !ok {
+					continue
+				}
+				sum.message(1, func(p *encoder) {
+					point(p, 7, r)
+					p.doubleAlways(4, d.Value)
+				})
+			}
+			sum.uint64Field(2, temporalityCumulative)
+		})
+	case *view.LastValueData:
+		// Metric.gauge
+		e.message(5, func(gauge *encoder) {
+			for _, r := range vd.Rows {
+				r := r
+				d, ok := r.Data.(*view.LastValueData)
+				if !ok {
+					continue
+				}
+				gauge.message(1, func(p *encoder) {
+					point(p, 7, r)
+					p.doubleAlways(4, d.Value)
+				})
+			}
+		})
+	case *view.DistributionData:
+		var bounds []float64
+		if v.Aggregation != nil {
+			bounds = v.Aggregation.Buckets
+		}
+		// Metric.histogram
+		e.message(9, func(hist *encoder) {
+			for _, r := range vd.Rows {
+				r := r
+				d, ok := r.Data.(*view.DistributionData)
+				if !ok {
+					continue
+				}
+				hist.message(1, func(p *encoder) {
+					point(p, 9, r)
+					p.fixed64Always(4, uint64(d.Count))
+					p.doubleAlways(5, d.Sum())
+					counts := make([]uint64, len(d.CountPerBucket))
+					for i, c := range d.CountPerBucket {
+						counts[i] = uint64(c)
+					}
+					p.packedFixed64(6, counts)
+					p.packedDouble(7, bounds)
+					if d.Count > 0 {
+						p.doubleAlways(11, d.Min)
+						p.doubleAlways(12, d.Max)
+					}
+				})
+			}
+			hist.uint64Field(2, temporalityCumulative)
+		})
+	}
+}
+
+// encodeAttributes encodes the OpenCensus attributes, whose values are
+// strings, bools, int64s or float64s, as the repeated KeyValue field.
+func encodeAttributes(e *encoder, field int, attrs map[string]interface{}) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		encodeAttribute(e, field, k, attrs[k])
+	}
+}
+
+func encodeAttribute(e *encoder, field int, key string, value interface{}) {
+	e.message(field, func(kv *encoder) {
+		kv.stringField(1, key)
+		// KeyValue.value, an AnyValue
+		kv.message(2, func(av *encoder) {
+			switch v := value.(type) {
+			case string:
+				av.stringAlways(1, v)
+			case bool:
+				av.boolAlways(2, v)
+			case int64:
+				av.uint64Always(3, uint64(v))
+			case float64:
+				av.doubleAlways(4, v)
+			}
+		})
+	})
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// ProtocolGRPC exports with OTLP over gRPC.
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP exports with OTLP over HTTP, with protobuf bodies.
+	ProtocolHTTP = "http"
+
+	// flushInterval is how often the buffered spans and views are exported.
+	flushInterval = 5 * time.Second
+	// maxBufferedSpans is the number of buffered spans that triggers an
+	// export before the next interval.
+	maxBufferedSpans = 512
+	// exportTimeout bounds the time an export request may take.
+	exportTimeout = 10 * time.Second
+)
+
+// Options configures an Exporter.
+type Options struct {
+	// Endpoint is the collector, a host:port for gRPC, a URL for HTTP.
+	Endpoint string
+	// Protocol is ProtocolGRPC or ProtocolHTTP, defaulting to gRPC.
+	Protocol string
+	// Resource are the attributes of the resource the telemetry is
+	// reported for, e.g. the namespace and revision of the queue-proxy.
+	Resource map[string]string
+	// Logger reports the failed exports, if set.
+	Logger *zap.SugaredLogger
+}
+
+// Exporter is both a trace.Exporter and a view.Exporter, which buffers the
+// spans and views it's given and exports them in the background.
+type Exporter struct {
+	resource  map[string]string
+	logger    *zap.SugaredLogger
+	transport transport
+
+	mu    sync.Mutex
+	spans []*trace.SpanData
+	// views holds the latest data of each view, which is cumulative.
+	views map[string]*view.Data
+
+	// exportMu serializes the exports of the background loop and Flush.
+	exportMu sync.Mutex
+	flushCh  chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+var (
+	_ trace.Exporter = (*Exporter)(nil)
+	_ view.Exporter  = (*Exporter)(nil)
+)
+
+// NewExporter creates an Exporter to the collector of the options, which
+// has to be stopped with Stop.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("an OTLP endpoint is required")
+	}
+	var (
+		t   transport
+		err error
+	)
+	switch opts.Protocol {
+	case ProtocolGRPC, "":
+		t, err = newGRPCTransport(opts.Endpoint)
+	case ProtocolHTTP:
+		t = newHTTPTransport(opts.Endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", opts.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newExporter(opts, t), nil
+}
+
+func newExporter(opts Options, t transport) *Exporter {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	e := &Exporter{
+		resource:  opts.Resource,
+		logger:    logger,
+		transport: t,
+		views:     make(map[string]*view.Data),
+		flushCh:   make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ExportSpan implements trace.Exporter.
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	full := len(e.spans) >= maxBufferedSpans
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ExportView implements view.Exporter.
+func (e *Exporter) ExportView(vd *view.Data) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.views[vd.View.Name] = vd
+}
+
+// Flush exports the buffered spans and views right away.
+func (e *Exporter) Flush() error {
+	e.exportMu.Lock()
+	defer e.exportMu.Unlock()
+
+	e.mu.Lock()
+	spans, views := e.spans, e.views
+	e.spans, e.views = nil, make(map[string]*view.Data)
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	var errs []error
+	if len(spans) > 0 {
+		if err := e.transport.export(ctx, signalTraces, encodeTraces(e.resource, spans)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export %d spans: %v", len(spans), err))
+		}
+	}
+	if len(views) > 0 {
+		vds := make([]*view.Data, 0, len(views))
+		for _, vd := range views {
+			vds = append(vds, vd)
+		}
+		sort.Slice(vds, func(i, j int) bool { return vds[i].View.Name < vds[j].View.Name })
+		if err := e.transport.export(ctx, signalMetrics, encodeMetrics(e.resource, vds)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export %d views: %v", len(vds), err))
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%v; %v", errs[0], errs[1])
+	}
+}
+
+// Stop stops the background exports, flushes what's left and closes the
+// connection to the collector.
+func (e *Exporter) Stop() error {
+	var err error
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+		<-e.doneCh
+		err = e.Flush()
+		if cerr := e.transport.close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+func (e *Exporter) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+		case <-e.flushCh:
+		}
+		if err := e.Flush(); err != nil {
+			e.logger.Errorw("Failed to export to the OTLP collector", zap.Error(err))
+		}
+	}
+}
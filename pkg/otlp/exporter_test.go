@@ -0,0 +1,468 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+)
+
+// field is a decoded protobuf field, with the value of varint and fixed64
+// fields in v, and of length-delimited fields in b.
+type field struct {
+	num int
+	v   uint64
+	b   []byte
+}
+
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+	var fields []field
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("Malformed tag in %v", b)
+		}
+		b = b[n:]
+		f := field{num: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.v, n = binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("Malformed varint in %v", b)
+			}
+			b = b[n:]
+		case wireFixed64:
+			f.v = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				t.Fatalf("Malformed length in %v", b)
+			}
+			f.b = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("Unexpected wire type %d", tag&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// get returns the fields with the given number.
+func get(t *testing.T, b []byte, num int) []field {
+	t.Helper()
+	var fs []field
+	for _, f := range decode(t, b) {
+		if f.num == num {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// one returns the single field with the given number.
+func one(t *testing.T, b []byte, num int) field {
+	t.Helper()
+	fs := get(t, b, num)
+	if len(fs) != 1 {
+		t.Fatalf("Got %d fields #%d, want 1", len(fs), num)
+	}
+	return fs[0]
+}
+
+// attributes decodes the KeyValues with the given number, with their
+// values formatted as strings.
+func attributes(t *testing.T, b []byte, num int) map[string]interface{} {
+	t.Helper()
+	attrs := map[string]interface{}{}
+	for _, kv := range get(t, b, num) {
+		key := string(one(t, kv.b, 1).b)
+		av := one(t, kv.b, 2).b
+		for _, f := range decode(t, av) {
+			switch f.num {
+			case 1:
+				attrs[key] = string(f.b)
+			case 2:
+				attrs[key] = f.v == 1
+			case 3:
+				attrs[key] = int64(f.v)
+			case 4:
+				attrs[key] = math.Float64frombits(f.v)
+			}
+		}
+	}
+	return attrs
+}
+
+var testResource = map[string]string{
+	"service.name":             "queue-proxy",
+	"k8s.namespace.name":       "default",
+	"knative.serving.revision": "hello-00001",
+}
+
+func checkResource(t *testing.T, b []byte) {
+	t.Helper()
+	got := attributes(t, one(t, b, 1).b, 1)
+	if len(got) != len(testResource) {
+		t.Errorf("Resource attributes = %v, want %v", got, testResource)
+	}
+	for k, v := range testResource {
+		if got[k] != v {
+			t.Errorf("Resource attribute %s = %v, want %s", k, got[k], v)
+		}
+	}
+}
+
+type request struct {
+	path        string
+	contentType string
+	body        []byte
+}
+
+func newCollector(t *testing.T) (*httptest.Server, <-chan request) {
+	reqs := make(chan request, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("ReadAll() = %v", err)
+		}
+		reqs <- request{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: body}
+	}))
+	return ts, reqs
+}
+
+func testSpan() *trace.SpanData {
+	start := time.Unix(1000, 0)
+	return &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		ParentSpanID: trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "queue_proxy",
+		StartTime:    start,
+		EndTime:      start.Add(time.Second),
+		Attributes: map[string]interface{}{
+			"http.path":        "/",
+			"http.status_code": int64(500),
+		},
+		Annotations: []trace.Annotation{{Time: start, Message: "proxied"}},
+		Status:      trace.Status{Code: trace.StatusCodeUnknown, Message: "boom"},
+	}
+}
+
+func checkSpans(t *testing.T, body []byte) {
+	t.Helper()
+	rs := one(t, body, 1).b
+	checkResource(t, rs)
+
+	ss := one(t, rs, 2).b
+	if got, want := string(one(t, one(t, ss, 1).b, 1).b), scopeName; got != want {
+		t.Errorf("Scope name = %q, want %q", got, want)
+	}
+	span := one(t, ss, 2).b
+	want := testSpan()
+	if got := one(t, span, 1).b; string(got) != string(want.TraceID[:]) {
+		t.Errorf("TraceID = %v, want %v", got, want.TraceID)
+	}
+	if got := one(t, span, 4).b; string(got) != string(want.ParentSpanID[:]) {
+		t.Errorf("ParentSpanID = %v, want %v", got, want.ParentSpanID)
+	}
+	if got := string(one(t, span, 5).b); got != want.Name {
+		t.Errorf("Name = %q, want %q", got, want.Name)
+	}
+	if got := one(t, span, 6).v; got != spanKindServer {
+		t.Errorf("Kind = %d, want %d", got, spanKindServer)
+	}
+	if got, want := one(t, span, 8).v-one(t, span, 7).v, uint64(time.Second); got != want {
+		t.Errorf("Duration = %d, want %d", got, want)
+	}
+	attrs := attributes(t, span, 9)
+	if got, want := attrs["http.status_code"], int64(500); got != want {
+		t.Errorf("http.status_code = %v, want %v", got, want)
+	}
+	if got, want := attrs["http.path"], "/"; got != want {
+		t.Errorf("http.path = %v, want %v", got, want)
+	}
+	if got, want := string(one(t, one(t, span, 11).b, 2).b), "proxied"; got != want {
+		t.Errorf("Event name = %q, want %q", got, want)
+	}
+	status := one(t, span, 15).b
+	if got := one(t, status, 3).v; got != statusCodeError {
+		t.Errorf("Status code = %d, want %d", got, statusCodeError)
+	}
+}
+
+func TestExportSpansHTTP(t *testing.T) {
+	ts, reqs := newCollector(t)
+	defer ts.Close()
+
+	e, err := NewExporter(Options{Endpoint: ts.URL, Protocol: ProtocolHTTP, Resource: testResource})
+	if err != nil {
+		t.Fatalf("NewExporter() = %v", err)
+	}
+	defer e.Stop()
+
+	e.ExportSpan(testSpan())
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+
+	req := <-reqs
+	if got, want := req.path, "/v1/traces"; got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+	if got, want := req.contentType, "application/x-protobuf"; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+	checkSpans(t, req.body)
+
+	// Nothing is left to export.
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	select {
+	case req := <-reqs:
+		t.Errorf("Unexpected export to %s", req.path)
+	default:
+	}
+}
+
+func TestExportSpansBatched(t *testing.T) {
+	ts, reqs := newCollector(t)
+	defer ts.Close()
+
+	e, err := NewExporter(Options{Endpoint: ts.URL, Protocol: ProtocolHTTP, Resource: testResource})
+	if err != nil {
+		t.Fatalf("NewExporter() = %v", err)
+	}
+	defer e.Stop()
+
+	// A full buffer is exported without waiting for the interval.
+	for i := 0; i < maxBufferedSpans; i++ {
+		e.ExportSpan(testSpan())
+	}
+	select {
+	case req := <-reqs:
+		spans := get(t, one(t, one(t, req.body, 1).b, 2).b, 2)
+		if len(spans) != maxBufferedSpans {
+			t.Errorf("Got %d spans, want %d", len(spans), maxBufferedSpans)
+		}
+	case <-time.After(flushInterval / 2):
+		t.Error("Timed out waiting for the spans to be exported")
+	}
+}
+
+func TestExportViewsHTTP(t *testing.T) {
+	ts, reqs := newCollector(t)
+	defer ts.Close()
+
+	e, err := NewExporter(Options{Endpoint: ts.URL + "/", Protocol: ProtocolHTTP, Resource: testResource})
+	if err != nil {
+		t.Fatalf("NewExporter() = %v", err)
+	}
+	defer e.Stop()
+
+	key, err := tag.NewKey("response_code")
+	if err != nil {
+		t.Fatalf("NewKey() = %v", err)
+	}
+	measure := stats.Float64("latencies", "the latencies", stats.UnitMilliseconds)
+	start := time.Unix(1000, 0)
+	e.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "request_count",
+			Description: "the requests",
+			Measure:     measure,
+			Aggregation: view.Count(),
+		},
+		Start: start,
+		End:   start.Add(time.Minute),
+		Rows: []*view.Row{{
+			Tags: []tag.Tag{{Key: key, Value: "200"}},
+			Data: &view.CountData{Value: 3},
+		}},
+	})
+	e.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "request_latencies",
+			Measure:     measure,
+			Aggregation: view.Distribution(10, 100),
+		},
+		Start: start,
+		End:   start.Add(time.Minute),
+		Rows: []*view.Row{{
+			Tags: []tag.Tag{{Key: key, Value: "200"}},
+			Data: &view.DistributionData{Count: 3, Mean: 20, Min: 5, Max: 50, CountPerBucket: []int64{1, 2, 0}},
+		}},
+	})
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+
+	req := <-reqs
+	if got, want := req.path, "/v1/metrics"; got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+	rm := one(t, req.body, 1).b
+	checkResource(t, rm)
+	metrics := get(t, one(t, rm, 2).b, 2)
+	if len(metrics) != 2 {
+		t.Fatalf("Got %d metrics, want 2", len(metrics))
+	}
+
+	// The metrics are sorted by name.
+	count, latencies := metrics[0].b, metrics[1].b
+	if got, want := string(one(t, count, 1).b), "request_count"; got != want {
+		t.Errorf("Name = %q, want %q", got, want)
+	}
+	if got, want := string(one(t, count, 3).b), stats.UnitMilliseconds; got != want {
+		t.Errorf("Unit = %q, want %q", got, want)
+	}
+	sum := one(t, count, 7).b
+	if got := one(t, sum, 2).v; got != temporalityCumulative {
+		t.Errorf("Temporality = %d, want %d", got, temporalityCumulative)
+	}
+	if got := one(t, sum, 3).v; got != 1 {
+		t.Errorf("Monotonic = %d, want 1", got)
+	}
+	point := one(t, sum, 1).b
+	if got, want := one(t, point, 6).v, uint64(3); got != want {
+		t.Errorf("Count = %d, want %d", got, want)
+	}
+	if got, want := attributes(t, point, 7)["response_code"], "200"; got != want {
+		t.Errorf("response_code = %v, want %v", got, want)
+	}
+
+	if got, want := string(one(t, latencies, 1).b), "request_latencies"; got != want {
+		t.Errorf("Name = %q, want %q", got, want)
+	}
+	hist := one(t, one(t, latencies, 9).b, 1).b
+	if got, want := one(t, hist, 4).v, uint64(3); got != want {
+		t.Errorf("Count = %d, want %d", got, want)
+	}
+	if got, want := math.Float64frombits(one(t, hist, 5).v), 60.0; got != want {
+		t.Errorf("Sum = %v, want %v", got, want)
+	}
+	if got, want := len(one(t, hist, 6).b), 3*8; got != want {
+		t.Errorf("Bucket counts are %d bytes, want %d", got, want)
+	}
+	if got, want := len(one(t, hist, 7).b), 2*8; got != want {
+		t.Errorf("Bounds are %d bytes, want %d", got, want)
+	}
+	if got, want := attributes(t, hist, 9)["response_code"], "200"; got != want {
+		t.Errorf("response_code = %v, want %v", got, want)
+	}
+}
+
+func TestExportHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	e, err := NewExporter(Options{Endpoint: ts.URL, Protocol: ProtocolHTTP})
+	if err != nil {
+		t.Fatalf("NewExporter() = %v", err)
+	}
+	defer e.Stop()
+
+	e.ExportSpan(testSpan())
+	if err := e.Flush(); err == nil {
+		t.Error("Flush() = nil, want an error")
+	}
+}
+
+func TestExportSpansGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		methods []string
+		bodies  [][]byte
+	)
+	server := grpc.NewServer(
+		grpc.CustomCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			var body rawMessage
+			if err := stream.RecvMsg(&body); err != nil {
+				return err
+			}
+			mu.Lock()
+			methods = append(methods, method)
+			bodies = append(bodies, body)
+			mu.Unlock()
+			return stream.SendMsg(rawMessage(nil))
+		}))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	// The protocol defaults to gRPC.
+	e, err := NewExporter(Options{Endpoint: lis.Addr().String(), Resource: testResource})
+	if err != nil {
+		t.Fatalf("NewExporter() = %v", err)
+	}
+
+	e.ExportSpan(testSpan())
+	// Stop flushes what's left.
+	if err := e.Stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) != 1 {
+		t.Fatalf("Got %d exports, want 1", len(methods))
+	}
+	if got, want := methods[0], traceExportMethod; got != want {
+		t.Errorf("Method = %q, want %q", got, want)
+	}
+	checkSpans(t, bodies[0])
+}
+
+func TestNewExporterErrors(t *testing.T) {
+	for _, opts := range []Options{{
+		Protocol: ProtocolHTTP,
+	}, {
+		Endpoint: "localhost:4317",
+		Protocol: "thrift",
+	}} {
+		if _, err := NewExporter(opts); err == nil {
+			t.Errorf("NewExporter(%v) = nil, want an error", opts)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// signal is the kind of telemetry an export request carries.
+type signal int
+
+const (
+	signalTraces signal = iota
+	signalMetrics
+)
+
+// transport sends the encoded export requests to the collector.
+type transport interface {
+	export(ctx context.Context, sig signal, body []byte) error
+	close() error
+}
+
+// httpTransport sends the export requests with OTLP/HTTP, as protobuf
+// bodies POSTed to the /v1/traces and /v1/metrics paths of the endpoint.
+type httpTransport struct {
+	client   *http.Client
+	endpoint string
+}
+
+func newHTTPTransport(endpoint string) *httpTransport {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return &httpTransport{
+		client:   &http.Client{},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+	}
+}
+
+func (t *httpTransport) export(ctx context.Context, sig signal, body []byte) error {
+	path := "/v1/traces"
+	if sig == signalMetrics {
+		path = "/v1/metrics"
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := t.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body for the connection to be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("exporting to %s%s failed with status %d", t.endpoint, path, resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *httpTransport) close() error {
+	return nil
+}
+
+// The methods of the OTLP collector services.
+const (
+	traceExportMethod   = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	metricsExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// grpcTransport sends the export requests with OTLP/gRPC, with the already
+// encoded messages passed through as is by rawCodec.
+type grpcTransport struct {
+	conn *grpc.ClientConn
+}
+
+func newGRPCTransport(endpoint string) (*grpcTransport, error) {
+	// gRPC targets have no scheme, but it's a common mistake to use
+	// the URL of the collector.
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	// The dial doesn't block, the connection is made by the first export.
+	conn, err := grpc.DialContext(context.Background(), endpoint, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &grpcTransport{conn: conn}, nil
+}
+
+func (t *grpcTransport) export(ctx context.Context, sig signal, body []byte) error {
+	method := traceExportMethod
+	if sig == signalMetrics {
+		method = metricsExportMethod
+	}
+	var resp rawMessage
+	// The response only reports partial successes, which are ignored.
+	return t.conn.Invoke(ctx, method, rawMessage(body), &resp, grpc.CallCustomCodec(rawCodec{}))
+}
+
+func (t *grpcTransport) close() error {
+	return t.conn.Close()
+}
+
+// rawMessage is a message encoded by hand.
+type rawMessage []byte
+
+// rawCodec is a grpc.Codec of rawMessages, which are already encoded.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case rawMessage:
+		return m, nil
+	case *rawMessage:
+		return *m, nil
+	default:
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+func (rawCodec) String() string {
+	return "raw"
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The protobuf wire types used by the OTLP messages.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encoder appends the protobuf encoding of fields to a buffer. As in proto3,
+// the scalar fields with their zero value are omitted, but for the ones
+// that are part of a oneof or optional, which use the *Always variants.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) varint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) fixed64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) uint64Field(field int, v uint64) {
+	if v != 0 {
+		e.uint64Always(field, v)
+	}
+}
+
+func (e *encoder) uint64Always(field int, v uint64) {
+	e.tag(field, wireVarint)
+	e.varint(v)
+}
+
+func (e *encoder) boolAlways(field int, v bool) {
+	var b uint64
+	if v {
+		b = 1
+	}
+	e.uint64Always(field, b)
+}
+
+func (e *encoder) fixed64Field(field int, v uint64) {
+	if v != 0 {
+		e.fixed64Always(field, v)
+	}
+}
+
+func (e *encoder) fixed64Always(field int, v uint64) {
+	e.tag(field, wireFixed64)
+	e.fixed64(v)
+}
+
+func (e *encoder) doubleAlways(field int, v float64) {
+	e.fixed64Always(field, math.Float64bits(v))
+}
+
+func (e *encoder) bytesField(field int, b []byte) {
+	if len(b) != 0 {
+		e.bytesAlways(field, b)
+	}
+}
+
+func (e *encoder) bytesAlways(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) stringField(field int, s string) {
+	if s != "" {
+		e.stringAlways(field, s)
+	}
+}
+
+func (e *encoder) stringAlways(field int, s string) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// message encodes the embedded message written by fn as the given field.
+func (e *encoder) message(field int, fn func(*encoder)) {
+	var m encoder
+	fn(&m)
+	e.bytesAlways(field, m.buf)
+}
+
+// packedFixed64 encodes a repeated fixed64 field in its packed form.
+func (e *encoder) packedFixed64(field int, vs []uint64) {
+	if len(vs) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(vs)))
+	for _, v := range vs {
+		e.fixed64(v)
+	}
+}
+
+// packedDouble encodes a repeated double field in its packed form.
+func (e *encoder) packedDouble(field int, vs []float64) {
+	if len(vs) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(vs)))
+	for _, v := range vs {
+		e.fixed64(math.Float64bits(v))
+	}
+}
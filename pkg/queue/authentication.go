@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+// ErrNoCredentials is returned by Authenticators for the requests that
+// don't carry the credentials they check.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator authenticates the requests the queue-proxy forwards to the
+// user container.
+type Authenticator interface {
+	// Authenticate returns the identity of the client of the request, or
+	// an error if it can't be authenticated, ErrNoCredentials when the
+	// request doesn't carry any credentials for this Authenticator.
+	Authenticate(r *http.Request) (string, error)
+	// Scheme is the HTTP authentication scheme challenged in the
+	// WWW-Authenticate header of unauthenticated requests, if any.
+	Scheme() string
+}
+
+// AuthenticationPolicy are the Authenticators of the requests and the
+// requests let through without authentication.
+type AuthenticationPolicy struct {
+	// Authenticators are tried in order, a request is let through by the
+	// first one that authenticates it.
+	Authenticators []Authenticator
+	// Exempt returns whether the request is let through without
+	// authentication, if set.
+	Exempt func(*http.Request) bool
+	// ProbePaths are the paths of the user container's probes. Only the
+	// kubelet probes of these paths are let through, so that its health
+	// checks pass without the rest of the container being exposed to
+	// requests merely claiming to be probes.
+	ProbePaths []string
+	// Probe answers the probes of the queue-proxy itself, i.e. the
+	// requests whose network.ProbeHeaderName header is Name, without
+	// authentication. Those probes are never forwarded.
+	Probe http.Handler
+}
+
+// AuthenticationHandler only forwards the requests authenticated by the
+// policy, with the identity of their client in the
+// network.AuthenticatedIdentityHeaderName header, and answers the others
+// with 401 Unauthorized. The probes of the queue-proxy itself are answered
+// by the policy's Probe rather than forwarded.
+func AuthenticationHandler(h http.Handler, p AuthenticationPolicy) http.HandlerFunc {
+	var challenges []string
+	for _, a := range p.Authenticators {
+		if s := a.Scheme(); s != "" {
+			challenges = append(challenges, s)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The identity sent by the client itself is never trusted.
+		r.Header.Del(network.AuthenticatedIdentityHeaderName)
+
+		if p.Probe != nil && r.Header.Get(network.ProbeHeaderName) == Name {
+			p.Probe.ServeHTTP(w, r)
+			return
+		}
+		if (network.IsKubeletProbe(r) && p.isProbePath(r.URL.Path)) ||
+			(p.Exempt != nil && p.Exempt(r)) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		err := ErrNoCredentials
+		for _, a := range p.Authenticators {
+			id, aerr := a.Authenticate(r)
+			if aerr == nil {
+				r.Header.Set(network.AuthenticatedIdentityHeaderName, id)
+				h.ServeHTTP(w, r)
+				return
+			}
+			// Report why the credentials that were sent were rejected.
+			if aerr != ErrNoCredentials {
+				err = aerr
+			}
+		}
+
+		for _, c := range challenges {
+			w.Header().Add("WWW-Authenticate", c)
+		}
+		http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+	}
+}
+
+func (p AuthenticationPolicy) isProbePath(path string) bool {
+	for _, pp := range p.ProbePaths {
+		if path == pp {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCertAuthenticator authenticates the requests by the subject
+// alternative names of the client certificate verified by the ingress, as
+// forwarded in the X-Forwarded-Client-Cert header.
+type ClientCertAuthenticator struct {
+	// SANs are the URI and DNS SANs let through, * for any of them.
+	SANs []string
+	// TrustedProxies are the peers whose X-Forwarded-Client-Cert header
+	// is trusted. The header of any other peer is ignored.
+	TrustedProxies []*net.IPNet
+}
+
+var _ Authenticator = (*ClientCertAuthenticator)(nil)
+
+// Authenticate implements Authenticator, with the first SAN let through
+// as the identity.
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (string, error) {
+	xfcc := r.Header.Get(network.ForwardedClientCertHeaderName)
+	if xfcc == "" || !network.IsPeerTrusted(r, a.TrustedProxies) {
+		return "", ErrNoCredentials
+	}
+	cert := parseForwardedClientCert(xfcc)
+	sans := append(cert["uri"], cert["dns"]...)
+	for _, san := range sans {
+		for _, want := range a.SANs {
+			if want == "*" || san == want {
+				return san, nil
+			}
+		}
+	}
+	if len(sans) == 0 {
+		return "", errors.New("client certificate has no SAN")
+	}
+	return "", fmt.Errorf("client certificate of %s is not allowed", strings.Join(sans, ","))
+}
+
+// Scheme implements Authenticator. Client certificates aren't challenged
+// over HTTP.
+func (a *ClientCertAuthenticator) Scheme() string {
+	return ""
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+type fakeAuthenticator struct {
+	id     string
+	err    error
+	scheme string
+}
+
+func (a fakeAuthenticator) Authenticate(*http.Request) (string, error) {
+	return a.id, a.err
+}
+
+func (a fakeAuthenticator) Scheme() string {
+	return a.scheme
+}
+
+func TestAuthenticationHandler(t *testing.T) {
+	bearer := fakeAuthenticator{err: ErrNoCredentials, scheme: "Bearer"}
+	tests := []struct {
+		name          string
+		policy        AuthenticationPolicy
+		header        http.Header
+		path          string
+		wantStatus    int
+		wantIdentity  string
+		wantChallenge []string
+		wantProbed    bool
+	}{{
+		name: "authenticated",
+		policy: AuthenticationPolicy{Authenticators: []Authenticator{
+			bearer,
+			fakeAuthenticator{id: "spiffe://client"},
+		}},
+		header:       http.Header{network.AuthenticatedIdentityHeaderName: {"admin"}},
+		wantStatus:   http.StatusOK,
+		wantIdentity: "spiffe://client",
+	}, {
+		name:          "no credentials",
+		policy:        AuthenticationPolicy{Authenticators: []Authenticator{bearer}},
+		header:        http.Header{network.AuthenticatedIdentityHeaderName: {"admin"}},
+		wantStatus:    http.StatusUnauthorized,
+		wantChallenge: []string{"Bearer"},
+	}, {
+		name: "invalid credentials",
+		policy: AuthenticationPolicy{Authenticators: []Authenticator{
+			fakeAuthenticator{err: errors.New("invalid bearer token"), scheme: "Bearer"},
+			fakeAuthenticator{err: ErrNoCredentials},
+		}},
+		wantStatus:    http.StatusUnauthorized,
+		wantChallenge: []string{"Bearer"},
+	}, {
+		name:       "knative probe",
+		policy:     AuthenticationPolicy{Authenticators: []Authenticator{bearer}},
+		header:     http.Header{network.ProbeHeaderName: {Name}},
+		wantStatus: http.StatusOK,
+		wantProbed: true,
+	}, {
+		name:          "knative probe of another component",
+		policy:        AuthenticationPolicy{Authenticators: []Authenticator{bearer}},
+		header:        http.Header{network.ProbeHeaderName: {"activator"}},
+		wantStatus:    http.StatusUnauthorized,
+		wantChallenge: []string{"Bearer"},
+	}, {
+		name: "kubelet probe",
+		policy: AuthenticationPolicy{
+			Authenticators: []Authenticator{bearer},
+			ProbePaths:     []string{"/healthz"},
+		},
+		header:     http.Header{network.KubeletProbeHeaderName: {"queue"}},
+		path:       "/healthz",
+		wantStatus: http.StatusOK,
+	}, {
+		name: "kubelet probe of another path",
+		policy: AuthenticationPolicy{
+			Authenticators: []Authenticator{bearer},
+			ProbePaths:     []string{"/healthz"},
+		},
+		header:        http.Header{"User-Agent": {"kube-probe/1.15"}},
+		path:          "/admin",
+		wantStatus:    http.StatusUnauthorized,
+		wantChallenge: []string{"Bearer"},
+	}, {
+		name: "exempt",
+		policy: AuthenticationPolicy{
+			Authenticators: []Authenticator{bearer},
+			Exempt:         func(r *http.Request) bool { return r.URL.Path == "/public" },
+		},
+		path:       "/public",
+		wantStatus: http.StatusOK,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotIdentity string
+			var forwarded, probed bool
+			test.policy.Probe = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				probed = true
+			})
+			h := AuthenticationHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = true
+				gotIdentity = r.Header.Get(network.AuthenticatedIdentityHeaderName)
+			}), test.policy)
+
+			path := test.path
+			if path == "" {
+				path = "/"
+			}
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			for k, v := range test.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got, want := w.Code, test.wantStatus; got != want {
+				t.Errorf("Status = %d, want %d", got, want)
+			}
+			if got, want := gotIdentity, test.wantIdentity; got != want {
+				t.Errorf("Identity = %q, want %q", got, want)
+			}
+			if got, want := probed, test.wantProbed; got != want {
+				t.Errorf("Probed = %v, want %v", got, want)
+			}
+			if probed && forwarded {
+				t.Error("Probe was forwarded")
+			}
+			if got, want := w.Header()["Www-Authenticate"], test.wantChallenge; len(got) != len(want) || (len(want) > 0 && got[0] != want[0]) {
+				t.Errorf("WWW-Authenticate = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	tests := []struct {
+		name       string
+		sans       []string
+		xfcc       string
+		remoteAddr string
+		want       string
+		wantErr    error
+	}{{
+		name:    "no client certificate",
+		sans:    []string{"*"},
+		wantErr: ErrNoCredentials,
+	}, {
+		name: "allowed URI SAN",
+		sans: []string{"spiffe://other", "spiffe://client"},
+		xfcc: `Hash=abc;Subject="CN=client";URI=spiffe://client;DNS=client.example.com`,
+		want: "spiffe://client",
+	}, {
+		name: "allowed DNS SAN",
+		sans: []string{"client.example.com"},
+		xfcc: `Hash=abc;URI=spiffe://client;DNS=client.example.com`,
+		want: "client.example.com",
+	}, {
+		name: "any SAN",
+		sans: []string{"*"},
+		xfcc: `Hash=abc;DNS=client.example.com`,
+		want: "client.example.com",
+	}, {
+		name:    "other SAN",
+		sans:    []string{"spiffe://admin"},
+		xfcc:    `Hash=abc;URI=spiffe://client`,
+		wantErr: errors.New("client certificate of spiffe://client is not allowed"),
+	}, {
+		name:    "only the element of the ingress is used",
+		sans:    []string{"spiffe://sidecar"},
+		xfcc:    `Hash=abc;URI=spiffe://client,By=spiffe://gateway;URI=spiffe://sidecar`,
+		wantErr: errors.New("client certificate of spiffe://client is not allowed"),
+	}, {
+		name:       "untrusted peer",
+		sans:       []string{"*"},
+		xfcc:       `Hash=abc;URI=spiffe://client`,
+		remoteAddr: "10.8.0.3:43210",
+		wantErr:    ErrNoCredentials,
+	}, {
+		name:    "no SAN",
+		sans:    []string{"*"},
+		xfcc:    `Hash=abc;Subject="CN=client"`,
+		wantErr: errors.New("client certificate has no SAN"),
+	}}
+
+	_, trusted, _ := net.ParseCIDR("10.4.0.0/16")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.4.0.2:43210"
+			if test.remoteAddr != "" {
+				r.RemoteAddr = test.remoteAddr
+			}
+			if test.xfcc != "" {
+				r.Header.Set(network.ForwardedClientCertHeaderName, test.xfcc)
+			}
+			a := &ClientCertAuthenticator{SANs: test.sans, TrustedProxies: []*net.IPNet{trusted}}
+			got, err := a.Authenticate(r)
+			if (err == nil) != (test.wantErr == nil) || (err != nil && err.Error() != test.wantErr.Error()) {
+				t.Fatalf("Authenticate() = %v, want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Authenticate() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
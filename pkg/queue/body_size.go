@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	pkghttp "github.com/knative/serving/pkg/http"
+	"knative.dev/pkg/websocket"
+)
+
+var (
+	// ErrRequestBodyTooLarge is returned when reading past the limit of
+	// the request bodies of BodySizeHandler.
+	ErrRequestBodyTooLarge = errors.New("request body too large")
+	// ErrResponseBodyTooLarge is returned when writing past the limit of
+	// the response bodies of BodySizeHandler.
+	ErrResponseBodyTooLarge = errors.New("response body too large")
+)
+
+// BodySizeHandler fails the requests with a body larger than
+// maxRequestBytes with a 413, and the responses with a body larger than
+// maxResponseBytes with a 502, zero meaning unlimited. The sizes of the
+// bodies it served are passed to report, if it isn't nil.
+//
+// A request announcing a larger body is failed before it's forwarded.
+// Otherwise, reading past the limit fails, which fails forwarding the
+// request. A response announcing a larger body is replaced, and one
+// streamed past the limit is cut, as its headers are already sent.
+func BodySizeHandler(h http.Handler, maxRequestBytes, maxResponseBytes int64, report func(request, response int64)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxRequestBytes > 0 && r.ContentLength > maxRequestBytes {
+			http.Error(w, ErrRequestBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			if report != nil {
+				report(0, int64(len(ErrRequestBodyTooLarge.Error())+1))
+			}
+			return
+		}
+
+		body := &sizedBody{limit: maxRequestBytes}
+		if r.Body != nil && r.Body != http.NoBody {
+			body.ReadCloser = r.Body
+			r.Body = body
+		}
+		sw := &sizedWriter{writer: w, body: body, limit: maxResponseBytes}
+		h.ServeHTTP(sw, r)
+		if report != nil {
+			report(atomic.LoadInt64(&body.n), sw.n)
+		}
+	})
+}
+
+// sizedBody counts the bytes read from the request body, and fails the
+// reads past the limit, unless it's zero. The body may still be read by
+// the transport once the handler is done, so the counts are atomic.
+type sizedBody struct {
+	io.ReadCloser
+	limit    int64
+	n        int64
+	exceeded int32
+}
+
+func (b *sizedBody) Read(p []byte) (int, error) {
+	if b.tooLarge() {
+		return 0, ErrRequestBodyTooLarge
+	}
+	n := atomic.LoadInt64(&b.n)
+	// Read a byte past the limit at most, to tell it's exceeded.
+	if b.limit > 0 && int64(len(p)) > b.limit-n+1 {
+		p = p[:b.limit-n+1]
+	}
+	read, err := b.ReadCloser.Read(p)
+	if n += int64(read); b.limit > 0 && n > b.limit {
+		atomic.StoreInt32(&b.exceeded, 1)
+		atomic.StoreInt64(&b.n, b.limit)
+		return read - int(n-b.limit), ErrRequestBodyTooLarge
+	}
+	atomic.StoreInt64(&b.n, n)
+	return read, err
+}
+
+func (b *sizedBody) tooLarge() bool {
+	return atomic.LoadInt32(&b.exceeded) == 1
+}
+
+// sizedWriter counts the bytes of the response body, and fails it past
+// the limit, unless it's zero.
+type sizedWriter struct {
+	writer http.ResponseWriter
+	body   *sizedBody
+	limit  int64
+
+	wroteHeader bool
+	n           int64
+	// exceeded is set once the response is failed for its size.
+	exceeded bool
+}
+
+var (
+	_ http.Flusher        = (*sizedWriter)(nil)
+	_ http.Hijacker       = (*sizedWriter)(nil)
+	_ http.ResponseWriter = (*sizedWriter)(nil)
+)
+
+func (sw *sizedWriter) Header() http.Header { return sw.writer.Header() }
+
+func (sw *sizedWriter) WriteHeader(code int) {
+	if sw.wroteHeader || pkghttp.IsInformational(code) {
+		sw.writer.WriteHeader(code)
+		return
+	}
+	sw.wroteHeader = true
+
+	switch {
+	case sw.body.tooLarge():
+		// Forwarding the request failed on reading its body.
+		sw.fail(ErrRequestBodyTooLarge, http.StatusRequestEntityTooLarge)
+	case sw.limit > 0 && contentLength(sw.writer.Header()) > sw.limit:
+		sw.fail(ErrResponseBodyTooLarge, http.StatusBadGateway)
+		// Send the error before the write of the body aborts the request.
+		sw.Flush()
+	default:
+		sw.writer.WriteHeader(code)
+	}
+}
+
+// fail replaces the response with the error.
+func (sw *sizedWriter) fail(err error, code int) {
+	sw.exceeded = true
+	header := sw.writer.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	http.Error(sw.writer, err.Error(), code)
+	sw.n = int64(len(err.Error()) + 1)
+}
+
+func (sw *sizedWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.exceeded {
+		return 0, ErrResponseBodyTooLarge
+	}
+	if sw.limit > 0 && sw.n+int64(len(p)) > sw.limit {
+		sw.exceeded = true
+		n, _ := sw.writer.Write(p[:sw.limit-sw.n])
+		sw.n += int64(n)
+		return n, ErrResponseBodyTooLarge
+	}
+	n, err := sw.writer.Write(p)
+	sw.n += int64(n)
+	return n, err
+}
+
+func (sw *sizedWriter) Flush() {
+	if f, ok := sw.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (sw *sizedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return websocket.HijackIfPossible(sw.writer)
+}
+
+// contentLength returns the Content-Length of the header, or -1 if it has
+// none.
+func contentLength(header http.Header) int64 {
+	n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBodySizeHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		chunked       bool
+		response      string
+		contentLength bool
+		wantStatus    int
+		wantBody      string
+		wantErr       error
+		wantSizes     [2]int64
+	}{{
+		name:       "within the limits",
+		body:       "request",
+		response:   "response",
+		wantStatus: http.StatusOK,
+		wantBody:   "response",
+		wantSizes:  [2]int64{7, 8},
+	}, {
+		name:       "request announced too large",
+		body:       "a large request",
+		response:   "response",
+		wantStatus: http.StatusRequestEntityTooLarge,
+		wantBody:   "request body too large\n",
+		wantSizes:  [2]int64{0, 23},
+	}, {
+		name:       "request streamed too large",
+		body:       "a large request",
+		chunked:    true,
+		response:   "response",
+		wantStatus: http.StatusRequestEntityTooLarge,
+		wantBody:   "request body too large\n",
+		wantSizes:  [2]int64{10, 23},
+	}, {
+		name:          "response announced too large",
+		response:      "a large response",
+		contentLength: true,
+		wantStatus:    http.StatusBadGateway,
+		wantBody:      "response body too large\n",
+		wantErr:       ErrResponseBodyTooLarge,
+		wantSizes:     [2]int64{0, 24},
+	}, {
+		name:       "response streamed too large",
+		response:   "a large response",
+		wantStatus: http.StatusOK,
+		wantBody:   "a large re",
+		wantErr:    ErrResponseBodyTooLarge,
+		wantSizes:  [2]int64{0, 10},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var writeErr error
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Like the proxy, fail the request when its body can't be read.
+				if _, err := ioutil.ReadAll(r.Body); err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				if test.contentLength {
+					w.Header().Set("Content-Length", strconv.Itoa(len(test.response)))
+				}
+				_, writeErr = w.Write([]byte(test.response))
+			})
+			var sizes [2]int64
+			report := func(request, response int64) {
+				sizes = [2]int64{request, response}
+			}
+
+			var body io.Reader = strings.NewReader(test.body)
+			if test.chunked {
+				// Hide the length of the body.
+				body = ioutil.NopCloser(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", body)
+			if test.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			BodySizeHandler(h, 10, 10, report).ServeHTTP(rec, req)
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("Status = %d, want: %d", rec.Code, test.wantStatus)
+			}
+			if got := rec.Body.String(); got != test.wantBody {
+				t.Errorf("Body = %q, want: %q", got, test.wantBody)
+			}
+			if writeErr != test.wantErr {
+				t.Errorf("Write() = %v, want: %v", writeErr, test.wantErr)
+			}
+			if sizes != test.wantSizes {
+				t.Errorf("Reported sizes = %v, want: %v", sizes, test.wantSizes)
+			}
+		})
+	}
+}
+
+func TestBodySizeHandlerProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer backend.Close()
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("Error parsing the URL: %v", err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	server := httptest.NewServer(BodySizeHandler(proxy, 1024, 0, nil))
+	defer server.Close()
+
+	for _, size := range []int{1024, 1025} {
+		// Stream the body, for its size not to be known upfront.
+		pr, pw := io.Pipe()
+		go func() {
+			pw.Write([]byte(strings.Repeat("x", size)))
+			pw.Close()
+		}()
+		resp, err := http.Post(server.URL, "text/plain", pr)
+		if err != nil {
+			t.Fatalf("Error sending %d bytes: %v", size, err)
+		}
+		resp.Body.Close()
+		want := http.StatusOK
+		if size > 1024 {
+			want = http.StatusRequestEntityTooLarge
+		}
+		if resp.StatusCode != want {
+			t.Errorf("Status of %d bytes = %d, want: %d", size, resp.StatusCode, want)
+		}
+	}
+}
@@ -17,24 +17,166 @@ limitations under the License.
 package queue
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	// ErrUpdateCapacity indicates that the capacity could not be updated as wished.
 	ErrUpdateCapacity = errors.New("failed to add all capacity to the breaker")
+	// ErrUpdateQueueDepth indicates that the queue depth could not be updated
+	// as wished.
+	ErrUpdateQueueDepth = errors.New("queue depth must be greater than 0")
 	// ErrRelease indicates that release was called more often than acquire.
 	ErrRelease = errors.New("semaphore release error: returned tokens must be <= acquired tokens")
+	// ErrRequestWeight indicates the weight of a request was invalid.
+	ErrRequestWeight = errors.New("request weight must be greater than 0")
 )
 
+// Priority orders the requests waiting for capacity in a Breaker. Requests
+// of a higher priority are let through first, the ones of the same
+// priority in the order they arrived.
+type Priority int
+
+const (
+	// PriorityNormal is the priority of the regular user requests.
+	PriorityNormal Priority = iota
+	// PriorityHigh lets requests, like health checks, jump ahead of the
+	// regular ones. Higher priorities may be used for more tiers.
+	PriorityHigh
+)
+
+// QueueDiscipline is the order in which a Breaker lets the queued calls of
+// the same priority through.
+type QueueDiscipline int
+
+const (
+	// QueueFIFO lets the calls through in the order they arrived.
+	QueueFIFO QueueDiscipline = iota
+	// QueueLIFO lets the newest calls through first. Under overload most
+	// calls are served quickly, while the oldest ones wait until they
+	// time out rather than everyone waiting for long.
+	QueueLIFO
+	// QueueAdaptive is QueueFIFO as long as the queue drains regularly.
+	// Once it hasn't been empty for the AdaptiveInterval, like CoDel, the
+	// calls arriving are let through LIFO and shed with ErrAcquireTimeout
+	// if they wait for longer than the AdaptiveTarget.
+	QueueAdaptive
+)
+
+// serviceTimeDecay is the weight of the moving average of the service
+// time of a Breaker, i.e. each call counts for 1/serviceTimeDecay.
+const serviceTimeDecay = 8
+
+const (
+	// DefaultAdaptiveTarget is the default AdaptiveTarget of BreakerParams.
+	DefaultAdaptiveTarget = 5 * time.Millisecond
+	// DefaultAdaptiveInterval is the default AdaptiveInterval of
+	// BreakerParams.
+	DefaultAdaptiveInterval = 100 * time.Millisecond
+)
+
+var queueDisciplineNames = map[QueueDiscipline]string{
+	QueueFIFO:     "FIFO",
+	QueueLIFO:     "LIFO",
+	QueueAdaptive: "Adaptive",
+}
+
+// String returns the name of the discipline, as parsed by
+// ParseQueueDiscipline.
+func (d QueueDiscipline) String() string {
+	if name, ok := queueDisciplineNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("QueueDiscipline(%d)", int(d))
+}
+
+// ParseQueueDiscipline returns the discipline of the given name, e.g.
+// "LIFO". The empty name is QueueFIFO.
+func ParseQueueDiscipline(name string) (QueueDiscipline, error) {
+	if name == "" {
+		return QueueFIFO, nil
+	}
+	for d, n := range queueDisciplineNames {
+		if n == name {
+			return d, nil
+		}
+	}
+	return QueueFIFO, fmt.Errorf("unknown queue discipline %q", name)
+}
+
 // BreakerParams defines the parameters of the breaker.
 type BreakerParams struct {
 	QueueDepth      int
 	MaxConcurrency  int
 	InitialCapacity int
+	// Hooks are called in the phases of each call, optionally.
+	Hooks BreakerHooks
+	// Discipline is the order queued calls are let through in, QueueFIFO
+	// by default.
+	Discipline QueueDiscipline
+	// AdaptiveTarget and AdaptiveInterval tune QueueAdaptive. They
+	// default to DefaultAdaptiveTarget and DefaultAdaptiveInterval.
+	AdaptiveTarget   time.Duration
+	AdaptiveInterval time.Duration
+	// Overflow, if set, is called with the context of the calls rejected
+	// because the queue is full, e.g. to serve them elsewhere, and the
+	// call returns what it returns rather than ErrQueueFull.
+	Overflow func(ctx context.Context) error
+	// Timeout, if set, returns how long the calls whose context has no
+	// deadline may take to get through, e.g. the request timeout. Calls
+	// aren't failed after it, but it is their deadline when preempting
+	// the queued calls as the capacity is lowered.
+	Timeout func() time.Duration
+}
+
+// BreakerHooks are callbacks for the phases of the calls of a Breaker,
+// e.g. to trace them or to record how long they wait. Any of them may be
+// nil. They are called synchronously by the caller of Maybe, so they
+// must be quick.
+type BreakerHooks struct {
+	// OnEnqueue is called when a call takes a slot in the queue, before it
+	// waits for capacity. The context it returns, e.g. with a tracing span,
+	// is passed to the other hooks of the call.
+	OnEnqueue func(ctx context.Context) context.Context
+	// OnDequeue is called when a call stops waiting for capacity, with how
+	// long it waited and the error Maybe returns if it didn't get capacity.
+	OnDequeue func(ctx context.Context, wait time.Duration, err error)
+	// OnReject is called when a call is rejected because the queue is full.
+	OnReject func(ctx context.Context)
+	// OnComplete is called when the thunk of a call returns, with how long
+	// it ran.
+	OnComplete func(ctx context.Context, run time.Duration)
+	// OnStateChange is called when a Breaker goes from no calls in-flight
+	// to some, with active true and the context of the call, and back,
+	// with active false and a background context. The calls are
+	// serialized and alternate, and no call is let through while one is
+	// running, so that e.g. a paused container is resumed before the
+	// first call reaches it. If it returns an error going active, the
+	// call fails with it. It isn't called by FairBreaker.
+	OnStateChange func(ctx context.Context, active bool) error
+}
+
+// queueWaitKey is the context key of the queue wait recorded by the calls
+// of a Breaker.
+type queueWaitKey struct{}
+
+// WithQueueWait returns a context recording how long the Breaker or
+// FairBreaker call it is passed to waited for capacity. The wait is set
+// before the thunk of the call runs, so that e.g. the thunk can report it.
+func WithQueueWait(ctx context.Context) (context.Context, *time.Duration) {
+	wait := new(time.Duration)
+	return context.WithValue(ctx, queueWaitKey{}, wait), wait
+}
+
+func recordQueueWait(ctx context.Context, wait time.Duration) {
+	if w, ok := ctx.Value(queueWaitKey{}).(*time.Duration); ok {
+		*w = wait
+	}
 }
 
 // Breaker is a component that enforces a concurrency limit on the
@@ -42,8 +184,69 @@ type BreakerParams struct {
 // executions in excess of the concurrency limit. Function call attempts
 // beyond the limit of the queue are failed immediately.
 type Breaker struct {
-	pendingRequests chan struct{}
-	sem             *semaphore
+	// The counters are accessed atomically, so they come first to be
+	// 64-bit aligned on 32-bit platforms.
+	inFlight int64
+	queued   int64
+	rejected int64
+	// pending is the number of calls in the breaker, queued or in-flight,
+	// and totalSlots the number of calls it takes, i.e. the queue depth
+	// plus the maximal concurrency.
+	pending    int64
+	totalSlots int64
+	// serviceTime is the moving average of how long the thunks run, in
+	// nanoseconds.
+	serviceTime int64
+	// draining is set by Drain, which waits for idle to be closed once
+	// no call is pending anymore.
+	draining int32
+	idle     chan struct{}
+	idleOnce sync.Once
+
+	// active is the state last reported to the OnStateChange hook.
+	// `stateMux` must be held to access it.
+	stateMux sync.Mutex
+	active   bool
+
+	maxConcurrency int
+	sem            *semaphore
+	hooks          BreakerHooks
+	overflow       func(ctx context.Context) error
+}
+
+// BreakerStats is a snapshot of the state of a Breaker.
+type BreakerStats struct {
+	// InFlight is the number of calls holding a concurrency token.
+	InFlight int
+	// Queued is the number of calls waiting for a concurrency token.
+	Queued int
+	// Capacity is the number of allowed in-flight calls.
+	Capacity int
+	// Rejected is the number of calls rejected because the queue was
+	// full, since the Breaker was created.
+	Rejected int64
+}
+
+// SemaphoreStats is a snapshot of the tokens of the semaphore of a Breaker,
+// to diagnose its capacity.
+type SemaphoreStats struct {
+	// MaxCapacity is the most tokens the semaphore can have in rotation.
+	MaxCapacity int `json:"maxCapacity"`
+	// Capacity is the number of tokens in rotation.
+	Capacity int `json:"capacity"`
+	// Reducers is the number of tokens to take out of the rotation as they
+	// are released, as the capacity was lowered while they were held.
+	Reducers int `json:"reducers"`
+	// EffectiveCapacity is the capacity once the reducers are taken out.
+	EffectiveCapacity int `json:"effectiveCapacity"`
+	// Free is the number of tokens in the queue, ready to be acquired.
+	Free int `json:"free"`
+	// Outstanding is the number of tokens held by in-flight calls. Only
+	// releases of those consume the reducers, so more reducers than
+	// outstanding tokens means capacity is stuck out of the rotation.
+	Outstanding int `json:"outstanding"`
+	// Waiters is the number of calls waiting for tokens.
+	Waiters int `json:"waiters"`
 }
 
 // NewBreaker creates a Breaker with the desired queue depth,
@@ -58,48 +261,343 @@ func NewBreaker(params BreakerParams) *Breaker {
 	if params.InitialCapacity < 0 || params.InitialCapacity > params.MaxConcurrency {
 		panic(fmt.Sprintf("Initial capacity must be between 0 and max concurrency. Got %v.", params.InitialCapacity))
 	}
+	if _, ok := queueDisciplineNames[params.Discipline]; !ok {
+		panic(fmt.Sprintf("Unknown queue discipline. Got %v.", params.Discipline))
+	}
 	sem := newSemaphore(params.MaxConcurrency, params.InitialCapacity)
+	sem.discipline = params.Discipline
+	sem.timeout = params.Timeout
+	sem.target, sem.interval = params.AdaptiveTarget, params.AdaptiveInterval
+	if sem.target <= 0 {
+		sem.target = DefaultAdaptiveTarget
+	}
+	if sem.interval <= 0 {
+		sem.interval = DefaultAdaptiveInterval
+	}
 	return &Breaker{
-		pendingRequests: make(chan struct{}, params.QueueDepth+params.MaxConcurrency),
-		sem:             sem,
+		totalSlots:     int64(params.QueueDepth + params.MaxConcurrency),
+		maxConcurrency: params.MaxConcurrency,
+		sem:            sem,
+		idle:           make(chan struct{}),
+		hooks:          params.Hooks,
+		overflow:       params.Overflow,
 	}
 }
 
+func (h BreakerHooks) enqueue(ctx context.Context) context.Context {
+	if h.OnEnqueue == nil {
+		return ctx
+	}
+	return h.OnEnqueue(ctx)
+}
+
+func (h BreakerHooks) dequeue(ctx context.Context, wait time.Duration, err error) {
+	if h.OnDequeue != nil {
+		h.OnDequeue(ctx, wait, err)
+	}
+}
+
+func (h BreakerHooks) reject(ctx context.Context) {
+	if h.OnReject != nil {
+		h.OnReject(ctx)
+	}
+}
+
+func (h BreakerHooks) complete(ctx context.Context, run time.Duration) {
+	if h.OnComplete != nil {
+		h.OnComplete(ctx, run)
+	}
+}
+
+// tryAcquirePending takes a slot in the pending request queue, if there
+// is one left.
+func (b *Breaker) tryAcquirePending() bool {
+	for {
+		cur := atomic.LoadInt64(&b.pending)
+		if cur >= atomic.LoadInt64(&b.totalSlots) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.pending, cur, cur+1) {
+			return true
+		}
+	}
+}
+
+// releasePending gives the slot in the pending request queue back.
+func (b *Breaker) releasePending() {
+	if atomic.AddInt64(&b.pending, -1) == 0 && atomic.LoadInt32(&b.draining) == 1 {
+		b.closeIdle()
+	}
+}
+
+// closeIdle wakes up Drain once the last pending call is gone.
+func (b *Breaker) closeIdle() {
+	b.idleOnce.Do(func() {
+		close(b.idle)
+	})
+}
+
 // Maybe conditionally executes thunk based on the Breaker concurrency
 // and queue parameters. If the concurrency limit and queue capacity are
-// already consumed, Maybe returns immediately without calling thunk. If
-// the thunk was executed, Maybe returns true, else false. Timeout is the
-// time before this function returns false without calling thunk. A 0
-// timeout value is infinite timeout.
-func (b *Breaker) Maybe(timeout time.Duration, thunk func()) bool {
+// already consumed, Maybe returns ErrQueueFull, or what the
+// Overflow of the BreakerParams returns, immediately without calling
+// thunk. If the thunk was executed, Maybe returns nil. Timeout is
+// the time before this function returns ErrAcquireTimeout without calling
+// thunk. A 0 timeout value is infinite timeout.
+func (b *Breaker) Maybe(timeout time.Duration, thunk func()) error {
+	return b.MaybeWithRelease(timeout, func(func()) {
+		thunk()
+	})
+}
+
+// MaybeWithRelease is like Maybe, but thunk is passed a function that gives
+// the concurrency token back before thunk returns, e.g. once the response
+// headers are committed and only the body is left to stream to a slow
+// client. The call still occupies its slot in the queue until thunk returns.
+// release may be called more than once.
+func (b *Breaker) MaybeWithRelease(timeout time.Duration, thunk func(release func())) error {
+	ctx := context.Background()
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return b.MaybeContextWithRelease(ctx, thunk)
+}
+
+// MaybeContext is like Maybe, but waits for capacity until ctx is done,
+// e.g. because the client went away or the deadline of its request passed,
+// which frees the slot in the queue right away. It returns
+// ErrQueueFull if the queue is full, ErrAcquireTimeout if the
+// deadline of ctx passes before thunk was called, the error of ctx if it
+// is canceled before, and nil once thunk returns.
+func (b *Breaker) MaybeContext(ctx context.Context, thunk func()) error {
+	return b.MaybeContextWithRelease(ctx, func(func()) {
+		thunk()
+	})
+}
+
+// MaybeContextWithRelease is like MaybeContext, but thunk is passed the
+// release function of MaybeWithRelease.
+func (b *Breaker) MaybeContextWithRelease(ctx context.Context, thunk func(release func())) error {
+	return b.maybe(ctx, PriorityNormal, 1, thunk)
+}
+
+// MaybeWithPriority is like MaybeContext, but lets thunk jump ahead of the
+// calls of a lower priority waiting for capacity. The calls still share
+// the same queue, so it is rejected as well when the queue is full.
+func (b *Breaker) MaybeWithPriority(ctx context.Context, priority Priority, thunk func()) error {
+	return b.maybe(ctx, priority, 1, func(func()) {
+		thunk()
+	})
+}
+
+// MaybeWithWeight is like MaybeContextWithRelease, but thunk takes weight
+// concurrency tokens at once, e.g. for an expensive stream or a batch of
+// requests. A call heavier than the current capacity takes all of it. The
+// call still takes a single slot in the queue. It returns ErrRequestWeight
+// if weight is less than 1.
+func (b *Breaker) MaybeWithWeight(ctx context.Context, weight int, thunk func(release func())) error {
+	if weight < 1 {
+		return ErrRequestWeight
+	}
+	return b.maybe(ctx, PriorityNormal, weight, thunk)
+}
+
+// Reserve takes a concurrency token if one is free right away, without
+// waiting in the queue or jumping ahead of the calls waiting there. It
+// returns the function giving the token back, which may be called more
+// than once, and whether a token was taken; it isn't if the queue is full,
+// no token is free or the breaker is draining. This lets callers probe the
+// capacity of several breakers without blocking a goroutine on each, e.g.
+// to pull requests to whichever backend has room. The hooks are not called
+// for reservations.
+func (b *Breaker) Reserve() (release func(), ok bool) {
+	if !b.tryAcquirePending() {
+		return nil, false
+	}
+	if err := b.sem.acquireNow(); err != nil {
+		b.releasePending()
+		return nil, false
+	}
+	atomic.AddInt64(&b.inFlight, 1)
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			atomic.AddInt64(&b.inFlight, -1)
+			b.sem.release()
+			b.updateState(context.Background())
+			b.releasePending()
+		})
+	}
+	if err := b.updateState(context.Background()); err != nil {
+		release()
+		return nil, false
+	}
+	return release, true
+}
+
+// Drain fails the calls waiting for capacity and all the calls made from
+// now on with ErrDraining, e.g. once the target of the breaker went away
+// or before shutting down. It then waits for the calls holding capacity
+// to run to completion, or returns the error of ctx if it is done first.
+// It returns the number of queued calls it shed, which is 0 when draining
+// again.
+func (b *Breaker) Drain(ctx context.Context) (shed int, err error) {
+	shed = b.drain()
 	select {
-	default:
+	case <-b.idle:
+		return shed, nil
+	case <-ctx.Done():
+		return shed, ctx.Err()
+	}
+}
+
+// drain is Drain without waiting for the calls holding capacity.
+func (b *Breaker) drain() int {
+	shed := b.sem.drain()
+	atomic.StoreInt32(&b.draining, 1)
+	if atomic.LoadInt64(&b.pending) == 0 {
+		b.closeIdle()
+	}
+	return shed
+}
+
+func (b *Breaker) maybe(ctx context.Context, priority Priority, weight int, thunk func(release func())) error {
+	if !b.tryAcquirePending() {
 		// Pending request queue is full.  Report failure.
-		return false
-	case b.pendingRequests <- struct{}{}:
-		// Pending request has capacity.
-		// Wait for capacity in the active queue.
-		if !b.sem.acquire(timeout) {
-			return false
+		atomic.AddInt64(&b.rejected, 1)
+		b.hooks.reject(ctx)
+		if b.overflow != nil {
+			return b.overflow(ctx)
 		}
-		// Defer releasing capacity in the active and pending request queue.
-		defer func() {
+		return ErrQueueFull
+	}
+
+	// Pending request has capacity.
+	// Wait for capacity in the active queue.
+	ctx = b.hooks.enqueue(ctx)
+	atomic.AddInt64(&b.queued, 1)
+	start := time.Now()
+	tokens, err := b.sem.acquireWeighted(ctx, priority, weight)
+	atomic.AddInt64(&b.queued, -1)
+	if err == context.DeadlineExceeded {
+		err = ErrAcquireTimeout
+	}
+	wait := time.Since(start)
+	b.hooks.dequeue(ctx, wait, err)
+	if err != nil {
+		b.releasePending()
+		return err
+	}
+	recordQueueWait(ctx, wait)
+	atomic.AddInt64(&b.inFlight, 1)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			atomic.AddInt64(&b.inFlight, -1)
 			// It's safe to ignore the error returned by release since we
 			// make sure the semaphore is only manipulated here and acquire
 			// + release calls are equally paired.
-			b.sem.release()
-			<-b.pendingRequests
-		}()
-		// Do the thing.
-		thunk()
-		// Report success
-		return true
+			b.sem.releaseN(tokens)
+			b.updateState(context.Background())
+		})
+	}
+	if err := b.updateState(ctx); err != nil {
+		release()
+		b.releasePending()
+		return err
+	}
+	// Defer releasing capacity in the active and pending request queue.
+	start = time.Now()
+	defer func() {
+		release()
+		b.releasePending()
+		run := time.Since(start)
+		b.observeServiceTime(run)
+		b.hooks.complete(ctx, run)
+	}()
+	// Do the thing.
+	thunk(release)
+	// Report success
+	return nil
+}
+
+// updateState reports to the OnStateChange hook if the breaker went from
+// no calls in-flight to some or back since it last did. The errors going
+// idle are dropped.
+func (b *Breaker) updateState(ctx context.Context) error {
+	if b.hooks.OnStateChange == nil {
+		return nil
+	}
+	b.stateMux.Lock()
+	defer b.stateMux.Unlock()
+	active := atomic.LoadInt64(&b.inFlight) > 0
+	if active == b.active {
+		return nil
+	}
+	if err := b.hooks.OnStateChange(ctx, active); err != nil && active {
+		return err
+	}
+	b.active = active
+	return nil
+}
+
+// observeServiceTime adds the run time of a thunk to the moving average.
+// The average is updated without synchronization, so concurrent updates
+// may be lost, which is fine for an estimate.
+func (b *Breaker) observeServiceTime(run time.Duration) {
+	old := atomic.LoadInt64(&b.serviceTime)
+	if old == 0 {
+		atomic.StoreInt64(&b.serviceTime, int64(run))
+		return
+	}
+	atomic.StoreInt64(&b.serviceTime, old+(int64(run)-old)/serviceTimeDecay)
+}
+
+// RetryAfter estimates how long it takes the queued calls to get through,
+// from the queue length and the average time the thunks run, as the calls
+// are served Capacity at a time. It's 0 before any thunk returned. It is
+// e.g. a hint for the clients of the calls rejected with ErrQueueFull
+// when to try again.
+func (b *Breaker) RetryAfter() time.Duration {
+	queued := atomic.LoadInt64(&b.queued)
+	capacity := int64(b.Capacity())
+	if capacity < 1 {
+		capacity = 1
 	}
+	return time.Duration((queued + 1) * atomic.LoadInt64(&b.serviceTime) / capacity)
 }
 
 // UpdateConcurrency updates the maximum number of in-flight requests.
+// As it is lowered, the queued calls that can't get through before their
+// deadline anymore, from the average time the thunks run, are failed with
+// ErrPreempted right away rather than left to time out.
 func (b *Breaker) UpdateConcurrency(size int) error {
-	return b.sem.updateCapacity(size)
+	lowered := size < b.sem.Capacity()
+	if err := b.sem.updateCapacity(size); err != nil {
+		return err
+	}
+	if lowered {
+		b.sem.preempt(time.Now(), time.Duration(atomic.LoadInt64(&b.serviceTime)))
+	}
+	return nil
+}
+
+// UpdateQueueDepth updates the number of requests that may wait for
+// capacity. The requests already queued beyond a lowered depth are kept,
+// and new ones rejected until the queue drained below it.
+func (b *Breaker) UpdateQueueDepth(size int) error {
+	if size <= 0 {
+		return ErrUpdateQueueDepth
+	}
+	atomic.StoreInt64(&b.totalSlots, int64(size+b.maxConcurrency))
+	return nil
+}
+
+// QueueDepth returns the number of requests that may wait for capacity.
+func (b *Breaker) QueueDepth() int {
+	return int(atomic.LoadInt64(&b.totalSlots)) - b.maxConcurrency
 }
 
 // Capacity returns the number of allowed in-flight requests on this breaker.
@@ -107,6 +605,25 @@ func (b *Breaker) Capacity() int {
 	return b.sem.Capacity()
 }
 
+// Stats returns a snapshot of the state of the breaker. The numbers are
+// read one after the other, so they may be slightly off from each other
+// while requests come and go.
+func (b *Breaker) Stats() BreakerStats {
+	return BreakerStats{
+		InFlight: int(atomic.LoadInt64(&b.inFlight)),
+		Queued:   int(atomic.LoadInt64(&b.queued)),
+		Capacity: b.Capacity(),
+		Rejected: atomic.LoadInt64(&b.rejected),
+	}
+}
+
+// SemaphoreStats returns a snapshot of the tokens of the semaphore of the
+// breaker, e.g. to diagnose capacity lost after rapid UpdateConcurrency
+// calls.
+func (b *Breaker) SemaphoreStats() SemaphoreStats {
+	return b.sem.stats()
+}
+
 // newSemaphore creates a semaphore with the desired maximal and initial capacity.
 // Maximal capacity is the size of the buffered channel, it defines maximum number of tokens
 // in the rotation. Attempting to add more capacity then the max will result in error.
@@ -132,21 +649,259 @@ type semaphore struct {
 	reducers int
 	capacity int
 	mux      sync.Mutex
+
+	// waiters are the acquires waiting for tokens, ordered by priority and
+	// then as the discipline says. Released tokens are handed to the first of
+	// them before going back to the queue, so the queue is empty while
+	// there are waiters, and only the first of them holds tokens. `mux`
+	// must be held to access them.
+	waiters []*waiter
+
+	// discipline orders the waiters of the same priority. target and
+	// interval tune QueueAdaptive, and standingSince is when the waiters
+	// last went from none to some.
+	discipline       QueueDiscipline
+	target, interval time.Duration
+	standingSince    time.Time
+
+	// drained fails the waiting and all new acquires with ErrDraining.
+	drained bool
+
+	// timeout is the deadline of the acquires without one, see
+	// BreakerParams.Timeout.
+	timeout func() time.Duration
 }
 
-// acquire receives the token from the semaphore, potentially blocking.
-func (s *semaphore) acquire(timeout time.Duration) bool {
-	tt := &time.Timer{}
-	if timeout != 0 {
-		tt = time.NewTimer(timeout)
-		defer tt.Stop()
-	}
+// waiter is an acquire waiting to be handed its tokens.
+type waiter struct {
+	priority Priority
+	weight   int
+	// got is the number of tokens handed to the waiter so far.
+	got   int
+	ready chan struct{}
+	// deadline is when the acquire fails if it didn't get its tokens,
+	// zero if never.
+	deadline time.Time
+	// failed is the error the waiter is failed with by drain or preempt.
+	failed error
+}
 
+// acquire receives the token from the semaphore, potentially blocking
+// until ctx is done.
+func (s *semaphore) acquire(ctx context.Context) error {
+	_, err := s.acquireWeighted(ctx, PriorityNormal, 1)
+	return err
+}
+
+// acquireNow receives a token from the semaphore if one is available,
+// without blocking, or returns ErrAcquireTimeout. Tokens are only available
+// while nobody waits for one.
+func (s *semaphore) acquireNow() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.drained {
+		return ErrDraining
+	}
 	select {
 	case <-s.queue:
-		return true
-	case <-tt.C:
-		return false
+		return nil
+	default:
+		return ErrAcquireTimeout
+	}
+}
+
+// acquirePriority is like acquire, but the acquires of a priority above
+// PriorityNormal are handed the released tokens first.
+func (s *semaphore) acquirePriority(ctx context.Context, priority Priority) error {
+	_, err := s.acquireWeighted(ctx, priority, 1)
+	return err
+}
+
+// acquireWeighted is like acquirePriority, but acquires weight tokens at
+// once. An acquire heavier than the current capacity takes all of it
+// instead of waiting for the capacity to be raised. It returns the number
+// of tokens acquired, which must be given back with releaseN.
+//
+// Acquires that can't be served right away wait in the waiters. An
+// acquire whose ctx is done by the time it would be handed its tokens
+// fails, and leaves the waiters before the tokens are handed on, so that
+// it never holds on to tokens it doesn't need anymore.
+func (s *semaphore) acquireWeighted(ctx context.Context, priority Priority, weight int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mux.Lock()
+	if s.drained {
+		s.mux.Unlock()
+		return 0, ErrDraining
+	}
+	if len(s.waiters) == 0 {
+		// Nobody waits, so the free tokens are ours to take.
+		if need := s.needWeight(weight); len(s.queue) >= need {
+			for i := 0; i < need; i++ {
+				<-s.queue
+			}
+			s.mux.Unlock()
+			return need, nil
+		}
+	}
+	w := &waiter{priority: priority, weight: weight, ready: make(chan struct{}, 1)}
+	now := time.Now()
+	if d, ok := ctx.Deadline(); ok {
+		w.deadline = d
+	} else if s.timeout != nil {
+		if t := s.timeout(); t > 0 {
+			w.deadline = now.Add(t)
+		}
+	}
+	if len(s.waiters) == 0 {
+		// Hold on to the free tokens while waiting for the rest.
+		for ; len(s.queue) > 0; w.got++ {
+			<-s.queue
+		}
+	}
+	if len(s.waiters) == 0 {
+		s.standingSince = now
+	}
+	standing := s.discipline == QueueAdaptive && now.Sub(s.standingSince) > s.interval
+	// Wait behind the waiters of a higher priority, and of the same one
+	// unless serving LIFO.
+	i := len(s.waiters)
+	for i > 0 && s.waiters[i-1].priority < priority {
+		i--
+	}
+	if s.discipline == QueueLIFO || standing {
+		for i > 0 && s.waiters[i-1].priority == priority {
+			i--
+		}
+	}
+	s.waiters = append(s.waiters, nil)
+	copy(s.waiters[i+1:], s.waiters[i:])
+	s.waiters[i] = w
+	if i == 0 && len(s.waiters) > 1 {
+		// Only the first waiter is handed the released tokens, so take
+		// over the ones the waiter we cut ahead of got so far, up to what
+		// we need. Otherwise neither of us may ever get enough of them.
+		prev := s.waiters[1]
+		n := s.need(w) - w.got
+		if n > prev.got {
+			n = prev.got
+		}
+		w.got += n
+		prev.got -= n
+	}
+	// A new first waiter may already hold enough tokens.
+	s.handOut()
+	s.mux.Unlock()
+
+	if standing {
+		// Shed the calls that can't get through quickly while the queue
+		// doesn't drain.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.target)
+		defer cancel()
+	}
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	// Even if the tokens arrived, ctx may have been done first.
+	err := ctx.Err()
+	if err == nil {
+		err = w.failed
+	}
+	if err != nil {
+		for i, other := range s.waiters {
+			if other == w {
+				s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+				break
+			}
+		}
+		// Pass on the tokens handed to us in the meantime. A waiter that
+		// got all of its tokens isn't in waiters anymore either.
+		for ; w.got > 0; w.got-- {
+			s.releaseLocked()
+		}
+		return 0, err
+	}
+	return w.got, nil
+}
+
+// drain fails the waiters and all new acquires with ErrDraining, and
+// returns the number of waiters it failed. The tokens handed to the
+// waiters go back to the queue as they leave.
+func (s *semaphore) drain() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.drained = true
+	shed := len(s.waiters)
+	for _, w := range s.waiters {
+		w.failed = ErrDraining
+		w.ready <- struct{}{}
+	}
+	s.waiters = nil
+	return shed
+}
+
+// preempt fails the waiters with ErrPreempted that can't be handed their
+// tokens before their deadline anymore, and returns the number of them.
+// The wait of each is estimated from the tokens needed by the waiters
+// ahead of it that are kept, as the effective capacity is freed every
+// serviceTime. Nothing is estimated without a capacity or serviceTime.
+func (s *semaphore) preempt(now time.Time, serviceTime time.Duration) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	capacity := s.effectiveCapacity()
+	if capacity < 1 || serviceTime <= 0 {
+		return 0
+	}
+	preempted, ahead := 0, 0
+	kept := s.waiters[:0]
+	for _, w := range s.waiters {
+		need := s.need(w)
+		wait := time.Duration(ahead+need) * serviceTime / time.Duration(capacity)
+		if !w.deadline.IsZero() && now.Add(wait).After(w.deadline) {
+			w.failed = ErrPreempted
+			w.ready <- struct{}{}
+			preempted++
+			continue
+		}
+		kept = append(kept, w)
+		ahead += need
+	}
+	for i := len(kept); i < len(s.waiters); i++ {
+		s.waiters[i] = nil
+	}
+	s.waiters = kept
+	return preempted
+}
+
+// need returns the number of tokens the waiter needs to proceed: its
+// weight, but no more than the capacity. `mux` must be held to call it.
+func (s *semaphore) need(w *waiter) int {
+	return s.needWeight(w.weight)
+}
+
+// needWeight is need for an acquire of the given weight.
+func (s *semaphore) needWeight(weight int) int {
+	if c := s.effectiveCapacity(); c > 0 && c < weight {
+		return c
+	}
+	return weight
+}
+
+// handOut lets the first waiters proceed as long as they got the tokens
+// they need. `mux` must be held to call it.
+func (s *semaphore) handOut() {
+	for len(s.waiters) > 0 && s.waiters[0].got >= s.need(s.waiters[0]) {
+		w := s.waiters[0]
+		s.waiters = s.waiters[1:]
+		w.ready <- struct{}{}
 	}
 }
 
@@ -156,20 +911,52 @@ func (s *semaphore) acquire(timeout time.Duration) bool {
 func (s *semaphore) release() error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
+	return s.releaseLocked()
+}
 
+// releaseN releases n tokens, as acquired by acquireWeighted.
+func (s *semaphore) releaseN(n int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i := 0; i < n; i++ {
+		if err := s.releaseLocked(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseLocked is release with `mux` held.
+func (s *semaphore) releaseLocked() error {
 	if s.reducers > 0 {
 		s.capacity--
 		s.reducers--
+		// The first waiter may need fewer tokens now.
+		s.handOut()
 		return nil
 	}
 
+	if !s.put() {
+		// This only happens if release is called more often than acquire.
+		return ErrRelease
+	}
+	return nil
+}
+
+// put hands a token to the first waiter, or puts it to the queue. It
+// returns false if the queue is full. `mux` must be held to call it.
+func (s *semaphore) put() bool {
+	if len(s.waiters) > 0 {
+		s.waiters[0].got++
+		s.handOut()
+		return true
+	}
 	// We want to make sure releasing a token is always non-blocking.
 	select {
 	case s.queue <- struct{}{}:
-		return nil
+		return true
 	default:
-		// This only happens if release is called more often than acquire.
-		return ErrRelease
+		return false
 	}
 }
 
@@ -192,16 +979,13 @@ func (s *semaphore) updateCapacity(size int) error {
 	for s.effectiveCapacity() < size {
 		if s.reducers > 0 {
 			s.reducers--
+		} else if s.put() {
+			s.capacity++
 		} else {
-			select {
-			case s.queue <- struct{}{}:
-				s.capacity++
-			default:
-				// This indicates that we're operating close to
-				// MaxCapacity and returned more tokens than we
-				// acquired.
-				return ErrUpdateCapacity
-			}
+			// This indicates that we're operating close to
+			// MaxCapacity and returned more tokens than we
+			// acquired.
+			return ErrUpdateCapacity
 		}
 	}
 
@@ -216,6 +1000,8 @@ func (s *semaphore) updateCapacity(size int) error {
 			s.reducers++
 		}
 	}
+	// The first waiter may need fewer tokens now.
+	s.handOut()
 
 	return nil
 }
@@ -226,6 +1012,28 @@ func (s *semaphore) effectiveCapacity() int {
 	return s.capacity - s.reducers
 }
 
+// stats returns a snapshot of the tokens of the semaphore.
+func (s *semaphore) stats() SemaphoreStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// The tokens handed to waiters so far are held by neither the queue
+	// nor in-flight calls.
+	handed := 0
+	for _, w := range s.waiters {
+		handed += w.got
+	}
+	return SemaphoreStats{
+		MaxCapacity:       cap(s.queue),
+		Capacity:          s.capacity,
+		Reducers:          s.reducers,
+		EffectiveCapacity: s.effectiveCapacity(),
+		Free:              len(s.queue),
+		Outstanding:       s.capacity - len(s.queue) - handed,
+		Waiters:           len(s.waiters),
+	}
+}
+
 // Capacity is the effective capacity after taking reducers into
 // account.
 func (s *semaphore) Capacity() int {
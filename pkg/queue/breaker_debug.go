@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// breakerDebug is the state of a Breaker served by BreakerDebugHandler.
+type breakerDebug struct {
+	InFlight  int            `json:"inFlight"`
+	Queued    int            `json:"queued"`
+	Capacity  int            `json:"capacity"`
+	Rejected  int64          `json:"rejected"`
+	Semaphore SemaphoreStats `json:"semaphore"`
+}
+
+// BreakerDebugHandler serves the state of b as JSON: its stats and the
+// tokens of its semaphore, for operators to diagnose capacity stuck after
+// rapid UpdateConcurrency oscillations while autoscaling.
+func BreakerDebugHandler(b *Breaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := b.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(breakerDebug{
+			InFlight:  stats.InFlight,
+			Queued:    stats.Queued,
+			Capacity:  stats.Capacity,
+			Rejected:  stats.Rejected,
+			Semaphore: b.SemaphoreStats(),
+		})
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBreakerDebugHandler(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 2, InitialCapacity: 2})
+	release, ok := b.Reserve()
+	if !ok {
+		t.Fatal("Reserve() = false, want: true")
+	}
+	defer release()
+	b.UpdateConcurrency(0)
+
+	rec := httptest.NewRecorder()
+	BreakerDebugHandler(b)(rec, httptest.NewRequest(http.MethodGet, RequestQueueBreakerPath, nil))
+
+	if got, want := rec.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+	var got map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode the response: %v", err)
+	}
+	want := map[string]interface{}{
+		"inFlight": 1.0,
+		"queued":   0.0,
+		"capacity": 0.0,
+		"rejected": 0.0,
+		"semaphore": map[string]interface{}{
+			"maxCapacity":       2.0,
+			"capacity":          1.0,
+			"reducers":          1.0,
+			"effectiveCapacity": 0.0,
+			"free":              0.0,
+			"outstanding":       1.0,
+			"waiters":           0.0,
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Response (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,486 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+var (
+	breakerStressDuration = flag.Duration("breaker-stress-duration", 200*time.Millisecond,
+		"How long TestBreakerStress hammers the Breaker. Run it with -race and e.g. -args -breaker-stress-duration=1m to look for races.")
+	semaphorePropertyCount = flag.Int("semaphore-property-count", 2000,
+		"How many random operation sequences TestSemaphoreProperties checks. Raise it with e.g. -args -semaphore-property-count=1000000 to fuzz the semaphore.")
+)
+
+// settleTimeout bounds the wait for an acquire to return or to wait, so
+// that a lost hand-out fails the test instead of hanging it.
+const settleTimeout = 5 * time.Second
+
+// acquireResult is what acquireWeighted returned.
+type acquireResult struct {
+	tokens int
+	err    error
+}
+
+// pendingAcquire is an acquire waiting for its tokens.
+type pendingAcquire struct {
+	// seq is the order the acquire was made in.
+	seq    int
+	cancel context.CancelFunc
+	done   chan acquireResult
+}
+
+// semaphoreHarness makes the acquires of a semaphore in goroutines and
+// keeps track of the tokens they hold, so that the invariants of the
+// semaphore can be checked between operations.
+type semaphoreHarness struct {
+	sem *semaphore
+	// held are the tokens of each acquire that got them and didn't release
+	// them yet.
+	held []int
+	// waiting are the acquires in the waiters of sem.
+	waiting map[*waiter]*pendingAcquire
+	seq     int
+}
+
+// acquire acquires weight tokens, and returns once the acquire either got
+// them or waits for them.
+func (h *semaphoreHarness) acquire(priority Priority, weight int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pendingAcquire{seq: h.seq, cancel: cancel, done: make(chan acquireResult, 1)}
+	h.seq++
+	go func() {
+		tokens, err := h.sem.acquireWeighted(ctx, priority, weight)
+		p.done <- acquireResult{tokens, err}
+	}()
+
+	for deadline := time.Now().Add(settleTimeout); time.Now().Before(deadline); runtime.Gosched() {
+		select {
+		case r := <-p.done:
+			cancel()
+			if r.err != nil {
+				return fmt.Errorf("acquireWeighted(%d) = %v", weight, r.err)
+			}
+			h.held = append(h.held, r.tokens)
+			return nil
+		default:
+		}
+		if w := h.newWaiter(); w != nil {
+			h.waiting[w] = p
+			return nil
+		}
+	}
+	cancel()
+	return fmt.Errorf("acquireWeighted(%d) neither returned nor waits", weight)
+}
+
+// newWaiter returns the waiter of the semaphore not known yet, if any.
+func (h *semaphoreHarness) newWaiter() *waiter {
+	h.sem.mux.Lock()
+	defer h.sem.mux.Unlock()
+	for _, w := range h.sem.waiters {
+		if _, ok := h.waiting[w]; !ok {
+			return w
+		}
+	}
+	return nil
+}
+
+// pending returns the waiting acquires in the order they were made.
+func (h *semaphoreHarness) pending() []*waiter {
+	ws := make([]*waiter, 0, len(h.waiting))
+	for w := range h.waiting {
+		ws = append(ws, w)
+	}
+	sort.Slice(ws, func(i, j int) bool { return h.waiting[ws[i]].seq < h.waiting[ws[j]].seq })
+	return ws
+}
+
+// settle collects the tokens of the waiting acquires that were handed them.
+func (h *semaphoreHarness) settle() error {
+	h.sem.mux.Lock()
+	waiting := make(map[*waiter]bool, len(h.sem.waiters))
+	for _, w := range h.sem.waiters {
+		waiting[w] = true
+	}
+	h.sem.mux.Unlock()
+
+	for _, w := range h.pending() {
+		if waiting[w] {
+			continue
+		}
+		p := h.waiting[w]
+		delete(h.waiting, w)
+		select {
+		case r := <-p.done:
+			p.cancel()
+			if r.err != nil {
+				return fmt.Errorf("acquire %d handed its tokens = %v", p.seq, r.err)
+			}
+			h.held = append(h.held, r.tokens)
+		case <-time.After(settleTimeout):
+			return fmt.Errorf("acquire %d handed its tokens didn't return", p.seq)
+		}
+	}
+	return nil
+}
+
+// cancel cancels the waiting acquire w.
+func (h *semaphoreHarness) cancel(w *waiter) error {
+	p := h.waiting[w]
+	delete(h.waiting, w)
+	p.cancel()
+	select {
+	case r := <-p.done:
+		if r.err != context.Canceled {
+			return fmt.Errorf("canceled acquire %d = %d, %v, want: %v", p.seq, r.tokens, r.err, context.Canceled)
+		}
+	case <-time.After(settleTimeout):
+		return fmt.Errorf("canceled acquire %d didn't return", p.seq)
+	}
+	return h.settle()
+}
+
+// release releases the tokens of the i-th acquire that holds some.
+func (h *semaphoreHarness) release(i int) error {
+	tokens := h.held[i]
+	h.held = append(h.held[:i], h.held[i+1:]...)
+	if err := h.sem.releaseN(tokens); err != nil {
+		return fmt.Errorf("releaseN(%d) = %v", tokens, err)
+	}
+	return h.settle()
+}
+
+// heldTokens returns the number of tokens the acquires hold.
+func (h *semaphoreHarness) heldTokens() int {
+	n := 0
+	for _, tokens := range h.held {
+		n += tokens
+	}
+	return n
+}
+
+// checkOrder checks that the waiters of the same priority are ordered as
+// the discipline says. QueueAdaptive orders them either way.
+func (h *semaphoreHarness) checkOrder(discipline QueueDiscipline) error {
+	if discipline == QueueAdaptive {
+		return nil
+	}
+	h.sem.mux.Lock()
+	defer h.sem.mux.Unlock()
+	for i := 1; i < len(h.sem.waiters); i++ {
+		prev, w := h.sem.waiters[i-1], h.sem.waiters[i]
+		if prev.priority != w.priority {
+			continue
+		}
+		if newer := h.waiting[w].seq > h.waiting[prev].seq; newer != (discipline == QueueFIFO) {
+			return fmt.Errorf("%v waiters %d and %d are out of order", discipline, h.waiting[prev].seq, h.waiting[w].seq)
+		}
+	}
+	return nil
+}
+
+// close cancels the waiting acquires and releases all the tokens.
+func (h *semaphoreHarness) close() error {
+	for _, w := range h.pending() {
+		if _, ok := h.waiting[w]; !ok {
+			// Handed its tokens when another was canceled.
+			continue
+		}
+		if err := h.cancel(w); err != nil {
+			return err
+		}
+	}
+	for len(h.held) > 0 {
+		if err := h.release(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fuzzSemaphore interprets data as a sequence of operations on a
+// semaphore and checks its invariants after each of them.
+//
+// The first byte chooses the maximal capacity in its lowest four bits and
+// the queue discipline in the others, the second one the initial capacity.
+// Each of the following bytes is an operation modulo 5 and an argument,
+// the byte divided by 5.
+func fuzzSemaphore(data []byte) (err error) {
+	if len(data) < 2 {
+		return nil
+	}
+	maxCapacity := int(data[0] % 16)
+	discipline := QueueDiscipline(data[0] / 16 % 3)
+	capacity := int(data[1]) % (maxCapacity + 1)
+	sem := newSemaphore(maxCapacity, capacity)
+	sem.discipline = discipline
+	// Go LIFO as soon as two acquires wait, but never shed them.
+	sem.target, sem.interval = time.Hour, 0
+
+	h := &semaphoreHarness{sem: sem, waiting: make(map[*waiter]*pendingAcquire)}
+	defer func() {
+		if cerr := h.close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close: %v", cerr)
+		}
+	}()
+	for i, op := range data[2:] {
+		arg := int(op / 5)
+		switch op % 5 {
+		case 0:
+			// Acquire up to 4 tokens, of a normal or high priority.
+			if err := h.acquire(PriorityNormal+Priority(arg/4%2), 1+arg%4); err != nil {
+				return fmt.Errorf("op %d: %v", i, err)
+			}
+		case 1:
+			// Release the tokens of an acquire, if one holds some.
+			if len(h.held) > 0 {
+				if err := h.release(arg % len(h.held)); err != nil {
+					return fmt.Errorf("op %d: %v", i, err)
+				}
+			}
+		case 2:
+			// Update the capacity, beyond the maximum on purpose.
+			size := arg % (maxCapacity + 2)
+			err := sem.updateCapacity(size)
+			switch {
+			case size > maxCapacity && err != ErrUpdateCapacity:
+				return fmt.Errorf("op %d: updateCapacity(%d) = %v, want: %v", i, size, err, ErrUpdateCapacity)
+			case size <= maxCapacity && err != nil:
+				return fmt.Errorf("op %d: updateCapacity(%d) = %v", i, size, err)
+			case err == nil:
+				capacity = size
+			}
+			if err := h.settle(); err != nil {
+				return fmt.Errorf("op %d: %v", i, err)
+			}
+		case 3:
+			// Release all the held tokens.
+			for len(h.held) > 0 {
+				if err := h.release(0); err != nil {
+					return fmt.Errorf("op %d: %v", i, err)
+				}
+			}
+		case 4:
+			// Cancel a waiting acquire, if there is one.
+			if ws := h.pending(); len(ws) > 0 {
+				if err := h.cancel(ws[arg%len(ws)]); err != nil {
+					return fmt.Errorf("op %d: %v", i, err)
+				}
+			}
+		}
+		if err := checkSemaphore(sem, capacity, h.heldTokens()); err != nil {
+			return fmt.Errorf("op %d (%#x): %v", i, op, err)
+		}
+		if err := h.checkOrder(discipline); err != nil {
+			return fmt.Errorf("op %d (%#x): %v", i, op, err)
+		}
+	}
+	return nil
+}
+
+// checkSemaphore checks the invariants of a semaphore with the given
+// capacity, of which held tokens are acquired and not released yet.
+func checkSemaphore(sem *semaphore, capacity, held int) error {
+	sem.mux.Lock()
+	defer sem.mux.Unlock()
+
+	// The tokens handed to the waiters so far are held too.
+	for i, w := range sem.waiters {
+		held += w.got
+		if i > 0 && w.priority > sem.waiters[i-1].priority {
+			return fmt.Errorf("waiter %d of priority %d is behind one of priority %d", i, w.priority, sem.waiters[i-1].priority)
+		}
+	}
+	switch free := len(sem.queue); {
+	case sem.effectiveCapacity() != capacity:
+		return fmt.Errorf("effective capacity = %d, want: %d", sem.effectiveCapacity(), capacity)
+	case sem.reducers < 0 || sem.reducers > sem.capacity || sem.capacity > cap(sem.queue):
+		return fmt.Errorf("want 0 <= reducers (%d) <= capacity (%d) <= max capacity (%d)", sem.reducers, sem.capacity, cap(sem.queue))
+	case free+held != sem.capacity:
+		return fmt.Errorf("free (%d) + held (%d) tokens != capacity (%d)", free, held, sem.capacity)
+	case sem.reducers > held:
+		return fmt.Errorf("reducers (%d) > held tokens (%d)", sem.reducers, held)
+	case sem.reducers > 0 && free > 0:
+		return fmt.Errorf("%d reducers with %d free tokens", sem.reducers, free)
+	case len(sem.waiters) > 0 && free > 0:
+		return fmt.Errorf("%d waiters with %d free tokens", len(sem.waiters), free)
+	case len(sem.waiters) > 0 && sem.waiters[0].got >= sem.need(sem.waiters[0]):
+		return fmt.Errorf("first waiter holds %d of the %d tokens it needs", sem.waiters[0].got, sem.need(sem.waiters[0]))
+	}
+	return nil
+}
+
+func TestSemaphoreProperties(t *testing.T) {
+	var failure error
+	check := func(data []byte) bool {
+		if err := fuzzSemaphore(data); err != nil {
+			failure = err
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: *semaphorePropertyCount}); err != nil {
+		t.Errorf("Semaphore invariant violated: %v, %v", failure, err)
+	}
+}
+
+func TestSemaphorePropertiesRegressions(t *testing.T) {
+	const (
+		acquire    = 0
+		release    = 1
+		update     = 2
+		releaseAll = 3
+		cancel     = 4
+		// arg is the multiplier of the argument of an operation.
+		arg = 5
+		// lifo and adaptive choose the discipline in the first byte.
+		lifo     = 16 * byte(QueueLIFO)
+		adaptive = 16 * byte(QueueAdaptive)
+		// weight2 and high are the arguments of an acquire of 2 tokens,
+		// and of one of a high priority.
+		weight2 = 1
+		high    = 4
+	)
+	for _, data := range [][]byte{
+		// Reduce the capacity while all tokens are held, then raise it again
+		// before releasing them.
+		{4, 4, acquire, acquire, acquire, acquire, update + 1*arg, update + 3*arg, releaseAll},
+		// Reduce the capacity to zero with tokens held and free.
+		{4, 4, acquire, acquire, update, release, release, acquire},
+		// Go beyond the maximal capacity, and wait for a token.
+		{2, 0, update + 3*arg, update + 2*arg, acquire, acquire, acquire, releaseAll},
+		// A high priority acquire cuts ahead of a heavier one, which is
+		// canceled while holding a token.
+		{lifo | 2, 2, acquire, acquire, acquire + weight2*arg, acquire + high*arg, release, release, cancel},
+		// Waiters go LIFO once they stand, and need fewer tokens as the
+		// capacity goes down.
+		{adaptive | 3, 3, acquire + 2*arg, acquire + weight2*arg, acquire + weight2*arg, release, update + 1*arg, releaseAll},
+	} {
+		if err := fuzzSemaphore(data); err != nil {
+			t.Errorf("fuzzSemaphore(%v) = %v", data, err)
+		}
+	}
+}
+
+func TestBreakerStress(t *testing.T) {
+	const (
+		maxConcurrency = 5
+		workers        = 20
+	)
+	seed := time.Now().UnixNano()
+	t.Logf("Stressing the Breaker for %v with seed %d", *breakerStressDuration, seed)
+
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: maxConcurrency, InitialCapacity: 1})
+	var running, maxRunning, accepted int64
+	thunk := func(rnd *rand.Rand) func() {
+		return func() {
+			n := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+			for {
+				m := atomic.LoadInt64(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+					break
+				}
+			}
+			atomic.AddInt64(&accepted, 1)
+			time.Sleep(time.Duration(rnd.Intn(200)) * time.Microsecond)
+		}
+	}
+
+	deadline := time.Now().Add(*breakerStressDuration)
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		rnd := rand.New(rand.NewSource(seed + int64(i)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rnd.Intn(2000))*time.Microsecond)
+				var err error
+				switch rnd.Intn(7) {
+				case 0, 1:
+					err = b.MaybeContext(ctx, thunk(rnd))
+				case 2:
+					err = b.MaybeWithPriority(ctx, PriorityHigh, thunk(rnd))
+				case 3:
+					err = b.MaybeContextWithRelease(ctx, func(release func()) {
+						thunk(rnd)()
+						release()
+						// The token is given back, so this doesn't count as running.
+						time.Sleep(time.Duration(rnd.Intn(100)) * time.Microsecond)
+					})
+				case 4:
+					err = b.UpdateConcurrency(rnd.Intn(maxConcurrency + 1))
+				case 5:
+					err = b.UpdateQueueDepth(1 + rnd.Intn(20))
+				case 6:
+					// Heavier than the max concurrency on purpose.
+					err = b.MaybeWithWeight(ctx, 1+rnd.Intn(maxConcurrency+1), func(func()) {
+						thunk(rnd)()
+					})
+				}
+				cancel()
+				switch err {
+				case nil, ErrQueueFull, ErrAcquireTimeout, ErrPreempted, context.Canceled:
+				default:
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&maxRunning); got > maxConcurrency {
+		t.Errorf("Max concurrently running calls = %d, want <= %d", got, maxConcurrency)
+	}
+	if atomic.LoadInt64(&accepted) == 0 {
+		t.Error("No call went through")
+	}
+
+	// Once everything settled, the breaker must be back to a clean state.
+	const final = 3
+	if err := b.UpdateConcurrency(final); err != nil {
+		t.Fatalf("UpdateConcurrency(%d) = %v", final, err)
+	}
+	if err := checkSemaphore(b.sem, final, 0); err != nil {
+		t.Errorf("Semaphore invariant violated: %v", err)
+	}
+	if got := b.Stats(); got.InFlight != 0 || got.Queued != 0 || got.Capacity != final {
+		t.Errorf("Stats() = %+v, want no calls and a capacity of %d", got, final)
+	}
+	if got := pending(b); got != 0 {
+		t.Errorf("pending = %d, want: 0", got)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerPoolReporter is notified about the lifecycle of the breakers in a
+// BreakerPool.
+type BreakerPoolReporter interface {
+	// BreakerCreated is called after a breaker was created for key. size is
+	// the number of breakers in the pool afterwards.
+	BreakerCreated(key interface{}, size int)
+	// BreakerRemoved is called after the breaker of key was removed from
+	// the pool. idle is true if it was evicted for not being used.
+	BreakerRemoved(key interface{}, size int, idle bool)
+}
+
+// BreakerPool is a concurrency safe collection of Breakers created on demand
+// for a key, e.g. a revision. Breakers that were not used for the idle
+// timeout and have no requests in flight or queued are evicted by Run.
+type BreakerPool struct {
+	params      BreakerParams
+	idleTimeout time.Duration
+	reporter    BreakerPoolReporter
+
+	mux      sync.Mutex
+	breakers map[interface{}]*pooledBreaker
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+type pooledBreaker struct {
+	breaker  *Breaker
+	lastUsed time.Time
+}
+
+// NewBreakerPool creates a BreakerPool whose breakers are created with
+// params. An idleTimeout of 0 disables eviction. reporter may be nil.
+func NewBreakerPool(params BreakerParams, idleTimeout time.Duration, reporter BreakerPoolReporter) *BreakerPool {
+	return &BreakerPool{
+		params:      params,
+		idleTimeout: idleTimeout,
+		reporter:    reporter,
+		breakers:    make(map[interface{}]*pooledBreaker),
+		now:         time.Now,
+	}
+}
+
+// GetOrCreate returns the breaker for key, creating it if needed, and marks
+// it as used. The returned boolean is true if the breaker already existed.
+func (p *BreakerPool) GetOrCreate(key interface{}) (*Breaker, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	pb, ok := p.breakers[key]
+	if !ok {
+		pb = &pooledBreaker{breaker: NewBreaker(p.params)}
+		p.breakers[key] = pb
+		if p.reporter != nil {
+			p.reporter.BreakerCreated(key, len(p.breakers))
+		}
+	}
+	pb.lastUsed = p.now()
+	return pb.breaker, ok
+}
+
+// Get returns the breaker for key, if there is one.
+func (p *BreakerPool) Get(key interface{}) (*Breaker, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if pb, ok := p.breakers[key]; ok {
+		return pb.breaker, true
+	}
+	return nil, false
+}
+
+// Remove deletes the breaker for key from the pool. The breaker is
+// drained, so that the calls waiting for it fail with ErrDraining rather
+// than wait for capacity it won't get anymore. Remove doesn't wait for the
+// calls holding capacity.
+func (p *BreakerPool) Remove(key interface{}) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	pb, ok := p.breakers[key]
+	if !ok {
+		return
+	}
+	pb.breaker.drain()
+	delete(p.breakers, key)
+	if p.reporter != nil {
+		p.reporter.BreakerRemoved(key, len(p.breakers), false /*idle*/)
+	}
+}
+
+// Len returns the number of breakers in the pool.
+func (p *BreakerPool) Len() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return len(p.breakers)
+}
+
+// UpdateAll sets the concurrency of every breaker in the pool to the value
+// capacity returns for its key. The breakers for which capacity or the
+// update fail are left untouched and the errors are returned by key.
+func (p *BreakerPool) UpdateAll(capacity func(key interface{}) (int, error)) map[interface{}]error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var errs map[interface{}]error
+	for key, pb := range p.breakers {
+		c, err := capacity(key)
+		if err == nil {
+			err = pb.breaker.UpdateConcurrency(c)
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make(map[interface{}]error)
+			}
+			errs[key] = err
+		}
+	}
+	return errs
+}
+
+// EvictIdle removes the breakers that were not used for the idle timeout and
+// have no requests in flight or queued, and returns how many were removed.
+func (p *BreakerPool) EvictIdle() int {
+	if p.idleTimeout == 0 {
+		return 0
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	now := p.now()
+	evicted := 0
+	for key, pb := range p.breakers {
+		if now.Sub(pb.lastUsed) < p.idleTimeout || atomic.LoadInt64(&pb.breaker.pending) > 0 {
+			continue
+		}
+		delete(p.breakers, key)
+		evicted++
+		if p.reporter != nil {
+			p.reporter.BreakerRemoved(key, len(p.breakers), true /*idle*/)
+		}
+	}
+	return evicted
+}
+
+// Run evicts idle breakers periodically until stopCh is closed.
+// It returns immediately if eviction is disabled.
+func (p *BreakerPool) Run(stopCh <-chan struct{}) {
+	if p.idleTimeout == 0 {
+		return
+	}
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			p.EvictIdle()
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type poolEvent struct {
+	key     interface{}
+	size    int
+	removed bool
+	idle    bool
+}
+
+type fakePoolReporter struct {
+	events []poolEvent
+}
+
+func (r *fakePoolReporter) BreakerCreated(key interface{}, size int) {
+	r.events = append(r.events, poolEvent{key: key, size: size})
+}
+
+func (r *fakePoolReporter) BreakerRemoved(key interface{}, size int, idle bool) {
+	r.events = append(r.events, poolEvent{key: key, size: size, removed: true, idle: idle})
+}
+
+var poolParams = BreakerParams{QueueDepth: 1, MaxConcurrency: 10, InitialCapacity: 0}
+
+func TestBreakerPoolGetOrCreate(t *testing.T) {
+	reporter := &fakePoolReporter{}
+	p := NewBreakerPool(poolParams, 0, reporter)
+
+	a, existed := p.GetOrCreate("a")
+	if existed {
+		t.Error("GetOrCreate(a) existed = true for a new key")
+	}
+	if again, existed := p.GetOrCreate("a"); !existed || again != a {
+		t.Errorf("GetOrCreate(a) = %p, %v, want: %p, true", again, existed, a)
+	}
+	if got, ok := p.Get("a"); !ok || got != a {
+		t.Errorf("Get(a) = %p, %v, want: %p, true", got, ok, a)
+	}
+	if _, ok := p.Get("b"); ok {
+		t.Error("Get(b) = true for a missing key")
+	}
+	p.GetOrCreate("b")
+	if got, want := p.Len(), 2; got != want {
+		t.Errorf("Len = %d, want: %d", got, want)
+	}
+
+	p.Remove("a")
+	p.Remove("a")
+	if got, want := p.Len(), 1; got != want {
+		t.Errorf("Len = %d, want: %d", got, want)
+	}
+	// The calls still holding on to a removed breaker fail.
+	if got, want := a.Maybe(0, func() {}), ErrDraining; got != want {
+		t.Errorf("Maybe() on a removed breaker = %v, want: %v", got, want)
+	}
+
+	want := []poolEvent{{key: "a", size: 1}, {key: "b", size: 2}, {key: "a", size: 1, removed: true}}
+	if diff := cmp.Diff(want, reporter.events, cmp.AllowUnexported(poolEvent{})); diff != "" {
+		t.Errorf("Reported events (-want, +got):\n%s", diff)
+	}
+}
+
+func TestBreakerPoolUpdateAll(t *testing.T) {
+	p := NewBreakerPool(poolParams, 0, nil)
+	for _, k := range []string{"a", "b", "c"} {
+		p.GetOrCreate(k)
+	}
+	errBoom := errors.New("boom")
+	errs := p.UpdateAll(func(key interface{}) (int, error) {
+		switch key {
+		case "a":
+			return 5, nil
+		case "b":
+			return 0, errBoom
+		}
+		return poolParams.MaxConcurrency + 1, nil
+	})
+
+	if got, want := errs, map[interface{}]error{"b": errBoom, "c": ErrUpdateCapacity}; !cmp.Equal(got, want, cmp.Comparer(func(a, b error) bool { return a == b })) {
+		t.Errorf("UpdateAll errors = %v, want: %v", got, want)
+	}
+	for k, want := range map[string]int{"a": 5, "b": 0, "c": 0} {
+		b, _ := p.Get(k)
+		if got := b.Capacity(); got != want {
+			t.Errorf("Capacity(%s) = %d, want: %d", k, got, want)
+		}
+	}
+}
+
+func TestBreakerPoolEvictIdle(t *testing.T) {
+	reporter := &fakePoolReporter{}
+	p := NewBreakerPool(poolParams, time.Minute, reporter)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	p.GetOrCreate("idle")
+	busy, _ := p.GetOrCreate("busy")
+	now = now.Add(30 * time.Second)
+	p.GetOrCreate("recent")
+
+	// A request is queued on the busy breaker, since it has no capacity.
+	busy.tryAcquirePending()
+	defer busy.releasePending()
+
+	now = now.Add(30 * time.Second)
+	if got, want := p.EvictIdle(), 1; got != want {
+		t.Errorf("EvictIdle = %d, want: %d", got, want)
+	}
+	for k, want := range map[string]bool{"idle": false, "busy": true, "recent": true} {
+		if _, got := p.Get(k); got != want {
+			t.Errorf("Get(%s) = %v, want: %v", k, got, want)
+		}
+	}
+	if got, want := reporter.events[len(reporter.events)-1], (poolEvent{key: "idle", size: 2, removed: true, idle: true}); got != want {
+		t.Errorf("Last event = %+v, want: %+v", got, want)
+	}
+}
+
+func TestBreakerPoolEvictionDisabled(t *testing.T) {
+	p := NewBreakerPool(poolParams, 0, nil)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	p.GetOrCreate("a")
+
+	now = now.Add(time.Hour)
+	if got := p.EvictIdle(); got != 0 {
+		t.Errorf("EvictIdle = %d, want: 0", got)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	done := make(chan struct{})
+	go func() {
+		p.Run(stopCh)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Run didn't return with eviction disabled")
+	}
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package queue
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -61,6 +64,9 @@ func TestBreakerInvalidConstructor(t *testing.T) {
 	}, {
 		"InitialCapacity out-of-bounds",
 		BreakerParams{QueueDepth: 1, MaxConcurrency: 5, InitialCapacity: 6},
+	}, {
+		"Discipline unknown",
+		BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1, Discipline: QueueAdaptive + 1},
 	}}
 
 	for _, test := range tests {
@@ -212,6 +218,926 @@ func TestBreaker_Timeout(t *testing.T) {
 	}
 }
 
+func TestBreakerMaybeErrors(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0}
+	b := NewBreaker(params)
+
+	// Without capacity, a queued request times out.
+	if got, want := b.Maybe(time.Millisecond, func() {}), ErrAcquireTimeout; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+
+	// Once the queue is full, requests are rejected right away.
+	reqs := b.concurrentRequests(slots(b), 0)
+	if got, want := b.Maybe(0, func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+
+	// Let the queued requests through.
+	for _, r := range reqs {
+		close(r.barrier)
+	}
+	b.UpdateConcurrency(1)
+	for _, r := range reqs {
+		r.wait()
+	}
+}
+
+func TestBreakerMaybeWithRelease(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	released := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.MaybeWithRelease(0, func(release func()) {
+			release()
+			release() // Releasing twice must not add capacity.
+			close(released)
+			<-finish
+		})
+	}()
+	<-released
+
+	// The token was given back, so another request can run while the
+	// first one is still in progress.
+	if err := b.Maybe(semAcquireTimeout, func() {}); err != nil {
+		t.Errorf("Maybe() = %v after the token was released, want: nil", err)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeWithRelease() = %v, want: nil", err)
+	}
+	if got, want := b.Capacity(), 1; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+	if got, want := len(b.sem.queue), 1; got != want {
+		t.Errorf("Available tokens = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerMaybeContext(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	// Queue a request and cancel it, like a client going away.
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error)
+	go func() {
+		queued <- b.MaybeContext(ctx, func() {
+			t.Error("thunk of a canceled request was called")
+		})
+	}()
+	waitForPending(b, 2)
+	cancel()
+	if got, want := <-queued, context.Canceled; got != want {
+		t.Errorf("MaybeContext() = %v, want: %v", got, want)
+	}
+
+	// The canceled request gave its slot in the queue back.
+	if got, want := pending(b), 1; got != want {
+		t.Errorf("pending = %d, want: %d", got, want)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if got, want := b.MaybeContext(ctx, func() {}), ErrAcquireTimeout; got != want {
+		t.Errorf("MaybeContext() = %v, want: %v", got, want)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	if got, want := pending(b), 0; got != want {
+		t.Errorf("pending = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerMaybeContextQueueFull(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0}
+	b := NewBreaker(params)
+
+	// Fill the pending request queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < slots(b); i++ {
+		go b.MaybeContext(ctx, func() {})
+	}
+	waitForPending(b, slots(b))
+
+	if got, want := b.MaybeContext(context.Background(), func() {}), ErrQueueFull; got != want {
+		t.Errorf("MaybeContext() = %v, want: %v", got, want)
+	}
+}
+
+func TestBreakerOverflow(t *testing.T) {
+	errOverflow := errors.New("overflowed")
+	var overflowed []string
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0,
+		Overflow: func(ctx context.Context) error {
+			overflowed = append(overflowed, ctx.Value(hookCallKey{}).(string))
+			return errOverflow
+		}}
+	b := NewBreaker(params)
+
+	// Fill the pending request queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < slots(b); i++ {
+		go b.MaybeContext(ctx, func() {})
+	}
+	waitForPending(b, slots(b))
+
+	// The rejected request is overflowed rather than failed.
+	ctx = context.WithValue(context.Background(), hookCallKey{}, "rejected")
+	if got, want := b.MaybeContext(ctx, func() {
+		t.Error("Thunk of a rejected request was called")
+	}), errOverflow; got != want {
+		t.Errorf("MaybeContext() = %v, want: %v", got, want)
+	}
+	if got, want := overflowed, []string{"rejected"}; !cmp.Equal(got, want) {
+		t.Errorf("Overflowed = %v, want: %v", got, want)
+	}
+	if got, want := b.Stats().Rejected, int64(1); got != want {
+		t.Errorf("Stats().Rejected = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerMaybeWithPriority(t *testing.T) {
+	params := BreakerParams{QueueDepth: 2, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	// Queue a regular request before a high priority one.
+	order := make(chan string, 2)
+	go b.MaybeContext(context.Background(), func() {
+		order <- "normal"
+	})
+	waitForPending(b, 2)
+	go b.MaybeWithPriority(context.Background(), PriorityHigh, func() {
+		order <- "high"
+	})
+	waitForWaiters(b.sem, 2)
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	for _, want := range []string{"high", "normal"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Request %q went through, want: %q", got, want)
+			}
+		case <-time.After(semAcquireTimeout):
+			t.Fatalf("Timed out waiting for the %q request", want)
+		}
+	}
+}
+
+func TestBreakerReserve(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 2, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	release, ok := b.Reserve()
+	if !ok {
+		t.Fatal("Reserve() = false, want: true")
+	}
+	if got, want := b.Stats().InFlight, 1; got != want {
+		t.Errorf("InFlight = %d, want: %d", got, want)
+	}
+	if _, ok := b.Reserve(); ok {
+		t.Error("Reserve() without a free token = true, want: false")
+	}
+
+	// A reservation doesn't jump ahead of the queued calls.
+	done := make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {})
+	}()
+	waitForPending(b, 2)
+	if _, ok := b.Reserve(); ok {
+		t.Error("Reserve() with a queued call = true, want: false")
+	}
+
+	release()
+	release() // Releasing twice must not add capacity.
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	waitForPending(b, 0)
+
+	release, ok = b.Reserve()
+	if !ok {
+		t.Fatal("Reserve() after the token was given back = false, want: true")
+	}
+	release()
+	if got, want := len(b.sem.queue), 1; got != want {
+		t.Errorf("Available tokens = %d, want: %d", got, want)
+	}
+
+	// A failed reservation gives its slot in the queue back.
+	full := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 0, InitialCapacity: 0})
+	if _, ok := full.Reserve(); ok {
+		t.Error("Reserve() without capacity = true, want: false")
+	}
+	if got, want := pending(full), 0; got != want {
+		t.Errorf("pending = %d after a failed reservation, want: %d", got, want)
+	}
+
+	// Without a slot in the queue, the reservation is rejected.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go full.MaybeContext(ctx, func() {})
+	waitForPending(full, 1)
+	if _, ok := full.Reserve(); ok {
+		t.Error("Reserve() with a full queue = true, want: false")
+	}
+}
+
+func TestBreakerDrain(t *testing.T) {
+	params := BreakerParams{QueueDepth: 2, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	queued := make(chan error)
+	go func() {
+		queued <- b.MaybeContext(context.Background(), func() {
+			t.Error("Thunk of a drained call was called")
+		})
+	}()
+	waitForWaiters(b.sem, 1)
+
+	// Drain gives up waiting for the call holding capacity once ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if shed, err := b.Drain(ctx); shed != 1 || err != context.DeadlineExceeded {
+		t.Errorf("Drain() = %d, %v, want: 1, %v", shed, err, context.DeadlineExceeded)
+	}
+	if got, want := <-queued, ErrDraining; got != want {
+		t.Errorf("Queued MaybeContext() = %v, want: %v", got, want)
+	}
+	if got, want := b.MaybeContext(context.Background(), func() {}), ErrDraining; got != want {
+		t.Errorf("MaybeContext() after Drain() = %v, want: %v", got, want)
+	}
+	if _, ok := b.Reserve(); ok {
+		t.Error("Reserve() after Drain() = true, want: false")
+	}
+
+	// The call holding capacity runs to completion, which draining again
+	// waits for.
+	drained := make(chan error)
+	go func() {
+		_, err := b.Drain(context.Background())
+		drained <- err
+	}()
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Drain() = %v, want: nil", err)
+	}
+	waitForPending(b, 0)
+}
+
+func TestBreakerDrainIdle(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	if shed, err := b.Drain(context.Background()); shed != 0 || err != nil {
+		t.Errorf("Drain() = %d, %v, want: 0, nil", shed, err)
+	}
+}
+
+func TestBreakerPreempt(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout func() time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+	}{{
+		name: "context deadline",
+		ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 2500*time.Millisecond)
+		},
+	}, {
+		name:    "breaker timeout",
+		timeout: func() time.Duration { return 2500 * time.Millisecond },
+		ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4, Timeout: test.timeout})
+			// The thunks take a second.
+			b.observeServiceTime(time.Second)
+
+			var releases []func()
+			for i := 0; i < 4; i++ {
+				release, ok := b.Reserve()
+				if !ok {
+					t.Fatal("Reserve() = false, want: true")
+				}
+				releases = append(releases, release)
+			}
+			errs := make(chan error, 4)
+			for i := 0; i < 4; i++ {
+				go func() {
+					ctx, cancel := test.ctx()
+					defer cancel()
+					errs <- b.MaybeContext(ctx, func() {})
+				}()
+			}
+			waitForWaiters(b.sem, 4)
+
+			// At 4 calls a second, all of the queued calls get through in time.
+			b.UpdateConcurrency(3)
+			if got := b.Stats().Queued; got != 4 {
+				t.Fatalf("Queued = %d, want: 4", got)
+			}
+
+			// At 1 call a second, only the first 2 do.
+			b.UpdateConcurrency(1)
+			for i := 0; i < 2; i++ {
+				if err := <-errs; err != ErrPreempted {
+					t.Errorf("MaybeContext() = %v, want: %v", err, ErrPreempted)
+				}
+			}
+			for _, release := range releases {
+				release()
+			}
+			for i := 0; i < 2; i++ {
+				if err := <-errs; err != nil {
+					t.Errorf("MaybeContext() = %v, want: nil", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerPreemptUnknownServiceTime(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 2, InitialCapacity: 2})
+	release, ok := b.Reserve()
+	if !ok {
+		t.Fatal("Reserve() = false, want: true")
+	}
+	defer release()
+	b.UpdateConcurrency(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- b.MaybeContext(ctx, func() {})
+	}()
+	waitForWaiters(b.sem, 1)
+
+	// Even past the deadline, nothing is preempted without an estimate.
+	later := time.Now().Add(2 * time.Hour)
+	if got := b.sem.preempt(later, 0); got != 0 {
+		t.Errorf("preempt() = %d, want: 0", got)
+	}
+	if got := b.sem.preempt(later, time.Second); got != 1 {
+		t.Errorf("preempt() = %d, want: 1", got)
+	}
+	if err := <-done; err != ErrPreempted {
+		t.Errorf("MaybeContext() = %v, want: %v", err, ErrPreempted)
+	}
+}
+
+func TestQueueDiscipline(t *testing.T) {
+	for _, d := range []QueueDiscipline{QueueFIFO, QueueLIFO, QueueAdaptive} {
+		if got, err := ParseQueueDiscipline(d.String()); err != nil || got != d {
+			t.Errorf("ParseQueueDiscipline(%q) = %v, %v, want: %v, nil", d.String(), got, err, d)
+		}
+	}
+	if got, err := ParseQueueDiscipline(""); err != nil || got != QueueFIFO {
+		t.Errorf("ParseQueueDiscipline(\"\") = %v, %v, want: %v, nil", got, err, QueueFIFO)
+	}
+	if _, err := ParseQueueDiscipline("fifo"); err == nil {
+		t.Error("ParseQueueDiscipline(\"fifo\") = nil, want an error")
+	}
+	if got, want := (QueueAdaptive + 1).String(), "QueueDiscipline(3)"; got != want {
+		t.Errorf("String() = %q, want: %q", got, want)
+	}
+}
+
+func TestBreakerLIFO(t *testing.T) {
+	params := BreakerParams{QueueDepth: 3, MaxConcurrency: 1, InitialCapacity: 1, Discipline: QueueLIFO}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		go b.MaybeContext(context.Background(), func() {
+			order <- i
+		})
+		waitForWaiters(b.sem, i+1)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	// The newest request goes through first.
+	for _, want := range []int{2, 1, 0} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Request %d went through, want: %d", got, want)
+			}
+		case <-time.After(semAcquireTimeout):
+			t.Fatalf("Timed out waiting for request %d", want)
+		}
+	}
+}
+
+func TestBreakerAdaptive(t *testing.T) {
+	params := BreakerParams{
+		QueueDepth:       2,
+		MaxConcurrency:   1,
+		InitialCapacity:  1,
+		Discipline:       QueueAdaptive,
+		AdaptiveTarget:   10 * time.Millisecond,
+		AdaptiveInterval: 20 * time.Millisecond,
+	}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	// The first request queues while the queue is still fresh, so it
+	// waits for as long as it takes.
+	first := make(chan error)
+	go func() {
+		first <- b.MaybeContext(context.Background(), func() {})
+	}()
+	waitForWaiters(b.sem, 1)
+
+	// Once the queue stood for the interval, newer requests are shed
+	// after the target.
+	time.Sleep(2 * params.AdaptiveInterval)
+	if err := b.MaybeContext(context.Background(), func() {}); err != ErrAcquireTimeout {
+		t.Errorf("MaybeContext() = %v, want: %v", err, ErrAcquireTimeout)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	if err := <-first; err != nil {
+		t.Errorf("First queued MaybeContext() = %v, want: nil", err)
+	}
+
+	// Once drained, the queue is fresh again.
+	running, finish = make(chan struct{}), make(chan struct{})
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+	go func() {
+		first <- b.MaybeContext(context.Background(), func() {})
+	}()
+	waitForWaiters(b.sem, 1)
+	time.Sleep(2 * params.AdaptiveTarget)
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	if err := <-first; err != nil {
+		t.Errorf("Queued MaybeContext() = %v, want: nil", err)
+	}
+}
+
+func TestBreakerMaybeWithWeight(t *testing.T) {
+	params := BreakerParams{QueueDepth: 2, MaxConcurrency: 2, InitialCapacity: 2}
+	b := NewBreaker(params)
+
+	if got, want := b.MaybeWithWeight(context.Background(), 0, func(func()) {}), ErrRequestWeight; got != want {
+		t.Errorf("MaybeWithWeight(0) = %v, want: %v", got, want)
+	}
+
+	// A call of weight 2 takes all the capacity.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeWithWeight(context.Background(), 2, func(func()) {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), semNoChangeTimeout)
+	defer cancel()
+	if got, want := b.MaybeContext(ctx, func() {
+		t.Error("Call went through while the weighted call holds all the capacity")
+	}), ErrAcquireTimeout; got != want {
+		t.Errorf("MaybeContext() = %v, want: %v", got, want)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeWithWeight(2) = %v, want: nil", err)
+	}
+	if got, want := len(b.sem.queue), 2; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+type hookCallKey struct{}
+
+// hookRecorder records the phases of the calls named by their contexts.
+type hookRecorder struct {
+	mux    sync.Mutex
+	phases map[string][]string
+}
+
+func (r *hookRecorder) record(ctx context.Context, phase string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	name, _ := ctx.Value(hookCallKey{}).(string)
+	r.phases[name] = append(r.phases[name], phase)
+}
+
+func (r *hookRecorder) hooks() BreakerHooks {
+	return BreakerHooks{
+		OnEnqueue: func(ctx context.Context) context.Context {
+			r.record(ctx, "enqueue")
+			// The later hooks get this context.
+			name, _ := ctx.Value(hookCallKey{}).(string)
+			return context.WithValue(ctx, hookCallKey{}, name+"-traced")
+		},
+		OnDequeue: func(ctx context.Context, _ time.Duration, err error) {
+			if err != nil {
+				r.record(ctx, "dequeue: "+err.Error())
+				return
+			}
+			r.record(ctx, "dequeue")
+		},
+		OnReject: func(ctx context.Context) {
+			r.record(ctx, "reject")
+		},
+		OnComplete: func(ctx context.Context, _ time.Duration) {
+			r.record(ctx, "complete")
+		},
+	}
+}
+
+func TestBreakerHooks(t *testing.T) {
+	rec := &hookRecorder{phases: map[string][]string{}}
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1, Hooks: rec.hooks()}
+	b := NewBreaker(params)
+	call := func(name string) context.Context {
+		return context.WithValue(context.Background(), hookCallKey{}, name)
+	}
+
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error, 2)
+	go func() {
+		done <- b.MaybeContext(call("running"), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	ctx, cancel := context.WithTimeout(call("timeout"), semNoChangeTimeout)
+	defer cancel()
+	if got, want := b.MaybeContext(ctx, func() {}), ErrAcquireTimeout; got != want {
+		t.Errorf("MaybeContext(timeout) = %v, want: %v", got, want)
+	}
+	go func() {
+		done <- b.MaybeContext(call("queued"), func() {})
+	}()
+	waitForPending(b, 2)
+	if got, want := b.MaybeContext(call("rejected"), func() {}), ErrQueueFull; got != want {
+		t.Errorf("MaybeContext(rejected) = %v, want: %v", got, want)
+	}
+	close(finish)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("MaybeContext() = %v, want: nil", err)
+		}
+	}
+
+	want := map[string][]string{
+		"running":        {"enqueue"},
+		"running-traced": {"dequeue", "complete"},
+		"timeout":        {"enqueue"},
+		"timeout-traced": {"dequeue: " + ErrAcquireTimeout.Error()},
+		"queued":         {"enqueue"},
+		"queued-traced":  {"dequeue", "complete"},
+		"rejected":       {"reject"},
+	}
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+	if !cmp.Equal(rec.phases, want) {
+		t.Errorf("Hook calls (-want,+got): %s", cmp.Diff(want, rec.phases))
+	}
+}
+
+func TestBreakerStateChange(t *testing.T) {
+	var (
+		mux    sync.Mutex
+		states []bool
+	)
+	params := BreakerParams{QueueDepth: 2, MaxConcurrency: 2, InitialCapacity: 2, Hooks: BreakerHooks{
+		OnStateChange: func(_ context.Context, active bool) error {
+			mux.Lock()
+			defer mux.Unlock()
+			states = append(states, active)
+			return nil
+		},
+	}}
+	b := NewBreaker(params)
+
+	// Overlapping calls make a single transition each way.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+	if err := b.MaybeContext(context.Background(), func() {}); err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+
+	// Releasing the token early counts as going idle, and so do reservations.
+	if err := b.MaybeContextWithRelease(context.Background(), func(release func()) {
+		release()
+		mux.Lock()
+		defer mux.Unlock()
+		if got, want := len(states), 4; got != want {
+			t.Errorf("Got %d state changes after release, want: %d", got, want)
+		}
+	}); err != nil {
+		t.Errorf("MaybeContextWithRelease() = %v, want: nil", err)
+	}
+	release, ok := b.Reserve()
+	if !ok {
+		t.Fatal("Reserve() = false, want: true")
+	}
+	release()
+
+	mux.Lock()
+	defer mux.Unlock()
+	if got, want := states, []bool{true, false, true, false, true, false}; !cmp.Equal(got, want) {
+		t.Errorf("State changes = %v, want: %v", got, want)
+	}
+}
+
+func TestBreakerRetryAfter(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 2, MaxConcurrency: 2, InitialCapacity: 0})
+	if got := b.RetryAfter(); got != 0 {
+		t.Errorf("RetryAfter() without service times = %v, want: 0", got)
+	}
+
+	b.observeServiceTime(100 * time.Millisecond)
+	b.observeServiceTime(180 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- b.MaybeContext(ctx, func() {})
+	}()
+	waitForWaiters(b.sem, 1)
+
+	// The queued call and the next one take the moving average each.
+	if got, want := b.RetryAfter(), 220*time.Millisecond; got != want {
+		t.Errorf("RetryAfter() = %v, want: %v", got, want)
+	}
+	// Once served, the next call only waits for its share of the
+	// capacity, of the average lowered by the quick call.
+	if err := b.UpdateConcurrency(2); err != nil {
+		t.Fatalf("UpdateConcurrency() = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("MaybeContext() = %v", err)
+	}
+	cancel()
+	if got, max := b.RetryAfter(), 55*time.Millisecond; got <= 0 || got >= max {
+		t.Errorf("RetryAfter() = %v, want within (0, %v)", got, max)
+	}
+}
+
+func TestBreakerQueueWait(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+
+	// Occupy the only concurrency slot.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+
+	ctx, wait := WithQueueWait(context.Background())
+	seen := make(chan time.Duration, 1)
+	queued := make(chan error)
+	go func() {
+		queued <- b.MaybeContext(ctx, func() {
+			seen <- *wait
+		})
+	}()
+	waitForPending(b, 2)
+	time.Sleep(semNoChangeTimeout)
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+	if err := <-queued; err != nil {
+		t.Errorf("Queued MaybeContext() = %v, want: nil", err)
+	}
+	if got := <-seen; got < semNoChangeTimeout {
+		t.Errorf("Queue wait in the thunk = %v, want at least %v", got, semNoChangeTimeout)
+	}
+
+	// Without a wait recorder, calls go through all the same.
+	if err := b.MaybeContext(context.Background(), func() {}); err != nil {
+		t.Errorf("MaybeContext() = %v, want: nil", err)
+	}
+}
+
+func TestBreakerStats(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+
+	// Occupy the only concurrency slot and queue a request behind it.
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error, 2)
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+	go func() {
+		done <- b.MaybeContext(context.Background(), func() {})
+	}()
+	waitForPending(b, 2)
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.Stats().Queued == 1, nil
+	}); err != nil {
+		t.Fatal("Timed out waiting for the request to be queued")
+	}
+	if got, want := b.Maybe(0, func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+
+	want := BreakerStats{InFlight: 1, Queued: 1, Capacity: 1, Rejected: 1}
+	if got := b.Stats(); !cmp.Equal(got, want) {
+		t.Errorf("Stats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+
+	close(finish)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("MaybeContext() = %v, want: nil", err)
+		}
+	}
+	want = BreakerStats{InFlight: 0, Queued: 0, Capacity: 1, Rejected: 1}
+	if got := b.Stats(); !cmp.Equal(got, want) {
+		t.Errorf("Stats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+}
+
+func TestBreakerSemaphoreStats(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 4, InitialCapacity: 2}
+	b := NewBreaker(params)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, ok := b.Reserve()
+		if !ok {
+			t.Fatal("Reserve() = false, want: true")
+		}
+		releases = append(releases, release)
+	}
+
+	// Both tokens are held, so taking them out needs reducers.
+	b.UpdateConcurrency(0)
+	want := SemaphoreStats{MaxCapacity: 4, Capacity: 2, Reducers: 2, Outstanding: 2}
+	if got := b.SemaphoreStats(); !cmp.Equal(got, want) {
+		t.Errorf("SemaphoreStats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+
+	// Adding capacity back consumes the reducers first.
+	b.UpdateConcurrency(3)
+	want = SemaphoreStats{MaxCapacity: 4, Capacity: 3, EffectiveCapacity: 3, Free: 1, Outstanding: 2}
+	if got := b.SemaphoreStats(); !cmp.Equal(got, want) {
+		t.Errorf("SemaphoreStats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+
+	releases[0]()
+	want = SemaphoreStats{MaxCapacity: 4, Capacity: 3, EffectiveCapacity: 3, Free: 2, Outstanding: 1}
+	if got := b.SemaphoreStats(); !cmp.Equal(got, want) {
+		t.Errorf("SemaphoreStats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+	releases[1]()
+}
+
+func TestBreakerUpdateQueueDepth(t *testing.T) {
+	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0}
+	b := NewBreaker(params)
+
+	// Fill the queue.
+	reqs := b.concurrentRequests(slots(b), 0)
+	if got, want := b.Maybe(0, func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+
+	// A deeper queue takes another request.
+	if err := b.UpdateQueueDepth(2); err != nil {
+		t.Fatalf("UpdateQueueDepth() = %v", err)
+	}
+	if got, want := b.QueueDepth(), 2; got != want {
+		t.Errorf("QueueDepth = %d, want: %d", got, want)
+	}
+	reqs = append(reqs, b.concurrentRequest(0))
+
+	// A shallower queue keeps the queued requests, but takes no new ones.
+	if err := b.UpdateQueueDepth(1); err != nil {
+		t.Fatalf("UpdateQueueDepth() = %v", err)
+	}
+	if got, want := b.Maybe(0, func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+	if got, want := pending(b), 3; got != want {
+		t.Errorf("pending = %d, want: %d", got, want)
+	}
+
+	if got, want := b.UpdateQueueDepth(0), ErrUpdateQueueDepth; got != want {
+		t.Errorf("UpdateQueueDepth(0) = %v, want: %v", got, want)
+	}
+
+	// Let the queued requests through.
+	for _, r := range reqs {
+		close(r.barrier)
+	}
+	b.UpdateConcurrency(1)
+	for _, r := range reqs {
+		if !<-r.accepted {
+			t.Error("Queued request was rejected")
+		}
+	}
+}
+
 func TestBreaker_UpdateConcurrency_Overlow(t *testing.T) {
 	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0}
 	b := NewBreaker(params)
@@ -261,9 +1187,285 @@ func TestSemaphore_acquire_HasCapacity(t *testing.T) {
 	}
 }
 
+func TestSemaphore_acquirePriority(t *testing.T) {
+	sem := newSemaphore(1, 1)
+	sem.acquire(context.Background())
+
+	order := make(chan string, 4)
+	go func() {
+		sem.acquirePriority(context.Background(), PriorityNormal)
+		order <- "normal"
+	}()
+	waitForWaiters(sem, 1)
+	for i, w := range []struct {
+		name     string
+		priority Priority
+	}{
+		{"high-1", PriorityHigh},
+		{"high-2", PriorityHigh},
+		{"highest", PriorityHigh + 1},
+	} {
+		w := w
+		go func() {
+			sem.acquirePriority(context.Background(), w.priority)
+			order <- w.name
+		}()
+		waitForWaiters(sem, i+2)
+	}
+
+	// Every release lets the next acquire through: the highest priority
+	// first, and the same priority in order of arrival.
+	for _, want := range []string{"highest", "high-1", "high-2", "normal"} {
+		sem.release()
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Acquire %q went through, want: %q", got, want)
+			}
+		case <-time.After(semAcquireTimeout):
+			t.Fatalf("Timed out waiting for acquire %q", want)
+		}
+	}
+}
+
+func TestSemaphore_acquirePriority_Canceled(t *testing.T) {
+	sem := newSemaphore(1, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- sem.acquirePriority(ctx, PriorityHigh)
+	}()
+	waitForWaiters(sem, 1)
+	cancel()
+	if got, want := <-errCh, context.Canceled; got != want {
+		t.Errorf("acquirePriority = %v, want: %v", got, want)
+	}
+
+	// Without waiters the token goes back to the queue.
+	waitForWaiters(sem, 0)
+	sem.release()
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquire_ContextDone(t *testing.T) {
+	sem := newSemaphore(1, 1)
+
+	// A done context fails the acquire even with a free token.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, want := sem.acquire(ctx), context.Canceled; got != want {
+		t.Errorf("acquire() = %v, want: %v", got, want)
+	}
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquire_Timeout(t *testing.T) {
+	sem := newSemaphore(1, 1)
+	sem.acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if got, want := sem.acquire(ctx), context.DeadlineExceeded; got != want {
+		t.Errorf("acquire() = %v, want: %v", got, want)
+	}
+
+	// The timed out waiter is gone, so the token goes back to the queue.
+	waitForWaiters(sem, 0)
+	sem.release()
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquire_CanceledWhileHandedOut(t *testing.T) {
+	sem := newSemaphore(1, 1)
+	sem.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- sem.acquire(ctx)
+	}()
+	waitForWaiters(sem, 1)
+
+	// Hand the token to the waiter after its context is done, before it
+	// gets to run.
+	sem.mux.Lock()
+	cancel()
+	sem.releaseLocked()
+	sem.mux.Unlock()
+
+	if got, want := <-errCh, context.Canceled; got != want {
+		t.Errorf("acquire() = %v, want: %v", got, want)
+	}
+	// The token was passed on rather than kept by the canceled waiter.
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquireWeighted(t *testing.T) {
+	sem := newSemaphore(3, 3)
+	if got, err := sem.acquireWeighted(context.Background(), PriorityNormal, 2); err != nil || got != 2 {
+		t.Fatalf("acquireWeighted(2) = %d, %v, want: 2, nil", got, err)
+	}
+
+	// The only free token is handed to the next weighted acquire, which
+	// waits for another one.
+	gotCh := make(chan int)
+	go func() {
+		got, _ := sem.acquireWeighted(context.Background(), PriorityNormal, 2)
+		gotCh <- got
+	}()
+	waitForWaiters(sem, 1)
+	if got, want := len(sem.queue), 0; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+
+	sem.releaseN(2)
+	select {
+	case got := <-gotCh:
+		if got != 2 {
+			t.Errorf("acquireWeighted(2) = %d, want: 2", got)
+		}
+	case <-time.After(semAcquireTimeout):
+		t.Fatal("Timed out waiting for the weighted acquire")
+	}
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquireWeighted_CutAhead(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		discipline QueueDiscipline
+		priority   Priority
+	}{{
+		name:       "LIFO",
+		discipline: QueueLIFO,
+		priority:   PriorityNormal,
+	}, {
+		name:       "higher priority",
+		discipline: QueueFIFO,
+		priority:   PriorityHigh,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			sem := newSemaphore(2, 2)
+			sem.discipline = tc.discipline
+			sem.acquire(context.Background())
+
+			// The first waiter holds the only free token while waiting
+			// for another one.
+			firstCh := make(chan int)
+			go func() {
+				got, _ := sem.acquireWeighted(context.Background(), PriorityNormal, 2)
+				firstCh <- got
+			}()
+			waitForWaiters(sem, 1)
+
+			// The waiter cutting ahead of it takes over that token.
+			secondCh := make(chan int)
+			go func() {
+				got, _ := sem.acquireWeighted(context.Background(), tc.priority, 2)
+				secondCh <- got
+			}()
+			waitForWaiters(sem, 2)
+
+			sem.release()
+			select {
+			case got := <-secondCh:
+				if got != 2 {
+					t.Errorf("Second acquireWeighted(2) = %d, want: 2", got)
+				}
+			case <-time.After(semAcquireTimeout):
+				t.Fatal("Timed out waiting for the acquire that cut ahead")
+			}
+
+			sem.releaseN(2)
+			select {
+			case got := <-firstCh:
+				if got != 2 {
+					t.Errorf("First acquireWeighted(2) = %d, want: 2", got)
+				}
+			case <-time.After(semAcquireTimeout):
+				t.Fatal("Timed out waiting for the first acquire")
+			}
+		})
+	}
+}
+
+func TestSemaphore_acquireWeighted_AboveCapacity(t *testing.T) {
+	sem := newSemaphore(5, 2)
+	if got, err := sem.acquireWeighted(context.Background(), PriorityNormal, 4); err != nil || got != 2 {
+		t.Errorf("acquireWeighted(4) = %d, %v, want: 2, nil", got, err)
+	}
+}
+
+func TestSemaphore_acquireWeighted_CapacityReduced(t *testing.T) {
+	sem := newSemaphore(4, 4)
+	sem.acquireWeighted(context.Background(), PriorityNormal, 3)
+
+	gotCh := make(chan int)
+	go func() {
+		got, _ := sem.acquireWeighted(context.Background(), PriorityNormal, 4)
+		gotCh <- got
+	}()
+	waitForWaiters(sem, 1)
+
+	// The waiter already holds all the tokens it needs after the reduction.
+	if err := sem.updateCapacity(1); err != nil {
+		t.Fatalf("updateCapacity(1) = %v", err)
+	}
+	select {
+	case got := <-gotCh:
+		if got != 1 {
+			t.Errorf("acquireWeighted(4) = %d, want: 1", got)
+		}
+	case <-time.After(semAcquireTimeout):
+		t.Fatal("Timed out waiting for the weighted acquire")
+	}
+
+	sem.releaseN(3)
+	sem.releaseN(1)
+	if got, want := sem.Capacity(), 1; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
+func TestSemaphore_acquireWeighted_Canceled(t *testing.T) {
+	sem := newSemaphore(2, 2)
+	sem.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		_, err := sem.acquireWeighted(ctx, PriorityNormal, 2)
+		errCh <- err
+	}()
+	waitForWaiters(sem, 1)
+	cancel()
+	if got, want := <-errCh, context.Canceled; got != want {
+		t.Errorf("acquireWeighted = %v, want: %v", got, want)
+	}
+
+	// The token handed to the canceled acquire goes back to the queue.
+	if got, want := len(sem.queue), 1; got != want {
+		t.Errorf("len(queue) = %d, want: %d", got, want)
+	}
+}
+
 func TestSemaphore_release(t *testing.T) {
 	sem := newSemaphore(1, 1)
-	sem.acquire(0)
+	sem.acquire(context.Background())
 	if err := sem.release(); err != nil {
 		t.Errorf("release = %v; want: %v", err, nil)
 	}
@@ -276,8 +1478,8 @@ func TestSemaphore_releasesSeveralReducers(t *testing.T) {
 	const wantAfterFirstrelease = 1
 	const wantAfterSecondrelease = 0
 	sem := newSemaphore(2, 2)
-	sem.acquire(0)
-	sem.acquire(0)
+	sem.acquire(context.Background())
+	sem.acquire(context.Background())
 	sem.updateCapacity(0)
 	sem.release()
 	if got := sem.Capacity(); got != wantAfterSecondrelease {
@@ -302,7 +1504,7 @@ func TestSemaphore_updateCapacity(t *testing.T) {
 	if got, want := sem.Capacity(), 1; got != want {
 		t.Errorf("Capacity = %d, want: %d", got, want)
 	}
-	sem.acquire(0)
+	sem.acquire(context.Background())
 	sem.updateCapacity(initialCapacity + 2)
 	if got, want := sem.Capacity(), 3; got != want {
 		t.Errorf("Capacity = %d, want: %d", got, want)
@@ -313,8 +1515,8 @@ func TestSemaphore_updateCapacity(t *testing.T) {
 func TestSemaphore_updateCapacity_LessThenReducers(t *testing.T) {
 	const initialCapacity = 2
 	sem := newSemaphore(2, initialCapacity)
-	sem.acquire(0)
-	sem.acquire(0)
+	sem.acquire(context.Background())
+	sem.acquire(context.Background())
 	sem.updateCapacity(initialCapacity - 2)
 	if got, want := sem.reducers, 2; got != want {
 		t.Errorf("sem.reducers = %d, want: %d", got, want)
@@ -330,8 +1532,8 @@ func TestSemaphore_updateCapacity_LessThenReducers(t *testing.T) {
 func TestSemaphore_updateCapacity_ConsumingReducers(t *testing.T) {
 	const initialCapacity = 2
 	sem := newSemaphore(2, initialCapacity)
-	sem.acquire(0)
-	sem.acquire(0)
+	sem.acquire(context.Background())
+	sem.acquire(context.Background())
 	sem.updateCapacity(initialCapacity - 2)
 	if got, want := sem.reducers, 2; got != want {
 		t.Errorf("sem.reducers = %d, want: %d", got, want)
@@ -352,7 +1554,7 @@ func TestSemaphore_updateCapacity_Overflow(t *testing.T) {
 
 func TestSemaphore_updateCapacity_OutOfBound(t *testing.T) {
 	sem := newSemaphore(1, 1)
-	sem.acquire(0)
+	sem.acquire(context.Background())
 	if err := sem.updateCapacity(-1); err != ErrUpdateCapacity {
 		t.Errorf("updateCapacity = %v, want: %v", err, ErrUpdateCapacity)
 	}
@@ -390,9 +1592,9 @@ func (b *Breaker) concurrentRequest(timeout time.Duration) request {
 	if len(b.sem.queue) > 0 {
 		// Expect request to be performed
 		defer waitForQueue(b.sem.queue, len(b.sem.queue)-1)
-	} else if len(b.pendingRequests) < cap(b.pendingRequests) {
+	} else if pending(b) < slots(b) {
 		// Expect request to be queued
-		defer waitForQueue(b.pendingRequests, len(b.pendingRequests)+1)
+		defer waitForPending(b, pending(b)+1)
 	} else {
 		// Expect request to be rejected
 		defer r.wait()
@@ -402,10 +1604,10 @@ func (b *Breaker) concurrentRequest(timeout time.Duration) request {
 	start.Add(1)
 	go func() {
 		start.Done()
-		ok := b.Maybe(timeout, func() {
+		err := b.Maybe(timeout, func() {
 			<-r.barrier
 		})
-		r.accepted <- ok
+		r.accepted <- err == nil
 	}()
 	start.Wait() // Ensure that the go func has had a chance to execute.
 	return r
@@ -428,6 +1630,34 @@ func waitForQueue(queue chan struct{}, size int) {
 	}
 }
 
+// pending returns the number of requests in the breaker.
+func pending(b *Breaker) int {
+	return int(atomic.LoadInt64(&b.pending))
+}
+
+// slots returns the number of requests the breaker takes.
+func slots(b *Breaker) int {
+	return int(atomic.LoadInt64(&b.totalSlots))
+}
+
+func waitForPending(b *Breaker, size int) {
+	if err := wait.PollImmediate(1*time.Millisecond, 100*time.Millisecond, func() (bool, error) {
+		return pending(b) == size, nil
+	}); err != nil {
+		panic("timed out waiting for pending requests")
+	}
+}
+
+func waitForWaiters(sem *semaphore, size int) {
+	if err := wait.PollImmediate(1*time.Millisecond, 100*time.Millisecond, func() (bool, error) {
+		sem.mux.Lock()
+		defer sem.mux.Unlock()
+		return len(sem.waiters) == size, nil
+	}); err != nil {
+		panic("timed out waiting for waiters")
+	}
+}
+
 func accepted(requests []request) []bool {
 	got := make([]bool, len(requests))
 	for i, r := range requests {
@@ -451,7 +1681,88 @@ func unlockAll(requests []request) {
 func tryAcquire(sem *semaphore, gotChan chan struct{}) {
 	go func() {
 		// blocking until someone puts the token into the semaphore
-		sem.acquire(0)
+		sem.acquire(context.Background())
 		gotChan <- struct{}{}
 	}()
 }
+
+// The Breaker benchmarks are fixed workloads of its admission path. Their
+// results are checked against test/benchmarks.txt by test/benchmarks.sh, so
+// changing a workload means updating its baseline.
+
+func BenchmarkBreakerMaybe(b *testing.B) {
+	breaker := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := breaker.MaybeContext(context.Background(), func() {}); err != nil {
+			b.Fatalf("MaybeContext() = %v", err)
+		}
+	}
+}
+
+func BenchmarkBreakerMaybeParallel(b *testing.B) {
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10000, MaxConcurrency: 100, InitialCapacity: 100})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := breaker.MaybeContext(context.Background(), func() {}); err != nil {
+				b.Errorf("MaybeContext() = %v", err)
+			}
+		}
+	})
+}
+
+// BenchmarkBreakerMaybe10kConcurrent lets 10k concurrent calls through a
+// capacity of 100 per operation.
+func BenchmarkBreakerMaybe10kConcurrent(b *testing.B) {
+	const calls = 10000
+	breaker := NewBreaker(BreakerParams{QueueDepth: calls, MaxConcurrency: 100, InitialCapacity: 100})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(calls)
+		for j := 0; j < calls; j++ {
+			go func() {
+				defer wg.Done()
+				if err := breaker.MaybeContext(context.Background(), func() {}); err != nil {
+					b.Errorf("MaybeContext() = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkBreakerCapacityChurn makes calls while the capacity keeps
+// changing, as when the activator follows the endpoints of a revision.
+func BenchmarkBreakerCapacityChurn(b *testing.B) {
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10000, MaxConcurrency: 100, InitialCapacity: 100})
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for size := 10; ; size = 110 - size {
+			select {
+			case <-stop:
+				return
+			default:
+				breaker.UpdateConcurrency(size)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := breaker.MaybeContext(context.Background(), func() {}); err != nil {
+				b.Errorf("MaybeContext() = %v", err)
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-done
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	pkghttp "github.com/knative/serving/pkg/http"
+	"github.com/knative/serving/pkg/network"
+	"knative.dev/pkg/websocket"
+)
+
+// CacheDefaultsHandler sets the Cache-Control and Surrogate-Control headers
+// of the responses the application didn't set them on, to the values the
+// ingress passed for the traffic target in the request headers. The ingress
+// appends its values after any the client sent, so the last ones are used.
+// TODO: clients can still set the defaults on routes without a cache policy.
+func CacheDefaultsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheControl := lastHeader(r.Header, network.DefaultCacheControlHeaderName)
+		surrogateControl := lastHeader(r.Header, network.DefaultSurrogateControlHeaderName)
+		r.Header.Del(network.DefaultCacheControlHeaderName)
+		r.Header.Del(network.DefaultSurrogateControlHeaderName)
+
+		if cacheControl != "" || surrogateControl != "" {
+			w = &cacheDefaultsWriter{
+				writer: w,
+				defaults: map[string]string{
+					"Cache-Control":     cacheControl,
+					"Surrogate-Control": surrogateControl,
+				},
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func lastHeader(header http.Header, name string) string {
+	values := header[http.CanonicalHeaderKey(name)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+type cacheDefaultsWriter struct {
+	writer   http.ResponseWriter
+	defaults map[string]string
+	applied  bool
+}
+
+var (
+	_ http.Flusher        = (*cacheDefaultsWriter)(nil)
+	_ http.Hijacker       = (*cacheDefaultsWriter)(nil)
+	_ http.ResponseWriter = (*cacheDefaultsWriter)(nil)
+)
+
+// apply sets the defaults once the response headers are committed.
+func (cw *cacheDefaultsWriter) apply() {
+	if cw.applied {
+		return
+	}
+	cw.applied = true
+	header := cw.writer.Header()
+	for name, value := range cw.defaults {
+		if value != "" && header.Get(name) == "" {
+			header.Set(name, value)
+		}
+	}
+}
+
+func (cw *cacheDefaultsWriter) Header() http.Header { return cw.writer.Header() }
+
+func (cw *cacheDefaultsWriter) Write(p []byte) (int, error) {
+	cw.apply()
+	return cw.writer.Write(p)
+}
+
+func (cw *cacheDefaultsWriter) WriteHeader(code int) {
+	if !pkghttp.IsInformational(code) {
+		cw.apply()
+	}
+	cw.writer.WriteHeader(code)
+}
+
+func (cw *cacheDefaultsWriter) Flush() {
+	cw.apply()
+	if f, ok := cw.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (cw *cacheDefaultsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return websocket.HijackIfPossible(cw.writer)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestCacheDefaultsHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		response http.Header
+		want     http.Header
+	}{{
+		name: "no defaults",
+		want: http.Header{},
+	}, {
+		name: "defaults applied",
+		header: http.Header{
+			network.DefaultCacheControlHeaderName:     {"public, max-age=60"},
+			network.DefaultSurrogateControlHeaderName: {"max-age=3600"},
+		},
+		want: http.Header{
+			"Cache-Control":     {"public, max-age=60"},
+			"Surrogate-Control": {"max-age=3600"},
+		},
+	}, {
+		name: "application headers kept",
+		header: http.Header{
+			network.DefaultCacheControlHeaderName:     {"public, max-age=60"},
+			network.DefaultSurrogateControlHeaderName: {"max-age=3600"},
+		},
+		response: http.Header{
+			"Cache-Control": {"no-store"},
+		},
+		want: http.Header{
+			"Cache-Control":     {"no-store"},
+			"Surrogate-Control": {"max-age=3600"},
+		},
+	}, {
+		name: "values of the ingress win",
+		header: http.Header{
+			network.DefaultCacheControlHeaderName: {"no-store", "public, max-age=60"},
+		},
+		want: http.Header{
+			"Cache-Control": {"public, max-age=60"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got http.Header
+			h := CacheDefaultsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header
+				for name, values := range test.response {
+					w.Header()[name] = values
+				}
+				w.Write([]byte("hello"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if len(got) != 0 {
+				t.Errorf("Request headers = %v, want the defaults removed", got)
+			}
+			resp := rec.Result().Header
+			resp.Del("Content-Type")
+			if !cmp.Equal(resp, test.want) {
+				t.Errorf("Response headers (-want, +got): %s", cmp.Diff(test.want, resp))
+			}
+		})
+	}
+}
+
+func TestCacheDefaultsHandlerInformational(t *testing.T) {
+	h := CacheDefaultsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set(network.DefaultCacheControlHeaderName, "public, max-age=60")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got, want := rec.Header().Get("Cache-Control"), "no-cache"; got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+var clientCertHeaders = []string{
+	network.ClientCertSubjectHeaderName,
+	network.ClientCertSANHeaderName,
+	network.ClientCertVerifiedHeaderName,
+}
+
+// ClientCertHandler replaces the client certificate headers of the request
+// with the details of the certificate the ingress verified. Values sent by
+// the client itself are always discarded, so the user container can trust
+// the headers it receives for authorization. The X-Forwarded-Client-Cert
+// header is only honored when the immediate peer is in trusted, and
+// dropped otherwise.
+func ClientCertHandler(h http.Handler, trusted []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range clientCertHeaders {
+			r.Header.Del(name)
+		}
+		if !network.IsPeerTrusted(r, trusted) {
+			r.Header.Del(network.ForwardedClientCertHeaderName)
+		}
+
+		// X-Forwarded-Client-Cert: By=<uri>;Hash=<hash>;Subject="<subject>";URI=<uri>;DNS=<dns>, ...
+		// Each proxy that terminated mTLS appends an element. The first one is
+		// added by the ingress, which drops the header if the client sent one.
+		if xfcc := r.Header.Get(network.ForwardedClientCertHeaderName); xfcc != "" {
+			cert := parseForwardedClientCert(xfcc)
+			if subject := cert["subject"]; len(subject) > 0 {
+				r.Header.Set(network.ClientCertSubjectHeaderName, subject[0])
+			}
+			if sans := append(cert["uri"], cert["dns"]...); len(sans) > 0 {
+				r.Header.Set(network.ClientCertSANHeaderName, strings.Join(sans, ","))
+			}
+			r.Header.Set(network.ClientCertVerifiedHeaderName, network.ClientCertVerifiedSuccess)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseForwardedClientCert returns the values of the first element of an
+// X-Forwarded-Client-Cert header by lower cased key. Values may be quoted,
+// in which case they can contain the separators and escaped quotes.
+func parseForwardedClientCert(xfcc string) map[string][]string {
+	values := make(map[string][]string)
+	var key, value strings.Builder
+	inKey, quoted, escaped := true, false, false
+	flush := func() {
+		if k := strings.ToLower(strings.TrimSpace(key.String())); k != "" {
+			values[k] = append(values[k], strings.TrimSpace(value.String()))
+		}
+		key.Reset()
+		value.Reset()
+		inKey = true
+	}
+	for _, c := range xfcc {
+		switch {
+		case escaped:
+			value.WriteRune(c)
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"' && !inKey:
+			quoted = !quoted
+		case quoted:
+			value.WriteRune(c)
+		case c == '=' && inKey:
+			inKey = false
+		case c == ';':
+			flush()
+		case c == ',':
+			flush()
+			return values
+		case inKey:
+			key.WriteRune(c)
+		default:
+			value.WriteRune(c)
+		}
+	}
+	flush()
+	return values
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestClientCertHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       http.Header
+	}{{
+		name: "no client certificate",
+		want: http.Header{},
+	}, {
+		name: "spoofed headers are stripped",
+		header: http.Header{
+			network.ClientCertSubjectHeaderName:  {"CN=admin"},
+			network.ClientCertSANHeaderName:      {"spiffe://admin"},
+			network.ClientCertVerifiedHeaderName: {network.ClientCertVerifiedSuccess},
+		},
+		want: http.Header{},
+	}, {
+		name:       "forwarded client certificate from an untrusted peer",
+		remoteAddr: "10.8.0.3:43210",
+		header: http.Header{
+			network.ForwardedClientCertHeaderName: {`Subject="CN=admin";URI=spiffe://admin`},
+		},
+		want: http.Header{},
+	}, {
+		name: "verified client certificate",
+		header: http.Header{
+			network.ForwardedClientCertHeaderName: {`Hash=abc;Subject="CN=client,O=Acme\"s";URI=spiffe://client;DNS=client.example.com`},
+			network.ClientCertSubjectHeaderName:   {"CN=admin"},
+		},
+		want: http.Header{
+			network.ClientCertSubjectHeaderName:  {`CN=client,O=Acme"s`},
+			network.ClientCertSANHeaderName:      {"spiffe://client,client.example.com"},
+			network.ClientCertVerifiedHeaderName: {network.ClientCertVerifiedSuccess},
+		},
+	}, {
+		name: "only the element of the ingress is used",
+		header: http.Header{
+			network.ForwardedClientCertHeaderName: {`Subject="CN=client";DNS=a.example.com;DNS=b.example.com,By=spiffe://sidecar;Subject="CN=gateway"`},
+		},
+		want: http.Header{
+			network.ClientCertSubjectHeaderName:  {"CN=client"},
+			network.ClientCertSANHeaderName:      {"a.example.com,b.example.com"},
+			network.ClientCertVerifiedHeaderName: {network.ClientCertVerifiedSuccess},
+		},
+	}}
+
+	_, trusted, _ := net.ParseCIDR("10.4.0.0/16")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := http.Header{}
+			h := ClientCertHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, name := range clientCertHeaders {
+					if v, ok := r.Header[name]; ok {
+						got[name] = v
+					}
+				}
+				if _, ok := r.Header[network.ForwardedClientCertHeaderName]; ok && len(test.want) == 0 {
+					t.Error("Untrusted X-Forwarded-Client-Cert was forwarded")
+				}
+			}), []*net.IPNet{trusted})
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.RemoteAddr = "10.4.0.2:43210"
+			if test.remoteAddr != "" {
+				req.RemoteAddr = test.remoteAddr
+			}
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Headers (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
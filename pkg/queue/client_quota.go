@@ -0,0 +1,220 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/network"
+)
+
+// ErrClientQuotaExceeded indicates that a client used up its quota.
+var ErrClientQuotaExceeded = errors.New("client quota exceeded")
+
+// ClientQuotaLimiter enforces the ClientQuota of a Revision on one of its
+// queue-proxies. The usage of the clients on the other pods of the
+// Revision, as synced by SyncClientQuota, counts towards the quota too,
+// as long as it is from the current or the previous second. The requests
+// per second are counted in slices of a second.
+type ClientQuotaLimiter struct {
+	quota serving.ClientQuota
+	now   func() time.Time
+
+	mux sync.Mutex
+	// slice is the second the requests of local were made in, and
+	// remoteSlice the one of the requests of remote.
+	slice       int64
+	local       map[string]*autoscaler.ClientUsage
+	remoteSlice int64
+	remote      map[string]autoscaler.ClientUsage
+}
+
+// NewClientQuotaLimiter creates a ClientQuotaLimiter enforcing quota.
+func NewClientQuotaLimiter(quota serving.ClientQuota) *ClientQuotaLimiter {
+	return &ClientQuotaLimiter{
+		quota:  quota,
+		now:    time.Now,
+		local:  make(map[string]*autoscaler.ClientUsage),
+		remote: make(map[string]autoscaler.ClientUsage),
+	}
+}
+
+// Client returns the client making the request: the value of the Header of
+// the quota, or else the IP the request was last forwarded for, or else the
+// IP it came from. The earlier hops of X-Forwarded-For are chosen by the
+// client, so only the one appended by the proxy in front of the revision,
+// e.g. the ingress, is trusted.
+func (l *ClientQuotaLimiter) Client(r *http.Request) string {
+	if l.quota.Header != "" {
+		if v := r.Header.Get(l.quota.Header); v != "" {
+			return v
+		}
+	}
+	if xff := strings.Join(r.Header["X-Forwarded-For"], ","); xff != "" {
+		hops := strings.Split(xff, ",")
+		return strings.TrimSpace(hops[len(hops)-1])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Acquire counts a request of the client against its quota. It returns the
+// function to call once the request finished, which may be called more
+// than once, or ErrClientQuotaExceeded.
+func (l *ClientQuotaLimiter) Acquire(client string) (release func(), err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.roll()
+
+	u, ok := l.local[client]
+	if !ok {
+		u = &autoscaler.ClientUsage{}
+	}
+	// The remote usage is dropped once it is too old to be trusted, e.g.
+	// while the coordinator can't be reached.
+	remote := l.remote[client]
+	if l.slice-l.remoteSlice > 1 {
+		remote = autoscaler.ClientUsage{}
+	} else if l.remoteSlice != l.slice {
+		remote.Requests = 0
+	}
+	if l.quota.Concurrency > 0 && u.InFlight+remote.InFlight >= l.quota.Concurrency {
+		return nil, ErrClientQuotaExceeded
+	}
+	if l.quota.RPS > 0 && u.Requests+remote.Requests >= l.quota.RPS {
+		return nil, ErrClientQuotaExceeded
+	}
+	u.InFlight++
+	u.Requests++
+	l.local[client] = u
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mux.Lock()
+			defer l.mux.Unlock()
+			u.InFlight--
+			if u.InFlight == 0 && u.Requests == 0 {
+				delete(l.local, client)
+			}
+		})
+	}, nil
+}
+
+// roll starts a new slice if the second of the current one passed,
+// forgetting the clients without requests in flight. `mux` must be held to
+// call it.
+func (l *ClientQuotaLimiter) roll() {
+	slice := l.now().Unix()
+	if slice == l.slice {
+		return
+	}
+	l.slice = slice
+	for client, u := range l.local {
+		if u.InFlight == 0 {
+			delete(l.local, client)
+			continue
+		}
+		u.Requests = 0
+	}
+}
+
+// Usage returns the usage of the clients on this pod, and the slice their
+// requests were made in.
+func (l *ClientQuotaLimiter) Usage() (slice int64, usage map[string]autoscaler.ClientUsage) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.roll()
+	usage = make(map[string]autoscaler.ClientUsage, len(l.local))
+	for client, u := range l.local {
+		usage[client] = *u
+	}
+	return l.slice, usage
+}
+
+// SetRemote sets the usage of the clients on the other pods of the
+// Revision, whose requests were made in the slice.
+func (l *ClientQuotaLimiter) SetRemote(slice int64, usage map[string]autoscaler.ClientUsage) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.remoteSlice = slice
+	l.remote = usage
+}
+
+// ClientQuotaHandler answers the requests of the clients over their quota
+// with 429 Too Many Requests, without passing them on to h. Probes are
+// always passed on.
+func ClientQuotaHandler(h http.Handler, l *ClientQuotaLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if network.IsKubeletProbe(r) || r.Header.Get(network.ProbeHeaderName) != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		release, err := l.Acquire(l.Client(r))
+		if err != nil {
+			// The requests per second are counted by the second.
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		h.ServeHTTP(w, r)
+	}
+}
+
+// SyncClientQuota reports the usage of the limiter, as the pod of the
+// Revision of the namespace/name key, to the autoscaler.ClientQuotaCoordinator
+// at url, and sets the usage on the other pods from its response.
+func SyncClientQuota(ctx context.Context, client *http.Client, url, key, pod string, l *ClientQuotaLimiter) error {
+	slice, usage := l.Usage()
+	body, err := json.Marshal(autoscaler.ClientQuotaReport{Key: key, Pod: pod, Slice: slice, Usage: usage})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("client quota report to %s returned status %d", url, resp.StatusCode)
+	}
+	var remote autoscaler.ClientQuotaReport
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return err
+	}
+	l.SetRemote(remote.Slice, remote.Usage)
+	return nil
+}
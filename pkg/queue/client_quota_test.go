@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestClientQuotaLimiterConcurrency(t *testing.T) {
+	l := NewClientQuotaLimiter(serving.ClientQuota{Concurrency: 2})
+	first, err := l.Acquire("a")
+	if err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+	if _, err := l.Acquire("a"); err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+	if _, err := l.Acquire("a"); err != ErrClientQuotaExceeded {
+		t.Errorf("Acquire() over quota = %v, want: %v", err, ErrClientQuotaExceeded)
+	}
+	// Other clients have their own quota.
+	if _, err := l.Acquire("b"); err != nil {
+		t.Errorf("Acquire(b) = %v, want: nil", err)
+	}
+
+	first()
+	first()
+	if _, err := l.Acquire("a"); err != nil {
+		t.Errorf("Acquire() after release = %v, want: nil", err)
+	}
+}
+
+func TestClientQuotaLimiterRPS(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewClientQuotaLimiter(serving.ClientQuota{RPS: 2})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire("a")
+		if err != nil {
+			t.Fatalf("Acquire() = %v", err)
+		}
+		release()
+	}
+	if _, err := l.Acquire("a"); err != ErrClientQuotaExceeded {
+		t.Errorf("Acquire() over quota = %v, want: %v", err, ErrClientQuotaExceeded)
+	}
+
+	// The quota is restored in the next slice.
+	now = now.Add(time.Second)
+	if _, err := l.Acquire("a"); err != nil {
+		t.Errorf("Acquire() in the next slice = %v, want: nil", err)
+	}
+}
+
+func TestClientQuotaLimiterRemote(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewClientQuotaLimiter(serving.ClientQuota{Concurrency: 2, RPS: 3})
+	l.now = func() time.Time { return now }
+
+	// The other pods used up the requests of the slice.
+	l.SetRemote(1000, map[string]autoscaler.ClientUsage{"a": {Requests: 3}})
+	if _, err := l.Acquire("a"); err != ErrClientQuotaExceeded {
+		t.Errorf("Acquire() = %v, want: %v", err, ErrClientQuotaExceeded)
+	}
+
+	// In the next slice, their requests don't count but their in-flight ones do.
+	now = now.Add(time.Second)
+	l.SetRemote(1000, map[string]autoscaler.ClientUsage{"a": {InFlight: 2, Requests: 3}})
+	if _, err := l.Acquire("a"); err != ErrClientQuotaExceeded {
+		t.Errorf("Acquire() = %v, want: %v", err, ErrClientQuotaExceeded)
+	}
+
+	// Usage older than that is dropped.
+	now = now.Add(time.Second)
+	if _, err := l.Acquire("a"); err != nil {
+		t.Errorf("Acquire() with stale remote usage = %v, want: nil", err)
+	}
+	slice, usage := l.Usage()
+	if want := map[string]autoscaler.ClientUsage{"a": {InFlight: 1, Requests: 1}}; slice != 1002 || !cmp.Equal(usage, want) {
+		t.Errorf("Usage() = %d, %v, want: 1002, %v", slice, usage, want)
+	}
+}
+
+func TestClientQuotaLimiterClient(t *testing.T) {
+	l := NewClientQuotaLimiter(serving.ClientQuota{Header: "X-Api-Key", RPS: 1})
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{{
+		name:   "header",
+		header: http.Header{"X-Api-Key": {"key"}, "X-Forwarded-For": {"10.0.0.1"}},
+		want:   "key",
+	}, {
+		name:   "forwarded",
+		header: http.Header{"X-Forwarded-For": {"10.0.0.1, 10.0.0.2"}},
+		want:   "10.0.0.2",
+	}, {
+		name:   "forwarded in several headers",
+		header: http.Header{"X-Forwarded-For": {"10.0.0.1", "10.0.0.3"}},
+		want:   "10.0.0.3",
+	}, {
+		name: "remote address",
+		want: "192.0.2.1",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			if got := l.Client(req); got != test.want {
+				t.Errorf("Client() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClientQuotaHandler(t *testing.T) {
+	l := NewClientQuotaLimiter(serving.ClientQuota{Header: "X-Api-Key", RPS: 1})
+	h := ClientQuotaHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), l)
+	serve := func(probe bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", "key")
+		if probe {
+			req.Header.Set(network.ProbeHeaderName, "queue")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got := serve(false).Code; got != http.StatusOK {
+		t.Errorf("Status = %d, want: %d", got, http.StatusOK)
+	}
+	rec := serve(false)
+	if got, want := rec.Code, http.StatusTooManyRequests; got != want {
+		t.Errorf("Status over quota = %d, want: %d", got, want)
+	}
+	if got, want := rec.Header().Get("Retry-After"), "1"; got != want {
+		t.Errorf("Retry-After = %q, want: %q", got, want)
+	}
+	if got := serve(true).Code; got != http.StatusOK {
+		t.Errorf("Status of probe = %d, want: %d", got, http.StatusOK)
+	}
+}
+
+// acceptAll is a ClientQuotaValidator accepting every report.
+type acceptAll struct{}
+
+func (acceptAll) ValidateClientQuota(*http.Request, *autoscaler.ClientQuotaReport) error {
+	return nil
+}
+
+func TestSyncClientQuota(t *testing.T) {
+	coordinator := autoscaler.NewClientQuotaCoordinator(time.Minute, acceptAll{})
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	limiters := []*ClientQuotaLimiter{
+		NewClientQuotaLimiter(serving.ClientQuota{Concurrency: 2}),
+		NewClientQuotaLimiter(serving.ClientQuota{Concurrency: 2}),
+	}
+	pods := []string{"a", "b"}
+	sync := func(pod int) {
+		if err := SyncClientQuota(context.Background(), server.Client(), server.URL, "ns/rev", pods[pod], limiters[pod]); err != nil {
+			t.Fatalf("SyncClientQuota() = %v", err)
+		}
+	}
+
+	// Each pod takes one of the two requests the client may have in flight.
+	for pod, l := range limiters {
+		if _, err := l.Acquire("client"); err != nil {
+			t.Fatalf("Acquire() = %v", err)
+		}
+		sync(pod)
+	}
+	sync(0)
+	for _, l := range limiters {
+		if _, err := l.Acquire("client"); err != ErrClientQuotaExceeded {
+			t.Errorf("Acquire() = %v, want: %v", err, ErrClientQuotaExceeded)
+		}
+	}
+
+	if err := SyncClientQuota(context.Background(), server.Client(), server.URL, "rev", "a", limiters[0]); err == nil {
+		t.Error("SyncClientQuota() with a malformed key = nil, want an error")
+	}
+}
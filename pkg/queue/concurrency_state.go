@@ -0,0 +1,261 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// concurrencyStateTimeout bounds the calls of the pauser, so that a
+	// hung runtime integration doesn't keep the pod in a stale state.
+	concurrencyStateTimeout = 5 * time.Second
+	// concurrencyStateRetryInterval is how long to wait before trying to
+	// resume the user container again after it failed.
+	concurrencyStateRetryInterval = time.Second
+)
+
+// defaultPauserClient is the client of the HTTPPausers without one.
+var defaultPauserClient = &http.Client{Timeout: concurrencyStateTimeout}
+
+// PodPauser is implemented by runtime integrations that are able to
+// freeze the user container while the pod has no requests in flight and
+// thaw it again before the next request is forwarded, e.g. a cgroup
+// freezer, a CRIU checkpoint or a microVM snapshot provider.
+type PodPauser interface {
+	// Pause is called when the last in-flight request finished.
+	Pause(ctx context.Context) error
+	// Resume is called when the first request after a pause arrives.
+	// The request is forwarded without waiting for it, a paused user
+	// container picks it up once it is resumed. Failed resumes are
+	// retried while there are requests in flight.
+	Resume(ctx context.Context) error
+}
+
+// ConcurrencyStateHandler tracks the number of in-flight requests and
+// invokes the pauser whenever the pod transitions between being idle and
+// serving requests.
+func ConcurrencyStateHandler(logger *zap.SugaredLogger, h http.Handler, pauser PodPauser) http.HandlerFunc {
+	state := newConcurrencyState(logger, pauser)
+	var (
+		mux      sync.Mutex
+		inFlight int
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		inFlight++
+		if inFlight == 1 {
+			state.set(true)
+		}
+		mux.Unlock()
+
+		defer func() {
+			mux.Lock()
+			defer mux.Unlock()
+			inFlight--
+			if inFlight == 0 {
+				state.set(false)
+			}
+		}()
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+// ConcurrencyStateHook is ConcurrencyStateHandler as the OnStateChange
+// hook of a Breaker, tracking the calls let through by the breaker.
+func ConcurrencyStateHook(logger *zap.SugaredLogger, pauser PodPauser) func(context.Context, bool) error {
+	state := newConcurrencyState(logger, pauser)
+	return func(_ context.Context, active bool) error {
+		state.set(active)
+		return nil
+	}
+}
+
+// concurrencyState puts the pod in the state it should be in with a
+// PodPauser in the background, so that a slow or hung pauser holds up
+// neither the requests nor the Breaker reporting the changes. Only the
+// latest state is applied, the ones superseded while the pauser is called
+// are skipped.
+type concurrencyState struct {
+	logger *zap.SugaredLogger
+	pauser PodPauser
+
+	mux sync.Mutex
+	// want is whether the pod should be active, and active whether it was
+	// last put in that state. The pod is resumed for the first request.
+	want, active bool
+	// syncing is whether a goroutine applies the state.
+	syncing bool
+}
+
+func newConcurrencyState(logger *zap.SugaredLogger, pauser PodPauser) *concurrencyState {
+	return &concurrencyState{logger: logger, pauser: pauser}
+}
+
+// set requests the pod to be active or paused.
+func (s *concurrencyState) set(active bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.want = active
+	if !s.syncing && s.want != s.active {
+		s.syncing = true
+		go s.sync()
+	}
+}
+
+// sync calls the pauser until the pod is in the state it should be in. A
+// pod that failed to pause is considered paused, as it serves requests
+// anyway, while failed resumes are retried.
+func (s *concurrencyState) sync() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for s.want != s.active {
+		active := s.want
+		s.mux.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), concurrencyStateTimeout)
+		var err error
+		if active {
+			if err = s.pauser.Resume(ctx); err != nil {
+				s.logger.Errorw("Failed to resume the user container", zap.Error(err))
+			}
+		} else if err = s.pauser.Pause(ctx); err != nil {
+			s.logger.Errorw("Failed to pause the user container", zap.Error(err))
+			err = nil
+		}
+		cancel()
+		if err != nil {
+			time.Sleep(concurrencyStateRetryInterval)
+		}
+		s.mux.Lock()
+		if err == nil {
+			s.active = active
+		}
+	}
+	s.syncing = false
+}
+
+// HTTPPauser is the reference PodPauser implementation. It notifies an
+// external endpoint, e.g. a node-local daemon that owns the runtime
+// integration, about the desired state of the pod.
+type HTTPPauser struct {
+	// Endpoint is the URL the state changes are POSTed to.
+	Endpoint string
+	// PodName identifies the pod towards the endpoint.
+	PodName string
+	// Client is used to send the requests. A client timing out after
+	// concurrencyStateTimeout is used if nil.
+	Client *http.Client
+}
+
+var _ PodPauser = (*HTTPPauser)(nil)
+
+// PodCheckpointer is implemented by runtime integrations that are able to
+// snapshot a warmed-up pod, so that pods created during scale-up can be
+// restored from that snapshot instead of cold starting.
+type PodCheckpointer interface {
+	// Restore is called when the pod starts, to restore its user container
+	// from the snapshot of the Revision. It returns false if there is no
+	// snapshot yet.
+	Restore(ctx context.Context) (bool, error)
+	// Checkpoint is called once the user container of a pod that was not
+	// restored is ready to serve.
+	Checkpoint(ctx context.Context) error
+}
+
+var _ PodCheckpointer = (*HTTPPauser)(nil)
+
+// concurrencyStateRequest is the payload sent by HTTPPauser.
+type concurrencyStateRequest struct {
+	Action string `json:"action"`
+	Pod    string `json:"pod"`
+}
+
+// Pause implements PodPauser.
+func (p *HTTPPauser) Pause(ctx context.Context) error {
+	return p.send(ctx, "pause")
+}
+
+// Resume implements PodPauser.
+func (p *HTTPPauser) Resume(ctx context.Context) error {
+	return p.send(ctx, "resume")
+}
+
+// Restore implements PodCheckpointer. The endpoint answers 404 if there is
+// no snapshot to restore from.
+func (p *HTTPPauser) Restore(ctx context.Context) (bool, error) {
+	status, err := p.post(ctx, "restore")
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusNotFound:
+		return false, nil
+	case status != http.StatusOK:
+		return false, fmt.Errorf("restore request to %s returned status %d", p.Endpoint, status)
+	}
+	return true, nil
+}
+
+// Checkpoint implements PodCheckpointer.
+func (p *HTTPPauser) Checkpoint(ctx context.Context) error {
+	return p.send(ctx, "checkpoint")
+}
+
+func (p *HTTPPauser) send(ctx context.Context, action string) error {
+	status, err := p.post(ctx, action)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s request to %s returned status %d", action, p.Endpoint, status)
+	}
+	return nil
+}
+
+// post sends the action to the endpoint and returns the status code of the
+// response.
+func (p *HTTPPauser) post(ctx context.Context, action string) (int, error) {
+	body, err := json.Marshal(concurrencyStateRequest{Action: action, Pod: p.PodName})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = defaultPauserClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+type fakePauser struct {
+	mux       sync.Mutex
+	calls     []string
+	resumeErr error
+	// block, if set, holds up the calls until it is closed.
+	block chan struct{}
+}
+
+func (p *fakePauser) Pause(context.Context) error {
+	p.call("pause")
+	return nil
+}
+
+func (p *fakePauser) Resume(context.Context) error {
+	p.call("resume")
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	err := p.resumeErr
+	p.resumeErr = nil
+	return err
+}
+
+func (p *fakePauser) call(action string) {
+	p.mux.Lock()
+	p.calls = append(p.calls, action)
+	block := p.block
+	p.mux.Unlock()
+	if block != nil {
+		<-block
+	}
+}
+
+// waitForCalls waits for the pauser to have been called as wanted, as it
+// is called in the background.
+func waitForCalls(t *testing.T, p *fakePauser, want []string) {
+	t.Helper()
+	var got []string
+	if err := wait.PollImmediate(time.Millisecond, 3*time.Second, func() (bool, error) {
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		got = append(got[:0], p.calls...)
+		return cmp.Equal(want, got), nil
+	}); err != nil {
+		t.Fatalf("Unexpected pauser calls (-want +got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestConcurrencyStateHandler(t *testing.T) {
+	defer ClearAll()
+	pauser := &fakePauser{}
+	inner := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := ConcurrencyStateHandler(TestLogger(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-inner
+	}), pauser)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	<-started
+	<-started
+	waitForCalls(t, pauser, []string{"resume"})
+	close(inner)
+	wg.Wait()
+
+	// Two overlapping requests only resume and pause once.
+	waitForCalls(t, pauser, []string{"resume", "pause"})
+}
+
+func TestConcurrencyStateHandlerResumeFailure(t *testing.T) {
+	defer ClearAll()
+	pauser := &fakePauser{resumeErr: errors.New("no can do")}
+	inner := make(chan struct{})
+	h := ConcurrencyStateHandler(TestLogger(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-inner
+	}), pauser)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	// The resume is retried while the request is in flight.
+	waitForCalls(t, pauser, []string{"resume", "resume"})
+	close(inner)
+	<-done
+	waitForCalls(t, pauser, []string{"resume", "resume", "pause"})
+}
+
+func TestConcurrencyStateHandlerHungPauser(t *testing.T) {
+	defer ClearAll()
+	pauser := &fakePauser{block: make(chan struct{})}
+	first := true
+	h := ConcurrencyStateHandler(TestLogger(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if first {
+			// Let the pauser get stuck resuming.
+			first = false
+			waitForCalls(t, pauser, []string{"resume"})
+		}
+	}), pauser)
+
+	// The requests don't wait for the pauser.
+	for i := 0; i < 3; i++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Request was held up by the pauser")
+		}
+	}
+
+	// Only the latest state is applied once the pauser returns: the pod
+	// is idle, so the resumes of the requests after the first are skipped.
+	close(pauser.block)
+	waitForCalls(t, pauser, []string{"resume", "pause"})
+}
+
+func TestHTTPPauser(t *testing.T) {
+	var got []concurrencyStateRequest
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req concurrencyStateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Decode() = %v", err)
+		}
+		got = append(got, req)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := &HTTPPauser{Endpoint: server.URL, PodName: "pod"}
+	if err := p.Resume(context.Background()); err != nil {
+		t.Errorf("Resume() = %v", err)
+	}
+	if err := p.Pause(context.Background()); err != nil {
+		t.Errorf("Pause() = %v", err)
+	}
+	if err := p.Checkpoint(context.Background()); err != nil {
+		t.Errorf("Checkpoint() = %v", err)
+	}
+	if restored, err := p.Restore(context.Background()); err != nil || !restored {
+		t.Errorf("Restore() = %v, %v, want: true, nil", restored, err)
+	}
+	want := []concurrencyStateRequest{{Action: "resume", Pod: "pod"}, {Action: "pause", Pod: "pod"},
+		{Action: "checkpoint", Pod: "pod"}, {Action: "restore", Pod: "pod"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected requests (-want +got): %s", diff)
+	}
+
+	status = http.StatusNotFound
+	if restored, err := p.Restore(context.Background()); err != nil || restored {
+		t.Errorf("Restore() without snapshot = %v, %v, want: false, nil", restored, err)
+	}
+
+	status = http.StatusInternalServerError
+	if err := p.Pause(context.Background()); err == nil {
+		t.Error("Pause() = nil, want an error")
+	}
+	if _, err := p.Restore(context.Background()); err == nil {
+		t.Error("Restore() = nil, want an error")
+	}
+}
+
+func TestConcurrencyStateHandlerPostsState(t *testing.T) {
+	defer ClearAll()
+	var (
+		mux sync.Mutex
+		got []concurrencyStateRequest
+	)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req concurrencyStateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Decode() = %v", err)
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		got = append(got, req)
+	}))
+	defer endpoint.Close()
+
+	posted := func(want []concurrencyStateRequest) error {
+		return wait.PollImmediate(time.Millisecond, 3*time.Second, func() (bool, error) {
+			mux.Lock()
+			defer mux.Unlock()
+			return cmp.Equal(want, got), nil
+		})
+	}
+
+	pauser := &HTTPPauser{Endpoint: endpoint.URL, PodName: "pod"}
+	server := httptest.NewServer(ConcurrencyStateHandler(TestLogger(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The user container is resumed while it has the request.
+		if err := posted([]concurrencyStateRequest{{Action: "resume", Pod: "pod"}}); err != nil {
+			t.Error("Resume wasn't posted while serving the request")
+		}
+	}), pauser))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+
+	want := []concurrencyStateRequest{{Action: "resume", Pod: "pod"}, {Action: "pause", Pod: "pod"}}
+	if err := posted(want); err != nil {
+		mux.Lock()
+		defer mux.Unlock()
+		t.Errorf("Unexpected posted state (-want +got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestHTTPPauserTimeout(t *testing.T) {
+	if got, want := defaultPauserClient.Timeout, concurrencyStateTimeout; got != want {
+		t.Errorf("Timeout = %v, want: %v", got, want)
+	}
+}
+
+func TestConcurrencyStateHook(t *testing.T) {
+	defer ClearAll()
+	pauser := &fakePauser{}
+	b := NewBreaker(BreakerParams{QueueDepth: 2, MaxConcurrency: 2, InitialCapacity: 2,
+		Hooks: BreakerHooks{OnStateChange: ConcurrencyStateHook(TestLogger(t), pauser)}})
+
+	inner := make(chan struct{})
+	started := make(chan struct{}, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.MaybeContext(context.Background(), func() {
+				started <- struct{}{}
+				<-inner
+			})
+		}()
+	}
+	<-started
+	<-started
+	waitForCalls(t, pauser, []string{"resume"})
+	close(inner)
+	wg.Wait()
+	waitForCalls(t, pauser, []string{"resume", "pause"})
+
+	// A hung pauser doesn't hold up the calls.
+	pauser.mux.Lock()
+	pauser.block = make(chan struct{})
+	pauser.mux.Unlock()
+	if err := b.MaybeContext(context.Background(), func() {
+		waitForCalls(t, pauser, []string{"resume", "pause", "resume"})
+	}); err != nil {
+		t.Errorf("MaybeContext() = %v", err)
+	}
+	close(pauser.block)
+	waitForCalls(t, pauser, []string{"resume", "pause", "resume", "pause"})
+}
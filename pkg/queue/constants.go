@@ -26,4 +26,34 @@ const (
 	// Main usage is to delay the termination of user-container until all
 	// accepted requests have been processed.
 	RequestQueueDrainPath = "/wait-for-drain"
+
+	// RequestQueueBreakerPath is the path of the admin endpoint dumping
+	// the state of the breaker of the queue-proxy as JSON, to diagnose its
+	// capacity.
+	RequestQueueBreakerPath = "/debug/breaker"
+
+	// PodInfoVolumePath is where the downward API volume exposing the
+	// pod's annotations is mounted in the queue-proxy container.
+	PodInfoVolumePath = "/etc/podinfo"
+	// PodInfoAnnotationsFile is the file within PodInfoVolumePath that
+	// holds the pod's annotations.
+	PodInfoAnnotationsFile = "annotations"
+
+	// RequestAuthenticationVolumePath is where the secret holding the JWKS
+	// of the revision's request authentication is mounted in the
+	// queue-proxy container.
+	RequestAuthenticationVolumePath = "/var/run/knative/authentication"
+
+	// StatsSocketVolumePath is where the volume shared with the other
+	// containers of the pod is mounted in the queue-proxy container when
+	// the stats are served on a unix socket.
+	StatsSocketVolumePath = "/var/run/knative/stats"
+	// StatsSocketFile is the file within StatsSocketVolumePath that is the
+	// unix socket serving the stats.
+	StatsSocketFile = "stats.sock"
+
+	// QueueDepthPerConcurrency is how many requests the queue-proxy queues
+	// per unit of container concurrency, to allow the autoscaler to get a
+	// strong enough signal.
+	QueueDepthPerConcurrency = 10
 )
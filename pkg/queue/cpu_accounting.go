@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CPUUsageFunc returns the CPU time consumed so far.
+type CPUUsageFunc func() (time.Duration, error)
+
+// CgroupCPUUsagePath returns the CPU accounting file of the cgroup mounted
+// at root, e.g. /sys/fs/cgroup, preferring cgroup v2 over v1.
+func CgroupCPUUsagePath(root string) (string, error) {
+	for _, p := range []string{"cpu.stat", "cpu,cpuacct/cpuacct.usage", "cpuacct/cpuacct.usage"} {
+		p = filepath.Join(root, p)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no CPU accounting found in %s", root)
+}
+
+// CgroupCPUUsage returns a CPUUsageFunc reading the CPU accounting file at
+// path, either a cgroup v2 cpu.stat or a cgroup v1 cpuacct.usage file.
+func CgroupCPUUsage(path string) CPUUsageFunc {
+	v2 := filepath.Base(path) == "cpu.stat"
+	return func() (time.Duration, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		return parseCgroupCPUUsage(string(b), v2)
+	}
+}
+
+func parseCgroupCPUUsage(s string, v2 bool) (time.Duration, error) {
+	if !v2 {
+		// cpuacct.usage holds the nanoseconds consumed.
+		ns, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		return time.Duration(ns), err
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "usage_usec" {
+			us, err := strconv.ParseInt(fields[1], 10, 64)
+			return time.Duration(us) * time.Microsecond, err
+		}
+	}
+	return 0, errors.New("cpu.stat has no usage_usec")
+}
+
+// cpuAccounting tracks the requests in flight, to tell which ones were
+// served on their own.
+type cpuAccounting struct {
+	mux      sync.Mutex
+	inFlight int
+	started  uint64
+}
+
+// start registers a request. It returns the number of requests started so
+// far, and whether no other request is in flight.
+func (a *cpuAccounting) start() (uint64, bool) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.inFlight++
+	a.started++
+	return a.started, a.inFlight == 1
+}
+
+// end unregisters a request. It returns whether no other request started
+// since the request with the given start count.
+func (a *cpuAccounting) end(started uint64) bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.inFlight--
+	return a.started == started
+}
+
+// CPUAccountingHandler reports the CPU time consumed while a request was
+// served. As usage covers the whole cgroup, e.g. the pod, this is best
+// effort: only the requests served while no other request was in flight
+// are reported, and failures to read the usage are ignored.
+func CPUAccountingHandler(h http.Handler, usage CPUUsageFunc, report func(time.Duration)) http.Handler {
+	a := &cpuAccounting{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started, alone := a.start()
+		measure := alone
+		var before time.Duration
+		if measure {
+			var err error
+			before, err = usage()
+			measure = err == nil
+		}
+		served := false
+		defer func() {
+			// Sample before unregistering, as another request may start
+			// right after.
+			var after time.Duration
+			if measure && served {
+				var err error
+				after, err = usage()
+				measure = err == nil && after >= before
+			}
+			if a.end(started) && measure && served {
+				report(after - before)
+			}
+		}()
+		h.ServeHTTP(w, r)
+		served = true
+	})
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCgroupCPUUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := CgroupCPUUsagePath(dir); err == nil {
+		t.Error("CgroupCPUUsagePath() = nil, wanted an error without accounting files")
+	}
+
+	v1 := filepath.Join(dir, "cpu,cpuacct", "cpuacct.usage")
+	if err := os.MkdirAll(filepath.Dir(v1), 0755); err != nil {
+		t.Fatal("MkdirAll() =", err)
+	}
+	if err := ioutil.WriteFile(v1, []byte("1500000000\n"), 0644); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if got, err := CgroupCPUUsagePath(dir); err != nil || got != v1 {
+		t.Errorf("CgroupCPUUsagePath() = %q, %v, want: %q", got, err, v1)
+	}
+	if got, err := CgroupCPUUsage(v1)(); err != nil || got != 1500*time.Millisecond {
+		t.Errorf("CgroupCPUUsage(v1)() = %v, %v, want: %v", got, err, 1500*time.Millisecond)
+	}
+
+	// cgroup v2 is preferred.
+	v2 := filepath.Join(dir, "cpu.stat")
+	if err := ioutil.WriteFile(v2, []byte("usage_usec 2500\nuser_usec 2000\nsystem_usec 500\n"), 0644); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if got, err := CgroupCPUUsagePath(dir); err != nil || got != v2 {
+		t.Errorf("CgroupCPUUsagePath() = %q, %v, want: %q", got, err, v2)
+	}
+	if got, err := CgroupCPUUsage(v2)(); err != nil || got != 2500*time.Microsecond {
+		t.Errorf("CgroupCPUUsage(v2)() = %v, %v, want: %v", got, err, 2500*time.Microsecond)
+	}
+
+	if err := ioutil.WriteFile(v2, []byte("nr_periods 0\n"), 0644); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if _, err := CgroupCPUUsage(v2)(); err == nil {
+		t.Error("CgroupCPUUsage(v2)() = nil, wanted an error without usage_usec")
+	}
+}
+
+func TestCPUAccountingHandler(t *testing.T) {
+	var usage time.Duration
+	usageErr := error(nil)
+	var reported []time.Duration
+
+	var inner http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage += 30 * time.Millisecond
+	})
+	h := CPUAccountingHandler(inner, func() (time.Duration, error) {
+		return usage, usageErr
+	}, func(d time.Duration) {
+		reported = append(reported, d)
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := reported, []time.Duration{30 * time.Millisecond}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Reported = %v, want: %v", got, want)
+	}
+
+	// Failing to read the usage skips the request.
+	reported = nil
+	usageErr = errors.New("no cgroup")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(reported) != 0 {
+		t.Errorf("Reported = %v, want none", reported)
+	}
+}
+
+func TestCPUAccountingHandlerConcurrent(t *testing.T) {
+	reported := make(chan time.Duration, 2)
+	entered, release := make(chan struct{}), make(chan struct{})
+	var inner http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+	h := CPUAccountingHandler(inner, func() (time.Duration, error) {
+		return time.Second, nil
+	}, func(d time.Duration) {
+		reported <- d
+	})
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			done <- struct{}{}
+		}()
+	}
+	<-entered
+	<-entered
+	close(release)
+	<-done
+	<-done
+
+	// Neither request was served on its own.
+	if len(reported) != 0 {
+		t.Errorf("Reported %d requests, want none", len(reported))
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
+)
+
+// DynamicConfig holds the queue-proxy parameters that can be changed
+// without restarting the pod, by annotating it. Zero values mean that the
+// parameter is not overridden.
+type DynamicConfig struct {
+	ContainerConcurrency int
+	LoggingLevel         *zapcore.Level
+	RevisionTimeout      time.Duration
+}
+
+// ReadPodAnnotations parses the downward API annotations file at path.
+func ReadPodAnnotations(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	annotations := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The downward API writes one `key="value"` pair per line.
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, err := strconv.Unquote(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for annotation %s: %v", parts[0], err)
+		}
+		annotations[parts[0]] = value
+	}
+	return annotations, scanner.Err()
+}
+
+// NewDynamicConfigFromAnnotations extracts the DynamicConfig from the pod's
+// annotations.
+func NewDynamicConfigFromAnnotations(annotations map[string]string) (*DynamicConfig, error) {
+	dc := &DynamicConfig{}
+	if v, ok := annotations[autoscaling.ContainerConcurrencyAnnotationKey]; ok {
+		cc, err := strconv.Atoi(v)
+		if err != nil || cc < 0 {
+			return nil, fmt.Errorf("invalid %s annotation value: %q", autoscaling.ContainerConcurrencyAnnotationKey, v)
+		}
+		dc.ContainerConcurrency = cc
+	}
+	if v, ok := annotations[serving.QueueSideCarLoggingLevelAnnotation]; ok {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation value: %q", serving.QueueSideCarLoggingLevelAnnotation, v)
+		}
+		dc.LoggingLevel = &level
+	}
+	if v, ok := annotations[serving.QueueSideCarTimeoutSecondsAnnotation]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			return nil, fmt.Errorf("invalid %s annotation value: %q", serving.QueueSideCarTimeoutSecondsAnnotation, v)
+		}
+		dc.RevisionTimeout = time.Duration(secs) * time.Second
+	}
+	return dc, nil
+}
+
+func (dc *DynamicConfig) equal(other *DynamicConfig) bool {
+	if dc.ContainerConcurrency != other.ContainerConcurrency || dc.RevisionTimeout != other.RevisionTimeout {
+		return false
+	}
+	if dc.LoggingLevel == nil || other.LoggingLevel == nil {
+		return dc.LoggingLevel == other.LoggingLevel
+	}
+	return *dc.LoggingLevel == *other.LoggingLevel
+}
+
+// WatchDynamicConfig polls the downward API annotations file at path every
+// period, and calls onChange with the DynamicConfig whenever it changes,
+// until stopCh is closed. Invalid configurations are logged and skipped.
+func WatchDynamicConfig(logger *zap.SugaredLogger, path string, period time.Duration, onChange func(*DynamicConfig), stopCh <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	last := &DynamicConfig{}
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		annotations, err := ReadPodAnnotations(path)
+		if err != nil {
+			logger.Errorw("Failed to read the pod annotations from "+path, zap.Error(err))
+			continue
+		}
+		dc, err := NewDynamicConfigFromAnnotations(annotations)
+		if err != nil {
+			logger.Errorw("Ignoring invalid dynamic configuration", zap.Error(err))
+			continue
+		}
+		if dc.equal(last) {
+			continue
+		}
+		last = dc
+		onChange(dc)
+	}
+}
+
+// UpdateBreakerConcurrency returns a DynamicConfig change handler applying
+// the container concurrency to the breaker, and scaling its queue depth
+// along. Without an override, the breaker goes back to defaultCC, the
+// container concurrency the revision is configured with. A concurrency of 0
+// (unlimited) cannot be applied to a breaker, so it falls back too.
+func UpdateBreakerConcurrency(logger *zap.SugaredLogger, breaker *Breaker, defaultCC int) func(*DynamicConfig) {
+	return func(dc *DynamicConfig) {
+		cc := dc.ContainerConcurrency
+		if cc <= 0 {
+			cc = defaultCC
+		}
+		if cc == breaker.Capacity() {
+			return
+		}
+		logger.Infof("Updating container concurrency from %d to %d", breaker.Capacity(), cc)
+		if err := breaker.UpdateConcurrency(cc); err != nil {
+			logger.Errorw("Failed to update the container concurrency", zap.Error(err))
+			return
+		}
+		if err := breaker.UpdateQueueDepth(cc * QueueDepthPerConcurrency); err != nil {
+			logger.Errorw("Failed to update the queue depth", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func writeAnnotations(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write annotations: %v", err)
+	}
+}
+
+func TestReadPodAnnotations(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     map[string]string
+		wantErr  bool
+	}{{
+		name:     "no annotations",
+		contents: "",
+		want:     map[string]string{},
+	}, {
+		name:     "some annotations",
+		contents: "foo=\"bar\"\nbaz=\"a=b\"\n",
+		want:     map[string]string{"foo": "bar", "baz": "a=b"},
+	}, {
+		name:     "not quoted",
+		contents: "foo=bar\n",
+		wantErr:  true,
+	}}
+
+	dir, err := ioutil.TempDir("", "podinfo")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, PodInfoAnnotationsFile)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writeAnnotations(t, path, test.contents)
+			got, err := ReadPodAnnotations(path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadPodAnnotations() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ReadPodAnnotations() (-want, +got) = %v", diff)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := ReadPodAnnotations(filepath.Join(dir, "missing")); err == nil {
+			t.Error("Expected an error for a missing file")
+		}
+	})
+}
+
+func TestNewDynamicConfigFromAnnotations(t *testing.T) {
+	debug := zapcore.DebugLevel
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *DynamicConfig
+		wantErr     bool
+	}{{
+		name: "no annotations",
+		want: &DynamicConfig{},
+	}, {
+		name: "all set",
+		annotations: map[string]string{
+			autoscaling.ContainerConcurrencyAnnotationKey: "42",
+			serving.QueueSideCarLoggingLevelAnnotation:    "debug",
+			serving.QueueSideCarTimeoutSecondsAnnotation:  "30",
+		},
+		want: &DynamicConfig{
+			ContainerConcurrency: 42,
+			LoggingLevel:         &debug,
+			RevisionTimeout:      30 * time.Second,
+		},
+	}, {
+		name: "invalid concurrency",
+		annotations: map[string]string{
+			autoscaling.ContainerConcurrencyAnnotationKey: "lots",
+		},
+		wantErr: true,
+	}, {
+		name: "invalid logging level",
+		annotations: map[string]string{
+			serving.QueueSideCarLoggingLevelAnnotation: "chatty",
+		},
+		wantErr: true,
+	}, {
+		name: "negative timeout",
+		annotations: map[string]string{
+			serving.QueueSideCarTimeoutSecondsAnnotation: "-1",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewDynamicConfigFromAnnotations(test.annotations)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewDynamicConfigFromAnnotations() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("NewDynamicConfigFromAnnotations() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestWatchDynamicConfig(t *testing.T) {
+	defer ClearAll()
+	dir, err := ioutil.TempDir("", "podinfo")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, PodInfoAnnotationsFile)
+	writeAnnotations(t, path, "")
+
+	changes := make(chan *DynamicConfig, 10)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go WatchDynamicConfig(TestLogger(t), path, 5*time.Millisecond, func(dc *DynamicConfig) {
+		changes <- dc
+	}, stopCh)
+
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"30\"\n", serving.QueueSideCarTimeoutSecondsAnnotation))
+	select {
+	case dc := <-changes:
+		if got, want := dc.RevisionTimeout, 30*time.Second; got != want {
+			t.Errorf("RevisionTimeout = %v, want: %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the configuration change")
+	}
+
+	// Invalid configurations and unchanged ones are not propagated.
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"soon\"\n", serving.QueueSideCarTimeoutSecondsAnnotation))
+	time.Sleep(50 * time.Millisecond)
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"30\"\n", serving.QueueSideCarTimeoutSecondsAnnotation))
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case dc := <-changes:
+		t.Errorf("Unexpected configuration change: %#v", dc)
+	default:
+	}
+}
+
+func TestUpdateBreakerConcurrency(t *testing.T) {
+	defer ClearAll()
+	dir, err := ioutil.TempDir("", "podinfo")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, PodInfoAnnotationsFile)
+	writeAnnotations(t, path, "")
+
+	logger := TestLogger(t)
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 1})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go WatchDynamicConfig(logger, path, 5*time.Millisecond, UpdateBreakerConcurrency(logger, breaker, 1), stopCh)
+
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"5\"\n", autoscaling.ContainerConcurrencyAnnotationKey))
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Capacity() == 5, nil
+	}); err != nil {
+		t.Fatalf("Capacity = %d, want: 5", breaker.Capacity())
+	}
+	if got, want := breaker.QueueDepth(), 5*QueueDepthPerConcurrency; got != want {
+		t.Errorf("QueueDepth = %d, want: %d", got, want)
+	}
+
+	// Removing the override restores the configured concurrency.
+	writeAnnotations(t, path, "")
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Capacity() == 1, nil
+	}); err != nil {
+		t.Fatalf("Capacity = %d, want: 1", breaker.Capacity())
+	}
+	if got, want := breaker.QueueDepth(), QueueDepthPerConcurrency; got != want {
+		t.Errorf("QueueDepth = %d, want: %d", got, want)
+	}
+
+	// Unlimited concurrency can't be applied to the breaker, so it falls
+	// back to the configured concurrency as well.
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"5\"\n", autoscaling.ContainerConcurrencyAnnotationKey))
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Capacity() == 5, nil
+	}); err != nil {
+		t.Fatalf("Capacity = %d, want: 5", breaker.Capacity())
+	}
+	writeAnnotations(t, path, fmt.Sprintf("%s=\"0\"\n", autoscaling.ContainerConcurrencyAnnotationKey))
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.Capacity() == 1, nil
+	}); err != nil {
+		t.Fatalf("Capacity = %d, want: 1", breaker.Capacity())
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"net/http"
+)
+
+// The errors of the calls a Breaker or FairBreaker doesn't let through,
+// which callers tell apart to answer them, e.g. with ErrorStatusCode.
+var (
+	// ErrQueueFull indicates the breaker queue depth was exceeded.
+	ErrQueueFull = errors.New("pending request queue full")
+	// ErrAcquireTimeout indicates the request timed out waiting for
+	// capacity in the breaker.
+	ErrAcquireTimeout = errors.New("timed out waiting for capacity")
+	// ErrDraining indicates the breaker was drained, e.g. because its
+	// target went away.
+	ErrDraining = errors.New("breaker is draining")
+	// ErrPreempted indicates the request was failed while queued because
+	// the capacity of the breaker dropped, so that it could not have been
+	// let through before its deadline.
+	ErrPreempted = errors.New("preempted: not enough capacity to serve the request in time")
+)
+
+// ErrorStatusCode returns the HTTP status code of the response to a
+// request a breaker failed with err: 504 if it timed out waiting for
+// capacity, and 503 otherwise, e.g. if the breaker was full or draining.
+func ErrorStatusCode(err error) int {
+	if err == ErrAcquireTimeout {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FairBreakerParams defines the parameters of the fair breaker.
+type FairBreakerParams struct {
+	BreakerParams
+	// KeyQueueDepth is the number of calls of a single key that may wait
+	// for capacity. 0 lets a key take the whole queue.
+	KeyQueueDepth int
+}
+
+// FairBreaker is a variant of Breaker whose callers pass a key, e.g. the
+// source revision or the identity of the client. Instead of letting the
+// queued calls through in the order they arrived, the keys waiting for
+// capacity take turns, so that a noisy caller mostly delays its own calls.
+// The calls of each key are let through FIFO, whatever the Discipline of
+// the BreakerParams.
+type FairBreaker struct {
+	mux sync.Mutex
+
+	totalSlots     int
+	keyQueueDepth  int
+	maxConcurrency int
+	capacity       int
+	hooks          BreakerHooks
+
+	// pending is the number of calls in the breaker, queued or in-flight.
+	pending  int
+	inFlight int
+	rejected int64
+
+	// waiters are the queued calls of each key, in the order they arrived,
+	// and turns the keys with queued calls, in the order they are let through.
+	waiters map[string][]chan struct{}
+	turns   []string
+}
+
+// NewFairBreaker creates a FairBreaker with the desired queue depth,
+// concurrency limit and initial capacity.
+func NewFairBreaker(params FairBreakerParams) *FairBreaker {
+	if params.QueueDepth <= 0 {
+		panic(fmt.Sprintf("Queue depth must be greater than 0. Got %v.", params.QueueDepth))
+	}
+	if params.MaxConcurrency < 0 {
+		panic(fmt.Sprintf("Max concurrency must be 0 or greater. Got %v.", params.MaxConcurrency))
+	}
+	if params.InitialCapacity < 0 || params.InitialCapacity > params.MaxConcurrency {
+		panic(fmt.Sprintf("Initial capacity must be between 0 and max concurrency. Got %v.", params.InitialCapacity))
+	}
+	if params.KeyQueueDepth < 0 {
+		panic(fmt.Sprintf("Key queue depth must be 0 or greater. Got %v.", params.KeyQueueDepth))
+	}
+	return &FairBreaker{
+		totalSlots:     params.QueueDepth + params.MaxConcurrency,
+		keyQueueDepth:  params.KeyQueueDepth,
+		maxConcurrency: params.MaxConcurrency,
+		capacity:       params.InitialCapacity,
+		hooks:          params.Hooks,
+		waiters:        make(map[string][]chan struct{}),
+	}
+}
+
+// Maybe is like Breaker.MaybeContext for a call of the given key. It also
+// returns ErrQueueFull if the key already has KeyQueueDepth calls
+// waiting for capacity.
+func (b *FairBreaker) Maybe(ctx context.Context, key string, thunk func()) error {
+	b.mux.Lock()
+	if b.pending >= b.totalSlots || (b.keyQueueDepth > 0 && len(b.waiters[key]) >= b.keyQueueDepth) {
+		b.rejected++
+		b.mux.Unlock()
+		b.hooks.reject(ctx)
+		return ErrQueueFull
+	}
+	b.pending++
+
+	var ready chan struct{}
+	if len(b.turns) == 0 && b.inFlight < b.capacity {
+		b.inFlight++
+	} else {
+		ready = make(chan struct{}, 1)
+		if len(b.waiters[key]) == 0 {
+			b.turns = append(b.turns, key)
+		}
+		b.waiters[key] = append(b.waiters[key], ready)
+	}
+	b.mux.Unlock()
+
+	ctx = b.hooks.enqueue(ctx)
+	start := time.Now()
+	if ready != nil {
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			b.mux.Lock()
+			if !b.removeWaiter(key, ready) {
+				// The call was let through in the meantime, pass it on.
+				b.inFlight--
+				b.dispatch()
+			}
+			b.pending--
+			b.mux.Unlock()
+			err := ctx.Err()
+			if err == context.DeadlineExceeded {
+				err = ErrAcquireTimeout
+			}
+			b.hooks.dequeue(ctx, time.Since(start), err)
+			return err
+		}
+	}
+	wait := time.Since(start)
+	b.hooks.dequeue(ctx, wait, nil)
+	recordQueueWait(ctx, wait)
+
+	start = time.Now()
+	defer func() {
+		b.mux.Lock()
+		b.inFlight--
+		b.pending--
+		b.dispatch()
+		b.mux.Unlock()
+		b.hooks.complete(ctx, time.Since(start))
+	}()
+	thunk()
+	return nil
+}
+
+// dispatch lets the queued calls through while there is capacity, taking
+// the first call of the key whose turn it is. `mux` must be held to call it.
+func (b *FairBreaker) dispatch() {
+	for b.inFlight < b.capacity && len(b.turns) > 0 {
+		key := b.turns[0]
+		b.turns = b.turns[1:]
+		waiters := b.waiters[key]
+		if len(waiters) > 1 {
+			b.waiters[key] = waiters[1:]
+			// The key waits for its next turn behind the others.
+			b.turns = append(b.turns, key)
+		} else {
+			delete(b.waiters, key)
+		}
+		b.inFlight++
+		waiters[0] <- struct{}{}
+	}
+}
+
+// removeWaiter removes the queued call of the key, returning false if it
+// isn't queued anymore. `mux` must be held to call it.
+func (b *FairBreaker) removeWaiter(key string, ready chan struct{}) bool {
+	waiters := b.waiters[key]
+	for i, w := range waiters {
+		if w != ready {
+			continue
+		}
+		if len(waiters) > 1 {
+			b.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			return true
+		}
+		delete(b.waiters, key)
+		for j, k := range b.turns {
+			if k == key {
+				b.turns = append(b.turns[:j], b.turns[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// UpdateConcurrency updates the maximum number of in-flight requests.
+// The requests already in-flight beyond a lowered capacity are kept, and
+// no new ones let through until they dropped below it.
+func (b *FairBreaker) UpdateConcurrency(size int) error {
+	if size < 0 || size > b.maxConcurrency {
+		return ErrUpdateCapacity
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.capacity = size
+	b.dispatch()
+	return nil
+}
+
+// Capacity returns the number of allowed in-flight requests on this breaker.
+func (b *FairBreaker) Capacity() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.capacity
+}
+
+// Stats returns a snapshot of the state of the breaker.
+func (b *FairBreaker) Stats() BreakerStats {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return BreakerStats{
+		InFlight: b.inFlight,
+		Queued:   b.pending - b.inFlight,
+		Capacity: b.capacity,
+		Rejected: b.rejected,
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestFairBreakerInvalidConstructor(t *testing.T) {
+	tests := []struct {
+		name    string
+		options FairBreakerParams
+	}{{
+		name:    "QueueDepth = 0",
+		options: FairBreakerParams{BreakerParams: BreakerParams{QueueDepth: 0, MaxConcurrency: 1, InitialCapacity: 1}},
+	}, {
+		name:    "MaxConcurrency negative",
+		options: FairBreakerParams{BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: -1, InitialCapacity: 1}},
+	}, {
+		name:    "InitialCapacity out-of-bounds",
+		options: FairBreakerParams{BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 5, InitialCapacity: 6}},
+	}, {
+		name:    "KeyQueueDepth negative",
+		options: FairBreakerParams{BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}, KeyQueueDepth: -1},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Expected a panic but the code didn't panic.")
+				}
+			}()
+
+			NewFairBreaker(test.options)
+		})
+	}
+}
+
+func TestFairBreakerTakesTurns(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1},
+	})
+	finish, done := occupyFairBreaker(t, b)
+
+	// The noisy key queues all of its calls before the quiet one.
+	order := make(chan string, 4)
+	calls := []struct{ key, name string }{
+		{"noisy", "noisy-1"},
+		{"noisy", "noisy-2"},
+		{"noisy", "noisy-3"},
+		{"quiet", "quiet-1"},
+	}
+	for i, call := range calls {
+		call := call
+		go b.Maybe(context.Background(), call.key, func() {
+			order <- call.name
+		})
+		waitForFairQueued(t, b, i+1)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	for _, want := range []string{"noisy-1", "quiet-1", "noisy-2", "noisy-3"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Call %q went through, want: %q", got, want)
+			}
+		case <-time.After(semAcquireTimeout):
+			t.Fatalf("Timed out waiting for the %q call", want)
+		}
+	}
+}
+
+func TestFairBreakerKeyQueueDepth(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1},
+		KeyQueueDepth: 1,
+	})
+	finish, done := occupyFairBreaker(t, b)
+
+	go b.Maybe(context.Background(), "noisy", func() {})
+	waitForFairQueued(t, b, 1)
+	if got, want := b.Maybe(context.Background(), "noisy", func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe(noisy) = %v, want: %v", got, want)
+	}
+	go b.Maybe(context.Background(), "quiet", func() {})
+	waitForFairQueued(t, b, 2)
+
+	want := BreakerStats{InFlight: 1, Queued: 2, Capacity: 1, Rejected: 1}
+	if got := b.Stats(); !cmp.Equal(got, want) {
+		t.Errorf("Stats() = %+v, want: %+v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	waitForFairQueued(t, b, 0)
+}
+
+func TestFairBreakerQueueFull(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1},
+	})
+	finish, done := occupyFairBreaker(t, b)
+
+	go b.Maybe(context.Background(), "a", func() {})
+	waitForFairQueued(t, b, 1)
+	if got, want := b.Maybe(context.Background(), "b", func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe(b) = %v, want: %v", got, want)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+}
+
+func TestFairBreakerTimeout(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 2, MaxConcurrency: 1, InitialCapacity: 1},
+	})
+	finish, done := occupyFairBreaker(t, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), semNoChangeTimeout)
+	defer cancel()
+	if got, want := b.Maybe(ctx, "a", func() {
+		t.Error("Timed out call went through")
+	}), ErrAcquireTimeout; got != want {
+		t.Errorf("Maybe() = %v, want: %v", got, want)
+	}
+	if got, want := b.Stats().Queued, 0; got != want {
+		t.Errorf("Queued = %d, want: %d", got, want)
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	if err := b.Maybe(context.Background(), "a", func() {}); err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+}
+
+func TestFairBreakerUpdateConcurrency(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 2, InitialCapacity: 0},
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- b.Maybe(context.Background(), "a", func() {})
+	}()
+	waitForFairQueued(t, b, 1)
+
+	if err := b.UpdateConcurrency(1); err != nil {
+		t.Errorf("UpdateConcurrency(1) = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	if got, want := b.Capacity(), 1; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+	if got, want := b.UpdateConcurrency(3), ErrUpdateCapacity; got != want {
+		t.Errorf("UpdateConcurrency(3) = %v, want: %v", got, want)
+	}
+}
+
+func TestFairBreakerHooks(t *testing.T) {
+	rec := &hookRecorder{phases: map[string][]string{}}
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1, Hooks: rec.hooks()},
+		KeyQueueDepth: 1,
+	})
+	call := func(name string) context.Context {
+		return context.WithValue(context.Background(), hookCallKey{}, name)
+	}
+
+	if err := b.Maybe(call("passed"), "a", func() {}); err != nil {
+		t.Errorf("Maybe(passed) = %v, want: nil", err)
+	}
+	finish, done := occupyFairBreaker(t, b)
+	ctx, cancel := context.WithTimeout(call("timeout"), semNoChangeTimeout)
+	defer cancel()
+	if got, want := b.Maybe(ctx, "a", func() {}), ErrAcquireTimeout; got != want {
+		t.Errorf("Maybe(timeout) = %v, want: %v", got, want)
+	}
+	go b.Maybe(call("queued"), "a", func() {})
+	waitForFairQueued(t, b, 1)
+	if got, want := b.Maybe(call("rejected"), "a", func() {}), ErrQueueFull; got != want {
+		t.Errorf("Maybe(rejected) = %v, want: %v", got, want)
+	}
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	waitForFairQueued(t, b, 0)
+
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+	for name, want := range map[string][]string{
+		"passed":         {"enqueue"},
+		"passed-traced":  {"dequeue", "complete"},
+		"timeout":        {"enqueue"},
+		"timeout-traced": {"dequeue: " + ErrAcquireTimeout.Error()},
+		"queued":         {"enqueue"},
+		"rejected":       {"reject"},
+	} {
+		if got := rec.phases[name]; !cmp.Equal(got, want) {
+			t.Errorf("Hook calls of %s = %v, want: %v", name, got, want)
+		}
+	}
+}
+
+func TestFairBreakerQueueWait(t *testing.T) {
+	b := NewFairBreaker(FairBreakerParams{
+		BreakerParams: BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1},
+	})
+
+	finish, done := occupyFairBreaker(t, b)
+	ctx, wait := WithQueueWait(context.Background())
+	seen := make(chan time.Duration, 1)
+	queued := make(chan error)
+	go func() {
+		queued <- b.Maybe(ctx, "a", func() {
+			seen <- *wait
+		})
+	}()
+	waitForFairQueued(t, b, 1)
+	time.Sleep(semNoChangeTimeout)
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Maybe() = %v, want: nil", err)
+	}
+	if err := <-queued; err != nil {
+		t.Errorf("Queued Maybe() = %v, want: nil", err)
+	}
+	if got := <-seen; got < semNoChangeTimeout {
+		t.Errorf("Queue wait in the thunk = %v, want at least %v", got, semNoChangeTimeout)
+	}
+}
+
+// occupyFairBreaker occupies the only concurrency slot of b until finish
+// is closed, after which the call's result is sent to done.
+func occupyFairBreaker(t *testing.T, b *FairBreaker) (chan struct{}, chan error) {
+	t.Helper()
+	running, finish, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- b.Maybe(context.Background(), "occupant", func() {
+			close(running)
+			<-finish
+		})
+	}()
+	<-running
+	return finish, done
+}
+
+func waitForFairQueued(t *testing.T, b *FairBreaker, size int) {
+	t.Helper()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.Stats().Queued == size, nil
+	}); err != nil {
+		t.Fatalf("Timed out waiting for %d queued calls, got: %d", size, b.Stats().Queued)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// grpcWebTrailerFlag marks the message of a grpc-web response carrying
+	// the trailers, which browsers can't read.
+	grpcWebTrailerFlag = 0x80
+)
+
+// GRPCWebHandler returns a handler translating the grpc-web requests of
+// browsers to gRPC for h, e.g. a proxy to a gRPC server, and its responses
+// back to grpc-web. h must send the requests over HTTP/2, which they are
+// marked as. Other requests are passed to h as they are.
+func GRPCWebHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suffix, text, ok := parseGRPCWebContentType(r.Header.Get("Content-Type"))
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		r.Header.Set("Content-Type", grpcContentType+suffix)
+		r.Header.Set("Te", "trailers")
+		r.Header.Del("Content-Length")
+		r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+		if text {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+			r.ContentLength = -1
+		}
+
+		gw := &grpcWebWriter{writer: w, out: w, header: make(http.Header), suffix: suffix}
+		if text {
+			gw.contentType = grpcWebTextContentType
+			enc := base64.NewEncoder(base64.StdEncoding, w)
+			defer enc.Close()
+			gw.out = enc
+		} else {
+			gw.contentType = grpcWebContentType
+		}
+		h.ServeHTTP(gw, r)
+		gw.writeTrailers()
+	})
+}
+
+// parseGRPCWebContentType returns the codec suffix of the grpc-web content
+// type ct, e.g. "+proto", and whether it is the base64 encoded text one.
+func parseGRPCWebContentType(ct string) (suffix string, text, ok bool) {
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	for _, prefix := range []string{grpcWebTextContentType, grpcWebContentType} {
+		if ct == prefix || strings.HasPrefix(ct, prefix+"+") {
+			return ct[len(prefix):], prefix == grpcWebTextContentType, true
+		}
+	}
+	return "", false, false
+}
+
+// grpcWebWriter writes a gRPC response as grpc-web, with the trailers in
+// the body. The trailers are kept in its own header until the response is
+// done.
+type grpcWebWriter struct {
+	writer      http.ResponseWriter
+	out         io.Writer
+	header      http.Header
+	contentType string
+	suffix      string
+
+	wroteHeader bool
+	trailers    []string
+}
+
+var (
+	_ http.Flusher        = (*grpcWebWriter)(nil)
+	_ http.ResponseWriter = (*grpcWebWriter)(nil)
+)
+
+func (gw *grpcWebWriter) Header() http.Header { return gw.header }
+
+func (gw *grpcWebWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+
+	h := gw.writer.Header()
+	for k, vv := range gw.header {
+		switch {
+		case k == "Trailer":
+			for _, v := range vv {
+				for _, t := range strings.Split(v, ",") {
+					if t = strings.TrimSpace(t); t != "" {
+						gw.trailers = append(gw.trailers, http.CanonicalHeaderKey(t))
+					}
+				}
+			}
+		case strings.HasPrefix(k, http.TrailerPrefix):
+		default:
+			h[k] = vv
+		}
+	}
+	// The body grows by the trailers.
+	h.Del("Content-Length")
+	if ct := h.Get("Content-Type"); strings.HasPrefix(ct, grpcContentType) {
+		h.Set("Content-Type", gw.contentType+ct[len(grpcContentType):])
+	} else {
+		h.Set("Content-Type", gw.contentType+gw.suffix)
+	}
+	gw.writer.WriteHeader(code)
+}
+
+func (gw *grpcWebWriter) Write(p []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	return gw.out.Write(p)
+}
+
+func (gw *grpcWebWriter) Flush() {
+	if f, ok := gw.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeTrailers writes the trailers set once the response was done, both
+// the announced ones and those with http.TrailerPrefix, as the last
+// message of the body.
+func (gw *grpcWebWriter) writeTrailers() {
+	if !gw.wroteHeader {
+		// A trailers-only response keeps the status in the headers.
+		gw.WriteHeader(http.StatusOK)
+		return
+	}
+	var b strings.Builder
+	write := func(k string, vv []string) {
+		for _, v := range vv {
+			b.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	for _, k := range gw.trailers {
+		write(k, gw.header[k])
+	}
+	for k, vv := range gw.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			write(k[len(http.TrailerPrefix):], vv)
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+	frame := make([]byte, 5, 5+b.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(b.Len()))
+	gw.out.Write(append(frame, b.String()...))
+}
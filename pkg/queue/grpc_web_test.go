@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseGRPCWebContentType(t *testing.T) {
+	tests := []struct {
+		ct     string
+		suffix string
+		text   bool
+		ok     bool
+	}{{
+		ct: "application/grpc-web",
+		ok: true,
+	}, {
+		ct:     "application/grpc-web+proto",
+		suffix: "+proto",
+		ok:     true,
+	}, {
+		ct:     "application/grpc-web-text+proto; charset=utf-8",
+		suffix: "+proto",
+		text:   true,
+		ok:     true,
+	}, {
+		ct:   "Application/gRPC-Web-Text",
+		text: true,
+		ok:   true,
+	}, {
+		ct: "application/grpc",
+	}, {
+		ct: "application/grpc+json",
+	}, {
+		ct: "application/grpc-webfoo",
+	}, {
+		ct: "application/json",
+	}, {
+		ct: "",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.ct, func(t *testing.T) {
+			suffix, text, ok := parseGRPCWebContentType(test.ct)
+			if suffix != test.suffix || text != test.text || ok != test.ok {
+				t.Errorf("parseGRPCWebContentType(%q) = %q, %v, %v, want: %q, %v, %v",
+					test.ct, suffix, text, ok, test.suffix, test.text, test.ok)
+			}
+		})
+	}
+}
+
+// grpcMessage frames msg as a gRPC message.
+func grpcMessage(msg string) string {
+	return "\x00\x00\x00\x00" + string(rune(len(msg))) + msg
+}
+
+// grpcServer answers like a reverse proxy of a gRPC server would, echoing
+// the request with the status in the trailers.
+func grpcServer(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Content-Type"), "application/grpc+proto"; got != want {
+			t.Errorf("Content-Type = %q, want: %q", got, want)
+		}
+		if got, want := r.Header.Get("Te"), "trailers"; got != want {
+			t.Errorf("Te = %q, want: %q", got, want)
+		}
+		if got, want := r.ProtoMajor, 2; got != want {
+			t.Errorf("ProtoMajor = %d, want: %d", got, want)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+	})
+}
+
+func TestGRPCWebHandler(t *testing.T) {
+	msg := grpcMessage("ping")
+	tests := []struct {
+		name   string
+		ct     string
+		encode func(string) string
+	}{{
+		name:   "binary",
+		ct:     "application/grpc-web+proto",
+		encode: func(s string) string { return s },
+	}, {
+		name:   "text",
+		ct:     "application/grpc-web-text+proto",
+		encode: func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ping.PingService/Ping", strings.NewReader(test.encode(msg)))
+			req.Header.Set("Content-Type", test.ct)
+			rec := httptest.NewRecorder()
+			GRPCWebHandler(grpcServer(t)).ServeHTTP(rec, req)
+
+			if got, want := rec.Header().Get("Content-Type"), test.ct; got != want {
+				t.Errorf("Content-Type = %q, want: %q", got, want)
+			}
+			if got := rec.Header().Get("Trailer"); got != "" {
+				t.Errorf("Trailer = %q, want: none", got)
+			}
+			trailers := "grpc-status: 0\r\ngrpc-message: ok\r\n"
+			want := test.encode(msg + "\x80\x00\x00\x00" + string(rune(len(trailers))) + trailers)
+			if got := rec.Body.String(); got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGRPCWebHandlerTrailersOnly(t *testing.T) {
+	h := GRPCWebHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "12")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/ping.PingService/Ping", nil)
+	req.Header.Set("Content-Type", "application/grpc-web")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got, want := rec.Header().Get("Grpc-Status"), "12"; got != want {
+		t.Errorf("Grpc-Status = %q, want: %q", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "application/grpc-web"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+	if got := rec.Body.Len(); got != 0 {
+		t.Errorf("Body length = %d, want: 0", got)
+	}
+}
+
+func TestGRPCWebHandlerPassthrough(t *testing.T) {
+	for _, ct := range []string{"application/grpc", "application/grpc+proto", "application/json"} {
+		t.Run(ct, func(t *testing.T) {
+			body := []byte(`{"msg":"ping"}`)
+			h := GRPCWebHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Type"); got != ct {
+					t.Errorf("Content-Type = %q, want: %q", got, ct)
+				}
+				if got, _ := ioutil.ReadAll(r.Body); !bytes.Equal(got, body) {
+					t.Errorf("Body = %q, want: %q", got, body)
+				}
+				w.Header().Set("Content-Type", ct)
+				w.Write(body)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/ping.PingService/Ping", bytes.NewReader(body))
+			req.Header.Set("Content-Type", ct)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Type"); got != ct {
+				t.Errorf("Response Content-Type = %q, want: %q", got, ct)
+			}
+			if got := rec.Body.Bytes(); !bytes.Equal(got, body) {
+				t.Errorf("Response body = %q, want: %q", got, body)
+			}
+		})
+	}
+}
@@ -17,10 +17,43 @@ limitations under the License.
 package health
 
 import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
 	"time"
 )
 
+// HTTPProbeUserAgent is the User-Agent of the HTTP probes, unless their
+// headers set another.
+const HTTPProbeUserAgent = "Knative-Queue-Proxy-Probe"
+
+// HTTPProbeConfig is an HTTP readiness probe of another container of the
+// pod, as specified by its HTTPGetAction.
+type HTTPProbeConfig struct {
+	// Path is the path to GET.
+	Path string `json:"path,omitempty"`
+	// Scheme is HTTP or HTTPS, HTTP by default. The certificate of the
+	// container isn't verified, as kubelet doesn't.
+	Scheme string `json:"scheme,omitempty"`
+	// Headers are set on the probe requests, e.g. an Authorization token
+	// for frameworks authenticating their health endpoints, or the Host
+	// and User-Agent.
+	Headers http.Header `json:"headers,omitempty"`
+}
+
+var (
+	httpProbeTransport = &http.Transport{
+		DisableKeepAlives: true,
+	}
+	httpsProbeTransport = &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}
+)
+
 // TCPProbe checks that a TCP socket to the address can be opened.
 // Did not reuse k8s.io/kubernetes/pkg/probe/tcp to not create a dependency
 // on klog.
@@ -32,3 +65,38 @@ func TCPProbe(addr string, socketTimeout time.Duration) error {
 	conn.Close()
 	return nil
 }
+
+// HTTPProbe checks that a GET of the probe's path at the address answers
+// with a status of at least 200 and below 400, like kubelet's HTTP probes.
+func HTTPProbe(addr string, config HTTPProbeConfig, timeout time.Duration) error {
+	scheme, transport := "http", httpProbeTransport
+	if config.Scheme == "HTTPS" {
+		scheme, transport = "https", httpsProbeTransport
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+addr+config.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", HTTPProbeUserAgent)
+	for name, values := range config.Headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			if len(values) > 0 {
+				req.Host = values[len(values)-1]
+			}
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("HTTP probe answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
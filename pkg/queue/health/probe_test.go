@@ -41,3 +41,69 @@ func TestTCPProbe(t *testing.T) {
 		t.Error("Expected probe to fail but it didn't")
 	}
 }
+
+func TestHTTPProbe(t *testing.T) {
+	var got *http.Request
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	serverAddr := server.Listener.Addr().String()
+
+	config := HTTPProbeConfig{
+		Path: "/healthz",
+		Headers: http.Header{
+			"Authorization": {"Bearer token"},
+			"host":          {"app.example.com"},
+		},
+	}
+	if err := HTTPProbe(serverAddr, config, time.Second); err != nil {
+		t.Errorf("Expected probe to succeed but it failed with %v", err)
+	}
+	if got.URL.Path != "/healthz" {
+		t.Errorf("Path = %q, want: /healthz", got.URL.Path)
+	}
+	if got.Host != "app.example.com" {
+		t.Errorf("Host = %q, want: app.example.com", got.Host)
+	}
+	if got, want := got.Header.Get("Authorization"), "Bearer token"; got != want {
+		t.Errorf("Authorization = %q, want: %q", got, want)
+	}
+	if got, want := got.Header.Get("User-Agent"), HTTPProbeUserAgent; got != want {
+		t.Errorf("User-Agent = %q, want: %q", got, want)
+	}
+
+	// The User-Agent can be set too.
+	config.Headers.Set("User-Agent", "probe/1.0")
+	if err := HTTPProbe(serverAddr, config, time.Second); err != nil {
+		t.Errorf("Expected probe to succeed but it failed with %v", err)
+	}
+	if got, want := got.Header.Get("User-Agent"), "probe/1.0"; got != want {
+		t.Errorf("User-Agent = %q, want: %q", got, want)
+	}
+
+	// Statuses from 400 fail the probe.
+	status = http.StatusUnauthorized
+	if err := HTTPProbe(serverAddr, config, time.Second); err == nil {
+		t.Error("Expected probe to fail but it didn't")
+	}
+
+	server.Close()
+	if err := HTTPProbe(serverAddr, config, time.Second); err == nil {
+		t.Error("Expected probe to fail but it didn't")
+	}
+}
+
+func TestHTTPSProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The self-signed certificate isn't verified.
+	if err := HTTPProbe(server.Listener.Addr().String(), HTTPProbeConfig{Scheme: "HTTPS"}, time.Second); err != nil {
+		t.Errorf("Expected probe to succeed but it failed with %v", err)
+	}
+}
@@ -0,0 +1,449 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash.
+	_ "crypto/sha512" // Registers SHA-384 and SHA-512 for crypto.Hash.
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwtLeeway is the clock skew tolerated on the validity of the tokens.
+	jwtLeeway = 30 * time.Second
+	// jwksTTL is how long a fetched key set is used before it is fetched
+	// again.
+	jwksTTL = 5 * time.Minute
+	// jwksMinRefresh bounds how often the key set is fetched again for a
+	// token signed by an unknown key, e.g. right after a key rotation.
+	jwksMinRefresh = 10 * time.Second
+)
+
+// JWKSFetcher returns a JSON Web Key Set document.
+type JWKSFetcher func(ctx context.Context) ([]byte, error)
+
+// JWKSFromURL fetches the JSON Web Key Set from url with client.
+func JWKSFromURL(client *http.Client, url string) JWKSFetcher {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s failed with status %d", url, resp.StatusCode)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+}
+
+// JWKSFromFile reads the JSON Web Key Set from the file at path, e.g. a
+// mounted Secret, which is updated in place.
+func JWKSFromFile(path string) JWKSFetcher {
+	return func(context.Context) ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}
+}
+
+// JWTAuthenticator authenticates the requests by the JSON Web Token they
+// carry as bearer token, signed by a key of a JSON Web Key Set.
+type JWTAuthenticator struct {
+	keys *keySet
+	// Issuer is the iss claim the tokens must have, if set.
+	Issuer string
+	// Audiences are the aud claims the tokens must have one of, if set.
+	Audiences []string
+
+	// now is the clock, stubbed in tests.
+	now func() time.Time
+}
+
+var _ Authenticator = (*JWTAuthenticator)(nil)
+
+// NewJWTAuthenticator creates a JWTAuthenticator verifying the tokens with
+// the keys returned by fetch, which are fetched again periodically and
+// for tokens signed by a key it doesn't know yet.
+func NewJWTAuthenticator(fetch JWKSFetcher, issuer string, audiences []string) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys:      &keySet{fetch: fetch, now: time.Now},
+		Issuer:    issuer,
+		Audiences: audiences,
+		now:       time.Now,
+	}
+}
+
+// Authenticate implements Authenticator, with the sub claim of the token
+// as the identity.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (string, error) {
+	authz := r.Header.Get("Authorization")
+	if len(authz) < len("Bearer ") || !strings.EqualFold(authz[:len("Bearer ")], "Bearer ") {
+		return "", ErrNoCredentials
+	}
+	token := strings.TrimSpace(authz[len("Bearer "):])
+
+	claims, err := a.verify(r.Context(), token)
+	if err != nil {
+		return "", fmt.Errorf("invalid bearer token: %v", err)
+	}
+	return claims.Subject, nil
+}
+
+// Scheme implements Authenticator.
+func (a *JWTAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  jwtAudience `json:"aud"`
+	Expiry    *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+}
+
+// jwtAudience is the aud claim, either a string or a list of them.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = jwtAudience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return errors.New("aud must be a string or a list of strings")
+	}
+	*a = l
+	return nil
+}
+
+func (a *JWTAuthenticator) verify(ctx context.Context, token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+
+	key, err := a.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %v", err)
+	}
+	now := a.now()
+	if claims.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*claims.Expiry, 0).Add(jwtLeeway)) {
+		return nil, errors.New("token is expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if a.Issuer != "" && claims.Issuer != a.Issuer {
+		return nil, fmt.Errorf("issuer %q is not allowed", claims.Issuer)
+	}
+	if len(a.Audiences) > 0 && !hasAudience(claims.Audience, a.Audiences) {
+		return nil, fmt.Errorf("audience %q is not allowed", strings.Join(claims.Audience, ","))
+	}
+	return &claims, nil
+}
+
+func hasAudience(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jwtHashes are the hashes of the supported signature algorithms, by the
+// size suffix of their names.
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// verifySignature verifies the signature of the signed content with the
+// key, which must be of the type the algorithm uses. The none algorithm
+// is never accepted.
+func verifySignature(alg string, key interface{}, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hash, ok := jwtHashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS"):
+		k, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("key can't verify %s", alg)
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key can't verify %s", alg)
+		}
+		h := hash.New()
+		h.Write(signed)
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), sig)
+		} else {
+			err = rsa.VerifyPSS(k, hash, h.Sum(nil), sig, nil)
+		}
+		if err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case strings.HasPrefix(alg, "ES"):
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key can't verify %s", alg)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		h := hash.New()
+		h.Write(signed)
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, h.Sum(nil), r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// keySet caches the keys of a JSON Web Key Set by their kid.
+type keySet struct {
+	fetch JWKSFetcher
+	now   func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// key returns the key of the given kid. Tokens without kid are verified
+// with the only key of the set.
+func (s *keySet) key(ctx context.Context, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	k, known := s.lookup(kid)
+	stale := now.Sub(s.fetched) >= jwksTTL
+	if stale || (!known && now.Sub(s.fetched) >= jwksMinRefresh) {
+		if err := s.refresh(ctx); err != nil {
+			// Keep verifying with the keys we have.
+			if !known {
+				return nil, fmt.Errorf("failed to fetch the keys: %v", err)
+			}
+			return k, nil
+		}
+		s.fetched = now
+		k, known = s.lookup(kid)
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return k, nil
+}
+
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	b, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric
+	K string `json:"k"`
+}
+
+// parseJWKS parses the signing keys of a JSON Web Key Set by their kid.
+// The keys of unsupported types are skipped.
+func parseJWKS(b []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("malformed key set: %v", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("malformed key %q: %v", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key verifying the signatures, nil for the key
+// types that aren't supported.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testHMACKey   = []byte("0123456789abcdef0123456789abcdef")
+	testNow       = time.Unix(1500000000, 0)
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func testJWKS(t *testing.T) []byte {
+	t.Helper()
+	b, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "rsa",
+			"use": "sig",
+			"n":   b64(testRSAKey.N.Bytes()),
+			"e":   b64(big.NewInt(int64(testRSAKey.E)).Bytes()),
+		}, {
+			"kty": "EC",
+			"kid": "ec",
+			"crv": "P-256",
+			"x":   b64(testECKey.X.Bytes()),
+			"y":   b64(testECKey.Y.Bytes()),
+		}, {
+			"kty": "oct",
+			"kid": "hmac",
+			"k":   b64(testHMACKey),
+		}, {
+			"kty": "RSA",
+			"kid": "encryption",
+			"use": "enc",
+		}, {
+			"kty": "OKP",
+			"kid": "unsupported",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	return b
+}
+
+// signToken signs the claims with the key of the kid, by the algorithm.
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, testRSAKey, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, testRSAKey, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, testECKey, digest[:])
+		if err == nil {
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	case "HS256":
+		mac := hmac.New(sha256.New, testHMACKey)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "none":
+	}
+	if err != nil {
+		t.Fatalf("Failed to sign the token: %v", err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://auth.example.com",
+		"sub": "alice",
+		"aud": []string{"hello", "world"},
+		"exp": testNow.Add(time.Minute).Unix(),
+		"nbf": testNow.Add(-time.Minute).Unix(),
+	}
+}
+
+func newTestJWTAuthenticator(t *testing.T) *JWTAuthenticator {
+	a := NewJWTAuthenticator(func(context.Context) ([]byte, error) {
+		return testJWKS(t), nil
+	}, "https://auth.example.com", []string{"hello"})
+	a.now = func() time.Time { return testNow }
+	a.keys.now = a.now
+	return a
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		authz   string
+		wantErr string
+	}{{
+		name:  "RS256",
+		authz: "Bearer " + signToken(t, "RS256", "rsa", validClaims()),
+	}, {
+		name:  "PS256",
+		authz: "Bearer " + signToken(t, "PS256", "rsa", validClaims()),
+	}, {
+		name:  "ES256",
+		authz: "Bearer " + signToken(t, "ES256", "ec", validClaims()),
+	}, {
+		name:  "HS256",
+		authz: "bearer " + signToken(t, "HS256", "hmac", validClaims()),
+	}, {
+		name:  "single audience",
+		authz: "Bearer " + signToken(t, "RS256", "rsa", with("aud", "hello")),
+	}, {
+		name:    "no token",
+		wantErr: ErrNoCredentials.Error(),
+	}, {
+		name:    "basic authentication",
+		authz:   "Basic YWxpY2U6c2VjcmV0",
+		wantErr: ErrNoCredentials.Error(),
+	}, {
+		name:    "malformed token",
+		authz:   "Bearer abc",
+		wantErr: "invalid bearer token: malformed token",
+	}, {
+		name:    "tampered token",
+		authz:   "Bearer " + strings.Replace(signToken(t, "RS256", "rsa", validClaims()), ".", ".e30", 1),
+		wantErr: "invalid bearer token: invalid signature",
+	}, {
+		name:    "none algorithm",
+		authz:   "Bearer " + signToken(t, "none", "rsa", validClaims()),
+		wantErr: `invalid bearer token: unsupported algorithm "none"`,
+	}, {
+		name:    "HMAC with an RSA key",
+		authz:   "Bearer " + signToken(t, "HS256", "rsa", validClaims()),
+		wantErr: "invalid bearer token: key can't verify HS256",
+	}, {
+		name:    "unknown key",
+		authz:   "Bearer " + signToken(t, "RS256", "other", validClaims()),
+		wantErr: `invalid bearer token: unknown key "other"`,
+	}, {
+		name:    "encryption key",
+		authz:   "Bearer " + signToken(t, "RS256", "encryption", validClaims()),
+		wantErr: `invalid bearer token: unknown key "encryption"`,
+	}, {
+		name:    "expired",
+		authz:   "Bearer " + signToken(t, "RS256", "rsa", with("exp", testNow.Add(-time.Minute).Unix())),
+		wantErr: "invalid bearer token: token is expired",
+	}, {
+		name:  "expired within the leeway",
+		authz: "Bearer " + signToken(t, "RS256", "rsa", with("exp", testNow.Add(-jwtLeeway/2).Unix())),
+	}, {
+		name:    "no expiry",
+		authz:   "Bearer " + signToken(t, "RS256", "rsa", with("exp", nil)),
+		wantErr: "invalid bearer token: token has no expiry",
+	}, {
+		name:    "not valid yet",
+		authz:   "Bearer " + signToken(t, "RS256", "rsa", with("nbf", testNow.Add(time.Minute).Unix())),
+		wantErr: "invalid bearer token: token is not valid yet",
+	}, {
+		name:    "other issuer",
+		authz:   "Bearer " + signToken(t, "RS256", "rsa", with("iss", "https://evil.example.com")),
+		wantErr: `invalid bearer token: issuer "https://evil.example.com" is not allowed`,
+	}, {
+		name:    "other audience",
+		authz:   "Bearer " + signToken(t, "RS256", "rsa", with("aud", "other")),
+		wantErr: `invalid bearer token: audience "other" is not allowed`,
+	}}
+
+	a := newTestJWTAuthenticator(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authz != "" {
+				r.Header.Set("Authorization", test.authz)
+			}
+			id, err := a.Authenticate(r)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("Authenticate() = %v, want error %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() = %v", err)
+			}
+			if got, want := id, "alice"; got != want {
+				t.Errorf("Authenticate() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorKeyRotation(t *testing.T) {
+	fetches := 0
+	jwks := []byte(`{"keys": []}`)
+	var fetchErr error
+	a := NewJWTAuthenticator(func(context.Context) ([]byte, error) {
+		fetches++
+		return jwks, fetchErr
+	}, "", nil)
+	now := testNow
+	a.now = func() time.Time { return now }
+	a.keys.now = a.now
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	claims := validClaims()
+	claims["exp"] = testNow.Add(time.Hour).Unix()
+	r.Header.Set("Authorization", "Bearer "+signToken(t, "RS256", "rsa", claims))
+	if _, err := a.Authenticate(r); err == nil {
+		t.Fatal("Authenticate() = nil, want an error for an unknown key")
+	}
+
+	// The key is rotated in, but the set is only fetched again after a while.
+	jwks = testJWKS(t)
+	if _, err := a.Authenticate(r); err == nil {
+		t.Error("Authenticate() = nil, want an error before the key set is fetched again")
+	}
+	if got, want := fetches, 1; got != want {
+		t.Errorf("Fetches = %d, want %d", got, want)
+	}
+	now = now.Add(jwksMinRefresh)
+	if _, err := a.Authenticate(r); err != nil {
+		t.Errorf("Authenticate() = %v", err)
+	}
+	if got, want := fetches, 2; got != want {
+		t.Errorf("Fetches = %d, want %d", got, want)
+	}
+
+	// The keys that were fetched keep being used when fetching fails.
+	fetchErr = errors.New("unavailable")
+	now = now.Add(jwksTTL)
+	if _, err := a.Authenticate(r); err != nil {
+		t.Errorf("Authenticate() = %v", err)
+	}
+	if got, want := fetches, 3; got != want {
+		t.Errorf("Fetches = %d, want %d", got, want)
+	}
+}
+
+func TestJWKSFromURL(t *testing.T) {
+	jwks := testJWKS(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(jwks)
+	}))
+	defer ts.Close()
+
+	got, err := JWKSFromURL(ts.Client(), ts.URL+"/jwks.json")(context.Background())
+	if err != nil {
+		t.Fatalf("JWKSFromURL() = %v", err)
+	}
+	if string(got) != string(jwks) {
+		t.Errorf("JWKSFromURL() = %s, want %s", got, jwks)
+	}
+
+	if _, err := JWKSFromURL(ts.Client(), ts.URL+"/missing")(context.Background()); err == nil {
+		t.Error("JWKSFromURL() = nil, want an error for a 404")
+	}
+}
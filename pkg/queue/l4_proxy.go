@@ -0,0 +1,216 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// l4DialTimeout bounds how long a connection waits for the user
+	// container to accept its counterpart.
+	l4DialTimeout = 5 * time.Second
+
+	// maxDatagramSize is the largest UDP payload.
+	maxDatagramSize = 64 * 1024
+)
+
+// ProxyTCP proxies the connections accepted on l to target, e.g. the
+// non-HTTP port of the user container, until l is closed. A connection
+// counts as a request for as long as it is open, so that connection
+// oriented protocols are autoscaled by their number of connections.
+func ProxyTCP(l net.Listener, target string, reqChan chan<- ReqEvent) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go proxyConn(conn, target, reqChan)
+	}
+}
+
+func proxyConn(conn net.Conn, target string, reqChan chan<- ReqEvent) {
+	defer conn.Close()
+
+	start := time.Now()
+	reqChan <- ReqEvent{Time: start, EventType: ReqIn}
+	defer func() {
+		now := time.Now()
+		reqChan <- ReqEvent{Time: now, EventType: ReqOut, Duration: now.Sub(start)}
+	}()
+
+	upstream, err := net.DialTimeout("tcp", target, l4DialTimeout)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pipe(upstream, conn)
+	}()
+	go func() {
+		defer wg.Done()
+		pipe(conn, upstream)
+	}()
+	wg.Wait()
+}
+
+// pipe copies src to dst until src is done, and then closes the writing
+// side of dst, so that half-closed connections work.
+func pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	if c, ok := dst.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
+
+// udpSession is the traffic of one client of a UDP port, with its own
+// socket to the user container for the replies to get back to the client.
+type udpSession struct {
+	addr     net.Addr
+	upstream net.Conn
+	// lastSeen is when the client last sent a datagram. `mux` of the
+	// udpProxy must be held to access it.
+	lastSeen time.Time
+}
+
+// udpProxy proxies the datagrams of a UDP port to the user container.
+type udpProxy struct {
+	conn        net.PacketConn
+	target      string
+	idleTimeout time.Duration
+	reqChan     chan<- ReqEvent
+
+	mux      sync.Mutex
+	sessions map[string]*udpSession
+}
+
+// ProxyUDP proxies the datagrams received on conn to target, until conn is
+// closed. The datagrams of each client form a session, which counts as a
+// request until the client was idle for idleTimeout.
+func ProxyUDP(conn net.PacketConn, target string, idleTimeout time.Duration, reqChan chan<- ReqEvent) error {
+	p := &udpProxy{
+		conn:        conn,
+		target:      target,
+		idleTimeout: idleTimeout,
+		reqChan:     reqChan,
+		sessions:    make(map[string]*udpSession),
+	}
+	defer p.closeSessions()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if s := p.session(addr); s != nil {
+			s.upstream.Write(buf[:n])
+		}
+	}
+}
+
+// session returns the session of the client at addr, which is opened if
+// it has none. It returns nil if the user container can't be reached.
+func (p *udpProxy) session(addr net.Addr) *udpSession {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	s, ok := p.sessions[addr.String()]
+	if !ok {
+		upstream, err := net.DialTimeout("udp", p.target, l4DialTimeout)
+		if err != nil {
+			return nil
+		}
+		s = &udpSession{addr: addr, upstream: upstream}
+		p.sessions[addr.String()] = s
+		go p.reply(s)
+	}
+	s.lastSeen = time.Now()
+	return s
+}
+
+// expired ends the session if the client was idle for the idle timeout,
+// and returns when it was last seen otherwise.
+func (p *udpProxy) expired(s *udpSession) (time.Time, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if time.Since(s.lastSeen) < p.idleTimeout {
+		return s.lastSeen, false
+	}
+	p.forgetLocked(s)
+	return s.lastSeen, true
+}
+
+// forgetLocked drops the session, so that the next datagram of its client
+// opens a new one. `mux` must be held to call it.
+func (p *udpProxy) forgetLocked(s *udpSession) {
+	if p.sessions[s.addr.String()] == s {
+		delete(p.sessions, s.addr.String())
+	}
+}
+
+// reply sends the replies of the user container back to the client of the
+// session, until the session expires or is closed.
+func (p *udpProxy) reply(s *udpSession) {
+	defer func() {
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		p.forgetLocked(s)
+		s.upstream.Close()
+	}()
+
+	start := time.Now()
+	p.reqChan <- ReqEvent{Time: start, EventType: ReqIn}
+	defer func() {
+		now := time.Now()
+		p.reqChan <- ReqEvent{Time: now, EventType: ReqOut, Duration: now.Sub(start)}
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		lastSeen, expired := p.expired(s)
+		if expired {
+			return
+		}
+		s.upstream.SetReadDeadline(lastSeen.Add(p.idleTimeout))
+		n, err := s.upstream.Read(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				// The client may have sent more datagrams in the meantime.
+				continue
+			}
+			return
+		}
+		p.conn.WriteTo(buf[:n], s.addr)
+	}
+}
+
+func (p *udpProxy) closeSessions() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, s := range p.sessions {
+		s.upstream.Close()
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func expectReqEvent(t *testing.T, reqChan chan ReqEvent, want ReqEventType) {
+	t.Helper()
+	select {
+	case e := <-reqChan:
+		if e.EventType != want {
+			t.Errorf("EventType = %v, want: %v", e.EventType, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for an event of type %v", want)
+	}
+}
+
+func TestProxyTCP(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	reqChan := make(chan ReqEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- ProxyTCP(l, echo.Addr().String(), reqChan)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	defer conn.Close()
+	expectReqEvent(t, reqChan, ReqIn)
+
+	if _, err := conn.Write([]byte("PING")); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	// Half-closing the connection must still let the reply through.
+	conn.(*net.TCPConn).CloseWrite()
+	got, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if string(got) != "PING" {
+		t.Errorf("Reply = %q, want: %q", got, "PING")
+	}
+	expectReqEvent(t, reqChan, ReqOut)
+
+	l.Close()
+	if err := <-done; err == nil {
+		t.Error("ProxyTCP() = nil after the listener was closed, want an error")
+	}
+}
+
+func TestProxyTCPUnreachable(t *testing.T) {
+	// Find a port nobody listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	target := closed.Addr().String()
+	closed.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer l.Close()
+	reqChan := make(chan ReqEvent, 10)
+	go ProxyTCP(l, target, reqChan)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	defer conn.Close()
+	if got, err := ioutil.ReadAll(conn); len(got) != 0 {
+		t.Errorf("ReadAll() = (%q, %v), want the connection to be closed", got, err)
+	}
+	expectReqEvent(t, reqChan, ReqIn)
+	expectReqEvent(t, reqChan, ReqOut)
+}
+
+func TestProxyUDP(t *testing.T) {
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() = %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() = %v", err)
+	}
+	const idleTimeout = 100 * time.Millisecond
+	reqChan := make(chan ReqEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- ProxyUDP(conn, echo.LocalAddr().String(), idleTimeout, reqChan)
+	}()
+
+	roundTrip := func(client net.Conn, msg string) {
+		t.Helper()
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 16)
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatalf("Read() = %v", err)
+		}
+		if got := string(buf[:n]); got != msg {
+			t.Errorf("Reply = %q, want: %q", got, msg)
+		}
+	}
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	defer client.Close()
+	roundTrip(client, "PING")
+	expectReqEvent(t, reqChan, ReqIn)
+	// Datagrams of the same client share the session.
+	roundTrip(client, "PONG")
+	select {
+	case e := <-reqChan:
+		t.Errorf("Got event %v while the session was active", e.EventType)
+	case <-time.After(idleTimeout / 2):
+	}
+	// The session ends once the client is idle.
+	expectReqEvent(t, reqChan, ReqOut)
+
+	// The next datagram opens a new session.
+	roundTrip(client, "PING")
+	expectReqEvent(t, reqChan, ReqIn)
+
+	conn.Close()
+	if err := <-done; err == nil {
+		t.Error("ProxyUDP() = nil after the connection was closed, want an error")
+	}
+	expectReqEvent(t, reqChan, ReqOut)
+}
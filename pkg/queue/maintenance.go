@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+// MaintenanceHandler answers the requests with the given page and a 503
+// status, without passing them on to h. The probes still go to h, so the
+// pod stays ready and keeps receiving the requests to answer. The
+// Content-Type is detected from the page when contentType is empty, and a
+// default message is sent when page is empty.
+func MaintenanceHandler(h http.Handler, page, contentType string) http.Handler {
+	if page == "" {
+		page, contentType = "Revision is under maintenance", "text/plain; charset=utf-8"
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType([]byte(page))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(network.ProbeHeaderName) != "" || network.IsKubeletProbe(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(page))
+	})
+}
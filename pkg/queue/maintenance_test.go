@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestMaintenanceHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		page            string
+		contentType     string
+		header          http.Header
+		wantStatus      int
+		wantBody        string
+		wantContentType string
+	}{{
+		name:            "default page",
+		wantStatus:      http.StatusServiceUnavailable,
+		wantBody:        "Revision is under maintenance",
+		wantContentType: "text/plain; charset=utf-8",
+	}, {
+		name:            "detected content type",
+		page:            "<html><body>Back soon</body></html>",
+		wantStatus:      http.StatusServiceUnavailable,
+		wantBody:        "<html><body>Back soon</body></html>",
+		wantContentType: "text/html; charset=utf-8",
+	}, {
+		name:            "configured content type",
+		page:            `{"error": "maintenance"}`,
+		contentType:     "application/json",
+		wantStatus:      http.StatusServiceUnavailable,
+		wantBody:        `{"error": "maintenance"}`,
+		wantContentType: "application/json",
+	}, {
+		name:       "knative probe",
+		page:       "Back soon",
+		header:     http.Header{network.ProbeHeaderName: []string{Name}},
+		wantStatus: http.StatusOK,
+		wantBody:   "user-container",
+	}, {
+		name:       "kubelet probe",
+		page:       "Back soon",
+		header:     http.Header{network.KubeletProbeHeaderName: []string{"queue"}},
+		wantStatus: http.StatusOK,
+		wantBody:   "user-container",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := MaintenanceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("user-container"))
+			}), test.page, test.contentType)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+
+			if got, want := resp.Code, test.wantStatus; got != want {
+				t.Errorf("Status = %d, want: %d", got, want)
+			}
+			if got, want := resp.Body.String(), test.wantBody; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+			if test.wantContentType != "" {
+				if got, want := resp.Header().Get("Content-Type"), test.wantContentType; got != want {
+					t.Errorf("Content-Type = %q, want: %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+)
+
+// overflowRequestKey is the context key of the request a Breaker call
+// serves, for OverflowHandler.
+type overflowRequestKey struct{}
+
+type overflowRequest struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+// WithOverflowRequest returns a context carrying the request a Breaker
+// call serves, so that the Overflow of an OverflowHandler can serve it
+// instead when the call is rejected.
+func WithOverflowRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	return context.WithValue(ctx, overflowRequestKey{}, overflowRequest{w: w, r: r})
+}
+
+// OverflowHandler returns an Overflow for BreakerParams serving the
+// requests of the rejected calls with h, e.g. a proxy to a fallback
+// target. Calls without a context from WithOverflowRequest still fail
+// with ErrQueueFull.
+func OverflowHandler(h http.Handler) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		or, ok := ctx.Value(overflowRequestKey{}).(overflowRequest)
+		if !ok {
+			return ErrQueueFull
+		}
+		h.ServeHTTP(or.w, or.r)
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOverflowHandler(t *testing.T) {
+	overflow := OverflowHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	// Without the request in the context, the call is still rejected.
+	if got, want := overflow(context.Background()), ErrQueueFull; got != want {
+		t.Errorf("overflow() = %v, want: %v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := overflow(WithOverflowRequest(context.Background(), rec, req)); err != nil {
+		t.Errorf("overflow() = %v, want: nil", err)
+	}
+	if got, want := rec.Code, http.StatusTeapot; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/network"
+)
+
+// PathBreakers limit the concurrency of the requests by the prefix of their
+// path, each prefix with its own Breaker. They are meant to be composed with
+// the Breaker of the container concurrency, in front of it, so that the
+// requests to a slow endpoint wait in their own queue rather than taking all
+// of the slots of the container.
+type PathBreakers struct {
+	// routes are sorted by decreasing length of their prefix, for the
+	// longest prefix to match first.
+	routes []pathBreaker
+}
+
+type pathBreaker struct {
+	prefix  string
+	breaker *Breaker
+}
+
+// NewPathBreakers creates the PathBreakers of the limits, queueing up to
+// QueueDepthPerConcurrency requests per slot of each prefix.
+func NewPathBreakers(limits serving.PathConcurrency) *PathBreakers {
+	pb := &PathBreakers{}
+	for prefix, concurrency := range limits {
+		pb.routes = append(pb.routes, pathBreaker{
+			prefix: prefix,
+			breaker: NewBreaker(BreakerParams{
+				QueueDepth:      concurrency * QueueDepthPerConcurrency,
+				MaxConcurrency:  concurrency,
+				InitialCapacity: concurrency,
+			}),
+		})
+	}
+	sort.Slice(pb.routes, func(i, j int) bool {
+		return len(pb.routes[i].prefix) > len(pb.routes[j].prefix)
+	})
+	return pb
+}
+
+// Breaker returns the Breaker of the longest prefix of path, or nil if no
+// prefix matches it.
+func (pb *PathBreakers) Breaker(path string) *Breaker {
+	for _, route := range pb.routes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.breaker
+		}
+	}
+	return nil
+}
+
+// PathConcurrencyHandler lets the requests through the Breaker of their path
+// in pb, if any, before passing them on to h. The requests it doesn't let
+// through are answered as the Breaker failed them. Probes are always passed
+// on.
+func PathConcurrencyHandler(h http.Handler, pb *PathBreakers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if network.IsKubeletProbe(r) || r.Header.Get(network.ProbeHeaderName) != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		breaker := pb.Breaker(r.URL.Path)
+		if breaker == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// Requests timing out while still queued were never seen by the
+		// user container.
+		SetTimeoutStatus(r.Context(), http.StatusGatewayTimeout)
+		if err := breaker.MaybeContext(r.Context(), func() {
+			SetTimeoutStatus(r.Context(), http.StatusServiceUnavailable)
+			h.ServeHTTP(w, r)
+		}); err != nil {
+			if err == ErrQueueFull {
+				w.Header().Set(network.OverloadedByHeaderName, Name)
+			}
+			http.Error(w, err.Error(), ErrorStatusCode(err))
+		}
+	}
+}
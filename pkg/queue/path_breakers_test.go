@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestPathBreakers(t *testing.T) {
+	pb := NewPathBreakers(serving.PathConcurrency{
+		"/report":        2,
+		"/report/daily/": 1,
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{{
+		path: "/report",
+		want: 2,
+	}, {
+		path: "/reports/weekly",
+		want: 2,
+	}, {
+		path: "/report/daily/today",
+		want: 1,
+	}, {
+		path: "/healthy",
+	}, {
+		path: "/",
+	}}
+	for _, test := range tests {
+		b := pb.Breaker(test.path)
+		var got int
+		if b != nil {
+			got = b.Capacity()
+		}
+		if got != test.want {
+			t.Errorf("Breaker(%q).Capacity() = %d, want: %d", test.path, got, test.want)
+		}
+	}
+}
+
+func TestPathConcurrencyHandler(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	h := PathConcurrencyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/report/slow" {
+			started <- struct{}{}
+			<-unblock
+		}
+	}), NewPathBreakers(serving.PathConcurrency{"/report": 1}))
+
+	serve := func(ctx context.Context, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil).WithContext(ctx)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Take the only slot of /report.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(context.Background(), "/report/slow", nil)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if got := serve(ctx, "/report/fast", nil); got.Code != http.StatusGatewayTimeout {
+		t.Errorf("Status of a queued request = %d, want: %d", got.Code, http.StatusGatewayTimeout)
+	}
+	if got := serve(context.Background(), "/other", nil); got.Code != http.StatusOK {
+		t.Errorf("Status of another path = %d, want: %d", got.Code, http.StatusOK)
+	}
+	if got := serve(context.Background(), "/report/fast", http.Header{network.ProbeHeaderName: {"queue"}}); got.Code != http.StatusOK {
+		t.Errorf("Probe status = %d, want: %d", got.Code, http.StatusOK)
+	}
+
+	close(unblock)
+	if got := <-done; got.Code != http.StatusOK {
+		t.Errorf("Status of the slow request = %d, want: %d", got.Code, http.StatusOK)
+	}
+	if got := serve(context.Background(), "/report/fast", nil); got.Code != http.StatusOK {
+		t.Errorf("Status once the slot is free = %d, want: %d", got.Code, http.StatusOK)
+	}
+}
@@ -58,6 +58,12 @@ var (
 	averageProxiedConcurrentRequestsGV = newGV(
 		"queue_average_proxied_concurrent_requests",
 		"Number of proxied requests currently being handled by this pod")
+	averageBytesInFlightGV = newGV(
+		"queue_average_bytes_in_flight",
+		"Number of request body bytes currently being handled by this pod")
+	averageRequestDurationGV = newGV(
+		"queue_average_request_duration_seconds",
+		"Average duration of the requests completed by this pod")
 )
 
 func newGV(n, h string) *prometheus.GaugeVec {
@@ -90,7 +96,7 @@ func NewPrometheusStatsReporter(namespace, config, revision, pod string) (*Prome
 	}
 
 	registry := prometheus.NewRegistry()
-	for _, gv := range []*prometheus.GaugeVec{operationsPerSecondGV, proxiedOperationsPerSecondGV, averageConcurrentRequestsGV, averageProxiedConcurrentRequestsGV} {
+	for _, gv := range []*prometheus.GaugeVec{operationsPerSecondGV, proxiedOperationsPerSecondGV, averageConcurrentRequestsGV, averageProxiedConcurrentRequestsGV, averageBytesInFlightGV, averageRequestDurationGV} {
 		if err := registry.Register(gv); err != nil {
 			return nil, fmt.Errorf("register metric failed: %v", err)
 		}
@@ -118,6 +124,8 @@ func (r *PrometheusStatsReporter) Report(stat *autoscaler.Stat) error {
 	proxiedOperationsPerSecondGV.With(r.labels).Set(stat.ProxiedRequestCount)
 	averageConcurrentRequestsGV.With(r.labels).Set(stat.AverageConcurrentRequests)
 	averageProxiedConcurrentRequestsGV.With(r.labels).Set(stat.AverageProxiedConcurrentRequests)
+	averageBytesInFlightGV.With(r.labels).Set(stat.AverageBytesInFlight)
+	averageRequestDurationGV.With(r.labels).Set(stat.AverageRequestDuration)
 
 	return nil
 }
@@ -98,6 +98,12 @@ func TestReporter_Report(t *testing.T) {
 	testReportWithProxiedRequests(t, &autoscaler.Stat{RequestCount: 39, AverageConcurrentRequests: 3, ProxiedRequestCount: 15, AverageProxiedConcurrentRequests: 2}, 39, 3, 15, 2)
 }
 
+func TestReporter_ReportBytesAndDuration(t *testing.T) {
+	testReportWithProxiedRequests(t, &autoscaler.Stat{RequestCount: 39, AverageConcurrentRequests: 3, AverageBytesInFlight: 1024, AverageRequestDuration: 0.5}, 39, 3, 0, 0)
+	checkData(t, averageBytesInFlightGV, 1024)
+	checkData(t, averageRequestDurationGV, 0.5)
+}
+
 func testReportWithProxiedRequests(t *testing.T, stat *autoscaler.Stat, reqCount, concurrency, proxiedCount, proxiedConcurrency float64) {
 	t.Helper()
 	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod)
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/knative/serving/pkg/network"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter is a token bucket limiting the requests per second let through
+// to the user container, which the Breaker doesn't for handlers fast enough
+// for the container concurrency not to bound their rate. It is meant to be
+// composed with the Breaker, in front of it, so that the requests over the
+// limit don't take a slot in its queue.
+type RateLimiter struct {
+	limiter *rate.Limiter
+	now     func() time.Time
+}
+
+// NewRateLimiter creates a RateLimiter letting through rps requests per
+// second, up to burst of them at once.
+func NewRateLimiter(rps, burst int) *RateLimiter {
+	return &RateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(rps), burst),
+		now:     time.Now,
+	}
+}
+
+// Allow returns whether a request may be let through now and, if it may
+// not, how long until one may.
+func (l *RateLimiter) Allow() (bool, time.Duration) {
+	now := l.now()
+	r := l.limiter.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		// Give the token back, the request isn't going to wait for it.
+		r.CancelAt(now)
+		return false, d
+	}
+	return true, 0
+}
+
+// RateLimitHandler answers the requests over the rate of l with 429 Too Many
+// Requests, without passing them on to h, and hints when to retry them.
+// Probes are always passed on.
+func RateLimitHandler(h http.Handler, l *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if network.IsKubeletProbe(r) || r.Header.Get(network.ProbeHeaderName) != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if ok, d := l.Allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+			http.Error(w, "requests per second limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+}
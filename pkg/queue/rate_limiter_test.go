@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter(2, 4)
+	l.now = func() time.Time { return now }
+
+	allowed := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if ok, _ := l.Allow(); ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The burst is let through at once.
+	if got, want := allowed(), 4; got != want {
+		t.Errorf("Allowed %d requests, want: %d", got, want)
+	}
+	if ok, d := l.Allow(); ok || d != 500*time.Millisecond {
+		t.Errorf("Allow() = %v, %v, want: false, 500ms", ok, d)
+	}
+
+	// Then the rate.
+	now = now.Add(time.Second)
+	if got, want := allowed(), 2; got != want {
+		t.Errorf("Allowed %d requests a second later, want: %d", got, want)
+	}
+
+	// The rejected requests don't take the tokens.
+	now = now.Add(2 * time.Second)
+	if got, want := allowed(), 4; got != want {
+		t.Errorf("Allowed %d requests two seconds later, want: %d", got, want)
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	h := RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), l)
+
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got := serve(nil); got.Code != http.StatusOK {
+		t.Errorf("Status = %d, want: %d", got.Code, http.StatusOK)
+	}
+	got := serve(nil)
+	if got.Code != http.StatusTooManyRequests {
+		t.Errorf("Status = %d, want: %d", got.Code, http.StatusTooManyRequests)
+	}
+	if got, want := got.Header().Get("Retry-After"), "1"; got != want {
+		t.Errorf("Retry-After = %q, want: %q", got, want)
+	}
+	if got := serve(http.Header{network.ProbeHeaderName: {"queue"}}); got.Code != http.StatusOK {
+		t.Errorf("Probe status = %d, want: %d", got.Code, http.StatusOK)
+	}
+}
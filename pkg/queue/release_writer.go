@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	pkghttp "github.com/knative/serving/pkg/http"
+	"knative.dev/pkg/websocket"
+)
+
+// ReleaseOnHeaders returns a ResponseWriter that calls release once the
+// response headers of w are committed, i.e. on the first final WriteHeader,
+// the first Write or when the connection is hijacked for an upgrade.
+// Informational responses, like 103 Early Hints, don't commit the headers.
+func ReleaseOnHeaders(w http.ResponseWriter, release func()) http.ResponseWriter {
+	return &releaseWriter{writer: w, release: release}
+}
+
+type releaseWriter struct {
+	writer  http.ResponseWriter
+	release func()
+}
+
+var (
+	_ http.Flusher        = (*releaseWriter)(nil)
+	_ http.Hijacker       = (*releaseWriter)(nil)
+	_ http.ResponseWriter = (*releaseWriter)(nil)
+)
+
+func (rw *releaseWriter) Header() http.Header { return rw.writer.Header() }
+
+func (rw *releaseWriter) Write(p []byte) (int, error) {
+	rw.release()
+	return rw.writer.Write(p)
+}
+
+func (rw *releaseWriter) WriteHeader(code int) {
+	rw.writer.WriteHeader(code)
+	if !pkghttp.IsInformational(code) {
+		rw.release()
+	}
+}
+
+func (rw *releaseWriter) Flush() {
+	if f, ok := rw.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (rw *releaseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.release()
+	return websocket.HijackIfPossible(rw.writer)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReleaseOnHeaders(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(http.ResponseWriter)
+		want  int
+	}{{
+		name:  "nothing written",
+		write: func(w http.ResponseWriter) { w.Header().Set("Foo", "bar") },
+	}, {
+		name:  "early hints",
+		write: func(w http.ResponseWriter) { w.WriteHeader(http.StatusEarlyHints) },
+	}, {
+		name:  "write header",
+		write: func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+		want:  1,
+	}, {
+		name:  "implicit header",
+		write: func(w http.ResponseWriter) { w.Write([]byte("hello")) },
+		want:  1,
+	}, {
+		name: "streaming body",
+		write: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("hello"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("world"))
+		},
+		want: 3,
+	}, {
+		name: "hijack",
+		write: func(w http.ResponseWriter) {
+			// The recorder can't be hijacked, but the slot is given up anyway.
+			w.(http.Hijacker).Hijack()
+		},
+		want: 1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			rec := httptest.NewRecorder()
+			test.write(ReleaseOnHeaders(rec, func() { calls++ }))
+			if calls != test.want {
+				t.Errorf("release called %d times, want: %d", calls, test.want)
+			}
+		})
+	}
+}
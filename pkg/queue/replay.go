@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	pkghttp "github.com/knative/serving/pkg/http"
+)
+
+// ReplayTransport is a RoundTripper sending the idempotent requests once
+// more when the first attempt got no response, e.g. because the user
+// container crashed while serving it, instead of failing them with a 502.
+// The bodies of the requests are buffered up to a limit to be replayed.
+type ReplayTransport struct {
+	transport    http.RoundTripper
+	maxBodyBytes int64
+	methods      map[string]bool
+	wait         func(ctx context.Context) error
+}
+
+// NewReplayTransport returns a ReplayTransport sending the requests with
+// transport. The GET and HEAD requests, and those with one of the given
+// methods, are replayed if their body is at most maxBodyBytes. wait is
+// called before the replay, to wait for the user container to be back,
+// and the request fails with the error of the first attempt if it fails.
+func NewReplayTransport(transport http.RoundTripper, maxBodyBytes int64, methods []string, wait func(ctx context.Context) error) *ReplayTransport {
+	t := &ReplayTransport{
+		transport:    transport,
+		maxBodyBytes: maxBodyBytes,
+		methods:      map[string]bool{http.MethodGet: true, http.MethodHead: true},
+		wait:         wait,
+	}
+	for _, m := range methods {
+		t.methods[m] = true
+	}
+	return t
+}
+
+var _ http.RoundTripper = (*ReplayTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// Upgraded connections and bodies known to be too large aren't replayed.
+	if !t.methods[r.Method] || pkghttp.UpgradeProtocol(r) != "" || r.ContentLength > t.maxBodyBytes {
+		return t.transport.RoundTrip(r)
+	}
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, t.maxBodyBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(b)) > t.maxBodyBytes {
+			// Too large to be replayed, send what was read and the rest.
+			rr := *r
+			rr.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			return t.transport.RoundTrip(&rr)
+		}
+		r.Body.Close()
+		body = b
+	}
+
+	resp, err := t.transport.RoundTrip(withBody(r, body))
+	if err == nil || r.Context().Err() != nil {
+		return resp, err
+	}
+	if t.wait(r.Context()) != nil {
+		return nil, err
+	}
+	return t.transport.RoundTrip(withBody(r, body))
+}
+
+// withBody returns a shallow copy of r sending body.
+func withBody(r *http.Request, body []byte) *http.Request {
+	rr := *r
+	if body == nil {
+		rr.Body = nil
+		return &rr
+	}
+	rr.Body = ioutil.NopCloser(bytes.NewReader(body))
+	rr.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return &rr
+}
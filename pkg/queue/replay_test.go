@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// flakyTransport fails the first `failures` requests and records the
+// bodies of all of them.
+type flakyTransport struct {
+	failures int
+	bodies   []string
+}
+
+var errCrashed = errors.New("connection reset by peer")
+
+func (t *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := ""
+	if r.Body != nil {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}
+	t.bodies = append(t.bodies, body)
+	if len(t.bodies) <= t.failures {
+		return nil, errCrashed
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestReplayTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		failures int
+		waitErr  error
+		want     []string
+		waits    int
+		wantErr  bool
+	}{{
+		name:   "no failure",
+		method: http.MethodGet,
+		want:   []string{""},
+	}, {
+		name:     "get replayed",
+		method:   http.MethodGet,
+		failures: 1,
+		want:     []string{"", ""},
+		waits:    1,
+	}, {
+		name:     "body replayed",
+		method:   http.MethodPut,
+		body:     "hello",
+		failures: 1,
+		want:     []string{"hello", "hello"},
+		waits:    1,
+	}, {
+		name:     "replayed once",
+		method:   http.MethodHead,
+		failures: 2,
+		want:     []string{"", ""},
+		waits:    1,
+		wantErr:  true,
+	}, {
+		name:     "not idempotent",
+		method:   http.MethodPost,
+		body:     "hello",
+		failures: 1,
+		want:     []string{"hello"},
+		wantErr:  true,
+	}, {
+		name:     "body too large",
+		method:   http.MethodPut,
+		body:     "hello world",
+		failures: 1,
+		want:     []string{"hello world"},
+		wantErr:  true,
+	}, {
+		name:     "container not back",
+		method:   http.MethodGet,
+		failures: 1,
+		waitErr:  errors.New("timed out"),
+		want:     []string{""},
+		waits:    1,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flaky := &flakyTransport{failures: test.failures}
+			waited := 0
+			rt := NewReplayTransport(flaky, 5, []string{http.MethodPut}, func(context.Context) error {
+				waited++
+				return test.waitErr
+			})
+
+			r, _ := http.NewRequest(test.method, "http://example.com", strings.NewReader(test.body))
+			if test.body == "" {
+				r.Body = nil
+			}
+			// The length of streamed bodies is unknown.
+			r.ContentLength = -1
+			_, err := rt.RoundTrip(r)
+			if (err != nil) != test.wantErr {
+				t.Errorf("RoundTrip() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil && err != errCrashed {
+				t.Errorf("RoundTrip() = %v, want the error of the first attempt", err)
+			}
+			if diff := cmp.Diff(test.want, flaky.bodies); diff != "" {
+				t.Errorf("Sent bodies (-want +got): %v", diff)
+			}
+			if waited != test.waits {
+				t.Errorf("Waited %d times, want: %d", waited, test.waits)
+			}
+		})
+	}
+}
+
+func TestReplayTransportCanceled(t *testing.T) {
+	flaky := &flakyTransport{failures: 1}
+	rt := NewReplayTransport(flaky, 5, nil, func(context.Context) error {
+		t.Error("Unexpected wait for a canceled request")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if _, err := rt.RoundTrip(r.WithContext(ctx)); err != errCrashed {
+		t.Errorf("RoundTrip() = %v, want: %v", err, errCrashed)
+	}
+	if len(flaky.bodies) != 1 {
+		t.Errorf("Sent %d requests, want: 1", len(flaky.bodies))
+	}
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pkghttp "github.com/knative/serving/pkg/http"
+	"github.com/knative/serving/pkg/network"
+	"knative.dev/pkg/websocket"
+)
+
+// ResponseCache is an in-memory LRU cache of the responses of the user
+// container to GET and HEAD requests, bounded by their size. Only the
+// responses explicitly made cacheable by their Cache-Control are cached,
+// for a TTL, or their max-age if that's shorter, apart for each of the
+// values of the request headers they Vary on.
+type ResponseCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	now      func() time.Time
+
+	mux   sync.Mutex
+	bytes int64
+	// lru holds the *cachedResponse, the most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+	// varies are the names of the headers the responses vary on, by the
+	// method and URI of their requests.
+	varies map[string]*vary
+}
+
+type vary struct {
+	names   []string
+	entries int
+}
+
+type cachedResponse struct {
+	key     string
+	primary string
+	header  http.Header
+	body    []byte
+	size    int64
+	stored  time.Time
+	expires time.Time
+}
+
+// NewResponseCache creates a ResponseCache of at most maxBytes of
+// responses, each cached for at most ttl.
+func NewResponseCache(maxBytes int64, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		varies:   make(map[string]*vary),
+	}
+}
+
+// get returns the fresh response cached for the request, if any.
+func (c *ResponseCache) get(r *http.Request) *cachedResponse {
+	primary := primaryKey(r)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	v, ok := c.varies[primary]
+	if !ok {
+		return nil
+	}
+	e, ok := c.entries[variantKey(primary, v.names, r.Header)]
+	if !ok {
+		return nil
+	}
+	cr := e.Value.(*cachedResponse)
+	if !c.now().Before(cr.expires) {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return cr
+}
+
+// put caches the response to the request for ttl, evicting the least
+// recently used responses to make room for it.
+func (c *ResponseCache) put(r *http.Request, header http.Header, body []byte, ttl time.Duration) {
+	primary := primaryKey(r)
+	names := varyNames(header)
+	key := variantKey(primary, names, r.Header)
+	size := int64(len(key)+len(body)) + headerSize(header)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	for c.bytes+size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+	v, ok := c.varies[primary]
+	if !ok {
+		v = &vary{}
+		c.varies[primary] = v
+	}
+	// The variants cached under other names are left to expire.
+	v.names = names
+	v.entries++
+
+	now := c.now()
+	c.entries[key] = c.lru.PushFront(&cachedResponse{
+		key:     key,
+		primary: primary,
+		header:  header,
+		body:    body,
+		size:    size,
+		stored:  now,
+		expires: now.Add(ttl),
+	})
+	c.bytes += size
+}
+
+// remove drops the cached response of e. `mux` must be held to call it.
+func (c *ResponseCache) remove(e *list.Element) {
+	cr := c.lru.Remove(e).(*cachedResponse)
+	delete(c.entries, cr.key)
+	c.bytes -= cr.size
+	if v := c.varies[cr.primary]; v != nil {
+		if v.entries--; v.entries <= 0 {
+			delete(c.varies, cr.primary)
+		}
+	}
+}
+
+// primaryKey is the key of the responses to r, before they vary on its
+// headers. It includes the Host, since a revision may serve several
+// domains, e.g. through its tag routes.
+func primaryKey(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+func variantKey(primary string, names []string, header http.Header) string {
+	var b strings.Builder
+	b.WriteString(primary)
+	for _, name := range names {
+		b.WriteString("\n" + name + ": " + strings.Join(header[name], ", "))
+	}
+	return b.String()
+}
+
+// varyNames returns the canonical names of the request headers the
+// response varies on.
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, v := range header["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func headerSize(header http.Header) int64 {
+	var size int64
+	for name, values := range header {
+		for _, v := range values {
+			size += int64(len(name) + len(v))
+		}
+	}
+	return size
+}
+
+// cacheableRequest returns whether the response to the request may be
+// answered from, and stored in, the cache. The responses to the requests
+// carrying credentials or an identity may be specific to their client.
+func cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	for _, name := range []string{"Authorization", "Cookie",
+		network.AuthenticatedIdentityHeaderName,
+		network.ClientCertVerifiedHeaderName,
+		network.ForwardedClientCertHeaderName} {
+		if r.Header.Get(name) != "" {
+			return false
+		}
+	}
+	if pkghttp.UpgradeProtocol(r) != "" {
+		return false
+	}
+	// The client asks for a response from the user container.
+	for _, d := range cacheDirectives(r.Header) {
+		if d == "no-cache" || d == "no-store" {
+			return false
+		}
+	}
+	return true
+}
+
+// responseTTL returns how long the response may be cached for, at most
+// ttl, or 0 if it may not be. Only the responses whose Cache-Control is
+// public or has a max-age or s-maxage may be cached.
+func responseTTL(status int, header http.Header, ttl time.Duration) time.Duration {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return 0
+	}
+	for _, name := range varyNames(header) {
+		if name == "*" {
+			return 0
+		}
+	}
+
+	public, maxAge, sMaxAge := false, -1, -1
+	for _, d := range cacheDirectives(header) {
+		switch {
+		case d == "no-store", d == "no-cache", d == "private",
+			strings.HasPrefix(d, "no-cache="), strings.HasPrefix(d, "private="):
+			return 0
+		case d == "public":
+			public = true
+		case strings.HasPrefix(d, "max-age="):
+			maxAge = parseAge(d[len("max-age="):])
+		case strings.HasPrefix(d, "s-maxage="):
+			sMaxAge = parseAge(d[len("s-maxage="):])
+		}
+	}
+	// The s-maxage is meant for shared caches like this one.
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge >= 0 {
+		if age := time.Duration(maxAge) * time.Second; age < ttl {
+			return age
+		}
+		return ttl
+	}
+	if public {
+		return ttl
+	}
+	return 0
+}
+
+// cacheDirectives returns the lowercased directives of the Cache-Control
+// header.
+func cacheDirectives(header http.Header) []string {
+	var directives []string
+	for _, v := range header["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives
+}
+
+// parseAge parses the seconds of an age directive. Invalid ages are 0,
+// i.e. the response is stale right away.
+func parseAge(v string) int {
+	age, err := strconv.Atoi(strings.Trim(v, `"`))
+	if err != nil || age < 0 {
+		return 0
+	}
+	return age
+}
+
+// ResponseCacheHandler answers the GET and HEAD requests with the
+// responses cached in c, and caches the responses of h to them. The
+// answers from the cache neither take a slot of the breaker nor count
+// towards the concurrency, so it must wrap the handler doing that.
+func ResponseCacheHandler(h http.Handler, c *ResponseCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cacheableRequest(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if cr := c.get(r); cr != nil {
+			cr.serve(w, c.now())
+			return
+		}
+
+		cw := &cachingWriter{writer: w, limit: c.maxBytes}
+		h.ServeHTTP(cw, r)
+		if cw.skip {
+			return
+		}
+		if ttl := responseTTL(cw.status, cw.header, c.ttl); ttl > 0 {
+			c.put(r, cw.header, cw.body.Bytes(), ttl)
+		}
+	})
+}
+
+// serve writes the cached response, with its Age as of now.
+func (cr *cachedResponse) serve(w http.ResponseWriter, now time.Time) {
+	header := w.Header()
+	for name, values := range cr.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(cr.stored)/time.Second)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(cr.body)
+}
+
+// cachingWriter keeps a copy of the response it writes, unless it's
+// larger than the limit.
+type cachingWriter struct {
+	writer http.ResponseWriter
+	limit  int64
+
+	status int
+	header http.Header
+	body   bytes.Buffer
+	// skip is set when the response can't be cached, e.g. because it's
+	// too large or wasn't written whole.
+	skip bool
+}
+
+var (
+	_ http.Flusher        = (*cachingWriter)(nil)
+	_ http.Hijacker       = (*cachingWriter)(nil)
+	_ http.ResponseWriter = (*cachingWriter)(nil)
+)
+
+func (cw *cachingWriter) Header() http.Header { return cw.writer.Header() }
+
+func (cw *cachingWriter) WriteHeader(code int) {
+	if cw.status == 0 && !pkghttp.IsInformational(code) {
+		cw.status = code
+		// The headers are committed, later changes aren't sent.
+		cw.header = make(http.Header, len(cw.writer.Header()))
+		for name, values := range cw.writer.Header() {
+			cw.header[name] = append([]string(nil), values...)
+		}
+	}
+	cw.writer.WriteHeader(code)
+}
+
+func (cw *cachingWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	n, err := cw.writer.Write(p)
+	switch {
+	case cw.skip:
+	case err != nil, int64(cw.body.Len()+n) > cw.limit:
+		cw.skip = true
+		cw.body = bytes.Buffer{}
+	default:
+		cw.body.Write(p[:n])
+	}
+	return n, err
+}
+
+func (cw *cachingWriter) Flush() {
+	if f, ok := cw.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (cw *cachingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.skip = true
+	return websocket.HijackIfPossible(cw.writer)
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/network"
+)
+
+var publicHeader = http.Header{"Cache-Control": {"public"}}
+
+// countingHandler answers with the response header and body, counting
+// the requests it gets.
+type countingHandler struct {
+	header http.Header
+	status int
+	body   string
+	calls  int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	for name, values := range h.header {
+		w.Header()[name] = values
+	}
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	w.Write([]byte(h.body))
+}
+
+func TestResponseCacheHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		reqHeader http.Header
+		header    http.Header
+		status    int
+		wantCalls int
+	}{{
+		name:      "cached",
+		header:    publicHeader,
+		wantCalls: 1,
+	}, {
+		name:      "no cache-control",
+		wantCalls: 2,
+	}, {
+		name:      "max-age",
+		header:    http.Header{"Cache-Control": {"max-age=60"}},
+		wantCalls: 1,
+	}, {
+		name:      "head",
+		method:    http.MethodHead,
+		header:    publicHeader,
+		wantCalls: 1,
+	}, {
+		name:      "post",
+		method:    http.MethodPost,
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "authorization",
+		reqHeader: http.Header{"Authorization": {"Bearer token"}},
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "request cookie",
+		reqHeader: http.Header{"Cookie": {"session=1"}},
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "authenticated identity",
+		reqHeader: http.Header{network.AuthenticatedIdentityHeaderName: {"spiffe://client"}},
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "client certificate",
+		reqHeader: http.Header{network.ClientCertVerifiedHeaderName: {network.ClientCertVerifiedSuccess}},
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "client asks for a fresh response",
+		reqHeader: http.Header{"Cache-Control": {"no-cache"}},
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "error",
+		status:    http.StatusInternalServerError,
+		header:    publicHeader,
+		wantCalls: 2,
+	}, {
+		name:      "cookie",
+		header:    http.Header{"Cache-Control": {"public"}, "Set-Cookie": {"session=1"}},
+		wantCalls: 2,
+	}, {
+		name:      "no-store",
+		header:    http.Header{"Cache-Control": {"no-store"}},
+		wantCalls: 2,
+	}, {
+		name:      "private",
+		header:    http.Header{"Cache-Control": {"max-age=60, Private"}},
+		wantCalls: 2,
+	}, {
+		name:      "public no-store",
+		header:    http.Header{"Cache-Control": {"public, no-store"}},
+		wantCalls: 2,
+	}, {
+		name:      "max-age=0",
+		header:    http.Header{"Cache-Control": {"max-age=0"}},
+		wantCalls: 2,
+	}, {
+		name:      "s-maxage over max-age",
+		header:    http.Header{"Cache-Control": {"max-age=0, s-maxage=60"}},
+		wantCalls: 1,
+	}, {
+		name:      "vary on everything",
+		header:    http.Header{"Cache-Control": {"public"}, "Vary": {"*"}},
+		wantCalls: 2,
+	}, {
+		name:      "vary on the same value",
+		reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+		header:    http.Header{"Cache-Control": {"public"}, "Vary": {"accept-encoding"}},
+		wantCalls: 1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := &countingHandler{header: test.header, status: test.status, body: "hello"}
+			h := ResponseCacheHandler(backend, NewResponseCache(1<<20, time.Minute))
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(method, "http://example.com/path?q=1", nil)
+				for name, values := range test.reqHeader {
+					req.Header[name] = values
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if method != http.MethodHead && rec.Body.String() != "hello" {
+					t.Errorf("Body = %q, want: %q", rec.Body.String(), "hello")
+				}
+			}
+			if backend.calls != test.wantCalls {
+				t.Errorf("Calls = %d, want: %d", backend.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	backend := &countingHandler{header: http.Header{"Cache-Control": {"public"}, "Vary": {"Accept-Encoding, Accept"}}, body: "hello"}
+	h := ResponseCacheHandler(backend, NewResponseCache(1<<20, time.Minute))
+
+	serve := func(path, encoding string) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("/", "gzip")
+	serve("/", "br")
+	serve("/", "gzip")
+	serve("/", "br")
+	serve("/other", "gzip")
+	if got, want := backend.calls, 3; got != want {
+		t.Errorf("Calls = %d, want: %d", got, want)
+	}
+}
+
+func TestResponseCacheHost(t *testing.T) {
+	backend := &countingHandler{header: publicHeader, body: "hello"}
+	h := ResponseCacheHandler(backend, NewResponseCache(1<<20, time.Minute))
+
+	for _, host := range []string{"example.com", "tag.example.com", "example.com", "tag.example.com"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+	}
+	if got, want := backend.calls, 2; got != want {
+		t.Errorf("Calls = %d, want: %d", got, want)
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewResponseCache(1<<20, time.Minute)
+	cache.now = func() time.Time { return now }
+	backend := &countingHandler{header: publicHeader, body: "hello"}
+	h := ResponseCacheHandler(backend, cache)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+		return rec
+	}
+
+	serve("/ttl")
+	now = now.Add(59 * time.Second)
+	if got, want := serve("/ttl").Header().Get("Age"), "59"; got != want {
+		t.Errorf("Age = %q, want: %q", got, want)
+	}
+	now = now.Add(time.Second)
+	serve("/ttl")
+	if got, want := backend.calls, 2; got != want {
+		t.Errorf("Calls after the TTL = %d, want: %d", got, want)
+	}
+
+	// A shorter max-age takes precedence.
+	backend.header = http.Header{"Cache-Control": {"public, max-age=10"}}
+	serve("/max-age")
+	now = now.Add(10 * time.Second)
+	serve("/max-age")
+	if got, want := backend.calls, 4; got != want {
+		t.Errorf("Calls after the max-age = %d, want: %d", got, want)
+	}
+	if len(cache.varies) != 2 || cache.lru.Len() != 2 {
+		t.Errorf("Cached %d responses of %d requests, want: 2 of 2", cache.lru.Len(), len(cache.varies))
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	backend := &countingHandler{header: publicHeader, body: body}
+	// Room for two of the responses.
+	cache := NewResponseCache(300, time.Minute)
+	h := ResponseCacheHandler(backend, cache)
+
+	serve := func(path string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+	}
+	serve("/a")
+	serve("/b")
+	serve("/a") // hit, /b is now the least recently used.
+	serve("/c") // evicts /b.
+	serve("/a") // hit.
+	serve("/b") // miss, evicts /c.
+	if got, want := backend.calls, 4; got != want {
+		t.Errorf("Calls = %d, want: %d", got, want)
+	}
+	if cache.bytes > cache.maxBytes {
+		t.Errorf("Cached %d bytes, want at most %d", cache.bytes, cache.maxBytes)
+	}
+
+	// Responses larger than the cache aren't cached.
+	backend.body = strings.Repeat("x", 300)
+	serve("/large")
+	serve("/large")
+	if got, want := backend.calls, 6; got != want {
+		t.Errorf("Calls = %d, want: %d", got, want)
+	}
+	if got, want := cache.lru.Len(), 2; got != want {
+		t.Errorf("Cached %d responses, want: %d", got, want)
+	}
+}
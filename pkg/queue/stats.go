@@ -26,6 +26,12 @@ import (
 type ReqEvent struct {
 	Time      time.Time
 	EventType ReqEventType
+	// Bytes is the size of the request body, if known. It must be the
+	// same for the incoming and the closed event of a request.
+	Bytes int64
+	// Duration is the time it took to handle the request. Only set for
+	// closed requests.
+	Duration time.Duration
 }
 
 // ReqEventType denotes the type (incoming/closed) of a ReqEvent.
@@ -40,6 +46,16 @@ const (
 	ProxiedIn
 	// ProxiedOut represents a finished proxied request.
 	ProxiedOut
+	// ReqIdle represents a request that is still open but went idle, e.g.
+	// a WebSocket connection without traffic. It stops counting towards
+	// the concurrency until the matching ReqActive.
+	ReqIdle
+	// ReqActive represents an idle request that is active again.
+	ReqActive
+	// ProxiedIdle represents a proxied request that went idle.
+	ProxiedIdle
+	// ProxiedActive represents an idle proxied request that is active again.
+	ProxiedActive
 )
 
 // Channels is a structure for holding the channels for driving Stats.
@@ -72,11 +88,16 @@ func NewStats(podName string, channels Channels, startedAt time.Time) *Stats {
 			proxiedCount       float64
 			concurrency        int32
 			proxiedConcurrency int32
+			bytesInFlight      int64
+			completedCount     float64
+			totalDuration      time.Duration
 		)
 
 		lastChange := startedAt
 		timeOnConcurrency := make(map[int32]time.Duration)
 		timeOnProxiedConcurrency := make(map[int32]time.Duration)
+		// The integral of bytesInFlight over time and the time covered by it.
+		var byteSeconds, reportedSeconds float64
 
 		// Updates the lastChanged/timeOnConcurrency state
 		// Note: Due to nature of the channels used below, the ReportChan
@@ -88,6 +109,8 @@ func NewStats(podName string, channels Channels, startedAt time.Time) *Stats {
 				durationSinceChange := time.Sub(lastChange)
 				timeOnConcurrency[concurrency] += durationSinceChange
 				timeOnProxiedConcurrency[proxiedConcurrency] += durationSinceChange
+				byteSeconds += float64(bytesInFlight) * durationSinceChange.Seconds()
+				reportedSeconds += durationSinceChange.Seconds()
 				lastChange = time
 			}
 		}
@@ -105,11 +128,25 @@ func NewStats(podName string, channels Channels, startedAt time.Time) *Stats {
 				case ReqIn:
 					requestCount++
 					concurrency++
+					bytesInFlight += event.Bytes
 				case ProxiedOut:
 					proxiedConcurrency--
 					fallthrough
 				case ReqOut:
 					concurrency--
+					bytesInFlight -= event.Bytes
+					completedCount++
+					totalDuration += event.Duration
+				case ProxiedIdle:
+					proxiedConcurrency--
+					fallthrough
+				case ReqIdle:
+					concurrency--
+				case ProxiedActive:
+					proxiedConcurrency++
+					fallthrough
+				case ReqActive:
+					concurrency++
 				}
 			case now := <-s.ch.ReportChan:
 				updateState(now)
@@ -122,6 +159,12 @@ func NewStats(podName string, channels Channels, startedAt time.Time) *Stats {
 					RequestCount:                     requestCount,
 					ProxiedRequestCount:              proxiedCount,
 				}
+				if reportedSeconds > 0 {
+					stat.AverageBytesInFlight = byteSeconds / reportedSeconds
+				}
+				if completedCount > 0 {
+					stat.AverageRequestDuration = totalDuration.Seconds() / completedCount
+				}
 				// Send the stat to another goroutine to transmit
 				// so we can continue bucketing stats.
 				s.ch.StatChan <- stat
@@ -131,6 +174,9 @@ func NewStats(podName string, channels Channels, startedAt time.Time) *Stats {
 				timeOnProxiedConcurrency = make(map[int32]time.Duration)
 				requestCount = 0
 				proxiedCount = 0
+				byteSeconds, reportedSeconds = 0, 0
+				completedCount = 0
+				totalDuration = 0
 			}
 		}
 	}()
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// CPUReporter reports the CPU time attributed to the requests of a revision.
+type CPUReporter struct {
+	ctx       context.Context
+	cpuMetric *stats.Float64Measure
+}
+
+// NewCPUReporter creates a reporter that sums up the CPU seconds recorded
+// in cpuMetric for the revision.
+func NewCPUReporter(ns, service, config, rev string, cpuMetric *stats.Float64Measure) (*CPUReporter, error) {
+	if ns == "" {
+		return nil, errors.New("namespace must not be empty")
+	}
+	if config == "" {
+		return nil, errors.New("config must not be empty")
+	}
+	if rev == "" {
+		return nil, errors.New("revision must not be empty")
+	}
+
+	nsTag, err := tag.NewKey(metricskey.LabelNamespaceName)
+	if err != nil {
+		return nil, err
+	}
+	svcTag, err := tag.NewKey(metricskey.LabelServiceName)
+	if err != nil {
+		return nil, err
+	}
+	configTag, err := tag.NewKey(metricskey.LabelConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+	revTag, err := tag.NewKey(metricskey.LabelRevisionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := view.Register(&view.View{
+		Description: cpuMetric.Description(),
+		Measure:     cpuMetric,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{nsTag, svcTag, configTag, revTag},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(nsTag, ns),
+		tag.Insert(svcTag, valueOrUnknown(service)),
+		tag.Insert(configTag, config),
+		tag.Insert(revTag, rev),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CPUReporter{
+		ctx:       ctx,
+		cpuMetric: cpuMetric,
+	}, nil
+}
+
+// ReportCPUTime adds the CPU time d spent on a request.
+func (r *CPUReporter) ReportCPUTime(d time.Duration) {
+	metrics.Record(r.ctx, r.cpuMetric.M(d.Seconds()))
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+func TestCPUReporter(t *testing.T) {
+	const cpuName = "request_cpu_seconds"
+	cpuMetric := stats.Float64(
+		cpuName,
+		"The CPU time spent on requests in seconds",
+		"s")
+
+	if _, err := NewCPUReporter(testNs, testSvc, "", testRev, cpuMetric); err == nil {
+		t.Error("NewCPUReporter() = nil, wanted an error for an empty config")
+	}
+
+	r, err := NewCPUReporter(testNs, "" /*service name*/, testConf, testRev, cpuMetric)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewCPUReporter() = %v", err)
+	}
+	defer view.Unregister(view.Find(cpuName))
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     testNs,
+		metricskey.LabelServiceName:       "unknown",
+		metricskey.LabelConfigurationName: testConf,
+		metricskey.LabelRevisionName:      testRev,
+	}
+
+	r.ReportCPUTime(250 * time.Millisecond)
+	assertSumData(t, cpuName, wantTags, 0.25)
+
+	// The CPU time is cumulative.
+	r.ReportCPUTime(1500 * time.Millisecond)
+	assertSumData(t, cpuName, wantTags, 1.75)
+}
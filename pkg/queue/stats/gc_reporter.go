@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"context"
+	"errors"
+
+	"github.com/knative/serving/pkg/gctuning"
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/tag"
+)
+
+// NewGCReporter creates a reporter of the garbage collector of the
+// queue-proxy, tagged with its revision.
+func NewGCReporter(ns, service, config, rev string) (*gctuning.Reporter, error) {
+	if ns == "" {
+		return nil, errors.New("namespace must not be empty")
+	}
+	if config == "" {
+		return nil, errors.New("config must not be empty")
+	}
+	if rev == "" {
+		return nil, errors.New("revision must not be empty")
+	}
+
+	nsTag, err := tag.NewKey(metricskey.LabelNamespaceName)
+	if err != nil {
+		return nil, err
+	}
+	svcTag, err := tag.NewKey(metricskey.LabelServiceName)
+	if err != nil {
+		return nil, err
+	}
+	configTag, err := tag.NewKey(metricskey.LabelConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+	revTag, err := tag.NewKey(metricskey.LabelRevisionName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(nsTag, ns),
+		tag.Insert(svcTag, valueOrUnknown(service)),
+		tag.Insert(configTag, config),
+		tag.Insert(revTag, rev),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return gctuning.NewReporter(ctx, nsTag, svcTag, configTag, revTag)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats/view"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestGCReporter(t *testing.T) {
+	if _, err := NewGCReporter(testNs, testSvc, "", testRev); err == nil {
+		t.Error("NewGCReporter() = nil, wanted an error for an empty config")
+	}
+
+	r, err := NewGCReporter(testNs, "" /*service name*/, testConf, testRev)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewGCReporter() = %v", err)
+	}
+	defer view.Unregister(view.Find("gc_pause_seconds"), view.Find("gc_cpu_fraction"), view.Find("heap_bytes"))
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     testNs,
+		metricskey.LabelServiceName:       "unknown",
+		metricskey.LabelConfigurationName: testConf,
+		metricskey.LabelRevisionName:      testRev,
+	}
+
+	ticks := make(chan time.Time)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go r.Run(ticks, stopCh)
+	ticks <- time.Now()
+
+	if err := checkTags("heap_bytes", wantTags); err != nil {
+		t.Error(err)
+	}
+}
+
+func checkTags(name string, wantTags map[string]string) error {
+	var err error
+	wait.PollImmediate(1*time.Millisecond, 2*time.Second, func() (bool, error) {
+		var d []*view.Row
+		if d, err = view.RetrieveData(name); err != nil {
+			return false, nil
+		}
+		if len(d) != 1 {
+			err = fmt.Errorf("len(d) = %d, want: 1", len(d))
+			return false, nil
+		}
+		if len(d[0].Tags) != len(wantTags) {
+			err = fmt.Errorf("Tags = %v, want: %v", d[0].Tags, wantTags)
+			return false, nil
+		}
+		for _, got := range d[0].Tags {
+			if want := wantTags[got.Key.Name()]; got.Value != want {
+				err = fmt.Errorf("Tags[%v] = %v, want: %v", got.Key.Name(), got.Value, want)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// queueWaitDistribution spans from the requests let through right away to
+// the ones waiting for a cold start, in seconds.
+var queueWaitDistribution = view.Distribution(0, 0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300)
+
+// QueueWaitReporter reports how long the requests of a revision waited for
+// capacity in the queue-proxy.
+type QueueWaitReporter struct {
+	ctx        context.Context
+	waitMetric *stats.Float64Measure
+}
+
+// NewQueueWaitReporter creates a reporter that records the queue wait of
+// the requests of the revision in a histogram of waitMetric, in seconds.
+func NewQueueWaitReporter(ns, service, config, rev string, waitMetric *stats.Float64Measure) (*QueueWaitReporter, error) {
+	if ns == "" {
+		return nil, errors.New("namespace must not be empty")
+	}
+	if config == "" {
+		return nil, errors.New("config must not be empty")
+	}
+	if rev == "" {
+		return nil, errors.New("revision must not be empty")
+	}
+
+	nsTag, err := tag.NewKey(metricskey.LabelNamespaceName)
+	if err != nil {
+		return nil, err
+	}
+	svcTag, err := tag.NewKey(metricskey.LabelServiceName)
+	if err != nil {
+		return nil, err
+	}
+	configTag, err := tag.NewKey(metricskey.LabelConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+	revTag, err := tag.NewKey(metricskey.LabelRevisionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := view.Register(&view.View{
+		Description: waitMetric.Description(),
+		Measure:     waitMetric,
+		Aggregation: queueWaitDistribution,
+		TagKeys:     []tag.Key{nsTag, svcTag, configTag, revTag},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(nsTag, ns),
+		tag.Insert(svcTag, valueOrUnknown(service)),
+		tag.Insert(configTag, config),
+		tag.Insert(revTag, rev),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueueWaitReporter{
+		ctx:        ctx,
+		waitMetric: waitMetric,
+	}, nil
+}
+
+// ReportQueueWait records that a request waited for d.
+func (r *QueueWaitReporter) ReportQueueWait(d time.Duration) {
+	metrics.Record(r.ctx, r.waitMetric.M(d.Seconds()))
+}
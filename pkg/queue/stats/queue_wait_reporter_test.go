@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+func TestQueueWaitReporter(t *testing.T) {
+	const waitName = "queue_wait_seconds"
+	waitMetric := stats.Float64(
+		waitName,
+		"The time requests waited for capacity in seconds",
+		"s")
+
+	if _, err := NewQueueWaitReporter(testNs, testSvc, "", testRev, waitMetric); err == nil {
+		t.Error("NewQueueWaitReporter() = nil, wanted an error for an empty config")
+	}
+
+	r, err := NewQueueWaitReporter(testNs, "" /*service name*/, testConf, testRev, waitMetric)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewQueueWaitReporter() = %v", err)
+	}
+	defer view.Unregister(view.Find(waitName))
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     testNs,
+		metricskey.LabelServiceName:       "unknown",
+		metricskey.LabelConfigurationName: testConf,
+		metricskey.LabelRevisionName:      testRev,
+	}
+
+	r.ReportQueueWait(0)
+	r.ReportQueueWait(250 * time.Millisecond)
+	r.ReportQueueWait(3 * time.Second)
+	assertDistributionData(t, waitName, wantTags, 3, 0, 3)
+}
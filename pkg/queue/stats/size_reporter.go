@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"context"
+	"errors"
+
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// sizeDistribution spans from empty bodies to uploads and downloads of
+// hundreds of megabytes, in bytes.
+var sizeDistribution = view.Distribution(0, 1<<8, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20, 4<<20, 16<<20, 64<<20, 256<<20)
+
+// SizeReporter reports the sizes of the bodies of the requests of a
+// revision and of their responses.
+type SizeReporter struct {
+	ctx            context.Context
+	requestMetric  *stats.Int64Measure
+	responseMetric *stats.Int64Measure
+}
+
+// NewSizeReporter creates a reporter that records the sizes of the bodies
+// of the requests of the revision in a histogram of requestMetric, and
+// those of their responses in one of responseMetric, in bytes.
+func NewSizeReporter(ns, service, config, rev string, requestMetric, responseMetric *stats.Int64Measure) (*SizeReporter, error) {
+	if ns == "" {
+		return nil, errors.New("namespace must not be empty")
+	}
+	if config == "" {
+		return nil, errors.New("config must not be empty")
+	}
+	if rev == "" {
+		return nil, errors.New("revision must not be empty")
+	}
+
+	nsTag, err := tag.NewKey(metricskey.LabelNamespaceName)
+	if err != nil {
+		return nil, err
+	}
+	svcTag, err := tag.NewKey(metricskey.LabelServiceName)
+	if err != nil {
+		return nil, err
+	}
+	configTag, err := tag.NewKey(metricskey.LabelConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+	revTag, err := tag.NewKey(metricskey.LabelRevisionName)
+	if err != nil {
+		return nil, err
+	}
+
+	tagKeys := []tag.Key{nsTag, svcTag, configTag, revTag}
+	if err := view.Register(&view.View{
+		Description: requestMetric.Description(),
+		Measure:     requestMetric,
+		Aggregation: sizeDistribution,
+		TagKeys:     tagKeys,
+	}, &view.View{
+		Description: responseMetric.Description(),
+		Measure:     responseMetric,
+		Aggregation: sizeDistribution,
+		TagKeys:     tagKeys,
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(nsTag, ns),
+		tag.Insert(svcTag, valueOrUnknown(service)),
+		tag.Insert(configTag, config),
+		tag.Insert(revTag, rev),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SizeReporter{
+		ctx:            ctx,
+		requestMetric:  requestMetric,
+		responseMetric: responseMetric,
+	}, nil
+}
+
+// ReportSizes records that a request had a body of request bytes, and its
+// response one of response bytes.
+func (r *SizeReporter) ReportSizes(request, response int64) {
+	metrics.Record(r.ctx, r.requestMetric.M(request))
+	metrics.Record(r.ctx, r.responseMetric.M(response))
+}
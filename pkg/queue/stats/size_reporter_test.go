@@ -0,0 +1,56 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+
+	"knative.dev/pkg/metrics/metricskey"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+func TestSizeReporter(t *testing.T) {
+	const (
+		requestName  = "request_size_bytes"
+		responseName = "response_size_bytes"
+	)
+	requestMetric := stats.Int64(requestName, "The size of the request bodies in bytes", stats.UnitBytes)
+	responseMetric := stats.Int64(responseName, "The size of the response bodies in bytes", stats.UnitBytes)
+
+	if _, err := NewSizeReporter(testNs, testSvc, "", testRev, requestMetric, responseMetric); err == nil {
+		t.Error("NewSizeReporter() = nil, wanted an error for an empty config")
+	}
+
+	r, err := NewSizeReporter(testNs, "" /*service name*/, testConf, testRev, requestMetric, responseMetric)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSizeReporter() = %v", err)
+	}
+	defer view.Unregister(view.Find(requestName))
+	defer view.Unregister(view.Find(responseName))
+	wantTags := map[string]string{
+		metricskey.LabelNamespaceName:     testNs,
+		metricskey.LabelServiceName:       "unknown",
+		metricskey.LabelConfigurationName: testConf,
+		metricskey.LabelRevisionName:      testRev,
+	}
+
+	r.ReportSizes(0, 512)
+	r.ReportSizes(2048, 10)
+	assertDistributionData(t, requestName, wantTags, 2, 0, 2048)
+	assertDistributionData(t, responseName, wantTags, 2, 10, 512)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearerToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		auth  string
+		want  int
+	}{{
+		name: "no token required",
+		want: http.StatusOK,
+	}, {
+		name: "no token required, token sent",
+		auth: "Bearer abc",
+		want: http.StatusOK,
+	}, {
+		name:  "valid token",
+		token: "abc",
+		auth:  "Bearer abc",
+		want:  http.StatusOK,
+	}, {
+		name:  "missing token",
+		token: "abc",
+		want:  http.StatusUnauthorized,
+	}, {
+		name:  "wrong token",
+		token: "abc",
+		auth:  "Bearer abd",
+		want:  http.StatusUnauthorized,
+	}, {
+		name:  "wrong scheme",
+		token: "abc",
+		auth:  "Basic abc",
+		want:  http.StatusUnauthorized,
+	}, {
+		name:  "token prefix",
+		token: "abc",
+		auth:  "Bearer ab",
+		want:  http.StatusUnauthorized,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://pod:9090/metrics", nil)
+			if test.auth != "" {
+				req.Header.Set("Authorization", test.auth)
+			}
+			rec := httptest.NewRecorder()
+			RequireBearerToken(test.token, ok).ServeHTTP(rec, req)
+			if rec.Code != test.want {
+				t.Errorf("Code = %d, want %d", rec.Code, test.want)
+			}
+		})
+	}
+}
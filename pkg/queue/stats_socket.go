@@ -0,0 +1,53 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// StatsSocketListener creates a listener on the unix domain socket at
+// the given path, so that scrapers living in the same pod (e.g. mesh
+// sidecars or node agents sharing a volume) can read the queue-proxy
+// stats without going through the pod network.
+// A stale socket file left behind by a previous incarnation of the
+// container is removed before listening.
+func StatsSocketListener(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to remove stale socket %s", path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on socket %s", path)
+	}
+	return l, nil
+}
+
+// ServeStatsSocket serves the given stats handler on the unix domain
+// socket at path. It blocks until the listener fails.
+func ServeStatsSocket(path string, h http.Handler) error {
+	l, err := StatsSocketListener(path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return http.Serve(l, h)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsSocketListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats-socket")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stats.sock")
+
+	// A stale file must not prevent us from listening.
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	l, err := StatsSocketListener(path)
+	if err != nil {
+		t.Fatalf("StatsSocketListener() = %v", err)
+	}
+	defer l.Close()
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("queue_average_concurrent_requests 1"))
+	}))
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	resp, err := client.Get("http://unix/metrics")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if got, want := string(body), "queue_average_concurrent_requests 1"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+}
+
+func TestStatsSocketListenerInvalidPath(t *testing.T) {
+	if _, err := StatsSocketListener("/does/not/exist/stats.sock"); err == nil {
+		t.Error("StatsSocketListener() = nil, want an error")
+	}
+}
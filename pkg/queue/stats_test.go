@@ -263,6 +263,37 @@ type testStats struct {
 	reportBiChan chan time.Time
 }
 
+func TestIdleRequests(t *testing.T) {
+	now := time.Now()
+	s := newTestStats(now)
+
+	s.requestStart(now)
+	s.proxiedStart(now)
+	now = now.Add(500 * time.Millisecond)
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ReqIdle}
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ProxiedIdle}
+	now = now.Add(1 * time.Second)
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ProxiedActive}
+	now = now.Add(500 * time.Millisecond)
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ReqActive}
+	s.requestEnd(now)
+	s.proxiedEnd(now)
+	got := s.report(now)
+
+	// Idle time neither counts towards the concurrency nor ends the requests.
+	want := &autoscaler.Stat{
+		Time:                             &now,
+		PodName:                          podName,
+		AverageConcurrentRequests:        0.75,
+		AverageProxiedConcurrentRequests: 0.5,
+		RequestCount:                     2,
+		ProxiedRequestCount:              1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected stat (-want +got): %v", diff)
+	}
+}
+
 func newTestStats(now time.Time) *testStats {
 	reportBiChan := make(chan time.Time)
 	ch := Channels{
@@ -298,3 +329,32 @@ func (s *testStats) report(now time.Time) *autoscaler.Stat {
 	s.reportBiChan <- now
 	return <-s.ch.StatChan
 }
+
+func TestRequestBytesAndDuration(t *testing.T) {
+	now := time.Now()
+	s := newTestStats(now)
+
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ReqIn, Bytes: 100}
+	now = now.Add(500 * time.Millisecond)
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ProxiedIn, Bytes: 300}
+	now = now.Add(500 * time.Millisecond)
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ReqOut, Bytes: 100, Duration: time.Second}
+	s.ch.ReqChan <- ReqEvent{Time: now, EventType: ProxiedOut, Bytes: 300, Duration: 500 * time.Millisecond}
+	now = now.Add(1 * time.Second)
+	got := s.report(now)
+
+	want := &autoscaler.Stat{
+		Time:                             &now,
+		PodName:                          podName,
+		AverageConcurrentRequests:        0.75,
+		AverageProxiedConcurrentRequests: 0.25,
+		RequestCount:                     2,
+		ProxiedRequestCount:              1,
+		// (100 bytes * 0.5s + 400 bytes * 0.5s) / 2s
+		AverageBytesInFlight:   125,
+		AverageRequestDuration: 0.75,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected stat (-want +got): %v", diff)
+	}
+}
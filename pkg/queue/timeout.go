@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"knative.dev/pkg/websocket"
+
+	pkghttp "github.com/knative/serving/pkg/http"
 )
 
 var defaultTimeoutBody = "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>"
@@ -36,6 +38,7 @@ var defaultTimeoutBody = "<html><head><title>Timeout</title></head><body><h1>Tim
 // The new Handler calls h.ServeHTTP to handle each request, but if a
 // call runs for longer than its time limit, the handler responds with
 // a 503 Service Unavailable error and the given message in its body.
+// The status can be changed per request with SetTimeoutStatus.
 // (If msg is empty, a suitable default message will be sent.)
 // After such a timeout, writes by h to its ResponseWriter will return
 // ErrHandlerTimeout.
@@ -46,6 +49,13 @@ var defaultTimeoutBody = "<html><head><title>Timeout</title></head><body><h1>Tim
 //
 // The implementation is largely inspired by http.TimeoutHandler.
 func TimeToFirstByteTimeoutHandler(h http.Handler, dt time.Duration, msg string) http.Handler {
+	return DynamicTimeToFirstByteTimeoutHandler(h, func() time.Duration { return dt }, msg)
+}
+
+// DynamicTimeToFirstByteTimeoutHandler is like TimeToFirstByteTimeoutHandler,
+// but calls dt for every request to get the timeout, so that it can be
+// changed at runtime.
+func DynamicTimeToFirstByteTimeoutHandler(h http.Handler, dt func() time.Duration, msg string) http.Handler {
 	return &timeoutHandler{
 		handler: h,
 		body:    msg,
@@ -53,10 +63,25 @@ func TimeToFirstByteTimeoutHandler(h http.Handler, dt time.Duration, msg string)
 	}
 }
 
+// timeoutWriterKey is the context key under which the timeoutWriter of a
+// request is stored.
+type timeoutWriterKey struct{}
+
+// SetTimeoutStatus changes the status code written if the request handled
+// with ctx times out before writing its first byte. It is a no-op for
+// requests not served by a TimeToFirstByteTimeoutHandler.
+func SetTimeoutStatus(ctx context.Context, code int) {
+	if tw, ok := ctx.Value(timeoutWriterKey{}).(*timeoutWriter); ok {
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.code = code
+	}
+}
+
 type timeoutHandler struct {
 	handler http.Handler
 	body    string
-	dt      time.Duration
+	dt      func() time.Duration
 }
 
 func (h *timeoutHandler) errorBody() string {
@@ -76,7 +101,8 @@ func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	panicChan := make(chan interface{})
 	defer close(panicChan)
 
-	tw := &timeoutWriter{w: w}
+	tw := &timeoutWriter{w: w, code: http.StatusServiceUnavailable}
+	ctx = context.WithValue(ctx, timeoutWriterKey{}, tw)
 	go func() {
 		// The defer statements are executed in LIFO order,
 		// so recover will execute first, then only, the channel will be closed.
@@ -89,7 +115,7 @@ func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handler.ServeHTTP(tw, r.WithContext(ctx))
 	}()
 
-	timeout := time.NewTimer(h.dt)
+	timeout := time.NewTimer(h.dt())
 	defer timeout.Stop()
 	for {
 		select {
@@ -116,6 +142,7 @@ type timeoutWriter struct {
 	w http.ResponseWriter
 
 	mu        sync.Mutex
+	code      int
 	timedOut  bool
 	wroteOnce bool
 }
@@ -155,7 +182,11 @@ func (tw *timeoutWriter) WriteHeader(code int) {
 		return
 	}
 
-	tw.wroteOnce = true
+	// Informational responses are followed by the final response, which
+	// might still time out.
+	if !pkghttp.IsInformational(code) {
+		tw.wroteOnce = true
+	}
 	tw.w.WriteHeader(code)
 }
 
@@ -170,7 +201,7 @@ func (tw *timeoutWriter) TimeoutAndWriteError(msg string) bool {
 	defer tw.mu.Unlock()
 
 	if !tw.wroteOnce {
-		tw.w.WriteHeader(http.StatusServiceUnavailable)
+		tw.w.WriteHeader(tw.code)
 		io.WriteString(tw.w, msg)
 
 		tw.timedOut = true
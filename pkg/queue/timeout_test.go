@@ -16,6 +16,8 @@ limitations under the License.
 package queue
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -136,3 +138,92 @@ func TestTimeToFirstByteTimeoutHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeToFirstByteTimeoutHandlerInformational(t *testing.T) {
+	// httptest.ResponseRecorder treats informational responses as final,
+	// so go through a real server here.
+	h := TimeToFirstByteTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("hi"))
+	}), 10*time.Millisecond, "")
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if got, want := resp.StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+	if got, want := string(body), defaultTimeoutBody; got != want {
+		t.Errorf("Body = %q, want %q", got, want)
+	}
+}
+
+func TestDynamicTimeToFirstByteTimeoutHandler(t *testing.T) {
+	timeout := 10 * time.Millisecond
+	handler := DynamicTimeToFirstByteTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("hi"))
+	}), func() time.Duration { return timeout }, "request timeout")
+
+	for _, test := range []struct {
+		timeout    time.Duration
+		wantStatus int
+	}{{
+		timeout:    10 * time.Millisecond,
+		wantStatus: http.StatusServiceUnavailable,
+	}, {
+		timeout:    time.Second,
+		wantStatus: http.StatusOK,
+	}} {
+		timeout = test.timeout
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Code; got != test.wantStatus {
+			t.Errorf("With timeout %v status = %d, want %d", test.timeout, got, test.wantStatus)
+		}
+	}
+}
+
+func TestSetTimeoutStatus(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		code       int
+		wantStatus int
+	}{{
+		name:       "default",
+		wantStatus: http.StatusServiceUnavailable,
+	}, {
+		name:       "changed",
+		code:       http.StatusGatewayTimeout,
+		wantStatus: http.StatusGatewayTimeout,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := TimeToFirstByteTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.code != 0 {
+					SetTimeoutStatus(r.Context(), test.code)
+				}
+				<-r.Context().Done()
+			}), 10*time.Millisecond, "request timeout")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := rr.Code; got != test.wantStatus {
+				t.Errorf("Status = %d, want %d", got, test.wantStatus)
+			}
+		})
+	}
+
+	// Outside of the handler it's a no-op.
+	SetTimeoutStatus(context.Background(), http.StatusGatewayTimeout)
+}
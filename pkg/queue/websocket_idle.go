@@ -0,0 +1,242 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"knative.dev/pkg/websocket"
+)
+
+// WebSocketIdleTracker reports when a hijacked WebSocket connection went
+// without data frames for the idle window, and when it carries data again,
+// so that idle connections don't count towards the concurrency of the
+// revision. Control frames, like pings, don't make a connection active.
+type WebSocketIdleTracker struct {
+	window   time.Duration
+	onIdle   func()
+	onActive func()
+
+	mux sync.Mutex
+	// timer fires after the idle window, once the connection is hijacked.
+	timer *time.Timer
+	// lastActive is when the last data frame went through.
+	lastActive time.Time
+	idle       bool
+	stopped    bool
+}
+
+// NewWebSocketIdleTracker returns a tracker calling onIdle when the
+// connection went idle for window, and onActive when it carries data
+// again. The calls alternate and start with onIdle.
+func NewWebSocketIdleTracker(window time.Duration, onIdle, onActive func()) *WebSocketIdleTracker {
+	return &WebSocketIdleTracker{
+		window:   window,
+		onIdle:   onIdle,
+		onActive: onActive,
+	}
+}
+
+// Writer returns a ResponseWriter tracking the connection w is hijacked
+// for. The handshake isn't tracked, since the request counts as active
+// until the connection is hijacked.
+func (t *WebSocketIdleTracker) Writer(w http.ResponseWriter) http.ResponseWriter {
+	return &webSocketIdleWriter{ResponseWriter: w, tracker: t}
+}
+
+// Stop stops tracking the connection, which is active again if it was
+// idle, so that the request ends as it started.
+func (t *WebSocketIdleTracker) Stop() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	if t.idle {
+		t.idle = false
+		t.onActive()
+	}
+}
+
+// start starts the idle window, once the connection is hijacked.
+func (t *WebSocketIdleTracker) start() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.stopped || t.timer != nil {
+		return
+	}
+	t.lastActive = time.Now()
+	t.timer = time.AfterFunc(t.window, t.expire)
+}
+
+// touch records a data frame, which restarts the idle window.
+func (t *WebSocketIdleTracker) touch() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.stopped || t.timer == nil {
+		return
+	}
+	t.lastActive = time.Now()
+	if t.idle {
+		t.idle = false
+		t.onActive()
+	}
+	t.timer.Reset(t.window)
+}
+
+func (t *WebSocketIdleTracker) expire() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	// The timer may have fired while a data frame reset it.
+	if t.stopped || t.idle || time.Since(t.lastActive) < t.window {
+		return
+	}
+	t.idle = true
+	t.onIdle()
+}
+
+type webSocketIdleWriter struct {
+	http.ResponseWriter
+	tracker *WebSocketIdleTracker
+}
+
+var (
+	_ http.Flusher  = (*webSocketIdleWriter)(nil)
+	_ http.Hijacker = (*webSocketIdleWriter)(nil)
+)
+
+func (w *webSocketIdleWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, and tracks the frames going through the returned
+// connection. The handshake response written to the returned
+// bufio.ReadWriter isn't tracked.
+func (w *webSocketIdleWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := websocket.HijackIfPossible(w.ResponseWriter)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.tracker.start()
+	return &webSocketIdleConn{Conn: conn, tracker: w.tracker}, rw, nil
+}
+
+// webSocketIdleConn touches its tracker with the data frames read from
+// and written to the connection.
+type webSocketIdleConn struct {
+	net.Conn
+	tracker *WebSocketIdleTracker
+	// in and out are only used by Read and Write respectively, which
+	// may run concurrently.
+	in, out frameScanner
+}
+
+func (c *webSocketIdleConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if c.in.scan(p[:n]) {
+		c.tracker.touch()
+	}
+	return n, err
+}
+
+func (c *webSocketIdleConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if c.out.scan(p[:n]) {
+		c.tracker.touch()
+	}
+	return n, err
+}
+
+// maxFrameHeaderSize is the size of a frame header with a 64 bit payload
+// length and a masking key, see https://tools.ietf.org/html/rfc6455#section-5.2.
+const maxFrameHeaderSize = 14
+
+// frameScanner follows the WebSocket frames of one direction of a
+// connection, which may be split over any number of reads or writes.
+type frameScanner struct {
+	header [maxFrameHeaderSize]byte
+	// n is the number of header bytes of the current frame seen so far.
+	n int
+	// payload is the number of payload bytes of the current frame left.
+	payload uint64
+}
+
+// scan consumes p and reports whether it holds the header of a data
+// frame, i.e. a text, binary or continuation frame.
+func (s *frameScanner) scan(p []byte) bool {
+	data := false
+	for len(p) > 0 {
+		if s.payload > 0 {
+			if uint64(len(p)) <= s.payload {
+				s.payload -= uint64(len(p))
+				return data
+			}
+			p = p[s.payload:]
+			s.payload = 0
+			continue
+		}
+		s.header[s.n] = p[0]
+		s.n++
+		p = p[1:]
+		if s.n < 2 || s.n < frameHeaderSize(s.header[1]) {
+			continue
+		}
+		// Control frames have the high bit of their opcode set.
+		if s.header[0]&0x08 == 0 {
+			data = true
+		}
+		s.payload = framePayloadLength(s.header[:s.n])
+		s.n = 0
+	}
+	return data
+}
+
+// frameHeaderSize returns the size of a frame header from its second byte.
+func frameHeaderSize(b byte) int {
+	size := 2
+	switch b & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if b&0x80 != 0 {
+		size += 4
+	}
+	return size
+}
+
+// framePayloadLength returns the payload length of a complete frame header.
+func framePayloadLength(header []byte) uint64 {
+	switch l := header[1] & 0x7f; l {
+	case 126:
+		return uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return binary.BigEndian.Uint64(header[2:10])
+	default:
+		return uint64(l)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// frame returns a WebSocket frame with the given opcode and payload size.
+func frame(opcode byte, size int, masked bool) []byte {
+	f := []byte{0x80 | opcode}
+	var mask byte
+	if masked {
+		mask = 0x80
+	}
+	switch {
+	case size < 126:
+		f = append(f, mask|byte(size))
+	case size < 1<<16:
+		f = append(f, mask|126, byte(size>>8), byte(size))
+	default:
+		f = append(f, mask|127, 0, 0, 0, 0, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	}
+	if masked {
+		f = append(f, 1, 2, 3, 4)
+	}
+	return append(f, make([]byte, size)...)
+}
+
+func concat(frames ...[]byte) []byte {
+	var b []byte
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func TestFrameScanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		chunk  int
+		want   []bool
+	}{{
+		name:   "text frame",
+		stream: frame(0x1, 5, false),
+		want:   []bool{true},
+	}, {
+		name:   "masked binary frame",
+		stream: frame(0x2, 5, true),
+		want:   []bool{true},
+	}, {
+		name:   "control frames",
+		stream: concat(frame(0x9, 4, true), frame(0xa, 4, false), frame(0x8, 2, false)),
+		want:   []bool{false},
+	}, {
+		name:   "ping then text",
+		stream: concat(frame(0x9, 0, false), frame(0x1, 3, false)),
+		want:   []bool{true},
+	}, {
+		name:   "split header",
+		stream: concat(frame(0x9, 0, false), frame(0x1, 300, true)),
+		chunk:  3,
+		// The header of the text frame ends in the fourth chunk.
+		want: concatBools(make([]bool, 3), []bool{true}, make([]bool, 100)),
+	}, {
+		name:   "16 bit length",
+		stream: concat(frame(0x2, 1000, false), frame(0x9, 0, false)),
+		chunk:  1004,
+		want:   []bool{true, false},
+	}, {
+		name:   "64 bit length",
+		stream: concat(frame(0x2, 70000, false), frame(0xa, 0, false)),
+		chunk:  70010,
+		want:   []bool{true, false},
+	}, {
+		name:   "continuation frame",
+		stream: concat(frame(0x8, 0, false), frame(0x0, 0, false)),
+		chunk:  2,
+		want:   []bool{false, true},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunk := test.chunk
+			if chunk == 0 {
+				chunk = len(test.stream)
+			}
+			var s frameScanner
+			var got []bool
+			for p := test.stream; len(p) > 0; {
+				n := chunk
+				if n > len(p) {
+					n = len(p)
+				}
+				got = append(got, s.scan(p[:n]))
+				p = p[n:]
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("Got %d chunks, want: %d", len(got), len(test.want))
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("scan(chunk %d) = %v, want: %v", i, got[i], test.want[i])
+				}
+			}
+			if s.n != 0 || s.payload != 0 {
+				t.Errorf("Scanner stopped within a frame: %#v", s)
+			}
+		})
+	}
+}
+
+func concatBools(bs ...[]bool) []bool {
+	var out []bool
+	for _, b := range bs {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// hijackRecorder is a ResponseWriter hijacked for conn.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, nil, nil
+}
+
+func TestWebSocketIdleTracker(t *testing.T) {
+	const window = 50 * time.Millisecond
+	events := make(chan string, 10)
+	tracker := NewWebSocketIdleTracker(window,
+		func() { events <- "idle" },
+		func() { events <- "active" })
+
+	server, client := net.Pipe()
+	defer client.Close()
+	w := tracker.Writer(&hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server})
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Fatalf("Hijack() = %v", err)
+	}
+	defer conn.Close()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("Got event %q, want: %q", got, want)
+			}
+		case <-time.After(10 * window):
+			t.Fatalf("Timed out waiting for event %q", want)
+		}
+	}
+
+	expect("idle")
+
+	// Pings keep the connection open, not active.
+	go client.Write(frame(0x9, 0, true))
+	conn.Read(make([]byte, 64))
+	select {
+	case e := <-events:
+		t.Fatalf("Got event %q after a ping, want none", e)
+	case <-time.After(2 * window):
+	}
+
+	// Messages from the client and the application make it active.
+	go client.Write(frame(0x1, 5, true))
+	conn.Read(make([]byte, 64))
+	expect("active")
+	expect("idle")
+
+	go client.Read(make([]byte, 64))
+	conn.Write(frame(0x2, 5, false))
+	expect("active")
+
+	tracker.Stop()
+	select {
+	case e := <-events:
+		t.Fatalf("Got event %q after Stop, want none", e)
+	case <-time.After(2 * window):
+	}
+}
+
+func TestWebSocketIdleTrackerStopWhileIdle(t *testing.T) {
+	var events []string
+	tracker := NewWebSocketIdleTracker(time.Millisecond,
+		func() { events = append(events, "idle") },
+		func() { events = append(events, "active") })
+
+	server, client := net.Pipe()
+	defer client.Close()
+	w := tracker.Writer(&hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server})
+	if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+		t.Fatalf("Hijack() = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	tracker.Stop()
+
+	// The request ends as active, as it started.
+	if got, want := len(events), 2; got != want || events[0] != "idle" || events[1] != "active" {
+		t.Errorf("Events = %v, want: [idle active]", events)
+	}
+}
+
+func TestWebSocketIdleTrackerNotHijacked(t *testing.T) {
+	tracker := NewWebSocketIdleTracker(time.Millisecond,
+		func() { t.Error("Unexpected idle event") },
+		func() { t.Error("Unexpected active event") })
+	w := tracker.Writer(httptest.NewRecorder())
+	w.WriteHeader(http.StatusBadRequest)
+	time.Sleep(10 * time.Millisecond)
+	tracker.Stop()
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+)
+
+// activationScale returns the scale an external system requested the PA to
+// be activated to ahead of traffic, and when that request expires. Zero is
+// returned when no activation is in effect.
+func activationScale(pa *pav1alpha1.PodAutoscaler, now time.Time) (int32, time.Time) {
+	scale, err := strconv.ParseInt(pa.Annotations[autoscaling.ActivationScaleAnnotationKey], 10, 32)
+	if err != nil || scale <= 0 {
+		return 0, time.Time{}
+	}
+	expiry, err := time.Parse(time.RFC3339, pa.Annotations[autoscaling.ActivationExpiryAnnotationKey])
+	if err != nil || !now.Before(expiry) {
+		return 0, time.Time{}
+	}
+	return int32(scale), expiry
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestActivationScale(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	later := now.Add(5 * time.Minute)
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantScale   int32
+		wantExpiry  time.Time
+	}{{
+		name: "no annotations",
+	}, {
+		name: "activated",
+		annotations: map[string]string{
+			autoscaling.ActivationScaleAnnotationKey:  "5",
+			autoscaling.ActivationExpiryAnnotationKey: later.Format(time.RFC3339),
+		},
+		wantScale:  5,
+		wantExpiry: later,
+	}, {
+		name: "expired",
+		annotations: map[string]string{
+			autoscaling.ActivationScaleAnnotationKey:  "5",
+			autoscaling.ActivationExpiryAnnotationKey: now.Format(time.RFC3339),
+		},
+	}, {
+		name: "zero scale",
+		annotations: map[string]string{
+			autoscaling.ActivationScaleAnnotationKey:  "0",
+			autoscaling.ActivationExpiryAnnotationKey: later.Format(time.RFC3339),
+		},
+	}, {
+		name: "invalid scale",
+		annotations: map[string]string{
+			autoscaling.ActivationScaleAnnotationKey:  "many",
+			autoscaling.ActivationExpiryAnnotationKey: later.Format(time.RFC3339),
+		},
+	}, {
+		name: "missing expiry",
+		annotations: map[string]string{
+			autoscaling.ActivationScaleAnnotationKey: "5",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pa := &pav1alpha1.PodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   testNamespace,
+					Name:        testRevision,
+					Annotations: test.annotations,
+				},
+			}
+			scale, expiry := activationScale(pa, now)
+			if scale != test.wantScale || !expiry.Equal(test.wantExpiry) {
+				t.Errorf("activationScale() = %d, %v; want %d, %v", scale, expiry, test.wantScale, test.wantExpiry)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"knative.dev/pkg/logging"
+
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// unschedulableMessage returns why the first of the given pods that the
+// scheduler gave up on can't be scheduled, e.g.
+// "0/3 nodes are available: 3 Insufficient cpu.", and whether there is one.
+func unschedulableMessage(pods []*corev1.Pod) (string, bool) {
+	for _, p := range pods {
+		for _, cond := range p.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse &&
+				cond.Reason == corev1.PodReasonUnschedulable {
+				return cond.Message, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkCapacity updates the CapacityPending condition of the PA from the
+// pods of its scale target, and returns whether some of them can't be
+// scheduled. Failures to list the pods are logged and leave the condition
+// as it was.
+func (ks *scaler) checkCapacity(ctx context.Context, pa *pav1alpha1.PodAutoscaler, ps *pav1alpha1.PodScalable) bool {
+	logger := logging.FromContext(ctx)
+
+	pods, err := ks.scaledPods(pa.Namespace, ps)
+	if err != nil {
+		logger.Errorw("Error listing pods to check for pending capacity", zap.Error(err))
+		return pa.Status.IsCapacityPending()
+	}
+
+	message, pending := unschedulableMessage(pods)
+	if !pending {
+		if pa.Status.IsCapacityPending() {
+			logger.Info("Pods are scheduled again, resuming scale ups")
+			pa.Status.ClearCapacityPending()
+		}
+		return false
+	}
+	if !pa.Status.IsCapacityPending() {
+		logger.Infof("Pods can't be scheduled, holding back scale ups: %s", message)
+		if ks.recorder != nil {
+			ks.recorder.Eventf(pa, corev1.EventTypeWarning, "CapacityPending",
+				"Holding back scale ups while pods can't be scheduled: %s", message)
+		}
+	}
+	pa.Status.MarkCapacityPending(message)
+	return true
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+
+	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	fakekubeclient "knative.dev/pkg/injection/clients/kubeclient/fake"
+	fakepodinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod/fake"
+
+	"github.com/knative/serving/pkg/reconciler/autoscaling/config"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	presources "github.com/knative/serving/pkg/resources"
+
+	logtesting "knative.dev/pkg/logging/testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+const insufficientCPU = "0/3 nodes are available: 3 Insufficient cpu."
+
+func unschedulablePod(name string) corev1.Pod {
+	p := consolidationPod(name, "")
+	p.Status.Conditions = []corev1.PodCondition{{
+		Type:    corev1.PodScheduled,
+		Status:  corev1.ConditionFalse,
+		Reason:  corev1.PodReasonUnschedulable,
+		Message: insufficientCPU,
+	}}
+	return p
+}
+
+func TestUnschedulableMessage(t *testing.T) {
+	pending := consolidationPod("pending", "")
+	pending.Status.Conditions = []corev1.PodCondition{{
+		Type:   corev1.PodScheduled,
+		Status: corev1.ConditionFalse,
+		// Not given up on by the scheduler yet.
+		Reason: "SchedulerError",
+	}}
+
+	tests := []struct {
+		name        string
+		pods        []corev1.Pod
+		wantMessage string
+		want        bool
+	}{{
+		name: "no pods",
+	}, {
+		name: "scheduled",
+		pods: []corev1.Pod{consolidationPod("a", "node-1")},
+	}, {
+		name: "not unschedulable",
+		pods: []corev1.Pod{pending},
+	}, {
+		name:        "unschedulable",
+		pods:        []corev1.Pod{consolidationPod("a", "node-1"), unschedulablePod("b")},
+		wantMessage: insufficientCPU,
+		want:        true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, got := unschedulableMessage(podPointers(test.pods))
+			if got != test.want || message != test.wantMessage {
+				t.Errorf("unschedulableMessage() = (%q, %v), want: (%q, %v)", message, got, test.wantMessage, test.want)
+			}
+		})
+	}
+}
+
+func TestScalerCapacityPending(t *testing.T) {
+	defer logtesting.ClearAll()
+	tests := []struct {
+		name          string
+		pods          []corev1.Pod
+		wasPending    bool
+		startReplicas int
+		scaleTo       int32
+		wantScale     int32
+		wantPending   bool
+	}{{
+		name:          "scale up",
+		pods:          []corev1.Pod{consolidationPod("a", "node-1")},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     5,
+	}, {
+		name:          "scale up held back",
+		pods:          []corev1.Pod{consolidationPod("a", "node-1"), unschedulablePod("b")},
+		startReplicas: 2,
+		scaleTo:       5,
+		wantScale:     2,
+		wantPending:   true,
+	}, {
+		name:          "scale down while pending",
+		pods:          []corev1.Pod{consolidationPod("a", "node-1"), unschedulablePod("b")},
+		wasPending:    true,
+		startReplicas: 2,
+		scaleTo:       1,
+		wantScale:     1,
+		wantPending:   true,
+	}, {
+		name:          "capacity back",
+		pods:          []corev1.Pod{consolidationPod("a", "node-1"), consolidationPod("b", "node-2")},
+		wasPending:    true,
+		startReplicas: 2,
+		scaleTo:       2,
+		wantScale:     2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t)
+
+			dynamicClient := fakedynamicclient.Get(ctx)
+			dynamicClient.PrependReactor("patch", "deployments",
+				func(action clientgotesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			kubeClient := fakekubeclient.Get(ctx)
+			podInformer := fakepodinformer.Get(ctx)
+			for _, p := range test.pods {
+				p := p
+				if _, err := kubeClient.CoreV1().Pods(testNamespace).Create(&p); err != nil {
+					t.Fatalf("Error creating pod: %v", err)
+				}
+				podInformer.Informer().GetIndexer().Add(&p)
+			}
+
+			revision := newRevision(t, fakeservingclient.Get(ctx), 0, 0)
+			newDeployment(t, dynamicClient, names.Deployment(revision), test.startReplicas)
+			revisionScaler := &scaler{
+				dynamicClient:     dynamicClient,
+				kubeClient:        kubeClient,
+				logger:            logtesting.TestLogger(t),
+				psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				podLister:         podInformer.Lister(),
+			}
+			pa := newKPA(t, fakeservingclient.Get(ctx), revision)
+			kpaMarkActive(pa, metav1.Now().Time)
+			if test.wasPending {
+				pa.Status.MarkCapacityPending(insufficientCPU)
+			}
+
+			ctx = config.ToContext(ctx, defaultConfig())
+			got, err := revisionScaler.Scale(ctx, pa, test.scaleTo)
+			if err != nil {
+				t.Fatalf("Scale() = %v", err)
+			}
+			if got != test.wantScale {
+				t.Errorf("Scale() = %d, want: %d", got, test.wantScale)
+			}
+			if got := pa.Status.IsCapacityPending(); got != test.wantPending {
+				t.Errorf("IsCapacityPending() = %v, want: %v", got, test.wantPending)
+			}
+		})
+	}
+}
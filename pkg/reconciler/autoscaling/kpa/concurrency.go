@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"knative.dev/pkg/logging"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/resources"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// propagateContainerConcurrency annotates the pods of the scale target with
+// the PA's containerConcurrency, from where their queue-proxies pick it up.
+// This lets the containerConcurrency be changed in place, without rolling
+// out a new revision. Unlimited concurrency can't be applied in place, so
+// PAs without a limit are left alone. The pods are read from the informer
+// cache, so only the pods that don't have it yet cost a request.
+func (ks *scaler) propagateContainerConcurrency(ctx context.Context, pa *pav1alpha1.PodAutoscaler) error {
+	if pa.Spec.ContainerConcurrency <= 0 {
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+
+	ps, err := resources.GetScaleResource(pa.Namespace, pa.Spec.ScaleTargetRef, ks.psInformerFactory)
+	if err != nil {
+		return err
+	}
+	pods, err := ks.scaledPods(pa.Namespace, ps)
+	if err != nil {
+		return err
+	}
+
+	want := strconv.Itoa(int(pa.Spec.ContainerConcurrency))
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, autoscaling.ContainerConcurrencyAnnotationKey, want)
+	for _, pod := range pods {
+		if pod.Annotations[autoscaling.ContainerConcurrencyAnnotationKey] == want {
+			continue
+		}
+		if _, err := ks.kubeClient.CoreV1().Pods(pa.Namespace).Patch(pod.Name, types.MergePatchType, []byte(patch)); err != nil {
+			logger.Errorw("Error setting the container concurrency of pod "+pod.Name, zap.Error(err))
+			continue
+		}
+		logger.Debugf("Set the container concurrency of pod %s to %s", pod.Name, want)
+	}
+	return nil
+}
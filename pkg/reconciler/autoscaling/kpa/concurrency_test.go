@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+
+	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	fakekubeclient "knative.dev/pkg/injection/clients/kubeclient/fake"
+	fakepodinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod/fake"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	presources "github.com/knative/serving/pkg/resources"
+
+	logtesting "knative.dev/pkg/logging/testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestPropagateContainerConcurrency(t *testing.T) {
+	defer logtesting.ClearAll()
+	tests := []struct {
+		name        string
+		cc          v1beta1.RevisionContainerConcurrencyType
+		want        string
+		wantPatches int
+	}{{
+		name:        "limited concurrency",
+		cc:          5,
+		want:        "5",
+		wantPatches: 3,
+	}, {
+		name:        "already propagated",
+		cc:          3,
+		want:        "3",
+		wantPatches: 1,
+	}, {
+		name: "unlimited concurrency",
+		cc:   0,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t)
+
+			dynamicClient := fakedynamicclient.Get(ctx)
+			kubeClient := fakekubeclient.Get(ctx)
+			podInformer := fakepodinformer.Get(ctx)
+			pods := []corev1.Pod{
+				consolidationPod("a", "node-1"),
+				consolidationPod("b", "node-1"),
+				consolidationPod("c", "node-2"),
+			}
+			pods[0].Annotations = map[string]string{
+				autoscaling.ContainerConcurrencyAnnotationKey: "3",
+			}
+			pods[1].Annotations = map[string]string{
+				autoscaling.ContainerConcurrencyAnnotationKey: "3",
+			}
+			for _, p := range pods {
+				p := p
+				if _, err := kubeClient.CoreV1().Pods(testNamespace).Create(&p); err != nil {
+					t.Fatalf("Error creating pod: %v", err)
+				}
+				podInformer.Informer().GetIndexer().Add(&p)
+			}
+
+			revision := newRevision(t, fakeservingclient.Get(ctx), 0, 0)
+			newDeployment(t, dynamicClient, names.Deployment(revision), len(pods))
+			revisionScaler := &scaler{
+				dynamicClient:     dynamicClient,
+				kubeClient:        kubeClient,
+				logger:            logtesting.TestLogger(t),
+				psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				podLister:         podInformer.Lister(),
+			}
+			pa := newKPA(t, fakeservingclient.Get(ctx), revision)
+			pa.Spec.ContainerConcurrency = test.cc
+
+			if err := revisionScaler.propagateContainerConcurrency(ctx, pa); err != nil {
+				t.Fatalf("propagateContainerConcurrency() = %v", err)
+			}
+
+			patches := 0
+			for _, action := range kubeClient.Actions() {
+				if action.Matches("patch", "pods") {
+					patches++
+				}
+			}
+			if patches != test.wantPatches {
+				t.Errorf("Got %d pod patches, want %d", patches, test.wantPatches)
+			}
+			if test.wantPatches == 0 {
+				return
+			}
+			pod, err := kubeClient.CoreV1().Pods(testNamespace).Get("c", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Error getting pod: %v", err)
+			}
+			if got := pod.Annotations[autoscaling.ContainerConcurrencyAnnotationKey]; got != test.want {
+				t.Errorf("Container concurrency annotation = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"knative.dev/pkg/logging"
+
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// podDeletionCostAnnotationKey is the annotation the ReplicaSet controller
+// consults to pick the pods to remove first when scaling down.
+const podDeletionCostAnnotationKey = "controller.kubernetes.io/pod-deletion-cost"
+
+// podDeletionCosts returns the deletion cost for each of the given pods.
+// The cost of a pod is the number of pods of the same set on its node, so
+// pods on the most thinly used nodes are removed first.
+func podDeletionCosts(pods []*corev1.Pod) map[string]int {
+	perNode := make(map[string]int, len(pods))
+	for _, p := range pods {
+		if p.Spec.NodeName != "" {
+			perNode[p.Spec.NodeName]++
+		}
+	}
+	costs := make(map[string]int, len(pods))
+	for _, p := range pods {
+		costs[p.Name] = perNode[p.Spec.NodeName]
+	}
+	return costs
+}
+
+// consolidate annotates the pods of the scale target with their deletion
+// costs ahead of scaling down. Failures are logged, since they must not
+// prevent the scale down itself.
+func (ks *scaler) consolidate(ctx context.Context, pa *pav1alpha1.PodAutoscaler, ps *pav1alpha1.PodScalable) {
+	logger := logging.FromContext(ctx)
+
+	pods, err := ks.scaledPods(pa.Namespace, ps)
+	if err != nil {
+		logger.Errorw("Error listing pods for consolidation", zap.Error(err))
+		return
+	}
+
+	costs := podDeletionCosts(pods)
+	marked := 0
+	for _, pod := range pods {
+		want := strconv.Itoa(costs[pod.Name])
+		if pod.Annotations[podDeletionCostAnnotationKey] == want {
+			continue
+		}
+		patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, podDeletionCostAnnotationKey, want)
+		if _, err := ks.kubeClient.CoreV1().Pods(pa.Namespace).Patch(pod.Name, types.MergePatchType, []byte(patch)); err != nil {
+			logger.Errorw("Error setting the deletion cost of pod "+pod.Name, zap.Error(err))
+			continue
+		}
+		marked++
+	}
+	if marked > 0 && ks.recorder != nil {
+		ks.recorder.Eventf(pa, corev1.EventTypeNormal, "Consolidating",
+			"Updated the deletion cost of %d pods to prefer removing pods on thinly used nodes", marked)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	fakekubeclient "knative.dev/pkg/injection/clients/kubeclient/fake"
+	fakepodinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod/fake"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/reconciler/autoscaling/config"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	presources "github.com/knative/serving/pkg/resources"
+
+	logtesting "knative.dev/pkg/logging/testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func consolidationPod(name, node string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      name,
+			Labels: map[string]string{
+				serving.RevisionUID: "1982",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+		},
+	}
+}
+
+func podPointers(pods []corev1.Pod) []*corev1.Pod {
+	ptrs := make([]*corev1.Pod, len(pods))
+	for i := range pods {
+		ptrs[i] = &pods[i]
+	}
+	return ptrs
+}
+
+func TestPodDeletionCosts(t *testing.T) {
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want map[string]int
+	}{{
+		name: "no pods",
+		want: map[string]int{},
+	}, {
+		name: "spread thinly",
+		pods: []corev1.Pod{
+			consolidationPod("a", "node-1"),
+			consolidationPod("b", "node-1"),
+			consolidationPod("c", "node-1"),
+			consolidationPod("d", "node-2"),
+			consolidationPod("e", "node-3"),
+			consolidationPod("f", "node-3"),
+		},
+		want: map[string]int{
+			"a": 3,
+			"b": 3,
+			"c": 3,
+			"d": 1,
+			"e": 2,
+			"f": 2,
+		},
+	}, {
+		name: "unscheduled pods go first",
+		pods: []corev1.Pod{
+			consolidationPod("a", "node-1"),
+			consolidationPod("b", ""),
+		},
+		want: map[string]int{
+			"a": 1,
+			"b": 0,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, podDeletionCosts(podPointers(test.pods))); diff != "" {
+				t.Errorf("podDeletionCosts (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestScalerConsolidation(t *testing.T) {
+	defer logtesting.ClearAll()
+	tests := []struct {
+		name          string
+		enabled       bool
+		startReplicas int
+		scaleTo       int32
+		wantPatches   int
+	}{{
+		name:          "scale down",
+		enabled:       true,
+		startReplicas: 3,
+		scaleTo:       2,
+		wantPatches:   3,
+	}, {
+		name:          "disabled",
+		startReplicas: 3,
+		scaleTo:       2,
+	}, {
+		name:          "scale up",
+		enabled:       true,
+		startReplicas: 3,
+		scaleTo:       4,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t)
+
+			dynamicClient := fakedynamicclient.Get(ctx)
+			dynamicClient.PrependReactor("patch", "deployments",
+				func(action clientgotesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			kubeClient := fakekubeclient.Get(ctx)
+			podInformer := fakepodinformer.Get(ctx)
+			for _, p := range []corev1.Pod{
+				consolidationPod("a", "node-1"),
+				consolidationPod("b", "node-1"),
+				consolidationPod("c", "node-2"),
+			} {
+				p := p
+				if _, err := kubeClient.CoreV1().Pods(testNamespace).Create(&p); err != nil {
+					t.Fatalf("Error creating pod: %v", err)
+				}
+				podInformer.Informer().GetIndexer().Add(&p)
+			}
+
+			revision := newRevision(t, fakeservingclient.Get(ctx), 0, 0)
+			newDeployment(t, dynamicClient, names.Deployment(revision), test.startReplicas)
+			revisionScaler := &scaler{
+				dynamicClient:     dynamicClient,
+				kubeClient:        kubeClient,
+				logger:            logtesting.TestLogger(t),
+				psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				podLister:         podInformer.Lister(),
+			}
+			pa := newKPA(t, fakeservingclient.Get(ctx), revision)
+			kpaMarkActive(pa, metav1.Now().Time)
+
+			conf := defaultConfig()
+			conf.Autoscaler.EnablePodConsolidation = test.enabled
+			ctx = config.ToContext(ctx, conf)
+			if _, err := revisionScaler.Scale(ctx, pa, test.scaleTo); err != nil {
+				t.Fatalf("Scale() = %v", err)
+			}
+
+			patches := 0
+			for _, action := range kubeClient.Actions() {
+				if action.Matches("patch", "pods") {
+					patches++
+				}
+			}
+			if patches != test.wantPatches {
+				t.Errorf("Got %d pod patches, want %d", patches, test.wantPatches)
+			}
+			if test.wantPatches == 0 {
+				return
+			}
+			pod, err := kubeClient.CoreV1().Pods(testNamespace).Get("c", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Error getting pod: %v", err)
+			}
+			if got, want := pod.Annotations[podDeletionCostAnnotationKey], "1"; got != want {
+				t.Errorf("Deletion cost = %q, want %q", got, want)
+			}
+		})
+	}
+}
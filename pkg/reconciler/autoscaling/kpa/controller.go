@@ -21,6 +21,8 @@ import (
 
 	"knative.dev/pkg/apis/duck"
 	endpointsinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/endpoints"
+	namespaceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace"
+	podinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod"
 	serviceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/service"
 	kpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler"
 	sksinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/serverlessservice"
@@ -53,6 +55,8 @@ func NewController(
 	sksInformer := sksinformer.Get(ctx)
 	serviceInformer := serviceinformer.Get(ctx)
 	endpointsInformer := endpointsinformer.Get(ctx)
+	namespaceInformer := namespaceinformer.Get(ctx)
+	podInformer := podinformer.Get(ctx)
 
 	c := &Reconciler{
 		Base: &areconciler.Base{
@@ -67,7 +71,9 @@ func NewController(
 		deciders:        deciders,
 	}
 	impl := controller.NewImpl(c, c.Logger, "KPA-Class Autoscaling")
-	c.scaler = newScaler(ctx, psInformerFactory, impl.EnqueueAfter)
+	c.scaler = newScaler(controller.WithEventRecorder(ctx, c.Recorder), psInformerFactory, impl.EnqueueAfter)
+	c.scaler.namespaceLister = namespaceInformer.Lister()
+	c.scaler.podLister = podInformer.Lister()
 
 	c.Logger.Info("Setting up KPA-Class event handlers")
 	// Handle PodAutoscalers missing the class annotation for backward compatibility.
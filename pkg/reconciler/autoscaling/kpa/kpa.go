@@ -20,12 +20,14 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	perrors "github.com/pkg/errors"
 	"go.uber.org/zap"
 
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
 	"github.com/knative/serving/pkg/apis/autoscaling"
 	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving"
@@ -76,6 +78,7 @@ func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
 		if err := c.Metrics.Delete(ctx, namespace, name); err != nil {
 			return err
 		}
+		c.scaler.patches.forget(namespace, name)
 		return nil
 	} else if err != nil {
 		return err
@@ -137,6 +140,18 @@ func (c *Reconciler) reconcile(ctx context.Context, pa *pav1alpha1.PodAutoscaler
 	if err != nil {
 		return perrors.Wrap(err, "error reconciling decider")
 	}
+	// The excess burst capacity only matters to the ingress layer when
+	// the revision asks for burst capacity to be maintained. Only its sign
+	// decides whether to buffer requests, so it's only published when that
+	// flips, rather than rewriting the SKS on every scaling decision.
+	if decider.Spec.TargetBurstCapacity > 0 {
+		ebc := decider.Status.ExcessBurstCapacity
+		if have := pa.Status.ExcessBurstCapacity; have == nil || (*have < 0) != (ebc < 0) {
+			pa.Status.ExcessBurstCapacity = ptr.Int32(ebc)
+		}
+	} else {
+		pa.Status.ExcessBurstCapacity = nil
+	}
 
 	if err := c.ReconcileMetric(ctx, pa, metricSvc); err != nil {
 		return perrors.Wrap(err, "error reconciling metric")
@@ -144,11 +159,36 @@ func (c *Reconciler) reconcile(ctx context.Context, pa *pav1alpha1.PodAutoscaler
 
 	// Get the appropriate current scale from the metric, and right size
 	// the scaleTargetRef based on it.
-	want, err := c.scaler.Scale(ctx, pa, decider.Status.DesiredScale)
+	now := time.Now()
+	desiredScale := decider.Status.DesiredScale
+	pre := c.preScale(ctx, pa, now)
+	if pa.IsHibernated() {
+		// Nothing but the removal of the hibernation scales the target up.
+		logger.Infof("Hibernated: %d -> 0", desiredScale)
+		desiredScale = 0
+	} else if pre > desiredScale {
+		logger.Infof("Pre-scaling ahead of traffic shift: %d -> %d", desiredScale, pre)
+		desiredScale = pre
+	}
+	if act, expiry := activationScale(pa, now); act > 0 && !pa.IsHibernated() {
+		// Revisit the PA once the activation expires, to let it scale back down.
+		c.scaler.enqueueCB(pa, expiry.Sub(now))
+		if act > desiredScale {
+			logger.Infof("Activating ahead of traffic until %v: %d -> %d", expiry, desiredScale, act)
+			desiredScale = act
+		}
+	}
+	want, err := c.scaler.Scale(ctx, pa, desiredScale)
 	if err != nil {
 		return perrors.Wrap(err, "error scaling target")
 	}
 
+	if config.FromContext(ctx).Autoscaler.EnableDynamicContainerConcurrency {
+		if err := c.scaler.propagateContainerConcurrency(ctx, pa); err != nil {
+			return perrors.Wrap(err, "error propagating container concurrency")
+		}
+	}
+
 	// Compare the desired and observed resources to determine our situation.
 	// We fetch private endpoints here, since for scaling we're interested in the actual
 	// state of the deployment.
@@ -164,14 +204,29 @@ func (c *Reconciler) reconcile(ctx context.Context, pa *pav1alpha1.PodAutoscaler
 	}
 	logger.Infof("PA scale got=%v, want=%v", got, want)
 
-	err = reportMetrics(pa, want, got)
+	// Let the Route know once the revision is scaled up for its traffic.
+	if t, ok := pa.Annotations[autoscaling.PreScaleTimeAnnotationKey]; ok {
+		pa.Status.PreScaleTime = t
+		pa.Status.PreScaleReady = int32(got) >= pre
+	} else {
+		pa.Status.PreScaleTime = ""
+		pa.Status.PreScaleReady = false
+	}
+
+	reporter, err := newStatsReporter(pa)
 	if err != nil {
 		return perrors.Wrap(err, "error reporting metrics")
 	}
+	reportMetrics(reporter, want, got)
+	c.scaler.patches.observe(pa, decider.Status.DecisionTime.Time, got, time.Now(), reporter)
 
 	// computeActiveCondition decides if we need to change the SKS mode,
-	// and returns true if the status has changed.
-	if changed := computeActiveCondition(pa, want, got); changed {
+	// and returns true if the status has changed. The SKS also needs an
+	// update if the excess burst capacity or pending capacity published
+	// on it is stale.
+	changed := computeActiveCondition(pa, want, got)
+	if changed || !equality.Semantic.DeepEqual(sks.Spec.ExcessBurstCapacity, pa.Status.ExcessBurstCapacity) ||
+		sks.Spec.CapacityPending != pa.Status.IsCapacityPending() {
 		_, err := c.ReconcileSKS(ctx, pa)
 		if err != nil {
 			return perrors.Wrap(err, "error re-reconciling SKS")
@@ -204,24 +259,22 @@ func (c *Reconciler) reconcileDecider(ctx context.Context, pa *pav1alpha1.PodAut
 	return decider, nil
 }
 
-func reportMetrics(pa *pav1alpha1.PodAutoscaler, want int32, got int) error {
+func newStatsReporter(pa *pav1alpha1.PodAutoscaler) (autoscaler.StatsReporter, error) {
 	var serviceLabel string
 	var configLabel string
 	if pa.Labels != nil {
 		serviceLabel = pa.Labels[serving.ServiceLabelKey]
 		configLabel = pa.Labels[serving.ConfigurationLabelKey]
 	}
-	reporter, err := autoscaler.NewStatsReporter(pa.Namespace, serviceLabel, configLabel, pa.Name)
-	if err != nil {
-		return err
-	}
+	return autoscaler.NewStatsReporter(pa.Namespace, serviceLabel, configLabel, pa.Name)
+}
 
+func reportMetrics(reporter autoscaler.StatsReporter, want int32, got int) {
 	reporter.ReportActualPodCount(int64(got))
 	// Negative "want" values represent an empty metrics pipeline and thus no specific request is being made.
 	if want >= 0 {
 		reporter.ReportRequestedPodCount(int64(want))
 	}
-	return nil
 }
 
 // computeActiveCondition updates the status of PA, depending on scales desired and present.
@@ -232,7 +285,11 @@ func computeActiveCondition(pa *pav1alpha1.PodAutoscaler, want int32, got int) (
 	switch {
 	case want == 0:
 		ret = !pa.Status.IsInactive() // Any state but inactive should change SKS.
-		pa.Status.MarkInactive("NoTraffic", "The target is not receiving traffic.")
+		if pa.IsHibernated() {
+			pa.Status.MarkInactive("Hibernated", "The target is hibernated.")
+		} else {
+			pa.Status.MarkInactive("NoTraffic", "The target is not receiving traffic.")
+		}
 
 	case got < minReady && want > 0:
 		ret = pa.Status.IsInactive() // If we were inactive and became activating.
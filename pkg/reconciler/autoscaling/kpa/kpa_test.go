@@ -174,6 +174,12 @@ func kpa(ns, n string, opts ...PodAutoscalerOption) *asv1a1.PodAutoscaler {
 	return kpa
 }
 
+func withPreScaleTime(t string) PodAutoscalerOption {
+	return func(pa *asv1a1.PodAutoscaler) {
+		pa.Annotations[autoscaling.PreScaleTimeAnnotationKey] = t
+	}
+}
+
 func markResourceNotOwned(rType, name string) PodAutoscalerOption {
 	return func(pa *asv1a1.PodAutoscaler) {
 		pa.Status.MarkResourceNotOwned(rType, name)
@@ -304,6 +310,7 @@ func TestReconcileAndScaleToZero(t *testing.T) {
 		fakeMetrics := newTestMetrics()
 		psFactory := presources.NewPodScalableInformerFactory(ctx)
 		scaler := newScaler(ctx, psFactory, func(interface{}, time.Duration) {})
+		scaler.podLister = listers.GetPodLister()
 		scaler.activatorProbe = func(*asv1a1.PodAutoscaler, http.RoundTripper) (bool, error) { return true, nil }
 		return &Reconciler{
 			Base: &areconciler.Base{
@@ -365,6 +372,26 @@ func TestReconcile(t *testing.T) {
 			expectedDeploy,
 			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
 		},
+	}, {
+		Name: "pre-scale is reported",
+		Key:  key,
+		Objects: []runtime.Object{
+			kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withPreScaleTime("2001-09-09T01:46:40Z")),
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady),
+			metricsSvc(testNamespace, testRevision, withSvcSelector(usualSelector),
+				withMSvcName("a330-200")),
+			expectedDeploy,
+			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withPreScaleTime("2001-09-09T01:46:40Z"),
+				func(pa *asv1a1.PodAutoscaler) {
+					pa.Status.PreScaleTime = "2001-09-09T01:46:40Z"
+					pa.Status.PreScaleReady = true
+				}),
+		}},
 	}, {
 		Name: "metric-service-mistmatch",
 		Key:  key,
@@ -777,6 +804,8 @@ func TestReconcile(t *testing.T) {
 
 		psFactory := presources.NewPodScalableInformerFactory(ctx)
 		fakeMetrics := newTestMetrics()
+		scaler := newScaler(ctx, psFactory, func(interface{}, time.Duration) {})
+		scaler.podLister = listers.GetPodLister()
 		return &Reconciler{
 			Base: &areconciler.Base{
 				Base:              rpkg.NewBase(ctx, controllerAgentName, newConfigWatcher()),
@@ -789,7 +818,127 @@ func TestReconcile(t *testing.T) {
 			},
 			endpointsLister: listers.GetEndpointsLister(),
 			deciders:        fakeDeciders,
-			scaler:          newScaler(ctx, psFactory, func(interface{}, time.Duration) {}),
+			scaler:          scaler,
+		}
+	}))
+}
+
+func TestReconcileExcessBurstCapacity(t *testing.T) {
+	const key = testNamespace + "/" + testRevision
+	const deployName = testRevision + "-deployment"
+	usualSelector := map[string]string{"a": "b"}
+	desiredScale := int32(11)
+	expectedDeploy := deploy(testNamespace, testRevision, func(d *appsv1.Deployment) {
+		d.Spec.Replicas = &desiredScale
+	})
+	withEBC := func(ebc int32) PodAutoscalerOption {
+		return func(pa *asv1a1.PodAutoscaler) {
+			pa.Status.ExcessBurstCapacity = ptr.Int32(ebc)
+		}
+	}
+	withSKSEBC := func(ebc int32) SKSOption {
+		return func(sks *nv1a1.ServerlessService) {
+			sks.Spec.ExcessBurstCapacity = ptr.Int32(ebc)
+		}
+	}
+
+	cfg := defaultConfig()
+	cfg.Autoscaler.TargetBurstCapacity = 10
+
+	table := TableTest{{
+		Name: "excess burst capacity is published",
+		Key:  key,
+		Objects: []runtime.Object{
+			kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision)),
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady),
+			metricsSvc(testNamespace, testRevision, withSvcSelector(usualSelector),
+				withMSvcName("a330-200")),
+			expectedDeploy,
+			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withEBC(-3)),
+		}},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady,
+				withSKSEBC(-3)),
+		}},
+	}, {
+		Name: "excess burst capacity is up to date",
+		Key:  key,
+		Objects: []runtime.Object{
+			kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withEBC(-3)),
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady,
+				withSKSEBC(-3)),
+			metricsSvc(testNamespace, testRevision, withSvcSelector(usualSelector),
+				withMSvcName("a330-200")),
+			expectedDeploy,
+			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
+		},
+	}, {
+		Name: "excess burst capacity keeps its sign",
+		Key:  key,
+		Objects: []runtime.Object{
+			kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withEBC(-5)),
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady,
+				withSKSEBC(-5)),
+			metricsSvc(testNamespace, testRevision, withSvcSelector(usualSelector),
+				withMSvcName("a330-200")),
+			expectedDeploy,
+			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
+		},
+	}, {
+		Name: "excess burst capacity changes its sign",
+		Key:  key,
+		Objects: []runtime.Object{
+			kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withEBC(4)),
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady,
+				withSKSEBC(4)),
+			metricsSvc(testNamespace, testRevision, withSvcSelector(usualSelector),
+				withMSvcName("a330-200")),
+			expectedDeploy,
+			makeSKSPrivateEndpoints(1, testNamespace, testRevision),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: kpa(testNamespace, testRevision, markActive, withMSvcStatus("a330-200"),
+				WithPAStatusService(testRevision), withEBC(-3)),
+		}},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady,
+				withSKSEBC(-3)),
+		}},
+	}}
+
+	defer logtesting.ClearAll()
+	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
+		fakeDeciders := newTestDeciders()
+		decider := resources.MakeDecider(
+			ctx, kpa(testNamespace, testRevision), cfg.Autoscaler, "trying-hard-to-care-in-this-test")
+		decider.Status.DesiredScale = desiredScale
+		decider.Status.ExcessBurstCapacity = -3
+		fakeDeciders.Create(ctx, decider)
+
+		psFactory := presources.NewPodScalableInformerFactory(ctx)
+		scaler := newScaler(ctx, psFactory, func(interface{}, time.Duration) {})
+		scaler.podLister = listers.GetPodLister()
+		return &Reconciler{
+			Base: &areconciler.Base{
+				Base:              rpkg.NewBase(ctx, controllerAgentName, newConfigWatcher()),
+				PALister:          listers.GetPodAutoscalerLister(),
+				SKSLister:         listers.GetServerlessServiceLister(),
+				ServiceLister:     listers.GetK8sServiceLister(),
+				Metrics:           newTestMetrics(),
+				ConfigStore:       &testConfigStore{config: cfg},
+				PSInformerFactory: psFactory,
+			},
+			endpointsLister: listers.GetEndpointsLister(),
+			deciders:        fakeDeciders,
+			scaler:          scaler,
 		}
 	}))
 }
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"sync"
+	"time"
+
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/autoscaler"
+)
+
+// scalePatch is the last patch of a PA's scale target.
+type scalePatch struct {
+	scale int32
+	time  time.Time
+	// Whether the time from the decision to the patch was reported.
+	actuationReported bool
+}
+
+// scalePatches remembers the last patch of each PA's scale target, to
+// report how long each stage of the scale change took once it's observed:
+// the decision of the autoscaler being actuated and the pods becoming ready.
+// The zero value is ready to use.
+type scalePatches struct {
+	mu      sync.Mutex
+	patches map[string]*scalePatch
+}
+
+// record remembers that the scale target of the PA was patched to scale.
+func (sp *scalePatches) record(pa *pav1alpha1.PodAutoscaler, scale int32, now time.Time) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.patches == nil {
+		sp.patches = make(map[string]*scalePatch)
+	}
+	sp.patches[pa.Namespace+"/"+pa.Name] = &scalePatch{
+		scale: scale,
+		time:  now,
+	}
+}
+
+// observe reports the latencies of the last patch of the PA's scale target.
+// The actuation latency is reported once, if the patch followed the given
+// decision of the autoscaler. The ready latency is reported once got pods,
+// the scale the target was patched to, are ready.
+func (sp *scalePatches) observe(pa *pav1alpha1.PodAutoscaler, decision time.Time, got int, now time.Time, reporter autoscaler.StatsReporter) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	key := pa.Namespace + "/" + pa.Name
+	patch, ok := sp.patches[key]
+	if !ok {
+		return
+	}
+	if !patch.actuationReported && !decision.IsZero() && !patch.time.Before(decision) {
+		reporter.ReportActuationLatency(patch.time.Sub(decision))
+		patch.actuationReported = true
+	}
+	if int32(got) == patch.scale {
+		reporter.ReportReadyLatency(now.Sub(patch.time))
+		delete(sp.patches, key)
+	}
+}
+
+// forget drops the last patch of the PA's scale target.
+func (sp *scalePatches) forget(namespace, name string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	delete(sp.patches, namespace+"/"+name)
+}
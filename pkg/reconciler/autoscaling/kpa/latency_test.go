@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/autoscaler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// latencyReporter records the reported scaling latencies. Reporting
+// any other measure panics.
+type latencyReporter struct {
+	autoscaler.StatsReporter
+	actuation []time.Duration
+	ready     []time.Duration
+}
+
+func (r *latencyReporter) ReportActuationLatency(d time.Duration) error {
+	r.actuation = append(r.actuation, d)
+	return nil
+}
+
+func (r *latencyReporter) ReportReadyLatency(d time.Duration) error {
+	r.ready = append(r.ready, d)
+	return nil
+}
+
+func TestScalePatches(t *testing.T) {
+	pa := &pav1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      testRevision,
+		},
+	}
+	decision := time.Now()
+	patched := decision.Add(200 * time.Millisecond)
+
+	var sp scalePatches
+	reporter := &latencyReporter{}
+
+	// Nothing was patched yet.
+	sp.observe(pa, decision, 0, patched, reporter)
+
+	sp.record(pa, 3, patched)
+	sp.observe(pa, decision, 1, patched.Add(time.Second), reporter)
+	sp.observe(pa, decision, 2, patched.Add(2*time.Second), reporter)
+	sp.observe(pa, decision, 3, patched.Add(5*time.Second), reporter)
+	// The scale change completed, so nothing is reported anymore.
+	sp.observe(pa, decision, 3, patched.Add(6*time.Second), reporter)
+
+	if got, want := reporter.actuation, []time.Duration{200 * time.Millisecond}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Actuation latencies = %v, want: %v", got, want)
+	}
+	if got, want := reporter.ready, []time.Duration{5 * time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ready latencies = %v, want: %v", got, want)
+	}
+}
+
+func TestScalePatchesStaleDecision(t *testing.T) {
+	pa := &pav1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      testRevision,
+		},
+	}
+	now := time.Now()
+
+	var sp scalePatches
+	reporter := &latencyReporter{}
+
+	// A patch before the decision wasn't caused by it, e.g. a pre-scale.
+	sp.record(pa, 2, now)
+	sp.observe(pa, now.Add(time.Second), 1, now.Add(2*time.Second), reporter)
+	// Neither is a patch without any decision.
+	sp.observe(pa, time.Time{}, 1, now.Add(2*time.Second), reporter)
+	if len(reporter.actuation) != 0 {
+		t.Errorf("Actuation latencies = %v, want none", reporter.actuation)
+	}
+
+	// Forgotten patches aren't reported.
+	sp.forget(pa.Namespace, pa.Name)
+	sp.observe(pa, time.Time{}, 2, now.Add(3*time.Second), reporter)
+	if len(reporter.ready) != 0 {
+		t.Errorf("Ready latencies = %v, want none", reporter.ready)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// namespaceHeadroom returns how many pods the scale target of the PA may
+// have under the namespaceMaxScale of its Namespace, and whether the
+// Namespace sets one. The pods of the other revisions count towards it
+// whether they are ready or not. Revisions scaling up at the same time
+// only see each other's pods once they are created, so the cap may be
+// briefly exceeded.
+func (ks *scaler) namespaceHeadroom(pa *pav1alpha1.PodAutoscaler, ps *pav1alpha1.PodScalable) (int32, bool, error) {
+	if ks.namespaceLister == nil {
+		return 0, false, nil
+	}
+	// A missing Namespace simply means there is no cap.
+	ns, err := ks.namespaceLister.Get(pa.Namespace)
+	if err != nil {
+		return 0, false, nil
+	}
+	max := autoscaling.NamespaceLimit(ns.Annotations, autoscaling.NamespaceMaxScaleAnnotationKey)
+	if max == 0 {
+		return 0, false, nil
+	}
+
+	own, err := metav1.LabelSelectorAsSelector(ps.Spec.Selector)
+	if err != nil {
+		return 0, false, err
+	}
+	pods, err := ks.kubeClient.CoreV1().Pods(pa.Namespace).List(metav1.ListOptions{LabelSelector: serving.RevisionLabelKey})
+	if err != nil {
+		return 0, false, err
+	}
+	others := 0
+	for _, p := range pods.Items {
+		if own.Matches(labels.Set(p.Labels)) || !isRunning(&p) {
+			continue
+		}
+		others++
+	}
+	if others >= max {
+		return 0, true, nil
+	}
+	return int32(max - others), true, nil
+}
+
+// isRunning returns whether the pod runs, or is yet to, and so counts
+// towards the cap of its Namespace.
+func isRunning(p *corev1.Pod) bool {
+	return p.DeletionTimestamp == nil && p.Status.Phase != corev1.PodSucceeded && p.Status.Phase != corev1.PodFailed
+}
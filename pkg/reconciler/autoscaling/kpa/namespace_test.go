@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+
+	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	fakekubeclient "knative.dev/pkg/injection/clients/kubeclient/fake"
+	fakenamespaceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace/fake"
+	fakepodinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod/fake"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/reconciler/autoscaling/config"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	presources "github.com/knative/serving/pkg/resources"
+
+	logtesting "knative.dev/pkg/logging/testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+// revisionPod returns a pod of the revision, labeled like the Deployment of
+// the revision labels them.
+func revisionPod(name, revision, uid string, phase corev1.PodPhase) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      name,
+			Labels: map[string]string{
+				serving.RevisionLabelKey: revision,
+				serving.RevisionUID:      uid,
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func TestScalerNamespaceMaxScale(t *testing.T) {
+	var (
+		own     = revisionPod("own", testRevision, "1982", corev1.PodRunning)
+		other   = revisionPod("other", "other", "1983", corev1.PodRunning)
+		another = revisionPod("another", "another", "1984", corev1.PodPending)
+		done    = revisionPod("done", "other", "1983", corev1.PodSucceeded)
+	)
+
+	tests := []struct {
+		name          string
+		maxScale      string
+		pods          []corev1.Pod
+		startReplicas int
+		scaleTo       int32
+		wantScale     int32
+	}{{
+		name:          "no cap",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     5,
+	}, {
+		name:          "invalid cap",
+		maxScale:      "lots",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     5,
+	}, {
+		name:          "under the cap",
+		maxScale:      "10",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     5,
+	}, {
+		name:          "clamped to the cap",
+		maxScale:      "4",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     2,
+	}, {
+		name:          "finished pods don't count",
+		maxScale:      "4",
+		pods:          []corev1.Pod{own, other, done},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     3,
+	}, {
+		name:          "held over the cap",
+		maxScale:      "2",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 1,
+		scaleTo:       5,
+		wantScale:     1,
+	}, {
+		name:          "scale down over the cap",
+		maxScale:      "2",
+		pods:          []corev1.Pod{own, other, another},
+		startReplicas: 4,
+		scaleTo:       3,
+		wantScale:     3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t)
+
+			dynamicClient := fakedynamicclient.Get(ctx)
+			dynamicClient.PrependReactor("patch", "deployments",
+				func(action clientgotesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			kubeClient := fakekubeclient.Get(ctx)
+			for _, p := range test.pods {
+				p := p
+				if _, err := kubeClient.CoreV1().Pods(testNamespace).Create(&p); err != nil {
+					t.Fatalf("Error creating pod: %v", err)
+				}
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}
+			if test.maxScale != "" {
+				ns.Annotations = map[string]string{
+					autoscaling.NamespaceMaxScaleAnnotationKey: test.maxScale,
+				}
+			}
+			namespaceInformer := fakenamespaceinformer.Get(ctx)
+			namespaceInformer.Informer().GetIndexer().Add(ns)
+
+			revision := newRevision(t, fakeservingclient.Get(ctx), 0, 0)
+			newDeployment(t, dynamicClient, names.Deployment(revision), test.startReplicas)
+			revisionScaler := &scaler{
+				dynamicClient:     dynamicClient,
+				kubeClient:        kubeClient,
+				logger:            logtesting.TestLogger(t),
+				psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				namespaceLister:   namespaceInformer.Lister(),
+				podLister:         fakepodinformer.Get(ctx).Lister(),
+			}
+			pa := newKPA(t, fakeservingclient.Get(ctx), revision)
+			kpaMarkActive(pa, metav1.Now().Time)
+
+			ctx = config.ToContext(ctx, defaultConfig())
+			got, err := revisionScaler.Scale(ctx, pa, test.scaleTo)
+			if err != nil {
+				t.Fatalf("Scale() = %v", err)
+			}
+			if got != test.wantScale {
+				t.Errorf("Scale() = %d, want: %d", got, test.wantScale)
+			}
+		})
+	}
+}
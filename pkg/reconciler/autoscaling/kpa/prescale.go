@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	pav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler/autoscaling/config"
+	resourceutil "github.com/knative/serving/pkg/resources"
+	"knative.dev/pkg/logging"
+)
+
+// preScale returns the scale the PA should have ahead of a traffic shift
+// announced by its Route: the requested percentage of the current scale of
+// the revisions the traffic is taken from. The announcement is honored for
+// one stable window, after which the metrics reflect the shifted traffic.
+// Zero is returned when no shift is in effect.
+func (c *Reconciler) preScale(ctx context.Context, pa *pav1alpha1.PodAutoscaler, now time.Time) int32 {
+	percent, err := strconv.Atoi(pa.Annotations[autoscaling.PreScalePercentAnnotationKey])
+	if err != nil || percent <= 0 {
+		return 0
+	}
+	from := pa.Annotations[autoscaling.PreScaleFromAnnotationKey]
+	if from == "" {
+		return 0
+	}
+	shiftTime, err := time.Parse(time.RFC3339, pa.Annotations[autoscaling.PreScaleTimeAnnotationKey])
+	if err != nil || now.After(shiftTime.Add(config.FromContext(ctx).Autoscaler.StableWindow)) {
+		return 0
+	}
+
+	logger := logging.FromContext(ctx)
+	var total int32
+	for _, name := range strings.Split(from, ",") {
+		src, err := c.PALister.PodAutoscalers(pa.Namespace).Get(name)
+		if err != nil {
+			logger.Debugf("Ignoring pre-scale source %q: %v", name, err)
+			continue
+		}
+		ps, err := resourceutil.GetScaleResource(pa.Namespace, src.Spec.ScaleTargetRef, c.PSInformerFactory)
+		if err != nil {
+			logger.Debugf("Ignoring pre-scale source %q: %v", name, err)
+			continue
+		}
+		if ps.Spec.Replicas != nil {
+			total += *ps.Spec.Replicas
+		}
+	}
+	return int32(math.Ceil(float64(total) * float64(percent) / 100))
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kpa
+
+import (
+	"testing"
+	"time"
+
+	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	fakekpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler/fake"
+	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	areconciler "github.com/knative/serving/pkg/reconciler/autoscaling"
+	"github.com/knative/serving/pkg/reconciler/autoscaling/config"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	presources "github.com/knative/serving/pkg/resources"
+
+	logtesting "knative.dev/pkg/logging/testing"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestPreScale(t *testing.T) {
+	defer logtesting.ClearAll()
+	now := time.Now()
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        int32
+	}{{
+		name: "no annotations",
+		want: 0,
+	}, {
+		name: "quarter of the traffic",
+		annotations: map[string]string{
+			autoscaling.PreScalePercentAnnotationKey: "25",
+			autoscaling.PreScaleFromAnnotationKey:    testRevision,
+			autoscaling.PreScaleTimeAnnotationKey:    now.Format(time.RFC3339),
+		},
+		want: 3,
+	}, {
+		name: "all of the traffic",
+		annotations: map[string]string{
+			autoscaling.PreScalePercentAnnotationKey: "100",
+			autoscaling.PreScaleFromAnnotationKey:    testRevision,
+			autoscaling.PreScaleTimeAnnotationKey:    now.Format(time.RFC3339),
+		},
+		want: 10,
+	}, {
+		name: "unknown source",
+		annotations: map[string]string{
+			autoscaling.PreScalePercentAnnotationKey: "50",
+			autoscaling.PreScaleFromAnnotationKey:    "missing," + testRevision,
+			autoscaling.PreScaleTimeAnnotationKey:    now.Format(time.RFC3339),
+		},
+		want: 5,
+	}, {
+		name: "expired",
+		annotations: map[string]string{
+			autoscaling.PreScalePercentAnnotationKey: "50",
+			autoscaling.PreScaleFromAnnotationKey:    testRevision,
+			autoscaling.PreScaleTimeAnnotationKey:    now.Add(-stableWindow - time.Second).Format(time.RFC3339),
+		},
+		want: 0,
+	}, {
+		name: "bad percent",
+		annotations: map[string]string{
+			autoscaling.PreScalePercentAnnotationKey: "lots",
+			autoscaling.PreScaleFromAnnotationKey:    testRevision,
+			autoscaling.PreScaleTimeAnnotationKey:    now.Format(time.RFC3339),
+		},
+		want: 0,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t)
+			ctx = config.ToContext(ctx, defaultConfig())
+
+			revision := newRevision(t, fakeservingclient.Get(ctx), 0, 0)
+			newDeployment(t, fakedynamicclient.Get(ctx), names.Deployment(revision), 10)
+			src := newKPA(t, fakeservingclient.Get(ctx), revision)
+			fakekpainformer.Get(ctx).Informer().GetIndexer().Add(src)
+
+			c := &Reconciler{
+				Base: &areconciler.Base{
+					PALister:          fakekpainformer.Get(ctx).Lister(),
+					PSInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				},
+			}
+			pa := kpa(testNamespace, "new-revision")
+			for k, v := range test.annotations {
+				pa.Annotations[k] = v
+			}
+
+			if got := c.preScale(ctx, pa, now); got != test.want {
+				t.Errorf("preScale() = %d, want: %d", got, test.want)
+			}
+		})
+	}
+}
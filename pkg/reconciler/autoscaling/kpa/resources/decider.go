@@ -54,6 +54,11 @@ func MakeDecider(ctx context.Context, pa *v1alpha1.PodAutoscaler, config *autosc
 	target, total := resources.ResolveConcurrency(pa, config)
 	panicThreshold := target * panicThresholdPercentage / 100.0
 
+	smoothing := config.ScaleSmoothing
+	if filter, ok := pa.ScaleSmoothing(); ok {
+		smoothing.Filter = filter
+	}
+
 	return &autoscaler.Decider{
 		ObjectMeta: *pa.ObjectMeta.DeepCopy(),
 		Spec: autoscaler.DeciderSpec{
@@ -65,6 +70,11 @@ func MakeDecider(ctx context.Context, pa *v1alpha1.PodAutoscaler, config *autosc
 			PanicThreshold:      panicThreshold,
 			StableWindow:        resources.StableWindow(pa, config),
 			ServiceName:         svc,
+
+			LearnedMinScaleMax:       pa.LearnedMinScaleMax(),
+			LearnedMinScaleRetention: config.LearnedMinScaleRetention,
+			ScalingStrategy:          pa.ScalingStrategy(),
+			ScaleSmoothing:           smoothing,
 		},
 	}
 }
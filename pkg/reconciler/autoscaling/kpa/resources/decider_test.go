@@ -18,6 +18,7 @@ package resources
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -96,6 +97,39 @@ func TestMakeDecider(t *testing.T) {
 			withService("rock-solid"),
 			withTarget(10.0), withPanicThreshold(40.0), withTotal(10.0),
 			withTargetAnnotation("10"), withPanicThresholdPercentageAnnotation("400")),
+	}, {
+		name: "with learned min scale",
+		pa:   pa(WithLearnedMinScaleMax(3)),
+		want: decider(withTarget(100.0), withPanicThreshold(200.0), withTotal(100),
+			withLearnedMinScale(3, time.Hour)),
+		cfgOpt: func(c autoscaler.Config) *autoscaler.Config {
+			c.LearnedMinScaleRetention = time.Hour
+			return &c
+		},
+	}, {
+		name: "with scaling strategy",
+		pa:   pa(WithScalingStrategy("pid")),
+		want: decider(withTarget(100.0), withPanicThreshold(200.0), withTotal(100),
+			withScalingStrategy("pid")),
+	}, {
+		name: "with scale smoothing",
+		pa:   pa(),
+		want: decider(withTarget(100.0), withPanicThreshold(200.0), withTotal(100),
+			withScaleSmoothing(autoscaler.ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA, Weight: 0.5, ProportionalGain: 0.4})),
+		cfgOpt: func(c autoscaler.Config) *autoscaler.Config {
+			c.ScaleSmoothing = autoscaler.ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA, Weight: 0.5, ProportionalGain: 0.4}
+			return &c
+		},
+	}, {
+		name: "with scale smoothing annotation",
+		pa:   pa(WithScaleSmoothing(autoscaling.ScaleSmoothingPID)),
+		want: decider(withTarget(100.0), withPanicThreshold(200.0), withTotal(100),
+			withScaleSmoothingAnnotation(autoscaling.ScaleSmoothingPID),
+			withScaleSmoothing(autoscaler.ScaleSmoothing{Filter: autoscaling.ScaleSmoothingPID, Weight: 0.5, ProportionalGain: 0.4})),
+		cfgOpt: func(c autoscaler.Config) *autoscaler.Config {
+			c.ScaleSmoothing = autoscaler.ScaleSmoothing{Filter: autoscaling.ScaleSmoothingEWMA, Weight: 0.5, ProportionalGain: 0.4}
+			return &c
+		},
 	}}
 
 	for _, tc := range cases {
@@ -183,6 +217,33 @@ func withService(s string) DeciderOption {
 	}
 }
 
+func withLearnedMinScale(max int32, retention time.Duration) DeciderOption {
+	return func(decider *autoscaler.Decider) {
+		decider.Annotations[autoscaling.LearnedMinScaleMaxAnnotationKey] = strconv.Itoa(int(max))
+		decider.Spec.LearnedMinScaleMax = max
+		decider.Spec.LearnedMinScaleRetention = retention
+	}
+}
+
+func withScalingStrategy(name string) DeciderOption {
+	return func(decider *autoscaler.Decider) {
+		decider.Annotations[autoscaling.ScalingStrategyAnnotationKey] = name
+		decider.Spec.ScalingStrategy = name
+	}
+}
+
+func withScaleSmoothing(s autoscaler.ScaleSmoothing) DeciderOption {
+	return func(decider *autoscaler.Decider) {
+		decider.Spec.ScaleSmoothing = s
+	}
+}
+
+func withScaleSmoothingAnnotation(filter string) DeciderOption {
+	return func(decider *autoscaler.Decider) {
+		decider.Annotations[autoscaling.ScaleSmoothingAnnotationKey] = filter
+	}
+}
+
 func withPanicThreshold(threshold float64) DeciderOption {
 	return func(decider *autoscaler.Decider) {
 		decider.Spec.PanicThreshold = threshold
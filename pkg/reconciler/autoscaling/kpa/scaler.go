@@ -19,13 +19,17 @@ package kpa
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 
 	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection/clients/dynamicclient"
+	"knative.dev/pkg/injection/clients/kubeclient"
 	"knative.dev/pkg/logging"
 
 	"github.com/knative/serving/pkg/activator"
@@ -38,9 +42,13 @@ import (
 	aresources "github.com/knative/serving/pkg/reconciler/autoscaling/resources"
 	"github.com/knative/serving/pkg/resources"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -67,8 +75,15 @@ type asyncProber interface {
 type scaler struct {
 	psInformerFactory duck.InformerFactory
 	dynamicClient     dynamic.Interface
+	kubeClient        kubernetes.Interface
+	recorder          record.EventRecorder
 	logger            *zap.SugaredLogger
 	transportFactory  prober.TransportFactory
+	// namespaceLister reads the caps of the Namespaces, they aren't
+	// enforced when it's nil.
+	namespaceLister corev1listers.NamespaceLister
+	// podLister reads the pods of the scale targets from the informer cache.
+	podLister corev1listers.PodLister
 
 	// For sync probes.
 	activatorProbe func(pa *pav1alpha1.PodAutoscaler, transport http.RoundTripper) (bool, error)
@@ -76,6 +91,9 @@ type scaler struct {
 	// For async probes.
 	probeManager asyncProber
 	enqueueCB    func(interface{}, time.Duration)
+
+	// The last patch of each scale target, to report scaling latencies.
+	patches scalePatches
 }
 
 // newScaler creates a scaler.
@@ -86,6 +104,8 @@ func newScaler(ctx context.Context, psInformerFactory duck.InformerFactory, enqu
 		// informer/lister each time.
 		psInformerFactory: psInformerFactory,
 		dynamicClient:     dynamicclient.Get(ctx),
+		kubeClient:        kubeclient.Get(ctx),
+		recorder:          controller.GetEventRecorder(ctx),
 		logger:            logger,
 		transportFactory: func() http.RoundTripper {
 			return network.NewAutoTransport()
@@ -103,11 +123,20 @@ func newScaler(ctx context.Context, psInformerFactory duck.InformerFactory, enqu
 	return ks
 }
 
+// scaledPods returns the pods selected by the scale target.
+func (ks *scaler) scaledPods(namespace string, ps *pav1alpha1.PodScalable) ([]*corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ps.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return ks.podLister.Pods(namespace).List(selector)
+}
+
 // Resolves the pa to hostname:port.
 func paToProbeTarget(pa *pav1alpha1.PodAutoscaler) string {
 	svc := network.GetServiceHostname(pa.Status.ServiceName, pa.Namespace)
 	port := networking.ServicePort(pa.Spec.ProtocolType)
-	return fmt.Sprintf("http://%s:%d/", svc, port)
+	return "http://" + net.JoinHostPort(svc, strconv.Itoa(port)) + "/"
 }
 
 // activatorProbe returns true if via probe it determines that the
@@ -140,19 +169,21 @@ func (ks *scaler) handleScaleToZero(pa *pav1alpha1.PodAutoscaler, desiredScale i
 	//   a) enable-scale-to-zero from configmap is true
 	//   b) The PA has been active for at least the stable window, after which it gets marked inactive
 	//   c) The PA has been inactive for at least the grace period
+	// A hibernated PA only waits for the activator to be put in the request path.
+	hibernated := pa.IsHibernated()
 
-	if !config.EnableScaleToZero {
+	if !config.EnableScaleToZero && !hibernated {
 		return 1, true
 	}
 
-	if pa.Status.IsActivating() { // Active=Unknown
+	if pa.Status.IsActivating() && !hibernated { // Active=Unknown
 		return scaleUnknown, false
-	} else if pa.Status.IsReady() { // Active=True
+	} else if pa.Status.IsReady() || pa.Status.IsActivating() { // Active=True, or hibernated while activating
 		// Don't scale-to-zero if the PA is active
 
 		// Do not scale to 0, but return desiredScale of 0 to mark PA inactive.
 		sw := aresources.StableWindow(pa, config)
-		if pa.Status.CanMarkInactive(sw) {
+		if hibernated || pa.Status.CanMarkInactive(sw) {
 			// We do not need to enqueue PA here, since this will
 			// make SKS reconcile and when it's done, PA will be reconciled again.
 			return desiredScale, false
@@ -166,7 +197,7 @@ func (ks *scaler) handleScaleToZero(pa *pav1alpha1.PodAutoscaler, desiredScale i
 		ks.logger.Infof("%s probing activator = %v, err = %v", pa.Name, r, err)
 		if r {
 			// Make sure we've been inactive for enough time.
-			if pa.Status.CanScaleToZero(config.ScaleToZeroGracePeriod) {
+			if hibernated || pa.Status.CanScaleToZero(config.ScaleToZeroGracePeriod) {
 				return desiredScale, true
 			}
 			// Re-enqeue the PA for reconciliation after grace period.
@@ -214,10 +245,14 @@ func (ks *scaler) applyScale(ctx context.Context, pa *pav1alpha1.PodAutoscaler,
 	}
 
 	logger.Debug("Successfully scaled.")
+	ks.patches.record(pa, desiredScale, time.Now())
 	return desiredScale, nil
 }
 
 // Scale attempts to scale the given PA's target reference to the desired scale.
+// It holds back scale ups while pods of the target can't be scheduled, which
+// it surfaces in the CapacityPending condition of the PA, and clamps them to
+// the namespaceMaxScale of the PA's Namespace.
 func (ks *scaler) Scale(ctx context.Context, pa *pav1alpha1.PodAutoscaler, desiredScale int32) (int32, error) {
 	logger := logging.FromContext(ctx)
 
@@ -227,10 +262,22 @@ func (ks *scaler) Scale(ctx context.Context, pa *pav1alpha1.PodAutoscaler, desir
 	}
 
 	min, max := pa.ScaleBounds()
+	if pa.IsHibernated() {
+		min = 0
+	}
 	if newScale := applyBounds(min, max, desiredScale); newScale != desiredScale {
 		logger.Debugf("Adjusting desiredScale to meet the min and max bounds before applying: %d -> %d", desiredScale, newScale)
 		desiredScale = newScale
 	}
+	if zones, zoneMin := pa.ZoneSpread(); desiredScale >= zoneMin && desiredScale < zones {
+		// Keep a Pod in every zone, unless maxScale doesn't allow for it.
+		newScale := zones
+		if max != 0 && newScale > max {
+			newScale = max
+		}
+		logger.Debugf("Adjusting desiredScale to spread across %d zones: %d -> %d", zones, desiredScale, newScale)
+		desiredScale = newScale
+	}
 
 	desiredScale, shouldApplyScale := ks.handleScaleToZero(pa, desiredScale, config.FromContext(ctx).Autoscaler)
 	if !shouldApplyScale {
@@ -247,10 +294,37 @@ func (ks *scaler) Scale(ctx context.Context, pa *pav1alpha1.PodAutoscaler, desir
 	if ps.Spec.Replicas != nil {
 		currentScale = *ps.Spec.Replicas
 	}
+	// The pods are only checked while they matter, to spare the API server.
+	if desiredScale > currentScale || pa.Status.IsCapacityPending() {
+		if ks.checkCapacity(ctx, pa, ps) && desiredScale > currentScale {
+			// More pods would only be as unschedulable as the pending ones.
+			logger.Infof("Holding the scale at %d instead of %d while pods can't be scheduled", currentScale, desiredScale)
+			desiredScale = currentScale
+		}
+	}
+	if desiredScale > currentScale {
+		headroom, capped, err := ks.namespaceHeadroom(pa, ps)
+		if err != nil {
+			logger.Errorw("Error counting the pods of the namespace", zap.Error(err))
+		} else if capped && desiredScale > headroom {
+			// The other revisions scale down on their own, this one
+			// just doesn't take more of the namespace.
+			newScale := headroom
+			if newScale < currentScale {
+				newScale = currentScale
+			}
+			logger.Infof("Clamping the scale at %d instead of %d to the maxScale of the namespace", newScale, desiredScale)
+			desiredScale = newScale
+		}
+	}
 	if desiredScale == currentScale {
 		return desiredScale, nil
 	}
 
+	if desiredScale > 0 && desiredScale < currentScale && config.FromContext(ctx).Autoscaler.EnablePodConsolidation {
+		ks.consolidate(ctx, pa, ps)
+	}
+
 	logger.Infof("Scaling from %d to %d", currentScale, desiredScale)
 	return ks.applyScale(ctx, pa, desiredScale, ps)
 }
@@ -29,6 +29,7 @@ import (
 	// These are the fake informers we want setup.
 	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
 	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
+	fakepodinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/pod/fake"
 
 	"github.com/knative/serving/pkg/activator"
 	"github.com/knative/serving/pkg/apis/autoscaling"
@@ -213,6 +214,27 @@ func TestScaler(t *testing.T) {
 		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
 			kpaMarkInactive(k, time.Now().Add(-gracePeriod))
 		},
+	}, {
+		label:         "hibernated marks inactive without waiting for idle",
+		startReplicas: 1,
+		scaleTo:       0,
+		wantReplicas:  0,
+		wantScaling:   false,
+		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
+			k.Annotations[autoscaling.HibernatedAnnotationKey] = "true"
+			kpaMarkActive(k, time.Now())
+		},
+	}, {
+		label:         "hibernated scales to zero before grace period, ignoring minScale",
+		startReplicas: 10,
+		scaleTo:       0,
+		minScale:      2,
+		wantReplicas:  0,
+		wantScaling:   true,
+		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
+			k.Annotations[autoscaling.HibernatedAnnotationKey] = "true"
+			kpaMarkInactive(k, time.Now())
+		},
 	}, {
 		label:         "scales up",
 		startReplicas: 1,
@@ -226,6 +248,35 @@ func TestScaler(t *testing.T) {
 		maxScale:      8,
 		wantReplicas:  8,
 		wantScaling:   true,
+	}, {
+		label:         "scales up to one Pod per zone",
+		startReplicas: 1,
+		scaleTo:       2,
+		wantReplicas:  3,
+		wantScaling:   true,
+		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
+			k.Annotations[autoscaling.ZoneSpreadAnnotationKey] = "3"
+		},
+	}, {
+		label:         "does not spread across zones below zoneSpreadMinScale",
+		startReplicas: 1,
+		scaleTo:       2,
+		wantReplicas:  2,
+		wantScaling:   true,
+		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
+			k.Annotations[autoscaling.ZoneSpreadAnnotationKey] = "3"
+			k.Annotations[autoscaling.ZoneSpreadMinScaleAnnotationKey] = "3"
+		},
+	}, {
+		label:         "zone spread is capped by maxScale",
+		startReplicas: 1,
+		scaleTo:       1,
+		maxScale:      2,
+		wantReplicas:  2,
+		wantScaling:   true,
+		kpaMutation: func(k *pav1alpha1.PodAutoscaler) {
+			k.Annotations[autoscaling.ZoneSpreadAnnotationKey] = "3"
+		},
 	}, {
 		label:         "scale up inactive revision",
 		startReplicas: 1,
@@ -273,6 +324,7 @@ func TestScaler(t *testing.T) {
 			revisionScaler := newScaler(ctx, presources.NewPodScalableInformerFactory(ctx), func(interface{}, time.Duration) {
 				cbCount++
 			})
+			revisionScaler.podLister = fakepodinformer.Get(ctx).Lister()
 			if test.proberfunc != nil {
 				revisionScaler.activatorProbe = test.proberfunc
 			} else {
@@ -379,6 +431,7 @@ func TestDisableScaleToZero(t *testing.T) {
 				dynamicClient:     fakedynamicclient.Get(ctx),
 				logger:            logging.FromContext(ctx),
 				psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
+				podLister:         fakepodinformer.Get(ctx).Lister(),
 			}
 			pa := newKPA(t, fakeservingclient.Get(ctx), revision)
 
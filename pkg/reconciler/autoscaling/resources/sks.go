@@ -42,6 +42,10 @@ func MakeSKS(pa *pav1alpha1.PodAutoscaler, mode nv1a1.ServerlessServiceOperation
 			Mode:         mode,
 			ObjectRef:    pa.Spec.ScaleTargetRef,
 			ProtocolType: pa.Spec.ProtocolType,
+			// Published for the ingress layer.
+			ExcessBurstCapacity: pa.Status.ExcessBurstCapacity,
+			// Published for the activator.
+			CapacityPending: pa.Status.IsCapacityPending(),
 		},
 	}
 }
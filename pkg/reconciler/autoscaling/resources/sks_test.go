@@ -91,4 +91,18 @@ func TestMakeSKS(t *testing.T) {
 	if got, want := MakeSKS(pa, mode), want; !cmp.Equal(got, want) {
 		t.Errorf("MakeSKS = %#v, want: %#v, diff: %s", got, want, cmp.Diff(got, want))
 	}
+
+	// The excess burst capacity is published for the ingress layer.
+	pa.Status.ExcessBurstCapacity = ptr.Int32(-5)
+	want.Spec.ExcessBurstCapacity = ptr.Int32(-5)
+	if got, want := MakeSKS(pa, mode), want; !cmp.Equal(got, want) {
+		t.Errorf("MakeSKS = %#v, want: %#v, diff: %s", got, want, cmp.Diff(got, want))
+	}
+
+	// So is whether pods of the revision can't be scheduled.
+	pa.Status.MarkCapacityPending("0/3 nodes are available: 3 Insufficient cpu.")
+	want.Spec.CapacityPending = true
+	if got, want := MakeSKS(pa, mode), want; !cmp.Equal(got, want) {
+		t.Errorf("MakeSKS = %#v, want: %#v, diff: %s", got, want, cmp.Diff(got, want))
+	}
 }
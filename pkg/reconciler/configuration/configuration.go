@@ -268,6 +268,15 @@ func (c *Reconciler) createRevision(ctx context.Context, config *v1alpha1.Config
 	logger := logging.FromContext(ctx)
 
 	rev := resources.MakeRevision(config)
+	if rev.Name == "" {
+		name, err := resources.RevisionName(config)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			rev.Name, rev.GenerateName = name, ""
+		}
+	}
 	created, err := c.ServingClientSet.ServingV1alpha1().Revisions(config.Namespace).Create(rev)
 	if err != nil {
 		return nil, err
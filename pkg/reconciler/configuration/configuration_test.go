@@ -31,6 +31,7 @@ import (
 	"knative.dev/pkg/controller"
 	logtesting "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
+	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
 	"github.com/knative/serving/pkg/gc"
@@ -119,6 +120,45 @@ func TestReconcile(t *testing.T) {
 			Eventf(corev1.EventTypeNormal, "Created", "Created Revision %q", "byo-name-create-foo"),
 		},
 		Key: "foo/byo-name-create",
+	}, {
+		Name: "create revision from name template",
+		Objects: []runtime.Object{
+			cfg("name-template", "foo", 1234, withRevisionNameTemplate),
+		},
+		WantCreates: []runtime.Object{
+			rev("name-template", "foo", 1234, func(rev *v1alpha1.Revision) {
+				rev.Name = "name-template-1234-abc123"
+				rev.GenerateName = ""
+				rev.Annotations = map[string]string{"example.com/git-sha": "abc123"}
+			}),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: cfg("name-template", "foo", 1234, withRevisionNameTemplate,
+				WithLatestCreated("name-template-1234-abc123"), WithObservedGen),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", "Created Revision %q", "name-template-1234-abc123"),
+		},
+		Key: "foo/name-template",
+	}, {
+		Name:    "create revision from name template (invalid name)",
+		WantErr: true,
+		Objects: []runtime.Object{
+			cfg("bad-name-template", "foo", 1234, withRevisionNameTemplate, func(cfg *v1alpha1.Configuration) {
+				cfg.Spec.GetTemplate().Annotations["example.com/git-sha"] = "ABC_123"
+			}),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: cfg("bad-name-template", "foo", 1234, withRevisionNameTemplate, func(cfg *v1alpha1.Configuration) {
+				cfg.Spec.GetTemplate().Annotations["example.com/git-sha"] = "ABC_123"
+			}, MarkRevisionCreationFailed(badTemplateNameErr)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "CreationFailed", "Failed to create Revision for Configuration %q: %v",
+				"bad-name-template", badTemplateNameErr),
+			Eventf(corev1.EventTypeWarning, "InternalError", badTemplateNameErr),
+		},
+		Key: "foo/bad-name-template",
 	}, {
 		Name: "create revision byo name (exists)",
 		Objects: []runtime.Object{
@@ -584,6 +624,19 @@ func rev(name, namespace string, generation int64, ro ...RevisionOption) *v1alph
 	return r
 }
 
+const badTemplateNameErr = `invalid revision name "bad-name-template-1234-ABC_123" from serving.knative.dev/revisionNameTemplate: ` +
+	`[a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, ` +
+	`and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]`
+
+// withRevisionNameTemplate names the Revisions after the generation and
+// the git commit annotated on the template.
+func withRevisionNameTemplate(cfg *v1alpha1.Configuration) {
+	cfg.Annotations = map[string]string{
+		serving.RevisionNameTemplateAnnotationKey: `{{.Config}}-{{.Generation}}-{{index .Annotations "example.com/git-sha"}}`,
+	}
+	cfg.Spec.GetTemplate().Annotations = map[string]string{"example.com/git-sha": "abc123"}
+}
+
 type testConfigStore struct {
 	config *config.Config
 }
@@ -50,6 +50,21 @@ func MakeRevision(config *v1alpha1.Configuration) *v1alpha1.Revision {
 	return rev
 }
 
+// RevisionName returns the name the revision name template of the
+// Configuration gives to the Revision of its current generation, or "" if
+// it has no template.
+func RevisionName(config *v1alpha1.Configuration) (string, error) {
+	text, ok := config.Annotations[serving.RevisionNameTemplateAnnotationKey]
+	if !ok {
+		return "", nil
+	}
+	return serving.ExecuteRevisionNameTemplate(text, serving.RevisionNameData{
+		Config:      config.Name,
+		Generation:  config.Generation,
+		Annotations: config.Spec.GetTemplate().Annotations,
+	})
+}
+
 // UpdateRevisionLabels sets the revisions labels given a Configuration.
 func UpdateRevisionLabels(rev *v1alpha1.Revision, config *v1alpha1.Configuration) {
 	if rev.Labels == nil {
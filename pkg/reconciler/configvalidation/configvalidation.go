@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configvalidation
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// PruneUnknownKeysAnnotationKey is the annotation that opts a ConfigMap
+// into having the keys none of our components read removed from it,
+// instead of only having them reported.
+const PruneUnknownKeysAnnotationKey = serving.GroupName + "/pruneUnknownKeys"
+
+// Reconciler implements controller.Reconciler for the ConfigMaps
+// configuring Knative Serving.
+type Reconciler struct {
+	*reconciler.Base
+
+	configMapLister corev1listers.ConfigMapLister
+	schemas         map[string]*schema
+}
+
+// Check that our Reconciler implements controller.Reconciler
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile validates the ConfigMap with the given key against its schema,
+// reporting invalid values and unknown keys as events on the ConfigMap.
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		c.Logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+
+	s, ok := c.schemas[name]
+	if !ok {
+		return nil
+	}
+	cm, err := c.configMapLister.ConfigMaps(namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Retrying won't fix an invalid value, so it is only reported.
+	if err := s.validate(cm); err != nil {
+		logger.Errorf("ConfigMap %s is invalid: %v", name, err)
+		c.Recorder.Eventf(cm, corev1.EventTypeWarning, "InvalidValue",
+			"ConfigMap %s is invalid: %v", name, err)
+	}
+
+	unknown := s.unknownKeys(cm)
+	if len(unknown) == 0 {
+		return nil
+	}
+	keys := strings.Join(unknown, ", ")
+	if prune, _ := strconv.ParseBool(cm.Annotations[PruneUnknownKeysAnnotationKey]); !prune {
+		logger.Warnf("ConfigMap %s has unknown keys: %s", name, keys)
+		c.Recorder.Eventf(cm, corev1.EventTypeWarning, "UnknownKeys",
+			"Keys %s are not read by any component, check them for typos", keys)
+		return nil
+	}
+
+	pruned := cm.DeepCopy()
+	for _, key := range unknown {
+		delete(pruned.Data, key)
+	}
+	if _, err := c.KubeClientSet.CoreV1().ConfigMaps(namespace).Update(pruned); err != nil {
+		c.Recorder.Eventf(cm, corev1.EventTypeWarning, "UpdateFailed",
+			"Failed to remove unknown keys %s: %v", keys, err)
+		return err
+	}
+	logger.Infof("Removed unknown keys %s from ConfigMap %s", keys, name)
+	c.Recorder.Eventf(cm, corev1.EventTypeNormal, "PrunedUnknownKeys",
+		"Removed unknown keys %s", keys)
+	return nil
+}
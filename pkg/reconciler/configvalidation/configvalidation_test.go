@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configvalidation
+
+import (
+	"context"
+	"testing"
+
+	// Install our fake informers
+	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/configmap/fake"
+
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
+
+	. "github.com/knative/serving/pkg/reconciler/testing/v1alpha1"
+	. "knative.dev/pkg/reconciler/testing"
+	_ "knative.dev/pkg/system/testing"
+)
+
+func TestReconcile(t *testing.T) {
+	defer logtesting.ClearAll()
+
+	TableTest{{
+		Name: "bad workqueue key",
+		Key:  "too/many/parts",
+	}, {
+		Name: "key not found",
+		Key:  system.Namespace() + "/" + autoscaler.ConfigName,
+	}, {
+		Name: "not one of our ConfigMaps",
+		Objects: []runtime.Object{
+			configMap("config-unknown", map[string]string{"foo": "bar"}),
+		},
+		Key: system.Namespace() + "/config-unknown",
+	}, {
+		Name: "valid",
+		Objects: []runtime.Object{
+			configMap(autoscaler.ConfigName, map[string]string{
+				"_example":      "documentation",
+				"stable-window": "60s",
+			}),
+		},
+		Key: system.Namespace() + "/" + autoscaler.ConfigName,
+	}, {
+		Name: "invalid value",
+		Objects: []runtime.Object{
+			configMap(autoscaler.ConfigName, map[string]string{
+				"max-scale-up-rate": "fast",
+			}),
+		},
+		Key: system.Namespace() + "/" + autoscaler.ConfigName,
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "InvalidValue",
+				"ConfigMap %s is invalid: %v", autoscaler.ConfigName,
+				`strconv.ParseFloat: parsing "fast": invalid syntax`),
+		},
+	}, {
+		Name: "unknown keys",
+		Objects: []runtime.Object{
+			configMap(autoscaler.ConfigName, map[string]string{
+				"stable-windw":        "60s",
+				"enable-scale-to-zer": "false",
+			}),
+		},
+		Key: system.Namespace() + "/" + autoscaler.ConfigName,
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "UnknownKeys",
+				"Keys %s are not read by any component, check them for typos",
+				"enable-scale-to-zer, stable-windw"),
+		},
+	}, {
+		Name: "unknown keys are pruned",
+		Objects: []runtime.Object{
+			configMap(autoscaler.ConfigName, map[string]string{
+				"stable-window": "60s",
+				"stable-windw":  "60s",
+			}, withPruning),
+		},
+		Key: system.Namespace() + "/" + autoscaler.ConfigName,
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: configMap(autoscaler.ConfigName, map[string]string{
+				"stable-window": "60s",
+			}, withPruning),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "PrunedUnknownKeys",
+				"Removed unknown keys %s", "stable-windw"),
+		},
+	}, {
+		Name:    "failure pruning unknown keys",
+		WantErr: true,
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("update", "configmaps"),
+		},
+		Objects: []runtime.Object{
+			configMap(autoscaler.ConfigName, map[string]string{
+				"stable-windw": "60s",
+			}, withPruning),
+		},
+		Key: system.Namespace() + "/" + autoscaler.ConfigName,
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: configMap(autoscaler.ConfigName, map[string]string{}, withPruning),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "UpdateFailed",
+				"Failed to remove unknown keys %s: %v", "stable-windw",
+				"inducing failure for update configmaps"),
+		},
+	}}.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
+		c := &Reconciler{
+			Base:            reconciler.NewBase(ctx, controllerAgentName, cmw),
+			configMapLister: listers.GetConfigMapLister(),
+		}
+		c.schemas = newSchemas(c.Logger, 0)
+		return c
+	}))
+}
+
+func configMap(name string, data map[string]string, opts ...func(*corev1.ConfigMap)) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: system.Namespace(),
+		},
+		Data: data,
+	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	return cm
+}
+
+func withPruning(cm *corev1.ConfigMap) {
+	cm.Annotations = map[string]string{
+		PruneUnknownKeysAnnotationKey: "true",
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configvalidation
+
+import (
+	"context"
+
+	"github.com/knative/serving/pkg/reconciler"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	configmapinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/configmap"
+	"knative.dev/pkg/system"
+)
+
+const (
+	controllerAgentName = "configvalidation-controller"
+)
+
+// NewController initializes the controller validating the ConfigMaps in
+// the system namespace. Invalid ConfigMaps are found as the informer lists
+// them on startup and whenever they change.
+func NewController(
+	ctx context.Context,
+	cmw configmap.Watcher,
+) *controller.Impl {
+	configMapInformer := configmapinformer.Get(ctx)
+
+	c := &Reconciler{
+		Base:            reconciler.NewBase(ctx, controllerAgentName, cmw),
+		configMapLister: configMapInformer.Lister(),
+	}
+	c.schemas = newSchemas(c.Logger, controller.GetResyncPeriod(ctx))
+	impl := controller.NewImpl(c, c.Logger, "ConfigValidation")
+
+	c.Logger.Info("Setting up event handlers")
+	configMapInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: reconciler.NamespaceFilterFunc(system.Namespace()),
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+
+	return impl
+}
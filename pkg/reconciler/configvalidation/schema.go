@@ -0,0 +1,214 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configvalidation
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/config"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/gc"
+	"github.com/knative/serving/pkg/network"
+	certconfig "github.com/knative/serving/pkg/reconciler/certificate/config"
+	istioconfig "github.com/knative/serving/pkg/reconciler/ingress/config"
+	routeconfig "github.com/knative/serving/pkg/reconciler/route/config"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/configmap"
+)
+
+// schema describes the keys and values a ConfigMap accepts.
+type schema struct {
+	// keys lists the keys the ConfigMap accepts.
+	keys sets.String
+	// prefixes lists the prefixes of the keys the ConfigMap accepts, for
+	// the ConfigMaps whose keys embed user-defined names.
+	prefixes []string
+	// validate parses the ConfigMap the way the components reading it do.
+	validate func(*corev1.ConfigMap) error
+}
+
+// unknownKeys returns the sorted keys of the ConfigMap the schema doesn't
+// accept. Keys starting with an underscore, like the _example block, are
+// documentation and always accepted.
+func (s *schema) unknownKeys(cm *corev1.ConfigMap) []string {
+	var unknown []string
+	for key := range cm.Data {
+		if strings.HasPrefix(key, "_") || s.keys.Has(key) || s.hasPrefix(key) {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func (s *schema) hasPrefix(key string) bool {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newSchemas returns the schemas of the ConfigMaps we validate, keyed by
+// the name of the ConfigMap. config-domain, config-logging and
+// config-observability accept arbitrary keys and aren't validated.
+func newSchemas(logger configmap.Logger, minRevisionTimeout time.Duration) map[string]*schema {
+	gcConfig := gc.NewConfigFromConfigMapFunc(logger, minRevisionTimeout)
+	return map[string]*schema{
+		autoscaler.ConfigName: {
+			keys: sets.NewString(
+				"container-concurrency-target-default",
+				"container-concurrency-target-percentage",
+				"enable-checkpoint-restore",
+				"enable-dynamic-container-concurrency",
+				"enable-pod-consolidation",
+				"enable-scale-to-zero",
+				"learned-min-scale-retention",
+				"max-scale-up-rate",
+				"panic-threshold-percentage",
+				"panic-window",
+				"panic-window-percentage",
+				"scale-smoothing",
+				"scale-smoothing-ewma-weight",
+				"scale-smoothing-pid-derivative-gain",
+				"scale-smoothing-pid-integral-gain",
+				"scale-smoothing-pid-proportional-gain",
+				"scale-to-zero-grace-period",
+				"stable-window",
+				"target-burst-capacity",
+				"tick-interval",
+			),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := autoscaler.NewConfigFromConfigMap(cm)
+				return err
+			},
+		},
+		certconfig.CertManagerConfigName: {
+			keys: sets.NewString("issuerRef", "solverConfig"),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := certconfig.NewCertManagerConfigFromConfigMap(cm)
+				return err
+			},
+		},
+		config.DefaultsConfigName: {
+			keys: sets.NewString(
+				"container-name-template",
+				"max-revision-timeout-seconds",
+				"queue-max-connections",
+				"queue-max-header-bytes",
+				"queue-read-header-timeout-seconds",
+				"revision-cpu-limit",
+				"revision-cpu-request",
+				"revision-memory-limit",
+				"revision-memory-request",
+				"revision-timeout-seconds",
+			),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := config.NewDefaultsConfigFromConfigMap(cm)
+				return err
+			},
+		},
+		deployment.ConfigName: {
+			keys: sets.NewString(
+				deployment.ActivatorBallastKey,
+				deployment.ActivatorGOGCKey,
+				deployment.ActivatorMemoryLimitKey,
+				deployment.ConcurrencyStateEndpointKey,
+				deployment.EnableEarlyHintsKey,
+				deployment.EnableQueueConfigReloadKey,
+				deployment.QueueSidecarBallastKey,
+				deployment.QueueSidecarGOGCKey,
+				deployment.QueueSidecarHeaderPolicyKey,
+				deployment.QueueSidecarImageKey,
+				deployment.QueueSidecarMemoryLimitKey,
+				deployment.ReleaseConcurrencyOnHeadersKey,
+				"registriesSkippingTagResolving",
+			),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := deployment.NewConfigFromConfigMap(cm)
+				return err
+			},
+		},
+		gc.ConfigName: {
+			keys: sets.NewString(
+				"orphan-sweep-mode",
+				"stale-revision-create-delay",
+				"stale-revision-lastpinned-debounce",
+				"stale-revision-minimum-generations",
+				"stale-revision-timeout",
+			),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := gcConfig(cm)
+				return err
+			},
+		},
+		istioconfig.IstioConfigName: {
+			prefixes: []string{istioconfig.GatewayKeyPrefix, istioconfig.LocalGatewayKeyPrefix},
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := istioconfig.NewIstioFromConfigMap(cm)
+				return err
+			},
+		},
+		network.ConfigName: {
+			keys: sets.NewString(
+				network.ActivatorColdStartTimeoutKey,
+				network.ActivatorMethodTimeoutsKey,
+				network.ActivatorShedCapacityPendingKey,
+				network.ActivatorTimeoutKey,
+				network.AutoTLSKey,
+				network.ClientCertTrustedProxiesKey,
+				network.ClusterLocalTLSKeyPrefix+network.TLSALPNProtocolsKey,
+				network.ClusterLocalTLSKeyPrefix+network.TLSCipherSuitesKey,
+				network.ClusterLocalTLSKeyPrefix+network.TLSMinProtocolVersionKey,
+				network.DefaultClusterIngressClassKey,
+				network.DomainTemplateKey,
+				network.ExternalTLSKeyPrefix+network.TLSALPNProtocolsKey,
+				network.ExternalTLSKeyPrefix+network.TLSCipherSuitesKey,
+				network.ExternalTLSKeyPrefix+network.TLSMinProtocolVersionKey,
+				network.HTTPProtocolKey,
+				network.IstioOutboundIPRangesKey,
+				network.PreferredIPFamilyKey,
+				network.TagTemplateKey,
+			),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := network.NewConfigFromConfigMap(cm)
+				return err
+			},
+		},
+		routeconfig.RolloutConfigName: {
+			keys: sets.NewString("allowed-gate-urls", "analysis-window", "gate-timeout", "metrics-url", "recheck-interval"),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := routeconfig.NewRolloutFromConfigMap(cm)
+				return err
+			},
+		},
+		tracingconfig.ConfigName: {
+			keys: sets.NewString("backend", "debug", "enable", "otlp-endpoint", "otlp-protocol", "sample-rate", "zipkin-endpoint"),
+			validate: func(cm *corev1.ConfigMap) error {
+				_, err := tracingconfig.NewTracingConfigFromConfigMap(cm)
+				return err
+			},
+		},
+	}
+}
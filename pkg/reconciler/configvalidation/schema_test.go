@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configvalidation
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/deployment"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	logtesting "knative.dev/pkg/logging/testing"
+
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestSchemasAcceptExamples(t *testing.T) {
+	schemas := newSchemas(logtesting.TestLogger(t), 0)
+	for name, s := range schemas {
+		t.Run(name, func(t *testing.T) {
+			var allowed []string
+			if name == deployment.ConfigName {
+				allowed = append(allowed, deployment.QueueSidecarImageKey)
+			}
+			_, example := ConfigMapsFromTestFile(t, name, allowed...)
+
+			if err := s.validate(example); err != nil {
+				t.Errorf("validate() = %v", err)
+			}
+			if unknown := s.unknownKeys(example); len(unknown) != 0 {
+				t.Errorf("unknownKeys() = %v, wanted none", unknown)
+			}
+		})
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	s := &schema{
+		keys:     sets.NewString("stable-window", "panic-window"),
+		prefixes: []string{"gateway."},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config-test",
+		},
+		Data: map[string]string{
+			"_example":                     "documentation",
+			"stable-window":                "60s",
+			"stable-windw":                 "60s",
+			"gateway.knative-ingress":      "istio-ingressgateway",
+			"local-gateway.cluster-local":  "cluster-local-gateway",
+			"panic-window":                 "6s",
+			"container-concurrency-target": "100",
+		},
+	}
+
+	want := []string{"container-concurrency-target", "local-gateway.cluster-local", "stable-windw"}
+	if got := s.unknownKeys(cm); !cmp.Equal(got, want) {
+		t.Errorf("unknownKeys() = %v, wanted %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventAggregationWindow is how long the events of an object with the same
+// type and reason are collapsed into one after the first was recorded.
+const eventAggregationWindow = time.Minute
+
+type eventKey struct {
+	uid       types.UID
+	eventtype string
+	reason    string
+}
+
+type aggregatedEvent struct {
+	object   runtime.Object
+	message  string
+	count    int
+	lastSeen time.Time
+}
+
+// aggregatingRecorder is a record.EventRecorder that keeps high frequency
+// events, e.g. repeated scale updates, from flooding etcd. The first event
+// of an object with a given type and reason is recorded right away. The
+// ones that follow within the window are counted, and once it ends only the
+// last of them is recorded, with the count and the time it was last seen.
+type aggregatingRecorder struct {
+	record.EventRecorder
+	window time.Duration
+
+	// Overridden in tests.
+	now       func() time.Time
+	afterFunc func(time.Duration, func()) *time.Timer
+
+	mux     sync.Mutex
+	pending map[eventKey]*aggregatedEvent
+}
+
+func newAggregatingRecorder(recorder record.EventRecorder, window time.Duration) *aggregatingRecorder {
+	return &aggregatingRecorder{
+		EventRecorder: recorder,
+		window:        window,
+		now:           time.Now,
+		afterFunc:     time.AfterFunc,
+		pending:       make(map[eventKey]*aggregatedEvent),
+	}
+}
+
+// Event implements record.EventRecorder.
+func (r *aggregatingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		r.EventRecorder.Event(object, eventtype, reason, message)
+		return
+	}
+	key := eventKey{uid: accessor.GetUID(), eventtype: eventtype, reason: reason}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if e, ok := r.pending[key]; ok {
+		e.object, e.message = object, message
+		e.count++
+		e.lastSeen = r.now()
+		return
+	}
+	r.pending[key] = &aggregatedEvent{object: object}
+	r.afterFunc(r.window, func() { r.flush(key) })
+	r.EventRecorder.Event(object, eventtype, reason, message)
+}
+
+// Eventf implements record.EventRecorder.
+func (r *aggregatingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *aggregatingRecorder) flush(key eventKey) {
+	r.mux.Lock()
+	e := r.pending[key]
+	delete(r.pending, key)
+	r.mux.Unlock()
+
+	if e == nil || e.count == 0 {
+		return
+	}
+	r.EventRecorder.PastEventf(e.object, metav1.NewTime(e.lastSeen), key.eventtype, key.reason,
+		"%s (%d similar events in the last %v)", e.message, e.count, r.window)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// pastEventRecorder is a record.FakeRecorder that also records past events,
+// with their timestamp.
+type pastEventRecorder struct {
+	*record.FakeRecorder
+	events []string
+}
+
+func (r *pastEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.events = append(r.events, fmt.Sprintf("%s %s %s", eventtype, reason, message))
+}
+
+func (r *pastEventRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.events = append(r.events, fmt.Sprintf("%s %s %s @%s", eventtype, reason,
+		fmt.Sprintf(messageFmt, args...), timestamp.UTC().Format(time.RFC3339)))
+}
+
+func TestAggregatingRecorder(t *testing.T) {
+	fake := &pastEventRecorder{FakeRecorder: &record.FakeRecorder{}}
+	r := newAggregatingRecorder(fake, time.Minute)
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	var flushes []func()
+	r.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		if d != time.Minute {
+			t.Errorf("Window = %v, want: %v", d, time.Minute)
+		}
+		flushes = append(flushes, f)
+		return nil
+	}
+
+	pa := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", UID: "a"}}
+	pb := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b", UID: "b"}}
+
+	r.Eventf(pa, corev1.EventTypeNormal, "Scaled", "Scaled to %d", 1)
+	now = now.Add(time.Second)
+	r.Eventf(pa, corev1.EventTypeNormal, "Scaled", "Scaled to %d", 2)
+	now = now.Add(time.Second)
+	r.Eventf(pa, corev1.EventTypeNormal, "Scaled", "Scaled to %d", 3)
+	// Other objects, types and reasons aren't aggregated with those.
+	r.Event(pb, corev1.EventTypeNormal, "Scaled", "Scaled to 1")
+	r.Event(pa, corev1.EventTypeWarning, "Scaled", "Failed to scale")
+	r.Event(pa, corev1.EventTypeNormal, "Created", "Created")
+
+	want := []string{
+		"Normal Scaled Scaled to 1",
+		"Normal Scaled Scaled to 1",
+		"Warning Scaled Failed to scale",
+		"Normal Created Created",
+	}
+	if diff := cmp.Diff(want, fake.events); diff != "" {
+		t.Errorf("Events before the window ended (-want, +got) = %s", diff)
+	}
+
+	fake.events = nil
+	for _, f := range flushes {
+		f()
+	}
+	want = []string{
+		"Normal Scaled Scaled to 3 (2 similar events in the last 1m0s) @2019-06-01T00:00:02Z",
+	}
+	if diff := cmp.Diff(want, fake.events); diff != "" {
+		t.Errorf("Events after the window ended (-want, +got) = %s", diff)
+	}
+
+	// A new window starts with the next event.
+	fake.events = nil
+	r.Event(pa, corev1.EventTypeNormal, "Scaled", "Scaled to 4")
+	want = []string{"Normal Scaled Scaled to 4"}
+	if diff := cmp.Diff(want, fake.events); diff != "" {
+		t.Errorf("Events of the next window (-want, +got) = %s", diff)
+	}
+}
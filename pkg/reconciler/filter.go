@@ -17,6 +17,8 @@ limitations under the License.
 package reconciler
 
 import (
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -72,6 +74,17 @@ func NameFilterFunc(name string) func(interface{}) bool {
 	}
 }
 
+// NamePrefixFilterFunc creates a FilterFunc only accepting objects whose name
+// starts with the given prefix.
+func NamePrefixFilterFunc(prefix string) func(interface{}) bool {
+	return func(obj interface{}) bool {
+		if mo, ok := obj.(metav1.Object); ok {
+			return strings.HasPrefix(mo.GetName(), prefix)
+		}
+		return false
+	}
+}
+
 // NamespaceFilterFunc creates a FilterFunc only accepting objects in the given namespace.
 func NamespaceFilterFunc(namespace string) func(interface{}) bool {
 	return func(obj interface{}) bool {
@@ -228,6 +228,36 @@ func TestNameFilterFunc(t *testing.T) {
 	}
 }
 
+func TestNamePrefixFilterFunc(t *testing.T) {
+	ti := []params{{
+		name: "name match",
+		in:   configWithName(nameToFilter),
+		want: true,
+	}, {
+		name: "prefix match",
+		in:   configWithName(nameToFilter + "-suffix"),
+		want: true,
+	}, {
+		name: "name mismatch",
+		in:   configWithName("bogus"),
+		want: false,
+	}, {
+		name: "non kubernetes object",
+		in:   struct{}{},
+		want: false,
+	}}
+
+	for _, test := range ti {
+		t.Run(test.name, func(t *testing.T) {
+			filter := NamePrefixFilterFunc(nameToFilter)
+			got := filter(test.in)
+			if got != test.want {
+				t.Errorf("NamePrefixFilterFunc() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
 func TestNamespaceFilterFunc(t *testing.T) {
 	ti := []params{{
 		name: "namespace match",
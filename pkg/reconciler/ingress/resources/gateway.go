@@ -100,6 +100,8 @@ func MakeServers(ci *v1alpha1.ClusterIngress, gatewayServiceNamespace string, or
 			}
 			credentialName = targetSecret(originSecret, ci)
 		}
+		// TODO: enforce ci.Spec.TLSPolicy once the vendored Istio API
+		// exposes the protocol versions and cipher suites of a server.
 		servers = append(servers, v1alpha3.Server{
 			Hosts: tls.Hosts,
 			Port: v1alpha3.Port{
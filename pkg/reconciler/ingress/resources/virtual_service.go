@@ -161,6 +161,9 @@ func makeVirtualServiceRoute(hosts []string, http *v1alpha1.HTTPIngressPath) *v1
 	var h *v1alpha3.Headers
 	// TODO(mattmoor): Switch to Headers when we can have a hard
 	// dependency on 1.1, but 1.0.x rejects the unknown fields.
+	// Until then the queue-proxy is the only one that strips the
+	// client certificate headers (network.ClientCertSubjectHeaderName
+	// and friends) sent by clients, they should also be removed here.
 	// if len(http.AppendHeaders) > 0 {
 	// 	h = &v1alpha3.Headers{
 	// 		Request: &v1alpha3.HeaderOperations{
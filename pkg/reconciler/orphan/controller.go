@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orphan
+
+import (
+	"context"
+
+	autoscalingv1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving"
+	servingv1alpha1 "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	kpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler"
+	certificateinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/certificate"
+	clusteringressinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/clusteringress"
+	sksinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/serverlessservice"
+	routeinformer "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/route"
+	"github.com/knative/serving/pkg/reconciler"
+	configns "github.com/knative/serving/pkg/reconciler/configuration/config"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+)
+
+const (
+	controllerAgentName = "orphan-controller"
+)
+
+// NewClusterIngressController initializes the controller sweeping the
+// ClusterIngresses of Routes that no longer exist.
+func NewClusterIngressController(
+	ctx context.Context,
+	cmw configmap.Watcher,
+) *controller.Impl {
+	ciInformer := clusteringressinformer.Get(ctx)
+	routeInformer := routeinformer.Get(ctx)
+
+	c := &Reconciler{
+		Base: reconciler.NewBase(ctx, controllerAgentName, cmw),
+		kind: "ClusterIngress",
+	}
+	c.resource = &clusterIngresses{
+		lister:      ciInformer.Lister(),
+		routeLister: routeInformer.Lister(),
+		client:      c.ServingClientSet,
+	}
+	return newController(ctx, c, "OrphanedClusterIngresses", ciInformer.Informer(),
+		reconciler.LabelExistsFilterFunc(serving.RouteLabelKey))
+}
+
+// NewCertificateController initializes the controller sweeping the
+// Certificates of Routes that no longer exist.
+func NewCertificateController(
+	ctx context.Context,
+	cmw configmap.Watcher,
+) *controller.Impl {
+	certInformer := certificateinformer.Get(ctx)
+	routeInformer := routeinformer.Get(ctx)
+
+	c := &Reconciler{
+		Base: reconciler.NewBase(ctx, controllerAgentName, cmw),
+		kind: "Certificate",
+	}
+	c.resource = &certificates{
+		lister:      certInformer.Lister(),
+		routeLister: routeInformer.Lister(),
+		client:      c.ServingClientSet,
+	}
+	return newController(ctx, c, "OrphanedCertificates", certInformer.Informer(),
+		controller.Filter(servingv1alpha1.SchemeGroupVersion.WithKind("Route")))
+}
+
+// NewServerlessServiceController initializes the controller sweeping the
+// ServerlessServices of PodAutoscalers that no longer exist.
+func NewServerlessServiceController(
+	ctx context.Context,
+	cmw configmap.Watcher,
+) *controller.Impl {
+	sksInformer := sksinformer.Get(ctx)
+	paInformer := kpainformer.Get(ctx)
+
+	c := &Reconciler{
+		Base: reconciler.NewBase(ctx, controllerAgentName, cmw),
+		kind: "ServerlessService",
+	}
+	c.resource = &serverlessServices{
+		lister:   sksInformer.Lister(),
+		paLister: paInformer.Lister(),
+		client:   c.ServingClientSet,
+	}
+	return newController(ctx, c, "OrphanedServerlessServices", sksInformer.Informer(),
+		controller.Filter(autoscalingv1alpha1.SchemeGroupVersion.WithKind("PodAutoscaler")))
+}
+
+// newController watches the resources swept by c. Orphans are found as the
+// informer lists the resources on startup and on every resync.
+func newController(ctx context.Context, c *Reconciler, name string, informer cache.SharedIndexInformer, filter func(interface{}) bool) *controller.Impl {
+	impl := controller.NewImpl(c, c.Logger, name)
+
+	c.Logger.Info("Setting up event handlers")
+	informer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: filter,
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+
+	c.Logger.Info("Setting up ConfigMap receivers")
+	configStore := configns.NewStore(c.Logger.Named("config-store"), controller.GetResyncPeriod(ctx))
+	configStore.WatchConfigs(c.ConfigMapWatcher)
+	c.configStore = configStore
+
+	return impl
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orphan
+
+import (
+	"context"
+
+	"github.com/knative/serving/pkg/gc"
+	"github.com/knative/serving/pkg/reconciler"
+	configns "github.com/knative/serving/pkg/reconciler/configuration/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/kmeta"
+	"knative.dev/pkg/logging"
+)
+
+// resource abstracts the kind of networking resource a Reconciler sweeps.
+type resource interface {
+	// get returns the resource with the given namespace and name.
+	get(namespace, name string) (kmeta.Accessor, error)
+	// ownerOf returns the owner of the resource, or nil when it
+	// isn't owned by one of our resources.
+	ownerOf(kmeta.Accessor) *owner
+	// delete deletes the given resource.
+	delete(kmeta.Accessor) error
+}
+
+// owner identifies the owner of a swept resource.
+type owner struct {
+	kind      string
+	namespace string
+	name      string
+	// uid is empty when the owner is only known by its name.
+	uid types.UID
+
+	// cached and live return the UID of the owner found in the
+	// informer's cache and in the API server respectively.
+	cached func() (types.UID, error)
+	live   func() (types.UID, error)
+}
+
+// exists returns whether the owner still exists. The informer's cache may
+// lag behind, so an owner missing from it is looked up again in the API
+// server before deciding its resource is orphaned.
+func (o *owner) exists() (bool, error) {
+	for _, get := range []func() (types.UID, error){o.cached, o.live} {
+		uid, err := get()
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if o.uid == "" || uid == o.uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reconciler implements controller.Reconciler for networking resources,
+// reporting or deleting the ones whose owner no longer exists.
+type Reconciler struct {
+	*reconciler.Base
+
+	kind        string
+	resource    resource
+	configStore reconciler.ConfigStore
+}
+
+// Check that our Reconciler implements controller.Reconciler
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile checks whether the owner of the resource with the given key
+// still exists, and reports or deletes the resource if it doesn't.
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		c.Logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+	ctx = c.configStore.ToContext(ctx)
+
+	obj, err := c.resource.get(namespace, name)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	o := c.resource.ownerOf(obj)
+	if o == nil {
+		return nil
+	}
+	if exists, err := o.exists(); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	switch configns.FromContext(ctx).RevisionGC.OrphanSweepMode {
+	case gc.OrphanSweepDelete:
+		logger.Infof("Deleting %s %q, as its %s %s/%s no longer exists", c.kind, key, o.kind, o.namespace, o.name)
+		if err := c.resource.delete(obj); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	default:
+		logger.Warnf("%s %q is orphaned, as its %s %s/%s no longer exists", c.kind, key, o.kind, o.namespace, o.name)
+		c.Recorder.Eventf(obj, corev1.EventTypeWarning, "Orphaned",
+			"%s %s/%s that owns this %s no longer exists", o.kind, o.namespace, o.name, c.kind)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orphan
+
+import (
+	"context"
+	"testing"
+
+	// Install our fake informers
+	_ "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler/fake"
+	_ "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/certificate/fake"
+	_ "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/clusteringress/fake"
+	_ "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/serverlessservice/fake"
+	_ "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/route/fake"
+
+	autoscalingv1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	netv1alpha1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/gc"
+	"github.com/knative/serving/pkg/reconciler"
+	"github.com/knative/serving/pkg/reconciler/configuration/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/kmeta"
+	logtesting "knative.dev/pkg/logging/testing"
+
+	. "github.com/knative/serving/pkg/reconciler/testing/v1alpha1"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestClusterIngresses(t *testing.T) {
+	defer logtesting.ClearAll()
+	newResource := func(listers *Listers, c *Reconciler) resource {
+		return &clusterIngresses{
+			lister:      listers.GetClusterIngressLister(),
+			routeLister: listers.GetRouteLister(),
+			client:      c.ServingClientSet,
+		}
+	}
+
+	TableTest{{
+		Name: "bad workqueue key",
+		Key:  "too/many/parts",
+	}, {
+		Name: "key not found",
+		Key:  "ingress",
+	}, {
+		Name: "route exists",
+		Objects: []runtime.Object{
+			route("owner", "owner-uid"),
+			clusterIngress("ingress", "owner"),
+		},
+		Key: "ingress",
+	}, {
+		Name: "not created for a route",
+		Objects: []runtime.Object{
+			clusterIngress("ingress", ""),
+		},
+		Key: "ingress",
+	}, {
+		Name: "route missing from the cache",
+		Objects: []runtime.Object{
+			clusterIngress("ingress", "owner"),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			getReturns("routes", route("owner", "owner-uid")),
+		},
+		Key: "ingress",
+	}, {
+		Name: "route deleted",
+		Objects: []runtime.Object{
+			clusterIngress("ingress", "owner"),
+		},
+		Key: "ingress",
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "Orphaned", "Route foo/owner that owns this ClusterIngress no longer exists"),
+		},
+	}}.Test(t, makeFactory("ClusterIngress", gc.OrphanSweepReport, newResource))
+
+	TableTest{{
+		Name: "route exists",
+		Objects: []runtime.Object{
+			route("owner", "owner-uid"),
+			clusterIngress("ingress", "owner"),
+		},
+		Key: "ingress",
+	}, {
+		Name: "route deleted",
+		Objects: []runtime.Object{
+			clusterIngress("ingress", "owner"),
+		},
+		Key: "ingress",
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			Name: "ingress",
+		}},
+	}, {
+		Name: "being deleted",
+		Objects: []runtime.Object{
+			clusterIngress("ingress", "owner", func(ci *netv1alpha1.ClusterIngress) {
+				ci.DeletionTimestamp = &metav1.Time{}
+			}),
+		},
+		Key: "ingress",
+	}}.Test(t, makeFactory("ClusterIngress", gc.OrphanSweepDelete, newResource))
+}
+
+func TestCertificates(t *testing.T) {
+	defer logtesting.ClearAll()
+	newResource := func(listers *Listers, c *Reconciler) resource {
+		return &certificates{
+			lister:      listers.GetCertificateLister(),
+			routeLister: listers.GetRouteLister(),
+			client:      c.ServingClientSet,
+		}
+	}
+
+	TableTest{{
+		Name: "route exists",
+		Objects: []runtime.Object{
+			route("owner", "owner-uid"),
+			certificate("cert", route("owner", "owner-uid")),
+		},
+		Key: "foo/cert",
+	}, {
+		Name: "not owned by a route",
+		Objects: []runtime.Object{
+			certificate("cert", nil),
+		},
+		Key: "foo/cert",
+	}, {
+		Name: "route recreated",
+		Objects: []runtime.Object{
+			route("owner", "new-uid"),
+			certificate("cert", route("owner", "owner-uid")),
+		},
+		Key: "foo/cert",
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "Orphaned", "Route foo/owner that owns this Certificate no longer exists"),
+		},
+	}}.Test(t, makeFactory("Certificate", gc.OrphanSweepReport, newResource))
+
+	TableTest{{
+		Name: "route deleted",
+		Objects: []runtime.Object{
+			certificate("cert", route("owner", "owner-uid")),
+		},
+		Key: "foo/cert",
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+			},
+			Name: "cert",
+		}},
+	}, {
+		Name: "failure deleting",
+		Objects: []runtime.Object{
+			certificate("cert", route("owner", "owner-uid")),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("delete", "certificates"),
+		},
+		Key:     "foo/cert",
+		WantErr: true,
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+			},
+			Name: "cert",
+		}},
+	}}.Test(t, makeFactory("Certificate", gc.OrphanSweepDelete, newResource))
+}
+
+func TestServerlessServices(t *testing.T) {
+	defer logtesting.ClearAll()
+	newResource := func(listers *Listers, c *Reconciler) resource {
+		return &serverlessServices{
+			lister:   listers.GetServerlessServiceLister(),
+			paLister: listers.GetPodAutoscalerLister(),
+			client:   c.ServingClientSet,
+		}
+	}
+
+	TableTest{{
+		Name: "pa exists",
+		Objects: []runtime.Object{
+			pa("owner", "owner-uid"),
+			sks("sks", pa("owner", "owner-uid")),
+		},
+		Key: "foo/sks",
+	}, {
+		Name: "failure getting pa",
+		Objects: []runtime.Object{
+			sks("sks", pa("owner", "owner-uid")),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("get", "podautoscalers"),
+		},
+		Key:     "foo/sks",
+		WantErr: true,
+	}, {
+		Name: "pa deleted",
+		Objects: []runtime.Object{
+			sks("sks", pa("owner", "owner-uid")),
+		},
+		Key: "foo/sks",
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "Orphaned", "PodAutoscaler foo/owner that owns this ServerlessService no longer exists"),
+		},
+	}}.Test(t, makeFactory("ServerlessService", gc.OrphanSweepReport, newResource))
+
+	TableTest{{
+		Name: "pa deleted",
+		Objects: []runtime.Object{
+			sks("sks", pa("owner", "owner-uid")),
+		},
+		Key: "foo/sks",
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+			},
+			Name: "sks",
+		}},
+	}}.Test(t, makeFactory("ServerlessService", gc.OrphanSweepDelete, newResource))
+}
+
+func makeFactory(kind string, mode gc.OrphanSweepMode, newResource func(*Listers, *Reconciler) resource) Factory {
+	return MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
+		c := &Reconciler{
+			Base: reconciler.NewBase(ctx, controllerAgentName, cmw),
+			kind: kind,
+			configStore: &testConfigStore{
+				config: &config.Config{
+					RevisionGC: &gc.Config{OrphanSweepMode: mode},
+				},
+			},
+		}
+		c.resource = newResource(listers, c)
+		return c
+	})
+}
+
+type testConfigStore struct {
+	config *config.Config
+}
+
+func (t *testConfigStore) ToContext(ctx context.Context) context.Context {
+	return config.ToContext(ctx, t.config)
+}
+
+var _ reconciler.ConfigStore = (*testConfigStore)(nil)
+
+// getReturns makes the API server return obj for any get of resource.
+func getReturns(resource string, obj runtime.Object) clientgotesting.ReactionFunc {
+	return func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		if !action.Matches("get", resource) {
+			return false, nil, nil
+		}
+		return true, obj, nil
+	}
+}
+
+func route(name string, uid types.UID) *v1alpha1.Route {
+	return &v1alpha1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "foo",
+			UID:       uid,
+		},
+	}
+}
+
+func pa(name string, uid types.UID) *autoscalingv1alpha1.PodAutoscaler {
+	return &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "foo",
+			UID:       uid,
+		},
+	}
+}
+
+func clusterIngress(name, routeName string, opts ...func(*netv1alpha1.ClusterIngress)) *netv1alpha1.ClusterIngress {
+	ci := &netv1alpha1.ClusterIngress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			UID:  "ingress-uid",
+		},
+	}
+	if routeName != "" {
+		ci.Labels = map[string]string{
+			serving.RouteLabelKey:          routeName,
+			serving.RouteNamespaceLabelKey: "foo",
+		}
+	}
+	for _, opt := range opts {
+		opt(ci)
+	}
+	return ci
+}
+
+func certificate(name string, owner kmeta.OwnerRefable) *netv1alpha1.Certificate {
+	cert := &netv1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "foo",
+			UID:       "cert-uid",
+		},
+	}
+	if owner != nil {
+		cert.OwnerReferences = []metav1.OwnerReference{*kmeta.NewControllerRef(owner)}
+	}
+	return cert
+}
+
+func sks(name string, owner kmeta.OwnerRefable) *netv1alpha1.ServerlessService {
+	sks := &netv1alpha1.ServerlessService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "foo",
+			UID:       "sks-uid",
+		},
+	}
+	sks.OwnerReferences = []metav1.OwnerReference{*kmeta.NewControllerRef(owner)}
+	return sks
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orphan
+
+import (
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
+	clientset "github.com/knative/serving/pkg/client/clientset/versioned"
+	kpalisters "github.com/knative/serving/pkg/client/listers/autoscaling/v1alpha1"
+	networkinglisters "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
+	listers "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/kmeta"
+)
+
+// clusterIngresses sweeps the ClusterIngresses of deleted Routes. Being
+// cluster-scoped, they reference their Route through labels.
+type clusterIngresses struct {
+	lister      networkinglisters.ClusterIngressLister
+	routeLister listers.RouteLister
+	client      clientset.Interface
+}
+
+func (r *clusterIngresses) get(_, name string) (kmeta.Accessor, error) {
+	ci, err := r.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+func (r *clusterIngresses) ownerOf(obj kmeta.Accessor) *owner {
+	labels := obj.GetLabels()
+	name, namespace := labels[serving.RouteLabelKey], labels[serving.RouteNamespaceLabelKey]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return routeOwner(r.routeLister, r.client, namespace, name, "")
+}
+
+func (r *clusterIngresses) delete(obj kmeta.Accessor) error {
+	return r.client.NetworkingV1alpha1().ClusterIngresses().Delete(obj.GetName(), deleteOptions(obj))
+}
+
+// certificates sweeps the Certificates of deleted Routes.
+type certificates struct {
+	lister      networkinglisters.CertificateLister
+	routeLister listers.RouteLister
+	client      clientset.Interface
+}
+
+func (r *certificates) get(namespace, name string) (kmeta.Accessor, error) {
+	cert, err := r.lister.Certificates(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func (r *certificates) ownerOf(obj kmeta.Accessor) *owner {
+	ref := controllerOf(obj, serving.GroupName, "Route")
+	if ref == nil {
+		return nil
+	}
+	return routeOwner(r.routeLister, r.client, obj.GetNamespace(), ref.Name, ref.UID)
+}
+
+func (r *certificates) delete(obj kmeta.Accessor) error {
+	return r.client.NetworkingV1alpha1().Certificates(obj.GetNamespace()).Delete(obj.GetName(), deleteOptions(obj))
+}
+
+// serverlessServices sweeps the ServerlessServices of deleted PodAutoscalers.
+type serverlessServices struct {
+	lister   networkinglisters.ServerlessServiceLister
+	paLister kpalisters.PodAutoscalerLister
+	client   clientset.Interface
+}
+
+func (r *serverlessServices) get(namespace, name string) (kmeta.Accessor, error) {
+	sks, err := r.lister.ServerlessServices(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return sks, nil
+}
+
+func (r *serverlessServices) ownerOf(obj kmeta.Accessor) *owner {
+	ref := controllerOf(obj, autoscaling.InternalGroupName, "PodAutoscaler")
+	if ref == nil {
+		return nil
+	}
+	return paOwner(r.paLister, r.client, obj.GetNamespace(), ref.Name, ref.UID)
+}
+
+func (r *serverlessServices) delete(obj kmeta.Accessor) error {
+	return r.client.NetworkingV1alpha1().ServerlessServices(obj.GetNamespace()).Delete(obj.GetName(), deleteOptions(obj))
+}
+
+func routeOwner(lister listers.RouteLister, client clientset.Interface, namespace, name string, uid types.UID) *owner {
+	return &owner{
+		kind:      "Route",
+		namespace: namespace,
+		name:      name,
+		uid:       uid,
+		cached: func() (types.UID, error) {
+			return uidOf(lister.Routes(namespace).Get(name))
+		},
+		live: func() (types.UID, error) {
+			return uidOf(client.ServingV1alpha1().Routes(namespace).Get(name, metav1.GetOptions{}))
+		},
+	}
+}
+
+func paOwner(lister kpalisters.PodAutoscalerLister, client clientset.Interface, namespace, name string, uid types.UID) *owner {
+	return &owner{
+		kind:      "PodAutoscaler",
+		namespace: namespace,
+		name:      name,
+		uid:       uid,
+		cached: func() (types.UID, error) {
+			return uidOf(lister.PodAutoscalers(namespace).Get(name))
+		},
+		live: func() (types.UID, error) {
+			return uidOf(client.AutoscalingV1alpha1().PodAutoscalers(namespace).Get(name, metav1.GetOptions{}))
+		},
+	}
+}
+
+func uidOf(obj metav1.Object, err error) (types.UID, error) {
+	if err != nil {
+		return "", err
+	}
+	return obj.GetUID(), nil
+}
+
+// controllerOf returns the controller reference of obj, if it is a
+// resource of the given group and kind.
+func controllerOf(obj kmeta.Accessor, group, kind string) *metav1.OwnerReference {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		if gv, err := schema.ParseGroupVersion(ref.APIVersion); err != nil || gv.Group != group || ref.Kind != kind {
+			return nil
+		}
+		return &ref
+	}
+	return nil
+}
+
+// deleteOptions makes sure that only the very resource that was found to be
+// orphaned is deleted, and not one that was recreated in the meantime.
+func deleteOptions(obj kmeta.Accessor) *metav1.DeleteOptions {
+	return &metav1.DeleteOptions{
+		Preconditions: metav1.NewUIDPreconditions(string(obj.GetUID())),
+	}
+}
@@ -115,8 +115,8 @@ func NewBase(ctx context.Context, controllerAgentName string, cmw configmap.Watc
 			eventBroadcaster.StartRecordingToSink(
 				&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")}),
 		}
-		recorder = eventBroadcaster.NewRecorder(
-			scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+		recorder = newAggregatingRecorder(eventBroadcaster.NewRecorder(
+			scheme.Scheme, corev1.EventSource{Component: controllerAgentName}), eventAggregationWindow)
 		go func() {
 			<-ctx.Done()
 			for _, w := range watches {
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	serviceinformer "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/service"
+	"github.com/knative/serving/pkg/reconciler"
+	"github.com/knative/serving/pkg/reconciler/report/resources"
+	"go.uber.org/zap"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	deploymentinformer "knative.dev/pkg/injection/informers/kubeinformers/appsv1/deployment"
+	configmapinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/configmap"
+	"knative.dev/pkg/kmeta"
+)
+
+const (
+	controllerAgentName = "report-controller"
+)
+
+// NewController initializes the controller keeping the report of every
+// namespace with Services up to date.
+func NewController(
+	ctx context.Context,
+	cmw configmap.Watcher,
+) *controller.Impl {
+	serviceInformer := serviceinformer.Get(ctx)
+	deploymentInformer := deploymentinformer.Get(ctx)
+	configMapInformer := configmapinformer.Get(ctx)
+
+	c := &Reconciler{
+		Base:             reconciler.NewBase(ctx, controllerAgentName, cmw),
+		serviceLister:    serviceInformer.Lister(),
+		deploymentLister: deploymentInformer.Lister(),
+		configMapLister:  configMapInformer.Lister(),
+	}
+	impl := controller.NewImpl(c, c.Logger, "ServingReports")
+
+	// Any change of a Service, or of the scale of one of its Revisions,
+	// updates the report of its namespace.
+	enqueueNamespace := func(obj interface{}) {
+		object, err := kmeta.DeletionHandlingAccessor(obj)
+		if err != nil {
+			c.Logger.Errorw("Failed to get the object of the event", zap.Error(err))
+			return
+		}
+		impl.EnqueueKey(object.GetNamespace() + "/" + resources.ConfigMapName)
+	}
+
+	c.Logger.Info("Setting up event handlers")
+	serviceInformer.Informer().AddEventHandler(controller.HandleAll(enqueueNamespace))
+	deploymentInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: reconciler.LabelExistsFilterFunc(serving.ServiceLabelKey),
+		Handler:    controller.HandleAll(enqueueNamespace),
+	})
+	configMapInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: reconciler.LabelExistsFilterFunc(serving.ReportLabelKey),
+		Handler:    controller.HandleAll(enqueueNamespace),
+	})
+
+	return impl
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	listers "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler"
+	"github.com/knative/serving/pkg/reconciler/report/resources"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// Reconciler implements controller.Reconciler for the reports of the
+// namespaces.
+type Reconciler struct {
+	*reconciler.Base
+
+	serviceLister    listers.ServiceLister
+	deploymentLister appsv1listers.DeploymentLister
+	configMapLister  corev1listers.ConfigMapLister
+}
+
+// Check that our Reconciler implements controller.Reconciler
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile writes the report of the Services of the namespace of the
+// given key, and deletes it once the namespace has none left.
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		c.Logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+
+	existing, err := c.configMapLister.ConfigMaps(namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err
+	} else if existing.Labels[serving.ReportLabelKey] != "true" {
+		return fmt.Errorf("ConfigMap %q is not a serving report, not overwriting it", key)
+	}
+
+	services, err := c.serviceLister.Services(namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		if existing == nil {
+			return nil
+		}
+		logger.Infof("Deleting report %q, as the namespace has no Services left", key)
+		err := c.KubeClientSet.CoreV1().ConfigMaps(namespace).Delete(name, &metav1.DeleteOptions{})
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	desired, err := resources.MakeConfigMap(namespace, resources.MakeReport(services, func(revisionName string) int32 {
+		d, err := c.deploymentLister.Deployments(namespace).Get(names.Deployment(&v1alpha1.Revision{ObjectMeta: metav1.ObjectMeta{Name: revisionName}}))
+		if err != nil {
+			// The Revision isn't scaled yet, or anymore.
+			return 0
+		}
+		return d.Status.ReadyReplicas
+	}))
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		_, err := c.KubeClientSet.CoreV1().ConfigMaps(namespace).Create(desired)
+		return err
+	}
+	if equality.Semantic.DeepEqual(existing.Data, desired.Data) {
+		return nil
+	}
+	// Don't modify the informers copy.
+	cm := existing.DeepCopy()
+	cm.Data = desired.Data
+	_, err = c.KubeClientSet.CoreV1().ConfigMaps(namespace).Update(cm)
+	return err
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"testing"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	"github.com/knative/serving/pkg/reconciler"
+	"github.com/knative/serving/pkg/reconciler/report/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/ptr"
+
+	. "github.com/knative/serving/pkg/reconciler/testing/v1alpha1"
+	. "github.com/knative/serving/pkg/testing/v1alpha1"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+var reportKey = "foo/" + resources.ConfigMapName
+
+func TestReconcile(t *testing.T) {
+	defer logtesting.ClearAll()
+
+	readyReport := resources.ServiceReport{
+		Name:                      "ready",
+		Ready:                     corev1.ConditionTrue,
+		URL:                       "http://ready.foo.example.com",
+		LatestReadyRevisionName:   "ready-00001",
+		LatestCreatedRevisionName: "ready-00001",
+		Traffic: []resources.TrafficReport{{
+			RevisionName:  "ready-00001",
+			Percent:       100,
+			ReadyReplicas: 2,
+		}},
+	}
+	failedReport := resources.ServiceReport{
+		Name:                      "failed",
+		Ready:                     corev1.ConditionFalse,
+		LatestCreatedRevisionName: "failed-00001",
+		Errors: []string{
+			`ConfigurationsReady: RevisionFailed: Revision "failed-00001" failed with message: Unable to fetch image.`,
+		},
+	}
+
+	TableTest{{
+		Name: "bad workqueue key",
+		Key:  "too/many/parts",
+	}, {
+		Name: "no services",
+		Key:  reportKey,
+	}, {
+		Name: "no services left",
+		Objects: []runtime.Object{
+			report(t, readyReport),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+				Verb:      "delete",
+				Resource:  corev1.SchemeGroupVersion.WithResource("configmaps"),
+			},
+			Name: resources.ConfigMapName,
+		}},
+		Key: reportKey,
+	}, {
+		Name: "create report",
+		Objects: []runtime.Object{
+			readyService(),
+			deployment("ready-00001", 2),
+			failedService(),
+		},
+		WantCreates: []runtime.Object{
+			report(t, failedReport, readyReport),
+		},
+		Key: reportKey,
+	}, {
+		Name: "update report",
+		Objects: []runtime.Object{
+			readyService(),
+			deployment("ready-00001", 2),
+			failedService(),
+			report(t, readyReport),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: report(t, failedReport, readyReport),
+		}},
+		Key: reportKey,
+	}, {
+		Name: "report up to date",
+		Objects: []runtime.Object{
+			readyService(),
+			deployment("ready-00001", 2),
+			report(t, readyReport),
+		},
+		Key: reportKey,
+	}, {
+		Name: "revision not scaled",
+		Objects: []runtime.Object{
+			readyService(),
+			report(t, readyReport),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: report(t, func() resources.ServiceReport {
+				sr := readyReport
+				sr.Traffic = []resources.TrafficReport{{
+					RevisionName: "ready-00001",
+					Percent:      100,
+				}}
+				return sr
+			}()),
+		}},
+		Key: reportKey,
+	}, {
+		Name:    "configmap is not a report",
+		WantErr: true,
+		Objects: []runtime.Object{
+			readyService(),
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resources.ConfigMapName,
+					Namespace: "foo",
+				},
+			},
+		},
+		Key: reportKey,
+	}}.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
+		return &Reconciler{
+			Base:             reconciler.NewBase(ctx, controllerAgentName, cmw),
+			serviceLister:    listers.GetServiceLister(),
+			deploymentLister: listers.GetDeploymentLister(),
+			configMapLister:  listers.GetConfigMapLister(),
+		}
+	}))
+}
+
+func readyService() *v1alpha1.Service {
+	return Service("ready", "foo", WithRunLatestRollout, WithInitSvcConditions,
+		WithReadyRoute, WithReadyConfig("ready-00001"), WithSvcStatusDomain,
+		WithSvcStatusTraffic(v1alpha1.TrafficTarget{
+			TrafficTarget: v1beta1.TrafficTarget{
+				RevisionName: "ready-00001",
+				Percent:      100,
+			},
+		}))
+}
+
+func failedService() *v1alpha1.Service {
+	return Service("failed", "foo", WithRunLatestRollout, WithInitSvcConditions,
+		WithFailedConfig("failed-00001", "RevisionFailed", "Unable to fetch image"))
+}
+
+func deployment(revisionName string, readyReplicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      revisionName + "-deployment",
+			Namespace: "foo",
+			Labels: map[string]string{
+				serving.RevisionLabelKey: revisionName,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.Int32(readyReplicas),
+		},
+		Status: appsv1.DeploymentStatus{
+			ReadyReplicas: readyReplicas,
+		},
+	}
+}
+
+func report(t *testing.T, services ...resources.ServiceReport) *corev1.ConfigMap {
+	cm, err := resources.MakeConfigMap("foo", &resources.Report{Services: services})
+	if err != nil {
+		t.Fatalf("MakeConfigMap() = %v", err)
+	}
+	return cm
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap holding the report of a
+	// namespace.
+	ConfigMapName = "serving-report"
+
+	// ReportKey is the key of the report in the data of the ConfigMap.
+	ReportKey = "report.json"
+)
+
+// Report summarizes the Services of a namespace.
+type Report struct {
+	Services []ServiceReport `json:"services"`
+}
+
+// ServiceReport summarizes the state of a Service.
+type ServiceReport struct {
+	Name  string                 `json:"name"`
+	Ready corev1.ConditionStatus `json:"ready"`
+	URL   string                 `json:"url,omitempty"`
+
+	LatestReadyRevisionName   string `json:"latestReadyRevisionName,omitempty"`
+	LatestCreatedRevisionName string `json:"latestCreatedRevisionName,omitempty"`
+
+	Traffic []TrafficReport `json:"traffic,omitempty"`
+
+	// Errors are the failing conditions of the Service, which carry the
+	// errors of its Route, Configuration and latest Revision.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// TrafficReport summarizes a traffic target of a Service, along with the
+// scale of its Revision.
+type TrafficReport struct {
+	RevisionName string `json:"revisionName"`
+	Tag          string `json:"tag,omitempty"`
+	Percent      int    `json:"percent"`
+	// ReadyReplicas is the number of ready pods of the Revision.
+	ReadyReplicas int32 `json:"readyReplicas"`
+}
+
+// MakeReport summarizes the given Services, in the order of their names.
+// readyReplicas returns the number of ready pods of a Revision.
+func MakeReport(services []*v1alpha1.Service, readyReplicas func(revisionName string) int32) *Report {
+	report := &Report{Services: make([]ServiceReport, 0, len(services))}
+	for _, svc := range services {
+		sr := ServiceReport{
+			Name:                      svc.Name,
+			Ready:                     corev1.ConditionUnknown,
+			LatestReadyRevisionName:   svc.Status.LatestReadyRevisionName,
+			LatestCreatedRevisionName: svc.Status.LatestCreatedRevisionName,
+		}
+		if svc.Status.URL != nil {
+			sr.URL = svc.Status.URL.String()
+		}
+		for _, cond := range svc.Status.Conditions {
+			if cond.Type == v1alpha1.ServiceConditionReady {
+				sr.Ready = cond.Status
+			} else if cond.IsFalse() {
+				sr.Errors = append(sr.Errors, conditionError(cond))
+			}
+		}
+		for _, tt := range svc.Status.Traffic {
+			sr.Traffic = append(sr.Traffic, TrafficReport{
+				RevisionName:  tt.RevisionName,
+				Tag:           tt.Tag,
+				Percent:       tt.Percent,
+				ReadyReplicas: readyReplicas(tt.RevisionName),
+			})
+		}
+		report.Services = append(report.Services, sr)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Name < report.Services[j].Name
+	})
+	return report
+}
+
+func conditionError(cond apis.Condition) string {
+	msg := string(cond.Type) + ": " + cond.Reason
+	if cond.Message != "" {
+		msg += ": " + cond.Message
+	}
+	return msg
+}
+
+// MakeConfigMap creates the ConfigMap holding the report of a namespace.
+func MakeConfigMap(namespace string, report *Report) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				serving.ReportLabelKey: "true",
+			},
+		},
+		Data: map[string]string{
+			ReportKey: string(data),
+		},
+	}, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+func TestMakeReport(t *testing.T) {
+	services := []*v1alpha1.Service{{
+		ObjectMeta: metav1.ObjectMeta{Name: "zeta"},
+		Status: v1alpha1.ServiceStatus{
+			Status: duckv1beta1.Status{
+				Conditions: duckv1beta1.Conditions{{
+					Type:   v1alpha1.ServiceConditionReady,
+					Status: corev1.ConditionFalse,
+					Reason: "Failed",
+				}, {
+					Type:    v1alpha1.ServiceConditionRoutesReady,
+					Status:  corev1.ConditionFalse,
+					Reason:  "Failed",
+					Message: "Something went wrong",
+				}, {
+					Type:   v1alpha1.ServiceConditionConfigurationsReady,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+			RouteStatusFields: v1alpha1.RouteStatusFields{
+				URL: &apis.URL{Scheme: "http", Host: "zeta.example.com"},
+				Traffic: []v1alpha1.TrafficTarget{{
+					TrafficTarget: v1beta1.TrafficTarget{
+						RevisionName: "zeta-00002",
+						Tag:          "candidate",
+						Percent:      10,
+					},
+				}, {
+					TrafficTarget: v1beta1.TrafficTarget{
+						RevisionName: "zeta-00001",
+						Percent:      90,
+					},
+				}},
+			},
+		},
+	}, {
+		// Not reconciled yet.
+		ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+	}}
+	replicas := map[string]int32{
+		"zeta-00001": 3,
+		"zeta-00002": 1,
+	}
+
+	got := MakeReport(services, func(revisionName string) int32 {
+		return replicas[revisionName]
+	})
+	want := &Report{
+		Services: []ServiceReport{{
+			Name:  "alpha",
+			Ready: corev1.ConditionUnknown,
+		}, {
+			Name:  "zeta",
+			Ready: corev1.ConditionFalse,
+			URL:   "http://zeta.example.com",
+			Traffic: []TrafficReport{{
+				RevisionName:  "zeta-00002",
+				Tag:           "candidate",
+				Percent:       10,
+				ReadyReplicas: 1,
+			}, {
+				RevisionName:  "zeta-00001",
+				Percent:       90,
+				ReadyReplicas: 3,
+			}},
+			Errors: []string{"RoutesReady: Failed: Something went wrong"},
+		}},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("MakeReport (-want, +got) = %s", cmp.Diff(want, got))
+	}
+}
+
+func TestMakeConfigMap(t *testing.T) {
+	cm, err := MakeConfigMap("foo", &Report{Services: []ServiceReport{{
+		Name:  "svc",
+		Ready: corev1.ConditionTrue,
+	}}})
+	if err != nil {
+		t.Fatalf("MakeConfigMap() = %v", err)
+	}
+	if got, want := cm.Name, ConfigMapName; got != want {
+		t.Errorf("Name = %q, want: %q", got, want)
+	}
+	if got, want := cm.Data[ReportKey], `{"services":[{"name":"svc","ready":"True"}]}`; got != want {
+		t.Errorf("Data[%q] = %s, want: %s", ReportKey, got, want)
+	}
+}
@@ -22,11 +22,13 @@ import (
 	"knative.dev/pkg/configmap"
 	pkglogging "knative.dev/pkg/logging"
 	pkgmetrics "knative.dev/pkg/metrics"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/autoscaler"
 	deployment "github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/logging"
 	"github.com/knative/serving/pkg/metrics"
 	"github.com/knative/serving/pkg/network"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 )
 
 type cfgKey struct{}
@@ -38,6 +40,8 @@ type Config struct {
 	Observability *metrics.ObservabilityConfig
 	Logging       *pkglogging.Config
 	Autoscaler    *autoscaler.Config
+	Defaults      *apiconfig.Defaults
+	Tracing       *tracingconfig.Config
 }
 
 func FromContext(ctx context.Context) *Config {
@@ -60,11 +64,13 @@ func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value i
 			"revision",
 			logger,
 			configmap.Constructors{
-				deployment.ConfigName:      deployment.NewConfigFromConfigMap,
-				network.ConfigName:         network.NewConfigFromConfigMap,
-				pkgmetrics.ConfigMapName(): metrics.NewObservabilityConfigFromConfigMap,
-				autoscaler.ConfigName:      autoscaler.NewConfigFromConfigMap,
-				pkglogging.ConfigMapName(): logging.NewConfigFromConfigMap,
+				deployment.ConfigName:        deployment.NewConfigFromConfigMap,
+				network.ConfigName:           network.NewConfigFromConfigMap,
+				pkgmetrics.ConfigMapName():   metrics.NewObservabilityConfigFromConfigMap,
+				autoscaler.ConfigName:        autoscaler.NewConfigFromConfigMap,
+				pkglogging.ConfigMapName():   logging.NewConfigFromConfigMap,
+				apiconfig.DefaultsConfigName: apiconfig.NewDefaultsConfigFromConfigMap,
+				tracingconfig.ConfigName:     tracingconfig.NewTracingConfigFromConfigMap,
 			},
 			onAfterStore...,
 		),
@@ -84,5 +90,7 @@ func (s *Store) Load() *Config {
 		Observability: s.UntypedLoad(pkgmetrics.ConfigMapName()).(*metrics.ObservabilityConfig).DeepCopy(),
 		Logging:       s.UntypedLoad((pkglogging.ConfigMapName())).(*pkglogging.Config).DeepCopy(),
 		Autoscaler:    s.UntypedLoad(autoscaler.ConfigName).(*autoscaler.Config).DeepCopy(),
+		Defaults:      s.UntypedLoad(apiconfig.DefaultsConfigName).(*apiconfig.Defaults).DeepCopy(),
+		Tracing:       s.UntypedLoad(tracingconfig.ConfigName).(*tracingconfig.Config).DeepCopy(),
 	}
 }
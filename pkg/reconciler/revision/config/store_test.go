@@ -26,11 +26,13 @@ import (
 	pkglogging "knative.dev/pkg/logging"
 	logtesting "knative.dev/pkg/logging/testing"
 	pkgmetrics "knative.dev/pkg/metrics"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/autoscaler"
 	deployment "github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/logging"
 	"github.com/knative/serving/pkg/metrics"
 	"github.com/knative/serving/pkg/network"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 
 	. "knative.dev/pkg/configmap/testing"
 )
@@ -44,12 +46,16 @@ func TestStoreLoadWithContext(t *testing.T) {
 	observabilityConfig := ConfigMapFromTestFile(t, pkgmetrics.ConfigMapName())
 	loggingConfig := ConfigMapFromTestFile(t, pkglogging.ConfigMapName())
 	autoscalerConfig := ConfigMapFromTestFile(t, autoscaler.ConfigName)
+	defaultsConfig := ConfigMapFromTestFile(t, apiconfig.DefaultsConfigName)
+	tracingConfig := ConfigMapFromTestFile(t, tracingconfig.ConfigName)
 
 	store.OnConfigChanged(deploymentConfig)
 	store.OnConfigChanged(networkConfig)
 	store.OnConfigChanged(observabilityConfig)
 	store.OnConfigChanged(loggingConfig)
 	store.OnConfigChanged(autoscalerConfig)
+	store.OnConfigChanged(defaultsConfig)
+	store.OnConfigChanged(tracingConfig)
 
 	config := FromContext(store.ToContext(context.Background()))
 
@@ -89,6 +95,20 @@ func TestStoreLoadWithContext(t *testing.T) {
 			t.Errorf("Unexpected autoscaler config (-want, +got): %v", diff)
 		}
 	})
+
+	t.Run("defaults", func(t *testing.T) {
+		expected, _ := apiconfig.NewDefaultsConfigFromConfigMap(defaultsConfig)
+		if diff := cmp.Diff(expected, config.Defaults); diff != "" {
+			t.Errorf("Unexpected defaults config (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("tracing", func(t *testing.T) {
+		expected, _ := tracingconfig.NewTracingConfigFromConfigMap(tracingConfig)
+		if diff := cmp.Diff(expected, config.Tracing); diff != "" {
+			t.Errorf("Unexpected tracing config (-want, +got): %v", diff)
+		}
+	})
 }
 
 func TestStoreImmutableConfig(t *testing.T) {
@@ -100,6 +120,8 @@ func TestStoreImmutableConfig(t *testing.T) {
 	store.OnConfigChanged(ConfigMapFromTestFile(t, pkgmetrics.ConfigMapName()))
 	store.OnConfigChanged(ConfigMapFromTestFile(t, pkglogging.ConfigMapName()))
 	store.OnConfigChanged(ConfigMapFromTestFile(t, autoscaler.ConfigName))
+	store.OnConfigChanged(ConfigMapFromTestFile(t, apiconfig.DefaultsConfigName))
+	store.OnConfigChanged(ConfigMapFromTestFile(t, tracingconfig.ConfigName))
 
 	config := store.Load()
 
@@ -107,6 +129,8 @@ func TestStoreImmutableConfig(t *testing.T) {
 	config.Network.IstioOutboundIPRanges = "mutated"
 	config.Logging.LoggingConfig = "mutated"
 	config.Autoscaler.MaxScaleUpRate = rand.Float64()
+	config.Defaults.QueueMaxConnections = 42
+	config.Tracing.SampleRate = 0.42
 
 	newConfig := store.Load()
 
@@ -122,4 +146,10 @@ func TestStoreImmutableConfig(t *testing.T) {
 	if newConfig.Autoscaler.MaxScaleUpRate == config.Autoscaler.MaxScaleUpRate {
 		t.Error("Autoscaler config is not immutable")
 	}
+	if newConfig.Defaults.QueueMaxConnections == 42 {
+		t.Error("Defaults config is not immutable")
+	}
+	if newConfig.Tracing.SampleRate == 0.42 {
+		t.Error("Tracing config is not immutable")
+	}
 }
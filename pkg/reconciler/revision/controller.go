@@ -24,6 +24,8 @@ import (
 	"knative.dev/pkg/injection/clients/kubeclient"
 	deploymentinformer "knative.dev/pkg/injection/informers/kubeinformers/appsv1/deployment"
 	configmapinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/configmap"
+	namespaceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace"
+	secretinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/secret"
 	serviceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/service"
 	kpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler"
 	revisioninformer "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/revision"
@@ -31,12 +33,15 @@ import (
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/autoscaler"
 	"github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/metrics"
 	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/reconciler"
 	"github.com/knative/serving/pkg/reconciler/revision/config"
+	"go.uber.org/zap"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -63,6 +68,8 @@ func NewController(
 	imageInformer := imageinformer.Get(ctx)
 	revisionInformer := revisioninformer.Get(ctx)
 	kpaInformer := kpainformer.Get(ctx)
+	namespaceInformer := namespaceinformer.Get(ctx)
+	secretInformer := secretinformer.Get(ctx)
 
 	c := &Reconciler{
 		Base:                reconciler.NewBase(ctx, controllerAgentName, cmw),
@@ -72,12 +79,22 @@ func NewController(
 		deploymentLister:    deploymentInformer.Lister(),
 		serviceLister:       serviceInformer.Lister(),
 		configMapLister:     configMapInformer.Lister(),
+		namespaceLister:     namespaceInformer.Lister(),
+		secretLister:        secretInformer.Lister(),
 		resolver: &digestResolver{
 			client:    kubeclient.Get(ctx),
 			transport: transport,
 		},
 	}
+	// Without a key the queue-proxies serve their stats to anyone, which
+	// keeps the autoscaler working in the meantime.
+	if key, err := autoscaler.EnsureStatsKey(c.KubeClientSet, system.Namespace()); err != nil {
+		c.Logger.Errorw("Failed to get the stats key, stats won't require authentication", zap.Error(err))
+	} else {
+		c.statsKey = key
+	}
 	impl := controller.NewImpl(c, c.Logger, "Revisions")
+	c.enqueueAfter = impl.EnqueueAfter
 
 	// Set up an event handler for when the resource types of interest change
 	c.Logger.Info("Setting up event handlers")
@@ -93,6 +110,12 @@ func NewController(
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
+	// Recreate the stats token secrets of the revisions if they are deleted.
+	secretInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.Filter(v1alpha1.SchemeGroupVersion.WithKind("Revision")),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
 	// We don't watch for changes to Image because we don't incorporate any of its
 	// properties into our own status and should work completely in the absence of
 	// a functioning Image controller.
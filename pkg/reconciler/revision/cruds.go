@@ -22,41 +22,81 @@ import (
 	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
 	"knative.dev/pkg/kmp"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
 	kpav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/autoscaler"
 	"github.com/knative/serving/pkg/reconciler/revision/config"
 	"github.com/knative/serving/pkg/reconciler/revision/resources"
+	resourcenames "github.com/knative/serving/pkg/reconciler/revision/resources/names"
 	presources "github.com/knative/serving/pkg/resources"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 )
 
 func (c *Reconciler) createDeployment(ctx context.Context, rev *v1alpha1.Revision) (*appsv1.Deployment, error) {
 	cfgs := config.FromContext(ctx)
 
+	// A missing Namespace simply means there are no Namespace level defaults.
+	ns, _ := c.namespaceLister.Get(rev.Namespace)
+
 	deployment := resources.MakeDeployment(
 		rev,
 		cfgs.Logging,
 		cfgs.Network,
 		cfgs.Observability,
+		cfgs.Tracing,
 		cfgs.Autoscaler,
-		cfgs.Deployment,
+		resources.NamespaceDeploymentConfig(cfgs.Deployment, ns),
+		cfgs.Defaults,
+		c.statsTokenSecret(rev, nil),
 	)
+	deployment.Spec.Replicas = ptr.Int32(resources.InitialScale(rev, ns))
 
 	return c.KubeClientSet.AppsV1().Deployments(deployment.Namespace).Create(deployment)
 }
 
+// statsTokenSecret returns the name of the secret the queue-proxies of the
+// revision read their stats token from, or "" if their stats are public.
+// Requiring the token changes the pod template, so Deployments that don't
+// require it yet are left alone rather than rolled out.
+func (c *Reconciler) statsTokenSecret(rev *v1alpha1.Revision, have *appsv1.Deployment) string {
+	if len(c.statsKey) == 0 || (have != nil && !requiresStatsToken(have)) {
+		return ""
+	}
+	return resourcenames.StatsTokenSecret(rev)
+}
+
+func requiresStatsToken(d *appsv1.Deployment) bool {
+	for _, container := range d.Spec.Template.Spec.Containers {
+		if container.Name != resources.QueueContainerName {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == "STATS_TOKEN" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (c *Reconciler) checkAndUpdateDeployment(ctx context.Context, rev *v1alpha1.Revision, have *appsv1.Deployment) (*appsv1.Deployment, error) {
 	logger := logging.FromContext(ctx)
 	cfgs := config.FromContext(ctx)
+	ns, _ := c.namespaceLister.Get(rev.Namespace)
 
 	deployment := resources.MakeDeployment(
 		rev,
 		cfgs.Logging,
 		cfgs.Network,
 		cfgs.Observability,
+		cfgs.Tracing,
 		cfgs.Autoscaler,
-		cfgs.Deployment,
+		resources.NamespaceDeploymentConfig(cfgs.Deployment, ns),
+		cfgs.Defaults,
+		c.statsTokenSecret(rev, have),
 	)
 
 	// Preserve the current scale of the Deployment.
@@ -104,6 +144,23 @@ func (c *Reconciler) createImageCache(ctx context.Context, rev *v1alpha1.Revisio
 	return c.CachingClientSet.CachingV1alpha1().Images(image.Namespace).Create(image)
 }
 
+func (c *Reconciler) createStatsTokenSecret(rev *v1alpha1.Revision) (*corev1.Secret, error) {
+	secret := resources.MakeStatsTokenSecret(rev, autoscaler.StatsToken(c.statsKey, rev.Namespace, rev.Name))
+
+	return c.KubeClientSet.CoreV1().Secrets(secret.Namespace).Create(secret)
+}
+
+func (c *Reconciler) checkAndUpdateStatsTokenSecret(rev *v1alpha1.Revision, have *corev1.Secret) (*corev1.Secret, error) {
+	secret := resources.MakeStatsTokenSecret(rev, autoscaler.StatsToken(c.statsKey, rev.Namespace, rev.Name))
+	if equality.Semantic.DeepEqual(have.Data, secret.Data) {
+		return have, nil
+	}
+
+	want := have.DeepCopy()
+	want.Data = secret.Data
+	return c.KubeClientSet.CoreV1().Secrets(want.Namespace).Update(want)
+}
+
 func (c *Reconciler) createKPA(ctx context.Context, rev *v1alpha1.Revision) (*kpav1alpha1.PodAutoscaler, error) {
 	kpa := resources.MakeKPA(rev)
 
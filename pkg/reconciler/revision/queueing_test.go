@@ -31,6 +31,7 @@ import (
 	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/system"
 	autoscalingv1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
@@ -38,6 +39,7 @@ import (
 	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
 	"github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/network"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -177,6 +179,14 @@ func newTestController(t *testing.T) (
 				"panic-window":                            "10s",
 				"scale-to-zero-threshold":                 "10m",
 				"tick-interval":                           "2s",
+			}}, {
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      apiconfig.DefaultsConfigName,
+			}}, {
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      tracingconfig.ConfigName,
 			}},
 	}
 	for _, configMap := range configs {
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revision
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"knative.dev/pkg/apis"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// readinessGateTimeout bounds the requests to the URL readiness gates.
+	readinessGateTimeout = 5 * time.Second
+
+	// readinessGateRecheckInterval is how long to wait before the readiness
+	// gates of a Revision are checked again, while some haven't passed yet.
+	readinessGateRecheckInterval = 10 * time.Second
+)
+
+var readinessGateClient = &http.Client{Timeout: readinessGateTimeout}
+
+// reconcileReadinessGates checks the readiness gates of the Revision until
+// all of them passed once. It only gates the Revisions that weren't ready
+// yet, so that adding gates doesn't take the traffic away from a Revision.
+func (c *Reconciler) reconcileReadinessGates(ctx context.Context, rev *v1alpha1.Revision) (statusUpdate, error) {
+	cond := rev.Status.GetCondition(v1alpha1.RevisionConditionDependenciesReady)
+	if cond.IsTrue() || (cond == nil && rev.Status.IsReady()) {
+		return nil, nil
+	}
+
+	var gates []serving.ReadinessGate
+	if v, ok := rev.Annotations[serving.ReadinessGatesAnnotationKey]; ok {
+		// Invalid values are rejected by the webhook, so they are ignored here.
+		gates, _ = serving.ParseReadinessGates(v)
+	}
+	if len(gates) == 0 {
+		if cond == nil {
+			return nil, nil
+		}
+		// The gates were removed while pending.
+		return func(rev *v1alpha1.Revision) {
+			rev.Status.MarkDependenciesReady()
+		}, nil
+	}
+
+	pending, err := c.pendingReadinessGate(ctx, rev, gates)
+	if err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		c.enqueueAfter(rev, readinessGateRecheckInterval)
+		return func(rev *v1alpha1.Revision) {
+			rev.Status.MarkDependenciesPending("DependenciesPending", pending)
+		}, nil
+	}
+	return func(rev *v1alpha1.Revision) {
+		rev.Status.MarkDependenciesReady()
+	}, nil
+}
+
+// pendingReadinessGate returns why the first of the gates that hasn't passed
+// is pending, or "" if all of them passed.
+func (c *Reconciler) pendingReadinessGate(ctx context.Context, rev *v1alpha1.Revision, gates []serving.ReadinessGate) (string, error) {
+	for _, g := range gates {
+		switch {
+		case g.URL != nil:
+			if err := checkReadinessURL(ctx, g.URL.String()); err != nil {
+				return fmt.Sprintf("URL %s isn't ready: %v", g.URL, err), nil
+			}
+		case g.Secret != "":
+			_, err := c.secretLister.Secrets(rev.Namespace).Get(g.Secret)
+			if apierrs.IsNotFound(err) {
+				return fmt.Sprintf("Secret %q doesn't exist", g.Secret), nil
+			} else if err != nil {
+				return "", err
+			}
+		case g.Condition != "":
+			if !rev.Status.GetCondition(apis.ConditionType(g.Condition)).IsTrue() {
+				return fmt.Sprintf("Condition %q isn't True", g.Condition), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// checkReadinessURL returns an error unless the URL answers a GET with a
+// 2xx status.
+func checkReadinessURL(ctx context.Context, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := readinessGateClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revision
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/apis"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	. "github.com/knative/serving/pkg/reconciler/testing/v1alpha1"
+)
+
+func TestReconcileReadinessGates(t *testing.T) {
+	ready := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ready.Close()
+	notReady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer notReady.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      "db-credentials",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		gates   string
+		status  func(*v1alpha1.RevisionStatus)
+		want    corev1.ConditionStatus
+		recheck bool
+	}{{
+		name: "no gates",
+	}, {
+		name:  "secret exists",
+		gates: "secret=db-credentials",
+		want:  corev1.ConditionTrue,
+	}, {
+		name:    "secret missing",
+		gates:   "secret=db-credentials, secret=other",
+		want:    corev1.ConditionUnknown,
+		recheck: true,
+	}, {
+		name:  "url ready",
+		gates: "url=" + ready.URL,
+		want:  corev1.ConditionTrue,
+	}, {
+		name:    "url not ready",
+		gates:   "url=" + notReady.URL,
+		want:    corev1.ConditionUnknown,
+		recheck: true,
+	}, {
+		name:  "condition true",
+		gates: "condition=SchemaMigrated",
+		status: func(rs *v1alpha1.RevisionStatus) {
+			rs.Conditions = append(rs.Conditions, apis.Condition{
+				Type:   "SchemaMigrated",
+				Status: corev1.ConditionTrue,
+			})
+		},
+		want: corev1.ConditionTrue,
+	}, {
+		name:    "condition missing",
+		gates:   "condition=SchemaMigrated",
+		want:    corev1.ConditionUnknown,
+		recheck: true,
+	}, {
+		name:  "gates passed before",
+		gates: "secret=other",
+		status: func(rs *v1alpha1.RevisionStatus) {
+			rs.MarkDependenciesReady()
+		},
+		want: corev1.ConditionTrue,
+	}, {
+		name:  "revision ready before the gates",
+		gates: "secret=other",
+		status: func(rs *v1alpha1.RevisionStatus) {
+			rs.MarkResourcesAvailable()
+			rs.MarkContainerHealthy()
+		},
+	}, {
+		name: "gates removed while pending",
+		status: func(rs *v1alpha1.RevisionStatus) {
+			rs.MarkDependenciesPending("DependenciesPending", "")
+		},
+		want: corev1.ConditionTrue,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rev := testRevision()
+			if test.gates != "" {
+				rev.Annotations[serving.ReadinessGatesAnnotationKey] = test.gates
+			}
+			rev.Status.InitializeConditions()
+			if test.status != nil {
+				test.status(&rev.Status)
+			}
+
+			rechecked := false
+			listers := NewListers([]runtime.Object{secret})
+			c := &Reconciler{
+				secretLister: listers.GetSecretLister(),
+				enqueueAfter: func(interface{}, time.Duration) {
+					rechecked = true
+				},
+			}
+			update, err := c.reconcileReadinessGates(context.Background(), rev)
+			if err != nil {
+				t.Fatalf("reconcileReadinessGates() = %v", err)
+			}
+			if update != nil {
+				update(rev)
+			}
+
+			got := rev.Status.GetCondition(v1alpha1.RevisionConditionDependenciesReady)
+			if test.want == "" {
+				if got != nil {
+					t.Errorf("DependenciesReady = %v, want none", got)
+				}
+			} else if got == nil || got.Status != test.want {
+				t.Errorf("DependenciesReady = %v, want %v", got, test.want)
+			}
+			if rechecked != test.recheck {
+				t.Errorf("Rechecked = %v, want %v", rechecked, test.recheck)
+			}
+		})
+	}
+}
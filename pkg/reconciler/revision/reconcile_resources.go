@@ -19,11 +19,14 @@ package revision
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/logging/logkey"
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	kpav1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler/revision/config"
 	"github.com/knative/serving/pkg/reconciler/revision/resources"
 	resourcenames "github.com/knative/serving/pkg/reconciler/revision/resources/names"
 	"go.uber.org/zap"
@@ -34,51 +37,86 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func (c *Reconciler) reconcileDeployment(ctx context.Context, rev *v1alpha1.Revision) error {
+// statusUpdate reflects the outcome of reconciling a child resource in the
+// Revision's status.
+type statusUpdate func(*v1alpha1.Revision)
+
+func (c *Reconciler) reconcileDeployment(ctx context.Context, rev *v1alpha1.Revision) (statusUpdate, error) {
 	ns := rev.Namespace
 	deploymentName := resourcenames.Deployment(rev)
 	logger := logging.FromContext(ctx).With(zap.String(logkey.Deployment, deploymentName))
 
+	// The secret has to exist before the pods referencing it can start.
+	if len(c.statsKey) > 0 {
+		if err := c.reconcileStatsTokenSecret(ctx, rev); err != nil {
+			return nil, err
+		}
+	}
+
+	created := false
 	deployment, err := c.deploymentLister.Deployments(ns).Get(deploymentName)
 	if apierrs.IsNotFound(err) {
 		// Deployment does not exist. Create it.
-		rev.Status.MarkDeploying("Deploying")
+		created = true
 		deployment, err = c.createDeployment(ctx, rev)
 		if err != nil {
 			logger.Errorf("Error creating deployment %q: %v", deploymentName, err)
-			return err
+			return func(rev *v1alpha1.Revision) {
+				rev.Status.MarkDeploying("Deploying")
+			}, err
 		}
 		logger.Infof("Created deployment %q", deploymentName)
 	} else if err != nil {
 		logger.Errorf("Error reconciling deployment %q: %v", deploymentName, err)
-		return err
+		return nil, err
 	} else if !metav1.IsControlledBy(deployment, rev) {
 		// Surface an error in the revision's status, and return an error.
-		rev.Status.MarkResourceNotOwned("Deployment", deploymentName)
-		return fmt.Errorf("revision: %q does not own Deployment: %q", rev.Name, deploymentName)
+		return func(rev *v1alpha1.Revision) {
+			rev.Status.MarkResourceNotOwned("Deployment", deploymentName)
+		}, fmt.Errorf("revision: %q does not own Deployment: %q", rev.Name, deploymentName)
 	} else {
 		// The deployment exists, but make sure that it has the shape that we expect.
 		deployment, err = c.checkAndUpdateDeployment(ctx, rev, deployment)
 		if err != nil {
 			logger.Errorf("Error updating deployment %q: %v", deploymentName, err)
-			return err
+			return nil, err
 		}
 	}
 
 	// If a container keeps crashing (no active pods in the deployment although we want some)
-	if *deployment.Spec.Replicas > 0 && deployment.Status.AvailableReplicas == 0 {
+	var pod *corev1.Pod
+	unavailable := *deployment.Spec.Replicas > 0 && deployment.Status.AvailableReplicas == 0
+	if unavailable {
 		pods, err := c.KubeClientSet.CoreV1().Pods(ns).List(metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector)})
 		if err != nil {
 			logger.Errorf("Error getting pods: %v", err)
 		} else if len(pods.Items) > 0 {
 			// Arbitrarily grab the very first pod, as they all should be crashing
-			pod := pods.Items[0]
+			pod = &pods.Items[0]
+		}
+	}
 
+	return func(rev *v1alpha1.Revision) {
+		if created {
+			rev.Status.MarkDeploying("Deploying")
+		}
+
+		// Whether the failure was classified into a more specific reason
+		// than the Deployment timing out.
+		classified := false
+
+		// Update the revision status if the pods cannot be created at all,
+		// e.g. because the namespace's quota is exhausted.
+		if unavailable {
+			classified = markReplicaFailure(rev, deployment)
+		}
+
+		if pod != nil {
 			// Update the revision status if pod cannot be scheduled(possibly resource constraints)
 			// If pod cannot be scheduled then we expect the container status to be empty.
 			for _, cond := range pod.Status.Conditions {
 				if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
-					rev.Status.MarkResourcesUnavailable(cond.Reason, cond.Message)
+					classified = markUnschedulable(rev, cond) || classified
 					break
 				}
 			}
@@ -96,21 +134,22 @@ func (c *Reconciler) reconcileDeployment(ctx context.Context, rev *v1alpha1.Revi
 				}
 			}
 		}
-	}
 
-	// Now that we have a Deployment, determine whether there is any relevant
-	// status to surface in the Revision.
-	if hasDeploymentTimedOut(deployment) && !rev.Status.IsActivationRequired() {
-		rev.Status.MarkProgressDeadlineExceeded(fmt.Sprintf(
-			"Unable to create pods for more than %d seconds.", resources.ProgressDeadlineSeconds))
-		c.Recorder.Eventf(rev, corev1.EventTypeNormal, "ProgressDeadlineExceeded",
-			"Revision %s not ready due to Deployment timeout", rev.Name)
-	}
-
-	return nil
+		// Now that we have a Deployment, determine whether there is any relevant
+		// status to surface in the Revision.
+		if hasDeploymentTimedOut(deployment) && !rev.Status.IsActivationRequired() {
+			// Keep the classified reason, it tells why the deadline was exceeded.
+			if !classified {
+				rev.Status.MarkProgressDeadlineExceeded(fmt.Sprintf(
+					"Unable to create pods for more than %d seconds.", resources.ProgressDeadlineSeconds))
+			}
+			c.Recorder.Eventf(rev, corev1.EventTypeNormal, "ProgressDeadlineExceeded",
+				"Revision %s not ready due to Deployment timeout", rev.Name)
+		}
+	}, nil
 }
 
-func (c *Reconciler) reconcileImageCache(ctx context.Context, rev *v1alpha1.Revision) error {
+func (c *Reconciler) reconcileImageCache(ctx context.Context, rev *v1alpha1.Revision) (statusUpdate, error) {
 	logger := logging.FromContext(ctx)
 
 	ns := rev.Namespace
@@ -120,18 +159,45 @@ func (c *Reconciler) reconcileImageCache(ctx context.Context, rev *v1alpha1.Revi
 		_, err := c.createImageCache(ctx, rev)
 		if err != nil {
 			logger.Errorf("Error creating image cache %q: %v", imageName, err)
-			return err
+			return nil, err
 		}
 		logger.Infof("Created image cache %q", imageName)
 	} else if getImageCacheErr != nil {
 		logger.Errorf("Error reconciling image cache %q: %v", imageName, getImageCacheErr)
-		return getImageCacheErr
+		return nil, getImageCacheErr
 	}
 
+	return nil, nil
+}
+
+func (c *Reconciler) reconcileStatsTokenSecret(ctx context.Context, rev *v1alpha1.Revision) error {
+	logger := logging.FromContext(ctx)
+
+	ns := rev.Namespace
+	secretName := resourcenames.StatsTokenSecret(rev)
+	secret, err := c.secretLister.Secrets(ns).Get(secretName)
+	if apierrs.IsNotFound(err) {
+		if _, err := c.createStatsTokenSecret(rev); err != nil {
+			logger.Errorf("Error creating stats token secret %q: %v", secretName, err)
+			return err
+		}
+		logger.Infof("Created stats token secret %q", secretName)
+		return nil
+	} else if err != nil {
+		logger.Errorf("Error reconciling stats token secret %q: %v", secretName, err)
+		return err
+	} else if !metav1.IsControlledBy(secret, rev) {
+		return fmt.Errorf("revision: %q does not own Secret: %q", rev.Name, secretName)
+	}
+
+	if _, err := c.checkAndUpdateStatsTokenSecret(rev, secret); err != nil {
+		logger.Errorf("Error updating stats token secret %q: %v", secretName, err)
+		return err
+	}
 	return nil
 }
 
-func (c *Reconciler) reconcileKPA(ctx context.Context, rev *v1alpha1.Revision) error {
+func (c *Reconciler) reconcileKPA(ctx context.Context, rev *v1alpha1.Revision) (statusUpdate, error) {
 	ns := rev.Namespace
 	kpaName := resourcenames.KPA(rev)
 	logger := logging.FromContext(ctx)
@@ -143,57 +209,106 @@ func (c *Reconciler) reconcileKPA(ctx context.Context, rev *v1alpha1.Revision) e
 		kpa, err = c.createKPA(ctx, rev)
 		if err != nil {
 			logger.Errorf("Error creating KPA %s: %v", kpaName, err)
-			return err
+			return nil, err
 		}
 		logger.Info("Created KPA:", kpaName)
 	} else if err != nil {
 		logger.Errorf("Error reconciling kpa %s: %v", kpaName, err)
-		return err
+		return nil, err
 	} else if !metav1.IsControlledBy(kpa, rev) {
 		// Surface an error in the revision's status, and return an error.
-		rev.Status.MarkResourceNotOwned("PodAutoscaler", kpaName)
-		return fmt.Errorf("revision: %q does not own PodAutoscaler: %q", rev.Name, kpaName)
+		return func(rev *v1alpha1.Revision) {
+			rev.Status.MarkResourceNotOwned("PodAutoscaler", kpaName)
+		}, fmt.Errorf("revision: %q does not own PodAutoscaler: %q", rev.Name, kpaName)
 	}
 
 	// Perhaps tha KPA spec changed underneath ourselves?
 	// TODO(vagababov): required for #1997. Should be removed in 0.7,
 	// to fix the protocol type when it's unset.
+	updated := false
 	tmpl := resources.MakeKPA(rev)
+	if config.FromContext(ctx).Autoscaler.EnableDynamicContainerConcurrency {
+		// The containerConcurrency may be changed in place on the PA.
+		tmpl.Spec.ContainerConcurrency = kpa.Spec.ContainerConcurrency
+	}
 	if !equality.Semantic.DeepEqual(tmpl.Spec, kpa.Spec) {
 		logger.Infof("KPA %s needs reconciliation", kpa.Name)
 
 		want := kpa.DeepCopy()
 		want.Spec = tmpl.Spec
 		if kpa, err = c.ServingClientSet.AutoscalingV1alpha1().PodAutoscalers(kpa.Namespace).Update(want); err != nil {
-			return err
+			return nil, err
 		}
-		// This change will trigger KPA -> SKS -> K8s service change;
-		// and those after reconciliation will back progpagate here.
-		rev.Status.MarkDeploying("Updating")
+		updated = true
 	}
 
-	// Propagate the service name from the PA.
-	rev.Status.ServiceName = kpa.Status.ServiceName
-
-	// Reflect the KPA status in our own.
-	cond := kpa.Status.GetCondition(kpav1alpha1.PodAutoscalerConditionReady)
-	switch {
-	case cond == nil:
-		rev.Status.MarkActivating("Deploying", "")
-		// If not ready => SKS did not report a service name, we can reliably use.
-	case cond.Status == corev1.ConditionUnknown:
-		rev.Status.MarkActivating(cond.Reason, cond.Message)
-	case cond.Status == corev1.ConditionFalse:
-		rev.Status.MarkInactive(cond.Reason, cond.Message)
-	case cond.Status == corev1.ConditionTrue:
-		rev.Status.MarkActive()
-
-		// Precondition for PA being active is SKS being active and
-		// that entices that |service.endpoints| > 0.
-		rev.Status.MarkResourcesAvailable()
-		rev.Status.MarkContainerHealthy()
+	// External systems can request the activation or the hibernation of the
+	// Revision at any time, propagate them to the KPA.
+	if !scaleRequestsEqual(tmpl.Annotations, kpa.Annotations) {
+		logger.Infof("KPA %s scale requests changed", kpa.Name)
+
+		want := kpa.DeepCopy()
+		if want.Annotations == nil {
+			want.Annotations = make(map[string]string, 2)
+		}
+		for _, k := range scaleRequestAnnotationKeys {
+			if v, ok := tmpl.Annotations[k]; ok {
+				want.Annotations[k] = v
+			} else {
+				delete(want.Annotations, k)
+			}
+		}
+		if kpa, err = c.ServingClientSet.AutoscalingV1alpha1().PodAutoscalers(kpa.Namespace).Update(want); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+
+	return func(rev *v1alpha1.Revision) {
+		if updated {
+			// This change will trigger KPA -> SKS -> K8s service change;
+			// and those after reconciliation will back progpagate here.
+			rev.Status.MarkDeploying("Updating")
+		}
+
+		// Propagate the service name from the PA.
+		rev.Status.ServiceName = kpa.Status.ServiceName
+
+		// Reflect the KPA status in our own.
+		cond := kpa.Status.GetCondition(kpav1alpha1.PodAutoscalerConditionReady)
+		switch {
+		case cond == nil:
+			rev.Status.MarkActivating("Deploying", "")
+			// If not ready => SKS did not report a service name, we can reliably use.
+		case cond.Status == corev1.ConditionUnknown:
+			rev.Status.MarkActivating(cond.Reason, cond.Message)
+		case cond.Status == corev1.ConditionFalse:
+			rev.Status.MarkInactive(cond.Reason, cond.Message)
+		case cond.Status == corev1.ConditionTrue:
+			rev.Status.MarkActive()
+
+			// Precondition for PA being active is SKS being active and
+			// that entices that |service.endpoints| > 0.
+			rev.Status.MarkResourcesAvailable()
+			rev.Status.MarkContainerHealthy()
+		}
+	}, nil
+}
+
+// scaleRequestAnnotationKeys are the annotations that request the activation
+// of a Revision ahead of traffic, or its hibernation.
+var scaleRequestAnnotationKeys = []string{
+	autoscaling.ActivationScaleAnnotationKey,
+	autoscaling.ActivationExpiryAnnotationKey,
+	autoscaling.HibernatedAnnotationKey,
+}
+
+func scaleRequestsEqual(a, b map[string]string) bool {
+	for _, k := range scaleRequestAnnotationKeys {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
 }
 
 func hasDeploymentTimedOut(deployment *appsv1.Deployment) bool {
@@ -211,3 +326,41 @@ func hasDeploymentTimedOut(deployment *appsv1.Deployment) bool {
 	}
 	return false
 }
+
+// markReplicaFailure surfaces the Deployment failing to create pods in the
+// Revision's status. Returns true if the failure was classified.
+func markReplicaFailure(rev *v1alpha1.Revision, deployment *appsv1.Deployment) bool {
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type != appsv1.DeploymentReplicaFailure || cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		// The ReplicaSet controller copies the API server's rejection into
+		// the message, e.g. `pods "foo" is forbidden: exceeded quota: compute, ...`.
+		if strings.Contains(cond.Message, "exceeded quota") {
+			rev.Status.MarkQuotaExceeded(cond.Message)
+			return true
+		}
+	}
+	return false
+}
+
+// markUnschedulable surfaces the given PodScheduled condition in the
+// Revision's status. Returns true if the scheduling failure was classified,
+// otherwise the condition is surfaced as is.
+func markUnschedulable(rev *v1alpha1.Revision, cond corev1.PodCondition) bool {
+	if cond.Reason == corev1.PodReasonUnschedulable {
+		// The scheduler reports why each node was filtered out, e.g.
+		// "0/3 nodes are available: 1 Insufficient cpu, 2 node(s) didn't match node selector."
+		switch {
+		case strings.Contains(cond.Message, "Insufficient "):
+			rev.Status.MarkInsufficientResources(cond.Message)
+			return true
+		case strings.Contains(cond.Message, "didn't match node selector"),
+			strings.Contains(cond.Message, "node affinity"):
+			rev.Status.MarkNodeAffinityUnsatisfiable(cond.Message)
+			return true
+		}
+	}
+	rev.Status.MarkResourcesUnavailable(cond.Reason, cond.Message)
+	return false
+}
@@ -22,6 +22,8 @@ import (
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
@@ -32,6 +34,7 @@ import (
 	"github.com/knative/serving/pkg/queue"
 	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
 	"github.com/knative/serving/pkg/resources"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -44,6 +47,14 @@ const (
 	varLogVolumePath   = "/var/log"
 	internalVolumeName = "knative-internal"
 	internalVolumePath = "/var/knative-internal"
+	podInfoVolumeName  = "knative-podinfo"
+	statsVolumeName    = "knative-stats"
+
+	requestAuthenticationVolumeName = "knative-request-authentication"
+
+	// zoneTopologyKey is the well-known Node label holding the zone the
+	// Node runs in.
+	zoneTopologyKey = "failure-domain.beta.kubernetes.io/zone"
 )
 
 var (
@@ -71,6 +82,48 @@ var (
 		MountPath: internalVolumePath,
 	}
 
+	// podInfoVolume exposes the pod's annotations to the queue-proxy, which
+	// is how it learns about containerConcurrency changes.
+	podInfoVolume = corev1.Volume{
+		Name: podInfoVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{{
+					Path: queue.PodInfoAnnotationsFile,
+					FieldRef: &corev1.ObjectFieldSelector{
+						FieldPath: "metadata.annotations",
+					},
+				}},
+			},
+		},
+	}
+
+	podInfoVolumeMount = corev1.VolumeMount{
+		Name:      podInfoVolumeName,
+		MountPath: queue.PodInfoVolumePath,
+		ReadOnly:  true,
+	}
+
+	// statsVolume holds the unix socket the queue-proxy serves its stats
+	// on, for the other containers of the pod to mount.
+	statsVolume = corev1.Volume{
+		Name: statsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+
+	statsVolumeMount = corev1.VolumeMount{
+		Name:      statsVolumeName,
+		MountPath: queue.StatsSocketVolumePath,
+	}
+
+	requestAuthenticationVolumeMount = corev1.VolumeMount{
+		Name:      requestAuthenticationVolumeName,
+		MountPath: queue.RequestAuthenticationVolumePath,
+		ReadOnly:  true,
+	}
+
 	// This PreStop hook is actually calling an endpoint on the queue-proxy
 	// because of the way PreStop hooks are called by kubelet. We use this
 	// to block the user-container from exiting before the queue-proxy is ready
@@ -107,7 +160,7 @@ func rewriteUserProbe(p *corev1.Probe, userPort int) {
 	}
 }
 
-func makePodSpec(rev *v1alpha1.Revision, loggingConfig *logging.Config, observabilityConfig *metrics.ObservabilityConfig, autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config) *corev1.PodSpec {
+func makePodSpec(rev *v1alpha1.Revision, loggingConfig *logging.Config, networkConfig *network.Config, observabilityConfig *metrics.ObservabilityConfig, tracingConfig *tracingconfig.Config, autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config, defaultsConfig *apiconfig.Defaults, statsTokenSecret string) *corev1.PodSpec {
 	userContainer := rev.Spec.GetContainer().DeepCopy()
 	// Adding or removing an overwritten corev1.Container field here? Don't forget to
 	// update the fieldmasks / validations in pkg/apis/serving
@@ -135,13 +188,15 @@ func makePodSpec(rev *v1alpha1.Revision, loggingConfig *logging.Config, observab
 	}
 
 	// If the client provides probes, we should fill in the port for them.
+	// Exec probes are left to the kubelet, which runs them in the user
+	// container, with its user and capabilities.
 	rewriteUserProbe(userContainer.ReadinessProbe, userPortInt)
 	rewriteUserProbe(userContainer.LivenessProbe, userPortInt)
 
 	podSpec := &corev1.PodSpec{
 		Containers: []corev1.Container{
 			*userContainer,
-			*makeQueueContainer(rev, loggingConfig, observabilityConfig, autoscalerConfig, deploymentConfig),
+			*makeQueueContainer(rev, loggingConfig, networkConfig, observabilityConfig, tracingConfig, autoscalerConfig, deploymentConfig, defaultsConfig, statsTokenSecret),
 		},
 		Volumes:                       append([]corev1.Volume{varLogVolume}, rev.Spec.Volumes...),
 		ServiceAccountName:            rev.Spec.ServiceAccountName,
@@ -153,9 +208,108 @@ func makePodSpec(rev *v1alpha1.Revision, loggingConfig *logging.Config, observab
 		podSpec.Volumes = append(podSpec.Volumes, internalVolume)
 	}
 
+	if needsPodInfo(autoscalerConfig, deploymentConfig) {
+		podSpec.Volumes = append(podSpec.Volumes, podInfoVolume)
+	}
+
+	if deploymentConfig.EnableQueueStatsSocket {
+		podSpec.Volumes = append(podSpec.Volumes, statsVolume)
+	}
+
+	if ra := requestAuthentication(rev); ra != nil && ra.JWKSSecret != "" {
+		podSpec.Volumes = append(podSpec.Volumes, makeRequestAuthenticationVolume(ra))
+	}
+
+	if _, ok := rev.Annotations[autoscaling.ZoneSpreadAnnotationKey]; ok {
+		podSpec.Affinity = makeZoneSpreadAffinity(rev)
+	}
+
 	return podSpec
 }
 
+// makeZoneSpreadAffinity makes the Pods of the Revision prefer not to be
+// scheduled in the same zone, so the autoscaler keeping one Pod per zone
+// results in one Pod in each zone.
+func makeZoneSpreadAffinity(rev *v1alpha1.Revision) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							serving.RevisionLabelKey: rev.Name,
+						},
+					},
+					TopologyKey: zoneTopologyKey,
+				},
+			}},
+		},
+	}
+}
+
+// httpReadinessProbe returns the HTTP get action of the user container's
+// readiness probe, if it has one.
+func httpReadinessProbe(rev *v1alpha1.Revision) *corev1.HTTPGetAction {
+	if p := rev.Spec.GetContainer().ReadinessProbe; p != nil {
+		return p.HTTPGet
+	}
+	return nil
+}
+
+// requestAuthentication returns the request authentication of the revision,
+// if it has one.
+func requestAuthentication(rev *v1alpha1.Revision) *serving.RequestAuthentication {
+	v, ok := rev.Annotations[serving.RequestAuthenticationAnnotationKey]
+	if !ok {
+		return nil
+	}
+	// The annotation was validated with the revision.
+	ra, _ := serving.ParseRequestAuthentication(v)
+	return &ra
+}
+
+// makeRequestAuthenticationVolume makes the volume of the secret holding the
+// JWKS that the queue-proxy verifies the tokens of requests with.
+func makeRequestAuthenticationVolume(ra *serving.RequestAuthentication) corev1.Volume {
+	return corev1.Volume{
+		Name: requestAuthenticationVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: ra.JWKSSecret,
+				Items: []corev1.KeyToPath{{
+					Key:  serving.JWKSSecretKey,
+					Path: serving.JWKSSecretKey,
+				}},
+			},
+		},
+	}
+}
+
+// userProbePaths returns the paths of the user container's HTTP probes,
+// which the kubelet calls without credentials.
+func userProbePaths(rev *v1alpha1.Revision) []string {
+	var paths []string
+	c := rev.Spec.GetContainer()
+	for _, p := range []*corev1.Probe{c.ReadinessProbe, c.LivenessProbe} {
+		if p == nil || p.HTTPGet == nil {
+			continue
+		}
+		path := p.HTTPGet.Path
+		if path == "" {
+			path = "/"
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// needsPodInfo returns whether the queue-proxy watches the pod's annotations
+// for configuration changes.
+func needsPodInfo(autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config) bool {
+	return autoscalerConfig.EnableDynamicContainerConcurrency || deploymentConfig.EnableQueueConfigReload
+}
+
 func getUserPort(rev *v1alpha1.Revision) int32 {
 	ports := rev.Spec.GetContainer().Ports
 
@@ -182,14 +336,31 @@ func buildUserPortEnv(userPort string) corev1.EnvVar {
 	}
 }
 
+// volatileAnnotation returns true for the Revision annotations that can have
+// high variance, like the heartbeat label or the activation and hibernation
+// requested by external systems. They must not roll out the Deployment when
+// they change.
+func volatileAnnotation(k string) bool {
+	switch k {
+	case serving.RevisionLastPinnedAnnotationKey,
+		autoscaling.ActivationScaleAnnotationKey,
+		autoscaling.ActivationExpiryAnnotationKey,
+		autoscaling.HibernatedAnnotationKey,
+		autoscaling.HibernationPageAnnotationKey:
+		return true
+	}
+	return false
+}
+
 // MakeDeployment constructs a K8s Deployment resource from a revision.
+// The queue-proxy requires the token in the statsTokenSecret from scrapers of
+// its stats, unless it is empty.
 func MakeDeployment(rev *v1alpha1.Revision,
 	loggingConfig *logging.Config, networkConfig *network.Config, observabilityConfig *metrics.ObservabilityConfig,
-	autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config) *appsv1.Deployment {
+	tracingConfig *tracingconfig.Config, autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config, defaultsConfig *apiconfig.Defaults,
+	statsTokenSecret string) *appsv1.Deployment {
 
-	podTemplateAnnotations := resources.FilterMap(rev.GetAnnotations(), func(k string) bool {
-		return k == serving.RevisionLastPinnedAnnotationKey
-	})
+	podTemplateAnnotations := resources.FilterMap(rev.GetAnnotations(), volatileAnnotation)
 
 	// TODO(nghia): Remove the need for this
 	// Only force-set the inject annotation if the revision does not state otherwise.
@@ -216,13 +387,10 @@ func MakeDeployment(rev *v1alpha1.Revision,
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      names.Deployment(rev),
-			Namespace: rev.Namespace,
-			Labels:    makeLabels(rev),
-			Annotations: resources.FilterMap(rev.GetAnnotations(), func(k string) bool {
-				// Exclude the heartbeat label, which can have high variance.
-				return k == serving.RevisionLastPinnedAnnotationKey
-			}),
+			Name:            names.Deployment(rev),
+			Namespace:       rev.Namespace,
+			Labels:          makeLabels(rev),
+			Annotations:     resources.FilterMap(rev.GetAnnotations(), volatileAnnotation),
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(rev)},
 		},
 		Spec: appsv1.DeploymentSpec{
@@ -234,7 +402,7 @@ func MakeDeployment(rev *v1alpha1.Revision,
 					Labels:      makeLabels(rev),
 					Annotations: podTemplateAnnotations,
 				},
-				Spec: *makePodSpec(rev, loggingConfig, observabilityConfig, autoscalerConfig, deploymentConfig),
+				Spec: *makePodSpec(rev, loggingConfig, networkConfig, observabilityConfig, tracingConfig, autoscalerConfig, deploymentConfig, defaultsConfig, statsTokenSecret),
 			},
 		},
 	}
@@ -27,6 +27,8 @@ import (
 	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/system"
 	_ "knative.dev/pkg/system/testing"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
@@ -35,6 +37,7 @@ import (
 	"github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/metrics"
 	"github.com/knative/serving/pkg/network"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -257,6 +260,18 @@ func withInternalVolumeMount() containerOption {
 	}
 }
 
+func withPodInfoVolumeMount() containerOption {
+	return func(container *corev1.Container) {
+		container.VolumeMounts = append(container.VolumeMounts, podInfoVolumeMount)
+	}
+}
+
+func withStatsVolumeMount() containerOption {
+	return func(container *corev1.Container) {
+		container.VolumeMounts = append(container.VolumeMounts, statsVolumeMount)
+	}
+}
+
 func withReadinessProbe(handler corev1.Handler) containerOption {
 	return func(container *corev1.Container) {
 		container.ReadinessProbe = &corev1.Probe{Handler: handler}
@@ -519,6 +534,52 @@ func TestMakePodSpec(t *testing.T) {
 				),
 				queueContainer(
 					withEnvVar("CONTAINER_CONCURRENCY", "0"),
+					withEnvVar("USER_READINESS_HTTP_PROBE", `{"path":"/"}`),
+				),
+			}),
+	}, {
+		name: "with http readiness probe headers and timeout",
+		rev: revision(func(revision *v1alpha1.Revision) {
+			container(revision.Spec.GetContainer(),
+				withHTTPReadinessProbe(v1alpha1.DefaultUserPort),
+				func(c *corev1.Container) {
+					c.ReadinessProbe.HTTPGet.HTTPHeaders = []corev1.HTTPHeader{{
+						Name:  "Authorization",
+						Value: "Bearer token",
+					}, {
+						Name:  "Host",
+						Value: "app.example.com",
+					}}
+					c.ReadinessProbe.TimeoutSeconds = 3
+				},
+			)
+		}),
+		lc: &logging.Config{},
+		oc: &metrics.ObservabilityConfig{},
+		ac: &autoscaler.Config{},
+		cc: &deployment.Config{},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(
+					withHTTPQPReadinessProbe,
+					func(c *corev1.Container) {
+						c.ReadinessProbe.HTTPGet.HTTPHeaders = []corev1.HTTPHeader{{
+							Name:  "Authorization",
+							Value: "Bearer token",
+						}, {
+							Name:  "Host",
+							Value: "app.example.com",
+						}, {
+							Name:  network.KubeletProbeHeaderName,
+							Value: "queue",
+						}}
+						c.ReadinessProbe.TimeoutSeconds = 3
+					},
+				),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "0"),
+					withEnvVar("USER_READINESS_HTTP_PROBE", `{"path":"/","headers":{"Authorization":["Bearer token"],"Host":["app.example.com"]}}`),
+					withEnvVar("USER_READINESS_HTTP_TIMEOUT_SECONDS", "3"),
 				),
 			}),
 	}, {
@@ -545,6 +606,42 @@ func TestMakePodSpec(t *testing.T) {
 					withEnvVar("CONTAINER_CONCURRENCY", "0"),
 				),
 			}),
+	}, {
+		name: "with zone spread",
+		rev: revision(func(revision *v1alpha1.Revision) {
+			revision.Annotations = map[string]string{
+				autoscaling.ZoneSpreadAnnotationKey: "3",
+			}
+		}),
+		lc: &logging.Config{},
+		oc: &metrics.ObservabilityConfig{},
+		ac: &autoscaler.Config{},
+		cc: &deployment.Config{},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "0"),
+				),
+			},
+			func(ps *corev1.PodSpec) {
+				ps.Affinity = &corev1.Affinity{
+					PodAntiAffinity: &corev1.PodAntiAffinity{
+						PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+							Weight: 100,
+							PodAffinityTerm: corev1.PodAffinityTerm{
+								LabelSelector: &metav1.LabelSelector{
+									MatchLabels: map[string]string{
+										serving.RevisionLabelKey: "bar",
+									},
+								},
+								TopologyKey: "failure-domain.beta.kubernetes.io/zone",
+							},
+						}},
+					},
+				}
+			},
+		),
 	}, {
 		name: "with http liveness probe",
 		rev: revision(func(revision *v1alpha1.Revision) {
@@ -604,6 +701,60 @@ func TestMakePodSpec(t *testing.T) {
 					withEnvVar("CONTAINER_CONCURRENCY", "0"),
 				),
 			}),
+	}, {
+		name: "with request authentication",
+		rev: revision(func(revision *v1alpha1.Revision) {
+			revision.Annotations = map[string]string{
+				serving.RequestAuthenticationAnnotationKey: "jwksSecret=jwks,issuer=https://auth.example.com",
+			}
+			container(revision.Spec.GetContainer(),
+				withLivenessProbe(corev1.Handler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: "/healthz",
+					},
+				}),
+			)
+		}),
+		lc: &logging.Config{},
+		oc: &metrics.ObservabilityConfig{},
+		ac: &autoscaler.Config{},
+		cc: &deployment.Config{},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(
+					withLivenessProbe(corev1.Handler{
+						HTTPGet: &corev1.HTTPGetAction{
+							Path: "/healthz",
+							Port: intstr.FromInt(networking.BackendHTTPPort),
+							HTTPHeaders: []corev1.HTTPHeader{{
+								Name:  network.KubeletProbeHeaderName,
+								Value: "queue",
+							}},
+						},
+					}),
+				),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "0"),
+					withEnvVar("REQUEST_AUTHENTICATION", "jwksSecret=jwks,issuer=https://auth.example.com"),
+					withEnvVar("REQUEST_AUTHENTICATION_PROBE_PATHS", "/healthz"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = append(container.VolumeMounts, requestAuthenticationVolumeMount)
+					},
+				),
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: requestAuthenticationVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: "jwks",
+						Items: []corev1.KeyToPath{{
+							Key:  "jwks.json",
+							Path: "jwks.json",
+						}},
+					},
+				},
+			}),
+		),
 	}, {
 		name: "with /var/log collection",
 		rev:  revision(withContainerConcurrency(1)),
@@ -626,6 +777,66 @@ func TestMakePodSpec(t *testing.T) {
 				podSpec.Volumes = append(podSpec.Volumes, internalVolume)
 			},
 		),
+	}, {
+		name: "with dynamic container concurrency",
+		rev:  revision(withContainerConcurrency(1)),
+		lc:   &logging.Config{},
+		oc:   &metrics.ObservabilityConfig{},
+		ac: &autoscaler.Config{
+			EnableDynamicContainerConcurrency: true,
+		},
+		cc: &deployment.Config{},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "1"),
+					withEnvVar("ENABLE_DYNAMIC_CONTAINER_CONCURRENCY", "true"),
+					withPodInfoVolumeMount(),
+				),
+			},
+			withAppendedVolumes(podInfoVolume),
+		),
+	}, {
+		name: "with queue config reload",
+		rev:  revision(withContainerConcurrency(1)),
+		lc:   &logging.Config{},
+		oc:   &metrics.ObservabilityConfig{},
+		ac:   &autoscaler.Config{},
+		cc: &deployment.Config{
+			EnableQueueConfigReload: true,
+		},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "1"),
+					withEnvVar("ENABLE_CONFIG_RELOAD", "true"),
+					withPodInfoVolumeMount(),
+				),
+			},
+			withAppendedVolumes(podInfoVolume),
+		),
+	}, {
+		name: "with queue stats socket",
+		rev:  revision(withContainerConcurrency(1)),
+		lc:   &logging.Config{},
+		oc:   &metrics.ObservabilityConfig{},
+		ac:   &autoscaler.Config{},
+		cc: &deployment.Config{
+			EnableQueueStatsSocket: true,
+		},
+		want: podSpec(
+			[]corev1.Container{
+				userContainer(),
+				queueContainer(
+					withEnvVar("CONTAINER_CONCURRENCY", "1"),
+					withEnvVar("QUEUE_STATS_SOCKET_PATH", "/var/run/knative/stats/stats.sock"),
+					withStatsVolumeMount(),
+				),
+			},
+			withAppendedVolumes(statsVolume),
+		),
 	}, {
 		name: "complex pod spec",
 		rev: revision(
@@ -696,7 +907,7 @@ func TestMakePodSpec(t *testing.T) {
 				return x.Cmp(y) == 0
 			})
 
-			got := makePodSpec(test.rev, test.lc, test.oc, test.ac, test.cc)
+			got := makePodSpec(test.rev, test.lc, &network.Config{}, test.oc, &tracingconfig.Config{}, test.ac, test.cc, &apiconfig.Defaults{}, "")
 			if diff := cmp.Diff(test.want, got, quantityComparer); diff != "" {
 				t.Errorf("makePodSpec (-want, +got) = %v", diff)
 			}
@@ -713,7 +924,7 @@ func TestMakePodSpec(t *testing.T) {
 			}
 			test.rev.Spec.DeprecatedContainer = nil
 
-			got := makePodSpec(test.rev, test.lc, test.oc, test.ac, test.cc)
+			got := makePodSpec(test.rev, test.lc, &network.Config{}, test.oc, &tracingconfig.Config{}, test.ac, test.cc, &apiconfig.Defaults{}, "")
 			if diff := cmp.Diff(test.want, got, quantityComparer); diff != "" {
 				t.Errorf("makePodSpec (-want, +got) = %v", diff)
 			}
@@ -810,8 +1021,8 @@ func TestMakeDeployment(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			// Tested above so that we can rely on it here for brevity.
-			test.want.Spec.Template.Spec = *makePodSpec(test.rev, test.lc, test.oc, test.ac, test.cc)
-			got := MakeDeployment(test.rev, test.lc, test.nc, test.oc, test.ac, test.cc)
+			test.want.Spec.Template.Spec = *makePodSpec(test.rev, test.lc, test.nc, test.oc, &tracingconfig.Config{}, test.ac, test.cc, &apiconfig.Defaults{}, "")
+			got := MakeDeployment(test.rev, test.lc, test.nc, test.oc, &tracingconfig.Config{}, test.ac, test.cc, &apiconfig.Defaults{}, "")
 			if diff := cmp.Diff(test.want, got, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
 				t.Errorf("MakeDeployment (-want, +got) = %v", diff)
 			}
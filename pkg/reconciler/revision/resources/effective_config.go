@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strconv"
+
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/metrics"
+	autoscalingresources "github.com/knative/serving/pkg/reconciler/autoscaling/resources"
+	pkgmetrics "knative.dev/pkg/metrics"
+)
+
+// MakeEffectiveConfig resolves the serving configuration that applies to
+// the revision, to be recorded in its status.
+func MakeEffectiveConfig(rev *v1alpha1.Revision, deploymentConfig *deployment.Config,
+	autoscalerConfig *autoscaler.Config, observabilityConfig *metrics.ObservabilityConfig) map[string]string {
+	ec := map[string]string{}
+	set := func(configName, key, value string) {
+		ec[configName+"/"+key] = value
+	}
+
+	set(deployment.ConfigName, deployment.QueueSidecarImageKey, deploymentConfig.QueueSidecarImage)
+	set(deployment.ConfigName, deployment.EnableEarlyHintsKey, strconv.FormatBool(deploymentConfig.EnableEarlyHints))
+	set(deployment.ConfigName, deployment.EnableQueueConfigReloadKey, strconv.FormatBool(deploymentConfig.EnableQueueConfigReload))
+	set(deployment.ConfigName, deployment.ReleaseConcurrencyOnHeadersKey, strconv.FormatBool(deploymentConfig.ReleaseConcurrencyOnHeaders))
+	set(deployment.ConfigName, deployment.QueueSidecarHeaderPolicyKey, deploymentConfig.QueueSidecarHeaderPolicy)
+	gogc, _ := deploymentConfig.QueueSidecarGC.GOGC()
+	set(deployment.ConfigName, deployment.QueueSidecarGOGCKey, gogc)
+	set(deployment.ConfigName, deployment.QueueSidecarMemoryLimitKey, strconv.FormatInt(deploymentConfig.QueueSidecarGC.MemoryLimit, 10))
+	set(deployment.ConfigName, deployment.QueueSidecarBallastKey, strconv.FormatInt(deploymentConfig.QueueSidecarGC.Ballast, 10))
+
+	set(autoscaler.ConfigName, "enable-scale-to-zero", strconv.FormatBool(autoscalerConfig.EnableScaleToZero))
+	set(autoscaler.ConfigName, "enable-checkpoint-restore", strconv.FormatBool(autoscalerConfig.EnableCheckpointRestore))
+	set(autoscaler.ConfigName, "enable-pod-consolidation", strconv.FormatBool(autoscalerConfig.EnablePodConsolidation))
+	set(autoscaler.ConfigName, "enable-dynamic-container-concurrency", strconv.FormatBool(autoscalerConfig.EnableDynamicContainerConcurrency))
+	set(autoscaler.ConfigName, "stable-window", autoscalerConfig.StableWindow.String())
+	set(autoscaler.ConfigName, "panic-window-percentage", formatFloat(autoscalerConfig.PanicWindowPercentage))
+	set(autoscaler.ConfigName, "target-burst-capacity", formatFloat(autoscalerConfig.TargetBurstCapacity))
+
+	set(pkgmetrics.ConfigMapName(), "logging.enable-var-log-collection", strconv.FormatBool(observabilityConfig.EnableVarLogCollection))
+	set(pkgmetrics.ConfigMapName(), "metrics.request-metrics-backend-destination", observabilityConfig.RequestMetricsBackend)
+	set(pkgmetrics.ConfigMapName(), "metrics.request-cpu-accounting", strconv.FormatBool(observabilityConfig.EnableRequestCPUAccounting))
+
+	if rev.Spec.TimeoutSeconds != nil {
+		set("revision", "timeoutSeconds", strconv.FormatInt(*rev.Spec.TimeoutSeconds, 10))
+	}
+	set("revision", "containerConcurrency", strconv.FormatInt(int64(rev.Spec.ContainerConcurrency), 10))
+	pa := MakeKPA(rev)
+	target, _ := autoscalingresources.ResolveConcurrency(pa, autoscalerConfig)
+	set("revision", "autoscalingClass", pa.Class())
+	set("revision", "autoscalingMetric", pa.Metric())
+	set("revision", "autoscalingTarget", formatFloat(target))
+
+	return ec
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
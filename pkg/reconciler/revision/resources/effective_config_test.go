@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	"github.com/knative/serving/pkg/autoscaler"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/pkg/metrics"
+	"knative.dev/pkg/ptr"
+)
+
+func TestMakeEffectiveConfig(t *testing.T) {
+	dc := &deployment.Config{
+		QueueSidecarImage: "queue:latest",
+		EnableEarlyHints:  true,
+	}
+	ac := &autoscaler.Config{
+		EnableScaleToZero:                  true,
+		StableWindow:                       time.Minute,
+		PanicWindowPercentage:              10,
+		ContainerConcurrencyTargetFraction: 0.7,
+		ContainerConcurrencyTargetDefault:  100,
+	}
+	oc := &metrics.ObservabilityConfig{
+		RequestMetricsBackend: "prometheus",
+	}
+
+	tests := []struct {
+		name string
+		rev  *v1alpha1.Revision
+		want map[string]string
+	}{{
+		name: "defaults",
+		rev: &v1alpha1.Revision{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "bar"},
+			Spec: v1alpha1.RevisionSpec{
+				RevisionSpec: v1beta1.RevisionSpec{
+					TimeoutSeconds: ptr.Int64(300),
+				},
+			},
+		},
+		want: map[string]string{
+			"revision/timeoutSeconds":       "300",
+			"revision/containerConcurrency": "0",
+			"revision/autoscalingClass":     autoscaling.KPA,
+			"revision/autoscalingMetric":    autoscaling.Concurrency,
+			"revision/autoscalingTarget":    "100",
+		},
+	}, {
+		name: "annotated revision",
+		rev: &v1alpha1.Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "foo",
+				Name:      "bar",
+				Annotations: map[string]string{
+					autoscaling.TargetAnnotationKey: "5",
+				},
+			},
+			Spec: v1alpha1.RevisionSpec{
+				RevisionSpec: v1beta1.RevisionSpec{
+					ContainerConcurrency: 10,
+					PodSpec: corev1.PodSpec{
+						Containers: []corev1.Container{{}},
+					},
+				},
+			},
+		},
+		want: map[string]string{
+			"revision/containerConcurrency": "10",
+			"revision/autoscalingClass":     autoscaling.KPA,
+			"revision/autoscalingMetric":    autoscaling.Concurrency,
+			"revision/autoscalingTarget":    "5",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := map[string]string{
+				"config-deployment/queueSidecarImage":                              "queue:latest",
+				"config-deployment/enableEarlyHints":                               "true",
+				"config-deployment/enableQueueConfigReload":                        "false",
+				"config-deployment/releaseConcurrencyOnHeaders":                    "false",
+				"config-deployment/queueSidecarHeaderPolicy":                       "",
+				"config-deployment/queueSidecarGOGC":                               "",
+				"config-deployment/queueSidecarMemoryLimit":                        "0",
+				"config-deployment/queueSidecarBallast":                            "0",
+				"config-autoscaler/enable-scale-to-zero":                           "true",
+				"config-autoscaler/enable-checkpoint-restore":                      "false",
+				"config-autoscaler/enable-pod-consolidation":                       "false",
+				"config-autoscaler/enable-dynamic-container-concurrency":           "false",
+				"config-autoscaler/stable-window":                                  "1m0s",
+				"config-autoscaler/panic-window-percentage":                        "10",
+				"config-autoscaler/target-burst-capacity":                          "0",
+				"config-observability/logging.enable-var-log-collection":           "false",
+				"config-observability/metrics.request-metrics-backend-destination": "prometheus",
+				"config-observability/metrics.request-cpu-accounting":              "false",
+			}
+			for k, v := range test.want {
+				want[k] = v
+			}
+			got := MakeEffectiveConfig(test.rev, dc, ac, oc)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("MakeEffectiveConfig (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -28,6 +28,12 @@ func ImageCache(rev kmeta.Accessor) string {
 	return kmeta.ChildName(rev.GetName(), "-cache")
 }
 
+// StatsTokenSecret returns the name of the secret holding the token the
+// queue-proxies of the revision require from scrapers of their stats.
+func StatsTokenSecret(rev kmeta.Accessor) string {
+	return kmeta.ChildName(rev.GetName(), "-stats-token")
+}
+
 // KPA returns the PA name for the revision.
 func KPA(rev kmeta.Accessor) string {
 	// We want the KPA's "key" to match the revision,
@@ -86,6 +86,15 @@ func TestNamer(t *testing.T) {
 		},
 		f:    ImageCache,
 		want: "foo-cache",
+	}, {
+		name: "StatsTokenSecret",
+		rev: &v1alpha1.Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "foo",
+			},
+		},
+		f:    StatsTokenSecret,
+		want: "foo-stats-token",
 	}, {
 		name: "KPA",
 		rev: &v1alpha1.Revision{
@@ -0,0 +1,43 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/deployment"
+	pkghttp "github.com/knative/serving/pkg/http"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NamespaceDeploymentConfig returns the deployment configuration of the
+// revisions in the Namespace, with the overrides of its annotations
+// applied. Invalid overrides are ignored.
+func NamespaceDeploymentConfig(cfg *deployment.Config, ns *corev1.Namespace) *deployment.Config {
+	if ns == nil {
+		return cfg
+	}
+	policy, ok := ns.Annotations[serving.QueueSideCarHeaderPolicyAnnotation]
+	if !ok || policy == cfg.QueueSidecarHeaderPolicy {
+		return cfg
+	}
+	if _, err := pkghttp.ParseHeaderPolicy(policy); err != nil {
+		return cfg
+	}
+	out := *cfg
+	out.QueueSidecarHeaderPolicy = policy
+	return &out
+}
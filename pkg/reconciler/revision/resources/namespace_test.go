@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/deployment"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceDeploymentConfig(t *testing.T) {
+	cfg := &deployment.Config{
+		QueueSidecarImage:        "queue:latest",
+		QueueSidecarHeaderPolicy: "Knative-*",
+	}
+	tests := []struct {
+		name string
+		ns   *corev1.Namespace
+		want string
+	}{{
+		name: "no namespace",
+		want: "Knative-*",
+	}, {
+		name: "no override",
+		ns:   &corev1.Namespace{},
+		want: "Knative-*",
+	}, {
+		name: "override",
+		ns: &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					serving.QueueSideCarHeaderPolicyAnnotation: "X-Forwarded-Proto=https",
+				},
+			},
+		},
+		want: "X-Forwarded-Proto=https",
+	}, {
+		name: "empty override disables the policy",
+		ns: &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					serving.QueueSideCarHeaderPolicyAnnotation: "",
+				},
+			},
+		},
+		want: "",
+	}, {
+		name: "invalid override",
+		ns: &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					serving.QueueSideCarHeaderPolicyAnnotation: "K-Network-Probe",
+				},
+			},
+		},
+		want: "Knative-*",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NamespaceDeploymentConfig(cfg, test.ns)
+			if diff := cmp.Diff(test.want, got.QueueSidecarHeaderPolicy); diff != "" {
+				t.Errorf("QueueSidecarHeaderPolicy (-want, +got) = %s", diff)
+			}
+			if got.QueueSidecarImage != cfg.QueueSidecarImage {
+				t.Errorf("QueueSidecarImage = %s, want: %s", got.QueueSidecarImage, cfg.QueueSidecarImage)
+			}
+		})
+	}
+	if cfg.QueueSidecarHeaderPolicy != "Knative-*" {
+		t.Errorf("The cluster configuration was modified: %s", cfg.QueueSidecarHeaderPolicy)
+	}
+}
@@ -17,25 +17,37 @@ limitations under the License.
 package resources
 
 import (
+	"encoding/json"
 	"math"
+	"net/http"
+	"path"
 	"strconv"
+	"strings"
 
 	"knative.dev/pkg/logging"
 	pkgmetrics "knative.dev/pkg/metrics"
 	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/system"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/autoscaler"
 	"github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/metrics"
+	"github.com/knative/serving/pkg/network"
+	"github.com/knative/serving/pkg/queue"
+	"github.com/knative/serving/pkg/queue/health"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-const requestQueueHTTPPortName = "queue-port"
+const (
+	requestQueueHTTPPortName = "queue-port"
+	requestQueueL4PortName   = "queue-l4-port"
+)
 
 var (
 	queueHTTPPort = corev1.ContainerPort{
@@ -157,8 +169,9 @@ func createResourcePercentageFromAnnotations(m map[string]string, k string) (boo
 }
 
 // makeQueueContainer creates the container spec for the queue sidecar.
-func makeQueueContainer(rev *v1alpha1.Revision, loggingConfig *logging.Config, observabilityConfig *metrics.ObservabilityConfig,
-	autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config) *corev1.Container {
+func makeQueueContainer(rev *v1alpha1.Revision, loggingConfig *logging.Config, networkConfig *network.Config, observabilityConfig *metrics.ObservabilityConfig,
+	tracingConfig *tracingconfig.Config, autoscalerConfig *autoscaler.Config, deploymentConfig *deployment.Config, defaultsConfig *apiconfig.Defaults,
+	statsTokenSecret string) *corev1.Container {
 	configName := ""
 	if owner := metav1.GetControllerOf(rev); owner != nil && owner.Kind == "Configuration" {
 		configName = owner.Name
@@ -190,8 +203,17 @@ func makeQueueContainer(rev *v1alpha1.Revision, loggingConfig *logging.Config, o
 	if observabilityConfig.EnableVarLogCollection {
 		volumeMounts = append(volumeMounts, internalVolumeMount)
 	}
+	if needsPodInfo(autoscalerConfig, deploymentConfig) {
+		volumeMounts = append(volumeMounts, podInfoVolumeMount)
+	}
+	if deploymentConfig.EnableQueueStatsSocket {
+		volumeMounts = append(volumeMounts, statsVolumeMount)
+	}
+	if ra := requestAuthentication(rev); ra != nil && ra.JWKSSecret != "" {
+		volumeMounts = append(volumeMounts, requestAuthenticationVolumeMount)
+	}
 
-	return &corev1.Container{
+	c := &corev1.Container{
 		Name:            QueueContainerName,
 		Image:           deploymentConfig.QueueSidecarImage,
 		Resources:       createQueueResources(rev.GetAnnotations(), rev.Spec.GetContainer()),
@@ -269,4 +291,380 @@ func makeQueueContainer(rev *v1alpha1.Revision, loggingConfig *logging.Config, o
 			Value: internalVolumePath,
 		}},
 	}
+
+	// Only restrict protocol upgrades when the revision asks for it.
+	annotations := rev.GetAnnotations()
+	if v, ok := annotations[serving.AllowedUpgradeProtocolsAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ALLOWED_UPGRADE_PROTOCOLS",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.MaxUpgradedConnectionsAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_UPGRADED_CONNECTIONS",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.WebSocketIdleWindowAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "WEBSOCKET_IDLE_WINDOW",
+			Value: v,
+		})
+	}
+
+	if v, ok := annotations[serving.RequestWeightHeaderAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_WEIGHT_HEADER",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.RequestCostsAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_COSTS",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarPathConcurrencyAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "PATH_CONCURRENCY",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryBufferBytesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RETRY_BUFFER_BYTES",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryMethodsAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RETRY_METHODS",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarResponseCacheBytesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RESPONSE_CACHE_BYTES",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarResponseCacheTTLAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RESPONSE_CACHE_TTL",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarMaxRequestBytesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_REQUEST_BYTES",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.QueueSideCarMaxResponseBytesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_RESPONSE_BYTES",
+			Value: v,
+		})
+	}
+
+	if v, ok := annotations[serving.ClientQuotaAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CLIENT_QUOTA",
+			Value: v,
+		})
+	}
+
+	if v, ok := annotations[serving.RequestsPerSecondLimitAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUESTS_PER_SECOND_LIMIT",
+			Value: v,
+		})
+		if v, ok := annotations[serving.RequestsPerSecondBurstAnnotationKey]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "REQUESTS_PER_SECOND_BURST",
+				Value: v,
+			})
+		}
+	}
+
+	if v, ok := annotations[serving.QueueDisciplineAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "QUEUE_DISCIPLINE",
+			Value: v,
+		})
+	}
+
+	if v, ok := annotations[serving.OverflowURLAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "OVERFLOW_URL",
+			Value: v,
+		})
+	}
+
+	// The queue-proxy samples the traces of the revision as it asks for.
+	if v, ok := annotations[serving.TracingSampleRateAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "TRACING_SAMPLE_RATE",
+			Value: v,
+		})
+	}
+	if v, ok := annotations[serving.TracingSampleOnErrorAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "TRACING_SAMPLE_ON_ERROR",
+			Value: v,
+		})
+	}
+
+	if v, _ := strconv.ParseBool(annotations[serving.GRPCWebAnnotationKey]); v {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_GRPC_WEB",
+			Value: "true",
+		})
+	}
+
+	// The port was validated with the revision.
+	if v, ok := annotations[serving.L4PortAnnotationKey]; ok {
+		l4, _ := serving.ParseL4Port(v)
+		c.Ports = append(c.Ports, corev1.ContainerPort{
+			Name:          requestQueueL4PortName,
+			ContainerPort: int32(networking.BackendL4Port),
+			Protocol:      l4.Protocol,
+		})
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "USER_L4_PORT",
+			Value: strconv.Itoa(int(l4.Port)),
+		}, corev1.EnvVar{
+			Name:  "USER_L4_PROTOCOL",
+			Value: string(l4.Protocol),
+		})
+	}
+
+	// The maintenance page only matters while the revision is in maintenance.
+	if v, _ := strconv.ParseBool(annotations[serving.MaintenanceModeAnnotationKey]); v {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAINTENANCE_MODE",
+			Value: "true",
+		}, corev1.EnvVar{
+			Name:  "MAINTENANCE_PAGE",
+			Value: annotations[serving.MaintenancePageAnnotationKey],
+		}, corev1.EnvVar{
+			Name:  "MAINTENANCE_PAGE_CONTENT_TYPE",
+			Value: annotations[serving.MaintenancePageContentTypeAnnotationKey],
+		})
+	}
+
+	// The kubelet can't authenticate, so the queue-proxy lets its probes of
+	// the user container through.
+	if v, ok := annotations[serving.RequestAuthenticationAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_AUTHENTICATION",
+			Value: v,
+		}, corev1.EnvVar{
+			Name:  "REQUEST_AUTHENTICATION_PROBE_PATHS",
+			Value: strings.Join(userProbePaths(rev), " "),
+		})
+	}
+
+	if networkConfig.ClientCertTrustedProxies != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CLIENT_CERT_TRUSTED_PROXIES",
+			Value: networkConfig.ClientCertTrustedProxies,
+		})
+	}
+
+	// Only configure the limits of the queue-proxy's server that are set,
+	// either by the revision or by default.
+	for _, limit := range []struct {
+		env        string
+		annotation string
+		value      int64
+	}{{
+		env:        "MAX_HEADER_BYTES",
+		annotation: serving.QueueSideCarMaxHeaderBytesAnnotation,
+		value:      defaultsConfig.QueueMaxHeaderBytes,
+	}, {
+		env:        "MAX_CONNECTIONS",
+		annotation: serving.QueueSideCarMaxConnectionsAnnotation,
+		value:      defaultsConfig.QueueMaxConnections,
+	}, {
+		env:        "READ_HEADER_TIMEOUT_SECONDS",
+		annotation: serving.QueueSideCarReadHeaderTimeoutSecondsAnnotation,
+		value:      defaultsConfig.QueueReadHeaderTimeoutSeconds,
+	}} {
+		v := strconv.FormatInt(limit.value, 10)
+		if a, ok := annotations[limit.annotation]; ok {
+			v = a
+		}
+		if v != "0" {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  limit.env,
+				Value: v,
+			})
+		}
+	}
+
+	// Attributing CPU time to requests needs request metrics to report it.
+	if observabilityConfig.EnableRequestCPUAccounting && observabilityConfig.RequestMetricsBackend != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_REQUEST_CPU_ACCOUNTING",
+			Value: "true",
+		})
+	}
+
+	// The OTLP collector of the request metrics is only passed down when
+	// they are exported with OTLP.
+	if observabilityConfig.RequestMetricsBackend == "otlp" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_REQUEST_METRICS_OTLP_ENDPOINT",
+			Value: observabilityConfig.RequestMetricsOTLPEndpoint,
+		}, corev1.EnvVar{
+			Name:  "SERVING_REQUEST_METRICS_OTLP_PROTOCOL",
+			Value: observabilityConfig.RequestMetricsOTLPProtocol,
+		})
+	}
+
+	// The tracing config is only passed down when tracing is enabled, so
+	// that the revisions aren't rolled out while it stays disabled.
+	if tracingConfig.Enable {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_BACKEND",
+			Value: string(tracingConfig.Backend),
+		}, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_ZIPKIN_ENDPOINT",
+			Value: tracingConfig.ZipkinEndpoint,
+		}, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_OTLP_ENDPOINT",
+			Value: tracingConfig.OTLPEndpoint,
+		}, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_OTLP_PROTOCOL",
+			Value: tracingConfig.OTLPProtocol,
+		}, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_DEBUG",
+			Value: strconv.FormatBool(tracingConfig.Debug),
+		}, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_SAMPLE_RATE",
+			Value: strconv.FormatFloat(tracingConfig.SampleRate, 'f', -1, 64),
+		})
+	}
+
+	// Checkpoint/restore is experimental, so only surface it when enabled.
+	// It is done by the runtime integration behind the concurrency state
+	// endpoint, so there is nothing to do without one.
+	if autoscalerConfig.EnableCheckpointRestore && deploymentConfig.ConcurrencyStateEndpoint != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_CHECKPOINT_RESTORE",
+			Value: "true",
+		})
+	}
+
+	if deploymentConfig.EnableEarlyHints {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_EARLY_HINTS",
+			Value: "true",
+		})
+	}
+	if deploymentConfig.EnableQueueStatsSocket {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "QUEUE_STATS_SOCKET_PATH",
+			Value: path.Join(queue.StatsSocketVolumePath, queue.StatsSocketFile),
+		})
+	}
+	if deploymentConfig.ReleaseConcurrencyOnHeaders {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RELEASE_CONCURRENCY_ON_HEADERS",
+			Value: "true",
+		})
+	}
+	if deploymentConfig.QueueSidecarHeaderPolicy != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_HEADER_POLICY",
+			Value: deploymentConfig.QueueSidecarHeaderPolicy,
+		})
+	}
+	if deploymentConfig.ConcurrencyStateEndpoint != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CONCURRENCY_STATE_ENDPOINT",
+			Value: deploymentConfig.ConcurrencyStateEndpoint,
+		})
+	}
+	// The Go runtime of queue-proxy reads GOGC and GOMEMLIMIT itself.
+	if gogc, ok := deploymentConfig.QueueSidecarGC.GOGC(); ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "GOGC",
+			Value: gogc,
+		})
+	}
+	if limit := deploymentConfig.QueueSidecarGC.MemoryLimit; limit > 0 {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "GOMEMLIMIT",
+			Value: strconv.FormatInt(limit, 10),
+		})
+	}
+	if ballast := deploymentConfig.QueueSidecarGC.Ballast; ballast > 0 {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "GC_BALLAST_BYTES",
+			Value: strconv.FormatInt(ballast, 10),
+		})
+	}
+	if autoscalerConfig.EnableDynamicContainerConcurrency {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_DYNAMIC_CONTAINER_CONCURRENCY",
+			Value: "true",
+		})
+	}
+	if deploymentConfig.EnableQueueConfigReload {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_CONFIG_RELOAD",
+			Value: "true",
+		})
+		// The pod annotations must not raise the request timeout beyond
+		// what a revision could be configured with.
+		if max := defaultsConfig.MaxRevisionTimeoutSeconds; max > 0 {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "MAX_REVISION_TIMEOUT_SECONDS",
+				Value: strconv.FormatInt(max, 10),
+			})
+		}
+	}
+	if statsTokenSecret != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name: "STATS_TOKEN",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: statsTokenSecret,
+					},
+					Key: StatsTokenSecretKey,
+				},
+			},
+		})
+	}
+	if get := httpReadinessProbe(rev); get != nil {
+		// The queue-proxy probes the user container the way kubelet does,
+		// with the headers of the probe, e.g. to authenticate.
+		probe := health.HTTPProbeConfig{
+			Path:   get.Path,
+			Scheme: string(get.Scheme),
+		}
+		for _, h := range get.HTTPHeaders {
+			if probe.Headers == nil {
+				probe.Headers = make(http.Header, len(get.HTTPHeaders))
+			}
+			probe.Headers[h.Name] = append(probe.Headers[h.Name], h.Value)
+		}
+		// Marshaling strings can't fail.
+		config, _ := json.Marshal(probe)
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "USER_READINESS_HTTP_PROBE",
+			Value: string(config),
+		})
+		if ts := rev.Spec.GetContainer().ReadinessProbe.TimeoutSeconds; ts > 0 {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "USER_READINESS_HTTP_TIMEOUT_SECONDS",
+				Value: strconv.Itoa(int(ts)),
+			})
+		}
+	}
+	return c
 }
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// InitialScale returns the number of replicas a new Deployment for the
+// revision should be created with. The revision's initialScale annotation
+// (inherited from its Configuration) takes precedence over the one set on
+// its Namespace, and the result defaults to 1. Zero is only honored when
+// the Namespace permits it, and the result is bounded by the revision's
+// minScale and maxScale annotations.
+func InitialScale(rev *v1alpha1.Revision, ns *corev1.Namespace) int32 {
+	var nsAnns map[string]string
+	if ns != nil {
+		nsAnns = ns.Annotations
+	}
+
+	scale := int32(1)
+	if v, ok := intAnnotation(nsAnns, autoscaling.InitialScaleAnnotationKey); ok {
+		scale = v
+	}
+	if v, ok := intAnnotation(rev.Annotations, autoscaling.InitialScaleAnnotationKey); ok {
+		scale = v
+	}
+
+	if scale == 0 && !strings.EqualFold(nsAnns[autoscaling.AllowZeroInitialScaleAnnotationKey], "true") {
+		scale = 1
+	}
+	if min, ok := intAnnotation(rev.Annotations, autoscaling.MinScaleAnnotationKey); ok && scale < min {
+		scale = min
+	}
+	if max, ok := intAnnotation(rev.Annotations, autoscaling.MaxScaleAnnotationKey); ok && max > 0 && scale > max {
+		scale = max
+	}
+	return scale
+}
+
+func intAnnotation(anns map[string]string, key string) (int32, bool) {
+	v, ok := anns[key]
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || i < 0 {
+		return 0, false
+	}
+	return int32(i), true
+}
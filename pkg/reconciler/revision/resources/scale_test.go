@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+func TestInitialScale(t *testing.T) {
+	tests := []struct {
+		name   string
+		revAnn map[string]string
+		nsAnn  map[string]string
+		noNS   bool
+		want   int32
+	}{{
+		name: "defaults",
+		want: 1,
+	}, {
+		name: "no namespace",
+		noNS: true,
+		want: 1,
+	}, {
+		name:   "revision annotation",
+		revAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "5"},
+		want:   5,
+	}, {
+		name:  "namespace annotation",
+		nsAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "3"},
+		want:  3,
+	}, {
+		name:   "revision overrides namespace",
+		revAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "7"},
+		nsAnn:  map[string]string{autoscaling.InitialScaleAnnotationKey: "3"},
+		want:   7,
+	}, {
+		name:   "zero not allowed",
+		revAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "0"},
+		want:   1,
+	}, {
+		name:   "zero allowed by namespace",
+		revAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "0"},
+		nsAnn:  map[string]string{autoscaling.AllowZeroInitialScaleAnnotationKey: "true"},
+		want:   0,
+	}, {
+		name: "namespace zero default",
+		nsAnn: map[string]string{
+			autoscaling.InitialScaleAnnotationKey:          "0",
+			autoscaling.AllowZeroInitialScaleAnnotationKey: "true",
+		},
+		want: 0,
+	}, {
+		name: "raised to minScale",
+		revAnn: map[string]string{
+			autoscaling.InitialScaleAnnotationKey: "2",
+			autoscaling.MinScaleAnnotationKey:     "4",
+		},
+		want: 4,
+	}, {
+		name:   "bounded by maxScale",
+		revAnn: map[string]string{autoscaling.MaxScaleAnnotationKey: "2"},
+		nsAnn:  map[string]string{autoscaling.InitialScaleAnnotationKey: "10"},
+		want:   2,
+	}, {
+		name:   "invalid revision annotation falls back to namespace",
+		revAnn: map[string]string{autoscaling.InitialScaleAnnotationKey: "lots"},
+		nsAnn:  map[string]string{autoscaling.InitialScaleAnnotationKey: "3"},
+		want:   3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rev := &v1alpha1.Revision{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "foo",
+					Name:        "bar",
+					Annotations: test.revAnn,
+				},
+			}
+			var ns *corev1.Namespace
+			if !test.noNS {
+				ns = &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "foo",
+						Annotations: test.nsAnn,
+					},
+				}
+			}
+			if got := InitialScale(rev, ns); got != test.want {
+				t.Errorf("InitialScale() = %d, want: %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	"knative.dev/pkg/kmeta"
+)
+
+// StatsTokenSecretKey is the key of the token in the stats token secret.
+const StatsTokenSecretKey = "token"
+
+// MakeStatsTokenSecret makes the secret holding the token the queue-proxies
+// of the revision require from scrapers of their stats.
+func MakeStatsTokenSecret(rev *v1alpha1.Revision, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.StatsTokenSecret(rev),
+			Namespace:       rev.Namespace,
+			Labels:          makeLabels(rev),
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(rev)},
+		},
+		Data: map[string][]byte{
+			StatsTokenSecretKey: []byte(token),
+		},
+	}
+}
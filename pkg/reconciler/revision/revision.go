@@ -20,6 +20,8 @@ import (
 	"context"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
@@ -31,10 +33,12 @@ import (
 	listers "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
 	"github.com/knative/serving/pkg/reconciler"
 	"github.com/knative/serving/pkg/reconciler/revision/config"
+	"github.com/knative/serving/pkg/reconciler/revision/resources"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	appsv1listers "k8s.io/client-go/listers/apps/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
@@ -56,9 +60,18 @@ type Reconciler struct {
 	deploymentLister    appsv1listers.DeploymentLister
 	serviceLister       corev1listers.ServiceLister
 	configMapLister     corev1listers.ConfigMapLister
+	namespaceLister     corev1listers.NamespaceLister
+	secretLister        corev1listers.SecretLister
 
 	resolver    resolver
 	configStore reconciler.ConfigStore
+
+	// enqueueAfter re-enqueues a Revision after a delay, to check its
+	// readiness gates again.
+	enqueueAfter func(interface{}, time.Duration)
+
+	// statsKey is the key the queue-proxies' stats tokens are derived from.
+	statsKey []byte
 }
 
 // Check that our Reconciler implements controller.Reconciler
@@ -174,13 +187,29 @@ func (c *Reconciler) reconcile(ctx context.Context, rev *v1alpha1.Revision) erro
 		return err
 	}
 
+	// Record the configuration the Revision is created with, for debugging.
+	if rev.Status.EffectiveConfig == nil {
+		cfgs := config.FromContext(ctx)
+		ns, _ := c.namespaceLister.Get(rev.Namespace)
+		rev.Status.EffectiveConfig = resources.MakeEffectiveConfig(rev,
+			resources.NamespaceDeploymentConfig(cfgs.Deployment, ns), cfgs.Autoscaler, cfgs.Observability)
+	}
+
+	// The Deployment and the image cache are built from the resolved digest,
+	// so it has to be known before the child resources are reconciled.
+	if err := c.reconcileDigest(ctx, rev); err != nil {
+		logger.Errorw("Failed to reconcile", zap.String("phase", "image digest"), zap.Error(err))
+		return err
+	}
+
+	// The child resources don't depend on each other, so they are reconciled
+	// concurrently. The phases don't touch the Revision's status themselves,
+	// instead the updates they return are applied in phase order, so that
+	// the result doesn't depend on which of them finished first.
 	phases := []struct {
 		name string
-		f    func(context.Context, *v1alpha1.Revision) error
+		f    func(context.Context, *v1alpha1.Revision) (statusUpdate, error)
 	}{{
-		name: "image digest",
-		f:    c.reconcileDigest,
-	}, {
 		name: "user deployment",
 		f:    c.reconcileDeployment,
 	}, {
@@ -189,13 +218,37 @@ func (c *Reconciler) reconcile(ctx context.Context, rev *v1alpha1.Revision) erro
 	}, {
 		name: "KPA",
 		f:    c.reconcileKPA,
+	}, {
+		name: "readiness gates",
+		f:    c.reconcileReadinessGates,
 	}}
 
-	for _, phase := range phases {
-		if err := phase.f(ctx, rev); err != nil {
-			logger.Errorw("Failed to reconcile", zap.String("phase", phase.name), zap.Error(err))
-			return err
+	updates := make([]statusUpdate, len(phases))
+	errs := make([]error, len(phases))
+	var wg sync.WaitGroup
+	for i, phase := range phases {
+		wg.Add(1)
+		go func(i int, f func(context.Context, *v1alpha1.Revision) (statusUpdate, error)) {
+			defer wg.Done()
+			updates[i], errs[i] = f(ctx, rev)
+		}(i, phase.f)
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, phase := range phases {
+		// Stop reflecting the children's status past the first failure,
+		// since it may be inconsistent with the failed phase.
+		if len(failed) == 0 && updates[i] != nil {
+			updates[i](rev)
 		}
+		if errs[i] != nil {
+			logger.Errorw("Failed to reconcile", zap.String("phase", phase.name), zap.Error(errs[i]))
+			failed = append(failed, errs[i])
+		}
+	}
+	if err := utilerrors.NewAggregate(failed); err != nil {
+		return err
 	}
 
 	readyAfterReconcile := rev.Status.IsReady()
@@ -32,6 +32,8 @@ import (
 	fakedeploymentinformer "knative.dev/pkg/injection/informers/kubeinformers/appsv1/deployment/fake"
 	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/configmap/fake"
 	fakeendpointsinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/endpoints/fake"
+	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace/fake"
+	fakesecretinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/secret/fake"
 	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/service/fake"
 	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
 	fakekpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler/fake"
@@ -49,12 +51,14 @@ import (
 	_ "knative.dev/pkg/metrics/testing"
 	"knative.dev/pkg/system"
 	av1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/autoscaler"
 	"github.com/knative/serving/pkg/deployment"
 	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/reconciler/revision/resources"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 	resourcenames "github.com/knative/serving/pkg/reconciler/revision/resources/names"
 	"golang.org/x/sync/errgroup"
 	appsv1 "k8s.io/api/apps/v1"
@@ -154,6 +158,16 @@ func newTestControllerWithConfig(t *testing.T, deploymentConfig *deployment.Conf
 			"panic-window":                            "10s",
 			"tick-interval":                           "2s",
 		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      apiconfig.DefaultsConfigName,
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      tracingconfig.ConfigName,
+		},
 	}, getTestDeploymentConfigMap()}
 
 	cms = append(cms, configs...)
@@ -230,6 +244,16 @@ func addResourcesToInformers(t *testing.T, ctx context.Context, rev *v1alpha1.Re
 		fakeimageinformer.Get(ctx).Informer().GetIndexer().Add(image)
 	}
 
+	secretName := resourcenames.StatsTokenSecret(rev)
+	secret, err := fakekubeclient.Get(ctx).CoreV1().Secrets(ns).Get(secretName, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		// Without a stats key the stats are public.
+	} else if err != nil {
+		t.Errorf("Secrets.Get(%v) = %v", secretName, err)
+	} else {
+		fakesecretinformer.Get(ctx).Informer().GetIndexer().Add(secret)
+	}
+
 	deploymentName := resourcenames.Deployment(rev)
 	deployment, err := fakekubeclient.Get(ctx).AppsV1().Deployments(ns).Get(deploymentName, metav1.GetOptions{})
 	if apierrs.IsNotFound(err) && haveBuild {
@@ -742,3 +766,55 @@ func TestGlobalResyncOnConfigMapUpdateDeployment(t *testing.T) {
 		})
 	}
 }
+
+func TestStatsTokenSecret(t *testing.T) {
+	rev := testRevision()
+	queueProxy := func(env ...corev1.EnvVar) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: resources.QueueContainerName,
+							Env:  env,
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		key  []byte
+		have *appsv1.Deployment
+		want string
+	}{{
+		name: "no key",
+		want: "",
+	}, {
+		name: "new deployment",
+		key:  []byte("key"),
+		want: "test-rev-stats-token",
+	}, {
+		name: "deployment requiring the token",
+		key:  []byte("key"),
+		have: queueProxy(corev1.EnvVar{Name: "STATS_TOKEN"}),
+		want: "test-rev-stats-token",
+	}, {
+		// Requiring the token would roll the deployment out.
+		name: "deployment with public stats",
+		key:  []byte("key"),
+		have: queueProxy(),
+		want: "",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Reconciler{statsKey: test.key}
+			if got := c.statsTokenSecret(rev, test.have); got != test.want {
+				t.Errorf("statsTokenSecret() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
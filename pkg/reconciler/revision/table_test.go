@@ -19,13 +19,16 @@ package revision
 import (
 	"context"
 	"testing"
+	"time"
 
 	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	logtesting "knative.dev/pkg/logging/testing"
+	"github.com/knative/serving/pkg/apis/autoscaling"
 	autoscalingv1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	apiconfig "github.com/knative/serving/pkg/apis/config"
 	"github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
@@ -35,6 +38,7 @@ import (
 	"github.com/knative/serving/pkg/reconciler"
 	"github.com/knative/serving/pkg/reconciler/revision/config"
 	"github.com/knative/serving/pkg/reconciler/revision/resources"
+	tracingconfig "github.com/knative/serving/pkg/tracing/config"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -70,7 +74,7 @@ func TestReconcile(t *testing.T) {
 		// We feed in a well formed Revision where none of its sub-resources exist,
 		// and we exect it to create them and initialize the Revision's status.
 		Objects: []runtime.Object{
-			rev("foo", "first-reconcile"),
+			rev("foo", "first-reconcile", withoutEffectiveConfig),
 		},
 		WantCreates: []runtime.Object{
 			// The first reconciliation of a Revision creates the following resources.
@@ -80,7 +84,8 @@ func TestReconcile(t *testing.T) {
 		},
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: rev("foo", "first-reconcile",
-				// The first reconciliation Populates the following status properties.
+				// The first reconciliation Populates the following status properties,
+				// including the effective configuration.
 				WithLogURL, AllUnknownConditions, MarkDeploying("Deploying")),
 		}},
 		Key: "foo/first-reconcile",
@@ -153,6 +158,8 @@ func TestReconcile(t *testing.T) {
 		WantCreates: []runtime.Object{
 			// We still see the following creates before the failure is induced.
 			deploy("foo", "create-user-deploy-failure"),
+			// The image cache is reconciled independently of the deployment.
+			image("foo", "create-user-deploy-failure"),
 		},
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: rev("foo", "create-user-deploy-failure",
@@ -164,6 +171,33 @@ func TestReconcile(t *testing.T) {
 			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for create deployments"),
 		},
 		Key: "foo/create-user-deploy-failure",
+	}, {
+		Name: "failure creating user deployment and kpa",
+		// The child resources are reconciled concurrently, so failures
+		// creating several of them are all reported.
+		WantErr: true,
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("create", "deployments"),
+			InduceFailure("create", "podautoscalers"),
+		},
+		Objects: []runtime.Object{
+			rev("foo", "create-deploy-kpa-failure"),
+		},
+		WantCreates: []runtime.Object{
+			kpa("foo", "create-deploy-kpa-failure"),
+			deploy("foo", "create-deploy-kpa-failure"),
+			image("foo", "create-deploy-kpa-failure"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: rev("foo", "create-deploy-kpa-failure",
+				WithLogURL, WithInitRevConditions,
+				MarkDeploying("Deploying")),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "InternalError",
+				"[inducing failure for create deployments, inducing failure for create podautoscalers]"),
+		},
+		Key: "foo/create-deploy-kpa-failure",
 	}, {
 		Name: "stable revision reconciliation",
 		// Test a simple stable reconciliation of an Active Revision.
@@ -201,6 +235,34 @@ func TestReconcile(t *testing.T) {
 			Patch: []byte(reconciler.ForceUpgradePatch),
 		}},
 		Key: "foo/needs-upgrade",
+	}, {
+		Name: "activation is propagated to kpa",
+		// Test that an external system requesting the activation of the
+		// Revision updates the KPA, but doesn't roll out the Deployment.
+		Objects: []runtime.Object{
+			rev("foo", "activate", WithLogURL, AllUnknownConditions,
+				WithRevisionAnnotation(autoscaling.ActivationScaleAnnotationKey, "5"),
+				WithRevisionAnnotation(autoscaling.ActivationExpiryAnnotationKey, "2019-06-01T12:00:00Z")),
+			kpa("foo", "activate"),
+			deploy("foo", "activate"),
+			image("foo", "activate"),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: kpa("foo", "activate", WithActivation(5, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))),
+		}},
+		Key: "foo/activate",
+	}, {
+		Name: "expired activation is removed from kpa",
+		Objects: []runtime.Object{
+			rev("foo", "deactivate", WithLogURL, AllUnknownConditions),
+			kpa("foo", "deactivate", WithActivation(5, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))),
+			deploy("foo", "deactivate"),
+			image("foo", "deactivate"),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: kpa("foo", "deactivate"),
+		}},
+		Key: "foo/deactivate",
 	}, {
 		Name: "update deployment containers",
 		// Test that we update a deployment with new containers when they disagree
@@ -464,6 +526,65 @@ func TestReconcile(t *testing.T) {
 				WithLogURL, AllUnknownConditions, MarkResourcesUnavailable("Insufficient energy", "Unschedulable")),
 		}},
 		Key: "foo/pod-schedule-error",
+	}, {
+		Name: "surface insufficient resources",
+		// Test the classification of pods not fitting on any node into the revision.
+		Objects: []runtime.Object{
+			rev("foo", "pod-insufficient",
+				withK8sServiceName("a-pod-insufficient"), WithLogURL, AllUnknownConditions, MarkActive),
+			kpa("foo", "pod-insufficient"),
+			pod("foo", "pod-insufficient", WithUnschedulableContainer("Unschedulable",
+				"0/3 nodes are available: 3 Insufficient cpu.")),
+			deploy("foo", "pod-insufficient"),
+			image("foo", "pod-insufficient"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: rev("foo", "pod-insufficient",
+				WithLogURL, AllUnknownConditions,
+				MarkInsufficientResources("0/3 nodes are available: 3 Insufficient cpu.")),
+		}},
+		Key: "foo/pod-insufficient",
+	}, {
+		Name: "surface unsatisfiable node affinity past the deadline",
+		// Test that the classified scheduling failure isn't replaced by the
+		// Deployment timing out.
+		Objects: []runtime.Object{
+			rev("foo", "pod-affinity",
+				withK8sServiceName("a-pod-affinity"), WithLogURL, AllUnknownConditions, MarkActive),
+			kpa("foo", "pod-affinity"),
+			pod("foo", "pod-affinity", WithUnschedulableContainer("Unschedulable",
+				"0/3 nodes are available: 3 node(s) didn't match node selector.")),
+			timeoutDeploy(deploy("foo", "pod-affinity")),
+			image("foo", "pod-affinity"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: rev("foo", "pod-affinity",
+				WithLogURL, AllUnknownConditions,
+				MarkNodeAffinityUnsatisfiable("0/3 nodes are available: 3 node(s) didn't match node selector.")),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "ProgressDeadlineExceeded",
+				"Revision %s not ready due to Deployment timeout",
+				"pod-affinity"),
+		},
+		Key: "foo/pod-affinity",
+	}, {
+		Name: "surface quota exceeded",
+		// Test the classification of the Deployment failing to create pods
+		// because of the namespace's quota into the revision.
+		Objects: []runtime.Object{
+			rev("foo", "quota-exceeded",
+				withK8sServiceName("a-quota-exceeded"), WithLogURL, AllUnknownConditions, MarkActive),
+			kpa("foo", "quota-exceeded"),
+			quotaExceededDeploy(deploy("foo", "quota-exceeded")),
+			image("foo", "quota-exceeded"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: rev("foo", "quota-exceeded",
+				WithLogURL, AllUnknownConditions,
+				MarkQuotaExceeded(`pods "quota-exceeded" is forbidden: exceeded quota: compute`)),
+		}},
+		Key: "foo/quota-exceeded",
 	}, {
 		Name: "ready steady state",
 		// Test the transition that Reconcile makes when Endpoints become ready on the
@@ -539,8 +660,11 @@ func TestReconcile(t *testing.T) {
 			deploymentLister:    listers.GetDeploymentLister(),
 			serviceLister:       listers.GetK8sServiceLister(),
 			configMapLister:     listers.GetConfigMapLister(),
+			namespaceLister:     listers.GetNamespaceLister(),
+			secretLister:        listers.GetSecretLister(),
 			resolver:            &nopResolver{},
 			configStore:         &testConfigStore{config: ReconcilerTestConfig()},
+			enqueueAfter:        func(interface{}, time.Duration) {},
 		}
 	}))
 }
@@ -554,6 +678,16 @@ func timeoutDeploy(deploy *appsv1.Deployment) *appsv1.Deployment {
 	return deploy
 }
 
+func quotaExceededDeploy(deploy *appsv1.Deployment) *appsv1.Deployment {
+	deploy.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:    appsv1.DeploymentReplicaFailure,
+		Status:  corev1.ConditionTrue,
+		Reason:  "FailedCreate",
+		Message: `pods "quota-exceeded" is forbidden: exceeded quota: compute`,
+	}}
+	return deploy
+}
+
 func noOwner(deploy *appsv1.Deployment) *appsv1.Deployment {
 	deploy.OwnerReferences = nil
 	return deploy
@@ -586,12 +720,20 @@ func rev(namespace, name string, ro ...RevisionOption) *v1alpha1.Revision {
 	}
 	r.SetDefaults(context.Background())
 
+	// Revisions have their effective configuration recorded on their first reconciliation.
+	cfgs := ReconcilerTestConfig()
+	r.Status.EffectiveConfig = resources.MakeEffectiveConfig(r, cfgs.Deployment, cfgs.Autoscaler, cfgs.Observability)
+
 	for _, opt := range ro {
 		opt(r)
 	}
 	return r
 }
 
+func withoutEffectiveConfig(r *v1alpha1.Revision) {
+	r.Status.EffectiveConfig = nil
+}
+
 func withK8sServiceName(sn string) RevisionOption {
 	return func(r *v1alpha1.Revision) {
 		r.Status.ServiceName = sn
@@ -628,7 +770,7 @@ func deploy(namespace, name string, opts ...interface{}) *appsv1.Deployment {
 	// before calling MakeDeployment within Reconcile.
 	rev.SetDefaults(context.Background())
 	return resources.MakeDeployment(rev, cfg.Logging, cfg.Network,
-		cfg.Observability, cfg.Autoscaler, cfg.Deployment,
+		cfg.Observability, cfg.Tracing, cfg.Autoscaler, cfg.Deployment, cfg.Defaults, "",
 	)
 
 }
@@ -688,5 +830,7 @@ func ReconcilerTestConfig() *config.Config {
 		},
 		Logging:    &logging.Config{},
 		Autoscaler: &autoscaler.Config{},
+		Defaults:   &apiconfig.Defaults{},
+		Tracing:    &tracingconfig.Config{},
 	}
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RolloutConfigName is the name of the config map holding the settings
+	// of the rollout gates.
+	RolloutConfigName = "config-rollout"
+)
+
+// Rollout holds the settings shared by the rollout gates of all Routes.
+type Rollout struct {
+	// MetricsURL is the base URL of the Prometheus server the revision
+	// metrics are read from. Gates get no metrics when it is empty.
+	MetricsURL string
+	// AllowedGateURLs are the URL prefixes the rollout gates of Routes may
+	// be called at. Gates at any other URL deny every traffic shift.
+	AllowedGateURLs []string
+	// AnalysisWindow is the duration over which the metrics are gathered.
+	AnalysisWindow time.Duration
+	// GateTimeout bounds the time a gate may take to decide.
+	GateTimeout time.Duration
+	// RecheckInterval is how long a denied traffic shift is held before
+	// the gates are consulted again.
+	RecheckInterval time.Duration
+}
+
+// NewRolloutFromConfigMap creates a Rollout from the supplied ConfigMap.
+func NewRolloutFromConfigMap(configMap *corev1.ConfigMap) (*Rollout, error) {
+	r := &Rollout{
+		MetricsURL: configMap.Data["metrics-url"],
+	}
+
+	for _, raw := range strings.FieldsFunc(configMap.Data["allowed-gate-urls"], func(c rune) bool {
+		return c == ',' || unicode.IsSpace(c)
+	}) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed-gate-urls entry %q: %v", raw, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid allowed-gate-urls entry %q: must be an absolute http(s) URL", raw)
+		}
+		r.AllowedGateURLs = append(r.AllowedGateURLs, raw)
+	}
+
+	for _, dur := range []struct {
+		key          string
+		field        *time.Duration
+		defaultValue time.Duration
+	}{{
+		key:          "analysis-window",
+		field:        &r.AnalysisWindow,
+		defaultValue: 5 * time.Minute,
+	}, {
+		key:          "gate-timeout",
+		field:        &r.GateTimeout,
+		defaultValue: 10 * time.Second,
+	}, {
+		key:          "recheck-interval",
+		field:        &r.RecheckInterval,
+		defaultValue: time.Minute,
+	}} {
+		if raw, ok := configMap.Data[dur.key]; !ok {
+			*dur.field = dur.defaultValue
+		} else if val, err := time.ParseDuration(raw); err != nil {
+			return nil, err
+		} else if val <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %v", dur.key, val)
+		} else {
+			*dur.field = val
+		}
+	}
+	return r, nil
+}
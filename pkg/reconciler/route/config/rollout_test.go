@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"knative.dev/pkg/system"
+
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestOurRollout(t *testing.T) {
+	cm, example := ConfigMapsFromTestFile(t, RolloutConfigName)
+	if _, err := NewRolloutFromConfigMap(cm); err != nil {
+		t.Errorf("NewRolloutFromConfigMap(actual) = %v", err)
+	}
+	if _, err := NewRolloutFromConfigMap(example); err != nil {
+		t.Errorf("NewRolloutFromConfigMap(example) = %v", err)
+	}
+}
+
+func TestNewRolloutFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *Rollout
+		wantErr bool
+	}{{
+		name: "defaults",
+		data: map[string]string{},
+		want: &Rollout{
+			AnalysisWindow:  5 * time.Minute,
+			GateTimeout:     10 * time.Second,
+			RecheckInterval: time.Minute,
+		},
+	}, {
+		name: "everything set",
+		data: map[string]string{
+			"metrics-url":       "http://prometheus:9090",
+			"allowed-gate-urls": "http://analysis.default.svc/check, https://gates.example.com/",
+			"analysis-window":   "1m",
+			"gate-timeout":      "5s",
+			"recheck-interval":  "30s",
+		},
+		want: &Rollout{
+			MetricsURL:      "http://prometheus:9090",
+			AllowedGateURLs: []string{"http://analysis.default.svc/check", "https://gates.example.com/"},
+			AnalysisWindow:  time.Minute,
+			GateTimeout:     5 * time.Second,
+			RecheckInterval: 30 * time.Second,
+		},
+	}, {
+		name: "relative allowed gate url",
+		data: map[string]string{
+			"allowed-gate-urls": "analysis.default.svc/check",
+		},
+		wantErr: true,
+	}, {
+		name: "invalid duration",
+		data: map[string]string{
+			"analysis-window": "soon",
+		},
+		wantErr: true,
+	}, {
+		name: "zero duration",
+		data: map[string]string{
+			"recheck-interval": "0s",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewRolloutFromConfigMap(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: system.Namespace(),
+					Name:      RolloutConfigName,
+				},
+				Data: test.data,
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewRolloutFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("NewRolloutFromConfigMap() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -32,6 +32,7 @@ type Config struct {
 	Domain  *Domain
 	GC      *gc.Config
 	Network *network.Config
+	Rollout *Rollout
 }
 
 func FromContext(ctx context.Context) *Config {
@@ -68,6 +69,7 @@ func NewStore(logger configmap.Logger, minRevisionTimeout time.Duration, onAfter
 				DomainConfigName:   NewDomainFromConfigMap,
 				gc.ConfigName:      gc.NewConfigFromConfigMapFunc(logger, minRevisionTimeout),
 				network.ConfigName: network.NewConfigFromConfigMap,
+				RolloutConfigName:  NewRolloutFromConfigMap,
 			},
 			onAfterStore...,
 		),
@@ -85,5 +87,6 @@ func (s *Store) Load() *Config {
 		Domain:  s.UntypedLoad(DomainConfigName).(*Domain).DeepCopy(),
 		GC:      s.UntypedLoad(gc.ConfigName).(*gc.Config).DeepCopy(),
 		Network: s.UntypedLoad(network.ConfigName).(*network.Config).DeepCopy(),
+		Rollout: s.UntypedLoad(RolloutConfigName).(*Rollout).DeepCopy(),
 	}
 }
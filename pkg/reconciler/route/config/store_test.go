@@ -36,10 +36,12 @@ func TestStoreLoadWithContext(t *testing.T) {
 	domainConfig := ConfigMapFromTestFile(t, DomainConfigName)
 	gcConfig := ConfigMapFromTestFile(t, gc.ConfigName)
 	networkConfig := ConfigMapFromTestFile(t, network.ConfigName)
+	rolloutConfig := ConfigMapFromTestFile(t, RolloutConfigName)
 
 	store.OnConfigChanged(domainConfig)
 	store.OnConfigChanged(gcConfig)
 	store.OnConfigChanged(networkConfig)
+	store.OnConfigChanged(rolloutConfig)
 
 	config := FromContext(store.ToContext(context.Background()))
 
@@ -60,6 +62,13 @@ func TestStoreLoadWithContext(t *testing.T) {
 		}
 	})
 
+	t.Run("rollout", func(t *testing.T) {
+		expected, _ := NewRolloutFromConfigMap(rolloutConfig)
+		if diff := cmp.Diff(expected, config.Rollout); diff != "" {
+			t.Errorf("Unexpected controller config (-want, +got): %v", diff)
+		}
+	})
+
 	t.Run("gc invalid timeout", func(t *testing.T) {
 		gcConfig.Data["stale-revision-timeout"] = "1h"
 		expected, err := gc.NewConfigFromConfigMapFunc(logtesting.TestLogger(t), 10*time.Hour)(gcConfig)
@@ -80,6 +89,7 @@ func TestStoreImmutableConfig(t *testing.T) {
 	store.OnConfigChanged(ConfigMapFromTestFile(t, DomainConfigName))
 	store.OnConfigChanged(ConfigMapFromTestFile(t, network.ConfigName))
 	store.OnConfigChanged(ConfigMapFromTestFile(t, gc.ConfigName))
+	store.OnConfigChanged(ConfigMapFromTestFile(t, RolloutConfigName))
 
 	config := store.Load()
 
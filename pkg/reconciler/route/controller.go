@@ -19,7 +19,9 @@ package route
 import (
 	"context"
 
+	namespaceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace"
 	serviceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/service"
+	kpainformer "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler"
 	certificateinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/certificate"
 	clusteringressinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/clusteringress"
 	configurationinformer "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/configuration"
@@ -35,6 +37,7 @@ import (
 	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/reconciler"
 	"github.com/knative/serving/pkg/reconciler/route/config"
+	"github.com/knative/serving/pkg/reconciler/route/rollout"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -63,6 +66,8 @@ func NewControllerWithClock(
 	revisionInformer := revisioninformer.Get(ctx)
 	clusterIngressInformer := clusteringressinformer.Get(ctx)
 	certificateInformer := certificateinformer.Get(ctx)
+	kpaInformer := kpainformer.Get(ctx)
+	namespaceInformer := namespaceinformer.Get(ctx)
 
 	// No need to lock domainConfigMutex yet since the informers that can modify
 	// domainConfig haven't started yet.
@@ -71,12 +76,16 @@ func NewControllerWithClock(
 		routeLister:          routeInformer.Lister(),
 		configurationLister:  configInformer.Lister(),
 		revisionLister:       revisionInformer.Lister(),
+		podAutoscalerLister:  kpaInformer.Lister(),
 		serviceLister:        serviceInformer.Lister(),
 		clusterIngressLister: clusterIngressInformer.Lister(),
 		certificateLister:    certificateInformer.Lister(),
+		namespaceLister:      namespaceInformer.Lister(),
 		clock:                clock,
 	}
 	impl := controller.NewImpl(c, c.Logger, "Routes")
+	c.enqueueAfter = impl.EnqueueAfter
+	c.gateChecks = rollout.NewAsyncChecker(impl.EnqueueKey)
 
 	c.Logger.Info("Setting up event handlers")
 	routeInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
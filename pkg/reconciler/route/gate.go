@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/logging"
+
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler/route/config"
+	"github.com/knative/serving/pkg/reconciler/route/rollout"
+	"github.com/knative/serving/pkg/reconciler/route/traffic"
+)
+
+// gateChecker runs the checks of the rollout gates, see rollout.AsyncChecker.
+type gateChecker interface {
+	Check(key string, step *rollout.Step, check func(context.Context) (*rollout.Decision, error)) (*rollout.Decision, bool, error)
+	Forget(key string)
+}
+
+// gateRollout consults the rollout gates of the Route before its traffic
+// shifts towards a revision. The gates are consulted in the background, and
+// the Route keeps its previous traffic until they decided. When a gate
+// denies a shift, or fails to decide, the Route keeps its previous traffic
+// and the gates are consulted again after the configured interval.
+func (c *Reconciler) gateRollout(ctx context.Context, prev []v1alpha1.TrafficTarget, t *traffic.Config, r *v1alpha1.Route) (*traffic.Config, error) {
+	cfg := config.FromContext(ctx).Rollout
+	client := &http.Client{
+		Timeout: cfg.GateTimeout,
+		// The gates are called at the URLs that were allowed, not wherever
+		// they redirect to.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	gates := rollout.GatesFromAnnotations(r.Annotations, client, cfg.AllowedGateURLs)
+	if len(gates) == 0 || len(prev) == 0 {
+		return t, nil
+	}
+	var metrics rollout.MetricsSource
+	if cfg.MetricsURL != "" {
+		metrics = &rollout.PrometheusSource{
+			URL:    cfg.MetricsURL,
+			Window: cfg.AnalysisWindow,
+			Client: client,
+		}
+	}
+
+	before, after := revisionPercents(prev, t)
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if after[name] <= before[name] || isRollbackTarget(t, name) {
+			continue
+		}
+		baseline := servingRevisions(before, name)
+		if len(baseline) == 0 {
+			continue
+		}
+		step := &rollout.Step{
+			Namespace:      r.Namespace,
+			Route:          r.Name,
+			Revision:       name,
+			Baseline:       baseline,
+			CurrentPercent: before[name],
+			TargetPercent:  after[name],
+		}
+		d, done, err := c.gateChecks.Check(r.Namespace+"/"+r.Name, step, func(ctx context.Context) (*rollout.Decision, error) {
+			return checkStep(ctx, gates, metrics, step)
+		})
+		if !done {
+			// The Route is enqueued once the gates decided.
+			return c.holdTraffic(ctx, prev, t, r)
+		}
+		if err != nil {
+			d = &rollout.Decision{Reason: err.Error()}
+		}
+		if !d.Approved {
+			c.Recorder.Eventf(r, corev1.EventTypeWarning, "RolloutHeld",
+				"Holding the shift of revision %q from %d%% to %d%% of the traffic: %s",
+				step.Revision, step.CurrentPercent, step.TargetPercent, d.Reason)
+			c.enqueueAfter(r, cfg.RecheckInterval)
+			return c.holdTraffic(ctx, prev, t, r)
+		}
+	}
+	return t, nil
+}
+
+// isRollbackTarget returns whether the traffic shifts towards the revision
+// because the Route is rolled back to it. Rollbacks aren't gated, as they
+// move traffic back to a revision that was already serving it.
+func isRollbackTarget(t *traffic.Config, name string) bool {
+	for _, rb := range t.Rollbacks {
+		if rb.To == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStep gathers the metrics of the revisions involved in the step, if
+// a MetricsSource is available, and runs the step through the gates.
+func checkStep(ctx context.Context, gates []rollout.Gate, metrics rollout.MetricsSource, step *rollout.Step) (*rollout.Decision, error) {
+	if metrics != nil {
+		step.Metrics = make(map[string]*rollout.Metrics, len(step.Baseline)+1)
+		for _, name := range append([]string{step.Revision}, step.Baseline...) {
+			m, err := metrics.RevisionMetrics(ctx, step.Namespace, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the metrics of revision %s: %v", name, err)
+			}
+			step.Metrics[name] = m
+		}
+	}
+	return rollout.Check(ctx, gates, step)
+}
+
+// holdTraffic rebuilds the Route's previous traffic so that it keeps being
+// served. If the previous traffic can't be routed anymore, the rollout
+// proceeds, since holding it would leave the Route without a backend.
+func (c *Reconciler) holdTraffic(ctx context.Context, prev []v1alpha1.TrafficTarget, t *traffic.Config, r *v1alpha1.Route) (*traffic.Config, error) {
+	logger := logging.FromContext(ctx)
+
+	held := r.DeepCopy()
+	held.Spec.Traffic = prev
+	ht, err := traffic.BuildTrafficConfiguration(c.configurationLister, c.revisionLister, held)
+	if err != nil {
+		logger.Errorw("Unable to hold the previous traffic, proceeding with the rollout", zap.Error(err))
+		return t, nil
+	}
+
+	r.Status.Traffic, err = ht.GetRevisionTrafficTargets(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return ht, nil
+}
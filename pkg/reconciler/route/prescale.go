@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	av1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/knative/serving/pkg/reconciler/route/traffic"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// preScaleRecheckInterval is how often a Route waiting for its
+	// revisions to be pre-scaled checks on them.
+	preScaleRecheckInterval = 5 * time.Second
+
+	// preScaleMaxWait bounds how long a traffic shift is held for the
+	// revisions to be pre-scaled, e.g. when their pods can't be scheduled.
+	preScaleMaxWait = 2 * time.Minute
+)
+
+// reconcilePreScale annotates the PodAutoscalers of the revisions whose share
+// of the Route's traffic grows, so that the autoscaler can scale them up in
+// proportion to the revisions they take traffic from before the traffic
+// arrives. It returns whether the revisions are scaled up, so that the
+// traffic can shift. Pre-scaling is an optimization, so failures are only
+// logged, and don't hold the traffic. Only Routes annotated with
+// autoscaling.PreScaleAnnotationKey are pre-scaled, and only the revisions
+// scaled by the KPA, which is the only autoscaler reporting when the
+// revision is pre-scaled.
+func (c *Reconciler) reconcilePreScale(ctx context.Context, prev []v1alpha1.TrafficTarget, t *traffic.Config, route *v1alpha1.Route) bool {
+	logger := logging.FromContext(ctx)
+	if route.Annotations[autoscaling.PreScaleAnnotationKey] != "true" {
+		return true
+	}
+
+	scaled := true
+	before, after := revisionPercents(prev, t)
+	for name, percent := range after {
+		if percent <= before[name] {
+			continue
+		}
+		from := servingRevisions(before, name)
+		if len(from) == 0 {
+			continue
+		}
+
+		// PodAutoscalers are named after the revision they scale.
+		pa, err := c.podAutoscalerLister.PodAutoscalers(route.Namespace).Get(name)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			logger.Errorf("Unable to get PodAutoscaler %q for pre-scaling: %v", name, err)
+			continue
+		}
+		if pa.Class() != autoscaling.KPA {
+			continue
+		}
+
+		fromStr := strings.Join(from, ",")
+		percentStr := strconv.Itoa(percent)
+		if pa.Annotations[autoscaling.PreScalePercentAnnotationKey] == percentStr &&
+			pa.Annotations[autoscaling.PreScaleFromAnnotationKey] == fromStr {
+			if !c.preScaled(pa) {
+				logger.Infof("Waiting for PodAutoscaler %q to be pre-scaled", name)
+				scaled = false
+			}
+			continue
+		}
+
+		newPA := pa.DeepCopy()
+		if newPA.Annotations == nil {
+			newPA.Annotations = make(map[string]string, 3)
+		}
+		newPA.Annotations[autoscaling.PreScalePercentAnnotationKey] = percentStr
+		newPA.Annotations[autoscaling.PreScaleFromAnnotationKey] = fromStr
+		newPA.Annotations[autoscaling.PreScaleTimeAnnotationKey] = c.clock.Now().UTC().Format(time.RFC3339)
+		patch, err := duck.CreateMergePatch(pa, newPA)
+		if err != nil {
+			logger.Errorf("Unable to create pre-scale patch for PodAutoscaler %q: %v", name, err)
+			continue
+		}
+		if _, err := c.ServingClientSet.AutoscalingV1alpha1().PodAutoscalers(route.Namespace).Patch(name, types.MergePatchType, patch); err != nil {
+			logger.Errorf("Unable to pre-scale PodAutoscaler %q: %v", name, err)
+			continue
+		}
+		scaled = false
+	}
+	return scaled
+}
+
+// preScaled returns whether the PodAutoscaler reported that its revision
+// has the ready pods it was pre-scaled to, or was given up waiting for.
+func (c *Reconciler) preScaled(pa *av1alpha1.PodAutoscaler) bool {
+	t := pa.Annotations[autoscaling.PreScaleTimeAnnotationKey]
+	if pa.Status.PreScaleReady && pa.Status.PreScaleTime == t {
+		return true
+	}
+	shiftTime, err := time.Parse(time.RFC3339, t)
+	return err != nil || c.clock.Now().After(shiftTime.Add(preScaleMaxWait))
+}
+
+// revisionPercents returns the percent of the Route's default traffic each
+// revision receives before and after applying t.
+func revisionPercents(prev []v1alpha1.TrafficTarget, t *traffic.Config) (before, after map[string]int) {
+	before = make(map[string]int, len(prev))
+	for _, tt := range prev {
+		before[tt.RevisionName] += tt.Percent
+	}
+	after = make(map[string]int)
+	for _, rt := range t.Targets[traffic.DefaultTarget] {
+		after[rt.RevisionName] += rt.Percent
+	}
+	return before, after
+}
+
+// servingRevisions returns the sorted names of the revisions other than
+// exclude that receive traffic according to percents.
+func servingRevisions(percents map[string]int, exclude string) []string {
+	var names []string
+	for name, percent := range percents {
+		if name != exclude && percent > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
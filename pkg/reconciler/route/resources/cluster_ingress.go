@@ -20,7 +20,9 @@ import (
 	"context"
 	"sort"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -50,6 +52,31 @@ func MakeIngressTLS(cert *v1alpha1.Certificate, hostNames []string) v1alpha1.Ing
 	}
 }
 
+// MakeIngressTLSPolicy creates the IngressTLSPolicy of the Route from the
+// cluster-wide policy of its visibility, overridden by the annotations on
+// its Namespace. It returns nil if neither restricts anything.
+func MakeIngressTLSPolicy(cfg *network.Config, r *servingv1alpha1.Route, ns *corev1.Namespace) (*v1alpha1.IngressTLSPolicy, error) {
+	policy := cfg.ExternalTLSPolicy
+	if IsClusterLocal(r) {
+		policy = cfg.ClusterLocalTLSPolicy
+	}
+	if ns != nil {
+		override, err := network.ParseTLSPolicy(ns.Annotations, networking.TLSPolicyAnnotationKeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		policy = policy.Override(override)
+	}
+	if policy.IsZero() {
+		return nil, nil
+	}
+	return &v1alpha1.IngressTLSPolicy{
+		MinProtocolVersion: v1alpha1.TLSProtocolVersion(policy.MinProtocolVersion),
+		CipherSuites:       policy.CipherSuites,
+		ALPNProtocols:      policy.ALPNProtocols,
+	}, nil
+}
+
 // MakeClusterIngress creates ClusterIngress to set up routing rules. Such ClusterIngress specifies
 // which Hosts that it applies to, as well as the routing rules.
 func MakeClusterIngress(ctx context.Context, r *servingv1alpha1.Route, tc *traffic.Config, tls []v1alpha1.IngressTLS, ingressClass string) (*v1alpha1.ClusterIngress, error) {
@@ -165,21 +192,69 @@ func makeIngressRule(domains []string, ns string, targets traffic.RevisionTarget
 		})
 	}
 
+	headers := map[string]string{
+		activator.RevisionHeaderName:      maxInactive(targets),
+		activator.RevisionHeaderNamespace: ns,
+	}
+	// The queue-proxy sets the cache headers the application doesn't.
+	if v := firstCacheHeader(targets, func(t traffic.RevisionTarget) string { return t.CacheControl }); v != "" {
+		headers[network.DefaultCacheControlHeaderName] = v
+	}
+	if v := firstCacheHeader(targets, func(t traffic.RevisionTarget) string { return t.SurrogateControl }); v != "" {
+		headers[network.DefaultSurrogateControlHeaderName] = v
+	}
+
 	return &v1alpha1.IngressRule{
 		Hosts: domains,
 		HTTP: &v1alpha1.HTTPIngressRuleValue{
 			Paths: []v1alpha1.HTTPIngressPath{{
 				Splits: splits,
-				// TODO(lichuqiang): #2201, plumbing to config timeout and retries.
-				AppendHeaders: map[string]string{
-					activator.RevisionHeaderName:      maxInactive(targets),
-					activator.RevisionHeaderNamespace: ns,
-				},
+				// TODO(lichuqiang): #2201, plumbing to config retries.
+				Timeout:       maxTimeout(targets),
+				AppendHeaders: headers,
 			}},
 		},
 	}
 }
 
+// maxTimeout returns the longest request timeout of the targets receiving
+// traffic, so that the ingress doesn't wait for longer than the revisions
+// would, whatever timeout the clients hint. It returns nil, for the default
+// timeout, if one of them has no timeout.
+func maxTimeout(targets traffic.RevisionTargets) *metav1.Duration {
+	var max int64
+	for _, t := range targets {
+		if t.Percent == 0 {
+			continue
+		}
+		if t.TimeoutSeconds == 0 {
+			return nil
+		}
+		if t.TimeoutSeconds > max {
+			max = t.TimeoutSeconds
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+	return &metav1.Duration{Duration: time.Duration(max) * time.Second}
+}
+
+// firstCacheHeader returns the cache header of the first of the targets
+// receiving traffic that sets it, as the headers are added per rule rather
+// than per split.
+func firstCacheHeader(targets traffic.RevisionTargets, header func(traffic.RevisionTarget) string) string {
+	for _, t := range targets {
+		if t.Percent == 0 {
+			continue
+		}
+		if v := header(t); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // maxInactive constructs Splits for the inactive targets, and add into given IngressPath.
 func maxInactive(targets traffic.RevisionTargets) string {
 	revisionName, inactiveRevisionName := "", ""
@@ -19,6 +19,7 @@ package resources
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/knative/serving/pkg/reconciler/route/config"
 
@@ -31,7 +32,9 @@ import (
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	"github.com/knative/serving/pkg/network"
 	"github.com/knative/serving/pkg/reconciler/route/traffic"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -214,6 +217,95 @@ func TestMakeClusterIngressSpec_CorrectVisibility(t *testing.T) {
 	}
 }
 
+func TestMakeIngressTLSPolicy(t *testing.T) {
+	cfg := &network.Config{
+		ExternalTLSPolicy: network.TLSPolicy{
+			MinProtocolVersion: "TLSV1_2",
+			CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+		},
+		ClusterLocalTLSPolicy: network.TLSPolicy{
+			ALPNProtocols: []string{"h2"},
+		},
+	}
+	public := &v1alpha1.Route{
+		Status: v1alpha1.RouteStatus{
+			RouteStatusFields: v1alpha1.RouteStatusFields{
+				URL: &apis.URL{Scheme: "http", Host: "domain.com"},
+			},
+		},
+	}
+	private := &v1alpha1.Route{
+		Status: v1alpha1.RouteStatus{
+			RouteStatusFields: v1alpha1.RouteStatusFields{
+				URL: &apis.URL{Scheme: "http", Host: "local-route.default.svc.cluster.local"},
+			},
+		},
+	}
+	namespace := func(anns map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Annotations: anns}}
+	}
+
+	cases := []struct {
+		name    string
+		cfg     *network.Config
+		route   *v1alpha1.Route
+		ns      *corev1.Namespace
+		want    *netv1alpha1.IngressTLSPolicy
+		wantErr bool
+	}{{
+		name:  "no policy",
+		cfg:   &network.Config{},
+		route: public,
+	}, {
+		name:  "external policy",
+		cfg:   cfg,
+		route: public,
+		want: &netv1alpha1.IngressTLSPolicy{
+			MinProtocolVersion: netv1alpha1.TLSProtocolVersion12,
+			CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+		},
+	}, {
+		name:  "cluster-local policy",
+		cfg:   cfg,
+		route: private,
+		want: &netv1alpha1.IngressTLSPolicy{
+			ALPNProtocols: []string{"h2"},
+		},
+	}, {
+		name:  "namespace overrides",
+		cfg:   cfg,
+		route: public,
+		ns: namespace(map[string]string{
+			networking.TLSPolicyAnnotationKeyPrefix + network.TLSMinProtocolVersionKey: "TLSV1_3",
+			networking.TLSPolicyAnnotationKeyPrefix + network.TLSALPNProtocolsKey:      "h2,http/1.1",
+		}),
+		want: &netv1alpha1.IngressTLSPolicy{
+			MinProtocolVersion: netv1alpha1.TLSProtocolVersion13,
+			CipherSuites:       []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+			ALPNProtocols:      []string{"h2", "http/1.1"},
+		},
+	}, {
+		name:  "invalid namespace override",
+		cfg:   cfg,
+		route: public,
+		ns: namespace(map[string]string{
+			networking.TLSPolicyAnnotationKeyPrefix + network.TLSMinProtocolVersionKey: "SSLV3",
+		}),
+		wantErr: true,
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := MakeIngressTLSPolicy(c.cfg, c.route, c.ns)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("MakeIngressTLSPolicy() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if !cmp.Equal(c.want, got) {
+				t.Errorf("Unexpected policy (-want, +got): %s", cmp.Diff(c.want, got))
+			}
+		})
+	}
+}
+
 func TestGetRouteDomains_NamelessTargetDup(t *testing.T) {
 	const base = "test-route.test-ns"
 	r := &v1alpha1.Route{
@@ -448,6 +540,117 @@ func TestMakeClusterIngressRule_TwoTargets(t *testing.T) {
 	}
 }
 
+// Targets with cache headers.
+func TestMakeClusterIngressRule_CacheHeaders(t *testing.T) {
+	targets := []traffic.RevisionTarget{{
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "revision",
+			Percent:           0,
+			CacheControl:      "no-store",
+		},
+		ServiceName: "nigh",
+		Active:      true,
+	}, {
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "revision",
+			Percent:           80,
+			SurrogateControl:  "max-age=3600",
+		},
+		ServiceName: "nigh",
+		Active:      true,
+	}, {
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "new-config",
+			RevisionName:      "new-revision",
+			Percent:           20,
+			CacheControl:      "public, max-age=60",
+			SurrogateControl:  "max-age=60",
+		},
+		ServiceName: "death",
+		Active:      true,
+	}}
+	domains := []string{"test.org"}
+	rule := makeIngressRule(domains, ns, targets)
+	expected := netv1alpha1.IngressRule{
+		Hosts: []string{"test.org"},
+		HTTP: &netv1alpha1.HTTPIngressRuleValue{
+			Paths: []netv1alpha1.HTTPIngressPath{{
+				Splits: []netv1alpha1.IngressBackendSplit{{
+					IngressBackend: netv1alpha1.IngressBackend{
+						ServiceNamespace: "test-ns",
+						ServiceName:      "nigh",
+						ServicePort:      intstr.FromInt(80),
+					},
+					Percent: 80,
+				}, {
+					IngressBackend: netv1alpha1.IngressBackend{
+						ServiceNamespace: "test-ns",
+						ServiceName:      "death",
+						ServicePort:      intstr.FromInt(80),
+					},
+					Percent: 20,
+				}},
+				AppendHeaders: map[string]string{
+					"Knative-Serving-Revision":    "revision",
+					"Knative-Serving-Namespace":   "test-ns",
+					"K-Default-Cache-Control":     "public, max-age=60",
+					"K-Default-Surrogate-Control": "max-age=3600",
+				},
+			}},
+		},
+	}
+
+	if !cmp.Equal(&expected, rule) {
+		t.Errorf("Unexpected rule (-want, +got): %s", cmp.Diff(&expected, rule))
+	}
+}
+
+func TestMakeClusterIngressRule_Timeout(t *testing.T) {
+	targets := []traffic.RevisionTarget{{
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "revision",
+			Percent:           80,
+		},
+		ServiceName:    "nigh",
+		Active:         true,
+		TimeoutSeconds: 30,
+	}, {
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "new-config",
+			RevisionName:      "new-revision",
+			Percent:           20,
+		},
+		ServiceName:    "death",
+		Active:         true,
+		TimeoutSeconds: 60,
+	}, {
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: "new-config",
+			RevisionName:      "newer-revision",
+			Percent:           0,
+		},
+		ServiceName:    "death",
+		Active:         true,
+		TimeoutSeconds: 600,
+	}}
+	domains := []string{"test.org"}
+	rule := makeIngressRule(domains, ns, targets)
+	// The ingress waits as long as the slowest revision receiving traffic.
+	if got, want := rule.HTTP.Paths[0].Timeout, (&metav1.Duration{Duration: time.Minute}); !cmp.Equal(got, want) {
+		t.Errorf("Timeout = %v, want: %v", got, want)
+	}
+
+	// A revision without timeout leaves the default to the ingress.
+	targets[0].TimeoutSeconds = 0
+	rule = makeIngressRule(domains, ns, targets)
+	if got := rule.HTTP.Paths[0].Timeout; got != nil {
+		t.Errorf("Timeout = %v, want: nil", got)
+	}
+}
+
 // Inactive target.
 func TestMakeClusterIngressRule_InactiveTarget(t *testing.T) {
 	targets := []traffic.RevisionTarget{{
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// AsyncChecker runs the checks of Steps in the background, so that slow or
+// unresponsive gates don't hold up the reconciliation of Routes. Once a
+// check is done, the Route is enqueued to pick up its Decision.
+type AsyncChecker struct {
+	enqueue func(key string)
+
+	mux    sync.Mutex
+	checks map[checkKey]*asyncCheck
+}
+
+// checkKey identifies the check of the shift of a Route's traffic towards
+// a revision.
+type checkKey struct {
+	route    string
+	revision string
+}
+
+type asyncCheck struct {
+	// step identifies the Step being checked, as the traffic of the Route
+	// may change while it is.
+	step     string
+	done     bool
+	decision *Decision
+	err      error
+}
+
+// NewAsyncChecker creates an AsyncChecker that calls enqueue with the key
+// of the Route whenever the check of one of its Steps is done.
+func NewAsyncChecker(enqueue func(key string)) *AsyncChecker {
+	return &AsyncChecker{
+		enqueue: enqueue,
+		checks:  make(map[checkKey]*asyncCheck),
+	}
+}
+
+// Check returns the Decision on the Step of the Route with the given key,
+// and whether the check is done. The first call for a Step starts check in
+// the background. Its result is returned once, after which the next call
+// checks the Step anew.
+func (a *AsyncChecker) Check(key string, step *Step, check func(context.Context) (*Decision, error)) (*Decision, bool, error) {
+	ck := checkKey{route: key, revision: step.Revision}
+	id := fmt.Sprintf("%d->%d %v", step.CurrentPercent, step.TargetPercent, step.Baseline)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if c, ok := a.checks[ck]; ok && c.step == id {
+		if !c.done {
+			return nil, false, nil
+		}
+		delete(a.checks, ck)
+		return c.decision, true, c.err
+	}
+
+	c := &asyncCheck{step: id}
+	a.checks[ck] = c
+	go func() {
+		d, err := check(context.Background())
+
+		a.mux.Lock()
+		// The Step may have been superseded while it was checked.
+		current := a.checks[ck] == c
+		if current {
+			c.done, c.decision, c.err = true, d, err
+		}
+		a.mux.Unlock()
+		if current {
+			a.enqueue(key)
+		}
+	}()
+	return nil, false, nil
+}
+
+// Forget drops the checks of the Route with the given key, e.g. once it is
+// deleted.
+func (a *AsyncChecker) Forget(key string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for ck := range a.checks {
+		if ck.route == key {
+			delete(a.checks, ck)
+		}
+	}
+}
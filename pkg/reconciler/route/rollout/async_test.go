@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAsyncChecker(t *testing.T) {
+	enqueued := make(chan string, 1)
+	a := NewAsyncChecker(func(key string) { enqueued <- key })
+	step := &Step{Revision: "canary", Baseline: []string{"stable"}, CurrentPercent: 10, TargetPercent: 50}
+
+	release := make(chan struct{})
+	calls := 0
+	check := func(context.Context) (*Decision, error) {
+		calls++
+		<-release
+		return &Decision{Reason: "nope"}, nil
+	}
+
+	if _, done, _ := a.Check("ns/route", step, check); done {
+		t.Fatal("Check() is done before the gates decided")
+	}
+	// Checking again while the gates decide doesn't start another check.
+	if _, done, _ := a.Check("ns/route", step, check); done {
+		t.Fatal("Check() is done before the gates decided")
+	}
+	close(release)
+
+	select {
+	case key := <-enqueued:
+		if key != "ns/route" {
+			t.Errorf("Enqueued %q, want: ns/route", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The route wasn't enqueued once the check was done")
+	}
+	d, done, err := a.Check("ns/route", step, check)
+	if !done || err != nil {
+		t.Fatalf("Check() = %v, %v, want done", done, err)
+	}
+	if diff := cmp.Diff(&Decision{Reason: "nope"}, d); diff != "" {
+		t.Errorf("Decision (-want, +got) = %v", diff)
+	}
+	if calls != 1 {
+		t.Errorf("The gates were called %d times, want: 1", calls)
+	}
+
+	// The decision is only returned once.
+	if _, done, _ := a.Check("ns/route", step, check); done {
+		t.Error("Check() returned the same decision twice")
+	}
+	<-enqueued
+}
+
+func TestAsyncCheckerSuperseded(t *testing.T) {
+	enqueued := make(chan string, 2)
+	a := NewAsyncChecker(func(key string) { enqueued <- key })
+
+	release := make(chan struct{})
+	slow := func(context.Context) (*Decision, error) {
+		<-release
+		return &Decision{Approved: true}, nil
+	}
+	a.Check("ns/route", &Step{Revision: "canary", TargetPercent: 50}, slow)
+
+	// The traffic changed while the gates decided, so the stale decision is
+	// dropped.
+	step := &Step{Revision: "canary", TargetPercent: 100}
+	a.Check("ns/route", step, func(context.Context) (*Decision, error) {
+		return nil, errors.New("gate unavailable")
+	})
+	<-enqueued
+	close(release)
+
+	if _, done, err := a.Check("ns/route", step, slow); !done || err == nil {
+		t.Errorf("Check() = %v, %v, want the error of the current step", done, err)
+	}
+}
+
+func TestAsyncCheckerForget(t *testing.T) {
+	a := NewAsyncChecker(func(string) {})
+	step := &Step{Revision: "canary"}
+	a.Check("ns/route", step, func(context.Context) (*Decision, error) {
+		return &Decision{Approved: true}, nil
+	})
+	a.Forget("ns/route")
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if len(a.checks) != 0 {
+		t.Errorf("checks = %v, want none", a.checks)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/knative/serving/pkg/apis/serving"
+)
+
+// Metrics summarizes the requests a revision served during the analysis
+// window.
+type Metrics struct {
+	RequestCount float64 `json:"requestCount"`
+	ErrorCount   float64 `json:"errorCount"`
+}
+
+// ErrorRate returns the fraction of the requests that failed, or 0 if
+// there were no requests.
+func (m *Metrics) ErrorRate() float64 {
+	if m.RequestCount <= 0 {
+		return 0
+	}
+	return m.ErrorCount / m.RequestCount
+}
+
+// Step describes a shift of a Route's traffic towards a revision.
+type Step struct {
+	Namespace string `json:"namespace"`
+	Route     string `json:"route"`
+	// Revision is the revision whose share of the traffic grows.
+	Revision string `json:"revision"`
+	// Baseline are the other revisions currently receiving traffic.
+	Baseline       []string `json:"baseline"`
+	CurrentPercent int      `json:"currentPercent"`
+	TargetPercent  int      `json:"targetPercent"`
+	// Metrics holds the metrics of the revision and of the baseline,
+	// keyed by revision name. It is empty when no MetricsSource is
+	// configured.
+	Metrics map[string]*Metrics `json:"metrics,omitempty"`
+}
+
+// Decision is the verdict of a Gate on a Step.
+type Decision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Gate approves or denies the Steps of a rollout.
+type Gate interface {
+	Check(ctx context.Context, step *Step) (*Decision, error)
+}
+
+// MetricsSource provides the Metrics of revisions.
+type MetricsSource interface {
+	RevisionMetrics(ctx context.Context, namespace, revision string) (*Metrics, error)
+}
+
+// GatesFromAnnotations returns the Gates requested by the annotations of a
+// Route. Invalid values are rejected by the webhook, so they are ignored
+// here. Gate URLs that don't start with one of allowedURLs deny every Step.
+func GatesFromAnnotations(annotations map[string]string, client *http.Client, allowedURLs []string) []Gate {
+	var gates []Gate
+	if v, ok := annotations[serving.RolloutMaxErrorRateAnnotationKey]; ok {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 && rate <= 1 {
+			gates = append(gates, &ErrorRateGate{MaxErrorRate: rate})
+		}
+	}
+	if url := strings.TrimSpace(annotations[serving.RolloutGateURLAnnotationKey]); url != "" {
+		if URLAllowed(url, allowedURLs) {
+			gates = append(gates, &WebhookGate{URL: url, Client: client})
+		} else {
+			gates = append(gates, &DenyGate{
+				Reason: fmt.Sprintf("rollout gate %s is not in the allowed-gate-urls of config-rollout", url),
+			})
+		}
+	}
+	return gates
+}
+
+// Check runs the Step through all the gates, and returns the first denial,
+// or an approval if all of them approve.
+func Check(ctx context.Context, gates []Gate, step *Step) (*Decision, error) {
+	for _, g := range gates {
+		d, err := g.Check(ctx, step)
+		if err != nil {
+			return nil, err
+		}
+		if !d.Approved {
+			return d, nil
+		}
+	}
+	return &Decision{Approved: true}, nil
+}
+
+// DenyGate denies every Step.
+type DenyGate struct {
+	Reason string
+}
+
+var _ Gate = (*DenyGate)(nil)
+
+// Check implements Gate.
+func (g *DenyGate) Check(context.Context, *Step) (*Decision, error) {
+	return &Decision{Reason: g.Reason}, nil
+}
+
+// ErrorRateGate denies the Steps towards a revision whose error rate is
+// above MaxErrorRate. Revisions without Metrics or without requests are
+// approved, since there is nothing to judge them on yet.
+type ErrorRateGate struct {
+	MaxErrorRate float64
+}
+
+var _ Gate = (*ErrorRateGate)(nil)
+
+// Check implements Gate.
+func (g *ErrorRateGate) Check(_ context.Context, step *Step) (*Decision, error) {
+	m := step.Metrics[step.Revision]
+	if m == nil || m.RequestCount <= 0 {
+		return &Decision{Approved: true, Reason: "no requests to analyze"}, nil
+	}
+	if rate := m.ErrorRate(); rate > g.MaxErrorRate {
+		return &Decision{
+			Reason: fmt.Sprintf("error rate %.4f of revision %s exceeds %.4f", rate, step.Revision, g.MaxErrorRate),
+		}, nil
+	}
+	return &Decision{Approved: true}, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/knative/serving/pkg/apis/serving"
+)
+
+type fakeGate struct {
+	decision *Decision
+	err      error
+	called   bool
+}
+
+func (g *fakeGate) Check(context.Context, *Step) (*Decision, error) {
+	g.called = true
+	return g.decision, g.err
+}
+
+func TestGatesFromAnnotations(t *testing.T) {
+	client := &http.Client{}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []Gate
+	}{{
+		name: "no gates",
+	}, {
+		name: "error rate gate",
+		annotations: map[string]string{
+			serving.RolloutMaxErrorRateAnnotationKey: "0.05",
+		},
+		want: []Gate{&ErrorRateGate{MaxErrorRate: 0.05}},
+	}, {
+		name: "invalid error rate",
+		annotations: map[string]string{
+			serving.RolloutMaxErrorRateAnnotationKey: "lots",
+		},
+	}, {
+		name: "both gates",
+		annotations: map[string]string{
+			serving.RolloutMaxErrorRateAnnotationKey: "0.1",
+			serving.RolloutGateURLAnnotationKey:      "http://analysis.svc/check",
+		},
+		want: []Gate{
+			&ErrorRateGate{MaxErrorRate: 0.1},
+			&WebhookGate{URL: "http://analysis.svc/check", Client: client},
+		},
+	}, {
+		name: "gate url not allowed",
+		annotations: map[string]string{
+			serving.RolloutGateURLAnnotationKey: "http://metadata.google.internal/computeMetadata/v1/",
+		},
+		want: []Gate{&DenyGate{
+			Reason: "rollout gate http://metadata.google.internal/computeMetadata/v1/ is not in the allowed-gate-urls of config-rollout",
+		}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := GatesFromAnnotations(test.annotations, client, []string{"http://analysis.svc/"})
+			if diff := cmp.Diff(test.want, got, cmp.Comparer(func(a, b *http.Client) bool {
+				return a == b
+			})); diff != "" {
+				t.Errorf("GatesFromAnnotations() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	approve := &Decision{Approved: true}
+	deny := &Decision{Reason: "nope"}
+
+	tests := []struct {
+		name    string
+		gates   []*fakeGate
+		want    *Decision
+		wantErr bool
+		skipped int
+	}{{
+		name: "no gates",
+		want: approve,
+	}, {
+		name:  "all approve",
+		gates: []*fakeGate{{decision: approve}, {decision: approve}},
+		want:  approve,
+	}, {
+		name:    "first denial wins",
+		gates:   []*fakeGate{{decision: deny}, {decision: approve}},
+		want:    deny,
+		skipped: 1,
+	}, {
+		name:    "error",
+		gates:   []*fakeGate{{err: errors.New("boom")}, {decision: approve}},
+		wantErr: true,
+		skipped: 1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gates := make([]Gate, 0, len(test.gates))
+			for _, g := range test.gates {
+				gates = append(gates, g)
+			}
+			got, err := Check(context.Background(), gates, &Step{})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Check() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Check() (-want, +got) = %v", diff)
+			}
+			skipped := 0
+			for _, g := range test.gates {
+				if !g.called {
+					skipped++
+				}
+			}
+			if skipped != test.skipped {
+				t.Errorf("Skipped %d gates, want: %d", skipped, test.skipped)
+			}
+		})
+	}
+}
+
+func TestErrorRateGate(t *testing.T) {
+	gate := &ErrorRateGate{MaxErrorRate: 0.1}
+	tests := []struct {
+		name    string
+		metrics map[string]*Metrics
+		want    bool
+	}{{
+		name: "no metrics",
+		want: true,
+	}, {
+		name:    "no requests",
+		metrics: map[string]*Metrics{"canary": {}},
+		want:    true,
+	}, {
+		name:    "below threshold",
+		metrics: map[string]*Metrics{"canary": {RequestCount: 100, ErrorCount: 5}},
+		want:    true,
+	}, {
+		name:    "above threshold",
+		metrics: map[string]*Metrics{"canary": {RequestCount: 100, ErrorCount: 20}},
+	}, {
+		name: "only the baseline fails",
+		metrics: map[string]*Metrics{
+			"canary": {RequestCount: 100},
+			"stable": {RequestCount: 100, ErrorCount: 100},
+		},
+		want: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := gate.Check(context.Background(), &Step{
+				Revision: "canary",
+				Baseline: []string{"stable"},
+				Metrics:  test.metrics,
+			})
+			if err != nil {
+				t.Fatalf("Check() = %v", err)
+			}
+			if d.Approved != test.want {
+				t.Errorf("Approved = %v, want: %v (reason: %s)", d.Approved, test.want, d.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	requestCountQuery = `sum(increase(revision_request_count{namespace_name=%q,revision_name=%q}[%s]))`
+	errorCountQuery   = `sum(increase(revision_request_count{namespace_name=%q,revision_name=%q,response_code_class="5xx"}[%s]))`
+)
+
+// PrometheusSource reads the Metrics of revisions from the request counts
+// the queue-proxies export to Prometheus.
+type PrometheusSource struct {
+	// URL is the base URL of the Prometheus server.
+	URL string
+	// Window is the duration over which the requests are counted.
+	Window time.Duration
+	Client *http.Client
+}
+
+var _ MetricsSource = (*PrometheusSource)(nil)
+
+// RevisionMetrics implements MetricsSource.
+func (p *PrometheusSource) RevisionMetrics(ctx context.Context, namespace, revision string) (*Metrics, error) {
+	window := fmt.Sprintf("%ds", int(p.Window.Seconds()))
+	requests, err := p.query(ctx, fmt.Sprintf(requestCountQuery, namespace, revision, window))
+	if err != nil {
+		return nil, err
+	}
+	failures, err := p.query(ctx, fmt.Sprintf(errorCountQuery, namespace, revision, window))
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{RequestCount: requests, ErrorCount: failures}, nil
+}
+
+// promResponse is the subset of the Prometheus instant query response we use.
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Value [2]interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// query runs an instant query returning a single scalar, where an empty
+// result means 0.
+func (p *PrometheusSource) query(ctx context.Context, q string) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, p.URL+"/api/v1/query?query="+url.QueryEscape(q), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+
+	pr := &promResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(pr); err != nil {
+		return 0, fmt.Errorf("failed to decode Prometheus response: %v", err)
+	}
+	if pr.Status != "success" {
+		return 0, fmt.Errorf("Prometheus query %q failed: %s", q, pr.Error)
+	}
+	if len(pr.Data.Result) == 0 {
+		return 0, nil
+	}
+	s, ok := pr.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected value in Prometheus response: %v", pr.Data.Result[0].Value[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrometheusSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests string
+		errors   string
+		status   string
+		want     *Metrics
+		wantErr  bool
+	}{{
+		name:     "requests and errors",
+		requests: `[{"metric": {}, "value": [1560000000, "200"]}]`,
+		errors:   `[{"metric": {}, "value": [1560000000, "3.5"]}]`,
+		status:   "success",
+		want:     &Metrics{RequestCount: 200, ErrorCount: 3.5},
+	}, {
+		name:     "no data",
+		requests: `[]`,
+		errors:   `[]`,
+		status:   "success",
+		want:     &Metrics{},
+	}, {
+		name:     "query error",
+		requests: `[]`,
+		errors:   `[]`,
+		status:   "error",
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query().Get("query")
+				if !strings.Contains(q, `namespace_name="default",revision_name="canary"`) || !strings.Contains(q, "[60s]") {
+					t.Errorf("Unexpected query: %s", q)
+				}
+				result := test.requests
+				if strings.Contains(q, "5xx") {
+					result = test.errors
+				}
+				fmt.Fprintf(w, `{"status": %q, "data": {"resultType": "vector", "result": %s}}`, test.status, result)
+			}))
+			defer server.Close()
+
+			source := &PrometheusSource{URL: server.URL, Window: time.Minute, Client: server.Client()}
+			got, err := source.RevisionMetrics(context.Background(), "default", "canary")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RevisionMetrics() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("RevisionMetrics() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
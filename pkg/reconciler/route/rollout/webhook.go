@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxDecisionBytes bounds the size of the Decisions read from the gates.
+const maxDecisionBytes = 64 << 10
+
+// URLAllowed returns whether the URL starts with one of the allowed URL
+// prefixes. The scheme and host have to match exactly, and the path has to
+// be the allowed one or below it.
+func URLAllowed(raw string, allowed []string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil {
+		return false
+	}
+	p := path.Clean("/" + u.Path)
+	for _, a := range allowed {
+		au, err := url.Parse(a)
+		if err != nil || !strings.EqualFold(au.Scheme, u.Scheme) || !strings.EqualFold(au.Host, u.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(path.Clean("/"+au.Path), "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") || prefix == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookGate delegates the Decision to an external analysis service. The
+// Step is POSTed as JSON to URL, which must answer with a JSON Decision.
+type WebhookGate struct {
+	URL    string
+	Client *http.Client
+}
+
+var _ Gate = (*WebhookGate)(nil)
+
+// Check implements Gate.
+func (g *WebhookGate) Check(ctx context.Context, step *Step) (*Decision, error) {
+	body, err := json.Marshal(step)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call rollout gate %s: %v", g.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rollout gate %s returned status %d", g.URL, resp.StatusCode)
+	}
+
+	d := &Decision{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDecisionBytes)).Decode(d); err != nil {
+		return nil, fmt.Errorf("failed to decode the decision of rollout gate %s: %v", g.URL, err)
+	}
+	return d, nil
+}
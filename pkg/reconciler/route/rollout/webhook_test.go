@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWebhookGate(t *testing.T) {
+	step := &Step{
+		Namespace:      "default",
+		Route:          "route",
+		Revision:       "canary",
+		Baseline:       []string{"stable"},
+		CurrentPercent: 10,
+		TargetPercent:  50,
+		Metrics: map[string]*Metrics{
+			"canary": {RequestCount: 10, ErrorCount: 1},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    *Decision
+		wantErr bool
+	}{{
+		name: "approved",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			got := &Step{}
+			if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+				t.Errorf("Failed to decode the step: %v", err)
+			}
+			if diff := cmp.Diff(step, got); diff != "" {
+				t.Errorf("Step (-want, +got) = %v", diff)
+			}
+			w.Write([]byte(`{"approved": true}`))
+		},
+		want: &Decision{Approved: true},
+	}, {
+		name: "denied",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"approved": false, "reason": "latency regression"}`))
+		},
+		want: &Decision{Reason: "latency regression"},
+	}, {
+		name: "server error",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		wantErr: true,
+	}, {
+		name: "oversized",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"approved": true, "reason": "` + strings.Repeat("x", maxDecisionBytes) + `"}`))
+		},
+		wantErr: true,
+	}, {
+		name: "garbage",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("yes"))
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.handler)
+			defer server.Close()
+
+			gate := &WebhookGate{URL: server.URL, Client: server.Client()}
+			got, err := gate.Check(context.Background(), step)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Check() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Check() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestURLAllowed(t *testing.T) {
+	allowed := []string{"http://analysis.default.svc/check", "https://gates.example.com"}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"http://analysis.default.svc/check", true},
+		{"http://analysis.default.svc/check/canary", true},
+		{"http://Analysis.Default.svc/check", true},
+		{"http://analysis.default.svc/checkout", false},
+		{"http://analysis.default.svc/check/../admin", false},
+		{"https://analysis.default.svc/check", false},
+		{"http://analysis.default.svc:8080/check", false},
+		{"http://user@analysis.default.svc/check", false},
+		{"https://gates.example.com/anything", true},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"::", false},
+	}
+
+	for _, test := range tests {
+		if got := URLAllowed(test.url, allowed); got != test.want {
+			t.Errorf("URLAllowed(%q) = %v, want: %v", test.url, got, test.want)
+		}
+	}
+	if URLAllowed("http://analysis.default.svc/check", nil) {
+		t.Error("URLAllowed() = true without any allowed URLs")
+	}
+}
@@ -19,6 +19,7 @@ package route
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
@@ -34,6 +35,7 @@ import (
 	netv1alpha1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
+	kpalisters "github.com/knative/serving/pkg/client/listers/autoscaling/v1alpha1"
 	networkinglisters "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
 	listers "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
 	"github.com/knative/serving/pkg/reconciler"
@@ -69,13 +71,17 @@ type Reconciler struct {
 	routeLister          listers.RouteLister
 	configurationLister  listers.ConfigurationLister
 	revisionLister       listers.RevisionLister
+	podAutoscalerLister  kpalisters.PodAutoscalerLister
 	serviceLister        corev1listers.ServiceLister
 	clusterIngressLister networkinglisters.ClusterIngressLister
 	certificateLister    networkinglisters.CertificateLister
+	namespaceLister      corev1listers.NamespaceLister
 	configStore          reconciler.ConfigStore
 	tracker              tracker.Interface
 
-	clock system.Clock
+	clock        system.Clock
+	enqueueAfter func(interface{}, time.Duration)
+	gateChecks   gateChecker
 }
 
 // Check that our Reconciler implements controller.Reconciler
@@ -100,6 +106,7 @@ func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
 	if apierrs.IsNotFound(err) {
 		// The resource may no longer exist, in which case we stop processing.
 		logger.Errorf("route %q in work queue no longer exists", key)
+		c.gateChecks.Forget(key)
 		return nil
 	} else if err != nil {
 		return err
@@ -180,12 +187,21 @@ func (c *Reconciler) reconcile(ctx context.Context, r *v1alpha1.Route) error {
 	r.Status.DeprecatedDomain = ""
 
 	// Configure traffic based on the RouteSpec.
+	prevTraffic := r.Status.Traffic
 	traffic, err := c.configureTraffic(ctx, r)
 	if traffic == nil || err != nil {
 		// Traffic targets aren't ready, no need to configure child resources.
 		return err
 	}
 
+	c.reportRollbacks(prevTraffic, traffic, r)
+
+	// Let the rollout gates, if any, approve the traffic shifts.
+	traffic, err = c.gateRollout(ctx, prevTraffic, traffic, r)
+	if err != nil {
+		return err
+	}
+
 	logger.Info("Updating targeted revisions.")
 	// In all cases we will add annotations to the referred targets.  This is so that when they become
 	// routable we can know (through a listener) and attempt traffic configuration again.
@@ -193,6 +209,16 @@ func (c *Reconciler) reconcile(ctx context.Context, r *v1alpha1.Route) error {
 		return err
 	}
 
+	// Let the autoscaler scale up revisions that are about to receive more
+	// traffic, and hold the traffic until it did before the ClusterIngress
+	// shifts it to them.
+	if !c.reconcilePreScale(ctx, prevTraffic, traffic, r) {
+		c.enqueueAfter(r, preScaleRecheckInterval)
+		if traffic, err = c.holdTraffic(ctx, prevTraffic, traffic, r); err != nil {
+			return err
+		}
+	}
+
 	// TODO(mattmoor): Remove completely after 0.7 cuts.
 	r.Status.DeprecatedDomainInternal = ""
 
@@ -228,6 +254,11 @@ func (c *Reconciler) reconcile(ctx context.Context, r *v1alpha1.Route) error {
 	if err != nil {
 		return err
 	}
+	// A missing Namespace simply means there are no Namespace level overrides.
+	ns, _ := c.namespaceLister.Get(r.Namespace)
+	if desired.Spec.TLSPolicy, err = resources.MakeIngressTLSPolicy(config.FromContext(ctx).Network, r, ns); err != nil {
+		return err
+	}
 	clusterIngress, err := c.reconcileClusterIngress(ctx, r, desired)
 	if err != nil {
 		return err
@@ -364,6 +395,21 @@ func (c *Reconciler) configureTraffic(ctx context.Context, r *v1alpha1.Route) (*
 	return t, nil
 }
 
+// reportRollbacks emits an event for every Configuration whose traffic is
+// being shifted away from its failed latest ready Revision.
+func (c *Reconciler) reportRollbacks(prev []v1alpha1.TrafficTarget, t *tr.Config, r *v1alpha1.Route) {
+	for _, rb := range t.Rollbacks {
+		for _, tt := range prev {
+			if tt.RevisionName == rb.From {
+				c.Recorder.Eventf(r, corev1.EventTypeWarning, "RolledBack",
+					"Shifted the traffic of Configuration %q from failed revision %q back to %q",
+					rb.Configuration, rb.From, rb.To)
+				break
+			}
+		}
+	}
+}
+
 func (c *Reconciler) ensureFinalizer(route *v1alpha1.Route) error {
 	finalizers := sets.NewString(route.Finalizers...)
 	if finalizers.Has(routeFinalizer) {
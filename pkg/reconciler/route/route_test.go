@@ -24,8 +24,10 @@ import (
 	"time"
 
 	// Inject the informers this controller depends on.
+	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace/fake"
 	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/service/fake"
 	fakeservingclient "github.com/knative/serving/pkg/client/injection/client/fake"
+	_ "github.com/knative/serving/pkg/client/injection/informers/autoscaling/v1alpha1/podautoscaler/fake"
 	_ "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/certificate/fake"
 	fakeciinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/clusteringress/fake"
 	fakecfginformer "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/configuration/fake"
@@ -195,6 +197,12 @@ func newTestSetup(t *testing.T, configs ...*corev1.ConfigMap) (
 			Namespace: system.Namespace(),
 		},
 		Data: map[string]string{},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.RolloutConfigName,
+			Namespace: system.Namespace(),
+		},
+		Data: map[string]string{},
 	}}, configs...)
 
 	for _, cfg := range cms {
@@ -19,6 +19,9 @@ package route
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -36,6 +39,8 @@ import (
 	"knative.dev/pkg/kmeta"
 	logtesting "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	av1alpha1 "github.com/knative/serving/pkg/apis/autoscaling/v1alpha1"
 	netv1alpha1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
@@ -45,6 +50,7 @@ import (
 	"github.com/knative/serving/pkg/reconciler"
 	"github.com/knative/serving/pkg/reconciler/route/config"
 	"github.com/knative/serving/pkg/reconciler/route/resources"
+	"github.com/knative/serving/pkg/reconciler/route/rollout"
 	"github.com/knative/serving/pkg/reconciler/route/traffic"
 
 	. "knative.dev/pkg/reconciler/testing"
@@ -59,6 +65,12 @@ var fakeCurTime = time.Unix(1e9, 0)
 
 // This is heavily based on the way the OpenShift Ingress controller tests its reconciliation method.
 func TestReconcile(t *testing.T) {
+	// A rollout gate denying every traffic shift.
+	denyingGate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"approved": false, "reason": "error budget exhausted"}`))
+	}))
+	defer denyingGate.Close()
+
 	table := TableTest{{
 		Name: "bad workqueue key",
 		// Make sure Reconcile handles bad keys.
@@ -721,6 +733,413 @@ func TestReconcile(t *testing.T) {
 		}},
 		Key:                     "default/new-latest-ready",
 		SkipNamespaceValidation: true,
+	}, {
+		Name: "new latest ready revision is held by a rollout gate",
+		Objects: []runtime.Object{
+			route("default", "held-rollout", WithConfigTarget("config"),
+				WithRouteAnnotation(serving.RolloutGateURLAnnotationKey, denyingGate.URL),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName: "config-00001",
+							Percent:      100,
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				// The Route controller attaches our label to this Configuration.
+				WithConfigLabel("serving.knative.dev/route", "held-rollout"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia")),
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown")),
+			simplePA("default", "config-00002"),
+			simpleReadyIngress(
+				route("default", "held-rollout", WithConfigTarget("config"), WithURL,
+					WithRouteAnnotation(serving.RolloutGateURLAnnotationKey, denyingGate.URL)),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "held-rollout", WithConfigTarget("config"))),
+		},
+		// The gate denies the shift to the new revision, so the Route keeps
+		// sending all of its traffic to the previous one.
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "RolloutHeld",
+				"Holding the shift of revision %q from %d%% to %d%% of the traffic: %s",
+				"config-00002", 0, 100, "error budget exhausted"),
+		},
+		Key:                     "default/held-rollout",
+		SkipNamespaceValidation: true,
+	}, {
+		Name: "new latest ready revision is pre-scaled",
+		Objects: []runtime.Object{
+			route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName: "config-00001",
+							Percent:      100,
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				// The Route controller attaches our label to this Configuration.
+				WithConfigLabel("serving.knative.dev/route", "new-latest-ready"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia")),
+			// This is the name of the new revision we're referencing above.
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown")),
+			simplePA("default", "config-00002"),
+			simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// Use the Revision name from the config.
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"))),
+		},
+		// The new revision's PodAutoscaler learns about the traffic it is about
+		// to receive, and the traffic is held until it is scaled up.
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchPreScale("default", "config-00002", 100, "config-00001"),
+		},
+		Key:                     "default/new-latest-ready",
+		SkipNamespaceValidation: true,
+	}, {
+		Name: "pre-scaled revision takes the traffic",
+		Objects: []runtime.Object{
+			route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName: "config-00001",
+							Percent:      100,
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				// The Route controller attaches our label to this Configuration.
+				WithConfigLabel("serving.knative.dev/route", "new-latest-ready"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia")),
+			// This is the name of the new revision we're referencing above.
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown")),
+			preScaledPA("default", "config-00002", 100, "config-00001"),
+			simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// Use the Revision name from the config.
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"))),
+		},
+		// A new LatestReadyRevisionName on the Configuration should result in the new Revision being rolled out.
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// This is the new config we're making become ready.
+								RevisionName: "config-00002",
+								Percent:      100,
+							},
+							ServiceName: "belltown",
+							Active:      true,
+						}},
+					},
+				},
+			),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName:   "config-00002",
+							Percent:        100,
+							LatestRevision: ptr.Bool(true),
+						},
+					})),
+		}},
+		Key:                     "default/new-latest-ready",
+		SkipNamespaceValidation: true,
+	}, {
+		Name: "revision is not pre-scaled without the annotation",
+		Objects: []runtime.Object{
+			route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName: "config-00001",
+							Percent:      100,
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				// The Route controller attaches our label to this Configuration.
+				WithConfigLabel("serving.knative.dev/route", "new-latest-ready"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia")),
+			// This is the name of the new revision we're referencing above.
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown")),
+			simplePA("default", "config-00002"),
+			simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// Use the Revision name from the config.
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "new-latest-ready", WithConfigTarget("config"))),
+		},
+		// The Route didn't opt in to pre-scaling, so the traffic shifts at once.
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// This is the new config we're making become ready.
+								RevisionName: "config-00002",
+								Percent:      100,
+							},
+							ServiceName: "belltown",
+							Active:      true,
+						}},
+					},
+				},
+			),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName:   "config-00002",
+							Percent:        100,
+							LatestRevision: ptr.Bool(true),
+						},
+					})),
+		}},
+		Key:                     "default/new-latest-ready",
+		SkipNamespaceValidation: true,
+	}, {
+		Name: "HPA-class revision is not pre-scaled",
+		Objects: []runtime.Object{
+			route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName: "config-00001",
+							Percent:      100,
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				// The Route controller attaches our label to this Configuration.
+				WithConfigLabel("serving.knative.dev/route", "new-latest-ready"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia")),
+			// This is the name of the new revision we're referencing above.
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown")),
+			hpaPA("default", "config-00002"),
+			simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// Use the Revision name from the config.
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"))),
+		},
+		// Only the KPA reports when a revision is pre-scaled, so the traffic
+		// shifts at once.
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: simpleReadyIngress(
+				route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"), WithURL),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								// This is the new config we're making become ready.
+								RevisionName: "config-00002",
+								Percent:      100,
+							},
+							ServiceName: "belltown",
+							Active:      true,
+						}},
+					},
+				},
+			),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route("default", "new-latest-ready", WithConfigTarget("config"),
+				WithRouteAnnotation(autoscaling.PreScaleAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName:   "config-00002",
+							Percent:        100,
+							LatestRevision: ptr.Bool(true),
+						},
+					})),
+		}},
+		Key:                     "default/new-latest-ready",
+		SkipNamespaceValidation: true,
+	}, {
+		Name: "failed latest ready revision is rolled back",
+		Objects: []runtime.Object{
+			route("default", "rollback", WithConfigTarget("config"),
+				WithRouteAnnotation(serving.RollbackOnFailureAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName:   "config-00002",
+							Percent:        100,
+							LatestRevision: ptr.Bool(true),
+						},
+					})),
+			cfg("default", "config",
+				WithGeneration(2), WithLatestCreated("config-00002"), WithLatestReady("config-00002"),
+				WithConfigLabel("serving.knative.dev/route", "rollback"),
+			),
+			rev("default", "config", 1, MarkRevisionReady, WithRevName("config-00001"), WithServiceName("magnolia"),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "config"),
+				WithRevisionLabel(serving.ConfigurationGenerationLabelKey, "1")),
+			// The latest ready revision started crashing after it was promoted.
+			rev("default", "config", 2, MarkRevisionReady, WithRevName("config-00002"), WithServiceName("belltown"),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "config"),
+				WithRevisionLabel(serving.ConfigurationGenerationLabelKey, "2"),
+				func(r *v1alpha1.Revision) { r.Status.MarkContainerExiting(1, "Crashed") }),
+			simpleReadyIngress(
+				route("default", "rollback", WithConfigTarget("config"), WithURL,
+					WithRouteAnnotation(serving.RollbackOnFailureAnnotationKey, "true")),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								RevisionName: "config-00002",
+								Percent:      100,
+							},
+							ServiceName: "belltown",
+							Active:      true,
+						}},
+					},
+				},
+			),
+			simpleK8sService(route("default", "rollback", WithConfigTarget("config"))),
+		},
+		// Traffic moves back to the last ready revision of the Configuration.
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: simpleReadyIngress(
+				route("default", "rollback", WithConfigTarget("config"), WithURL,
+					WithRouteAnnotation(serving.RollbackOnFailureAnnotationKey, "true")),
+				&traffic.Config{
+					Targets: map[string]traffic.RevisionTargets{
+						traffic.DefaultTarget: {{
+							TrafficTarget: v1beta1.TrafficTarget{
+								RevisionName: "config-00001",
+								Percent:      100,
+							},
+							ServiceName: "magnolia",
+							Active:      true,
+						}},
+					},
+				},
+			),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route("default", "rollback", WithConfigTarget("config"),
+				WithRouteAnnotation(serving.RollbackOnFailureAnnotationKey, "true"),
+				WithURL, WithAddress, WithInitRouteConditions,
+				MarkTrafficAssigned, MarkIngressReady, WithRouteFinalizer, WithStatusTraffic(
+					v1alpha1.TrafficTarget{
+						TrafficTarget: v1beta1.TrafficTarget{
+							RevisionName:   "config-00001",
+							Percent:        100,
+							LatestRevision: ptr.Bool(true),
+						},
+					})),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "RolledBack",
+				"Shifted the traffic of Configuration %q from failed revision %q back to %q",
+				"config", "config-00002", "config-00001"),
+		},
+		Key:                     "default/rollback",
+		SkipNamespaceValidation: true,
 	}, {
 		Name: "failure updating cluster ingress",
 		// Starting from the new latest ready, induce a failure updating the cluster ingress.
@@ -1882,23 +2301,40 @@ func TestReconcile(t *testing.T) {
 	// TODO(mattmoor): Multiple inactive Revisions
 
 	defer logtesting.ClearAll()
+	cfg := ReconcilerTestConfig(false)
+	cfg.Rollout.AllowedGateURLs = []string{denyingGate.URL}
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		return &Reconciler{
 			Base:                 reconciler.NewBase(ctx, controllerAgentName, cmw),
 			routeLister:          listers.GetRouteLister(),
 			configurationLister:  listers.GetConfigurationLister(),
 			revisionLister:       listers.GetRevisionLister(),
+			podAutoscalerLister:  listers.GetPodAutoscalerLister(),
 			serviceLister:        listers.GetK8sServiceLister(),
 			clusterIngressLister: listers.GetClusterIngressLister(),
+			namespaceLister:      listers.GetNamespaceLister(),
 			tracker:              &NullTracker{},
 			configStore: &testConfigStore{
-				config: ReconcilerTestConfig(false),
+				config: cfg,
 			},
-			clock: FakeClock{Time: fakeCurTime},
+			clock:        FakeClock{Time: fakeCurTime},
+			enqueueAfter: func(interface{}, time.Duration) {},
+			gateChecks:   syncGateChecks{},
 		}
 	}))
 }
 
+// syncGateChecks checks the rollout gates right away, rather than in the
+// background.
+type syncGateChecks struct{}
+
+func (syncGateChecks) Check(_ string, _ *rollout.Step, check func(context.Context) (*rollout.Decision, error)) (*rollout.Decision, bool, error) {
+	d, err := check(context.Background())
+	return d, true, err
+}
+
+func (syncGateChecks) Forget(string) {}
+
 func TestReconcile_EnableAutoTLS(t *testing.T) {
 	table := TableTest{{
 		Name: "check that Certificate and IngressTLS are correctly configured when creating a Route",
@@ -2050,14 +2486,18 @@ func TestReconcile_EnableAutoTLS(t *testing.T) {
 			routeLister:          listers.GetRouteLister(),
 			configurationLister:  listers.GetConfigurationLister(),
 			revisionLister:       listers.GetRevisionLister(),
+			podAutoscalerLister:  listers.GetPodAutoscalerLister(),
 			serviceLister:        listers.GetK8sServiceLister(),
 			clusterIngressLister: listers.GetClusterIngressLister(),
+			namespaceLister:      listers.GetNamespaceLister(),
 			certificateLister:    listers.GetCertificateLister(),
 			tracker:              &NullTracker{},
 			configStore: &testConfigStore{
 				config: ReconcilerTestConfig(true),
 			},
-			clock: FakeClock{Time: fakeCurTime},
+			clock:        FakeClock{Time: fakeCurTime},
+			enqueueAfter: func(interface{}, time.Duration) {},
+			gateChecks:   syncGateChecks{},
 		}
 	}))
 }
@@ -2205,6 +2645,48 @@ func patchFinalizers(namespace, name string) clientgotesting.PatchActionImpl {
 	return action
 }
 
+func simplePA(namespace, name string) *av1alpha1.PodAutoscaler {
+	return &av1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
+
+func preScaledPA(namespace, name string, percent int, from string) *av1alpha1.PodAutoscaler {
+	pa := simplePA(namespace, name)
+	shiftTime := fakeCurTime.UTC().Format(time.RFC3339)
+	pa.Annotations = map[string]string{
+		autoscaling.PreScaleFromAnnotationKey:    from,
+		autoscaling.PreScalePercentAnnotationKey: strconv.Itoa(percent),
+		autoscaling.PreScaleTimeAnnotationKey:    shiftTime,
+	}
+	pa.Status.PreScaleTime = shiftTime
+	pa.Status.PreScaleReady = true
+	return pa
+}
+
+func hpaPA(namespace, name string) *av1alpha1.PodAutoscaler {
+	pa := simplePA(namespace, name)
+	pa.Annotations = map[string]string{
+		autoscaling.ClassAnnotationKey: autoscaling.HPA,
+	}
+	return pa
+}
+
+func patchPreScale(namespace, name string, percent int, from string) clientgotesting.PatchActionImpl {
+	action := clientgotesting.PatchActionImpl{}
+	action.Name = name
+	action.Namespace = namespace
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q,%q:"%d",%q:%q}}}`,
+		autoscaling.PreScaleFromAnnotationKey, from,
+		autoscaling.PreScalePercentAnnotationKey, percent,
+		autoscaling.PreScaleTimeAnnotationKey, fakeCurTime.UTC().Format(time.RFC3339))
+	action.Patch = []byte(patch)
+	return action
+}
+
 func patchLastPinned(namespace, name string) clientgotesting.PatchActionImpl {
 	action := clientgotesting.PatchActionImpl{}
 	action.Name = name
@@ -2267,6 +2749,11 @@ func ReconcilerTestConfig(enableAutoTLS bool) *config.Config {
 		GC: &gc.Config{
 			StaleRevisionLastpinnedDebounce: time.Duration(1 * time.Minute),
 		},
+		Rollout: &config.Rollout{
+			AnalysisWindow:  5 * time.Minute,
+			GateTimeout:     10 * time.Second,
+			RecheckInterval: time.Minute,
+		},
 	}
 }
 
@@ -19,8 +19,11 @@ package traffic
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 
 	net "github.com/knative/serving/pkg/apis/networking"
 	"github.com/knative/serving/pkg/apis/serving"
@@ -42,11 +45,22 @@ type RevisionTarget struct {
 	Active      bool
 	Protocol    net.ProtocolType
 	ServiceName string // Revision service name.
+	// TimeoutSeconds is the request timeout of the revision, or 0 if it
+	// has none.
+	TimeoutSeconds int64
 }
 
 // RevisionTargets is a collection of revision targets.
 type RevisionTargets []RevisionTarget
 
+// A Rollback records that the traffic of a Configuration was shifted from
+// its latest ready Revision, which failed, back to a previous Revision.
+type Rollback struct {
+	Configuration string
+	From          string
+	To            string
+}
+
 // Config encapsulates details of our traffic so that we don't need to make API calls, or use details of the
 // route beyond its ObjectMeta to make routing changes.
 type Config struct {
@@ -63,6 +77,9 @@ type Config struct {
 	// The referred `Configuration`s and `Revision`s.
 	Configurations map[string]*v1alpha1.Configuration
 	Revisions      map[string]*v1alpha1.Revision
+
+	// The Configurations whose traffic was rolled back.
+	Rollbacks []Rollback
 }
 
 // BuildTrafficConfiguration consolidates and flattens the Route.Spec.Traffic to the Revision-level. It also provides a
@@ -73,6 +90,7 @@ type Config struct {
 func BuildTrafficConfiguration(configLister listers.ConfigurationLister, revLister listers.RevisionLister,
 	u *v1alpha1.Route) (*Config, error) {
 	builder := newBuilder(configLister, revLister, u.Namespace, len(u.Spec.Traffic))
+	builder.rollbackOnFailure, _ = strconv.ParseBool(u.Annotations[serving.RollbackOnFailureAnnotationKey])
 	builder.applySpecTraffic(u.Spec.Traffic)
 	return builder.build()
 }
@@ -134,6 +152,12 @@ type configBuilder struct {
 
 	// TargetError are deferred until we got a complete list of all referred targets.
 	deferredTargetErr TargetError
+
+	// rollbackOnFailure is whether the traffic of a Configuration whose latest
+	// ready Revision failed is shifted back to its previous ready Revision.
+	rollbackOnFailure bool
+	// rollbacks contains the Configurations whose traffic was rolled back.
+	rollbacks []Rollback
 }
 
 func newBuilder(
@@ -225,18 +249,81 @@ func (t *configBuilder) addConfigurationTarget(tt *v1alpha1.TrafficTarget) error
 	if err != nil {
 		return err
 	}
+	if t.rollbackOnFailure && isFailed(rev) {
+		if prev := t.previousReadyRevision(config, rev); prev != nil {
+			t.addRollback(Rollback{Configuration: config.Name, From: rev.Name, To: prev.Name})
+			rev = prev
+		}
+	}
 	ntt := tt.TrafficTarget.DeepCopy()
 	target := RevisionTarget{
-		TrafficTarget: *ntt,
-		Active:        !rev.Status.IsActivationRequired(),
-		Protocol:      rev.GetProtocol(),
-		ServiceName:   rev.Status.ServiceName,
+		TrafficTarget:  *ntt,
+		Active:         !rev.Status.IsActivationRequired(),
+		Protocol:       rev.GetProtocol(),
+		ServiceName:    rev.Status.ServiceName,
+		TimeoutSeconds: revisionTimeoutSeconds(rev),
 	}
 	target.TrafficTarget.RevisionName = rev.Name
 	t.addFlattenedTarget(target)
 	return nil
 }
 
+// revisionTimeoutSeconds returns the request timeout of rev, or 0 if it has
+// none.
+func revisionTimeoutSeconds(rev *v1alpha1.Revision) int64 {
+	if rev.Spec.TimeoutSeconds == nil {
+		return 0
+	}
+	return *rev.Spec.TimeoutSeconds
+}
+
+// previousReadyRevision returns the most recent ready Revision of config that
+// was created before failed, or nil if there is none.
+func (t *configBuilder) previousReadyRevision(config *v1alpha1.Configuration, failed *v1alpha1.Revision) *v1alpha1.Revision {
+	failedGen, err := strconv.ParseInt(failed.Labels[serving.ConfigurationGenerationLabelKey], 10, 64)
+	if err != nil {
+		return nil
+	}
+	revs, err := t.revLister.Revisions(t.namespace).List(labels.SelectorFromSet(labels.Set{
+		serving.ConfigurationLabelKey: config.Name,
+	}))
+	if err != nil {
+		return nil
+	}
+
+	var prev *v1alpha1.Revision
+	var prevGen int64
+	for _, rev := range revs {
+		gen, err := strconv.ParseInt(rev.Labels[serving.ConfigurationGenerationLabelKey], 10, 64)
+		if err != nil || gen >= failedGen || !rev.Status.IsReady() {
+			continue
+		}
+		if prev == nil || gen > prevGen {
+			prev, prevGen = rev, gen
+		}
+	}
+	if prev != nil {
+		t.revisions[prev.Name] = prev
+	}
+	return prev
+}
+
+func (t *configBuilder) addRollback(rb Rollback) {
+	for _, r := range t.rollbacks {
+		if r == rb {
+			return
+		}
+	}
+	t.rollbacks = append(t.rollbacks, rb)
+}
+
+// isFailed returns whether the revision failed to stay ready, as opposed
+// to still becoming ready.
+func isFailed(rev *v1alpha1.Revision) bool {
+	cond := rev.Status.GetCondition(v1alpha1.RevisionConditionReady)
+	return cond != nil && cond.Status == corev1.ConditionFalse
+}
+
 func (t *configBuilder) addRevisionTarget(tt *v1alpha1.TrafficTarget) error {
 	rev, err := t.getRevision(tt.RevisionName)
 	if err != nil {
@@ -247,10 +334,11 @@ func (t *configBuilder) addRevisionTarget(tt *v1alpha1.TrafficTarget) error {
 	}
 	ntt := tt.TrafficTarget.DeepCopy()
 	target := RevisionTarget{
-		TrafficTarget: *ntt,
-		Active:        !rev.Status.IsActivationRequired(),
-		Protocol:      rev.GetProtocol(),
-		ServiceName:   rev.Status.ServiceName,
+		TrafficTarget:  *ntt,
+		Active:         !rev.Status.IsActivationRequired(),
+		Protocol:       rev.GetProtocol(),
+		ServiceName:    rev.Status.ServiceName,
+		TimeoutSeconds: revisionTimeoutSeconds(rev),
 	}
 	t.revisions[tt.RevisionName] = rev
 	if configName, ok := rev.Labels[serving.ConfigurationLabelKey]; ok {
@@ -308,11 +396,13 @@ func (t *configBuilder) build() (*Config, error) {
 	if t.deferredTargetErr != nil {
 		t.targets = nil
 		t.revisionTargets = nil
+		t.rollbacks = nil
 	}
 	return &Config{
 		Targets:         consolidateAll(t.targets),
 		revisionTargets: t.revisionTargets,
 		Configurations:  t.configurations,
 		Revisions:       t.revisions,
+		Rollbacks:       t.rollbacks,
 	}, t.deferredTargetErr
 }
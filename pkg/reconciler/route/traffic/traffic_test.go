@@ -17,7 +17,9 @@ package traffic
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -76,6 +78,13 @@ var (
 	niceOldRev *v1alpha1.Revision
 	niceNewRev *v1alpha1.Revision
 
+	// crashedConfig has two good revisions, crashedOldRev and crashedPrevRev,
+	// and its latest ready revision crashedRev has since failed.
+	crashedConfig  *v1alpha1.Configuration
+	crashedOldRev  *v1alpha1.Revision
+	crashedPrevRev *v1alpha1.Revision
+	crashedRev     *v1alpha1.Revision
+
 	configLister listers.ConfigurationLister
 	revLister    listers.RevisionLister
 
@@ -90,6 +99,7 @@ func setUp() {
 	inactiveConfig, inactiveRev = getTestInactiveConfig("inactive")
 	goodConfig, goodOldRev, goodNewRev = getTestReadyConfig("good")
 	niceConfig, niceOldRev, niceNewRev = getTestReadyConfig("nice")
+	crashedConfig, crashedOldRev, crashedPrevRev, crashedRev = getTestCrashedConfig("crashed")
 	servingClient := fakeclientset.NewSimpleClientset()
 
 	servingInformer := informers.NewSharedInformerFactory(servingClient, 0)
@@ -107,6 +117,7 @@ func setUp() {
 		emptyConfig,
 		goodConfig, goodOldRev, goodNewRev,
 		niceConfig, niceOldRev, niceNewRev,
+		crashedConfig, crashedOldRev, crashedPrevRev, crashedRev,
 	}
 
 	for _, obj := range objs {
@@ -165,6 +176,54 @@ func TestBuildTrafficConfiguration_Vanilla(t *testing.T) {
 	}
 }
 
+func TestBuildTrafficConfiguration_RollbackOnFailure(t *testing.T) {
+	tts := []v1alpha1.TrafficTarget{{
+		TrafficTarget: v1beta1.TrafficTarget{
+			ConfigurationName: crashedConfig.Name,
+			Percent:           100,
+		},
+	}}
+
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		want        *v1alpha1.Revision
+		rollbacks   []Rollback
+	}{{
+		name: "disabled",
+		want: crashedRev,
+	}, {
+		name: "enabled",
+		annotations: map[string]string{
+			serving.RollbackOnFailureAnnotationKey: "true",
+		},
+		want: crashedPrevRev,
+		rollbacks: []Rollback{{
+			Configuration: crashedConfig.Name,
+			From:          crashedRev.Name,
+			To:            crashedPrevRev.Name,
+		}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := testRouteWithTrafficTargets(tts)
+			r.Annotations = tc.annotations
+			got, err := BuildTrafficConfiguration(configLister, revLister, r)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if got, want := got.revisionTargets[0].RevisionName, tc.want.Name; got != want {
+				t.Errorf("RevisionName = %q, want %q", got, want)
+			}
+			if !cmp.Equal(tc.rollbacks, got.Rollbacks) {
+				t.Errorf("Rollbacks (-want, +got) = %v", cmp.Diff(tc.rollbacks, got.Rollbacks))
+			}
+			if _, ok := got.Revisions[tc.want.Name]; !ok {
+				t.Errorf("Revisions = %v, want to contain %q", got.Revisions, tc.want.Name)
+			}
+		})
+	}
+}
+
 func TestBuildTrafficConfiguration_NoNameRevision(t *testing.T) {
 	tts := []v1alpha1.TrafficTarget{{
 		TrafficTarget: v1beta1.TrafficTarget{
@@ -1106,6 +1165,23 @@ func getTestReadyConfig(name string) (*v1alpha1.Configuration, *v1alpha1.Revisio
 	return config, rev1, rev2
 }
 
+func getTestCrashedConfig(name string) (*v1alpha1.Configuration, *v1alpha1.Revision, *v1alpha1.Revision, *v1alpha1.Revision) {
+	config := testConfig(name + "-config")
+	var revs []*v1alpha1.Revision
+	for i := 1; i <= 3; i++ {
+		rev := testRevForConfig(config, fmt.Sprintf("%s-revision-%d", name, i))
+		rev.Labels[serving.ConfigurationGenerationLabelKey] = strconv.Itoa(i)
+		rev.Status.MarkResourcesAvailable()
+		rev.Status.MarkContainerHealthy()
+		rev.Status.MarkActive()
+		revs = append(revs, rev)
+	}
+	revs[2].Status.MarkContainerExiting(1, "Crashed")
+	config.Status.SetLatestReadyRevisionName(revs[2].Name)
+	config.Status.SetLatestCreatedRevisionName(revs[2].Name)
+	return config, revs[0], revs[1], revs[2]
+}
+
 func TestMain(m *testing.M) {
 	setUp()
 	os.Exit(m.Run())
@@ -19,19 +19,25 @@ package serverlessservice
 import (
 	"context"
 
+	"go.uber.org/zap"
+
 	endpointsinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/endpoints"
+	namespaceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace"
 	serviceinformer "knative.dev/pkg/injection/informers/kubeinformers/corev1/service"
 	sksinformer "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/serverlessservice"
 	pkgreconciler "github.com/knative/serving/pkg/reconciler"
 
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
+	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/system"
 	"github.com/knative/serving/pkg/activator"
 	"github.com/knative/serving/pkg/apis/networking"
 	netv1alpha1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
+	listers "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
 	rbase "github.com/knative/serving/pkg/reconciler"
 	presources "github.com/knative/serving/pkg/resources"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -47,11 +53,13 @@ func NewController(
 ) *controller.Impl {
 	serviceInformer := serviceinformer.Get(ctx)
 	endpointsInformer := endpointsinformer.Get(ctx)
+	namespaceInformer := namespaceinformer.Get(ctx)
 	sksInformer := sksinformer.Get(ctx)
 
 	c := &reconciler{
 		Base:              rbase.NewBase(ctx, controllerAgentName, cmw),
 		endpointsLister:   endpointsInformer.Lister(),
+		namespaceLister:   namespaceInformer.Lister(),
 		serviceLister:     serviceInformer.Lister(),
 		sksLister:         sksInformer.Lister(),
 		psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
@@ -75,7 +83,7 @@ func NewController(
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
-	// Watch activator-service endpoints.
+	// Watch activator-service endpoints, including the dedicated pools.
 	grCb := func(obj interface{}) {
 		// Since changes in the Activator Service endpoints affect all the SKS objects,
 		// do a global resync.
@@ -86,9 +94,34 @@ func NewController(
 		// Accept only ActivatorService K8s service objects.
 		FilterFunc: rbase.ChainFilterFuncs(
 			rbase.NamespaceFilterFunc(system.Namespace()),
-			rbase.NameFilterFunc(activator.K8sServiceName)),
+			rbase.NamePrefixFilterFunc(activator.K8sServiceName)),
 		Handler: controller.HandleAll(grCb),
 	})
 
+	// Watch namespaces, since their labels select the activator pool of
+	// the SKS objects in them.
+	namespaceInformer.Informer().AddEventHandler(controller.HandleAll(
+		enqueueNamespaceSKSs(c.Logger, sksInformer.Lister(), impl.Enqueue)))
+
 	return impl
 }
+
+// enqueueNamespaceSKSs returns a namespace event handler enqueueing the SKS
+// objects in the namespace.
+func enqueueNamespaceSKSs(logger *zap.SugaredLogger, lister listers.ServerlessServiceLister, enqueue func(interface{})) func(interface{}) {
+	return func(obj interface{}) {
+		ns, err := kmeta.DeletionHandlingAccessor(obj)
+		if err != nil {
+			logger.Errorw("Failed to get the namespace of the event", zap.Error(err))
+			return
+		}
+		skss, err := lister.ServerlessServices(ns.GetName()).List(labels.Everything())
+		if err != nil {
+			logger.Errorw("Failed to list the SKS objects of namespace "+ns.GetName(), zap.Error(err))
+			return
+		}
+		for _, sks := range skss {
+			enqueue(sks)
+		}
+	}
+}
@@ -18,9 +18,11 @@ package serverlessservice
 
 import (
 	"context"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"golang.org/x/sync/errgroup"
 
 	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
@@ -31,9 +33,13 @@ import (
 	"knative.dev/pkg/controller"
 	logtesting "knative.dev/pkg/logging/testing"
 	"github.com/knative/serving/pkg/activator"
+	netv1alpha1 "github.com/knative/serving/pkg/apis/networking/v1alpha1"
+	listers "github.com/knative/serving/pkg/client/listers/networking/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
 
 	. "knative.dev/pkg/reconciler/testing"
 	. "github.com/knative/serving/pkg/reconciler/testing/v1alpha1"
@@ -156,3 +162,28 @@ func TestGlobalResyncOnActivatorChange(t *testing.T) {
 		t.Fatalf("Hooks timed out: %v", err)
 	}
 }
+
+func TestEnqueueNamespaceSKSs(t *testing.T) {
+	defer logtesting.ClearAll()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, sks := range []*netv1alpha1.ServerlessService{
+		SKS("test-ns1", "test-sks-1"),
+		SKS("test-ns1", "test-sks-2"),
+		SKS("test-ns2", "test-sks-3"),
+	} {
+		indexer.Add(sks)
+	}
+
+	var got []string
+	handler := enqueueNamespaceSKSs(logtesting.TestLogger(t), listers.NewServerlessServiceLister(indexer), func(obj interface{}) {
+		got = append(got, obj.(*netv1alpha1.ServerlessService).Name)
+	})
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns1"}}
+	handler(ns)
+	handler(cache.DeletedFinalStateUnknown{Key: "test-ns1", Obj: ns})
+	sort.Strings(got)
+	if want := []string{"test-sks-1", "test-sks-1", "test-sks-2", "test-sks-2"}; !cmp.Equal(got, want) {
+		t.Errorf("Enqueued %v, want: %v", got, want)
+	}
+}
@@ -56,6 +56,7 @@ type reconciler struct {
 	sksLister       listers.ServerlessServiceLister
 	serviceLister   corev1listers.ServiceLister
 	endpointsLister corev1listers.EndpointsLister
+	namespaceLister corev1listers.NamespaceLister
 
 	// Used to get PodScalables from object references.
 	psInformerFactory duck.InformerFactory
@@ -182,6 +183,33 @@ func (r *reconciler) reconcilePublicService(ctx context.Context, sks *netv1alpha
 	return nil
 }
 
+// activatorEndpoints returns the endpoints of the activator pool dedicated
+// to the given namespace, falling back to the shared pool if there is no
+// such pool or it has no ready endpoints.
+func (r *reconciler) activatorEndpoints(ctx context.Context, namespace string) (*corev1.Endpoints, error) {
+	logger := logging.FromContext(ctx)
+
+	if ns, err := r.namespaceLister.Get(namespace); err == nil {
+		if pool := ns.Labels[networking.ActivatorPoolLabelKey]; pool != "" {
+			psn := activator.PoolServiceName(pool)
+			eps, err := r.endpointsLister.Endpoints(system.Namespace()).Get(psn)
+			switch {
+			case err == nil && presources.ReadyAddressCount(eps) > 0:
+				return eps, nil
+			case err == nil:
+				logger.Infof("Activator pool %s has no endpoints, using the shared pool", psn)
+			case errors.IsNotFound(err):
+				logger.Infof("Activator pool %s does not exist, using the shared pool", psn)
+			default:
+				return nil, err
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return r.endpointsLister.Endpoints(system.Namespace()).Get(activator.K8sServiceName)
+}
+
 func (r *reconciler) reconcilePublicEndpoints(ctx context.Context, sks *netv1alpha1.ServerlessService) error {
 	logger := logging.FromContext(ctx)
 
@@ -191,7 +219,7 @@ func (r *reconciler) reconcilePublicEndpoints(ctx context.Context, sks *netv1alp
 		err                   error
 		foundServingEndpoints bool
 	)
-	activatorEps, err = r.endpointsLister.Endpoints(system.Namespace()).Get(activator.K8sServiceName)
+	activatorEps, err = r.activatorEndpoints(ctx, sks.Namespace)
 	if err != nil {
 		logger.Errorw("Error obtaining activator service endpoints", zap.Error(err))
 		return err
@@ -24,6 +24,7 @@ import (
 
 	// Inject the fakes for informers this reconciler depends on.
 	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/endpoints/fake"
+	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/namespace/fake"
 	_ "knative.dev/pkg/injection/informers/kubeinformers/corev1/service/fake"
 	_ "github.com/knative/serving/pkg/client/injection/informers/networking/v1alpha1/serverlessservice/fake"
 
@@ -110,6 +111,80 @@ func TestReconcile(t *testing.T) {
 		WantEvents: []string{
 			Eventf(corev1.EventTypeNormal, "Updated", `Successfully updated ServerlessService "steady/to-proxy"`),
 		},
+	}, {
+		Name: "proxy mode with dedicated activator pool",
+		Key:  "pooled/to-proxy",
+		Objects: []runtime.Object{
+			SKS("pooled", "to-proxy", markHappy, WithPubService, WithPrivateService("to-proxy-deadbeef"),
+				WithDeployRef("bar"), WithProxyMode),
+			deploy("pooled", "bar"),
+			svcpub("pooled", "to-proxy"),
+			svcpriv("pooled", "to-proxy", svcWithName("to-proxy-deadbeef")),
+			endpointspub("pooled", "to-proxy", WithSubsets),
+			endpointspriv("pooled", "to-proxy", epsWithName("to-proxy-deadbeed")),
+			activatorEndpoints(WithSubsets),
+			activatorEndpoints(withOtherSubsets, epsWithName(activator.PoolServiceName("tenant"))),
+			namespace("pooled", "tenant"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: SKS("pooled", "to-proxy", WithDeployRef("bar"),
+				markNoEndpoints, WithProxyMode, WithPubService, WithPrivateService("to-proxy-deadbeef")),
+		}},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: endpointspub("pooled", "to-proxy", withOtherSubsets),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Updated", `Successfully updated ServerlessService "pooled/to-proxy"`),
+		},
+	}, {
+		Name: "proxy mode with empty activator pool falls back to shared pool",
+		Key:  "pooled/to-proxy",
+		Objects: []runtime.Object{
+			SKS("pooled", "to-proxy", markHappy, WithPubService, WithPrivateService("to-proxy-deadbeef"),
+				WithDeployRef("bar"), WithProxyMode),
+			deploy("pooled", "bar"),
+			svcpub("pooled", "to-proxy"),
+			svcpriv("pooled", "to-proxy", svcWithName("to-proxy-deadbeef")),
+			endpointspub("pooled", "to-proxy", withOtherSubsets),
+			endpointspriv("pooled", "to-proxy", epsWithName("to-proxy-deadbeed")),
+			activatorEndpoints(WithSubsets),
+			activatorEndpoints(epsWithName(activator.PoolServiceName("tenant"))),
+			namespace("pooled", "tenant"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: SKS("pooled", "to-proxy", WithDeployRef("bar"),
+				markNoEndpoints, WithProxyMode, WithPubService, WithPrivateService("to-proxy-deadbeef")),
+		}},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: endpointspub("pooled", "to-proxy", WithSubsets),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Updated", `Successfully updated ServerlessService "pooled/to-proxy"`),
+		},
+	}, {
+		Name: "proxy mode with missing activator pool falls back to shared pool",
+		Key:  "pooled/to-proxy",
+		Objects: []runtime.Object{
+			SKS("pooled", "to-proxy", markHappy, WithPubService, WithPrivateService("to-proxy-deadbeef"),
+				WithDeployRef("bar"), WithProxyMode),
+			deploy("pooled", "bar"),
+			svcpub("pooled", "to-proxy"),
+			svcpriv("pooled", "to-proxy", svcWithName("to-proxy-deadbeef")),
+			endpointspub("pooled", "to-proxy", withOtherSubsets),
+			endpointspriv("pooled", "to-proxy", epsWithName("to-proxy-deadbeed")),
+			activatorEndpoints(WithSubsets),
+			namespace("pooled", "tenant"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: SKS("pooled", "to-proxy", WithDeployRef("bar"),
+				markNoEndpoints, WithProxyMode, WithPubService, WithPrivateService("to-proxy-deadbeef")),
+		}},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: endpointspub("pooled", "to-proxy", WithSubsets),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Updated", `Successfully updated ServerlessService "pooled/to-proxy"`),
+		},
 	}, {
 		Name: "many-private-services",
 		Key:  "many/privates",
@@ -593,6 +668,7 @@ func TestReconcile(t *testing.T) {
 			sksLister:         listers.GetServerlessServiceLister(),
 			serviceLister:     listers.GetK8sServiceLister(),
 			endpointsLister:   listers.GetEndpointsLister(),
+			namespaceLister:   listers.GetNamespaceLister(),
 			psInformerFactory: presources.NewPodScalableInformerFactory(ctx),
 		}
 	}))
@@ -710,6 +786,17 @@ func activatorEndpoints(eo ...EndpointsOption) *corev1.Endpoints {
 	return ep
 }
 
+func namespace(name, pool string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				networking.ActivatorPoolLabelKey: pool,
+			},
+		},
+	}
+}
+
 func epsWithName(n string) EndpointsOption {
 	return func(e *corev1.Endpoints) {
 		e.Name = n
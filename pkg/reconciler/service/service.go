@@ -40,6 +40,7 @@ import (
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/kmp"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
 )
 
 const (
@@ -56,6 +57,9 @@ type Reconciler struct {
 	configurationLister listers.ConfigurationLister
 	revisionLister      listers.RevisionLister
 	routeLister         listers.RouteLister
+
+	clock        system.Clock
+	enqueueAfter func(interface{}, time.Duration)
 }
 
 // Check that our Reconciler implements controller.Reconciler
@@ -90,9 +94,21 @@ func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
 	// Don't modify the informers copy
 	service := original.DeepCopy()
 
+	if deleted, err := c.reconcileTTL(ctx, service); err != nil {
+		logger.Errorw("Failed to act on the expired ttl", zap.Error(err))
+		return err
+	} else if deleted {
+		return nil
+	}
+
 	// Reconcile this copy of the service and then write back any status
 	// updates regardless of whether the reconciliation errored out.
 	reconcileErr := c.reconcile(ctx, service)
+	if reconcileErr != nil {
+		service.Status.MarkReconcileBackoff(reconcileErr)
+	} else {
+		service.Status.ClearReconcileBackoff()
+	}
 	if equality.Semantic.DeepEqual(original.Status, service.Status) {
 		// If we didn't change anything then don't call updateStatus.
 		// This is important because the copy we loaded from the informer's
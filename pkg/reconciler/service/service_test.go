@@ -18,8 +18,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	// Install our fake informers
 	_ "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/configuration/fake"
@@ -27,10 +30,13 @@ import (
 	_ "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/route/fake"
 	_ "github.com/knative/serving/pkg/client/injection/informers/serving/v1alpha1/service/fake"
 
+	"knative.dev/pkg/apis"
 	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	logtesting "knative.dev/pkg/logging/testing"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	"github.com/knative/serving/pkg/apis/serving/v1beta1"
 	"github.com/knative/serving/pkg/reconciler"
@@ -47,6 +53,8 @@ import (
 	. "github.com/knative/serving/pkg/testing/v1alpha1"
 )
 
+var fakeCurTime = time.Unix(1e9, 0)
+
 // This is heavily based on the way the OpenShift Ingress controller tests its reconciliation method.
 func TestReconcile(t *testing.T) {
 	table := TableTest{{
@@ -82,6 +90,154 @@ func TestReconcile(t *testing.T) {
 			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "run-latest"),
 			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "run-latest"),
 		},
+	}, {
+		Name: "ttl - starts counting once first seen",
+		Objects: []runtime.Object{
+			Service("ttl-start", "foo", withTTL("10h", "")),
+		},
+		Key: "foo/ttl-start",
+		WantCreates: []runtime.Object{
+			config("ttl-start", "foo", withTTL("10h", "")),
+			route("ttl-start", "foo", withTTL("10h", "")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-start", "foo", withTTL("10h", ""),
+				WithInitSvcConditions, withTTLStart(0)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-start"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-start"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-start"),
+		},
+	}, {
+		Name: "ttl - warns ahead of expiry",
+		Objects: []runtime.Object{
+			Service("ttl-warn", "foo", withTTL("10h", ""), withTTLStart(9*time.Hour+30*time.Minute)),
+		},
+		Key: "foo/ttl-warn",
+		WantCreates: []runtime.Object{
+			config("ttl-warn", "foo", withTTL("10h", "")),
+			route("ttl-warn", "foo", withTTL("10h", "")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-warn", "foo", withTTL("10h", ""), withTTLStart(9*time.Hour+30*time.Minute),
+				WithInitSvcConditions,
+				withTTLWarned("Service will be deleted once its ttl of 10h0m0s expires at 2001-09-09T02:16:40Z", 0)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "TTLExpiring", "Service %q will be deleted in 30m0s, when its ttl of 10h0m0s expires", "ttl-warn"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-warn"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-warn"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-warn"),
+		},
+	}, {
+		Name: "ttl - doesn't warn twice",
+		Objects: []runtime.Object{
+			Service("ttl-warned", "foo", withTTL("10h", ""), withTTLStart(9*time.Hour+30*time.Minute),
+				withTTLWarned("Service will be deleted once its ttl of 10h0m0s expires at 2001-09-09T02:16:40Z", 10*time.Minute)),
+		},
+		Key: "foo/ttl-warned",
+		WantCreates: []runtime.Object{
+			config("ttl-warned", "foo", withTTL("10h", "")),
+			route("ttl-warned", "foo", withTTL("10h", "")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-warned", "foo", withTTL("10h", ""), withTTLStart(9*time.Hour+30*time.Minute),
+				WithInitSvcConditions,
+				withTTLWarned("Service will be deleted once its ttl of 10h0m0s expires at 2001-09-09T02:16:40Z", 10*time.Minute)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-warned"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-warned"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-warned"),
+		},
+	}, {
+		Name: "ttl - warns first when expired without warning",
+		Objects: []runtime.Object{
+			Service("ttl-late", "foo", withTTL("1h", ""), withTTLStart(2*time.Hour)),
+		},
+		Key: "foo/ttl-late",
+		WantCreates: []runtime.Object{
+			config("ttl-late", "foo", withTTL("1h", "")),
+			route("ttl-late", "foo", withTTL("1h", "")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-late", "foo", withTTL("1h", ""), withTTLStart(2*time.Hour),
+				WithInitSvcConditions,
+				withTTLWarned("Service will be deleted once its ttl of 1h0m0s expires at 2001-09-09T01:47:40Z", 0)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "TTLExpiring", "Service %q will be deleted in 1m0s, when its ttl of 1h0m0s expires", "ttl-late"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-late"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-late"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-late"),
+		},
+	}, {
+		Name: "ttl - forgets a removed ttl",
+		Objects: []runtime.Object{
+			Service("ttl-removed", "foo", WithInlineRollout, withTTLStart(2*time.Hour),
+				withTTLWarned("Service will be deleted once its ttl of 1h0m0s expires at 2001-09-09T01:47:40Z", 10*time.Minute)),
+		},
+		Key: "foo/ttl-removed",
+		WantCreates: []runtime.Object{
+			config("ttl-removed", "foo", WithInlineRollout),
+			route("ttl-removed", "foo", WithInlineRollout),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-removed", "foo", WithInlineRollout, WithInitSvcConditions),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-removed"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-removed"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-removed"),
+		},
+	}, {
+		Name: "ttl - deletes expired service",
+		Objects: []runtime.Object{
+			Service("ttl-delete", "foo", withTTL("1h", ""), withTTLStart(2*time.Hour),
+				withTTLWarned("Service will be deleted once its ttl of 1h0m0s expires at 2001-09-09T01:47:40Z", 10*time.Minute)),
+		},
+		Key: "foo/ttl-delete",
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+				Verb:      "delete",
+				Resource:  v1alpha1.SchemeGroupVersion.WithResource("services"),
+			},
+			Name: "ttl-delete",
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "TTLExpired", "Deleting Service %q, its ttl of 1h0m0s expired", "ttl-delete"),
+		},
+	}, {
+		Name: "ttl - hibernates revisions of expired service",
+		Objects: []runtime.Object{
+			Service("ttl-hibernate", "foo", withTTL("1h", serving.TTLActionHibernate), withTTLStart(2*time.Hour),
+				withTTLWarned("Service will be hibernated once its ttl of 1h0m0s expires at 2001-09-09T01:47:40Z", 10*time.Minute)),
+			revision("ttl-hibernate-00001", "foo",
+				WithRevisionAnnotation(autoscaling.HibernatedAnnotationKey, "true")),
+			revision("ttl-hibernate-00002", "foo"),
+		},
+		Key: "foo/ttl-hibernate",
+		WantCreates: []runtime.Object{
+			config("ttl-hibernate", "foo", withTTL("1h", serving.TTLActionHibernate)),
+			route("ttl-hibernate", "foo", withTTL("1h", serving.TTLActionHibernate)),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: revision("ttl-hibernate-00002", "foo",
+				WithRevisionAnnotation(autoscaling.HibernatedAnnotationKey, "true")),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("ttl-hibernate", "foo", withTTL("1h", serving.TTLActionHibernate), withTTLStart(2*time.Hour),
+				WithInitSvcConditions,
+				withTTLWarned("Service will be hibernated once its ttl of 1h0m0s expires at 2001-09-09T01:47:40Z", 10*time.Minute)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "TTLExpired", "Hibernated %d Revisions of Service %q, its ttl of 1h0m0s expired", 1, "ttl-hibernate"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "ttl-hibernate"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "ttl-hibernate"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "ttl-hibernate"),
+		},
 	}, {
 		Name: "runLatest - create route and service",
 		Objects: []runtime.Object{
@@ -109,6 +265,33 @@ func TestReconcile(t *testing.T) {
 			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "run-latest"),
 			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "run-latest"),
 		},
+	}, {
+		Name: "runLatest - recovers from backoff",
+		Objects: []runtime.Object{
+			Service("run-latest", "foo", WithRunLatestRollout, WithInitSvcConditions,
+				withBackoff("inducing failure for create routes")),
+		},
+		Key: "foo/run-latest",
+		WantCreates: []runtime.Object{
+			config("run-latest", "foo", WithRunLatestRollout),
+			route("run-latest", "foo", WithRunLatestRollout),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			// The backoff is cleared once the Service reconciles.
+			Object: Service("run-latest", "foo", WithRunLatestRollout, WithInitSvcConditions),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "foo",
+			},
+			Name:  "run-latest",
+			Patch: []byte(reconciler.ForceUpgradePatch),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "run-latest"),
+			Eventf(corev1.EventTypeNormal, "Created", "Created Route %q", "run-latest"),
+			Eventf(corev1.EventTypeNormal, "Updated", "Updated Service %q", "run-latest"),
+		},
 	}, {
 		Name: "pinned - create route and service",
 		Objects: []runtime.Object{
@@ -913,8 +1096,17 @@ func TestReconcile(t *testing.T) {
 					cfg.Spec.GetTemplate().Spec.GetContainer().Image = "#"
 				}),
 		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("bad-config-update", "foo", WithInitSvcConditions, WithRunLatestRollout,
+				func(svc *v1alpha1.Service) {
+					svc.Spec.DeprecatedRunLatest.Configuration.GetTemplate().Spec.GetContainer().Image = "#"
+				},
+				withBackoff("Failed to parse image reference: spec.template.spec.containers[0].image\nimage: \"#\", error: could not parse reference")),
+		}},
 		WantEvents: []string{
-			Eventf(corev1.EventTypeWarning, "InternalError", "Failed to parse image reference: spec.template.spec.containers[0].image\nimage: \"#\", error: could not parse reference"),
+			// Surfacing the backoff is rejected, as the Service itself is invalid.
+			Eventf(corev1.EventTypeWarning, "UpdateFailed", "Failed to update status for Service %q: %v", "bad-config-update",
+				"Failed to parse image reference: spec.runLatest.configuration.revisionTemplate.spec.container.image\nimage: \"#\", error: could not parse reference"),
 		},
 	}, {
 		Name: "runLatest - route creation failure",
@@ -934,7 +1126,7 @@ func TestReconcile(t *testing.T) {
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: Service("create-route-failure", "foo", WithRunLatestRollout,
 				// First reconcile initializes conditions.
-				WithInitSvcConditions),
+				WithInitSvcConditions, withBackoff("inducing failure for create routes")),
 		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeNormal, "Created", "Created Configuration %q", "create-route-failure"),
@@ -960,7 +1152,7 @@ func TestReconcile(t *testing.T) {
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: Service("create-config-failure", "foo", WithRunLatestRollout,
 				// First reconcile initializes conditions.
-				WithInitSvcConditions),
+				WithInitSvcConditions, withBackoff("inducing failure for create configurations")),
 		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeWarning, "CreationFailed", "Failed to create Configuration %q: %v",
@@ -984,6 +1176,10 @@ func TestReconcile(t *testing.T) {
 		WantUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: route("update-route-failure", "foo", WithRunLatestRollout),
 		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("update-route-failure", "foo", WithRunLatestRollout, WithInitSvcConditions,
+				withBackoff("inducing failure for update routes")),
+		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update routes"),
 		},
@@ -1006,6 +1202,10 @@ func TestReconcile(t *testing.T) {
 		WantUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: config("update-config-failure", "foo", WithRunLatestRollout),
 		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Service("update-config-failure", "foo", WithRunLatestRollout, WithInitSvcConditions,
+				withBackoff("inducing failure for update configurations")),
+		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for update configurations"),
 		},
@@ -1271,7 +1471,8 @@ func TestReconcile(t *testing.T) {
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: Service("run-latest", "foo", WithRunLatestRollout,
 				// The first reconciliation will initialize the status conditions.
-				WithInitSvcConditions, MarkConfigurationNotOwned),
+				WithInitSvcConditions, MarkConfigurationNotOwned,
+				withBackoff(`service: "run-latest" does not own configuration: "run-latest"`)),
 		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeWarning, "InternalError", `service: "run-latest" does not own configuration: "run-latest"`),
@@ -1288,7 +1489,8 @@ func TestReconcile(t *testing.T) {
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: Service("run-latest", "foo", WithRunLatestRollout,
 				// The first reconciliation will initialize the status conditions.
-				WithInitSvcConditions, MarkRouteNotOwned),
+				WithInitSvcConditions, MarkRouteNotOwned,
+				withBackoff(`service: "run-latest" does not own route: "run-latest"`)),
 		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeWarning, "InternalError", `service: "run-latest" does not own route: "run-latest"`),
@@ -1350,6 +1552,8 @@ func TestReconcile(t *testing.T) {
 			configurationLister: listers.GetConfigurationLister(),
 			revisionLister:      listers.GetRevisionLister(),
 			routeLister:         listers.GetRouteLister(),
+			clock:               FakeClock{Time: fakeCurTime},
+			enqueueAfter:        func(interface{}, time.Duration) {},
 		}
 	}))
 }
@@ -1421,3 +1625,67 @@ func RouteFailed(reason, message string) RouteOption {
 		}
 	}
 }
+
+// withTTL makes an inline Service whose ttl annotations are set to the
+// given values unless empty.
+func withTTL(ttl, action string) ServiceOption {
+	return func(s *v1alpha1.Service) {
+		WithInlineRollout(s)
+		annotations := map[string]string{serving.TTLAnnotationKey: ttl}
+		if action != "" {
+			annotations[serving.TTLActionAnnotationKey] = action
+		}
+		WithServiceAnnotations(annotations)(s)
+	}
+}
+
+// withTTLStart records that the ttl of the Service started the given
+// duration ago.
+func withTTLStart(ago time.Duration) ServiceOption {
+	return func(s *v1alpha1.Service) {
+		s.Status.MarkTTLStarted(fakeCurTime.Add(-ago))
+	}
+}
+
+// withTTLWarned marks the Service as warned about its expiring ttl the
+// given duration ago.
+func withTTLWarned(message string, ago time.Duration) ServiceOption {
+	return func(s *v1alpha1.Service) {
+		s.Status.MarkTTLExpiring("%s", message)
+		for i, c := range s.Status.Conditions {
+			if c.Type == v1alpha1.ServiceConditionTTLExpiring {
+				s.Status.Conditions[i].LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(fakeCurTime.Add(-ago))}
+			}
+		}
+	}
+}
+
+func revision(name, namespace string, ro ...RevisionOption) *v1alpha1.Revision {
+	r := &v1alpha1.Revision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				serving.ConfigurationLabelKey: name[:strings.LastIndex(name, "-")],
+			},
+		},
+		Spec: v1alpha1.RevisionSpec{
+			RevisionSpec: v1beta1.RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image: "busybox",
+					}},
+				},
+			},
+		},
+	}
+	for _, opt := range ro {
+		opt(r)
+	}
+	return r
+}
+
+// withBackoff marks the Service as failed to reconcile.
+func withBackoff(err string) ServiceOption {
+	return WithReconcileBackoff(errors.New(err))
+}
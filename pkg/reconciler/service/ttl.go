@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	resourcenames "github.com/knative/serving/pkg/reconciler/service/resources/names"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// ttlWarningDivisor sets how long before its ttl expires a Service is
+	// warned about it, as a fraction of the ttl.
+	ttlWarningDivisor = 10
+
+	// ttlMinWarning is how long a Service is warned about at least before
+	// acting on its expired ttl, however short the ttl is.
+	ttlMinWarning = time.Minute
+)
+
+// serviceTTL returns the ttl of the Service and whether it has one.
+func serviceTTL(service *v1alpha1.Service) (time.Duration, bool) {
+	v, ok := service.Annotations[serving.TTLAnnotationKey]
+	if !ok {
+		return 0, false
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// reconcileTTL acts on the Service once its ttl expires, and warns about it
+// ahead of time. The ttl is measured from when the ttl annotation was first
+// seen, which is recorded in the status of the Service. It returns whether
+// the Service was deleted, in which case there is nothing left to reconcile.
+func (c *Reconciler) reconcileTTL(ctx context.Context, service *v1alpha1.Service) (bool, error) {
+	ttl, ok := serviceTTL(service)
+	if !ok {
+		service.Status.ClearTTL()
+		return false, nil
+	}
+	action := service.Annotations[serving.TTLActionAnnotationKey]
+	if action == "" {
+		action = serving.TTLActionDelete
+	}
+
+	now := c.clock.Now()
+	service.Status.MarkTTLStarted(now)
+	expiry := service.Status.TTLStartTime.Add(ttl)
+	warnAt := expiry.Add(-ttl / ttlWarningDivisor)
+	if now.Before(warnAt) {
+		c.enqueueAfter(service, warnAt.Sub(now))
+		return false, nil
+	}
+
+	// Never act on the ttl without having warned about it for a while first.
+	if cond := service.Status.GetCondition(v1alpha1.ServiceConditionTTLExpiring); cond == nil {
+		if minExpiry := now.Add(ttlMinWarning); expiry.Before(minExpiry) {
+			expiry = minExpiry
+		}
+		outcome := "deleted"
+		if action == serving.TTLActionHibernate {
+			outcome = "hibernated"
+		}
+		service.Status.MarkTTLExpiring("Service will be %s once its ttl of %v expires at %v",
+			outcome, ttl, expiry.UTC().Format(time.RFC3339))
+		c.Recorder.Eventf(service, corev1.EventTypeWarning, "TTLExpiring",
+			"Service %q will be %s in %v, when its ttl of %v expires",
+			service.Name, outcome, expiry.Sub(now).Round(time.Second), ttl)
+	} else if minExpiry := cond.LastTransitionTime.Inner.Add(ttlMinWarning); expiry.Before(minExpiry) {
+		expiry = minExpiry
+	}
+	if now.Before(expiry) {
+		c.enqueueAfter(service, expiry.Sub(now))
+		return false, nil
+	}
+
+	if action == serving.TTLActionHibernate {
+		return false, c.hibernateRevisions(ctx, service, ttl)
+	}
+	logging.FromContext(ctx).Infof("Deleting Service %q, its ttl of %v expired", service.Name, ttl)
+	c.Recorder.Eventf(service, corev1.EventTypeNormal, "TTLExpired",
+		"Deleting Service %q, its ttl of %v expired", service.Name, ttl)
+	// Only delete the Service the ttl was measured for, not one recreated
+	// under the same name since.
+	err := c.ServingClientSet.ServingV1alpha1().Services(service.Namespace).Delete(service.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &service.UID},
+	})
+	return err == nil, err
+}
+
+// hibernateRevisions marks the Revisions of the Service's Configuration as
+// hibernated, unless they already are.
+func (c *Reconciler) hibernateRevisions(ctx context.Context, service *v1alpha1.Service, ttl time.Duration) error {
+	revs, err := c.revisionLister.Revisions(service.Namespace).List(labels.SelectorFromSet(labels.Set{
+		serving.ConfigurationLabelKey: resourcenames.Configuration(service),
+	}))
+	if err != nil {
+		return err
+	}
+	hibernated := 0
+	for _, rev := range revs {
+		if autoscaling.IsHibernated(rev.Annotations) {
+			continue
+		}
+		// Don't modify the informers copy.
+		existing := rev.DeepCopy()
+		if existing.Annotations == nil {
+			existing.Annotations = make(map[string]string, 1)
+		}
+		existing.Annotations[autoscaling.HibernatedAnnotationKey] = "true"
+		if _, err := c.ServingClientSet.ServingV1alpha1().Revisions(rev.Namespace).Update(existing); err != nil {
+			return err
+		}
+		hibernated++
+	}
+	if hibernated > 0 {
+		logging.FromContext(ctx).Infof("Hibernated %d Revisions of Service %q, its ttl of %v expired", hibernated, service.Name, ttl)
+		c.Recorder.Eventf(service, corev1.EventTypeNormal, "TTLExpired",
+			"Hibernated %d Revisions of Service %q, its ttl of %v expired", hibernated, service.Name, ttl)
+	}
+	return nil
+}
@@ -158,6 +158,15 @@ func WithMetricAnnotation(metric string) PodAutoscalerOption {
 	return withAnnotationValue(autoscaling.MetricAnnotationKey, metric)
 }
 
+// WithActivation requests the activation of the PA to the given scale
+// until expiry.
+func WithActivation(scale int, expiry time.Time) PodAutoscalerOption {
+	return func(pa *autoscalingv1alpha1.PodAutoscaler) {
+		withAnnotationValue(autoscaling.ActivationScaleAnnotationKey, strconv.Itoa(scale))(pa)
+		withAnnotationValue(autoscaling.ActivationExpiryAnnotationKey, expiry.Format(time.RFC3339))(pa)
+	}
+}
+
 // WithUpperScaleBound sets maxScale to the given number.
 func WithUpperScaleBound(i int) PodAutoscalerOption {
 	return withAnnotationValue(autoscaling.MaxScaleAnnotationKey, strconv.Itoa(i))
@@ -168,6 +177,22 @@ func WithLowerScaleBound(i int) PodAutoscalerOption {
 	return withAnnotationValue(autoscaling.MinScaleAnnotationKey, strconv.Itoa(i))
 }
 
+// WithLearnedMinScaleMax opts the PA into learning its minimum scale,
+// bounded by the given number.
+func WithLearnedMinScaleMax(i int) PodAutoscalerOption {
+	return withAnnotationValue(autoscaling.LearnedMinScaleMaxAnnotationKey, strconv.Itoa(i))
+}
+
+// WithScalingStrategy sets the scaling strategy annotation on the PA.
+func WithScalingStrategy(name string) PodAutoscalerOption {
+	return withAnnotationValue(autoscaling.ScalingStrategyAnnotationKey, name)
+}
+
+// WithScaleSmoothing sets the scale smoothing annotation on the PA.
+func WithScaleSmoothing(filter string) PodAutoscalerOption {
+	return withAnnotationValue(autoscaling.ScaleSmoothingAnnotationKey, filter)
+}
+
 // WithMSvcStatus sets the name of the metrics service.
 func WithMSvcStatus(s string) PodAutoscalerOption {
 	return func(pa *autoscalingv1alpha1.PodAutoscaler) {
@@ -45,6 +45,26 @@ func WithRevName(name string) RevisionOption {
 	}
 }
 
+// WithRevisionLabel attaches a particular label to the revision.
+func WithRevisionLabel(key, value string) RevisionOption {
+	return func(rev *v1alpha1.Revision) {
+		if rev.Labels == nil {
+			rev.Labels = make(map[string]string)
+		}
+		rev.Labels[key] = value
+	}
+}
+
+// WithRevisionAnnotation sets the given annotation on the revision.
+func WithRevisionAnnotation(key, value string) RevisionOption {
+	return func(rev *v1alpha1.Revision) {
+		if rev.Annotations == nil {
+			rev.Annotations = make(map[string]string)
+		}
+		rev.Annotations[key] = value
+	}
+}
+
 // WithServiceName propagates the given service name to the revision status.
 func WithServiceName(sn string) RevisionOption {
 	return func(rev *v1alpha1.Revision) {
@@ -146,6 +166,27 @@ func MarkResourcesUnavailable(reason, message string) RevisionOption {
 	}
 }
 
+// MarkQuotaExceeded calls .Status.MarkQuotaExceeded on the Revision.
+func MarkQuotaExceeded(message string) RevisionOption {
+	return func(r *v1alpha1.Revision) {
+		r.Status.MarkQuotaExceeded(message)
+	}
+}
+
+// MarkNodeAffinityUnsatisfiable calls .Status.MarkNodeAffinityUnsatisfiable on the Revision.
+func MarkNodeAffinityUnsatisfiable(message string) RevisionOption {
+	return func(r *v1alpha1.Revision) {
+		r.Status.MarkNodeAffinityUnsatisfiable(message)
+	}
+}
+
+// MarkInsufficientResources calls .Status.MarkInsufficientResources on the Revision.
+func MarkInsufficientResources(message string) RevisionOption {
+	return func(r *v1alpha1.Revision) {
+		r.Status.MarkInsufficientResources(message)
+	}
+}
+
 // MarkRevisionReady calls the necessary helpers to make the Revision Ready=True.
 func MarkRevisionReady(r *v1alpha1.Revision) {
 	WithInitRevConditions(r)
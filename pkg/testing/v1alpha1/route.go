@@ -222,3 +222,13 @@ func WithIngressClass(ingressClass string) RouteOption {
 		r.Annotations[networking.IngressClassAnnotationKey] = ingressClass
 	}
 }
+
+// WithRouteAnnotation sets the specified annotation on the Route.
+func WithRouteAnnotation(key, value string) RouteOption {
+	return func(r *v1alpha1.Route) {
+		if r.Annotations == nil {
+			r.Annotations = make(map[string]string)
+		}
+		r.Annotations[key] = value
+	}
+}
@@ -212,6 +212,13 @@ func WithServiceAnnotations(annotations map[string]string) ServiceOption {
 	}
 }
 
+// WithServiceCreationTimestamp sets the Service's timestamp to the provided time.
+func WithServiceCreationTimestamp(t time.Time) ServiceOption {
+	return func(service *v1alpha1.Service) {
+		service.ObjectMeta.CreationTimestamp = metav1.Time{Time: t}
+	}
+}
+
 // WithConfigAnnotations assigns config annotations to a service
 func WithConfigAnnotations(annotations map[string]string) ServiceOption {
 	return func(service *v1alpha1.Service) {
@@ -320,6 +327,13 @@ func WithInitSvcConditions(s *v1alpha1.Service) {
 	s.Status.InitializeConditions()
 }
 
+// WithReconcileBackoff marks the Service as retried after failing to reconcile.
+func WithReconcileBackoff(err error) ServiceOption {
+	return func(s *v1alpha1.Service) {
+		s.Status.MarkReconcileBackoff(err)
+	}
+}
+
 // WithReadyRoute reflects the Route's readiness in the Service resource.
 func WithReadyRoute(s *v1alpha1.Service) {
 	s.Status.PropagateRouteStatus(&v1alpha1.RouteStatus{
@@ -29,30 +29,55 @@ const (
 	ConfigName = "config-tracing"
 
 	enableKey         = "enable"
+	backendKey        = "backend"
 	zipkinEndpointKey = "zipkin-endpoint"
+	otlpEndpointKey   = "otlp-endpoint"
+	otlpProtocolKey   = "otlp-protocol"
 	debugKey          = "debug"
 	sampleRateKey     = "sample-rate"
 )
 
+// BackendType is the system the traces are exported to.
+type BackendType string
+
+const (
+	// Zipkin exports the traces to a zipkin collector, the default.
+	Zipkin BackendType = "zipkin"
+	// OTLP exports the traces with the OpenTelemetry protocol.
+	OTLP BackendType = "otlp"
+)
+
+const (
+	// OTLPProtocolGRPC exports with OTLP over gRPC, the default.
+	OTLPProtocolGRPC = "grpc"
+	// OTLPProtocolHTTP exports with OTLP over HTTP, with protobuf bodies.
+	OTLPProtocolHTTP = "http"
+)
+
 // Config holds the configuration for tracers
 type Config struct {
 	Enable         bool
+	Backend        BackendType
 	ZipkinEndpoint string
+	OTLPEndpoint   string
+	OTLPProtocol   string
 	Debug          bool
 	SampleRate     float64
 }
 
 // Equals returns true if two Configs are identical
 func (cfg *Config) Equals(other *Config) bool {
-	return other.Enable == cfg.Enable && other.ZipkinEndpoint == cfg.ZipkinEndpoint && other.Debug == cfg.Debug && other.SampleRate == cfg.SampleRate
+	return *other == *cfg
 }
 
 // NewTracingConfigFromMap returns a Config given a map corresponding to a ConfigMap
 func NewTracingConfigFromMap(cfgMap map[string]string) (*Config, error) {
 	tc := Config{
-		Enable:     false,
-		Debug:      false,
-		SampleRate: 0.1,
+		Enable:       false,
+		Backend:      Zipkin,
+		OTLPProtocol: OTLPProtocolGRPC,
+		Debug:        false,
+		SampleRate:   0.1,
 	}
 	if enable, ok := cfgMap[enableKey]; ok {
 		enableBool, err := strconv.ParseBool(enable)
@@ -62,14 +87,38 @@ func NewTracingConfigFromMap(cfgMap map[string]string) (*Config, error) {
 		tc.Enable = enableBool
 	}
 
+	if backend, ok := cfgMap[backendKey]; ok {
+		switch b := BackendType(backend); b {
+		case Zipkin, OTLP:
+			tc.Backend = b
+		default:
+			return nil, fmt.Errorf("unsupported tracing backend %q, must be %s or %s", backend, Zipkin, OTLP)
+		}
+	}
+
 	if endpoint, ok := cfgMap[zipkinEndpointKey]; !ok {
-		if tc.Enable {
+		if tc.Enable && tc.Backend == Zipkin {
 			return nil, errors.New("tracing enabled but no zipkin endpoint specified")
 		}
 	} else {
 		tc.ZipkinEndpoint = endpoint
 	}
 
+	if endpoint, ok := cfgMap[otlpEndpointKey]; !ok {
+		if tc.Enable && tc.Backend == OTLP {
+			return nil, errors.New("tracing enabled but no otlp endpoint specified")
+		}
+	} else {
+		tc.OTLPEndpoint = endpoint
+	}
+
+	if protocol, ok := cfgMap[otlpProtocolKey]; ok {
+		if protocol != OTLPProtocolGRPC && protocol != OTLPProtocolHTTP {
+			return nil, fmt.Errorf("unsupported otlp protocol %q, must be %s or %s", protocol, OTLPProtocolGRPC, OTLPProtocolHTTP)
+		}
+		tc.OTLPProtocol = protocol
+	}
+
 	if debug, ok := cfgMap[debugKey]; ok {
 		debugBool, err := strconv.ParseBool(debug)
 		if err != nil {
@@ -59,7 +59,9 @@ func TestNewConfigFromMap(t *testing.T) {
 		name:  "Empty map",
 		input: map[string]string{},
 		output: Config{
-			SampleRate: 0.1,
+			Backend:      Zipkin,
+			OTLPProtocol: OTLPProtocolGRPC,
+			SampleRate:   0.1,
 		},
 	}, {
 		name: "Everything enabled",
@@ -71,10 +73,27 @@ func TestNewConfigFromMap(t *testing.T) {
 		},
 		output: Config{
 			Enable:         true,
+			Backend:        Zipkin,
 			Debug:          true,
 			ZipkinEndpoint: "some-endpoint",
+			OTLPProtocol:   OTLPProtocolGRPC,
 			SampleRate:     0.5,
 		},
+	}, {
+		name: "OTLP over HTTP",
+		input: map[string]string{
+			enableKey:       "true",
+			backendKey:      "otlp",
+			otlpEndpointKey: "http://otel-collector.observability:4318",
+			otlpProtocolKey: "http",
+		},
+		output: Config{
+			Enable:       true,
+			Backend:      OTLP,
+			OTLPEndpoint: "http://otel-collector.observability:4318",
+			OTLPProtocol: OTLPProtocolHTTP,
+			SampleRate:   0.1,
+		},
 	}}
 
 	for _, tc := range tt {
@@ -90,6 +109,43 @@ func TestNewConfigFromMap(t *testing.T) {
 	}
 }
 
+func TestNewConfigFromMapErrors(t *testing.T) {
+	tt := []struct {
+		name  string
+		input map[string]string
+	}{{
+		name: "Zipkin without endpoint",
+		input: map[string]string{
+			enableKey: "true",
+		},
+	}, {
+		name: "OTLP without endpoint",
+		input: map[string]string{
+			enableKey:         "true",
+			backendKey:        "otlp",
+			zipkinEndpointKey: "some-endpoint",
+		},
+	}, {
+		name: "Unknown backend",
+		input: map[string]string{
+			backendKey: "jaeger",
+		},
+	}, {
+		name: "Unknown OTLP protocol",
+		input: map[string]string{
+			otlpProtocolKey: "thrift",
+		},
+	}}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if cfg, err := NewTracingConfigFromMap(tc.input); err == nil {
+				t.Errorf("NewTracingConfigFromMap() = %v, want an error", cfg)
+			}
+		})
+	}
+}
+
 func TestConfigFromConfigMap(t *testing.T) {
 	cfg, err := NewTracingConfigFromConfigMap(&corev1.ConfigMap{
 		Data: map[string]string{
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/knative/serving/pkg/otlp"
 	"github.com/knative/serving/pkg/tracing/config"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
@@ -20,6 +21,7 @@ type OpenCensusTracer struct {
 	configOptions  []ConfigOption
 	zipkinReporter zipkinreporter.Reporter
 	zipkinExporter trace.Exporter
+	otlpExporter   *otlp.Exporter
 }
 
 // OpenCensus tracing keeps state in globals and therefore we can only run one OpenCensusTracer
@@ -107,7 +109,7 @@ func WithZipkinExporter(reporterFact ZipkinReporterFactory, endpoint *zipkinmode
 			exporter trace.Exporter
 		)
 
-		if cfg != nil && cfg.Enable {
+		if cfg != nil && cfg.Enable && cfg.Backend != config.OTLP {
 			// Initialize our reporter / exporter
 			// do this before cleanup to minimize time where we have duplicate exporters
 			reporter, err := reporterFact(cfg)
@@ -134,3 +136,35 @@ func WithZipkinExporter(reporterFact ZipkinReporterFactory, endpoint *zipkinmode
 		oct.zipkinExporter = exporter
 	}
 }
+
+// WithOTLPExporter exports the spans to the OTLP endpoint of the config,
+// when its backend is otlp, tagged with the given resource attributes.
+func WithOTLPExporter(resource map[string]string) ConfigOption {
+	return func(cfg *config.Config) {
+		var exporter *otlp.Exporter
+
+		if cfg != nil && cfg.Enable && cfg.Backend == config.OTLP {
+			var err error
+			exporter, err = otlp.NewExporter(otlp.Options{
+				Endpoint: cfg.OTLPEndpoint,
+				Protocol: cfg.OTLPProtocol,
+				Resource: resource,
+			})
+			if err != nil {
+				// TODO(greghaynes) log this error
+				return
+			}
+			trace.RegisterExporter(exporter)
+		}
+
+		// We know this is set because we are called with acquireGlobal lock held
+		oct := globalOct
+		if oct.otlpExporter != nil {
+			trace.UnregisterExporter(oct.otlpExporter)
+			// Stopping flushes the spans that are still buffered.
+			_ = oct.otlpExporter.Stop()
+		}
+
+		oct.otlpExporter = exporter
+	}
+}
@@ -1,8 +1,11 @@
 package tracing
 
 import (
+	"context"
 	"crypto/rand"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -59,6 +62,14 @@ func TestOpenCensusTracerApplyConfig(t *testing.T) {
 			Enable:         true,
 			ZipkinEndpoint: "test-endpoint:1234",
 		},
+	}, {
+		name: "OTLP backend",
+		cfg: config.Config{
+			Enable:       true,
+			Backend:      config.OTLP,
+			OTLPEndpoint: "test-endpoint:4317",
+		},
+		expect: nil,
 	}}
 
 	endpoint, _ := openzipkin.NewEndpoint("test", "localhost:1234")
@@ -86,6 +97,41 @@ func TestOpenCensusTracerApplyConfig(t *testing.T) {
 	}
 }
 
+func TestOpenCensusTracerOTLP(t *testing.T) {
+	paths := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	oct := NewOpenCensusTracer(WithOTLPExporter(map[string]string{"service.name": "test"}))
+	if err := oct.ApplyConfig(&config.Config{
+		Enable:       true,
+		Debug:        true,
+		Backend:      config.OTLP,
+		OTLPEndpoint: ts.URL,
+		OTLPProtocol: config.OTLPProtocolHTTP,
+	}); err != nil {
+		t.Fatalf("Failed to ApplyConfig on tracer: %v", err)
+	}
+
+	_, span := trace.StartSpan(context.Background(), "test")
+	span.End()
+
+	// Finishing stops the exporter, which flushes the span.
+	if err := oct.Finish(); err != nil {
+		t.Fatalf("Failed to finish OCT: %v", err)
+	}
+	select {
+	case path := <-paths:
+		if got, want := path, "/v1/traces"; got != want {
+			t.Errorf("Path = %q, want %q", got, want)
+		}
+	default:
+		t.Error("The span was not exported")
+	}
+}
+
 func TestCreateOCTConfig(t *testing.T) {
 	tcs := []struct {
 		name   string
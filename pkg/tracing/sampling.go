@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	pkghttp "github.com/knative/serving/pkg/http"
+)
+
+// SampledOnErrorAttribute marks the spans that were reported only because
+// their request failed.
+const SampledOnErrorAttribute = "knative.dev/sampled_on_error"
+
+// SamplingPolicy overrides the sampling of config-tracing for a request.
+type SamplingPolicy struct {
+	// Sampler decides whether the request is sampled. When nil, the
+	// sampler configured in config-tracing is used.
+	Sampler trace.Sampler
+	// SampleOnError reports the requests failing with a 5xx status, even
+	// when the Sampler did not sample them.
+	SampleOnError bool
+}
+
+// SamplingPolicyFunc returns the SamplingPolicy of a request, or nil if
+// the request follows config-tracing.
+type SamplingPolicyFunc func(*http.Request) *SamplingPolicy
+
+// SamplingPolicyFromAnnotations creates the SamplingPolicy of a revision
+// from its annotations, or returns nil if they don't override the sampling.
+// Invalid values are rejected by the webhook, so they are ignored here.
+func SamplingPolicyFromAnnotations(annotations map[string]string) *SamplingPolicy {
+	p := &SamplingPolicy{}
+	if v, ok := annotations[serving.TracingSampleRateAnnotationKey]; ok {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 && rate <= 1 {
+			p.Sampler = trace.ProbabilitySampler(rate)
+		}
+	}
+	p.SampleOnError, _ = strconv.ParseBool(annotations[serving.TracingSampleOnErrorAnnotationKey])
+	if p.Sampler == nil && !p.SampleOnError {
+		return nil
+	}
+	return p
+}
+
+type samplingPolicyKey struct{}
+
+// HTTPSpanMiddlewareWithSampling is like HTTPSpanMiddleware, but samples
+// the requests according to the SamplingPolicy returned by policy.
+func HTTPSpanMiddlewareWithSampling(next http.Handler, policy SamplingPolicyFunc) http.Handler {
+	return &samplingHandler{
+		policy: policy,
+		handler: &ochttp.Handler{
+			Handler: &errorSamplingHandler{next: next},
+			GetStartOptions: func(r *http.Request) trace.StartOptions {
+				if p, ok := r.Context().Value(samplingPolicyKey{}).(*SamplingPolicy); ok {
+					return trace.StartOptions{Sampler: p.Sampler}
+				}
+				return trace.StartOptions{}
+			},
+		},
+	}
+}
+
+// samplingHandler looks up the SamplingPolicy of the request once and
+// passes it down in the request context.
+type samplingHandler struct {
+	policy  SamplingPolicyFunc
+	handler http.Handler
+}
+
+func (h *samplingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p := h.policy(r); p != nil {
+		r = r.WithContext(context.WithValue(r.Context(), samplingPolicyKey{}, p))
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// errorSamplingHandler reports the failed requests that were not sampled
+// when their SamplingPolicy asks for it. Since the sampling decision can't
+// be changed once the request span is started, a separate span carrying
+// the request attributes is reported for them.
+type errorSamplingHandler struct {
+	next http.Handler
+}
+
+func (h *errorSamplingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := r.Context().Value(samplingPolicyKey{}).(*SamplingPolicy)
+	if !ok || !p.SampleOnError {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if span := trace.FromContext(r.Context()); span != nil && span.SpanContext().IsSampled() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+	h.next.ServeHTTP(rr, r)
+	if rr.ResponseCode < http.StatusInternalServerError {
+		return
+	}
+
+	_, span := trace.StartSpan(r.Context(), r.URL.Path,
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithSpanKind(trace.SpanKindServer))
+	span.AddAttributes(
+		trace.StringAttribute(ochttp.PathAttribute, r.URL.Path),
+		trace.StringAttribute(ochttp.HostAttribute, r.Host),
+		trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+		trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(rr.ResponseCode)),
+		trace.Int64Attribute("http.latency_ms", int64(time.Since(start)/time.Millisecond)),
+		trace.BoolAttribute(SampledOnErrorAttribute, true),
+	)
+	span.SetStatus(ochttp.TraceStatus(rr.ResponseCode, http.StatusText(rr.ResponseCode)))
+	span.End()
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	openzipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
+	reporterrecorder "github.com/openzipkin/zipkin-go/reporter/recorder"
+	"go.opencensus.io/trace"
+
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/tracing/config"
+)
+
+func TestSamplingPolicyFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantPolicy  bool
+		wantSampler bool
+		wantOnError bool
+	}{{
+		name: "no annotations",
+	}, {
+		name: "sample rate",
+		annotations: map[string]string{
+			serving.TracingSampleRateAnnotationKey: "0.5",
+		},
+		wantPolicy:  true,
+		wantSampler: true,
+	}, {
+		name: "invalid sample rate",
+		annotations: map[string]string{
+			serving.TracingSampleRateAnnotationKey: "2",
+		},
+	}, {
+		name: "sample on error",
+		annotations: map[string]string{
+			serving.TracingSampleOnErrorAnnotationKey: "true",
+		},
+		wantPolicy:  true,
+		wantOnError: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := SamplingPolicyFromAnnotations(test.annotations)
+			if got := p != nil; got != test.wantPolicy {
+				t.Fatalf("SamplingPolicyFromAnnotations() = %v, want policy: %v", p, test.wantPolicy)
+			}
+			if p == nil {
+				return
+			}
+			if got := p.Sampler != nil; got != test.wantSampler {
+				t.Errorf("Sampler set = %v, want: %v", got, test.wantSampler)
+			}
+			if p.SampleOnError != test.wantOnError {
+				t.Errorf("SampleOnError = %v, want: %v", p.SampleOnError, test.wantOnError)
+			}
+		})
+	}
+}
+
+func TestHTTPSpanMiddlewareWithSampling(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *SamplingPolicy
+		status    int
+		wantSpans int
+		wantAttrs map[string]string
+	}{{
+		name:   "cluster-wide sampling",
+		status: http.StatusOK,
+	}, {
+		name:      "always sampled",
+		policy:    &SamplingPolicy{Sampler: trace.AlwaysSample()},
+		status:    http.StatusOK,
+		wantSpans: 1,
+	}, {
+		name:   "not sampled on success",
+		policy: &SamplingPolicy{SampleOnError: true},
+		status: http.StatusOK,
+	}, {
+		name:      "sampled on error",
+		policy:    &SamplingPolicy{SampleOnError: true},
+		status:    http.StatusBadGateway,
+		wantSpans: 1,
+		wantAttrs: map[string]string{
+			SampledOnErrorAttribute: "true",
+			"http.status_code":      "502",
+		},
+	}, {
+		name:      "sampled error isn't reported twice",
+		policy:    &SamplingPolicy{Sampler: trace.AlwaysSample(), SampleOnError: true},
+		status:    http.StatusBadGateway,
+		wantSpans: 1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reporter := reporterrecorder.NewReporter()
+			defer reporter.Close()
+			endpoint, _ := openzipkin.NewEndpoint("test", "localhost:1234")
+			oct := NewOpenCensusTracer(WithZipkinExporter(func(cfg *config.Config) (zipkinreporter.Reporter, error) {
+				return reporter, nil
+			}, endpoint))
+			defer oct.Finish()
+
+			// Nothing is sampled cluster-wide.
+			if err := oct.ApplyConfig(&config.Config{Enable: true, SampleRate: 0}); err != nil {
+				t.Fatalf("Failed to apply tracer config: %v", err)
+			}
+
+			handler := HTTPSpanMiddlewareWithSampling(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}), func(*http.Request) *SamplingPolicy {
+				return test.policy
+			})
+			req := httptest.NewRequest(http.MethodGet, "http://test.example.com/path", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != test.status {
+				t.Errorf("Status = %d, want: %d", rr.Code, test.status)
+			}
+			spans := reporter.Flush()
+			if len(spans) != test.wantSpans {
+				t.Fatalf("Got %d spans, want %d: spans = %v", len(spans), test.wantSpans, spans)
+			}
+			for k, want := range test.wantAttrs {
+				if diff := cmp.Diff(want, spans[0].Tags[k]); diff != "" {
+					t.Errorf("Tag %s (-want, +got) = %v", k, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// artifacts.go provides methods to record the artifacts of an e2e test in a
+// standard layout, so that flake analysis tooling can tell the failures of
+// the data plane and of the control plane apart.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/test/spoof"
+	"knative.dev/pkg/test/zipkin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The artifacts of a test are written under the directory given by the
+// --artifacts flag, in a directory named after the test:
+//
+//	summary.json                          the outcome of the test, see ArtifactsSummary
+//	requests.jsonl                        a RequestTrace per line for the traced requests
+//	logs/<namespace>/<pod>/<container>.log the logs of the serving components and of the
+//	                                      test's pods since the test started
+//	resources/<kind>.json                 the serving resources, Deployments, Pods and
+//	                                      Events of the test's namespace
+//
+// The logs and the resources are only collected for the failed tests.
+const (
+	summaryFile   = "summary.json"
+	requestsFile  = "requests.jsonl"
+	logsDir       = "logs"
+	resourcesDir  = "resources"
+	servingSystem = "knative-serving"
+)
+
+const (
+	// FailureClassControlPlane classifies the failures of tests that had
+	// serving resources which never became ready.
+	FailureClassControlPlane = "control-plane"
+
+	// FailureClassDataPlane classifies the failures of tests whose serving
+	// resources were ready, but which had requests fail.
+	FailureClassDataPlane = "data-plane"
+)
+
+// RequestTrace is the record of a request made by a test.
+type RequestTrace struct {
+	Start      time.Time     `json:"start"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Method     string        `json:"method,omitempty"`
+	URL        string        `json:"url"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	// TraceID is the zipkin trace of the request, to look it up in the
+	// traces of the cluster.
+	TraceID string `json:"traceId,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// failed returns whether the request failed, by a transport error or a
+// server error.
+func (r *RequestTrace) failed() bool {
+	return r.Error != "" || r.StatusCode >= http.StatusInternalServerError
+}
+
+// ArtifactsSummary is the outcome of a test, as written to summary.json.
+type ArtifactsSummary struct {
+	Test   string    `json:"test"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Failed bool      `json:"failed"`
+	// FailureClass is FailureClassControlPlane or FailureClassDataPlane
+	// for the failed tests that could be classified, and empty otherwise.
+	FailureClass   string `json:"failureClass,omitempty"`
+	Requests       int    `json:"requests"`
+	FailedRequests int    `json:"failedRequests"`
+	// NotReady lists the serving resources of the test's namespace that
+	// weren't ready when it failed, with the reason.
+	NotReady []string `json:"notReady,omitempty"`
+}
+
+// Artifacts records the artifacts of a test. Its methods do nothing when
+// no artifacts directory is configured, so it can be used unconditionally.
+type Artifacts struct {
+	t         *testing.T
+	clients   *Clients
+	namespace string
+	dir       string
+	start     time.Time
+
+	mu       sync.Mutex
+	requests []RequestTrace
+}
+
+// StartArtifacts starts recording the artifacts of the test, whose
+// resources are in namespace. Close must be called once the test is done.
+func StartArtifacts(t *testing.T, clients *Clients, namespace string) *Artifacts {
+	a := &Artifacts{
+		t:         t,
+		clients:   clients,
+		namespace: namespace,
+		start:     time.Now(),
+	}
+	if ServingFlags.Artifacts != "" {
+		a.dir = filepath.Join(ServingFlags.Artifacts, filepath.FromSlash(t.Name()))
+	}
+	return a
+}
+
+// Record records the trace of a request.
+func (a *Artifacts) Record(r RequestTrace) {
+	if a.dir == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, r)
+}
+
+// TraceChecker wraps a ResponseChecker, e.g. of WaitForEndpointState, to
+// record a trace of the responses it checks from url.
+func (a *Artifacts) TraceChecker(url string, checker spoof.ResponseChecker) spoof.ResponseChecker {
+	return func(resp *spoof.Response) (bool, error) {
+		a.Record(RequestTrace{
+			Start:      time.Now(),
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			TraceID:    resp.Header.Get(zipkin.ZipkinTraceIDHeader),
+		})
+		return checker(resp)
+	}
+}
+
+// TraceTransport wraps an http.RoundTripper, e.g. the one of a
+// SpoofingClient's Client, to record a trace of its requests.
+func (a *Artifacts) TraceTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		r := RequestTrace{
+			Start:  time.Now(),
+			Method: req.Method,
+			URL:    req.URL.String(),
+		}
+		resp, err := rt.RoundTrip(req)
+		r.Duration = time.Since(r.Start)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.StatusCode = resp.StatusCode
+			r.TraceID = resp.Header.Get(zipkin.ZipkinTraceIDHeader)
+		}
+		a.Record(r)
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Close writes the artifacts of the test. Failing to write them doesn't
+// fail the test, it's only logged.
+func (a *Artifacts) Close() {
+	if a.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		a.t.Logf("Failed to create the artifacts directory %s: %v", a.dir, err)
+		return
+	}
+
+	a.mu.Lock()
+	requests := a.requests
+	a.mu.Unlock()
+
+	summary := ArtifactsSummary{
+		Test:     a.t.Name(),
+		Start:    a.start,
+		End:      time.Now(),
+		Failed:   a.t.Failed(),
+		Requests: len(requests),
+	}
+	for i := range requests {
+		if requests[i].failed() {
+			summary.FailedRequests++
+		}
+	}
+	if err := a.writeRequests(requests); err != nil {
+		a.t.Logf("Failed to write the request traces: %v", err)
+	}
+
+	if summary.Failed {
+		notReady, err := a.writeResources()
+		if err != nil {
+			a.t.Logf("Failed to write the resources: %v", err)
+		}
+		summary.NotReady = notReady
+		for _, ns := range []string{servingSystem, a.namespace} {
+			if err := a.writeLogs(ns); err != nil {
+				a.t.Logf("Failed to write the logs of namespace %s: %v", ns, err)
+			}
+		}
+		switch {
+		case len(summary.NotReady) > 0:
+			summary.FailureClass = FailureClassControlPlane
+		case summary.FailedRequests > 0:
+			summary.FailureClass = FailureClassDataPlane
+		}
+	}
+
+	if err := writeJSON(filepath.Join(a.dir, summaryFile), summary); err != nil {
+		a.t.Logf("Failed to write the summary: %v", err)
+	}
+}
+
+func (a *Artifacts) writeRequests(requests []RequestTrace) error {
+	f, err := os.Create(filepath.Join(a.dir, requestsFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for i := range requests {
+		if err := enc.Encode(&requests[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResources writes the snapshots of the resources of the test's
+// namespace, and returns the serving resources that aren't ready.
+func (a *Artifacts) writeResources() ([]string, error) {
+	dir := filepath.Join(a.dir, resourcesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var notReady []string
+	checkReady := func(kind, name string, c *apis.Condition) {
+		switch {
+		case c == nil:
+			notReady = append(notReady, fmt.Sprintf("%s/%s: no Ready condition", kind, name))
+		case !c.IsTrue():
+			notReady = append(notReady, fmt.Sprintf("%s/%s: %s: %s", kind, name, c.Reason, c.Message))
+		}
+	}
+
+	sc := a.clients.ServingAlphaClient
+	services, err := sc.Services.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		s := &services.Items[i]
+		checkReady("Service", s.Name, s.Status.GetCondition(apis.ConditionReady))
+	}
+	configs, err := sc.Configs.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range configs.Items {
+		c := &configs.Items[i]
+		checkReady("Configuration", c.Name, c.Status.GetCondition(apis.ConditionReady))
+	}
+	revisions, err := sc.Revisions.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions.Items {
+		r := &revisions.Items[i]
+		checkReady("Revision", r.Name, r.Status.GetCondition(apis.ConditionReady))
+	}
+	routes, err := sc.Routes.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes.Items {
+		r := &routes.Items[i]
+		checkReady("Route", r.Name, r.Status.GetCondition(apis.ConditionReady))
+	}
+
+	kube := a.clients.KubeClient.Kube
+	deployments, err := kube.AppsV1().Deployments(a.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return notReady, err
+	}
+	pods, err := kube.CoreV1().Pods(a.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return notReady, err
+	}
+	events, err := kube.CoreV1().Events(a.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return notReady, err
+	}
+
+	for name, v := range map[string]interface{}{
+		"services":       services,
+		"configurations": configs,
+		"revisions":      revisions,
+		"routes":         routes,
+		"deployments":    deployments,
+		"pods":           pods,
+		"events":         events,
+	} {
+		if err := writeJSON(filepath.Join(dir, name+".json"), v); err != nil {
+			return notReady, err
+		}
+	}
+	return notReady, nil
+}
+
+// writeLogs writes the logs of the containers of the pods of namespace
+// since the test started.
+func (a *Artifacts) writeLogs(namespace string) error {
+	pods := a.clients.KubeClient.Kube.CoreV1().Pods(namespace)
+	list, err := pods.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	since := metav1.NewTime(a.start)
+	for _, pod := range list.Items {
+		dir := filepath.Join(a.dir, logsDir, namespace, pod.Name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, c := range pod.Spec.Containers {
+			logs, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: c.Name,
+				SinceTime: &since,
+			}).DoRaw()
+			if err != nil {
+				// The container may not have started, keep the others.
+				a.t.Logf("Failed to get the logs of %s/%s/%s: %v", namespace, pod.Name, c.Name, err)
+				continue
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, c.Name+".log"), logs, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// benchcheck compares the results of `go test -bench`, read from stdin,
+// with a baseline. It fails when a benchmark of the baseline got slower
+// than the tolerance allows, or didn't run. For example,
+//   go test -run='^$' -bench=. ./pkg/queue | go run ./test/benchcheck -baseline=test/benchmarks.txt
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	baselinePath = flag.String("baseline", "", "The file holding the baseline ns/op of the benchmarks.")
+	tolerance    = flag.Float64("tolerance", 0.1, "How much slower than its baseline a benchmark may get, as a fraction.")
+	update       = flag.Bool("update", false, "Write the results read from stdin to the baseline instead of checking them.")
+)
+
+// procsSuffix is the GOMAXPROCS suffix of the benchmark names, which
+// depends on the machine running them.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// parse reads the ns/op of the benchmarks in the output of `go test -bench`
+// or a baseline. A benchmark run several times, e.g. with -count, keeps its
+// fastest result, which is the least noisy.
+func parse(r io.Reader) (map[string]float64, error) {
+	results := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		for i := 1; i < len(fields); i++ {
+			if fields[i] != "ns/op" {
+				continue
+			}
+			v, err := strconv.ParseFloat(fields[i-1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ns/op in %q: %v", scanner.Text(), err)
+			}
+			name := procsSuffix.ReplaceAllString(fields[0], "")
+			if old, ok := results[name]; !ok || v < old {
+				results[name] = v
+			}
+			break
+		}
+	}
+	return results, scanner.Err()
+}
+
+// compare returns the problems of the results against the baseline: the
+// benchmarks that got slower than the tolerance allows and the ones that
+// are missing.
+func compare(baseline, results map[string]float64, tolerance float64) []string {
+	var problems []string
+	for _, name := range sortedNames(baseline) {
+		want := baseline[name]
+		got, ok := results[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s didn't run", name))
+		case got > want*(1+tolerance):
+			problems = append(problems, fmt.Sprintf("%s takes %.1f ns/op, %.0f%% more than its baseline of %.1f ns/op",
+				name, got, (got/want-1)*100, want))
+		}
+	}
+	return problems
+}
+
+// write writes the results in the format of a baseline.
+func write(w io.Writer, results map[string]float64) error {
+	for _, name := range sortedNames(results) {
+		if _, err := fmt.Fprintf(w, "%s\t%.1f ns/op\n", name, results[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedNames(results map[string]float64) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func main() {
+	flag.Parse()
+	if *baselinePath == "" {
+		log.Fatal("-baseline is required")
+	}
+
+	results, err := parse(os.Stdin)
+	if err != nil {
+		log.Fatalf("Failed to read the benchmark results: %v", err)
+	}
+	if len(results) == 0 {
+		log.Fatal("No benchmark results on stdin")
+	}
+
+	if *update {
+		f, err := os.Create(*baselinePath)
+		if err != nil {
+			log.Fatalf("Failed to create the baseline: %v", err)
+		}
+		defer f.Close()
+		if err := write(f, results); err != nil {
+			log.Fatalf("Failed to write the baseline: %v", err)
+		}
+		return
+	}
+
+	f, err := os.Open(*baselinePath)
+	if err != nil {
+		log.Fatalf("Failed to open the baseline: %v", err)
+	}
+	defer f.Close()
+	baseline, err := parse(f)
+	if err != nil {
+		log.Fatalf("Failed to read the baseline: %v", err)
+	}
+
+	for _, name := range sortedNames(results) {
+		if want, ok := baseline[name]; ok {
+			log.Printf("%s: %.1f ns/op, baseline %.1f ns/op", name, results[name], want)
+		} else {
+			log.Printf("%s: %.1f ns/op, no baseline", name, results[name])
+		}
+	}
+	if problems := compare(baseline, results, *tolerance); len(problems) > 0 {
+		for _, p := range problems {
+			log.Print(p)
+		}
+		log.Fatalf("%d benchmarks regressed, see above. Run test/benchmarks.sh --update if that's expected.", len(problems))
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	const output = `goos: linux
+pkg: github.com/knative/serving/pkg/queue
+BenchmarkBreakerMaybe-8          	 3005714	       399.6 ns/op
+BenchmarkBreakerMaybe-8          	 3005714	       380.2 ns/op
+BenchmarkBreakerMaybeParallel-8  	 2994771	       395.7 ns/op	      16 B/op	       1 allocs/op
+BenchmarkThrottlerTry	629.1 ns/op
+PASS
+ok  	github.com/knative/serving/pkg/queue	6.263s
+`
+	got, err := parse(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("parse() = %v", err)
+	}
+	want := map[string]float64{
+		"BenchmarkBreakerMaybe":         380.2,
+		"BenchmarkBreakerMaybeParallel": 395.7,
+		"BenchmarkThrottlerTry":         629.1,
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("parse() = %v, want: %v, diff(-want,+got): %s", got, want, cmp.Diff(want, got))
+	}
+
+	if _, err := parse(strings.NewReader("BenchmarkBreakerMaybe 1 fast ns/op")); err == nil {
+		t.Error("parse() = nil, want an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := map[string]float64{
+		"BenchmarkFaster":  100,
+		"BenchmarkSlower":  100,
+		"BenchmarkTooSlow": 100,
+		"BenchmarkMissing": 100,
+	}
+	results := map[string]float64{
+		"BenchmarkFaster":  50,
+		"BenchmarkSlower":  109,
+		"BenchmarkTooSlow": 120,
+		"BenchmarkNew":     100,
+	}
+	got := compare(baseline, results, 0.1)
+	want := []string{
+		"BenchmarkMissing didn't run",
+		"BenchmarkTooSlow takes 120.0 ns/op, 20% more than its baseline of 100.0 ns/op",
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("compare() = %v, want: %v", got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := map[string]float64{
+		"BenchmarkB": 2,
+		"BenchmarkA": 1.5,
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, results); err != nil {
+		t.Fatalf("write() = %v", err)
+	}
+	got, err := parse(&buf)
+	if err != nil {
+		t.Fatalf("parse() = %v", err)
+	}
+	if want := map[string]float64{"BenchmarkA": 1.5, "BenchmarkB": 2}; !cmp.Equal(got, want) {
+		t.Errorf("parse(write()) = %v, want: %v", got, want)
+	}
+}
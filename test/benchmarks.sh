@@ -0,0 +1,52 @@
+#!/usr/bin/env bash
+
+# Copyright 2019 The Knative Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# This script runs the benchmarks of the admission path of the Breaker and
+# the Throttler, and fails if any of them got more than 10% slower than its
+# baseline in test/benchmarks.txt. It is run by the presubmit unit tests.
+# Run it with --update to record the current results as the new baseline,
+# on the same kind of machine as the presubmit tests.
+
+set -o errexit
+set -o pipefail
+
+readonly REPO_ROOT=$(dirname $0)/..
+readonly BENCHMARKS='^Benchmark(Breaker|Throttler)'
+readonly BASELINE=test/benchmarks.txt
+
+cd ${REPO_ROOT}
+
+function run_benchmarks() {
+  go test -run='^$' -bench="${BENCHMARKS}" -count=5 ./pkg/queue ./pkg/activator
+}
+
+function check_benchmarks() {
+  go run ./test/benchcheck -baseline=${BASELINE} -tolerance=0.1 "$@"
+}
+
+# Each benchmark keeps its fastest run, to smooth out the noise.
+results="$(run_benchmarks)"
+if [[ "$1" == "--update" ]]; then
+  echo "${results}" | check_benchmarks -update
+  exit 0
+fi
+if ! echo "${results}" | check_benchmarks; then
+  # A busy machine can slow a whole run down, so only fail if the
+  # regression shows again.
+  echo "Running the benchmarks again to rule out noise"
+  results+=$'\n'"$(run_benchmarks)"
+  echo "${results}" | check_benchmarks
+fi
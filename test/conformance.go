@@ -33,6 +33,7 @@ const (
 	HelloVolume         = "hellovolume"
 	HelloWorld          = "helloworld"
 	HTTPProxy           = "httpproxy"
+	Informational       = "informational"
 	InvalidHelloWorld   = "invalidhelloworld"
 	PizzaPlanet1        = "pizzaplanetv1"
 	PizzaPlanet2        = "pizzaplanetv2"
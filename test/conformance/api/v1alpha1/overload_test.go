@@ -0,0 +1,191 @@
+// +build e2e
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+
+	pkgTest "knative.dev/pkg/test"
+	"knative.dev/pkg/test/spoof"
+	"github.com/knative/serving/pkg/activator"
+	"github.com/knative/serving/pkg/apis/autoscaling"
+	"github.com/knative/serving/pkg/network"
+	"github.com/knative/serving/pkg/queue"
+	"github.com/knative/serving/test"
+	v1a1test "github.com/knative/serving/test/v1alpha1"
+
+	. "github.com/knative/serving/pkg/testing/v1alpha1"
+)
+
+// createOverloadService creates a Service running a single replica of the
+// timeout image that handles one request at a time, and returns its domain
+// and a client for it once it serves traffic.
+func createOverloadService(t *testing.T, clients *test.Clients, names *test.ResourceNames, fopt ...ServiceOption) (string, *spoof.SpoofingClient) {
+	fopt = append(fopt, WithConfigAnnotations(map[string]string{
+		autoscaling.MinScaleAnnotationKey: "1",
+		autoscaling.MaxScaleAnnotationKey: "1",
+	}))
+	objects, err := v1a1test.CreateRunLatestServiceReady(t, clients, names, &v1a1test.Options{
+		ContainerConcurrency: 1,
+	}, fopt...)
+	if err != nil {
+		t.Fatalf("Failed to create Service: %v", err)
+	}
+	domain := objects.Service.Status.URL.Host
+
+	t.Logf("Probing domain %s", domain)
+	if _, err := pkgTest.WaitForEndpointState(
+		clients.KubeClient,
+		t.Logf,
+		domain,
+		v1a1test.RetryingRouteInconsistency(pkgTest.IsStatusOK),
+		"WaitForSuccessfulResponse",
+		test.ServingFlags.ResolvableDomain); err != nil {
+		t.Fatalf("Error probing domain %s: %v", domain, err)
+	}
+
+	client, err := pkgTest.NewSpoofingClient(clients.KubeClient, t.Logf, domain, test.ServingFlags.ResolvableDomain)
+	if err != nil {
+		t.Fatalf("Error creating spoofing client: %v", err)
+	}
+	return domain, client
+}
+
+// sleepRequest returns a request to the timeout image that writes its
+// headers right away and then holds on to the connection for sleepMs.
+func sleepRequest(domain string, sleepMs int) (*http.Request, error) {
+	return http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s?timeout=%d", domain, sleepMs), nil)
+}
+
+func TestOverloadQueueFull(t *testing.T) {
+	t.Parallel()
+	clients := test.Setup(t)
+
+	names := test.ResourceNames{
+		Service: test.ObjectNameForTest(t),
+		Image:   test.Timeout,
+	}
+	test.CleanupOnInterrupt(func() { test.TearDown(clients, names) })
+	defer test.TearDown(clients, names)
+
+	domain, client := createOverloadService(t, clients, &names)
+
+	// Far more requests than a single replica handling one request at a
+	// time can queue, all of them held for a while.
+	const requests = 200
+	t.Logf("Sending %d concurrent requests", requests)
+	var (
+		mu       sync.Mutex
+		rejected int
+	)
+	group, _ := errgroup.WithContext(context.Background())
+	for i := 0; i < requests; i++ {
+		group.Go(func() error {
+			req, err := sleepRequest(domain, 5000)
+			if err != nil {
+				return fmt.Errorf("error creating http request: %v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("error making request: %v", err)
+			}
+			switch resp.StatusCode {
+			case http.StatusOK:
+				return nil
+			case http.StatusServiceUnavailable:
+				switch by := resp.Header.Get(network.OverloadedByHeaderName); by {
+				case activator.Name, queue.Name:
+				default:
+					return fmt.Errorf("got 503 with %s = %q, want %q or %q", network.OverloadedByHeaderName, by, activator.Name, queue.Name)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				rejected++
+				return nil
+			default:
+				return fmt.Errorf("got response status code %d, want %d or %d", resp.StatusCode, http.StatusOK, http.StatusServiceUnavailable)
+			}
+		})
+	}
+	if err := group.Wait(); err != nil {
+		t.Fatalf("Error making requests: %v", err)
+	}
+	if rejected == 0 {
+		t.Errorf("None of the %d requests were rejected, want some to be shed with %d", requests, http.StatusServiceUnavailable)
+	}
+}
+
+func TestOverloadQueueTimeout(t *testing.T) {
+	t.Parallel()
+	clients := test.Setup(t)
+
+	names := test.ResourceNames{
+		Service: test.ObjectNameForTest(t),
+		Image:   test.Timeout,
+	}
+	test.CleanupOnInterrupt(func() { test.TearDown(clients, names) })
+	defer test.TearDown(clients, names)
+
+	domain, client := createOverloadService(t, clients, &names, WithRevisionTimeoutSeconds(2))
+
+	// Keep the only slot busy for longer than the revision timeout. This
+	// request writes its headers right away, so it doesn't time out itself.
+	t.Log("Occupying the only request slot")
+	busy := make(chan error, 1)
+	go func() {
+		req, err := sleepRequest(domain, 10000)
+		if err != nil {
+			busy <- err
+			return
+		}
+		_, err = client.Do(req)
+		busy <- err
+	}()
+
+	// Requests queued behind it time out without ever reaching the
+	// container. Retry a few times in case the first one got the slot.
+	var got int
+	for i := 0; i < 3; i++ {
+		req, err := sleepRequest(domain, 0)
+		if err != nil {
+			t.Fatalf("Error creating http request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		if got = resp.StatusCode; got == http.StatusGatewayTimeout {
+			break
+		}
+		t.Logf("Got response status code %d, retrying", got)
+	}
+	if got != http.StatusGatewayTimeout {
+		t.Errorf("Queued request got response status code %d, want %d", got, http.StatusGatewayTimeout)
+	}
+
+	if err := <-busy; err != nil {
+		t.Errorf("Error making the occupying request: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// +build e2e
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	pkgTest "knative.dev/pkg/test"
+	"github.com/knative/serving/pkg/deployment"
+	"github.com/knative/serving/test"
+	v1b1test "github.com/knative/serving/test/v1beta1"
+)
+
+// informationalBody is the body the test posts to the informational image.
+const informationalBody = "Hello, informational responses!"
+
+// recordingTransport remembers the last response it returned, so that its
+// trailers can be inspected once the spoofing client read the body.
+type recordingTransport struct {
+	http.RoundTripper
+
+	mu   sync.Mutex
+	resp *http.Response
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := rt.RoundTripper.RoundTrip(r)
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.resp = resp
+	return resp, err
+}
+
+func (rt *recordingTransport) last() *http.Response {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.resp
+}
+
+// TestInformationalResponses verifies that 100 Continue, 103 Early Hints and
+// trailers written by the user container pass through to the client.
+func TestInformationalResponses(t *testing.T) {
+	t.Parallel()
+	clients := test.Setup(t)
+
+	cm, err := test.GetConfigMap(clients.KubeClient).Get(deployment.ConfigName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get ConfigMap %s: %v", deployment.ConfigName, err)
+	}
+	earlyHints := strings.ToLower(cm.Data[deployment.EnableEarlyHintsKey]) == "true"
+
+	names := test.ResourceNames{
+		Service: test.ObjectNameForTest(t),
+		Image:   test.Informational,
+	}
+	test.CleanupOnInterrupt(func() { test.TearDown(clients, names) })
+	defer test.TearDown(clients, names)
+
+	objects, err := v1b1test.CreateServiceReady(t, clients, &names)
+	if err != nil {
+		t.Fatalf("Failed to create Service: %v", err)
+	}
+	domain := objects.Service.Status.URL.Host
+
+	// Ready does not actually mean Ready for a Route just yet.
+	// See https://github.com/knative/serving/issues/1582
+	t.Logf("Probing domain %s", domain)
+	if _, err := pkgTest.WaitForEndpointState(
+		clients.KubeClient,
+		t.Logf,
+		domain,
+		v1b1test.RetryingRouteInconsistency(pkgTest.IsStatusOK),
+		"WaitForSuccessfulResponse",
+		test.ServingFlags.ResolvableDomain); err != nil {
+		t.Fatalf("Error probing domain %s: %v", domain, err)
+	}
+
+	client, err := pkgTest.NewSpoofingClient(clients.KubeClient, t.Logf, domain, test.ServingFlags.ResolvableDomain)
+	if err != nil {
+		t.Fatalf("Error creating spoofing client: %v", err)
+	}
+	transport := &recordingTransport{RoundTripper: client.Client.Transport}
+	client.Client.Transport = transport
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s", domain), strings.NewReader(informationalBody))
+	if err != nil {
+		t.Fatalf("Error creating http request: %v", err)
+	}
+	req.Header.Set("Expect", "100-continue")
+
+	var (
+		mu        sync.Mutex
+		continued bool
+		links     []string
+	)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		Got100Continue: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			continued = true
+		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if code == http.StatusEarlyHints {
+				links = append(links, header.Get("Link"))
+			}
+			return nil
+		},
+	}))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d, want: %d, body: %s", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+	if got := string(resp.Body); got != informationalBody {
+		t.Errorf("Body = %q, want: %q", got, informationalBody)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !continued {
+		t.Error("Got no 100 Continue for a request expecting it")
+	}
+	if earlyHints {
+		if len(links) != 1 || links[0] == "" {
+			t.Errorf("Got the Links %q of 103 Early Hints, want one with a Link header", links)
+		}
+	} else if len(links) != 0 {
+		t.Errorf("Got 103 Early Hints %q, want none with %s disabled", links, deployment.EnableEarlyHintsKey)
+	}
+
+	want := strconv.Itoa(len(informationalBody))
+	if got := transport.last().Trailer.Get("X-Body-Length"); got != want {
+		t.Errorf("Trailer X-Body-Length = %q, want: %q", got, want)
+	}
+}
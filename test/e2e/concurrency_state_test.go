@@ -0,0 +1,217 @@
+// +build e2e
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	pkgTest "knative.dev/pkg/test"
+	"knative.dev/pkg/test/logstream"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/deployment"
+	resourcenames "github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	"github.com/knative/serving/test"
+	v1a1test "github.com/knative/serving/test/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const concurrencyStateImage = "concurrencystate"
+
+// stateActions returns the actions the concurrency state endpoint behind the
+// Service name recorded for pod.
+func stateActions(kube *kubernetes.Clientset, name, pod string) ([]string, error) {
+	body, err := kube.CoreV1().Services(test.ServingNamespace).ProxyGet("http", name, "80", "/",
+		map[string]string{"pod": pod}).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var actions []string
+	err = json.Unmarshal(body, &actions)
+	return actions, err
+}
+
+// TestConcurrencyStatePauseResume verifies that the queue-proxy notifies the
+// concurrency state endpoint when its pod becomes busy and idle again.
+// It changes config-deployment, so it doesn't run in parallel.
+func TestConcurrencyStatePauseResume(t *testing.T) {
+	cancel := logstream.Start(t)
+	defer cancel()
+
+	clients := Setup(t)
+	kube := clients.KubeClient.Kube
+
+	// The endpoint is a plain Deployment, since the queue-proxy of a
+	// Knative Service would notify it about itself.
+	name := test.ObjectNameForTest(t)
+	labels := map[string]string{"app": name}
+	if _, err := kube.AppsV1().Deployments(test.ServingNamespace).Create(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "recorder",
+						Image: pkgTest.ImagePath(concurrencyStateImage),
+					}},
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to create the Deployment of the concurrency state endpoint: %v", err)
+	}
+	defer kube.AppsV1().Deployments(test.ServingNamespace).Delete(name, &metav1.DeleteOptions{})
+	if _, err := kube.CoreV1().Services(test.ServingNamespace).Create(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       80,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to create the Service of the concurrency state endpoint: %v", err)
+	}
+	defer kube.CoreV1().Services(test.ServingNamespace).Delete(name, &metav1.DeleteOptions{})
+
+	if err := pkgTest.WaitForDeploymentState(clients.KubeClient, name, func(d *appsv1.Deployment) (bool, error) {
+		return d.Status.ReadyReplicas > 0, nil
+	}, "ConcurrencyStateEndpointIsReady", test.ServingNamespace, 2*time.Minute); err != nil {
+		t.Fatalf("The concurrency state endpoint did not become ready: %v", err)
+	}
+
+	// Only the revisions created from now on are notifying the endpoint.
+	cms := test.GetConfigMap(clients.KubeClient)
+	cm, err := cms.Get(deployment.ConfigName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get ConfigMap %s: %v", deployment.ConfigName, err)
+	}
+	original := cm.DeepCopy()
+	defer func() {
+		latest, err := cms.Get(deployment.ConfigName, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("Failed to get ConfigMap %s: %v", deployment.ConfigName, err)
+			return
+		}
+		latest.Data = original.Data
+		if _, err := cms.Update(latest); err != nil {
+			t.Errorf("Failed to restore ConfigMap %s: %v", deployment.ConfigName, err)
+		}
+	}()
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	cm.Data[deployment.ConcurrencyStateEndpointKey] = fmt.Sprintf("http://%s.%s.svc.cluster.local", name, test.ServingNamespace)
+	if _, err := cms.Update(cm); err != nil {
+		t.Fatalf("Failed to update ConfigMap %s: %v", deployment.ConfigName, err)
+	}
+
+	var names test.ResourceNames
+	defer func() { test.TearDown(clients, names) }()
+	test.CleanupOnInterrupt(func() { test.TearDown(clients, names) })
+
+	// The config change is picked up asynchronously, so retry with a new
+	// Service until the queue-proxy of its revision knows the endpoint.
+	var objects *v1a1test.ResourceObjects
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		test.TearDown(clients, names)
+		names = test.ResourceNames{
+			Service: test.ObjectNameForTest(t),
+			Image:   "helloworld",
+		}
+		objects, err = v1a1test.CreateRunLatestServiceReady(t, clients, &names, &v1a1test.Options{})
+		if err != nil {
+			return false, err
+		}
+		d, err := kube.AppsV1().Deployments(test.ServingNamespace).Get(resourcenames.Deployment(objects.Revision), metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range d.Spec.Template.Spec.Containers {
+			for _, e := range c.Env {
+				if e.Name == "CONCURRENCY_STATE_ENDPOINT" {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}); err != nil {
+		t.Fatalf("Failed to create a Service notifying the concurrency state endpoint: %v", err)
+	}
+
+	domain := objects.Route.Status.URL.Host
+	if _, err := pkgTest.WaitForEndpointState(
+		clients.KubeClient,
+		t.Logf,
+		domain,
+		v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(helloworldResponse))),
+		"HelloWorldServesText",
+		test.ServingFlags.ResolvableDomain); err != nil {
+		t.Fatalf("The endpoint %s didn't serve the expected text %q: %v", domain, helloworldResponse, err)
+	}
+
+	pods, err := kube.CoreV1().Pods(test.ServingNamespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", serving.RevisionLabelKey, objects.Revision.Name),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		t.Fatalf("No pods or error: %v", err)
+	}
+	pod := pods.Items[0].Name
+
+	// The pod was resumed for the requests and paused once they were done.
+	var actions []string
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		actions, err = stateActions(kube, name, pod)
+		if err != nil {
+			t.Logf("Failed to get the actions of pod %s: %v", pod, err)
+			return false, nil
+		}
+		return len(actions) >= 2 && actions[0] == "resume" && actions[len(actions)-1] == "pause", nil
+	}); err != nil {
+		t.Fatalf("Got the actions %q for pod %s, want resume first and pause last: %v", actions, pod, err)
+	}
+
+	// A new request resumes the pod again.
+	if _, err := sendRequest(t, clients, test.ServingFlags.ResolvableDomain, domain); err != nil {
+		t.Fatalf("Failed to send a request to %s: %v", domain, err)
+	}
+	paused := len(actions)
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		actions, err = stateActions(kube, name, pod)
+		if err != nil {
+			t.Logf("Failed to get the actions of pod %s: %v", pod, err)
+			return false, nil
+		}
+		return len(actions) > paused && actions[paused] == "resume", nil
+	}); err != nil {
+		t.Errorf("Got the actions %q for pod %s, want a resume after the request: %v", actions, pod, err)
+	}
+}
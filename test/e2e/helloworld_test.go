@@ -39,6 +39,8 @@ func TestHelloWorld(t *testing.T) {
 	defer cancel()
 
 	clients := Setup(t)
+	artifacts := test.StartArtifacts(t, clients, test.ServingNamespace)
+	defer artifacts.Close()
 
 	names := test.ResourceNames{
 		Service: test.ObjectNameForTest(t),
@@ -61,7 +63,7 @@ func TestHelloWorld(t *testing.T) {
 		clients.KubeClient,
 		t.Logf,
 		domain,
-		v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(test.HelloWorldText))),
+		artifacts.TraceChecker(domain, v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(test.HelloWorldText)))),
 		"HelloWorldServesText",
 		test.ServingFlags.ResolvableDomain); err != nil {
 		t.Fatalf("The endpoint for Route %s at domain %s didn't serve the expected text \"%s\": %v", names.Route, domain, test.HelloWorldText, err)
@@ -90,6 +92,8 @@ func TestQueueSideCarResourceLimit(t *testing.T) {
 	defer cancel()
 
 	clients := Setup(t)
+	artifacts := test.StartArtifacts(t, clients, test.ServingNamespace)
+	defer artifacts.Close()
 
 	names := test.ResourceNames{
 		Service: test.ObjectNameForTest(t),
@@ -124,7 +128,7 @@ func TestQueueSideCarResourceLimit(t *testing.T) {
 		clients.KubeClient,
 		t.Logf,
 		domain,
-		v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(test.HelloWorldText))),
+		artifacts.TraceChecker(domain, v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(test.HelloWorldText)))),
 		"HelloWorldServesText",
 		test.ServingFlags.ResolvableDomain); err != nil {
 		t.Fatalf("The endpoint for Route %s at domain %s didn't serve the expected text \"%s\": %v", names.Route, domain, test.HelloWorldText, err)
@@ -0,0 +1,101 @@
+// +build e2e
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	pkgTest "knative.dev/pkg/test"
+	"knative.dev/pkg/test/logstream"
+	"github.com/knative/serving/pkg/apis/serving"
+	"github.com/knative/serving/pkg/network"
+	resourcenames "github.com/knative/serving/pkg/reconciler/revision/resources/names"
+	"github.com/knative/serving/test"
+	v1a1test "github.com/knative/serving/test/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestIPFamily verifies that a revision is served by its queue-proxy and,
+// after scaling to zero, through the activator whatever the IP family of the
+// cluster's pods. On IPv6 single-stack and dual-stack clusters this covers
+// the formatting of pod addresses by the activator, queue-proxy and the
+// autoscaler's scraper.
+func TestIPFamily(t *testing.T) {
+	t.Parallel()
+	cancel := logstream.Start(t)
+	defer cancel()
+
+	clients := Setup(t)
+
+	names := test.ResourceNames{
+		Service: test.ObjectNameForTest(t),
+		Image:   "helloworld",
+	}
+	test.CleanupOnInterrupt(func() { test.TearDown(clients, names) })
+	defer test.TearDown(clients, names)
+
+	t.Log("Creating a new Service")
+	resources, err := v1a1test.CreateRunLatestServiceReady(t, clients, &names, &v1a1test.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create initial Service: %v: %v", names.Service, err)
+	}
+	domain := resources.Route.Status.URL.Host
+
+	pods, err := clients.KubeClient.Kube.CoreV1().Pods(test.ServingNamespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", serving.RevisionLabelKey, resources.Revision.Name),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		t.Fatalf("No pods or error: %v", err)
+	}
+	podIP := net.ParseIP(pods.Items[0].Status.PodIP)
+	if podIP == nil {
+		t.Fatalf("Pod %s has no valid IP: %q", pods.Items[0].Name, pods.Items[0].Status.PodIP)
+	}
+	t.Logf("Pod %s has the %s address %s", pods.Items[0].Name, network.FamilyOf(podIP), podIP)
+
+	if _, err := pkgTest.WaitForEndpointState(
+		clients.KubeClient,
+		t.Logf,
+		domain,
+		v1a1test.RetryingRouteInconsistency(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, pkgTest.MatchesBody(test.HelloWorldText))),
+		"HelloWorldServesText",
+		test.ServingFlags.ResolvableDomain); err != nil {
+		t.Fatalf("The endpoint for Route %s at domain %s didn't serve the expected text %q: %v", names.Route, domain, test.HelloWorldText, err)
+	}
+
+	// Scaling to zero needs the autoscaler to scrape the pod, and the
+	// request after it needs the activator to probe and forward to the
+	// new pod.
+	if err := WaitForScaleToZero(t, resourcenames.Deployment(resources.Revision), clients); err != nil {
+		t.Fatalf("Could not scale to zero: %v", err)
+	}
+
+	resp, err := sendRequest(t, clients, test.ServingFlags.ResolvableDomain, domain)
+	if err != nil {
+		t.Fatalf("Error making request through the activator: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(resp.Body), test.HelloWorldText) {
+		t.Errorf("Got %d %q through the activator, want: %d %q", resp.StatusCode, resp.Body, http.StatusOK, test.HelloWorldText)
+	}
+}
@@ -21,6 +21,7 @@ package test
 
 import (
 	"flag"
+	"os"
 
 	"knative.dev/pkg/test"
 	"knative.dev/pkg/test/logging"
@@ -54,7 +55,8 @@ var ServingFlags = initializeServingFlags()
 
 // ServingEnvironmentFlags holds the e2e flags needed only by the serving repo.
 type ServingEnvironmentFlags struct {
-	ResolvableDomain bool // Resolve Route controller's `domainSuffix`
+	ResolvableDomain bool   // Resolve Route controller's `domainSuffix`
+	Artifacts        string // Directory to write the artifacts of the tests to
 }
 
 func initializeServingFlags() *ServingEnvironmentFlags {
@@ -63,6 +65,9 @@ func initializeServingFlags() *ServingEnvironmentFlags {
 	flag.BoolVar(&f.ResolvableDomain, "resolvabledomain", false,
 		"Set this flag to true if you have configured the `domainSuffix` on your Route controller to a domain that will resolve to your test cluster.")
 
+	flag.StringVar(&f.Artifacts, "artifacts", os.Getenv("ARTIFACTS"),
+		"The directory to write the artifacts of the tests to, for flake analysis. Defaults to $ARTIFACTS, and nothing is written if empty.")
+
 	flag.Parse()
 	flag.Set("alsologtostderr", "true")
 	logging.InitializeLogger(test.Flags.LogVerbose)
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// LatencyHeaderName is the header of a request asking the test images
+	// to wait for the given duration, e.g. "500ms", before handling it.
+	LatencyHeaderName = "K-Test-Latency"
+
+	// JitterHeaderName is the header of a request asking the test images
+	// to wait for up to the given duration more, picked at random.
+	JitterHeaderName = "K-Test-Jitter"
+)
+
+// Latency is the delay the test images are asked to inject in a request.
+// As they wait behind the activator and the queue-proxy, the request stays
+// in flight through both of them, e.g. to check it survives their
+// restarts during an upgrade.
+type Latency struct {
+	// Delay is how long the request is held at least.
+	Delay time.Duration
+	// Jitter is the most the request is held on top of the Delay.
+	Jitter time.Duration
+}
+
+// Apply asks for the latency in the headers of req.
+func (l Latency) Apply(req *http.Request) {
+	if l.Delay > 0 {
+		req.Header.Set(LatencyHeaderName, l.Delay.String())
+	}
+	if l.Jitter > 0 {
+		req.Header.Set(JitterHeaderName, l.Jitter.String())
+	}
+}
+
+// LatencyHandler wraps the handler of a test image to wait as asked by the
+// headers of the request before calling it.
+func LatencyHandler(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		delay, _ := time.ParseDuration(r.Header.Get(LatencyHeaderName))
+		if jitter, _ := time.ParseDuration(r.Header.Get(JitterHeaderName)); jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
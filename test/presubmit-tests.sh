@@ -29,4 +29,10 @@ source $(dirname $0)/../vendor/github.com/knative/test-infra/scripts/presubmit-t
 
 # We use the default build, unit and integration test runners.
 
+# The admission path of the data plane must not get slower unnoticed.
+function post_unit_tests() {
+  header "Checking the benchmarks against their baseline"
+  $(dirname $0)/benchmarks.sh
+}
+
 main $@
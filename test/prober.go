@@ -140,6 +140,7 @@ type manager struct {
 	logf      logging.FormatLogger
 	clients   *Clients
 	minProbes int64
+	latency   Latency
 
 	m      sync.RWMutex
 	probes map[string]Prober
@@ -182,6 +183,7 @@ func (m *manager) Spawn(domain string) Prober {
 			p.errCh <- err
 			return
 		}
+		m.latency.Apply(req)
 
 		// We keep polling the domain and accumulate success rates
 		// to ultimately establish the SLI and compare to the SLO.
@@ -251,6 +253,16 @@ func RunRouteProber(logf logging.FormatLogger, clients *Clients, domain string)
 	return pm
 }
 
+// RunRouteProberWithLatency is like RunRouteProber, but its probes ask the
+// test image for the given latency, so that they are in flight while the
+// data plane components restart.
+func RunRouteProberWithLatency(logf logging.FormatLogger, clients *Clients, domain string, latency Latency) Prober {
+	pm := NewProberManager(logf, clients, 10).(*manager)
+	pm.latency = latency
+	pm.Spawn(domain)
+	return pm
+}
+
 // AssertProberDefault is a helper for stopping the Prober and checking its SLI
 // against the default SLO, which requires perfect responses.
 // This takes `testing.T` so that it may be used in `defer`.
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/knative/serving/test"
+)
+
+type stateRequest struct {
+	Action string `json:"action"`
+	Pod    string `json:"pod"`
+}
+
+var (
+	mux     sync.Mutex
+	actions = make(map[string][]string)
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req stateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		actions[req.Pod] = append(actions[req.Pod], req.Action)
+	case http.MethodGet:
+		mux.Lock()
+		defer mux.Unlock()
+		got := actions[r.URL.Query().Get("pod")]
+		if got == nil {
+			got = []string{}
+		}
+		json.NewEncoder(w).Encode(got)
+	default:
+		http.Error(w, "only GET and POST are supported", http.StatusMethodNotAllowed)
+	}
+}
+
+func main() {
+	test.ListenAndServeGracefully(":8080", handler)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/knative/serving/test"
+)
+
+const (
+	// earlyHintsLink is the Link header of the 103 Early Hints response.
+	earlyHintsLink = "</style.css>; rel=preload; as=style"
+	// bodyLengthTrailer holds the length of the request body.
+	bodyLengthTrailer = "X-Body-Length"
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	// Reading the body makes the server send a 100 Continue to clients
+	// that expect one.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Link", earlyHintsLink)
+	w.WriteHeader(http.StatusEarlyHints)
+	w.Header().Del("Link")
+
+	w.Header().Set("Trailer", bodyLengthTrailer)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	w.Header().Set(bodyLengthTrailer, strconv.Itoa(len(body)))
+}
+
+func main() {
+	test.ListenAndServeGracefully(":8080", handler)
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/knative/serving/test"
 	"github.com/knative/serving/test/e2e"
@@ -32,6 +33,10 @@ import (
 
 const pipe = "/tmp/prober-signal"
 
+// probeLatency keeps the requests of the latency prober in flight for long
+// enough to be caught by the restarts of the activator and queue-proxies.
+var probeLatency = test.Latency{Delay: 500 * time.Millisecond, Jitter: time.Second}
+
 func TestProbe(t *testing.T) {
 	// We run the prober as a golang test because it fits in nicely with
 	// the rest of our integration tests, and AssertProberDefault needs
@@ -65,6 +70,24 @@ func TestProbe(t *testing.T) {
 	prober := test.RunRouteProber(log.Printf, clients, domain)
 	defer test.AssertProberDefault(t, prober)
 
+	// Probe a second Service with slow requests, which graceful shutdowns
+	// must let finish.
+	latencyNames := test.ResourceNames{
+		Service: "upgrade-probe-latency",
+		Image:   test.PizzaPlanet1,
+	}
+	defer test.TearDown(clients, latencyNames)
+
+	objects, err = v1a1test.CreateRunLatestServiceLegacyReady(t, clients, &latencyNames, &v1a1test.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create Service: %v", err)
+	}
+	latencyDomain := objects.Service.Status.URL.Host
+	assertServiceResourcesUpdated(t, clients, latencyNames, latencyDomain, test.PizzaPlanetText1)
+
+	latencyProber := test.RunRouteProberWithLatency(log.Printf, clients, latencyDomain, probeLatency)
+	defer test.AssertProberDefault(t, latencyProber)
+
 	// e2e-upgrade-test.sh will close this pipe to signal the upgrade is
 	// over, at which point we will finish the test and check the prober.
 	_, _ = ioutil.ReadFile(pipe)
@@ -0,0 +1,72 @@
+// +build postupgrade
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"net/http"
+	"testing"
+
+	pkgTest "knative.dev/pkg/test"
+	"knative.dev/pkg/test/spoof"
+	"github.com/knative/serving/pkg/network"
+	"github.com/knative/serving/pkg/queue"
+	"github.com/knative/serving/test"
+	"github.com/knative/serving/test/e2e"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestProtocolVersionPostUpgrade checks that the queue-proxies of a Service
+// created before the upgrade are rolled to the current data plane protocol.
+func TestProtocolVersionPostUpgrade(t *testing.T) {
+	t.Parallel()
+	clients := e2e.Setup(t)
+
+	svc, err := clients.ServingAlphaClient.Services.Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get Service: %v", err)
+	}
+	routeDomain := svc.Status.URL.Host
+
+	// Send a request to bring up the Revision, in case it's scaled to zero.
+	assertServiceResourcesUpdated(t, clients, test.ResourceNames{Service: serviceName}, routeDomain, test.PizzaPlanetText1)
+
+	// The activator rejects probes for the queue-proxy while it's in the
+	// path, and queue-proxies not rolled yet announce an older version.
+	_, err = pkgTest.WaitForEndpointState(
+		clients.KubeClient,
+		t.Logf,
+		routeDomain,
+		pkgTest.Retrying(pkgTest.MatchesAllOf(pkgTest.IsStatusOK, speaksProtocolVersion(network.CurrentProtocolVersion)), http.StatusBadRequest),
+		"WaitForCurrentProtocolVersion",
+		test.ServingFlags.ResolvableDomain,
+		pkgTest.WithHeader(http.Header{
+			network.ProbeHeaderName:           []string{queue.Name},
+			network.ProtocolVersionHeaderName: []string{network.CurrentProtocolVersion.String()},
+		}))
+	if err != nil {
+		t.Fatalf("The queue-proxies of Service %s didn't speak protocol version %v: %v", serviceName, network.CurrentProtocolVersion, err)
+	}
+}
+
+// speaksProtocolVersion polls until the response announces the version.
+func speaksProtocolVersion(version network.ProtocolVersion) spoof.ResponseChecker {
+	return func(resp *spoof.Response) (bool, error) {
+		return resp.Header.Get(network.ProtocolVersionHeaderName) == version.String(), nil
+	}
+}
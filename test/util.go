@@ -37,11 +37,12 @@ func ListenAndServeGracefully(addr string, handler func(w http.ResponseWriter, r
 
 // ListenAndServeGracefullyWithPattern creates an HTTP server, listens on the defined address
 // and handles incoming requests specified on pattern(path) with the given handlers.
+// The handlers inject the Latency asked for by the requests.
 // It blocks until SIGTERM is received and the underlying server has shutdown gracefully.
 func ListenAndServeGracefullyWithPattern(addr string, handlers map[string]func(w http.ResponseWriter, r *http.Request)) {
 	m := http.NewServeMux()
 	for pattern, handler := range handlers {
-		m.HandleFunc(pattern, handler)
+		m.HandleFunc(pattern, LatencyHandler(handler))
 	}
 
 	server := http.Server{Addr: addr, Handler: h2c.NewHandler(m, &http2.Server{})}